@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runAdmin shows the runtime controls of a NotifyHub REST server, pauses
+// and resumes its platforms, and drains its queues
+func runAdmin(e env, args []string) int {
+	if len(args) == 0 || (args[0] != "status" && args[0] != "pause" && args[0] != "resume" && args[0] != "drain") {
+		fmt.Fprintln(e.stderr, "Usage: notifyhub admin status --server <url> [flags]")
+		fmt.Fprintln(e.stderr, "       notifyhub admin pause|resume <platform> --server <url> [flags]")
+		fmt.Fprintln(e.stderr, "       notifyhub admin drain <queue> --server <url> [flags]")
+		return exitUsage
+	}
+	action := args[0]
+	args = args[1:]
+	var name string
+	if action != "status" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	fs := newFlagSet("admin "+action, e)
+	server := fs.String("server", "", "URL of the NotifyHub REST server")
+	token := fs.String("token", os.Getenv("NOTIFYHUB_ADMIN_TOKEN"), "admin bearer token of --server, $NOTIFYHUB_ADMIN_TOKEN by default")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if name == "" && fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	switch {
+	case *server == "":
+		fmt.Fprintf(e.stderr, "notifyhub admin %s: --server is required\n", action)
+		return exitUsage
+	case action != "status" && name == "":
+		fmt.Fprintf(e.stderr, "notifyhub admin %s: a platform or queue name is required\n", action)
+		return exitUsage
+	}
+
+	var path string
+	switch action {
+	case "status":
+		path = "/v1/admin/controls"
+	case "drain":
+		path = "/v1/admin/queues/" + url.PathEscape(name) + "/drain"
+	default:
+		path = "/v1/admin/platforms/" + url.PathEscape(name) + "/" + action
+	}
+	var reply struct {
+		PausedPlatforms map[string]int `json:"paused_platforms"`
+		Queues          map[string]int `json:"queues"`
+		Released        int            `json:"released"`
+		Drained         int            `json:"drained"`
+	}
+	if err := callAdmin(*server, *token, path, action != "status", &reply); err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub admin %s: %v\n", action, err)
+		return exitFailure
+	}
+
+	switch action {
+	case "status":
+		for _, platform := range sortedNames(reply.PausedPlatforms) {
+			fmt.Fprintf(e.stdout, "platform %s: paused, %d held\n", platform, reply.PausedPlatforms[platform])
+		}
+		for _, queue := range sortedNames(reply.Queues) {
+			fmt.Fprintf(e.stdout, "queue %s: %d waiting\n", queue, reply.Queues[queue])
+		}
+	case "pause":
+		fmt.Fprintf(e.stdout, "platform %s paused\n", name)
+	case "resume":
+		fmt.Fprintf(e.stdout, "platform %s resumed, %d held deliveries released\n", name, reply.Released)
+	case "drain":
+		fmt.Fprintf(e.stdout, "queue %s drained, %d removed\n", name, reply.Drained)
+	}
+	return exitOK
+}
+
+// callAdmin calls an admin endpoint of a REST server and decodes its reply
+func callAdmin(server, token, path string, post bool, reply interface{}) error {
+	method := http.MethodGet
+	if post {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(server, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil || failure.Error == "" {
+			return fmt.Errorf("%s", resp.Status)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, failure.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return fmt.Errorf("%s: %w", resp.Status, err)
+	}
+	return nil
+}
+
+// sortedNames returns the keys of a map of counts in order
+func sortedNames(counts map[string]int) []string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,186 @@
+// Command notifyhub sends notifications from the shell and inspects the
+// configuration and results of NotifyHub, e.g. for smoke tests and cron jobs:
+//
+//	notifyhub send --config notifyhub.yaml --platform feishu --title "Backup" --body-file report.md
+//	notifyhub validate --config notifyhub.yaml --preflight
+//	notifyhub render --template alert.tmpl --var host=db1
+//	notifyhub receipts --file receipts.jsonl --failed
+//	notifyhub dlq list --file dead-letters.jsonl
+//	notifyhub audit export --file audit.jsonl --tenant acme --out evidence.zip
+//	notifyhub admin pause feishu --server http://localhost:8080
+//	notifyhub replay --config notifyhub.yaml --archive --from 2026-10-16 --failed --rate 5
+//
+// Every command prints its flags with -h. Commands exit with status 0 on
+// success, 1 when a send or check fails and 2 on usage errors.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+)
+
+// Exit statuses
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// env is the environment of a command
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// command is a subcommand of the CLI
+type command struct {
+	summary string
+	run     func(e env, args []string) int
+}
+
+// commands are the subcommands by name
+var commands = map[string]command{
+	"send":     {"send a message", runSend},
+	"validate": {"check a configuration", runValidate},
+	"render":   {"render a message template", runRender},
+	"receipts": {"show send receipts", runReceipts},
+	"dlq":      {"list, resend or flush dead letters", runDLQ},
+	"audit":    {"export or verify audit evidence packages", runAudit},
+	"admin":    {"pause platforms and drain queues of a server", runAdmin},
+	"replay":   {"resend dead letters or archived messages", runReplay},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}))
+}
+
+// run runs the command line and returns the exit status
+func run(args []string, e env) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(e.stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(e.stderr, "notifyhub: unknown command %q\n", args[0])
+		usage(e.stderr)
+		return exitUsage
+	}
+	return cmd.run(e, args[1:])
+}
+
+// usage prints the commands
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: notifyhub <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-9s %s\n", name, commands[name].summary)
+	}
+}
+
+// newFlagSet returns the flag set of a command, printing errors and help to
+// stderr
+func newFlagSet(name string, e env) *flag.FlagSet {
+	fs := flag.NewFlagSet("notifyhub "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	return fs
+}
+
+// parseFlags parses the flags of a command, returning the exit status when
+// the command should stop
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	err := fs.Parse(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK, false
+	case err != nil:
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// configFlags are the flags that select a configuration
+type configFlags struct {
+	path    string
+	profile string
+	env     string
+}
+
+// register adds the configuration flags to a flag set
+func (c *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.path, "config", "", "configuration file (JSON, YAML or TOML)")
+	fs.StringVar(&c.profile, "profile", "", "profile of the configuration file")
+	fs.StringVar(&c.env, "env-prefix", config.ReferenceEnvPrefix, "prefix of the environment variables read, empty to read none")
+}
+
+// load builds the configuration
+func (c *configFlags) load(opts ...config.Option) (*config.Config, error) {
+	if c.env != "" {
+		opts = append(opts, config.FromEnv(c.env))
+	}
+	switch {
+	case c.path == "" && c.profile != "":
+		return nil, errors.New("--profile requires --config")
+	case c.path == "":
+		return config.New(opts...)
+	case c.profile != "":
+		return config.LoadProfile(c.path, c.profile, opts...)
+	}
+	return config.LoadFile(c.path, opts...)
+}
+
+// listFlag is a repeatable flag
+type listFlag []string
+
+// String implements flag.Value
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// pairs returns the values of key=value flags
+func (l listFlag) pairs() (map[string]interface{}, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]interface{}, len(l))
+	for _, pair := range l {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// readInput reads a file, or stdin for "-"
+func readInput(e env, path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(e.stdin)
+	}
+	return os.ReadFile(path)
+}
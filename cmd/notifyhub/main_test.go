@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var failing atomic.Bool
+	var bodies atomic.Value
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var b bytes.Buffer
+		_, _ = b.ReadFrom(r.Body)
+		bodies.Store(b.String())
+	}))
+	defer webhook.Close()
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	cfg := write("notifyhub.json", `{"max_retries": 0, "webhook": {"url": "`+webhook.URL+`", "max_retries": 0}}`)
+	invalid := write("invalid.json", `{"email": {"host": "smtp.example.com", "port": 70000, "from": "ops@example.com"}}`)
+	unreachable := write("unreachable.json", `{"webhook": {"url": "http://127.0.0.1:1/hook"}}`)
+	tmpl := write("alert.tmpl", `{{.host}} is down`)
+	auditLog := write("audit.jsonl", `{"time":"2026-10-16T10:00:00Z","action":"message_sent","message_id":"m1","metadata":{"tenant":"acme"},"content_hash":"sha256:0"}
+{"time":"2026-10-16T11:00:00Z","action":"message_sent","message_id":"m2","metadata":{"tenant":"globex"}}
+`)
+	evidence := filepath.Join(dir, "evidence.zip")
+	receipts := filepath.Join(dir, "receipts.jsonl")
+	deadLetters := filepath.Join(dir, "dead-letters.jsonl")
+	sealedLetters := filepath.Join(dir, "sealed-letters.jsonl")
+	flushLetters := filepath.Join(dir, "flush-letters.jsonl")
+	replayKeys := filepath.Join(dir, "replay-keys.txt")
+	t.Setenv("NOTIFYHUB_TEST_DLQ_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		failing    bool
+		wantStatus int
+		wantOut    string
+		wantErr    string
+		wantBody   string
+	}{
+		{name: "no command", args: nil, wantStatus: exitUsage, wantErr: "Usage: notifyhub"},
+		{name: "unknown command", args: []string{"mail"}, wantStatus: exitUsage, wantErr: `unknown command "mail"`},
+		{name: "help", args: []string{"send", "-h"}, wantStatus: exitOK, wantErr: "-body-file"},
+
+		{name: "validate", args: []string{"validate", "--config", cfg, "--env-prefix="}, wantStatus: exitOK, wantOut: "configuration is valid"},
+		{name: "validate effective", args: []string{"validate", "--config", cfg, "--env-prefix=", "--effective"}, wantStatus: exitOK, wantOut: "webhook.url = \"" + webhook.URL + "\" (file)"},
+		{name: "validate problems", args: []string{"validate", "--config", invalid, "--env-prefix="}, wantStatus: exitFailure, wantErr: "email.port"},
+		{name: "validate preflight", args: []string{"validate", "--config", cfg, "--env-prefix=", "--preflight"}, wantStatus: exitOK, wantOut: "webhook: ready (credentials check"},
+		{name: "validate preflight unreachable", args: []string{"validate", "--config", unreachable, "--env-prefix=", "--preflight"}, wantStatus: exitFailure, wantOut: "configuration is valid", wantErr: "webhook: not ready"},
+		{name: "validate profile without config", args: []string{"validate", "--profile", "prod"}, wantStatus: exitFailure, wantErr: "--profile requires --config"},
+
+		{name: "render", args: []string{"render", "--template", tmpl, "--var", "host=db1"}, wantStatus: exitOK, wantOut: "db1 is down"},
+		{name: "render stdin", args: []string{"render", "--template", "-", "--var", "n=3"}, stdin: "{{.n}} alerts", wantStatus: exitOK, wantOut: "3 alerts"},
+		{name: "render without template", args: []string{"render"}, wantStatus: exitUsage, wantErr: "--template is required"},
+		{name: "render bad var", args: []string{"render", "--template", tmpl, "--var", "host"}, wantStatus: exitUsage, wantErr: "not a key=value pair"},
+
+		{name: "send without targets", args: []string{"send", "--config", cfg, "--body", "hi"}, wantStatus: exitUsage, wantErr: "--platform or --to is required"},
+		{name: "send empty", args: []string{"send", "--config", cfg, "--platform", "webhook"}, wantStatus: exitUsage, wantErr: "cannot both be empty"},
+		{name: "send two bodies", args: []string{"send", "--config", cfg, "--platform", "webhook", "--body", "a", "--template", tmpl}, wantStatus: exitUsage, wantErr: "only one of"},
+		{name: "send bad priority", args: []string{"send", "--config", cfg, "--platform", "webhook", "--body", "a", "--priority", "9"}, wantStatus: exitUsage, wantErr: "--priority"},
+		{name: "send untyped target", args: []string{"send", "--config", cfg, "--to", "ops", "--body", "a"}, wantStatus: exitUsage, wantErr: "needs a type"},
+		{
+			name:       "send to platform",
+			args:       []string{"send", "--config", cfg, "--env-prefix=", "--platform", "webhook", "--title", "Backup", "--body-file", "-", "--priority", "high", "--receipts", receipts},
+			stdin:      "backup finished",
+			wantStatus: exitOK,
+			wantOut:    "success: 1 delivered",
+			wantBody:   "backup finished",
+		},
+		{
+			name:       "send template to url",
+			args:       []string{"send", "--config", cfg, "--env-prefix=", "--to", webhook.URL, "--template", tmpl, "--var", "host=db2", "--receipts", receipts, "--json"},
+			wantStatus: exitOK,
+			wantOut:    `"status": "success"`,
+			wantBody:   "db2 is down",
+		},
+		{
+			name:       "send dry run",
+			args:       []string{"send", "--config", cfg, "--env-prefix=", "--platform", "webhook", "--body", "not sent", "--dry-run", "--json"},
+			wantStatus: exitOK,
+			wantOut:    `"dry_run": true`,
+		},
+		{
+			name:       "send failure",
+			args:       []string{"send", "--config", cfg, "--env-prefix=", "--platform", "webhook", "--title", "Lost", "--body", "retry me", "--receipts", receipts, "--dead-letter", deadLetters},
+			failing:    true,
+			wantStatus: exitFailure,
+			wantOut:    "failed",
+		},
+
+		{name: "receipts", args: []string{"receipts", "--file", receipts}, wantStatus: exitOK, wantOut: "0 failed"},
+		{name: "receipts failed", args: []string{"receipts", "--file", receipts, "--failed", "--json"}, wantStatus: exitOK, wantOut: `"status":"failed"`},
+		{name: "receipts unknown id", args: []string{"receipts", "--file", receipts, "--id", "nope"}, wantStatus: exitFailure, wantErr: `no receipt of message "nope"`},
+		{name: "receipts without source", args: []string{"receipts"}, wantStatus: exitUsage, wantErr: "--file or --server is required"},
+
+		{name: "dlq without action", args: []string{"dlq"}, wantStatus: exitUsage, wantErr: "Usage: notifyhub dlq"},
+		{name: "dlq list", args: []string{"dlq", "list", "--file", deadLetters}, wantStatus: exitOK, wantOut: `"Lost"`},
+		{name: "dlq replay failing", args: []string{"dlq", "replay", "--file", deadLetters, "--config", cfg, "--env-prefix="}, failing: true, wantStatus: exitFailure, wantOut: "failed"},
+		{name: "dlq replay", args: []string{"dlq", "replay", "--file", deadLetters, "--config", cfg, "--env-prefix="}, wantStatus: exitOK, wantOut: "success", wantBody: "retry me"},
+		{name: "dlq list after replay", args: []string{"dlq", "list", "--file", deadLetters}, wantStatus: exitOK, wantOut: ""},
+		{
+			name:       "send failure sealed",
+			args:       []string{"send", "--config", cfg, "--env-prefix=", "--platform", "webhook", "--title", "Secret", "--body", "sealed", "--dead-letter", sealedLetters, "--dead-letter-key-env", "NOTIFYHUB_TEST_DLQ_KEY"},
+			failing:    true,
+			wantStatus: exitFailure,
+			wantOut:    "failed",
+		},
+		{name: "dlq list sealed without key", args: []string{"dlq", "list", "--file", sealedLetters}, wantStatus: exitFailure, wantErr: "payload is encrypted"},
+		{name: "dlq list sealed", args: []string{"dlq", "list", "--file", sealedLetters, "--dead-letter-key-env", "NOTIFYHUB_TEST_DLQ_KEY"}, wantStatus: exitOK, wantOut: `"Secret"`},
+		{name: "audit without action", args: []string{"audit"}, wantStatus: exitUsage, wantErr: "Usage: notifyhub audit"},
+		{name: "audit export", args: []string{"audit", "export", "--file", auditLog, "--tenant", "acme", "--from", "2026-10-16", "--out", evidence}, wantStatus: exitOK, wantOut: "exported 1 entries"},
+		{name: "audit export bad date", args: []string{"audit", "export", "--file", auditLog, "--to", "today", "--out", evidence}, wantStatus: exitUsage, wantErr: "invalid --to"},
+		{name: "audit verify", args: []string{"audit", "verify", evidence}, wantStatus: exitOK, wantOut: "OK, 1 entries"},
+		{name: "audit verify not a package", args: []string{"audit", "verify", auditLog}, wantStatus: exitFailure, wantOut: "FAILED: invalid evidence package"},
+		{name: "dlq two keys", args: []string{"dlq", "list", "--file", sealedLetters, "--dead-letter-key-env", "A", "--dead-letter-key-file", "b"}, wantStatus: exitUsage, wantErr: "only one of"},
+		{name: "dlq flush stdin", args: []string{"dlq", "flush", "--file", "-"}, wantStatus: exitUsage, wantErr: "must be a file"},
+		{
+			name:       "send failure to flush",
+			args:       []string{"send", "--config", cfg, "--env-prefix=", "--platform", "webhook", "--title", "Flood", "--body", "discard me", "--dead-letter", flushLetters, "--dead-letter-key-env", "NOTIFYHUB_TEST_DLQ_KEY"},
+			failing:    true,
+			wantStatus: exitFailure,
+			wantOut:    "failed",
+		},
+		{name: "replay without source", args: []string{"replay", "--config", cfg, "--env-prefix="}, wantStatus: exitUsage, wantErr: "one of --dead-letters and --archive"},
+		{name: "replay archive not configured", args: []string{"replay", "--config", cfg, "--env-prefix=", "--archive"}, wantStatus: exitFailure, wantErr: "no archive"},
+		{name: "replay dry run", args: []string{"replay", "--config", cfg, "--env-prefix=", "--dead-letters", flushLetters, "--dead-letter-key-env", "NOTIFYHUB_TEST_DLQ_KEY", "--dry-run"}, wantStatus: exitOK, wantOut: "would replay 1 of 1 messages"},
+		{name: "replay failing", args: []string{"replay", "--config", cfg, "--env-prefix=", "--dead-letters", flushLetters, "--dead-letter-key-env", "NOTIFYHUB_TEST_DLQ_KEY", "--keys-file", replayKeys}, failing: true, wantStatus: exitFailure, wantOut: "0 sent, 1 failed"},
+		{name: "replay", args: []string{"replay", "--config", cfg, "--env-prefix=", "--dead-letters", flushLetters, "--dead-letter-key-env", "NOTIFYHUB_TEST_DLQ_KEY", "--keys-file", replayKeys, "--rate", "10"}, wantStatus: exitOK, wantOut: "1 sent", wantBody: "discard me"},
+		{name: "replay again", args: []string{"replay", "--config", cfg, "--env-prefix=", "--dead-letters", flushLetters, "--dead-letter-key-env", "NOTIFYHUB_TEST_DLQ_KEY", "--keys-file", replayKeys, "--json"}, wantStatus: exitOK, wantOut: `"duplicates": 1`},
+		{name: "dlq flush", args: []string{"dlq", "flush", "--file", flushLetters}, wantStatus: exitOK, wantOut: "flushed 1 dead letters"},
+		{name: "dlq list after flush", args: []string{"dlq", "list", "--file", flushLetters}, wantStatus: exitOK, wantOut: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing.Store(tt.failing)
+			bodies.Store("")
+			var stdout, stderr bytes.Buffer
+			status := run(tt.args, env{stdin: strings.NewReader(tt.stdin), stdout: &stdout, stderr: &stderr})
+			if status != tt.wantStatus {
+				t.Errorf("run() = %d, want %d\nstdout: %s\nstderr: %s", status, tt.wantStatus, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantOut) || (tt.wantOut == "" && stdout.Len() > 0) {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantOut)
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantErr)
+			}
+			if body := bodies.Load().(string); !strings.Contains(body, tt.wantBody) {
+				t.Errorf("webhook body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	if sealed, err := os.ReadFile(sealedLetters); err != nil || bytes.Contains(sealed, []byte("Secret")) {
+		t.Errorf("sealed dead letters = %q, %v, want them encrypted", sealed, err)
+	}
+}
+
+func TestRunAdmin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": "invalid token"}`))
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/admin/controls":
+			_, _ = w.Write([]byte(`{"paused_platforms": {"feishu": 2}, "queues": {"async": 3, "held": 0}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/admin/platforms/feishu/pause":
+			_, _ = w.Write([]byte(`{"platform": "feishu", "paused": true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/admin/platforms/feishu/resume":
+			_, _ = w.Write([]byte(`{"platform": "feishu", "paused": false, "released": 2}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/admin/queues/async/drain":
+			_, _ = w.Write([]byte(`{"queue": "async", "drained": 3}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": "unknown queue"}`))
+		}
+	}))
+	defer server.Close()
+	t.Setenv("NOTIFYHUB_ADMIN_TOKEN", "admin-token")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		wantOut    string
+		wantErr    string
+	}{
+		{name: "without action", args: []string{"admin"}, wantStatus: exitUsage, wantErr: "Usage: notifyhub admin"},
+		{name: "without server", args: []string{"admin", "status"}, wantStatus: exitUsage, wantErr: "--server is required"},
+		{name: "without name", args: []string{"admin", "pause", "--server", server.URL}, wantStatus: exitUsage, wantErr: "platform or queue name is required"},
+		{name: "status", args: []string{"admin", "status", "--server", server.URL}, wantStatus: exitOK, wantOut: "platform feishu: paused, 2 held\nqueue async: 3 waiting"},
+		{name: "pause", args: []string{"admin", "pause", "feishu", "--server", server.URL}, wantStatus: exitOK, wantOut: "platform feishu paused"},
+		{name: "resume", args: []string{"admin", "resume", "--server", server.URL, "feishu"}, wantStatus: exitOK, wantOut: "2 held deliveries released"},
+		{name: "drain", args: []string{"admin", "drain", "async", "--server", server.URL}, wantStatus: exitOK, wantOut: "queue async drained, 3 removed"},
+		{name: "drain unknown", args: []string{"admin", "drain", "nope", "--server", server.URL}, wantStatus: exitFailure, wantErr: "404 Not Found: unknown queue"},
+		{name: "wrong token", args: []string{"admin", "status", "--server", server.URL, "--token", "nope"}, wantStatus: exitFailure, wantErr: "invalid token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := run(tt.args, env{stdin: strings.NewReader(""), stdout: &stdout, stderr: &stderr})
+			if status != tt.wantStatus {
+				t.Errorf("run() = %d, want %d\nstdout: %s\nstderr: %s", status, tt.wantStatus, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantOut) || (tt.wantOut == "" && stdout.Len() > 0) {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantOut)
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/redact"
+	"github.com/kart-io/notifyhub/pkg/secret"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// runReceipts shows the receipts appended by send --receipts, or the
+// receipt of a message sent through a NotifyHub REST server
+func runReceipts(e env, args []string) int {
+	fs := newFlagSet("receipts", e)
+	file := fs.String("file", "", "file of receipts written by send --receipts, - for stdin")
+	server := fs.String("server", "", "URL of a NotifyHub REST server to ask for the receipt of --id")
+	token := fs.String("token", os.Getenv("NOTIFYHUB_TOKEN"), "bearer token of --server, $NOTIFYHUB_TOKEN by default")
+	id := fs.String("id", "", "only show the receipt of this message")
+	failedOnly := fs.Bool("failed", false, "only show failed and partial receipts")
+	asJSON := fs.Bool("json", false, "print the receipts as JSON lines")
+	unmask := fs.Bool("unmask", false, "show target addresses unmasked")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+
+	var receipts iter.Seq2[*receipt.Receipt, error]
+	switch {
+	case *server != "" && *file != "":
+		fmt.Fprintln(e.stderr, "notifyhub receipts: only one of --file and --server can be set")
+		return exitUsage
+	case *server != "":
+		if *id == "" {
+			fmt.Fprintln(e.stderr, "notifyhub receipts: --server requires --id")
+			return exitUsage
+		}
+		r, err := fetchReceipt(*server, *token, *id, *unmask)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "notifyhub receipts: %v\n", err)
+			return exitFailure
+		}
+		receipts = func(yield func(*receipt.Receipt, error) bool) { yield(r, nil) }
+	case *file == "-":
+		receipts = receipt.Read(e.stdin)
+	case *file != "":
+		f, err := os.Open(*file)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "notifyhub receipts: %v\n", err)
+			return exitFailure
+		}
+		defer f.Close()
+		receipts = receipt.Read(f)
+	default:
+		fmt.Fprintln(e.stderr, "notifyhub receipts: --file or --server is required")
+		return exitUsage
+	}
+
+	// Receipts are printed as they are read, so that large files are not
+	// held in memory
+	found := false
+	for r, err := range receipts {
+		if err != nil {
+			fmt.Fprintf(e.stderr, "notifyhub receipts: %s: %v\n", *file, err)
+			return exitFailure
+		}
+		if (*id != "" && r.MessageID != *id) || (*failedOnly && !r.IsFailed() && !r.IsPartial()) {
+			continue
+		}
+		found = true
+		if !*unmask {
+			r = r.Masked(redact.MaskTarget)
+		}
+		if *asJSON {
+			line, _ := json.Marshal(r)
+			fmt.Fprintf(e.stdout, "%s\n", line)
+		} else {
+			printReceipt(e.stdout, r)
+		}
+	}
+	if *id != "" && !found {
+		fmt.Fprintf(e.stderr, "notifyhub receipts: no receipt of message %q\n", *id)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// fetchReceipt asks a REST server for the receipt of a message, with
+// unmasked targets if the server permits
+func fetchReceipt(server, token, id string, unmask bool) (*receipt.Receipt, error) {
+	endpoint := strings.TrimSuffix(server, "/") + "/v1/messages/" + url.PathEscape(id)
+	if unmask {
+		endpoint += "?unmask=true"
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var job struct {
+		State   string           `json:"state"`
+		Receipt *receipt.Receipt `json:"receipt"`
+		Error   string           `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, fmt.Errorf("%s: %w", resp.Status, err)
+	}
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: %s", resp.Status, job.Error)
+	case job.Receipt == nil && job.Error != "":
+		return nil, fmt.Errorf("message %s %s: %s", id, job.State, job.Error)
+	case job.Receipt == nil:
+		return nil, fmt.Errorf("message %s is %s", id, job.State)
+	}
+	return job.Receipt, nil
+}
+
+// deadLetter is a message whose send failed, as written by
+// send --dead-letter. NotifyHub keeps no dead letters itself; the file is
+// the dead letter queue of the CLI, e.g. for a cron job to retry.
+type deadLetter struct {
+	Message  *message.Message `json:"message"`
+	Receipt  *receipt.Receipt `json:"receipt,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	FailedAt time.Time        `json:"failed_at"`
+	Attempts int              `json:"attempts,omitempty"`
+}
+
+// keyFlags selects the key encrypting a dead letter file
+type keyFlags struct {
+	env  string
+	file string
+}
+
+// register adds the key flags to a flag set
+func (k *keyFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&k.env, "dead-letter-key-env", "", "environment variable with the base64 AES-256 key encrypting the dead letters")
+	fs.StringVar(&k.file, "dead-letter-key-file", "", "file with the base64 AES-256 key encrypting the dead letters")
+}
+
+// cipher returns the cipher of the dead letters, nil when no key is set
+func (k *keyFlags) cipher() (*secret.PayloadCipher, error) {
+	switch {
+	case k.env != "" && k.file != "":
+		return nil, errors.New("only one of --dead-letter-key-env and --dead-letter-key-file may be set")
+	case k.env != "":
+		return secret.NewPayloadCipher(secret.KeyFromEnv(k.env)), nil
+	case k.file != "":
+		return secret.NewPayloadCipher(secret.KeyFromFile(k.file)), nil
+	}
+	return nil, nil
+}
+
+// sealLetter serializes a dead letter, encrypting it when a cipher is set
+func sealLetter(c *secret.PayloadCipher, letter deadLetter) ([]byte, error) {
+	line, err := json.Marshal(letter)
+	if err != nil || c == nil {
+		return line, err
+	}
+	return c.Seal(context.Background(), line)
+}
+
+// runDLQ lists, resends or flushes the dead letters of a file
+func runDLQ(e env, args []string) int {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "replay" && args[0] != "flush") {
+		fmt.Fprintln(e.stderr, "Usage: notifyhub dlq list|replay|flush --file <dead letters> [flags]")
+		return exitUsage
+	}
+	action := args[0]
+
+	fs := newFlagSet("dlq "+action, e)
+	file := fs.String("file", "", "file of dead letters written by send --dead-letter")
+	var keys keyFlags
+	keys.register(fs)
+	var cfg configFlags
+	if action == "replay" {
+		cfg.register(fs)
+	}
+	if status, ok := parseFlags(fs, args[1:]); !ok {
+		return status
+	}
+	if *file == "" {
+		fmt.Fprintf(e.stderr, "notifyhub dlq %s: --file is required\n", action)
+		return exitUsage
+	}
+	if action == "flush" {
+		return flushDeadLetters(e, *file)
+	}
+	c, err := keys.cipher()
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub dlq %s: %v\n", action, err)
+		return exitUsage
+	}
+
+	var letters []deadLetter
+	err = readJSONLines(e, *file, func(decode func(interface{}) error) error {
+		var line json.RawMessage
+		if err := decode(&line); err != nil {
+			return err
+		}
+		if secret.IsSealed(line) {
+			if c == nil {
+				return fmt.Errorf("%w, set --dead-letter-key-env or --dead-letter-key-file", secret.ErrPayloadEncrypted)
+			}
+			opened, err := c.Open(context.Background(), line)
+			if err != nil {
+				return err
+			}
+			line = opened
+		}
+		var letter deadLetter
+		if err := json.Unmarshal(line, &letter); err != nil {
+			return err
+		}
+		if letter.Message == nil {
+			return fmt.Errorf("dead letter without a message")
+		}
+		letters = append(letters, letter)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub dlq %s: %v\n", action, err)
+		return exitFailure
+	}
+
+	if action == "list" {
+		for _, letter := range letters {
+			reason := letter.Error
+			if reason == "" && letter.Receipt != nil {
+				reason = strings.Join(letter.Receipt.GetErrors(), "; ")
+			}
+			fmt.Fprintf(e.stdout, "%s  %s  %q  %s\n", letter.FailedAt.Format(time.RFC3339), letter.Message.ID, letter.Message.Title, reason)
+		}
+		return exitOK
+	}
+	return replayDeadLetters(e, cfg, c, *file, letters)
+}
+
+// replayDeadLetters resends dead letters, leaving the ones that fail again
+// in the file, encrypted when a cipher is set
+func replayDeadLetters(e env, cfg configFlags, sealer *secret.PayloadCipher, file string, letters []deadLetter) int {
+	c, err := cfg.load(config.WithLogger(logger.Discard))
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub dlq replay: %v\n", err)
+		return exitFailure
+	}
+
+	var remaining bytes.Buffer
+	for _, letter := range letters {
+		rcpt, err := send(c, letter.Message, 0)
+		if err == nil && !rcpt.IsFailed() && !rcpt.IsPartial() {
+			printReceipt(e.stdout, rcpt)
+			continue
+		}
+
+		letter.Attempts++
+		letter.FailedAt = time.Now()
+		letter.Receipt, letter.Error = rcpt, ""
+		if err != nil {
+			letter.Error = err.Error()
+			fmt.Fprintf(e.stdout, "%s failed: %v\n", letter.Message.ID, err)
+		} else {
+			printReceipt(e.stdout, rcpt)
+		}
+		line, err := sealLetter(sealer, letter)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "notifyhub dlq replay: %v\n", err)
+			return exitFailure
+		}
+		remaining.Write(append(line, '\n'))
+	}
+
+	if err := os.WriteFile(file, remaining.Bytes(), 0o600); err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub dlq replay: %v\n", err)
+		return exitFailure
+	}
+	if remaining.Len() > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// flushDeadLetters discards the dead letters of a file, which need not be
+// decrypted to be counted
+func flushDeadLetters(e env, file string) int {
+	if file == "-" {
+		fmt.Fprintln(e.stderr, "notifyhub dlq flush: --file must be a file")
+		return exitUsage
+	}
+	var flushed int
+	if err := readJSONLines(e, file, func(decode func(interface{}) error) error {
+		var line json.RawMessage
+		if err := decode(&line); err != nil {
+			return err
+		}
+		flushed++
+		return nil
+	}); err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub dlq flush: %v\n", err)
+		return exitFailure
+	}
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub dlq flush: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(e.stdout, "flushed %d dead letters from %s\n", flushed, file)
+	return exitOK
+}
+
+// readJSONLines calls add for each line of a file of JSON lines, skipping
+// blank lines
+func readJSONLines(e env, path string, add func(decode func(interface{}) error) error) error {
+	var r io.Reader = e.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if err := add(func(v interface{}) error { return json.Unmarshal(text, v) }); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
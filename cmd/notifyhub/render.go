@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/template"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// runValidate checks a configuration, listing every problem found
+func runValidate(e env, args []string) int {
+	fs := newFlagSet("validate", e)
+	var cfg configFlags
+	cfg.register(fs)
+	effective := fs.Bool("effective", false, "print the effective settings and the layer that set each one")
+	preflight := fs.Bool("preflight", false, "also verify the credentials and endpoints of each platform")
+	timeout := fs.Duration("timeout", 30*time.Second, "bound of the preflight checks")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+
+	c, err := cfg.load(config.WithLogger(logger.Discard))
+	var problems config.ValidationErrors
+	if errors.As(err, &problems) {
+		for _, problem := range problems {
+			fmt.Fprintln(e.stderr, problem.Error())
+		}
+		fmt.Fprintf(e.stderr, "notifyhub validate: %d problems\n", len(problems))
+		return exitFailure
+	}
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub validate: %v\n", err)
+		return exitFailure
+	}
+
+	if *effective {
+		for _, setting := range c.Effective() {
+			value, _ := json.Marshal(setting.Value)
+			fmt.Fprintf(e.stdout, "%s = %s (%s)\n", setting.Path, value, setting.Source)
+		}
+		return exitOK
+	}
+	fmt.Fprintln(e.stdout, "configuration is valid")
+	if *preflight {
+		return runPreflight(e, c, *timeout)
+	}
+	return exitOK
+}
+
+// runPreflight verifies the platforms of a configuration, printing the
+// result of each
+func runPreflight(e env, c *config.Config, timeout time.Duration) int {
+	client, err := notifyhub.NewClient(c)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub validate: %v\n", err)
+		return exitFailure
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	report, err := client.(notifyhub.Preflighter).Preflight(ctx)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub validate: preflight: %v\n", err)
+		return exitFailure
+	}
+
+	names := make([]string, 0, len(report.Platforms))
+	for name := range report.Platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result := report.Platforms[name]
+		if result.Ready {
+			fmt.Fprintf(e.stdout, "%s: ready (%s check, %s)\n", name, result.Check, result.Duration.Round(time.Millisecond))
+		} else {
+			fmt.Fprintf(e.stderr, "%s: not ready: %s\n", name, result.Error)
+		}
+	}
+	if !report.Ready {
+		fmt.Fprintf(e.stderr, "notifyhub validate: %d platforms not ready\n", len(report.Failed()))
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runRender renders a message template with variables
+func runRender(e env, args []string) int {
+	fs := newFlagSet("render", e)
+	var vars listFlag
+	templateFile := fs.String("template", "", "template file, - for stdin")
+	fs.Var(&vars, "var", "template variable as key=value (repeatable)")
+	varsFile := fs.String("vars-file", "", "JSON file of template variables, overridden by --var")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if *templateFile == "" {
+		fmt.Fprintln(e.stderr, "notifyhub render: --template is required")
+		return exitUsage
+	}
+
+	data := make(map[string]interface{})
+	if *varsFile != "" {
+		encoded, err := readInput(e, *varsFile)
+		if err == nil {
+			err = json.Unmarshal(encoded, &data)
+		}
+		if err != nil {
+			fmt.Fprintf(e.stderr, "notifyhub render: --vars-file: %v\n", err)
+			return exitUsage
+		}
+	}
+	pairs, err := vars.pairs()
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub render: --var: %v\n", err)
+		return exitUsage
+	}
+	for key, value := range pairs {
+		data[key] = value
+	}
+
+	rendered, err := renderTemplate(e, *templateFile, data)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub render: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprint(e.stdout, rendered)
+	return exitOK
+}
+
+// renderTemplate renders a text/template file with variables, which the
+// template reads as {{.name}}
+func renderTemplate(e env, path string, vars map[string]interface{}) (string, error) {
+	content, err := readInput(e, path)
+	if err != nil {
+		return "", err
+	}
+	engine := template.NewTextEngine()
+	if err := engine.Parse(path, string(content)); err != nil {
+		return "", err
+	}
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	return engine.Render(context.Background(), path, vars)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/kart-io/notifyhub/pkg/archive"
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// runReplay sends again the dead letters of a file or the archived
+// messages of a time range
+func runReplay(e env, args []string) int {
+	fs := newFlagSet("replay", e)
+	var cfg configFlags
+	cfg.register(fs)
+	deadLetters := fs.String("dead-letters", "", "replay the dead letters of this file written by send --dead-letter")
+	fromArchive := fs.Bool("archive", false, "replay the messages of the archive of the configuration")
+	from := fs.String("from", "", "only replay messages that failed or completed at or after this RFC 3339 time or date")
+	to := fs.String("to", "", "only replay messages that failed or completed before this RFC 3339 time or date")
+	tenant := fs.String("tenant", "", "only replay messages of this tenant")
+	failedOnly := fs.Bool("failed", false, "only replay messages that failed or partially failed")
+	rate := fs.Float64("rate", 0, "messages sent per second, 0 for no limit")
+	dryRun := fs.Bool("dry-run", false, "list the messages that would be sent without sending them")
+	keysFile := fs.String("keys-file", "", "file remembering the replayed messages across runs, so that none is sent twice")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	var keys keyFlags
+	keys.register(fs)
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+
+	if (*deadLetters == "") == !*fromArchive {
+		fmt.Fprintln(e.stderr, "notifyhub replay: one of --dead-letters and --archive is required")
+		return exitUsage
+	}
+	if *rate < 0 {
+		fmt.Fprintln(e.stderr, "notifyhub replay: --rate cannot be negative")
+		return exitUsage
+	}
+	filter := notifyhub.ReplayFilter{Tenant: *tenant, FailedOnly: *failedOnly}
+	var err error
+	if filter.From, err = parseTime(*from); err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub replay: invalid --from: %v\n", err)
+		return exitUsage
+	}
+	if filter.To, err = parseTime(*to); err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub replay: invalid --to: %v\n", err)
+		return exitUsage
+	}
+	sealer, err := keys.cipher()
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub replay: %v\n", err)
+		return exitUsage
+	}
+
+	c, err := cfg.load(config.WithLogger(logger.Discard))
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub replay: %v\n", err)
+		return exitFailure
+	}
+	var source notifyhub.ReplaySource = notifyhub.DeadLetterSource{Path: *deadLetters, Cipher: sealer}
+	if *fromArchive {
+		store := c.ArchiveStore
+		if store == nil {
+			store = c.Archive.Store()
+		}
+		reader, ok := store.(archive.Reader)
+		if !ok {
+			fmt.Fprintln(e.stderr, "notifyhub replay: the configuration has no archive that can be read")
+			return exitFailure
+		}
+		source = notifyhub.ArchiveSource{Reader: reader, Prefix: c.Archive.Prefix}
+	}
+
+	opts := notifyhub.ReplayOptions{Rate: *rate, DryRun: *dryRun}
+	if *keysFile != "" {
+		store, err := openKeyFile(*keysFile)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "notifyhub replay: %v\n", err)
+			return exitFailure
+		}
+		opts.Keys = store
+	}
+
+	client, err := notifyhub.NewClient(c)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub replay: %v\n", err)
+		return exitFailure
+	}
+	defer client.Close()
+	report, err := client.Replay(context.Background(), source, filter, opts)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub replay: %v\n", err)
+		return exitFailure
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Fprintf(e.stdout, "%s\n", out)
+	} else {
+		for _, m := range report.Messages {
+			fmt.Fprintf(e.stdout, "%-9s  %s  %q  %d targets", m.Status, m.ID, m.Title, m.Targets)
+			if m.Error != "" {
+				fmt.Fprintf(e.stdout, "  %s", m.Error)
+			}
+			fmt.Fprintln(e.stdout)
+		}
+		verb := "replayed"
+		if report.DryRun {
+			verb = "would replay"
+		}
+		fmt.Fprintf(e.stdout, "%s %d of %d messages: %d sent, %d failed, %d duplicates\n",
+			verb, report.Matched-report.Duplicates, report.Matched, report.Sent, report.Failed, report.Duplicates)
+	}
+	if report.Failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// keyFile is an IdempotencyStore keeping the keys of replayed messages in a
+// file, one per line, so that later runs do not send them again
+type keyFile struct {
+	path string
+	keys map[string]bool
+	mu   sync.Mutex
+}
+
+// openKeyFile reads the keys of a key file, which need not exist
+func openKeyFile(path string) (*keyFile, error) {
+	k := &keyFile{path: path, keys: make(map[string]bool)}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			k.keys[key] = true
+		}
+	}
+	return k, scanner.Err()
+}
+
+// Claim implements notifyhub.IdempotencyStore, appending the key to the
+// file
+func (k *keyFile) Claim(ctx context.Context, key string) (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys[key] {
+		return false, nil
+	}
+	f, err := os.OpenFile(k.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, key); err != nil {
+		return false, err
+	}
+	k.keys[key] = true
+	return true, nil
+}
+
+// Release implements notifyhub.IdempotencyStore, rewriting the file
+// without the key
+func (k *keyFile) Release(ctx context.Context, key string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.keys[key] {
+		return nil
+	}
+	delete(k.keys, key)
+	var b strings.Builder
+	for key := range k.keys {
+		b.WriteString(key + "\n")
+	}
+	return os.WriteFile(k.path, []byte(b.String()), 0o600)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// priorities are the names accepted by --priority
+var priorities = map[string]message.Priority{
+	"low":    message.PriorityLow,
+	"normal": message.PriorityNormal,
+	"high":   message.PriorityHigh,
+	"urgent": message.PriorityUrgent,
+}
+
+// runSend sends a message and prints its receipt
+func runSend(e env, args []string) int {
+	fs := newFlagSet("send", e)
+	var cfg configFlags
+	cfg.register(fs)
+	var to, vars, metadata listFlag
+	platform := fs.String("platform", "", "platform of the targets, or the platform to send to when there are no --to targets")
+	fs.Var(&to, "to", "target as type:value, e.g. email:ops@example.com, or a value of --platform (repeatable)")
+	title := fs.String("title", "", "message title")
+	body := fs.String("body", "", "message body")
+	bodyFile := fs.String("body-file", "", "file with the message body, - for stdin")
+	templateFile := fs.String("template", "", "file with a template rendering the body from the --var values")
+	fs.Var(&vars, "var", "template variable as key=value (repeatable)")
+	fs.Var(&metadata, "metadata", "message metadata as key=value (repeatable)")
+	format := fs.String("format", "", "body format: text, markdown or html")
+	priority := fs.String("priority", "", "priority: low, normal, high or urgent")
+	timeout := fs.Duration("timeout", 0, "timeout of the send, the configured timeouts by default")
+	receipts := fs.String("receipts", "", "file to append the receipt to, as a JSON line")
+	deadLetters := fs.String("dead-letter", "", "file to append the message to when the send fails, see notifyhub dlq")
+	var keys keyFlags
+	keys.register(fs)
+	asJSON := fs.Bool("json", false, "print the receipt as JSON")
+	dryRun := fs.Bool("dry-run", false, "process the message without calling any platform")
+	verbose := fs.Bool("v", false, "log the send to stderr")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+
+	msg, err := buildMessage(e, sendRequest{
+		platform: *platform, to: to, title: *title, body: *body, bodyFile: *bodyFile,
+		templateFile: *templateFile, vars: vars, metadata: metadata, format: *format, priority: *priority,
+	})
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub send: %v\n", err)
+		return exitUsage
+	}
+	sealer, err := keys.cipher()
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub send: %v\n", err)
+		return exitUsage
+	}
+
+	log := logger.Discard
+	if *verbose {
+		log = logger.NewStandardLogger(stdlog.New(e.stderr, "", stdlog.LstdFlags), logger.Debug, "[notifyhub]")
+	}
+	opts := []config.Option{config.WithLogger(log)}
+	if *dryRun {
+		opts = append(opts, config.Override(config.WithDryRun()))
+	}
+	c, err := cfg.load(opts...)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "notifyhub send: %v\n", err)
+		return exitFailure
+	}
+	if len(to) == 0 && *platform == target.PlatformWebhook && c.Webhook != nil {
+		// The generic webhook platform only accepts URL targets
+		msg.Targets[0].Value = c.Webhook.URL
+	}
+	rcpt, sendErr := send(c, msg, *timeout)
+	if rcpt == nil {
+		rcpt = receipt.New(msg.ID)
+		rcpt.Status = receipt.StatusFailed
+	}
+
+	if *receipts != "" {
+		if err := appendJSONLine(*receipts, rcpt); err != nil {
+			fmt.Fprintf(e.stderr, "notifyhub send: %v\n", err)
+		}
+	}
+	failed := sendErr != nil || rcpt.IsFailed() || rcpt.IsPartial()
+	if failed && *deadLetters != "" {
+		letter := deadLetter{Message: msg, Receipt: rcpt, FailedAt: time.Now()}
+		if sendErr != nil {
+			letter.Error = sendErr.Error()
+		}
+		line, err := sealLetter(sealer, letter)
+		if err == nil {
+			err = appendLine(*deadLetters, line)
+		}
+		if err != nil {
+			fmt.Fprintf(e.stderr, "notifyhub send: %v\n", err)
+		}
+	}
+
+	if *asJSON {
+		writeJSON(e.stdout, rcpt)
+	} else {
+		printReceipt(e.stdout, rcpt)
+	}
+	if sendErr != nil {
+		fmt.Fprintf(e.stderr, "notifyhub send: %v\n", sendErr)
+	}
+	if failed {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// send sends a message with a client of the configuration
+func send(c *config.Config, msg *message.Message, timeout time.Duration) (*receipt.Receipt, error) {
+	client, err := notifyhub.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return client.Send(ctx, msg)
+}
+
+// sendRequest holds the message flags of the send command
+type sendRequest struct {
+	platform     string
+	to           []string
+	title        string
+	body         string
+	bodyFile     string
+	templateFile string
+	vars         listFlag
+	metadata     listFlag
+	format       string
+	priority     string
+}
+
+// buildMessage builds the message of the send flags
+func buildMessage(e env, req sendRequest) (*message.Message, error) {
+	msg := message.New()
+	msg.Title = req.title
+
+	sources := 0
+	for _, set := range []bool{req.body != "", req.bodyFile != "", req.templateFile != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, errors.New("only one of --body, --body-file and --template can be set")
+	}
+
+	vars, err := req.vars.pairs()
+	if err != nil {
+		return nil, fmt.Errorf("--var: %w", err)
+	}
+	msg.Variables = vars
+	switch {
+	case req.body != "":
+		msg.Body = req.body
+	case req.bodyFile != "":
+		data, err := readInput(e, req.bodyFile)
+		if err != nil {
+			return nil, err
+		}
+		msg.Body = string(data)
+	case req.templateFile != "":
+		msg.Body, err = renderTemplate(e, req.templateFile, vars)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	metadata, err := req.metadata.pairs()
+	if err != nil {
+		return nil, fmt.Errorf("--metadata: %w", err)
+	}
+	for key, value := range metadata {
+		msg.SetMetadata(key, value)
+	}
+
+	if req.format != "" {
+		msg.Format = message.Format(req.format)
+		switch msg.Format {
+		case message.FormatText, message.FormatMarkdown, message.FormatHTML:
+		default:
+			return nil, fmt.Errorf("--format must be text, markdown or html, got %q", req.format)
+		}
+	}
+	if req.priority != "" {
+		p, ok := priorities[strings.ToLower(req.priority)]
+		if !ok {
+			n, err := strconv.Atoi(req.priority)
+			if err != nil || n < int(message.PriorityLow) || n > int(message.PriorityUrgent) {
+				return nil, fmt.Errorf("--priority must be low, normal, high or urgent, got %q", req.priority)
+			}
+			p = message.Priority(n)
+		}
+		msg.Priority = p
+	}
+
+	msg.Targets, err = parseTargets(req.platform, req.to)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// parseTargets returns the targets of the --platform and --to flags. A
+// platform without targets sends to the platform's configured webhook, the
+// way target.New(target.TargetTypeWebhook, "feishu", "feishu") does; runSend
+// fills in the URL of the generic webhook platform.
+func parseTargets(platform string, to []string) ([]target.Target, error) {
+	if len(to) == 0 {
+		if platform == "" {
+			return nil, errors.New("--platform or --to is required")
+		}
+		return []target.Target{target.New(target.TargetTypeWebhook, platform, platform)}, nil
+	}
+
+	targets := make([]target.Target, 0, len(to))
+	for _, spec := range to {
+		targetType, value, ok := strings.Cut(spec, ":")
+		if ok && (value == "" || strings.HasPrefix(value, "//")) {
+			// A URL rather than type:value
+			ok = false
+		}
+		switch {
+		case ok:
+			targets = append(targets, target.New(targetType, value, platform))
+		case platform != "":
+			targets = append(targets, target.New(target.TargetTypeWebhook, spec, platform))
+		case strings.Contains(spec, "://"):
+			targets = append(targets, target.NewWebhook(spec))
+		default:
+			return nil, fmt.Errorf("--to %q needs a type, as in email:%s", spec, spec)
+		}
+	}
+	return targets, nil
+}
+
+// printReceipt prints a receipt as one line per target
+func printReceipt(w io.Writer, r *receipt.Receipt) {
+	fmt.Fprintf(w, "%s %s: %d delivered, %d failed, %d skipped\n", r.MessageID, r.Status, r.Successful, r.Failed, r.Skipped)
+	for _, result := range r.Results {
+		status := "ok"
+		switch {
+		case result.IsSkipped():
+			status = result.Status
+		case !result.Success:
+			status = "failed: " + result.Error
+		}
+		fmt.Fprintf(w, "  %-8s %s  %s\n", result.Platform, result.Target, status)
+	}
+}
+
+// writeJSON prints a value as indented JSON
+func writeJSON(w io.Writer, v interface{}) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+// appendJSONLine appends a value to a file of JSON lines
+func appendJSONLine(path string, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return appendLine(path, line)
+}
+
+// appendLine appends a line to a file
+func appendLine(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -166,10 +166,6 @@ func runIntegrationDemo() {
 	// Step 2: Register external DingTalk platform
 	// This is the key - we register our external platform without modifying NotifyHub core
 	fmt.Println("📋 Registering external DingTalk platform...")
-
-	// Get the platform registry (assuming NotifyHub exposes this)
-	// Note: In real implementation, NotifyHub would need to expose the registry
-	// or provide a RegisterExternalPlatform method
 	err = registerDingTalkPlatform(client)
 	if err != nil {
 		log.Fatalf("Failed to register DingTalk platform: %v", err)
@@ -199,7 +195,7 @@ func runIntegrationDemo() {
 
 	// Step 5: Send messages using DingTalk platform (through NotifyHub)
 	fmt.Println("\n📤 Sending test messages through NotifyHub...")
-	fmt.Println("⚠️  Note: These will fail because DingTalk platform is not actually registered")
+	fmt.Println("⚠️  Note: These will fail until YOUR_ACCESS_TOKEN is replaced with a real token")
 
 	// Example 1: Basic text message
 	err = sendBasicTextMessage(client, dingTalkConfig.WebhookURL)
@@ -228,33 +224,19 @@ func runIntegrationDemo() {
 	fmt.Println("\n🎉 Integration demo completed!")
 	fmt.Println("\nℹ️  Summary:")
 	fmt.Println("   ✅ Standalone platform works perfectly")
-	fmt.Println("   ⚠️  Integration requires NotifyHub core API changes:")
-	fmt.Println("      - client.RegisterPlatform(name, factory)")
-	fmt.Println("      - client.SetPlatformConfig(name, config)")
-	fmt.Println("      - Expose platform registry for external platforms")
+	fmt.Println("   🔌 Registered with client.RegisterPlatform and client.SetPlatformConfig")
 }
 
 // registerDingTalkPlatform registers the DingTalk platform factory
-// Note: This assumes NotifyHub provides a way to register external platforms
 func registerDingTalkPlatform(client notifyhub.Client) error {
-	// In a real implementation, NotifyHub would need to expose platform registration
-	// This is a conceptual example of how it would work
-
-	// factory := platform.Factory(NewDingTalkPlatform)
-	// return client.RegisterPlatform("dingtalk", factory)
-
-	fmt.Println("⚠️  Note: Platform registration would require NotifyHub to expose registry API")
-	return nil // Placeholder
+	return client.RegisterPlatform("dingtalk", dingtalk.New)
 }
 
 // configureDingTalkPlatform sets the configuration for DingTalk platform
 func configureDingTalkPlatform(client notifyhub.Client, config dingtalk.Config) error {
-	// In a real implementation:
-	// return client.SetPlatformConfig("dingtalk", config)
-
 	fmt.Printf("⚙️  DingTalk configuration: WebhookURL=%s, Timeout=%ds\n",
 		maskWebhookURL(config.WebhookURL), config.Timeout)
-	return nil // Placeholder
+	return client.SetPlatformConfig("dingtalk", config)
 }
 
 // testDingTalkCapabilities tests the platform capabilities
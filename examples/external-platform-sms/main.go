@@ -475,7 +475,6 @@ func runIntegrationDemo() {
 
 	// Send test messages
 	fmt.Println("\n📤 Sending test SMS messages through NotifyHub...")
-	fmt.Println("⚠️  Note: These will show errors because SMS platform is not actually registered")
 
 	// Example 1: Basic SMS
 	err = sendBasicSMS(client, "+86 138 0013 8000")
@@ -501,30 +500,19 @@ func runIntegrationDemo() {
 	fmt.Println("   🏢 Multiple provider support (Aliyun, Tencent, Twilio, Nexmo)")
 	fmt.Println("   🚦 Rate limiting prevents spam")
 	fmt.Println("   📋 Template system with variable substitution")
-	fmt.Println("   ⚠️  Integration requires NotifyHub core API changes:")
-	fmt.Println("      - client.RegisterPlatform(name, factory)")
-	fmt.Println("      - client.SetPlatformConfig(name, config)")
-	fmt.Println("      - SMS-specific routing and validation")
+	fmt.Println("   🔌 Registered with client.RegisterPlatform and client.SetPlatformConfig")
 }
 
 // Helper functions for integration demo
 
 func registerSMSPlatform(client notifyhub.Client) error {
-	// In a real implementation:
-	// factory := platform.Factory(sms.New)
-	// return client.RegisterPlatform("sms", factory)
-
-	fmt.Println("⚠️  Note: Platform registration would require NotifyHub to expose registry API")
-	return nil
+	return client.RegisterPlatform("sms", sms.New)
 }
 
 func configureSMSPlatform(client notifyhub.Client, config sms.Config) error {
-	// In a real implementation:
-	// return client.SetPlatformConfig("sms", config)
-
 	fmt.Printf("⚙️  SMS configuration: Provider=%s, Templates=%d, RateLimit=%v\n",
 		config.Provider, len(config.Templates), config.RateLimit.Enabled)
-	return nil
+	return client.SetPlatformConfig("sms", config)
 }
 
 func testSMSCapabilities(client notifyhub.Client) error {
@@ -0,0 +1,3 @@
+module github.com/kart-io/notifyhub/examples/sandbox-guest
+
+go 1.24.0
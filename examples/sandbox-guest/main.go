@@ -0,0 +1,154 @@
+//go:build wasip1
+
+// 沙箱平台示例 guest：把消息标题 POST 到每个 webhook 目标。
+//
+// 构建（需要 Go 1.24 及以上）：
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o guest.wasm .
+//
+// guest 按 sandbox 包文档约定的 ptr/len ABI 导出 alloc、free、
+// capabilities 和 send，并通过宿主模块 notifyhub 的 http_request 和 log
+// 访问外部；它只依赖标准库，不引用 NotifyHub 的包。
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"unsafe"
+)
+
+func main() {}
+
+// buffers 持有交给宿主的内存，直到调用 free，避免被 GC 回收
+var buffers = map[uint32][]byte{}
+
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+	if size == 0 {
+		return 0
+	}
+	buf := make([]byte, size)
+	ptr := uint32(uintptr(unsafe.Pointer(&buf[0])))
+	buffers[ptr] = buf
+	return ptr
+}
+
+//go:wasmexport free
+func free(ptr, size uint32) {
+	delete(buffers, ptr)
+}
+
+//go:wasmimport notifyhub http_request
+func hostHTTPRequest(ptr, size uint32) uint64
+
+//go:wasmimport notifyhub log
+func hostLog(ptr, size uint32)
+
+// read 返回宿主写入 alloc 缓冲区的数据
+func read(ptr, size uint32) []byte {
+	if size == 0 {
+		return nil
+	}
+	return buffers[ptr][:size]
+}
+
+// write 把数据复制到新的缓冲区，返回打包后的 ptr<<32 | len
+func write(data []byte) uint64 {
+	ptr := alloc(uint32(len(data)))
+	copy(buffers[ptr], data)
+	return uint64(ptr)<<32 | uint64(len(data))
+}
+
+// call 调用宿主函数：参数和结果都是 JSON
+func call(fn func(ptr, size uint32) uint64, input any, output any) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	packed := write(data)
+	ptr, size := uint32(packed>>32), uint32(packed)
+	result := fn(ptr, size)
+	free(ptr, size)
+	if output == nil || result == 0 {
+		return nil
+	}
+	resultPtr, resultSize := uint32(result>>32), uint32(result)
+	defer free(resultPtr, resultSize)
+	return json.Unmarshal(read(resultPtr, resultSize), output)
+}
+
+func logf(level, format string, args ...any) {
+	entry := map[string]string{"level": level, "message": fmt.Sprintf(format, args...)}
+	_ = call(func(ptr, size uint32) uint64 { hostLog(ptr, size); return 0 }, entry, nil)
+}
+
+type target struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendRequest struct {
+	Message struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	} `json:"message"`
+	Targets  []target          `json:"targets"`
+	Settings map[string]string `json:"settings"`
+}
+
+type result struct {
+	Target        target `json:"target"`
+	Success       bool   `json:"success"`
+	Response      string `json:"response,omitempty"`
+	Error         string `json:"error,omitempty"`
+	TargetInvalid bool   `json:"target_invalid,omitempty"`
+}
+
+type httpResponse struct {
+	Status int    `json:"status"`
+	Body   string `json:"body"`
+	Error  string `json:"error"`
+}
+
+//go:wasmexport capabilities
+func capabilities() uint64 {
+	return write([]byte(`{"supported_target_types":["webhook"],"supported_formats":["text"]}`))
+}
+
+//go:wasmexport send
+func send(ptr, size uint32) uint64 {
+	var req sendRequest
+	if err := json.Unmarshal(read(ptr, size), &req); err != nil {
+		data, _ := json.Marshal(map[string]string{"error": "invalid request: " + err.Error()})
+		return write(data)
+	}
+
+	logf("info", "sending to %d targets", len(req.Targets))
+	results := make([]result, 0, len(req.Targets))
+	for _, tgt := range req.Targets {
+		httpReq := map[string]any{
+			"method":  "POST",
+			"url":     tgt.Value,
+			"headers": map[string]string{"Content-Type": "text/plain"},
+			"body":    req.Settings["prefix"] + req.Message.Title,
+		}
+		var resp httpResponse
+		r := result{Target: tgt}
+		switch err := call(hostHTTPRequest, httpReq, &resp); {
+		case err != nil:
+			r.Error = err.Error()
+		case resp.Error != "":
+			r.Error = resp.Error
+		case resp.Status == 404:
+			r.Error, r.TargetInvalid = "endpoint not found", true
+		case resp.Status >= 300:
+			r.Error = fmt.Sprintf("unexpected status %d", resp.Status)
+		default:
+			r.Success, r.Response = true, resp.Body
+		}
+		results = append(results, r)
+	}
+
+	data, _ := json.Marshal(map[string]any{"results": results})
+	return write(data)
+}
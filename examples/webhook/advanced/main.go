@@ -52,6 +52,10 @@ func main() {
 	cfg.Async.Workers = 4
 	cfg.Logger.Level = "debug"
 
+	// Sign every request with HMAC-SHA256 (X-Signature and X-Timestamp
+	// headers), which the receiver checks with webhook.Verify
+	cfg.Webhook.Secret = "your_webhook_secret"
+
 	client, err := notifyhub.NewClient(cfg)
 	if err != nil {
 		logger.Error("创建NotifyHub客户端失败: %v", err)
@@ -356,29 +360,14 @@ func sendSignedWebhook(client notifyhub.Client, config *common.ExampleConfig, lo
 	msg.Body = "这个Webhook包含签名验证信息，确保数据完整性。"
 	msg.Format = message.FormatText
 
-	// Signature configuration
-	msg.PlatformData = map[string]interface{}{
-		"webhook": map[string]interface{}{
-			"signature": map[string]interface{}{
-				"algorithm":         "hmac-sha256",
-				"secret_key":        "your_webhook_secret",
-				"header_name":       "X-Signature-256",
-				"include_timestamp": true,
-				"timestamp_header":  "X-Timestamp",
-			},
-			"security": map[string]interface{}{
-				"verify_ssl":    true,
-				"require_https": true,
-				"ip_whitelist":  []string{"192.168.1.0/24", "10.0.0.0/8"},
-			},
-			"payload": map[string]interface{}{
-				"event":     "webhook_test",
-				"timestamp": time.Now().Unix(),
-				"data":      "sensitive_data_here",
-				"checksum":  "calculated_checksum",
-			},
-		},
-	}
+	// The client signs the request with the secret of the webhook
+	// configuration; the receiver verifies it:
+	//
+	//	body, _ := io.ReadAll(r.Body)
+	//	if err := webhook.Verify(r.Header, body, "your_webhook_secret", 0); err != nil {
+	//		http.Error(w, "invalid signature", http.StatusUnauthorized)
+	//		return
+	//	}
 
 	msg.Targets = []target.Target{
 		common.CreateWebhookTarget(config.Webhook.URL),
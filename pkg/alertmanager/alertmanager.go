@@ -0,0 +1,212 @@
+// Package alertmanager provides the Prometheus Alertmanager webhook format
+// for NotifyHub: webhooks can send messages as Alertmanager notifications,
+// so that NotifyHub feeds receivers written for Alertmanager, and the
+// notifications Alertmanager sends convert to messages, which the HTTP
+// server (package server/http) receives.
+package alertmanager
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// Version is the version of the webhook format
+const Version = "4"
+
+// DefaultReceiver is the receiver name of notifications sent without one
+const DefaultReceiver = "notifyhub"
+
+// Payload is the body of an Alertmanager webhook notification
+type Payload struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []Alert           `json:"alerts"`
+}
+
+// Alert is an alert of a notification
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// severities are the severity labels of message priorities
+var severities = map[message.Priority]string{
+	message.PriorityLow:    "info",
+	message.PriorityNormal: "warning",
+	message.PriorityHigh:   "critical",
+	message.PriorityUrgent: "critical",
+}
+
+// priorities are the message priorities of severity labels; alerts of
+// other severities have normal priority
+var priorities = map[string]message.Priority{
+	"none":      message.PriorityLow,
+	"debug":     message.PriorityLow,
+	"info":      message.PriorityLow,
+	"low":       message.PriorityLow,
+	"warning":   message.PriorityNormal,
+	"warn":      message.PriorityNormal,
+	"error":     message.PriorityHigh,
+	"high":      message.PriorityHigh,
+	"critical":  message.PriorityHigh,
+	"page":      message.PriorityUrgent,
+	"emergency": message.PriorityUrgent,
+	"fatal":     message.PriorityUrgent,
+}
+
+// Messages returns a message for each alert of a notification. A message
+// has the summary annotation, or else the alertname, as its title, marked
+// [RESOLVED] once the alert is resolved, and the description or message
+// annotation and the generator URL as its body. Its priority follows the
+// severity label, and it carries the status and labels of the alert and
+// the group key of the notification (see message.SetAlertLabels).
+func (p *Payload) Messages() []*message.Message {
+	messages := make([]*message.Message, 0, len(p.Alerts))
+	for _, alert := range p.Alerts {
+		status := alert.Status
+		if status == "" {
+			status = p.Status
+		}
+
+		title := alert.Annotations["summary"]
+		if title == "" {
+			title = alert.Labels["alertname"]
+		}
+		if status == message.AlertResolved {
+			title = "[RESOLVED] " + title
+		}
+		body := alert.Annotations["description"]
+		if body == "" {
+			body = alert.Annotations["message"]
+		}
+		if alert.GeneratorURL != "" {
+			body = strings.TrimSpace(body + "\n\n" + alert.GeneratorURL)
+		}
+
+		msg := message.New().SetTitle(title).SetBody(body).SetPriority(Priority(alert.Labels["severity"]))
+		msg.SetAlertStatus(status).SetAlertLabels(alert.Labels).SetAlertGroup(p.GroupKey)
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// Priority returns the message priority of a severity label, such as
+// critical or warning; unknown severities have normal priority
+func Priority(severity string) message.Priority {
+	if priority, ok := priorities[strings.ToLower(severity)]; ok {
+		return priority
+	}
+	return message.PriorityNormal
+}
+
+// FromMessage returns the notification of a message as the single alert of
+// its group. The alert has the labels of the message (see
+// message.SetAlertLabels), with alertname defaulting to the title and
+// severity to one derived from the priority, and the title and body as
+// summary and description annotations. It is resolved when the alert
+// status of the message is resolved, and firing otherwise.
+func FromMessage(msg *message.Message, receiver string, now time.Time) *Payload {
+	if receiver == "" {
+		receiver = DefaultReceiver
+	}
+
+	labels := make(map[string]string)
+	for name, value := range msg.AlertLabels() {
+		labels[name] = value
+	}
+	if labels["alertname"] == "" {
+		labels["alertname"] = msg.Title
+		if labels["alertname"] == "" {
+			labels["alertname"] = "NotifyHub"
+		}
+	}
+	if labels["severity"] == "" {
+		labels["severity"] = severities[msg.Priority]
+	}
+
+	annotations := make(map[string]string)
+	if msg.Title != "" {
+		annotations["summary"] = msg.Title
+	}
+	if msg.Body != "" {
+		annotations["description"] = msg.Body
+	}
+
+	alert := Alert{
+		Status:      message.AlertFiring,
+		Labels:      labels,
+		Annotations: annotations,
+		StartsAt:    msg.CreatedAt,
+		Fingerprint: Fingerprint(labels),
+	}
+	if alert.StartsAt.IsZero() {
+		alert.StartsAt = now
+	}
+	if msg.AlertStatus() == message.AlertResolved {
+		alert.Status = message.AlertResolved
+		alert.EndsAt = now
+	}
+
+	groupLabels := map[string]string{"alertname": labels["alertname"]}
+	return &Payload{
+		Version:           Version,
+		GroupKey:          "{}:" + formatLabels(groupLabels),
+		Status:            alert.Status,
+		Receiver:          receiver,
+		GroupLabels:       groupLabels,
+		CommonLabels:      labels,
+		CommonAnnotations: annotations,
+		Alerts:            []Alert{alert},
+	}
+}
+
+// Fingerprint returns the fingerprint of an alert with a label set, the
+// hex FNV-1a hash of its sorted labels and values
+func Fingerprint(labels map[string]string) string {
+	names := sortedNames(labels)
+	h := fnv.New64a()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0xff})
+		h.Write([]byte(labels[name]))
+		h.Write([]byte{0xff})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// formatLabels formats a label set as Alertmanager writes group keys, e.g.
+// {alertname="DiskFull"}
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, name := range sortedNames(labels) {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sortedNames returns the names of a label set in order
+func sortedNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,158 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+func TestFromMessage(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	now := created.Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		msg         func() *message.Message
+		receiver    string
+		status      string
+		labels      map[string]string
+		annotations map[string]string
+		endsAt      time.Time
+	}{
+		{
+			name: "firing",
+			msg: func() *message.Message {
+				msg := message.New().SetTitle("Disk full").SetBody("/var at 95%").SetPriority(message.PriorityHigh)
+				msg.CreatedAt = created
+				return msg
+			},
+			status:      "firing",
+			labels:      map[string]string{"alertname": "Disk full", "severity": "critical"},
+			annotations: map[string]string{"summary": "Disk full", "description": "/var at 95%"},
+		},
+		{
+			name: "resolved with labels",
+			msg: func() *message.Message {
+				msg := message.New().SetTitle("Disk full").SetAlertStatus(message.AlertResolved)
+				msg.SetAlertLabels(map[string]string{"alertname": "DiskFull", "instance": "db-1", "severity": "page"})
+				msg.CreatedAt = created
+				return msg
+			},
+			receiver:    "ops",
+			status:      "resolved",
+			labels:      map[string]string{"alertname": "DiskFull", "instance": "db-1", "severity": "page"},
+			annotations: map[string]string{"summary": "Disk full"},
+			endsAt:      now,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := FromMessage(tt.msg(), tt.receiver, now)
+			receiver := tt.receiver
+			if receiver == "" {
+				receiver = DefaultReceiver
+			}
+			if payload.Version != "4" || payload.Status != tt.status || payload.Receiver != receiver || len(payload.Alerts) != 1 {
+				t.Fatalf("FromMessage() = %+v", payload)
+			}
+			alert := payload.Alerts[0]
+			if alert.Status != tt.status || !alert.StartsAt.Equal(created) || !alert.EndsAt.Equal(tt.endsAt) {
+				t.Errorf("alert = %+v", alert)
+			}
+			if !reflect.DeepEqual(alert.Labels, tt.labels) || !reflect.DeepEqual(payload.CommonLabels, tt.labels) {
+				t.Errorf("labels = %v, want %v", alert.Labels, tt.labels)
+			}
+			if !reflect.DeepEqual(alert.Annotations, tt.annotations) {
+				t.Errorf("annotations = %v, want %v", alert.Annotations, tt.annotations)
+			}
+			if want := `{}:{alertname="` + tt.labels["alertname"] + `"}`; payload.GroupKey != want {
+				t.Errorf("GroupKey = %s, want %s", payload.GroupKey, want)
+			}
+			if alert.Fingerprint != Fingerprint(tt.labels) {
+				t.Errorf("Fingerprint = %s", alert.Fingerprint)
+			}
+		})
+	}
+
+	data, err := json.Marshal(FromMessage(message.New().SetBody("ping"), "", now))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	for _, field := range []string{`"groupKey":`, `"commonAnnotations":`, `"startsAt":`, `"alertname":"NotifyHub"`, `"severity":"warning"`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("payload %s lacks %s", data, field)
+		}
+	}
+}
+
+func TestPayload_Messages(t *testing.T) {
+	var payload Payload
+	err := json.Unmarshal([]byte(`{
+		"version": "4",
+		"groupKey": "{}:{alertname=\"DiskFull\"}",
+		"status": "firing",
+		"receiver": "notifyhub",
+		"alerts": [
+			{
+				"status": "firing",
+				"labels": {"alertname": "DiskFull", "instance": "db-1", "severity": "critical"},
+				"annotations": {"summary": "Disk full on db-1", "description": "/var at 95%"},
+				"startsAt": "2026-01-02T03:04:05Z",
+				"generatorURL": "http://prometheus/graph?g0.expr=disk"
+			},
+			{
+				"status": "resolved",
+				"labels": {"alertname": "DiskFull", "instance": "db-2", "severity": "Page"},
+				"annotations": {}
+			},
+			{
+				"labels": {"alertname": "Heartbeat", "severity": "unknown"},
+				"annotations": {"message": "no heartbeat"}
+			}
+		]
+	}`), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		title    string
+		body     string
+		priority message.Priority
+		status   string
+	}{
+		{"Disk full on db-1", "/var at 95%\n\nhttp://prometheus/graph?g0.expr=disk", message.PriorityHigh, "firing"},
+		{"[RESOLVED] DiskFull", "", message.PriorityUrgent, "resolved"},
+		{"Heartbeat", "no heartbeat", message.PriorityNormal, "firing"},
+	}
+	messages := payload.Messages()
+	if len(messages) != len(tests) {
+		t.Fatalf("Messages() = %d messages, want %d", len(messages), len(tests))
+	}
+	for i, tt := range tests {
+		msg := messages[i]
+		if msg.Title != tt.title || msg.Body != tt.body || msg.Priority != tt.priority || msg.AlertStatus() != tt.status {
+			t.Errorf("message %d = %q %q %d %s, want %q %q %d %s", i, msg.Title, msg.Body, msg.Priority, msg.AlertStatus(), tt.title, tt.body, tt.priority, tt.status)
+		}
+		if msg.AlertGroup() != payload.GroupKey || !reflect.DeepEqual(msg.AlertLabels(), payload.Alerts[i].Labels) {
+			t.Errorf("message %d: AlertGroup() = %q, AlertLabels() = %v", i, msg.AlertGroup(), msg.AlertLabels())
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	if got := Fingerprint(nil); got != "cbf29ce484222325" {
+		t.Errorf("Fingerprint(nil) = %s, want the FNV-1a offset basis", got)
+	}
+	a := Fingerprint(map[string]string{"a": "b", "c": "d"})
+	if b := Fingerprint(map[string]string{"c": "d", "a": "b"}); a != b {
+		t.Errorf("Fingerprint() = %s and %s for the same labels", a, b)
+	}
+	if b := Fingerprint(map[string]string{"a": "bc", "": "d"}); a == b {
+		t.Errorf("Fingerprint() collides for different label sets")
+	}
+}
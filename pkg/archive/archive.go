@@ -0,0 +1,349 @@
+// Package archive writes completed messages and their receipts to
+// long-term storage, such as a local directory or an S3 or GCS bucket, so
+// that they can be retained for years without growing the primary receipt
+// store.
+//
+// An Archiver buffers records and writes them on a schedule as
+// gzip-compressed JSON lines, one object per partition:
+//
+//	notifyhub/date=2026-10-16/tenant=acme/20261016T120000Z-1f2e3d4c-7.jsonl.gz
+//
+// Partitions follow the key=value layout that Athena, BigQuery and Spark
+// read as columns. Query reads the records of a time range back from the
+// stores that also implement Reader, as DirStore and S3Store do.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// DefaultInterval is how often buffered records are written when no
+// interval is configured
+const DefaultInterval = time.Hour
+
+// DefaultMaxRecords is the number of buffered records that are written
+// before the interval passes when no maximum is configured
+const DefaultMaxRecords = 10000
+
+// NoTenant is the tenant partition of messages sent for no tenant
+const NoTenant = "_none"
+
+// Record is an archived message with its receipt
+type Record struct {
+	Message    *message.Message `json:"message"`
+	Receipt    *receipt.Receipt `json:"receipt"`
+	ArchivedAt time.Time        `json:"archived_at"`
+}
+
+// Time returns when the message of a record completed
+func (r Record) Time() time.Time {
+	if r.Receipt != nil && !r.Receipt.Timestamp.IsZero() {
+		return r.Receipt.Timestamp
+	}
+	return r.ArchivedAt
+}
+
+// Store writes archive objects
+type Store interface {
+	// Put writes the data of an object, replacing any object of the key
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// Reader lists and reads archive objects
+type Reader interface {
+	// List returns the keys of the objects below a prefix, in order
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Get reads the data of an object
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Options configures an Archiver
+type Options struct {
+	Prefix            string        // prepended to object keys, e.g. "notifyhub"
+	Interval          time.Duration // how often records are written, DefaultInterval when zero
+	MaxRecords        int           // buffered records written before the interval passes, DefaultMaxRecords when zero
+	PartitionByTenant bool          // partition by tenant below the date
+	Logger            logger.Logger
+}
+
+// Archiver buffers records and writes them to a store. It is safe for
+// concurrent use.
+type Archiver struct {
+	store    Store
+	opts     Options
+	instance string // distinguishes the objects of archivers sharing a store
+	now      func() time.Time
+
+	mu      sync.Mutex
+	records []Record
+	seq     int
+
+	flushMu sync.Mutex // serializes writes, so records are written in order
+	full    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New creates an archiver writing to a store; Start writes on a schedule
+func New(store Store, opts Options) *Archiver {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.MaxRecords <= 0 {
+		opts.MaxRecords = DefaultMaxRecords
+	}
+	if opts.Logger == nil {
+		opts.Logger = logger.Discard
+	}
+	id := make([]byte, 4)
+	_, _ = rand.Read(id)
+	return &Archiver{
+		store:    store,
+		opts:     opts,
+		instance: hex.EncodeToString(id),
+		now:      time.Now,
+		full:     make(chan struct{}, 1),
+	}
+}
+
+// Add buffers a message and its receipt for archiving
+func (a *Archiver) Add(msg *message.Message, rcpt *receipt.Receipt) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, Record{Message: msg, Receipt: rcpt, ArchivedAt: a.now()})
+	if len(a.records) >= a.opts.MaxRecords {
+		select {
+		case a.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Pending returns the number of buffered records
+func (a *Archiver) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.records)
+}
+
+// Start writes the buffered records every interval, and as soon as
+// MaxRecords are buffered, until the archiver is closed
+func (a *Archiver) Start() {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go a.run()
+}
+
+// run writes the buffered records until the archiver is closed
+func (a *Archiver) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		case <-a.full:
+		}
+		if err := a.Flush(context.Background()); err != nil {
+			a.opts.Logger.Warn("Failed to archive messages", "pending", a.Pending(), "error", err)
+		}
+	}
+}
+
+// Flush writes the buffered records, one object per partition. Records of
+// partitions that could not be written stay buffered for the next flush;
+// beyond ten times MaxRecords the oldest of them are dropped.
+func (a *Archiver) Flush(ctx context.Context) error {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	a.mu.Lock()
+	records := a.records
+	a.records = nil
+	a.mu.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	partitions := make(map[string][]Record)
+	for _, r := range records {
+		key := a.partition(r)
+		partitions[key] = append(partitions[key], r)
+	}
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var failed []Record
+	var lastErr error
+	for _, key := range keys {
+		if err := a.write(ctx, key, partitions[key]); err != nil {
+			failed = append(failed, partitions[key]...)
+			lastErr = err
+			continue
+		}
+		a.opts.Logger.Debug("Archived messages", "partition", key, "records", len(partitions[key]))
+	}
+	if len(failed) > 0 {
+		a.requeue(failed)
+	}
+	return lastErr
+}
+
+// Close stops the scheduled writes and writes the buffered records
+func (a *Archiver) Close(ctx context.Context) error {
+	if a.stop != nil {
+		close(a.stop)
+		<-a.done
+	}
+	return a.Flush(ctx)
+}
+
+// partition returns the partition of a record
+func (a *Archiver) partition(r Record) string {
+	parts := []string{a.opts.Prefix, "date=" + r.Time().UTC().Format("2006-01-02")}
+	if a.opts.PartitionByTenant {
+		tenant := NoTenant
+		if r.Message != nil && r.Message.Tenant() != "" {
+			tenant = r.Message.Tenant()
+		}
+		parts = append(parts, "tenant="+url.PathEscape(tenant))
+	}
+	return path.Join(parts...)
+}
+
+// write writes the records of a partition as a new object
+func (a *Archiver) write(ctx context.Context, partition string, records []Record) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, r := range records {
+		// A record that cannot be encoded would never be written, so it
+		// is dropped instead of holding back its partition
+		if err := enc.Encode(r); err != nil {
+			a.opts.Logger.Error("Dropping unencodable archive record", "partition", partition, "error", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.seq++
+	seq := a.seq
+	a.mu.Unlock()
+	name := fmt.Sprintf("%s-%s-%d.jsonl.gz", a.now().UTC().Format("20060102T150405Z"), a.instance, seq)
+	key := path.Join(partition, name)
+	if err := a.store.Put(ctx, key, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write archive object %s: %w", key, err)
+	}
+	return nil
+}
+
+// requeue buffers records that could not be written ahead of the records
+// added since, dropping the oldest beyond ten times MaxRecords
+func (a *Archiver) requeue(records []Record) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(records, a.records...)
+	if limit := 10 * a.opts.MaxRecords; len(a.records) > limit {
+		dropped := len(a.records) - limit
+		a.records = append([]Record(nil), a.records[dropped:]...)
+		a.opts.Logger.Error("Dropping unarchived messages", "count", dropped)
+	}
+}
+
+// Read decodes the records of an archive object
+func Read(data []byte) ([]Record, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive object: %w", err)
+	}
+	defer zr.Close()
+
+	var records []Record
+	dec := json.NewDecoder(zr)
+	for dec.More() {
+		var r Record
+		if err := dec.Decode(&r); err != nil {
+			return records, fmt.Errorf("invalid archive record %d: %w", len(records), err)
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// Query reads the records of the objects below a prefix, as written with
+// Options.Prefix, whose messages completed at or after from and before
+// to; zero times leave the range open. Objects of dates outside the range
+// are not read.
+func Query(ctx context.Context, r Reader, prefix string, from, to time.Time) ([]Record, error) {
+	keys, err := r.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive objects: %w", err)
+	}
+
+	var records []Record
+	for _, key := range keys {
+		if date, ok := partitionDate(key); ok && !overlaps(date, from, to) {
+			continue
+		}
+		data, err := r.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive object %s: %w", key, err)
+		}
+		read, err := Read(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		for _, record := range read {
+			at := record.Time()
+			if (from.IsZero() || !at.Before(from)) && (to.IsZero() || at.Before(to)) {
+				records = append(records, record)
+			}
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time().Before(records[j].Time())
+	})
+	return records, nil
+}
+
+// partitionDate returns the date of the partition of an object key
+func partitionDate(key string) (time.Time, bool) {
+	for _, part := range strings.Split(key, "/") {
+		if value, ok := strings.CutPrefix(part, "date="); ok {
+			date, err := time.Parse("2006-01-02", value)
+			return date, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+// overlaps reports whether the UTC day starting at date overlaps the range
+// from, to
+func overlaps(date, from, to time.Time) bool {
+	return (from.IsZero() || date.AddDate(0, 0, 1).After(from)) && (to.IsZero() || date.Before(to))
+}
@@ -1,13 +1,22 @@
 package async
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/kart-io/notifyhub/pkg/message"
 	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/secret"
 	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/redis"
 )
 
 func TestMemoryHandle_Status(t *testing.T) {
@@ -207,6 +216,35 @@ func TestMemoryQueue_EnqueueAfterClose(t *testing.T) {
 	}
 }
 
+func TestMemoryQueue_Drain(t *testing.T) {
+	queue := NewMemoryQueue(QueueConfig{Workers: 2, BufferSize: 10})
+
+	ctx := context.Background()
+	var handles []Handle
+	for _, id := range []string{"first", "second"} {
+		handle, err := queue.Enqueue(ctx, &message.Message{ID: id, Body: "Test message"}, []target.Target{}, nil)
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		handles = append(handles, handle)
+	}
+
+	if got := queue.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+	if drained := queue.Drain(); drained != 2 {
+		t.Errorf("Drain() = %d, want 2", drained)
+	}
+	if got := queue.Len(); got != 0 {
+		t.Errorf("Len() after Drain() = %d, want 0", got)
+	}
+	for _, handle := range handles {
+		if _, err := handle.Wait(ctx); !errors.Is(err, ErrDrained) {
+			t.Errorf("Wait() error = %v, want ErrDrained", err)
+		}
+	}
+}
+
 func TestMemoryQueue_StartStop(t *testing.T) {
 	queue := NewMemoryQueue(QueueConfig{Workers: 2, BufferSize: 10})
 
@@ -385,3 +423,491 @@ func TestCallbackManager_HasCallbacks(t *testing.T) {
 		t.Error("HasCallbacks() = false, want true (callback set)")
 	}
 }
+
+// fakeRedis serves the commands of a RedisQueue, running its scripts as
+// Go code on an in-memory copy of the keys
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	pending  []string // LPUSH adds to the front, RPOP takes from the back
+	held     map[string]time.Time
+	items    map[string]string
+	attempts map[string]int64
+	dead     []string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{listener: listener, held: make(map[string]time.Time), items: make(map[string]string), attempts: make(map[string]int64)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := redis.ReadReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range reply.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+		f.mu.Lock()
+		out := f.do(args)
+		f.mu.Unlock()
+		if _, err := conn.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+// do runs a command and returns its RESP reply
+func (f *fakeRedis) do(args []string) string {
+	switch args[0] {
+	case "PING":
+		return "+PONG\r\n"
+	case "LLEN":
+		return fmt.Sprintf(":%d\r\n", len(f.pending))
+	case "LRANGE":
+		return respArray(f.dead...)
+	case "RPUSH":
+		f.dead = append(f.dead, args[2:]...)
+		return fmt.Sprintf(":%d\r\n", len(f.dead))
+	case "RPOP":
+		if len(f.dead) == 0 {
+			return "$-1\r\n"
+		}
+		last := f.dead[len(f.dead)-1]
+		f.dead = f.dead[:len(f.dead)-1]
+		return respArray(last)[4:]
+	case "EVALSHA":
+	default:
+		return "-ERR unknown command\r\n"
+	}
+
+	argv := args[3+len(f.keys()):]
+	switch args[1] {
+	case enqueueScript.SHA():
+		f.items[argv[0]] = argv[1]
+		delete(f.attempts, argv[0])
+		f.pending = append([]string{argv[0]}, f.pending...)
+		return ":1\r\n"
+	case reserveScript.SHA():
+		now := time.Now()
+		for id, until := range f.held {
+			if !until.After(now) {
+				delete(f.held, id)
+				f.pending = append(f.pending, id)
+			}
+		}
+		if len(f.pending) == 0 {
+			return "$-1\r\n"
+		}
+		id := f.pending[len(f.pending)-1]
+		f.pending = f.pending[:len(f.pending)-1]
+		item, ok := f.items[id]
+		if !ok {
+			return "*0\r\n"
+		}
+		var ms time.Duration
+		fmt.Sscan(argv[0], &ms)
+		f.held[id] = now.Add(ms * time.Millisecond)
+		f.attempts[id]++
+		return fmt.Sprintf("*3\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n:%d\r\n", len(id), id, len(item), item, f.attempts[id])
+	case extendScript.SHA():
+		if _, ok := f.held[argv[0]]; !ok {
+			return ":0\r\n"
+		}
+		var ms time.Duration
+		fmt.Sscan(argv[1], &ms)
+		f.held[argv[0]] = time.Now().Add(ms * time.Millisecond)
+		return ":1\r\n"
+	case ackScript.SHA():
+		delete(f.held, argv[0])
+		delete(f.items, argv[0])
+		delete(f.attempts, argv[0])
+		return ":1\r\n"
+	case retryScript.SHA():
+		if _, ok := f.held[argv[0]]; !ok {
+			return ":0\r\n"
+		}
+		var ms time.Duration
+		fmt.Sscan(argv[1], &ms)
+		f.held[argv[0]] = time.Now().Add(ms * time.Millisecond)
+		f.items[argv[0]] = argv[2]
+		return ":1\r\n"
+	case deadScript.SHA():
+		delete(f.held, argv[0])
+		delete(f.items, argv[0])
+		delete(f.attempts, argv[0])
+		f.dead = append([]string{argv[1]}, f.dead...)
+		return ":1\r\n"
+	case drainScript.SHA():
+		ids := f.pending
+		f.pending = nil
+		for _, id := range ids {
+			delete(f.items, id)
+			delete(f.attempts, id)
+		}
+		return respArray(ids...)
+	}
+	return "-NOSCRIPT No matching script\r\n"
+}
+
+// keys returns placeholders for the keys of a RedisQueue, which the fake
+// does not tell apart
+func (f *fakeRedis) keys() []string {
+	return make([]string, 5)
+}
+
+// respArray encodes strings as a RESP array
+func respArray(values ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(values))
+	for _, value := range values {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(value), value)
+	}
+	return b.String()
+}
+
+func TestRedisQueue(t *testing.T) {
+	server := newFakeRedis(t)
+	recovered := make(chan string, 10)
+	queue, err := NewRedisQueue(RedisQueueConfig{
+		QueueConfig:  QueueConfig{Workers: 1, RetryPolicy: RetryPolicy{InitialInterval: 10 * time.Millisecond}},
+		Redis:        redis.Options{Addr: server.listener.Addr().String()},
+		MaxAttempts:  2,
+		PollInterval: 5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewRedisQueue() error = %v", err)
+	}
+	queue.SetProcessor(func(ctx context.Context, msg *message.Message, targets []target.Target) Result {
+		recovered <- msg.ID
+		return Result{Receipt: &receipt.Receipt{MessageID: msg.ID}}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Messages waiting before Start can be drained
+	var drained []Handle
+	for _, id := range []string{"first", "second"} {
+		handle, err := queue.Enqueue(ctx, &message.Message{ID: id, Body: "Test message"}, nil)
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		drained = append(drained, handle)
+	}
+	if got := queue.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+	if got := queue.Drain(); got != 2 {
+		t.Errorf("Drain() = %d, want 2", got)
+	}
+	for _, handle := range drained {
+		if _, err := handle.Wait(ctx); !errors.Is(err, ErrDrained) {
+			t.Errorf("Wait() error = %v, want ErrDrained", err)
+		}
+	}
+
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer queue.Stop(context.Background())
+	if err := queue.IsHealthy(ctx); err != nil {
+		t.Errorf("IsHealthy() error = %v", err)
+	}
+
+	sent := func(ctx context.Context, msg *message.Message, targets []target.Target) Result {
+		return Result{Receipt: &receipt.Receipt{MessageID: msg.ID}}
+	}
+	handle, err := queue.EnqueueWithProcessor(ctx, &message.Message{ID: "ok", Body: "Test message"}, nil, sent)
+	if err != nil {
+		t.Fatalf("EnqueueWithProcessor() error = %v", err)
+	}
+	if r, err := handle.Wait(ctx); err != nil || r.MessageID != "ok" {
+		t.Fatalf("Wait() = %v, %v, want the receipt of the processor", r, err)
+	}
+
+	var calls int
+	failing := func(ctx context.Context, msg *message.Message, targets []target.Target) Result {
+		calls++
+		return Result{Error: errors.New("platform down")}
+	}
+	handle, err = queue.EnqueueWithProcessor(ctx, &message.Message{ID: "failing", Body: "Test message"}, nil, failing)
+	if err != nil {
+		t.Fatalf("EnqueueWithProcessor() error = %v", err)
+	}
+	if _, err := handle.Wait(ctx); err == nil || err.Error() != "platform down" {
+		t.Fatalf("Wait() error = %v, want the error of the last attempt", err)
+	}
+	if calls != 2 {
+		t.Errorf("processor called %d times, want MaxAttempts", calls)
+	}
+	letters, err := queue.DeadLetters(ctx)
+	if err != nil || len(letters) != 1 || letters[0].Message.ID != "failing" || letters[0].Attempts != 2 || letters[0].Error != "platform down" {
+		t.Fatalf("DeadLetters() = %+v, %v, want the failed message", letters, err)
+	}
+
+	// Requeued dead letters have no local processor left
+	if n, err := queue.RequeueDeadLetters(ctx); err != nil || n != 1 {
+		t.Fatalf("RequeueDeadLetters() = %d, %v, want 1", n, err)
+	}
+	select {
+	case id := <-recovered:
+		if id != "failing" {
+			t.Errorf("Processor got %s, want the requeued message", id)
+		}
+	case <-ctx.Done():
+		t.Fatal("requeued message was not processed")
+	}
+	// The processor returns before the message is acknowledged
+	stats := queue.GetStats()
+	for stats.Completed < 2 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+		stats = queue.GetStats()
+	}
+	if stats.Completed != 2 || stats.Failed != 1 || stats.Pending != 0 {
+		t.Errorf("GetStats() = %+v, want 2 completed and 1 failed", stats)
+	}
+}
+
+func TestRedisQueue_Cipher(t *testing.T) {
+	server := newFakeRedis(t)
+	key := make([]byte, 32)
+	open := func(cipher *secret.PayloadCipher) *RedisQueue {
+		t.Helper()
+		queue, err := NewRedisQueue(RedisQueueConfig{
+			QueueConfig:  QueueConfig{Workers: 1},
+			Redis:        redis.Options{Addr: server.listener.Addr().String()},
+			MaxAttempts:  1,
+			PollInterval: 5 * time.Millisecond,
+			Cipher:       cipher,
+		})
+		if err != nil {
+			t.Fatalf("NewRedisQueue() error = %v", err)
+		}
+		return queue
+	}
+	queue := open(secret.NewPayloadCipher(secret.StaticKey(key)))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	failing := func(ctx context.Context, msg *message.Message, targets []target.Target) Result {
+		return Result{Error: errors.New("platform down")}
+	}
+	msg := &message.Message{ID: "secret", Body: "Your code is 123456"}
+	handle, err := queue.EnqueueWithProcessor(ctx, msg, []target.Target{target.NewEmail("alice@example.com")}, failing)
+	if err != nil {
+		t.Fatalf("EnqueueWithProcessor() error = %v", err)
+	}
+	server.mu.Lock()
+	for _, item := range server.items {
+		if strings.Contains(item, "123456") || strings.Contains(item, "alice@example.com") || !secret.IsSealed([]byte(item)) {
+			t.Errorf("queued item = %s, want it encrypted", item)
+		}
+	}
+	server.mu.Unlock()
+
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer queue.Stop(context.Background())
+	if _, err := handle.Wait(ctx); err == nil {
+		t.Fatal("Wait() error = nil, want the failure")
+	}
+	server.mu.Lock()
+	for _, letter := range server.dead {
+		if strings.Contains(letter, "123456") || !secret.IsSealed([]byte(letter)) {
+			t.Errorf("dead letter = %s, want it encrypted", letter)
+		}
+	}
+	server.mu.Unlock()
+	letters, err := queue.DeadLetters(ctx)
+	if err != nil || len(letters) != 1 || letters[0].Message.Body != msg.Body || letters[0].Targets[0].Value != "alice@example.com" {
+		t.Fatalf("DeadLetters() = %+v, %v, want the decrypted message", letters, err)
+	}
+
+	// Without the key, dead letters cannot be read and are not lost
+	plain := open(nil)
+	if _, err := plain.DeadLetters(ctx); !errors.Is(err, secret.ErrPayloadEncrypted) {
+		t.Errorf("DeadLetters() without the key error = %v, want ErrPayloadEncrypted", err)
+	}
+	if n, err := plain.RequeueDeadLetters(ctx); err == nil || n != 0 {
+		t.Errorf("RequeueDeadLetters() without the key = %d, %v, want an error", n, err)
+	}
+	if letters, err := queue.DeadLetters(ctx); err != nil || len(letters) != 1 {
+		t.Errorf("DeadLetters() after a failed requeue = %+v, %v, want the letter kept", letters, err)
+	}
+}
+
+func TestRedisQueue_Hold(t *testing.T) {
+	server := newFakeRedis(t)
+	queue, err := NewRedisQueue(RedisQueueConfig{
+		QueueConfig:       QueueConfig{Workers: 2},
+		Redis:             redis.Options{Addr: server.listener.Addr().String()},
+		VisibilityTimeout: 30 * time.Millisecond,
+		PollInterval:      5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewRedisQueue() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// A send outlasting the visibility timeout is not delivered again, and
+	// Stop waits for it rather than cancelling it
+	var calls atomic.Int32
+	started := make(chan struct{})
+	var sendErr error
+	slow := func(ctx context.Context, msg *message.Message, targets []target.Target) Result {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		select {
+		case <-time.After(150 * time.Millisecond):
+		case <-ctx.Done():
+			sendErr = ctx.Err()
+		}
+		return Result{Receipt: &receipt.Receipt{MessageID: msg.ID}}
+	}
+	handle, err := queue.EnqueueWithProcessor(ctx, &message.Message{ID: "slow", Body: "Test message"}, nil, slow)
+	if err != nil {
+		t.Fatalf("EnqueueWithProcessor() error = %v", err)
+	}
+	<-started
+	time.Sleep(60 * time.Millisecond)
+	if err := queue.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if r, err := handle.Wait(ctx); err != nil || r.MessageID != "slow" {
+		t.Errorf("Wait() = %v, %v, want the receipt", r, err)
+	}
+	if sendErr != nil {
+		t.Errorf("send interrupted by Stop: %v", sendErr)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("processor called %d times, want once", n)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.items) != 0 || len(server.held) != 0 {
+		t.Errorf("items = %v, held = %v, want the message acknowledged", server.items, server.held)
+	}
+}
+
+func TestRedisQueue_Unreadable(t *testing.T) {
+	server := newFakeRedis(t)
+	queue, err := NewRedisQueue(RedisQueueConfig{
+		QueueConfig:       QueueConfig{Workers: 1},
+		Redis:             redis.Options{Addr: server.listener.Addr().String()},
+		VisibilityTimeout: 10 * time.Millisecond,
+		MaxAttempts:       2,
+		PollInterval:      5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewRedisQueue() error = %v", err)
+	}
+	processed := make(chan string, 10)
+	queue.SetProcessor(func(ctx context.Context, msg *message.Message, targets []target.Target) Result {
+		processed <- msg.ID
+		return Result{Receipt: &receipt.Receipt{MessageID: msg.ID}}
+	})
+
+	// An invalid item is dead-lettered at once; one encrypted with a key
+	// this client lacks after MaxAttempts
+	sealed, err := secret.NewPayloadCipher(secret.StaticKey(make([]byte, 32))).Seal(context.Background(), []byte(`{"id":"sealed","message":{"id":"sealed"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	server.items["invalid"] = "{"
+	server.items["sealed"] = string(sealed)
+	server.pending = []string{"sealed", "invalid"}
+	server.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer queue.Stop(context.Background())
+
+	var letters []DeadLetter
+	for len(letters) < 2 && ctx.Err() == nil {
+		time.Sleep(5 * time.Millisecond)
+		if letters, err = queue.DeadLetters(ctx); err != nil {
+			t.Fatalf("DeadLetters() error = %v", err)
+		}
+	}
+	if len(letters) != 2 {
+		t.Fatalf("DeadLetters() = %+v, want both items", letters)
+	}
+	byID := map[string]DeadLetter{letters[0].ID: letters[0], letters[1].ID: letters[1]}
+	if l := byID["invalid"]; l.Data != "{" || l.Attempts != 1 || l.Message != nil {
+		t.Errorf("invalid item letter = %+v, want it buried as stored after one attempt", l)
+	}
+	if l := byID["sealed"]; l.Data != string(sealed) || l.Attempts != 2 || !strings.Contains(l.Error, "decrypt") {
+		t.Errorf("sealed item letter = %+v, want it buried as stored after MaxAttempts", l)
+	}
+	select {
+	case id := <-processed:
+		t.Errorf("Processor got %s, want no message", id)
+	default:
+	}
+	if stats := queue.GetStats(); stats.Failed != 2 {
+		t.Errorf("GetStats() = %+v, want 2 failed", stats)
+	}
+}
+
+// BenchmarkMemoryQueue_EnqueueProcess measures a message through the
+// queue: enqueued, dequeued by a worker and its result delivered
+func BenchmarkMemoryQueue_EnqueueProcess(b *testing.B) {
+	queue := NewMemoryQueue(QueueConfig{Workers: 4, BufferSize: 1000})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_ = queue.Start(ctx)
+	defer func() { _ = queue.Stop(context.Background()) }()
+
+	processor := func(ctx context.Context, msg *message.Message, targets []target.Target) Result {
+		return Result{Receipt: &receipt.Receipt{MessageID: msg.ID}}
+	}
+	msg := message.New()
+	msg.ID = "bench"
+	targets := []target.Target{target.NewEmail("ops@example.com")}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	handles := make([]Handle, 0, b.N)
+	for i := 0; i < b.N; i++ {
+		handle, err := queue.EnqueueWithProcessor(ctx, msg, targets, processor)
+		if err != nil {
+			b.Fatal(err)
+		}
+		handles = append(handles, handle)
+	}
+	for _, handle := range handles {
+		if _, err := handle.Wait(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "msg/s")
+}
@@ -0,0 +1,113 @@
+package async_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/async"
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/secret"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+	"github.com/kart-io/notifyhub/pkg/utils/redis"
+)
+
+// flakyPlatform delivers to every target but the unreachable one, and
+// counts the sends to each target
+type flakyPlatform struct {
+	unreachable string
+	mu          sync.Mutex
+	sends       map[string]int
+}
+
+func (p *flakyPlatform) Name() string { return "sms" }
+
+func (p *flakyPlatform) GetCapabilities() platform.Capabilities {
+	return platform.Capabilities{Name: "sms", SupportedTargetTypes: []string{"phone"}}
+}
+
+func (p *flakyPlatform) Send(_ context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	results := make([]*platform.SendResult, 0, len(targets))
+	for _, tgt := range targets {
+		p.sends[tgt.Value]++
+		result := &platform.SendResult{Target: tgt, Success: true}
+		if tgt.Value == p.unreachable {
+			result = &platform.SendResult{Target: tgt, Error: errors.New("number unreachable")}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (p *flakyPlatform) ValidateTarget(target.Target) error { return nil }
+func (p *flakyPlatform) IsHealthy(context.Context) error    { return nil }
+func (p *flakyPlatform) Close() error                       { return nil }
+
+func TestRedisQueue_ClientDeadLetters(t *testing.T) {
+	addr := async.NewFakeRedisAddr(t)
+	key := secret.StaticKey(make([]byte, 32))
+	client, err := notifyhub.NewClientFromOptions(
+		config.WithQuickWebhook("https://hooks.example.com/notify"),
+		config.WithExternalPlatforms("sms"),
+		config.WithQueue(config.QueueRedis, config.RedisQueueConfig{Addr: addr, MaxAttempts: 3, Encryption: key}),
+		config.WithRetry(config.RetryPolicy{MaxAttempts: 1, InitialInterval: 10 * time.Millisecond}),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	sms := &flakyPlatform{unreachable: "+8613800138001", sends: make(map[string]int)}
+	if err := client.RegisterPlatform("sms", func(interface{}) (platform.Platform, error) { return sms, nil }); err != nil {
+		t.Fatalf("RegisterPlatform() error = %v", err)
+	}
+	if err := client.SetPlatformConfig("sms", "NotifyHub"); err != nil {
+		t.Fatalf("SetPlatformConfig() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg := message.New().SetTitle("Code").SetBody("123456")
+	msg.Targets = []target.Target{
+		{Type: "phone", Value: "+8613800138000", Platform: "sms"},
+		{Type: "phone", Value: "+8613800138001", Platform: "sms"},
+	}
+	handle, err := client.SendAsync(ctx, msg)
+	if err != nil {
+		t.Fatalf("SendAsync() error = %v", err)
+	}
+	if _, err := handle.Wait(ctx); err == nil {
+		t.Fatal("Wait() error = nil, want the failure of the last attempt")
+	}
+
+	// Only the failed target is sent again
+	sms.mu.Lock()
+	delivered, failed := sms.sends["+8613800138000"], sms.sends["+8613800138001"]
+	sms.mu.Unlock()
+	if delivered != 1 || failed != 3 {
+		t.Errorf("sends = %d delivered and %d failed, want 1 and MaxAttempts", delivered, failed)
+	}
+
+	// The dead letters are shared by the queues of the fleet with the key
+	queue, err := async.NewRedisQueue(async.RedisQueueConfig{Redis: redis.Options{Addr: addr}, Cipher: secret.NewPayloadCipher(key)})
+	if err != nil {
+		t.Fatalf("NewRedisQueue() error = %v", err)
+	}
+	letters, err := queue.DeadLetters(ctx)
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("DeadLetters() = %+v, %v, want the failed message", letters, err)
+	}
+	letter := letters[0]
+	if letter.Message.ID != msg.ID || letter.Attempts != 3 || len(letter.Targets) != 1 || letter.Targets[0].Value != "+8613800138001" {
+		t.Errorf("DeadLetters() = %+v, want the message with its failed target after 3 attempts", letter)
+	}
+}
@@ -0,0 +1,9 @@
+package async
+
+import "testing"
+
+// NewFakeRedisAddr starts a fake Redis server for the tests of package
+// async_test and returns its address
+func NewFakeRedisAddr(t *testing.T) string {
+	return newFakeRedis(t).listener.Addr().String()
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/kart-io/notifyhub/pkg/message"
 	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/target"
 )
 
 // Handle represents an asynchronous operation handle
@@ -85,6 +86,10 @@ const (
 type Result struct {
 	Receipt *receipt.Receipt `json:"receipt,omitempty"`
 	Error   error            `json:"error,omitempty"`
+
+	// Failed are the targets whose delivery failed, which a RedisQueue
+	// sends again without the targets that were delivered
+	Failed []target.Target `json:"failed,omitempty"`
 }
 
 // Callback function types are defined in callback.go
@@ -3,6 +3,7 @@ package async
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -32,6 +33,25 @@ type Queue interface {
 	GetStats() QueueStats
 }
 
+// ProcessingQueue is a Queue whose messages are processed by the function
+// they are enqueued with, as the client enqueues its sends. MemoryQueue
+// and RedisQueue implement it.
+type ProcessingQueue interface {
+	Queue
+
+	// EnqueueWithProcessor adds a message processed by processor
+	EnqueueWithProcessor(ctx context.Context, msg *message.Message, targets []target.Target, processor ProcessorFunc, opts ...Option) (Handle, error)
+
+	// Drain removes the waiting messages and returns how many it removed
+	Drain() int
+
+	// Len returns the number of waiting messages
+	Len() int
+}
+
+// ErrDrained is the result of the messages removed from a queue by Drain
+var ErrDrained = errors.New("message drained from the queue")
+
 // QueueConfig configures the queue
 type QueueConfig struct {
 	Workers     int           `json:"workers"`
@@ -72,6 +92,23 @@ type QueueItem struct {
 	Attempts  int              `json:"attempts"`
 	Processor ProcessorFunc    `json:"-"` // Function to process the message
 	Handle    Handle           `json:"-"` // Handle to send results to
+
+	pooled bool // taken from itemPool by the queue, returned once processed
+}
+
+// itemPool recycles the items of memory queues, which are dropped once a
+// worker has processed them
+var itemPool = sync.Pool{
+	New: func() interface{} { return new(QueueItem) },
+}
+
+// releaseItem returns an item a queue took from the pool; items created
+// elsewhere are left alone
+func releaseItem(item *QueueItem) {
+	if item.pooled {
+		*item = QueueItem{}
+		itemPool.Put(item)
+	}
 }
 
 // MemoryQueue implements Queue using in-memory channels
@@ -110,16 +147,6 @@ func NewMemoryQueue(config QueueConfig) *MemoryQueue {
 
 // Enqueue adds a message to the queue
 func (q *MemoryQueue) Enqueue(ctx context.Context, msg *message.Message, targets []target.Target, opts ...Option) (Handle, error) {
-	// Check if queue is closed
-	q.closeMutex.Lock()
-	if q.closed {
-		q.closeMutex.Unlock()
-		return nil, fmt.Errorf("queue is closed")
-	}
-	q.closeMutex.Unlock()
-
-	handle := NewMemoryHandle(msg.ID)
-
 	// Create a default processor that returns an error since no processor was provided
 	defaultProcessor := func(ctx context.Context, message *message.Message, targets []target.Target) Result {
 		return Result{
@@ -127,28 +154,7 @@ func (q *MemoryQueue) Enqueue(ctx context.Context, msg *message.Message, targets
 			Error:   fmt.Errorf("no processor function provided for message %s", message.ID),
 		}
 	}
-
-	item := &QueueItem{
-		ID:        msg.ID,
-		Message:   msg,
-		Targets:   targets,
-		Options:   opts,
-		Created:   time.Now(),
-		Processor: defaultProcessor,
-		Handle:    handle,
-	}
-
-	select {
-	case q.items <- item:
-		q.statsMutex.Lock()
-		q.stats.Pending++
-		q.statsMutex.Unlock()
-		return handle, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-q.shutdownCtx.Done():
-		return nil, fmt.Errorf("queue is shutting down")
-	}
+	return q.EnqueueWithProcessor(ctx, msg, targets, defaultProcessor, opts...)
 }
 
 // EnqueueWithProcessor adds a message to the queue with a custom processor
@@ -163,7 +169,8 @@ func (q *MemoryQueue) EnqueueWithProcessor(ctx context.Context, msg *message.Mes
 
 	handle := NewMemoryHandle(msg.ID)
 
-	item := &QueueItem{
+	item := itemPool.Get().(*QueueItem)
+	*item = QueueItem{
 		ID:        msg.ID,
 		Message:   msg,
 		Targets:   targets,
@@ -171,6 +178,7 @@ func (q *MemoryQueue) EnqueueWithProcessor(ctx context.Context, msg *message.Mes
 		Created:   time.Now(),
 		Processor: processor,
 		Handle:    handle,
+		pooled:    true,
 	}
 
 	select {
@@ -180,8 +188,10 @@ func (q *MemoryQueue) EnqueueWithProcessor(ctx context.Context, msg *message.Mes
 		q.statsMutex.Unlock()
 		return handle, nil
 	case <-ctx.Done():
+		releaseItem(item)
 		return nil, ctx.Err()
 	case <-q.shutdownCtx.Done():
+		releaseItem(item)
 		return nil, fmt.Errorf("queue is shutting down")
 	}
 }
@@ -238,6 +248,33 @@ func (q *MemoryQueue) Stop(ctx context.Context) error {
 	return nil
 }
 
+// Drain removes the messages waiting in the queue, completing their
+// handles with ErrDrained, and returns how many it removed. Messages that
+// workers are processing are not affected.
+func (q *MemoryQueue) Drain() int {
+	drained := 0
+	for {
+		select {
+		case item, ok := <-q.items:
+			if !ok {
+				return drained
+			}
+			if memHandle, ok := item.Handle.(*MemoryHandle); ok {
+				memHandle.SetResultWithCallback(Result{Error: ErrDrained}, item.Message)
+			}
+			releaseItem(item)
+			drained++
+		default:
+			return drained
+		}
+	}
+}
+
+// Len returns the number of messages waiting in the queue
+func (q *MemoryQueue) Len() int {
+	return len(q.items)
+}
+
 // IsHealthy checks queue health
 func (q *MemoryQueue) IsHealthy(ctx context.Context) error {
 	// Simple health check - check if workers are running
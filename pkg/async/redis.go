@@ -0,0 +1,748 @@
+// Package async provides the Redis-backed queue for NotifyHub async
+// processing, which keeps queued messages across restarts
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/secret"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/idgen"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+	"github.com/kart-io/notifyhub/pkg/utils/redis"
+)
+
+// Defaults of a RedisQueue
+const (
+	DefaultRedisQueuePrefix  = "notifyhub:queue:"
+	DefaultVisibilityTimeout = 5 * time.Minute
+	DefaultMaxAttempts       = 3
+	defaultPollInterval      = 200 * time.Millisecond
+)
+
+// RedisQueueConfig configures a RedisQueue
+type RedisQueueConfig struct {
+	QueueConfig
+
+	Redis redis.Options
+
+	// KeyPrefix prefixes the keys of the queue; empty uses
+	// DefaultRedisQueuePrefix. Clients sharing a prefix share the queue.
+	KeyPrefix string
+
+	// VisibilityTimeout is how long a worker holds a message before it is
+	// delivered again, to another worker or after a restart; zero uses
+	// DefaultVisibilityTimeout. Workers extend the hold while they process
+	// a message, so a message is delivered again only when its worker
+	// stopped without finishing it.
+	VisibilityTimeout time.Duration
+
+	// MaxAttempts is how many times a message is processed before it is
+	// moved to the dead letters, including deliveries to clients that
+	// could not decrypt it; zero uses DefaultMaxAttempts
+	MaxAttempts int
+
+	// PollInterval is how long idle workers wait before looking for
+	// messages again; zero uses 200ms
+	PollInterval time.Duration
+
+	// Processor processes the messages that were not enqueued by this
+	// queue, such as those left by a client before it restarted
+	Processor ProcessorFunc
+
+	// Cipher, when set, encrypts the queued messages and the dead letters
+	// in Redis. Items stored before it was set stay readable.
+	Cipher *secret.PayloadCipher
+
+	Logger logger.Logger
+}
+
+// DeadLetter is a message moved out of a RedisQueue after its last failed
+// attempt
+type DeadLetter struct {
+	ID       string           `json:"id"`
+	Message  *message.Message `json:"message"`
+	Targets  []target.Target  `json:"targets"`
+	Created  time.Time        `json:"created"`
+	Attempts int              `json:"attempts"`
+	Error    string           `json:"error"`
+	FailedAt time.Time        `json:"failed_at"`
+
+	// Data is the item as stored in the queue when it could not be read,
+	// such as one encrypted with another key; Message is nil then
+	Data string `json:"data,omitempty"`
+}
+
+// redisItem is a queued message as stored in Redis
+type redisItem struct {
+	ID      string           `json:"id"`
+	Message *message.Message `json:"message"`
+	Targets []target.Target  `json:"targets"`
+	Created time.Time        `json:"created"`
+}
+
+// localItem is a message enqueued by this queue, whose handle completes
+// when one of its workers processes it
+type localItem struct {
+	msg       *message.Message
+	handle    *MemoryHandle
+	processor ProcessorFunc
+}
+
+// Scripts of a RedisQueue. Its keys are the list of pending IDs, the
+// sorted set of the IDs held by workers scored by when they are released,
+// the hash of the items by ID, the hash of their attempts by ID and the
+// list of dead letters. Times are taken from the server clock.
+var (
+	// enqueueScript stores an item and appends its ID to the pending list
+	enqueueScript = redis.NewScript(`
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[2])
+redis.call('HDEL', KEYS[4], ARGV[1])
+redis.call('LPUSH', KEYS[1], ARGV[1])
+return 1
+`)
+
+	// reserveScript releases the items whose visibility timeout or retry
+	// delay passed, then holds the oldest pending item for ARGV[1]
+	// milliseconds and returns its ID, item and attempts
+	reserveScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now)) do
+  redis.call('ZREM', KEYS[2], id)
+  redis.call('RPUSH', KEYS[1], id)
+end
+local id = redis.call('RPOP', KEYS[1])
+if not id then
+  return nil
+end
+local item = redis.call('HGET', KEYS[3], id)
+if not item then
+  return {}
+end
+redis.call('ZADD', KEYS[2], now + tonumber(ARGV[1]), id)
+local attempts = redis.call('HINCRBY', KEYS[4], id, 1)
+return {id, item, attempts}
+`)
+
+	// extendScript holds an item for ARGV[2] more milliseconds while it is
+	// processed, unless it was released
+	extendScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+if not redis.call('ZSCORE', KEYS[2], ARGV[1]) then
+  return 0
+end
+redis.call('ZADD', KEYS[2], now + tonumber(ARGV[2]), ARGV[1])
+return 1
+`)
+
+	// ackScript removes a processed item
+	ackScript = redis.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('HDEL', KEYS[4], ARGV[1])
+return 1
+`)
+
+	// retryScript replaces a failed item with ARGV[3], the targets left to
+	// send to, and holds it for ARGV[2] milliseconds, after which it is
+	// delivered again
+	retryScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+if not redis.call('ZSCORE', KEYS[2], ARGV[1]) then
+  return 0
+end
+redis.call('ZADD', KEYS[2], now + tonumber(ARGV[2]), ARGV[1])
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[3])
+return 1
+`)
+
+	// deadScript moves an item to the dead letters
+	deadScript = redis.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('HDEL', KEYS[4], ARGV[1])
+redis.call('LPUSH', KEYS[5], ARGV[2])
+return 1
+`)
+
+	// drainScript removes the pending items and returns their IDs
+	drainScript = redis.NewScript(`
+local ids = redis.call('LRANGE', KEYS[1], 0, -1)
+redis.call('DEL', KEYS[1])
+for _, id in ipairs(ids) do
+  redis.call('HDEL', KEYS[3], id)
+  redis.call('HDEL', KEYS[4], id)
+end
+return ids
+`)
+)
+
+// RedisQueue implements Queue in Redis, so that queued messages survive
+// restarts and are shared by the clients of a fleet. Messages are
+// delivered at least once: a worker holds a message for the visibility
+// timeout, and a message whose worker stopped before finishing it is
+// delivered again. Messages whose processing failed, or that failed for
+// some targets, are retried with the backoff of the retry policy for the
+// failed targets only, and moved to the dead letters with them after
+// MaxAttempts.
+//
+// Handles complete in the process that enqueued the message while it runs;
+// messages processed by another process complete no handle.
+type RedisQueue struct {
+	config RedisQueueConfig
+	pool   *redis.Pool
+	keys   []string
+	logger logger.Logger
+
+	mu      sync.Mutex
+	local   map[string]*localItem
+	stats   QueueStats
+	workers int
+	closed  bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewRedisQueue creates a queue kept in a Redis server
+func NewRedisQueue(config RedisQueueConfig) (*RedisQueue, error) {
+	pool, err := redis.NewPool(config.Redis)
+	if err != nil {
+		return nil, err
+	}
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = DefaultRedisQueuePrefix
+	}
+	if config.VisibilityTimeout <= 0 {
+		config.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	log := config.Logger
+	if log == nil {
+		log = logger.Discard
+	}
+	prefix := config.KeyPrefix
+	return &RedisQueue{
+		config: config,
+		pool:   pool,
+		keys:   []string{prefix + "pending", prefix + "processing", prefix + "items", prefix + "attempts", prefix + "dead"},
+		logger: log,
+		local:  make(map[string]*localItem),
+		stats:  QueueStats{UpdatedAt: time.Now()},
+	}, nil
+}
+
+// Enqueue adds a message to the queue, processed by the Processor of the
+// configuration
+func (q *RedisQueue) Enqueue(ctx context.Context, msg *message.Message, targets []target.Target, opts ...Option) (Handle, error) {
+	return q.EnqueueWithProcessor(ctx, msg, targets, nil, opts...)
+}
+
+// EnqueueWithProcessor adds a message to the queue. The processor
+// processes it when a worker of this queue takes it; the Processor of the
+// configuration does otherwise.
+func (q *RedisQueue) EnqueueWithProcessor(ctx context.Context, msg *message.Message, targets []target.Target, processor ProcessorFunc, opts ...Option) (Handle, error) {
+	q.mu.Lock()
+	closed := q.closed
+	q.mu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("queue is closed")
+	}
+
+	item := redisItem{ID: idgen.GenerateTaskID(), Message: msg, Targets: targets, Created: time.Now()}
+	data, err := q.encode(ctx, item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message %s: %w", msg.ID, err)
+	}
+	handle := NewMemoryHandle(msg.ID)
+	q.mu.Lock()
+	q.local[item.ID] = &localItem{msg: msg, handle: handle, processor: processor}
+	q.mu.Unlock()
+
+	if _, err := enqueueScript.Run(ctx, q.pool, q.keys, item.ID, data); err != nil {
+		q.mu.Lock()
+		delete(q.local, item.ID)
+		q.mu.Unlock()
+		return nil, fmt.Errorf("failed to enqueue message %s: %w", msg.ID, err)
+	}
+	q.mu.Lock()
+	q.stats.Pending++
+	q.mu.Unlock()
+	return handle, nil
+}
+
+// EnqueueBatch adds multiple messages to the queue
+func (q *RedisQueue) EnqueueBatch(ctx context.Context, msgs []*message.Message, opts ...Option) (BatchHandle, error) {
+	handles := make([]Handle, len(msgs))
+	for i, msg := range msgs {
+		handle, err := q.Enqueue(ctx, msg, msg.Targets, opts...)
+		if err != nil {
+			return nil, err
+		}
+		handles[i] = handle
+	}
+	return NewBatchHandle(handles), nil
+}
+
+// SetProcessor sets the Processor of the configuration, for a processor
+// that needs the queue to be created first. It must be called before Start.
+func (q *RedisQueue) SetProcessor(processor ProcessorFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.config.Processor = processor
+}
+
+// Start starts the workers, which also take the messages left in the queue
+// by earlier runs
+func (q *RedisQueue) Start(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cancel != nil {
+		return fmt.Errorf("queue is already started")
+	}
+	ctx, q.cancel = context.WithCancel(ctx)
+	q.workers = q.config.Workers
+	for i := 0; i < q.config.Workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx, i)
+	}
+	return nil
+}
+
+// Stop stops the workers after the messages they are processing, whose
+// sends are not interrupted. Queued messages stay in Redis for the next
+// start.
+func (q *RedisQueue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	cancel := q.cancel
+	q.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return q.pool.Close()
+}
+
+// work takes messages until the context ends
+func (q *RedisQueue) work(ctx context.Context, id int) {
+	defer q.wg.Done()
+	for ctx.Err() == nil {
+		took, err := q.processNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			q.logger.Warn("Redis queue worker failed", "worker_id", id, "error", err)
+		}
+		if took && err == nil {
+			continue
+		}
+		timer := time.NewTimer(q.config.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// processNext takes a message and processes it, reporting whether there
+// was one
+func (q *RedisQueue) processNext(ctx context.Context) (bool, error) {
+	reply, err := reserveScript.Run(ctx, q.pool, q.keys, strconv.FormatInt(q.config.VisibilityTimeout.Milliseconds(), 10))
+	if err != nil || reply == nil {
+		return false, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("unexpected reply %v", reply)
+	}
+	if len(values) != 3 {
+		// The ID of a drained item
+		return true, nil
+	}
+	id, _ := values[0].(string)
+	data, _ := values[1].(string)
+	attempts, _ := values[2].(int64)
+
+	// An item that cannot be decrypted is left for the visibility timeout,
+	// so that a client with the key can take it, until its attempts are
+	// exhausted
+	plaintext, err := q.open(ctx, data)
+	if err != nil {
+		err = fmt.Errorf("failed to decrypt queued item %s: %w", id, err)
+		if int(attempts) < q.config.MaxAttempts {
+			return true, err
+		}
+		return true, q.buryUnreadable(id, data, int(attempts), err)
+	}
+	var item redisItem
+	if err := json.Unmarshal(plaintext, &item); err != nil {
+		return true, q.buryUnreadable(id, data, int(attempts), fmt.Errorf("invalid queued item %s: %w", id, err))
+	}
+	if item.Message == nil {
+		return true, q.buryUnreadable(id, data, int(attempts), fmt.Errorf("queued item %s has no message", id))
+	}
+
+	q.mu.Lock()
+	local := q.local[id]
+	q.stats.Processing++
+	processor := q.config.Processor
+	q.mu.Unlock()
+	if local != nil && local.processor != nil {
+		processor = local.processor
+	}
+
+	// The send runs to its end when the queue stops, bounded by the
+	// timeout of the configuration only
+	var result Result
+	if processor == nil {
+		result.Error = fmt.Errorf("no processor function available for queue item %s", id)
+	} else {
+		processCtx := context.WithoutCancel(ctx)
+		if q.config.Timeout > 0 {
+			var cancel context.CancelFunc
+			processCtx, cancel = context.WithTimeout(processCtx, q.config.Timeout)
+			defer cancel()
+		}
+		release := q.hold(id)
+		result = processor(processCtx, item.Message, item.Targets)
+		release()
+	}
+
+	q.mu.Lock()
+	q.stats.Processing--
+	q.mu.Unlock()
+
+	cause := result.Error
+	if cause == nil && len(result.Failed) > 0 {
+		cause = failedTargetsError(result)
+	}
+	if len(result.Failed) > 0 {
+		item.Targets = result.Failed
+	}
+	switch {
+	case cause == nil:
+		if _, err := ackScript.Run(context.Background(), q.pool, q.keys, id); err != nil {
+			return true, fmt.Errorf("failed to acknowledge %s: %w", id, err)
+		}
+		q.complete(id, result, &q.stats.Completed)
+	case int(attempts) < q.config.MaxAttempts:
+		delay := q.backoff(int(attempts))
+		q.logger.Warn("Queued message failed, retrying", "message_id", item.Message.ID, "attempt", attempts, "targets", len(item.Targets), "retry_in", delay, "error", cause)
+		data, err := q.encode(context.Background(), item)
+		if err != nil {
+			return true, fmt.Errorf("failed to encode %s: %w", id, err)
+		}
+		if _, err := retryScript.Run(context.Background(), q.pool, q.keys, id, strconv.FormatInt(delay.Milliseconds(), 10), data); err != nil {
+			return true, fmt.Errorf("failed to retry %s: %w", id, err)
+		}
+	default:
+		letter := DeadLetter{ID: id, Message: item.Message, Targets: item.Targets, Created: item.Created, Attempts: int(attempts), Error: cause.Error()}
+		if err := q.bury(letter); err != nil {
+			return true, err
+		}
+		result.Error = cause
+		q.complete(id, result, &q.stats.Failed)
+	}
+	return true, nil
+}
+
+// failedTargetsError describes the targets a message failed for
+func failedTargetsError(result Result) error {
+	msg := fmt.Sprintf("failed for %d targets", len(result.Failed))
+	if result.Receipt != nil {
+		if errs := result.Receipt.GetErrors(); len(errs) > 0 {
+			msg += ": " + errs[0]
+		}
+	}
+	return errors.New(msg)
+}
+
+// hold extends the hold on an item while it is processed, so that it is
+// not delivered again in the meantime, until the returned function is
+// called
+func (q *RedisQueue) hold(id string) (release func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		timeout := strconv.FormatInt(q.config.VisibilityTimeout.Milliseconds(), 10)
+		ticker := time.NewTicker(max(q.config.VisibilityTimeout/3, time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), q.timeout())
+			_, err := extendScript.Run(ctx, q.pool, q.keys, id, timeout)
+			cancel()
+			if err != nil {
+				q.logger.Warn("Failed to extend the hold on a queued message", "item_id", id, "error", err)
+			}
+		}
+	}()
+	// The hold is no longer extended once release returns, so that it does
+	// not override a retry delay
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// bury moves an item to the dead letters
+func (q *RedisQueue) bury(letter DeadLetter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout())
+	defer cancel()
+	letter.FailedAt = time.Now()
+	data, err := q.encode(ctx, letter)
+	if err != nil {
+		return err
+	}
+	if _, err := deadScript.Run(ctx, q.pool, q.keys, letter.ID, data); err != nil {
+		return fmt.Errorf("failed to move %s to the dead letters: %w", letter.ID, err)
+	}
+	q.logger.Error("Queued message moved to the dead letters", "item_id", letter.ID, "attempts", letter.Attempts, "error", letter.Error)
+	return nil
+}
+
+// buryUnreadable moves an item that cannot be read to the dead letters as
+// stored, failing the handle of this queue's message
+func (q *RedisQueue) buryUnreadable(id, data string, attempts int, cause error) error {
+	if err := q.bury(DeadLetter{ID: id, Attempts: attempts, Error: cause.Error(), Data: data}); err != nil {
+		return err
+	}
+	q.complete(id, Result{Error: cause}, &q.stats.Failed)
+	return cause
+}
+
+// complete completes the handle of a message this queue enqueued and
+// counts it
+func (q *RedisQueue) complete(id string, result Result, counter *int64) {
+	q.mu.Lock()
+	local := q.local[id]
+	delete(q.local, id)
+	*counter++
+	if local != nil && q.stats.Pending > 0 {
+		q.stats.Pending--
+	}
+	q.mu.Unlock()
+	if local != nil {
+		local.handle.SetResultWithCallback(result, local.msg)
+	}
+}
+
+// backoff returns the delay before the retry following an attempt
+func (q *RedisQueue) backoff(attempt int) time.Duration {
+	policy := q.config.RetryPolicy
+	if policy.InitialInterval <= 0 {
+		return time.Second
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := time.Duration(float64(policy.InitialInterval) * math.Pow(multiplier, float64(attempt-1)))
+	if policy.MaxInterval > 0 && delay > policy.MaxInterval {
+		delay = policy.MaxInterval
+	}
+	return delay
+}
+
+// Drain removes the messages waiting in the queue, completing the handles
+// of those this queue enqueued with ErrDrained, and returns how many it
+// removed. Messages that workers hold are not affected.
+func (q *RedisQueue) Drain() int {
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout())
+	defer cancel()
+	reply, err := drainScript.Run(ctx, q.pool, q.keys)
+	if err != nil {
+		q.logger.Error("Failed to drain redis queue", "error", err)
+		return 0
+	}
+	ids, _ := reply.([]interface{})
+	for _, id := range ids {
+		id, _ := id.(string)
+		q.mu.Lock()
+		local := q.local[id]
+		delete(q.local, id)
+		if local != nil && q.stats.Pending > 0 {
+			q.stats.Pending--
+		}
+		q.mu.Unlock()
+		if local != nil {
+			local.handle.SetResultWithCallback(Result{Error: ErrDrained}, local.msg)
+		}
+	}
+	return len(ids)
+}
+
+// Len returns the number of messages waiting in the queue, including those
+// of other clients sharing it
+func (q *RedisQueue) Len() int {
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout())
+	defer cancel()
+	n, err := q.pool.Do(ctx, "LLEN", q.keys[0])
+	if err != nil {
+		q.logger.Warn("Failed to count redis queue", "error", err)
+		return 0
+	}
+	count, _ := n.(int64)
+	return int(count)
+}
+
+// DeadLetters returns the messages moved to the dead letters, the latest
+// first
+func (q *RedisQueue) DeadLetters(ctx context.Context) ([]DeadLetter, error) {
+	reply, err := q.pool.Do(ctx, "LRANGE", q.keys[4], "0", "-1")
+	if err != nil {
+		return nil, err
+	}
+	values, _ := reply.([]interface{})
+	letters := make([]DeadLetter, 0, len(values))
+	for _, value := range values {
+		data, _ := value.(string)
+		var letter DeadLetter
+		if err := q.decode(ctx, data, &letter); err != nil {
+			return nil, fmt.Errorf("invalid dead letter: %w", err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// RequeueDeadLetters enqueues the dead letters again, with their attempts
+// reset, and returns how many it enqueued. Letters that could not be read
+// are enqueued as they were stored.
+func (q *RedisQueue) RequeueDeadLetters(ctx context.Context) (int, error) {
+	var requeued int
+	for {
+		reply, err := q.pool.Do(ctx, "RPOP", q.keys[4])
+		if err != nil {
+			return requeued, err
+		}
+		data, ok := reply.(string)
+		if !ok {
+			return requeued, nil
+		}
+		var letter DeadLetter
+		if err := q.decode(ctx, data, &letter); err != nil {
+			// Put it back, so that a letter is not lost to a wrong key
+			if _, pushErr := q.pool.Do(ctx, "RPUSH", q.keys[4], data); pushErr != nil {
+				q.logger.Error("Failed to keep dead letter", "error", pushErr)
+			}
+			return requeued, fmt.Errorf("invalid dead letter: %w", err)
+		}
+		item := letter.Data
+		if letter.Message != nil || item == "" {
+			if item, err = q.encode(ctx, redisItem{ID: letter.ID, Message: letter.Message, Targets: letter.Targets, Created: letter.Created}); err != nil {
+				return requeued, err
+			}
+		}
+		if _, err := enqueueScript.Run(ctx, q.pool, q.keys, letter.ID, item); err != nil {
+			return requeued, err
+		}
+		requeued++
+	}
+}
+
+// encode serializes an item or a dead letter as stored in Redis, sealed
+// with the cipher when there is one
+func (q *RedisQueue) encode(ctx context.Context, v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	if q.config.Cipher != nil {
+		if data, err = q.config.Cipher.Seal(ctx, data); err != nil {
+			return "", err
+		}
+	}
+	return string(data), nil
+}
+
+// open returns the serialized form of an item or a dead letter stored in
+// Redis
+func (q *RedisQueue) open(ctx context.Context, data string) ([]byte, error) {
+	if q.config.Cipher != nil {
+		return q.config.Cipher.Open(ctx, []byte(data))
+	}
+	if secret.IsSealed([]byte(data)) {
+		return nil, secret.ErrPayloadEncrypted
+	}
+	return []byte(data), nil
+}
+
+// decode decodes an item or a dead letter stored in Redis
+func (q *RedisQueue) decode(ctx context.Context, data string, v interface{}) error {
+	plaintext, err := q.open(ctx, data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, v)
+}
+
+// IsHealthy checks that the Redis server answers
+func (q *RedisQueue) IsHealthy(ctx context.Context) error {
+	reply, err := q.pool.Do(ctx, "PING")
+	if err != nil {
+		return fmt.Errorf("redis queue: %w", err)
+	}
+	if reply != "PONG" {
+		return errors.New("redis queue: unexpected reply to PING")
+	}
+	return nil
+}
+
+// GetStats returns the statistics of the messages of this queue
+func (q *RedisQueue) GetStats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.UpdatedAt = time.Now()
+	stats.Workers = q.workers
+	return stats
+}
+
+// timeout bounds the commands made without a context
+func (q *RedisQueue) timeout() time.Duration {
+	if q.config.Redis.Timeout > 0 {
+		return q.config.Redis.Timeout
+	}
+	return 5 * time.Second
+}
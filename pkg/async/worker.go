@@ -67,7 +67,11 @@ func (w *Worker) Stop() {
 
 // processItem processes a single queue item
 func (w *Worker) processItem(ctx context.Context, item *QueueItem) {
-	w.logger.Debug("Processing item", "worker_id", w.id, "item_id", item.ID)
+	defer releaseItem(item)
+	debug := logger.Enabled(w.logger, logger.Debug)
+	if debug {
+		w.logger.Debug("Processing item", "worker_id", w.id, "item_id", item.ID)
+	}
 
 	var result Result
 
@@ -90,7 +94,9 @@ func (w *Worker) processItem(ctx context.Context, item *QueueItem) {
 		}
 	}
 
-	w.logger.Debug("Item processed", "worker_id", w.id, "item_id", item.ID)
+	if debug {
+		w.logger.Debug("Item processed", "worker_id", w.id, "item_id", item.ID)
+	}
 }
 
 // WorkerPool manages a pool of workers
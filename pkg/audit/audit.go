@@ -0,0 +1,124 @@
+// Package audit records security-relevant NotifyHub events, such as sends
+// blocked by target access rules, for later review, and exports them as
+// evidence packages for compliance audits.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Actions recorded by NotifyHub
+const (
+	ActionTargetBlocked     = "target_blocked"
+	ActionTargetQuarantined = "target_quarantined"
+	ActionMessageSent       = "message_sent"
+)
+
+// Entry is a single audit record
+type Entry struct {
+	Time      time.Time         `json:"time"`
+	Action    string            `json:"action"`
+	MessageID string            `json:"message_id,omitempty"`
+	Platform  string            `json:"platform,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+
+	// Status is the receipt status of a sent message
+	Status string `json:"status,omitempty"`
+
+	// ContentHash is the ContentHash of the title and body of a sent
+	// message, which proves its content even when Content is excluded
+	ContentHash string   `json:"content_hash,omitempty"`
+	Content     *Content `json:"content,omitempty"`
+}
+
+// Content is the content of a sent message
+type Content struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+// ContentHash returns the SHA-256 hash of the title and body of a message
+// as "sha256:<hex>"
+func ContentHash(title, body string) string {
+	// The JSON encoding separates title and body unambiguously
+	data, _ := json.Marshal([2]string{title, body})
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Recorder stores audit entries
+type Recorder interface {
+	Record(ctx context.Context, entry Entry) error
+}
+
+// RecorderFunc adapts a function to the Recorder interface
+type RecorderFunc func(ctx context.Context, entry Entry) error
+
+// Record calls f(ctx, entry)
+func (f RecorderFunc) Record(ctx context.Context, entry Entry) error {
+	return f(ctx, entry)
+}
+
+// MemoryRecorder keeps the most recent audit entries in memory
+type MemoryRecorder struct {
+	entries []Entry
+	max     int
+	mu      sync.RWMutex
+}
+
+// NewMemoryRecorder creates a recorder that keeps up to max entries.
+// A max of zero or less keeps every entry.
+func NewMemoryRecorder(max int) *MemoryRecorder {
+	return &MemoryRecorder{max: max}
+}
+
+// Record implements Recorder
+func (m *MemoryRecorder) Record(ctx context.Context, entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries = append(m.entries, entry)
+	if m.max > 0 && len(m.entries) > m.max {
+		m.entries = append([]Entry(nil), m.entries[len(m.entries)-m.max:]...)
+	}
+	return nil
+}
+
+// Entries returns the recorded entries, oldest first
+func (m *MemoryRecorder) Entries() []Entry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Entry(nil), m.entries...)
+}
+
+// WriterRecorder writes audit entries to an io.Writer as JSON lines
+type WriterRecorder struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewWriterRecorder creates a recorder writing JSON lines to w
+func NewWriterRecorder(w io.Writer) *WriterRecorder {
+	return &WriterRecorder{w: w}
+}
+
+// Record implements Recorder
+func (r *WriterRecorder) Record(ctx context.Context, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRecorder(t *testing.T) {
+	recorder := NewMemoryRecorder(2)
+	ctx := context.Background()
+	for _, id := range []string{"m1", "m2", "m3"} {
+		_ = recorder.Record(ctx, Entry{Action: ActionTargetBlocked, MessageID: id})
+	}
+
+	entries := recorder.Entries()
+	if len(entries) != 2 || entries[0].MessageID != "m2" || entries[1].MessageID != "m3" {
+		t.Errorf("Entries() = %+v, want the two most recent", entries)
+	}
+}
+
+func TestWriterRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewWriterRecorder(&buf)
+	entry := Entry{
+		Time:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Action:   ActionTargetBlocked,
+		Platform: "email",
+		Target:   "ops@gmail.com",
+		Reason:   "target matches no allow pattern for email",
+	}
+	if err := recorder.Record(context.Background(), entry); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	var got Entry
+	if err := json.Unmarshal(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), &got); err != nil {
+		t.Fatalf("recorded line is not JSON: %v", err)
+	}
+	if got.Action != entry.Action || got.Target != entry.Target || !got.Time.Equal(entry.Time) {
+		t.Errorf("recorded entry = %+v, want %+v", got, entry)
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	entry := Entry{Time: at, Action: ActionMessageSent, Metadata: map[string]string{"tenant": "acme", "user": "alice"}}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty", Filter{}, true},
+		{"within dates", Filter{From: at.Add(-time.Hour), To: at.Add(time.Hour)}, true},
+		{"from excludes earlier", Filter{From: at.Add(time.Second)}, false},
+		{"to is excluded", Filter{To: at}, false},
+		{"tenant", Filter{Tenant: "acme"}, true},
+		{"other tenant", Filter{Tenant: "globex"}, false},
+		{"sender", Filter{Sender: "alice"}, true},
+		{"other sender", Filter{Sender: "bob"}, false},
+		{"actions", Filter{Actions: []string{ActionTargetBlocked, ActionMessageSent}}, true},
+		{"other actions", Filter{Actions: []string{ActionTargetBlocked}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(entry); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sentEntry returns the audit entry of a message sent for a tenant
+func sentEntry(id, tenant string, at time.Time) Entry {
+	return Entry{
+		Time:        at,
+		Action:      ActionMessageSent,
+		MessageID:   id,
+		Metadata:    map[string]string{"tenant": tenant},
+		Status:      "success",
+		ContentHash: ContentHash("Disk full", "/var at 95%"),
+		Content:     &Content{Title: "Disk full", Body: "/var at 95%"},
+	}
+}
+
+func TestExport(t *testing.T) {
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		sentEntry("m2", "acme", day.Add(2*time.Hour)),
+		sentEntry("m1", "acme", day.Add(time.Hour)),
+		sentEntry("m3", "globex", day.Add(time.Hour)),
+	}
+
+	for _, exclude := range []bool{false, true} {
+		var buf bytes.Buffer
+		manifest, err := Export(&buf, entries, ExportOptions{Filter: Filter{Tenant: "acme"}, ExcludeContent: exclude})
+		if err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		if manifest.Entries != 2 || !manifest.FirstEntry.Equal(day.Add(time.Hour)) || manifest.ContentExcluded != exclude {
+			t.Errorf("manifest = %+v, want the two acme entries", manifest)
+		}
+
+		verified, err := Verify(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if verified.EntriesSHA256 != manifest.EntriesSHA256 || verified.Filter.Tenant != "acme" {
+			t.Errorf("Verify() manifest = %+v, want %+v", verified, manifest)
+		}
+		if got := bytes.Contains(buf.Bytes(), []byte("Disk full")); got {
+			t.Errorf("package holds uncompressed content")
+		}
+	}
+}
+
+func TestExport_ContentMismatch(t *testing.T) {
+	entry := sentEntry("m1", "acme", time.Now())
+	entry.Content.Body = "/var at 5%"
+	if _, err := Export(io.Discard, []Entry{entry}, ExportOptions{}); !errors.Is(err, ErrContentMismatch) {
+		t.Errorf("Export() error = %v, want ErrContentMismatch", err)
+	}
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := Export(&buf, []Entry{sentEntry("m1", "acme", time.Now())}, ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite the package with an altered entry
+	var tampered bytes.Buffer
+	zw := zip.NewWriter(&tampered)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if f.Name == EntriesFile {
+			data = bytes.Replace(data, []byte(`"status":"success"`), []byte(`"status":"failed"`), 1)
+		}
+		w, _ := zw.Create(f.Name)
+		_, _ = w.Write(data)
+	}
+	_ = zw.Close()
+
+	if _, err := Verify(bytes.NewReader(tampered.Bytes()), int64(tampered.Len())); err == nil || !strings.Contains(err.Error(), "do not match the manifest hash") {
+		t.Errorf("Verify() error = %v, want a hash mismatch", err)
+	}
+}
+
+func TestReadEntries(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewWriterRecorder(&buf)
+	_ = recorder.Record(context.Background(), sentEntry("m1", "acme", time.Now()))
+	_ = recorder.Record(context.Background(), sentEntry("m2", "globex", time.Now()))
+
+	entries, err := ReadEntries(&buf, Filter{Tenant: "globex"})
+	if err != nil || len(entries) != 1 || entries[0].MessageID != "m2" {
+		t.Errorf("ReadEntries() = %+v, %v, want m2", entries, err)
+	}
+	if _, err := ReadEntries(strings.NewReader("{}\nnot json\n"), Filter{}); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ReadEntries() error = %v, want the invalid line", err)
+	}
+}
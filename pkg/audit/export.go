@@ -0,0 +1,263 @@
+package audit
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// Files of an evidence package
+const (
+	EntriesFile  = "entries.jsonl"
+	ManifestFile = "manifest.json"
+)
+
+// manifestVersion is the format version of evidence packages
+const manifestVersion = 1
+
+// ErrContentMismatch is returned for entries whose message content does
+// not match its hash, as when the audit log was altered
+var ErrContentMismatch = errors.New("message content does not match its hash")
+
+// Filter selects audit entries; empty fields select every entry
+type Filter struct {
+	From    time.Time `json:"from,omitempty"`    // entries at or after
+	To      time.Time `json:"to,omitempty"`      // entries before
+	Tenant  string    `json:"tenant,omitempty"`  // entries of the tenant in their metadata
+	Sender  string    `json:"sender,omitempty"`  // entries of the user in their metadata, who sent the message
+	Actions []string  `json:"actions,omitempty"` // entries of the actions
+}
+
+// Match reports whether the filter selects an entry
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case !f.From.IsZero() && e.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.Time.Before(f.To):
+		return false
+	case f.Tenant != "" && e.Metadata[message.MetadataTenant] != f.Tenant:
+		return false
+	case f.Sender != "" && e.Metadata[message.MetadataUser] != f.Sender:
+		return false
+	}
+	if len(f.Actions) == 0 {
+		return true
+	}
+	for _, action := range f.Actions {
+		if e.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Source provides the recorded entries an export selects
+type Source interface {
+	Query(ctx context.Context, filter Filter) ([]Entry, error)
+}
+
+// Query implements Source
+func (m *MemoryRecorder) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var entries []Entry
+	for _, e := range m.entries {
+		if filter.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// FileSource reads the entries of an audit log written by a WriterRecorder
+type FileSource struct {
+	Path string
+}
+
+// Query implements Source
+func (s FileSource) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadEntries(f, filter)
+}
+
+// ReadEntries reads the entries a filter selects from JSON lines
+func ReadEntries(r io.Reader, filter Filter) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid audit entry on line %d: %w", line, err)
+		}
+		if filter.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// ExportOptions configures an evidence package
+type ExportOptions struct {
+	Filter Filter
+
+	// ExcludeContent leaves out the titles and bodies of messages, keeping
+	// their hashes, for audits that must not see personal data
+	ExcludeContent bool
+}
+
+// Manifest describes an evidence package
+type Manifest struct {
+	Version         int       `json:"version"`
+	GeneratedAt     time.Time `json:"generated_at"`
+	Filter          Filter    `json:"filter"`
+	ContentExcluded bool      `json:"content_excluded"`
+	Entries         int       `json:"entries"`
+	EntriesSHA256   string    `json:"entries_sha256"` // hash of the entries file
+	FirstEntry      time.Time `json:"first_entry,omitempty"`
+	LastEntry       time.Time `json:"last_entry,omitempty"`
+}
+
+// Export writes an evidence package of the entries a filter selects: a
+// zip archive of the entries as JSON lines, oldest first, and a manifest
+// holding the hash of the entries file. It fails with ErrContentMismatch
+// when the content of an entry does not match its hash.
+func Export(w io.Writer, entries []Entry, opts ExportOptions) (*Manifest, error) {
+	var selected []Entry
+	var mismatched []string
+	for _, e := range entries {
+		if !opts.Filter.Match(e) {
+			continue
+		}
+		if !contentMatches(e) {
+			mismatched = append(mismatched, e.MessageID)
+		}
+		if opts.ExcludeContent {
+			e.Content = nil
+		}
+		selected = append(selected, e)
+	}
+	if len(mismatched) > 0 {
+		return nil, fmt.Errorf("%w: messages %s", ErrContentMismatch, strings.Join(mismatched, ", "))
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].Time.Before(selected[j].Time) })
+
+	var lines bytes.Buffer
+	enc := json.NewEncoder(&lines)
+	for _, e := range selected {
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+	}
+	sum := sha256.Sum256(lines.Bytes())
+	manifest := &Manifest{
+		Version:         manifestVersion,
+		GeneratedAt:     time.Now().UTC(),
+		Filter:          opts.Filter,
+		ContentExcluded: opts.ExcludeContent,
+		Entries:         len(selected),
+		EntriesSHA256:   hex.EncodeToString(sum[:]),
+	}
+	if len(selected) > 0 {
+		manifest.FirstEntry, manifest.LastEntry = selected[0].Time, selected[len(selected)-1].Time
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{{EntriesFile, lines.Bytes()}, {ManifestFile, append(manifestJSON, '\n')}} {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: manifest.GeneratedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Verify checks an evidence package written by Export: the entries file
+// must match the hash and count of the manifest, and the content of each
+// entry its hash
+func Verify(r io.ReaderAt, size int64) (*Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid evidence package: %w", err)
+	}
+	files := make(map[string][]byte, 2)
+	for _, f := range zr.File {
+		if f.Name != EntriesFile && f.Name != ManifestFile {
+			return nil, fmt.Errorf("invalid evidence package: unexpected file %s", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("invalid evidence package: %w", err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid evidence package: %w", err)
+		}
+		files[f.Name] = data
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(files[ManifestFile], &manifest); err != nil {
+		return nil, fmt.Errorf("invalid evidence package manifest: %w", err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("invalid evidence package: unsupported version %d", manifest.Version)
+	}
+	sum := sha256.Sum256(files[EntriesFile])
+	if hex.EncodeToString(sum[:]) != manifest.EntriesSHA256 {
+		return &manifest, errors.New("evidence package entries do not match the manifest hash")
+	}
+	entries, err := ReadEntries(bytes.NewReader(files[EntriesFile]), Filter{})
+	if err != nil {
+		return &manifest, err
+	}
+	if len(entries) != manifest.Entries {
+		return &manifest, fmt.Errorf("evidence package holds %d entries, the manifest %d", len(entries), manifest.Entries)
+	}
+	for _, e := range entries {
+		if !contentMatches(e) {
+			return &manifest, fmt.Errorf("%w: message %s", ErrContentMismatch, e.MessageID)
+		}
+	}
+	return &manifest, nil
+}
+
+// contentMatches reports whether the content of an entry matches its
+// hash; entries without content or hash match
+func contentMatches(e Entry) bool {
+	return e.Content == nil || e.ContentHash == "" || ContentHash(e.Content.Title, e.Content.Body) == e.ContentHash
+}
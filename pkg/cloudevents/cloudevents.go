@@ -0,0 +1,190 @@
+// Package cloudevents provides the CloudEvents HTTP binding for NotifyHub:
+// events posted in binary or structured mode are read into an Event, which
+// the HTTP server (package server/http) maps to messages.
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Content types of the structured and batched modes
+const (
+	ContentTypeStructured = "application/cloudevents+json"
+	ContentTypeBatch      = "application/cloudevents-batch+json"
+)
+
+// SpecVersion is the supported version of the specification
+const SpecVersion = "1.0"
+
+// ErrBatchUnsupported is returned for events posted in batched mode
+var ErrBatchUnsupported = errors.New("batched cloudevents are not supported")
+
+// Event is a CloudEvent
+type Event struct {
+	ID              string            `json:"id"`
+	Source          string            `json:"source"`
+	SpecVersion     string            `json:"specversion"`
+	Type            string            `json:"type"`
+	Subject         string            `json:"subject,omitempty"`
+	Time            time.Time         `json:"time,omitempty"`
+	DataContentType string            `json:"datacontenttype,omitempty"`
+	DataSchema      string            `json:"dataschema,omitempty"`
+	Data            []byte            `json:"-"`
+	Extensions      map[string]string `json:"-"`
+}
+
+// attributes are the context attributes of the specification; others are
+// extensions
+var attributes = map[string]bool{
+	"id": true, "source": true, "specversion": true, "type": true, "subject": true,
+	"time": true, "datacontenttype": true, "dataschema": true, "data": true, "data_base64": true,
+}
+
+// ReadRequest reads the event of a request: in structured mode, with a
+// Content-Type of application/cloudevents+json, the body is the event;
+// otherwise, in binary mode, the attributes are ce- headers and the body
+// is the data
+func ReadRequest(r *http.Request) (*Event, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event: %w", err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case ContentTypeBatch:
+		return nil, ErrBatchUnsupported
+	case ContentTypeStructured:
+		return parseStructured(body)
+	}
+	return parseBinary(r.Header, body)
+}
+
+// parseStructured parses an event in structured mode
+func parseStructured(body []byte) (*Event, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("invalid event: %w", err)
+	}
+	var e Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("invalid event: %w", err)
+	}
+
+	if data, ok := fields["data_base64"]; ok {
+		var encoded string
+		if err := json.Unmarshal(data, &encoded); err != nil {
+			return nil, fmt.Errorf("invalid event data_base64: %w", err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event data_base64: %w", err)
+		}
+		e.Data = decoded
+	} else if data, ok := fields["data"]; ok {
+		// JSON data is kept as is, other data is a JSON string
+		var text string
+		if e.isJSON() || json.Unmarshal(data, &text) != nil {
+			e.Data = data
+		} else {
+			e.Data = []byte(text)
+		}
+	}
+
+	for name, value := range fields {
+		if attributes[name] {
+			continue
+		}
+		var text string
+		if json.Unmarshal(value, &text) != nil {
+			text = string(value)
+		}
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]string)
+		}
+		e.Extensions[name] = text
+	}
+	return &e, e.validate()
+}
+
+// parseBinary parses an event in binary mode
+func parseBinary(header http.Header, body []byte) (*Event, error) {
+	if header.Get("ce-specversion") == "" {
+		return nil, errors.New("invalid event: neither structured nor binary mode")
+	}
+	e := Event{
+		ID:              header.Get("ce-id"),
+		Source:          header.Get("ce-source"),
+		SpecVersion:     header.Get("ce-specversion"),
+		Type:            header.Get("ce-type"),
+		Subject:         header.Get("ce-subject"),
+		DataContentType: header.Get("Content-Type"),
+		DataSchema:      header.Get("ce-dataschema"),
+		Data:            body,
+	}
+	if value := header.Get("ce-time"); value != "" {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event time: %w", err)
+		}
+		e.Time = t
+	}
+	for key, values := range header {
+		name := strings.ToLower(key)
+		if !strings.HasPrefix(name, "ce-") || attributes[name[3:]] || len(values) == 0 {
+			continue
+		}
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]string)
+		}
+		e.Extensions[name[3:]] = values[0]
+	}
+	return &e, e.validate()
+}
+
+// validate checks the required attributes of an event
+func (e *Event) validate() error {
+	var missing []string
+	for _, attr := range [][2]string{{"id", e.ID}, {"source", e.Source}, {"type", e.Type}} {
+		if attr[1] == "" {
+			missing = append(missing, attr[0])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid event: missing %s", strings.Join(missing, ", "))
+	}
+	if e.SpecVersion != SpecVersion {
+		return fmt.Errorf("invalid event: unsupported specversion %q", e.SpecVersion)
+	}
+	return nil
+}
+
+// isJSON reports whether the data of the event is JSON, which it is
+// without a content type
+func (e *Event) isJSON() bool {
+	mediaType, _, _ := mime.ParseMediaType(e.DataContentType)
+	return e.DataContentType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// DecodedData returns the data of the event decoded from JSON, or as a
+// string when it is not JSON
+func (e *Event) DecodedData() interface{} {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	if e.isJSON() {
+		var data interface{}
+		if json.Unmarshal(e.Data, &data) == nil {
+			return data
+		}
+	}
+	return string(e.Data)
+}
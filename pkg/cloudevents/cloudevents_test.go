@@ -0,0 +1,140 @@
+package cloudevents
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		headers     map[string]string
+		body        string
+		want        *Event
+		err         string
+	}{
+		{
+			name:        "structured",
+			contentType: ContentTypeStructured,
+			body: `{"specversion": "1.0", "id": "1", "source": "/orders", "type": "com.example.order.created",
+				"subject": "order-7", "time": "2026-01-02T03:04:05Z", "datacontenttype": "application/json",
+				"data": {"total": 42}, "tenant": "acme"}`,
+			want: &Event{
+				ID: "1", Source: "/orders", SpecVersion: "1.0", Type: "com.example.order.created", Subject: "order-7",
+				Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), DataContentType: "application/json",
+				Data: []byte(`{"total": 42}`), Extensions: map[string]string{"tenant": "acme"},
+			},
+		},
+		{
+			name:        "structured text data",
+			contentType: ContentTypeStructured + "; charset=utf-8",
+			body:        `{"specversion": "1.0", "id": "1", "source": "/orders", "type": "t", "datacontenttype": "text/plain", "data": "hello"}`,
+			want:        &Event{ID: "1", Source: "/orders", SpecVersion: "1.0", Type: "t", DataContentType: "text/plain", Data: []byte("hello")},
+		},
+		{
+			name:        "structured base64 data",
+			contentType: ContentTypeStructured,
+			body:        `{"specversion": "1.0", "id": "1", "source": "/orders", "type": "t", "data_base64": "aGVsbG8="}`,
+			want:        &Event{ID: "1", Source: "/orders", SpecVersion: "1.0", Type: "t", Data: []byte("hello")},
+		},
+		{
+			name:        "binary",
+			contentType: "application/json",
+			headers: map[string]string{
+				"ce-specversion": "1.0", "ce-id": "1", "ce-source": "/orders", "ce-type": "t",
+				"ce-time": "2026-01-02T03:04:05Z", "ce-tenant": "acme",
+			},
+			body: `{"total": 42}`,
+			want: &Event{
+				ID: "1", Source: "/orders", SpecVersion: "1.0", Type: "t", Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				DataContentType: "application/json", Data: []byte(`{"total": 42}`), Extensions: map[string]string{"tenant": "acme"},
+			},
+		},
+		{name: "batch", contentType: ContentTypeBatch, body: `[]`, err: ErrBatchUnsupported.Error()},
+		{name: "neither mode", contentType: "application/json", body: `{}`, err: "neither structured nor binary mode"},
+		{name: "missing attributes", contentType: ContentTypeStructured, body: `{"specversion": "1.0", "type": "t"}`, err: "missing id, source"},
+		{name: "unsupported version", contentType: ContentTypeStructured, body: `{"specversion": "0.3", "id": "1", "source": "/", "type": "t"}`, err: "unsupported specversion"},
+		{name: "invalid time", contentType: "text/plain", headers: map[string]string{"ce-specversion": "1.0", "ce-time": "yesterday"}, err: "invalid event time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			got, err := ReadRequest(r)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ReadRequest() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`[]`))
+	r.Header.Set("Content-Type", ContentTypeBatch)
+	if _, err := ReadRequest(r); !errors.Is(err, ErrBatchUnsupported) {
+		t.Errorf("ReadRequest() error = %v, want ErrBatchUnsupported", err)
+	}
+}
+
+func TestEvent_DecodedData(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  interface{}
+	}{
+		{Event{}, nil},
+		{Event{Data: []byte(`{"total": 42}`)}, map[string]interface{}{"total": float64(42)}},
+		{Event{DataContentType: "application/vnd.order+json", Data: []byte(`[1]`)}, []interface{}{float64(1)}},
+		{Event{DataContentType: "text/plain", Data: []byte(`{"total": 42}`)}, `{"total": 42}`},
+		{Event{Data: []byte(`not json`)}, "not json"},
+	}
+	for _, tt := range tests {
+		if got := tt.event.DecodedData(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DecodedData(%q) = %#v, want %#v", tt.event.Data, got, tt.want)
+		}
+	}
+}
+
+func FuzzReadRequest(f *testing.F) {
+	f.Add(ContentTypeStructured, "", `{"specversion": "1.0", "id": "1", "source": "/orders", "type": "t", "data": {"total": 42}, "tenant": "acme"}`)
+	f.Add(ContentTypeStructured, "", `{"specversion": "1.0", "id": "1", "source": "/", "type": "t", "datacontenttype": "text/plain", "data": "hello"}`)
+	f.Add(ContentTypeStructured, "", `{"specversion": "1.0", "id": "1", "source": "/", "type": "t", "data_base64": "aGVsbG8="}`)
+	f.Add(ContentTypeStructured, "", `{"specversion": "1.0", "id": 1, "data_base64": 7, "time": "never"}`)
+	f.Add("application/json", "2026-01-02T03:04:05Z", `{"total": 42}`)
+	f.Add("text/plain; charset=\"", "yesterday", "not json")
+
+	f.Fuzz(func(t *testing.T, contentType, eventTime, body string) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("ce-specversion", SpecVersion)
+		r.Header.Set("ce-id", "1")
+		r.Header.Set("ce-source", "/fuzz")
+		r.Header.Set("ce-type", "t")
+		if eventTime != "" {
+			r.Header.Set("ce-time", eventTime)
+		}
+
+		event, err := ReadRequest(r)
+		if err != nil {
+			return
+		}
+		if event.ID == "" || event.Source == "" || event.Type == "" || event.SpecVersion != SpecVersion {
+			t.Errorf("ReadRequest() = %+v without its required attributes", event)
+		}
+		event.DecodedData()
+	})
+}
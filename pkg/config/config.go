@@ -5,7 +5,19 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/kart-io/notifyhub/pkg/archive"
+	"github.com/kart-io/notifyhub/pkg/audit"
 	"github.com/kart-io/notifyhub/pkg/config/platforms"
+	"github.com/kart-io/notifyhub/pkg/contact"
+	"github.com/kart-io/notifyhub/pkg/flags"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/preference"
+	"github.com/kart-io/notifyhub/pkg/quarantine"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+	"github.com/kart-io/notifyhub/pkg/redact"
+	"github.com/kart-io/notifyhub/pkg/secret"
+	"github.com/kart-io/notifyhub/pkg/suppression"
+	"github.com/kart-io/notifyhub/pkg/target"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
 )
 
@@ -13,28 +25,179 @@ import (
 type FeishuConfig = platforms.FeishuConfig
 type EmailConfig = platforms.EmailConfig
 type WebhookConfig = platforms.WebhookConfig
+type WebhookEndpoint = platforms.WebhookEndpoint
 type SlackConfig = platforms.SlackConfig
+type ProxyConfig = platforms.ProxyConfig
+type TLSConfig = platforms.TLSConfig
+type OAuth2Config = platforms.OAuth2Config
 
 // Config represents the unified configuration structure
 type Config struct {
-	// Core settings
+	// Timeout is the client timeout: it bounds deliveries when neither the
+	// message, the platform section nor the send defaults set a timeout,
+	// and resolving secret references
 	Timeout    time.Duration `json:"timeout"`
 	MaxRetries int           `json:"max_retries"`
 
+	// Profile is the configuration file profile the settings were loaded
+	// with, see LoadProfile
+	Profile string `json:"profile,omitempty"`
+
 	// Platform configurations (strongly typed)
 	Feishu  *FeishuConfig  `json:"feishu,omitempty"`
 	Email   *EmailConfig   `json:"email,omitempty"`
 	Webhook *WebhookConfig `json:"webhook,omitempty"`
 	Slack   *SlackConfig   `json:"slack,omitempty"`
 
+	// Proxy routes the HTTP requests of the platforms without a proxy of
+	// their own through an outbound proxy; email connects to its SMTP
+	// server directly
+	Proxy *ProxyConfig `json:"proxy,omitempty"`
+
+	// ExternalPlatforms names the platforms registered with the client at
+	// runtime, so targets and rules may refer to them
+	ExternalPlatforms []string `json:"external_platforms,omitempty"`
+
+	// Plugins are platforms whose senders run as separate programs, by
+	// platform name
+	Plugins map[string]Plugin `json:"plugins,omitempty"`
+
+	// Defaults are the send settings of messages that do not choose their own
+	Defaults SendDefaults `json:"defaults"`
+
+	// DryRun records every send as delivered without calling any platform,
+	// logging the messages instead, for staging environments that must
+	// never notify real people
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// DryRunPlatforms are platforms whose sends are recorded and logged as
+	// with DryRun while the other platforms deliver
+	DryRunPlatforms []string `json:"dry_run_platforms,omitempty"`
+
 	// Async configuration
 	Async AsyncConfig `json:"async"`
 
 	// Logger configuration
 	Logger LoggerConfig `json:"logger"`
 
+	// Redaction masks personal data and secrets in log entries, send
+	// errors and receipt errors
+	Redaction RedactionConfig `json:"redaction"`
+
+	// DefaultRegion is the ISO 3166 region (e.g. "CN") assumed for phone
+	// numbers without a country calling code
+	DefaultRegion string `json:"default_region,omitempty"`
+
+	// DeliveryWindow is the recipient-local time of day during which
+	// deferrable messages are delivered; outside it they are held
+	DeliveryWindow *preference.DeliveryWindow `json:"delivery_window,omitempty"`
+
+	// TargetAccess restricts the target values each platform may send to
+	// (email domains, phone country codes, webhook hosts)
+	TargetAccess []target.AccessRule `json:"target_access,omitempty"`
+
+	// AuditSends records an audit entry for every completed send to the
+	// audit recorder, with the hash of the message content and, unless
+	// AuditContent is false, the content itself, for compliance exports
+	// (see audit.Export)
+	AuditSends   bool  `json:"audit_sends,omitempty"`
+	AuditContent *bool `json:"audit_content,omitempty"` // defaults to on
+
+	// TargetRateLimits cap the sends to each recipient (e.g. 5 SMS per
+	// phone number per hour)
+	TargetRateLimits []ratelimit.Limit `json:"target_rate_limits,omitempty"`
+
+	// Quotas cap the sends of platforms to respect provider quotas (e.g.
+	// 300 Feishu messages per minute), across every client sharing the
+	// TokenBuckets
+	Quotas []ratelimit.Quota `json:"quotas,omitempty"`
+
+	// UsageQuotas cap the messages of tenants and apps per day or month
+	// (e.g. 500 SMS a month per tenant), rejecting sends over them with
+	// an ErrQuotaExceeded error before they are sent or queued
+	UsageQuotas []ratelimit.UsageQuota `json:"usage_quotas,omitempty"`
+
+	// UsageWarningTargets receive a warning when a tenant or app reaches
+	// the soft limit of a usage quota
+	UsageWarningTargets []target.Target `json:"usage_warning_targets,omitempty"`
+
+	// RateLimitState saves the state of the in-memory quotas and target
+	// rate limits to a file, so that a restart does not reset the budgets
+	// spent; token buckets shared in Redis need no saving
+	RateLimitState RateLimitStateConfig `json:"rate_limit_state"`
+
+	// Archive writes completed messages and their receipts to a directory
+	// or bucket on a schedule, for long-term retention outside the primary
+	// receipt store
+	Archive ArchiveConfig `json:"archive"`
+
+	// Retry retries the sends that failed with a retryable error with
+	// exponential backoff before their results are marked failed, in place
+	// of the max retries and retry interval of the send defaults
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
+	// PlatformRetry overrides Retry for platforms (platform -> policy)
+	PlatformRetry map[string]RetryPolicy `json:"platform_retry,omitempty"`
+
+	// MaxInFlight caps the concurrent sends of each platform (platform ->
+	// sends, e.g. 5 for email and 50 for feishu); sends beyond the cap wait
+	// for one to finish. Platforms not listed are not capped.
+	MaxInFlight map[string]int `json:"max_in_flight,omitempty"`
+
+	// QuarantineThreshold is the number of consecutive hard failures
+	// (bounces, unknown numbers, missing endpoints) after which a target is
+	// quarantined; zero uses quarantine.DefaultThreshold
+	QuarantineThreshold int `json:"quarantine_threshold,omitempty"`
+
+	// SecretRefresh is how often secret references in platform settings
+	// are resolved again to pick up rotated credentials; zero resolves them
+	// only when the client is created or reloaded
+	SecretRefresh time.Duration `json:"secret_refresh,omitempty"`
+
+	// Credentials are alternative credentials of the platform sections
+	// (platform -> credentials), switched to when the section's credentials
+	// are rejected or when their rotation window starts, see Credential
+	Credentials map[string][]Credential `json:"credentials,omitempty"`
+
+	// Regions are alternative endpoints of the platform sections in other
+	// regions (platform -> regions), with affinity by target and failover
+	// when a region fails, see Region
+	Regions        map[string][]Region  `json:"regions,omitempty"`
+	RegionFailover RegionFailoverConfig `json:"region_failover"`
+
+	// DedupeRecipients delivers once per person when several targets resolve
+	// to the same directory contact, on the contact's most preferred platform
+	DedupeRecipients bool `json:"dedupe_recipients,omitempty"`
+
+	// Groups defines named recipient groups (name -> member references)
+	Groups map[string][]string `json:"groups,omitempty"`
+
+	// Aliases defines stable target names (e.g. "ops-room") that are
+	// replaced with the configured targets at send time, so endpoints can
+	// be rotated in configuration without code changes
+	Aliases map[string][]target.Target `json:"aliases,omitempty"`
+
 	// Instance-level settings
-	LoggerInstance logger.Logger `json:"-"`
+	LoggerInstance   logger.Logger            `json:"-"`
+	ContactDirectory contact.Directory        `json:"-"`
+	OnCallResolver   contact.OnCallResolver   `json:"-"`
+	ListProvider     contact.ListProvider     `json:"-"`
+	Suppression      suppression.Store        `json:"-"`
+	Preferences      preference.Store         `json:"-"`
+	Digests          *preference.DigestBuffer `json:"-"`
+	RateLimiter      ratelimit.Limiter        `json:"-"`
+	TokenBuckets     ratelimit.TokenBuckets   `json:"-"`
+	UsageCounter     ratelimit.UsageCounter   `json:"-"`
+	Audit            audit.Recorder           `json:"-"`
+	ArchiveStore     archive.Store            `json:"-"`
+	Quarantine       quarantine.Store         `json:"-"`
+	Secrets          *secret.Resolver         `json:"-"`
+	Decryption       secret.KeyProvider       `json:"-"`
+	Flags            flags.Provider           `json:"-"`
+
+	sources     map[string]string // setting path -> layer that set it
+	envPrefixes []string          // FromEnv prefixes, applied after the file
+	overrides   []Option          // Override options, applied last
 }
 
 // AsyncConfig configures asynchronous processing
@@ -46,6 +209,70 @@ type AsyncConfig struct {
 	MinWorkers int           `json:"min_workers"` // Minimum worker count
 	MaxWorkers int           `json:"max_workers"` // Maximum worker count
 	UsePool    bool          `json:"use_pool"`    // Enable goroutine pool mode
+
+	Queue string            `json:"queue,omitempty"` // Backend of the pool queue: memory, the default, or redis, which keeps queued messages across restarts
+	Redis *RedisQueueConfig `json:"redis,omitempty"` // Server of the redis queue
+}
+
+// Backends of the async queue
+const (
+	QueueMemory = "memory" // lost when the process exits
+	QueueRedis  = "redis"  // kept in Redis and shared by the clients of a fleet
+)
+
+// RedisQueueConfig configures the Redis server of the async queue
+type RedisQueueConfig struct {
+	Addr     string `json:"addr"`               // host:port
+	Username string `json:"username,omitempty"` // for Redis ACL users; empty uses the default user
+	Password string `json:"password,omitempty"`
+	DB       int    `json:"db,omitempty"`
+
+	KeyPrefix         string        `json:"key_prefix,omitempty"`         // prefixes the keys of the queue; empty uses notifyhub:queue:
+	VisibilityTimeout time.Duration `json:"visibility_timeout,omitempty"` // how long a message is held before it is delivered again when its worker stopped; workers extend it while they send; zero uses 5m
+	MaxAttempts       int           `json:"max_attempts,omitempty"`       // attempts before a message is moved to the dead letters; zero uses 3
+
+	Encryption secret.KeyProvider `json:"-"` // encrypts the queued messages and dead letters with AES-256-GCM when set
+}
+
+// Format fallback behaviors for messages in a format the target platform
+// does not support
+const (
+	FormatFallbackNone   = "none"   // send the message unchanged
+	FormatFallbackText   = "text"   // send the message as plain text
+	FormatFallbackReject = "reject" // fail the send
+)
+
+// SendDefaults configures the client-wide defaults of sends. Messages
+// override them with their own priority and format, and with the timeout,
+// retry and platform order settings of their metadata (see
+// message.SetTimeout, SetMaxRetries and SetPlatformOrder).
+type SendDefaults struct {
+	// Priority replaces message.PriorityNormal, the priority messages are
+	// created with, so that it stands for the client's default
+	Priority *message.Priority `json:"priority,omitempty"`
+
+	// Format is the format of messages without one
+	Format message.Format `json:"format,omitempty"`
+
+	// FormatFallback is FormatFallbackNone (the default),
+	// FormatFallbackText or FormatFallbackReject
+	FormatFallback string `json:"format_fallback,omitempty"`
+
+	// Timeout bounds the delivery to each target on platforms whose
+	// section sets no timeout; zero falls back to the client timeout. A
+	// message's own timeout and the deadline of the caller's context take
+	// precedence over both.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// MaxRetries is the number of times a send that failed with a
+	// transient error is retried, RetryInterval apart, unless a retry
+	// policy is configured
+	MaxRetries    int           `json:"max_retries,omitempty"`
+	RetryInterval time.Duration `json:"retry_interval,omitempty"`
+
+	// Platforms is the order in which platforms are chosen for user and
+	// group targets that do not name one, e.g. ["slack", "email"]
+	Platforms []string `json:"platforms,omitempty"`
 }
 
 // LoggerConfig configures logging behavior
@@ -54,13 +281,107 @@ type LoggerConfig struct {
 	Format string `json:"format"`
 }
 
+// RateLimitStateConfig configures the saving of in-memory rate limit state
+type RateLimitStateConfig struct {
+	File     string        `json:"file,omitempty"`     // state file, restored when a client starts; empty keeps the state in memory only
+	Interval time.Duration `json:"interval,omitempty"` // how often the state is saved, besides on close; zero uses 1m
+}
+
+// ArchiveConfig configures the archiving of completed messages, see
+// package archive. Objects are written to Dir, to the S3 bucket, or to the
+// ArchiveStore instance, which takes precedence over both.
+type ArchiveConfig struct {
+	Dir               string           `json:"dir,omitempty"`                 // local directory of the archive
+	S3                *ArchiveS3Config `json:"s3,omitempty"`                  // S3 or S3-compatible bucket of the archive, e.g. GCS with HMAC keys
+	Prefix            string           `json:"prefix,omitempty"`              // prepended to object keys
+	Interval          time.Duration    `json:"interval,omitempty"`            // how often buffered messages are written, besides on close; zero uses 1h
+	MaxRecords        int              `json:"max_records,omitempty"`         // buffered messages written before the interval passes; zero uses 10000
+	PartitionByTenant bool             `json:"partition_by_tenant,omitempty"` // partition objects by tenant below the date
+}
+
+// ArchiveS3Config configures an S3 or S3-compatible archive bucket
+type ArchiveS3Config struct {
+	Endpoint        string `json:"endpoint,omitempty"` // e.g. https://storage.googleapis.com; derived from the region when empty
+	Region          string `json:"region"`             // auto for GCS
+	Bucket          string `json:"bucket"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token,omitempty"` // for temporary credentials
+}
+
+// Store returns the store of the configured directory or bucket, or nil
+// when the archive is not configured
+func (a ArchiveConfig) Store() archive.Store {
+	switch {
+	case a.S3 != nil:
+		return &archive.S3Store{
+			Endpoint:        a.S3.Endpoint,
+			Region:          a.S3.Region,
+			Bucket:          a.S3.Bucket,
+			AccessKeyID:     a.S3.AccessKeyID,
+			SecretAccessKey: a.S3.SecretAccessKey,
+			SessionToken:    a.S3.SessionToken,
+		}
+	case a.Dir != "":
+		return archive.DirStore{Dir: a.Dir}
+	}
+	return nil
+}
+
+// RedactionConfig configures the masking of email addresses, phone
+// numbers, webhook tokens and other secrets, see package redact
+type RedactionConfig struct {
+	Enabled  *bool    `json:"enabled,omitempty"`  // defaults to on unless logger.level is debug
+	Disable  []string `json:"disable,omitempty"`  // default rules not to apply: email, phone, url_token, webhook, auth, secret
+	Patterns []string `json:"patterns,omitempty"` // additional regular expressions whose matches are masked
+
+	// Targets is how receipts served by the APIs show target addresses:
+	// partial (the default, c***@gmail.com), full or none
+	Targets string `json:"targets,omitempty"`
+}
+
+// Redactor returns the redactor of the configuration, nil when redaction
+// is disabled. Redaction is on by default at the production log levels
+// (info, warn and error) and off at the debug level.
+func (c *Config) Redactor() (*redact.Redactor, error) {
+	enabled := c.Logger.Level != "debug"
+	if c.Redaction.Enabled != nil {
+		enabled = *c.Redaction.Enabled
+	}
+	if !enabled {
+		return nil, nil
+	}
+	return redact.FromConfig(redact.Config{Disable: c.Redaction.Disable, Patterns: c.Redaction.Patterns})
+}
+
+// TargetMasker returns the mask of target addresses in served receipts,
+// nil when they are shown unmasked, see redact.TargetMasker
+func (c *Config) TargetMasker() (func(string) string, error) {
+	return redact.TargetMasker(c.Redaction.Targets)
+}
+
 // Option defines a functional option for configuration
 type Option func(*Config) error
 
 // New creates a new configuration with the given options
 func New(opts ...Option) (*Config, error) {
-	// Start with defaults
-	cfg := &Config{
+	return defaultConfig().load(nil, opts)
+}
+
+// defaultConfig returns the configuration options and files start from.
+// Validate fills in the settings left unset by every layer:
+//
+//	timeout           30s
+//	max_retries       3 (when negative)
+//	async.workers     4
+//	logger.level      info
+//	logger.format     json
+//	webhook.method    POST
+//
+// Platform sections have no defaults for their connection settings; a zero
+// timeout or retry count means the platform's own default.
+func defaultConfig() *Config {
+	return &Config{
 		Timeout:    30 * time.Second,
 		MaxRetries: 3,
 		Async: AsyncConfig{
@@ -72,20 +393,49 @@ func New(opts ...Option) (*Config, error) {
 			Format: "json",
 		},
 	}
+}
 
-	// Apply options
-	for _, opt := range opts {
-		if err := opt(cfg); err != nil {
-			return nil, err
-		}
+// validate checks the send defaults
+func (d SendDefaults) validate(problems *ValidationErrors, known map[string]bool) {
+	if d.Priority != nil && (*d.Priority < message.PriorityLow || *d.Priority > message.PriorityUrgent) {
+		problems.add("defaults.priority", "INVALID_VALUE", fmt.Sprintf("priority must be between %d and %d, got %d", message.PriorityLow, message.PriorityUrgent, *d.Priority))
 	}
-
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		return nil, err
+	switch d.Format {
+	case "", message.FormatText, message.FormatMarkdown, message.FormatHTML:
+	default:
+		problems.add("defaults.format", "INVALID_VALUE", fmt.Sprintf("unknown message format %q", d.Format))
+	}
+	switch d.FormatFallback {
+	case "", FormatFallbackNone, FormatFallbackText, FormatFallbackReject:
+	default:
+		problems.add("defaults.format_fallback", "INVALID_VALUE", fmt.Sprintf("format fallback must be none, text or reject, got %q", d.FormatFallback))
 	}
+	if d.Timeout < 0 {
+		problems.add("defaults.timeout", "INVALID_VALUE", fmt.Sprintf("timeout cannot be negative, got %v", d.Timeout))
+	}
+	if d.MaxRetries < 0 {
+		problems.add("defaults.max_retries", "INVALID_VALUE", fmt.Sprintf("max retries cannot be negative, got %d", d.MaxRetries))
+	}
+	if d.RetryInterval < 0 {
+		problems.add("defaults.retry_interval", "INVALID_VALUE", fmt.Sprintf("retry interval cannot be negative, got %v", d.RetryInterval))
+	}
+	for i, name := range d.Platforms {
+		problems.checkPlatform(fmt.Sprintf("defaults.platforms[%d]", i), name, known)
+	}
+}
 
-	return cfg, nil
+// IsDryRun reports whether the sends of a platform are recorded without
+// calling it, see DryRun and DryRunPlatforms
+func (c *Config) IsDryRun(platform string) bool {
+	if c.DryRun {
+		return true
+	}
+	for _, name := range c.DryRunPlatforms {
+		if name == platform {
+			return true
+		}
+	}
+	return false
 }
 
 // IsAsyncEnabled returns true if async processing is enabled
@@ -150,7 +500,15 @@ func (c *Config) HasSlack() bool {
 	return c.Slack != nil
 }
 
-// Validate validates the configuration
+// HasGroups returns true if group, on-call or dynamic list expansion is configured
+func (c *Config) HasGroups() bool {
+	return len(c.Groups) > 0 || c.ContactDirectory != nil || c.OnCallResolver != nil || c.ListProvider != nil
+}
+
+// Validate validates the configuration and fills in defaults. It checks
+// every setting rather than stopping at the first problem; the error is a
+// ValidationErrors listing each problem with the path of its field, e.g.
+// "email.port" or "target_rate_limits[1].platform".
 func (c *Config) Validate() error {
 	// Validate timeout
 	if c.Timeout <= 0 {
@@ -175,29 +533,187 @@ func (c *Config) Validate() error {
 		c.Logger.Format = "json"
 	}
 
-	// Validate platform configurations
-	if c.Feishu != nil {
-		if err := c.Feishu.Validate(); err != nil {
-			return fmt.Errorf("feishu configuration validation failed: %w", err)
+	var problems ValidationErrors
+	known := c.knownPlatforms()
+
+	switch c.Async.Queue {
+	case "", QueueMemory:
+	case QueueRedis:
+		if c.Async.Redis == nil || c.Async.Redis.Addr == "" {
+			problems.add("async.redis.addr", "MISSING_VALUE", "the redis queue needs the address of its server")
+		}
+	default:
+		problems.add("async.queue", "INVALID_VALUE", fmt.Sprintf("queue must be memory or redis, got %q", c.Async.Queue))
+	}
+	if r := c.Async.Redis; r != nil && (r.VisibilityTimeout < 0 || r.MaxAttempts < 0) {
+		problems.add("async.redis", "INVALID_VALUE", "visibility_timeout and max_attempts cannot be negative")
+	}
+
+	if c.RateLimitState.Interval < 0 {
+		problems.add("rate_limit_state.interval", "INVALID_VALUE", fmt.Sprintf("interval cannot be negative, got %s", c.RateLimitState.Interval))
+	}
+
+	if c.Archive.Dir != "" && c.Archive.S3 != nil {
+		problems.add("archive", "CONFLICT", "only one of dir and s3 can be set")
+	}
+	if s3 := c.Archive.S3; s3 != nil {
+		for _, field := range [][2]string{{"region", s3.Region}, {"bucket", s3.Bucket}, {"access_key_id", s3.AccessKeyID}, {"secret_access_key", s3.SecretAccessKey}} {
+			if field[1] == "" {
+				problems.add("archive.s3."+field[0], "MISSING_VALUE", field[0]+" cannot be empty")
+			}
 		}
 	}
+	if c.Archive.Interval < 0 {
+		problems.add("archive.interval", "INVALID_VALUE", fmt.Sprintf("interval cannot be negative, got %s", c.Archive.Interval))
+	}
+	if c.Archive.MaxRecords < 0 {
+		problems.add("archive.max_records", "INVALID_VALUE", fmt.Sprintf("max_records cannot be negative, got %d", c.Archive.MaxRecords))
+	}
 
-	if c.Email != nil {
-		if err := c.Email.Validate(); err != nil {
-			return fmt.Errorf("email configuration validation failed: %w", err)
+	if _, err := c.Redactor(); err != nil {
+		problems.add("redaction", "INVALID_VALUE", err.Error())
+	}
+	if _, err := c.TargetMasker(); err != nil {
+		problems.add("redaction.targets", "INVALID_VALUE", err.Error())
+	}
+
+	if c.DeliveryWindow != nil {
+		if err := c.DeliveryWindow.Validate(); err != nil {
+			problems.add("delivery_window", "INVALID_VALUE", err.Error())
 		}
 	}
 
-	if c.Webhook != nil {
-		if err := c.Webhook.Validate(); err != nil {
-			return fmt.Errorf("webhook configuration validation failed: %w", err)
+	for i, rule := range c.TargetAccess {
+		field := fmt.Sprintf("target_access[%d]", i)
+		if err := rule.Validate(); err != nil {
+			problems.add(field, "INVALID_VALUE", err.Error())
+		}
+		problems.checkPlatform(field+".platform", rule.Platform, known)
+	}
+
+	if c.QuarantineThreshold < 0 {
+		problems.add("quarantine_threshold", "INVALID_VALUE", fmt.Sprintf("quarantine threshold cannot be negative, got %d", c.QuarantineThreshold))
+	}
+
+	c.Defaults.validate(&problems, known)
+
+	for i, name := range c.DryRunPlatforms {
+		problems.checkPlatform(fmt.Sprintf("dry_run_platforms[%d]", i), name, known)
+	}
+
+	for i, name := range c.ExternalPlatforms {
+		if name == "" {
+			problems.add(fmt.Sprintf("external_platforms[%d]", i), "MISSING_VALUE", "platform name cannot be empty")
 		}
 	}
 
+	c.validatePlugins(&problems)
+
+	if c.SecretRefresh < 0 {
+		problems.add("secret_refresh", "INVALID_VALUE", fmt.Sprintf("secret refresh interval cannot be negative, got %v", c.SecretRefresh))
+	}
+
+	c.validateCredentials(&problems)
+	c.validateRegions(&problems)
+	c.validateRetry(&problems, known)
+
+	for i, limit := range c.TargetRateLimits {
+		field := fmt.Sprintf("target_rate_limits[%d]", i)
+		if err := limit.Validate(); err != nil {
+			problems.add(field, "INVALID_VALUE", err.Error())
+		}
+		problems.checkPlatform(field+".platform", limit.Platform, known)
+	}
+
+	for i, quota := range c.Quotas {
+		field := fmt.Sprintf("quotas[%d]", i)
+		if err := quota.Validate(); err != nil {
+			problems.add(field, "INVALID_VALUE", err.Error())
+		}
+		problems.checkPlatform(field+".platform", quota.Platform, known)
+	}
+
+	for i, quota := range c.UsageQuotas {
+		field := fmt.Sprintf("usage_quotas[%d]", i)
+		if err := quota.Validate(); err != nil {
+			problems.add(field, "INVALID_VALUE", err.Error())
+		}
+		problems.checkPlatform(field+".platform", quota.Platform, known)
+	}
+
+	for _, name := range sortedKeys(c.MaxInFlight) {
+		field := "max_in_flight." + name
+		if name == "" {
+			problems.add("max_in_flight", "MISSING_VALUE", "platform name cannot be empty")
+			continue
+		}
+		if max := c.MaxInFlight[name]; max <= 0 {
+			problems.add(field, "INVALID_VALUE", fmt.Sprintf("in-flight limit must be positive, got %d", max))
+		}
+		problems.checkPlatform(field, name, known)
+	}
+
+	if c.Proxy != nil {
+		problems.addPlatform("proxy", c.Proxy.Validate())
+	}
+
+	// Validate platform configurations
+	if c.Feishu != nil {
+		problems.addPlatform("feishu", c.Feishu.Validate())
+	}
+	if c.Email != nil {
+		problems.addPlatform("email", c.Email.Validate())
+	}
+	if c.Webhook != nil {
+		problems.addPlatform("webhook", c.Webhook.Validate())
+	}
 	if c.Slack != nil {
-		if err := c.Slack.Validate(); err != nil {
-			return fmt.Errorf("slack configuration validation failed: %w", err)
+		problems.addPlatform("slack", c.Slack.Validate())
+	}
+
+	// Validate group definitions
+	for _, name := range sortedKeys(c.Groups) {
+		if name == "" {
+			problems.add("groups", "MISSING_VALUE", "group name cannot be empty")
+		} else if len(c.Groups[name]) == 0 {
+			problems.add("groups."+name, "MISSING_VALUE", fmt.Sprintf("group %s must have at least one member", name))
+		}
+	}
+
+	// Validate target aliases
+	validator := target.NewValidator(c.DefaultRegion)
+	for _, name := range sortedKeys(c.Aliases) {
+		targets := c.Aliases[name]
+		if name == "" {
+			problems.add("aliases", "MISSING_VALUE", "alias name cannot be empty")
+			continue
 		}
+		if len(targets) == 0 {
+			problems.add("aliases."+name, "MISSING_VALUE", fmt.Sprintf("alias %s must have at least one target", name))
+		}
+		for i, t := range targets {
+			field := fmt.Sprintf("aliases.%s[%d]", name, i)
+			if t.Type == target.TargetTypeAlias {
+				problems.add(field, "CONFLICT", fmt.Sprintf("alias %s cannot reference alias %s", name, t.Value))
+				continue
+			}
+			if err := validator.Validate(t); err != nil {
+				problems.add(field, "INVALID_VALUE", fmt.Sprintf("alias %s has an invalid target: %v", name, err))
+			}
+			problems.checkPlatform(field+".platform", t.Platform, known)
+		}
+	}
+
+	for i, t := range c.UsageWarningTargets {
+		field := fmt.Sprintf("usage_warning_targets[%d]", i)
+		if err := validator.Validate(t); err != nil {
+			problems.add(field, "INVALID_VALUE", fmt.Sprintf("invalid usage warning target: %v", err))
+		}
+		problems.checkPlatform(field+".platform", t.Platform, known)
+	}
+
+	if len(problems) > 0 {
+		return problems
 	}
 
 	// Ensure logger instance is set
@@ -0,0 +1,167 @@
+// Package config provides alternative platform credentials for rotation
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// PrimaryCredential names the credentials of a platform section itself
+const PrimaryCredential = "primary"
+
+// Credential is an alternative set of credentials for a platform section.
+// Its settings replace the section's settings of the same name, e.g.
+// {"password": "..."} for email or {"token": "..."} for webhook, and may
+// hold secret references or encrypted values like the section.
+//
+// A platform sends with its preferred credentials: those whose rotation
+// window started last, or, among credentials without a window, the first
+// in order with the section's own credentials first. When the platform
+// rejects the preferred credentials the send is retried with the next ones,
+// which stay in use until they are rejected too. Typical setups are a
+// standby key without a window, and a new key whose window starts when the
+// old one is revoked, with the old one left in place as the fallback.
+type Credential struct {
+	Name     string            `json:"name"`
+	Settings map[string]string `json:"settings"`
+
+	// ActiveFrom and ActiveUntil bound the rotation window of the
+	// credentials; zero values leave the window open on that side
+	ActiveFrom  time.Time `json:"active_from,omitempty"`
+	ActiveUntil time.Time `json:"active_until,omitempty"`
+}
+
+// Active reports whether the credentials may be used at a time
+func (c Credential) Active(now time.Time) bool {
+	return !now.Before(c.ActiveFrom) && (c.ActiveUntil.IsZero() || now.Before(c.ActiveUntil))
+}
+
+// Apply returns a copy of a platform section, such as *EmailConfig, with
+// the settings of the credentials
+func (c Credential) Apply(section interface{}) (interface{}, error) {
+	applied, err := applySettings(section, c.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("credentials %s: %w", c.Name, err)
+	}
+	return applied, nil
+}
+
+// applySettings returns a copy of a platform section with text settings
+// replaced by name
+func applySettings(section interface{}, settings map[string]string) (interface{}, error) {
+	v := reflect.ValueOf(section)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a platform section, got %T", section)
+	}
+	copied := reflect.New(v.Elem().Type())
+	copied.Elem().Set(v.Elem())
+
+	fields := credentialFields(v.Elem().Type())
+	for name, value := range settings {
+		index, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q", name)
+		}
+		copied.Elem().Field(index).SetString(value)
+	}
+	return copied.Interface(), nil
+}
+
+// credentialFields returns the indexes of the string settings of a section
+// type by JSON name
+func credentialFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.IsExported() && name != "" && name != "-" && field.Type.Kind() == reflect.String {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// platformSection returns the configured section of a built-in platform,
+// or nil
+func (c *Config) platformSection(platform string) interface{} {
+	switch {
+	case platform == "feishu" && c.Feishu != nil:
+		return c.Feishu
+	case platform == "email" && c.Email != nil:
+		return c.Email
+	case platform == "webhook" && c.Webhook != nil:
+		return c.Webhook
+	case platform == "slack" && c.Slack != nil:
+		return c.Slack
+	}
+	return nil
+}
+
+// validateCredentials checks the alternative credentials of each platform
+func (c *Config) validateCredentials(problems *ValidationErrors) {
+	for _, platform := range sortedKeys(c.Credentials) {
+		field := "credentials." + platform
+		section := c.platformSection(platform)
+		if section == nil {
+			problems.add(field, "MISSING_VALUE", fmt.Sprintf("credentials require a configured %s section", platform))
+			continue
+		}
+
+		fields := credentialFields(reflect.TypeOf(section).Elem())
+		seen := map[string]bool{PrimaryCredential: true}
+		for i, cred := range c.Credentials[platform] {
+			field := fmt.Sprintf("%s[%d]", field, i)
+			switch {
+			case cred.Name == "":
+				problems.add(field+".name", "MISSING_VALUE", "credential name cannot be empty")
+			case seen[cred.Name]:
+				problems.add(field+".name", "CONFLICT", fmt.Sprintf("credential name %q is already used", cred.Name))
+			}
+			seen[cred.Name] = true
+
+			if len(cred.Settings) == 0 {
+				problems.add(field+".settings", "MISSING_VALUE", "credentials must set at least one setting")
+			}
+			for _, name := range sortedKeys(cred.Settings) {
+				if _, ok := fields[name]; !ok {
+					problems.add(field+".settings."+name, "INVALID_VALUE", fmt.Sprintf("%s has no text setting %q", platform, name))
+				}
+			}
+			if !cred.ActiveUntil.IsZero() && !cred.ActiveUntil.After(cred.ActiveFrom) {
+				problems.add(field+".active_until", "INVALID_VALUE", "active_until must be after active_from")
+			}
+		}
+	}
+}
+
+// maskCredentials hides the settings of a list of credentials in an
+// effective configuration
+func maskCredentials(value interface{}) interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+	masked := make([]interface{}, len(list))
+	for i, item := range list {
+		cred, ok := item.(map[string]interface{})
+		if !ok {
+			masked[i] = item
+			continue
+		}
+		copied := make(map[string]interface{}, len(cred))
+		for key, v := range cred {
+			copied[key] = v
+		}
+		if settings, ok := cred["settings"].(map[string]interface{}); ok {
+			hidden := make(map[string]interface{}, len(settings))
+			for name := range settings {
+				hidden[name] = "******"
+			}
+			copied["settings"] = hidden
+		}
+		masked[i] = copied
+	}
+	return masked
+}
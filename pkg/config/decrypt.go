@@ -0,0 +1,99 @@
+// Package config provides decryption of encrypted configuration values
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/secret"
+)
+
+// decryptValues replaces the encrypted string settings of the
+// configuration with their plaintext. The key is only requested when an
+// encrypted value is present.
+func (c *Config) decryptValues() error {
+	var key []byte
+	decrypt := func(path, value string) (string, error) {
+		if c.Decryption == nil {
+			return "", fmt.Errorf("%s is encrypted but no decryption key is configured, see WithDecryptionKey", path)
+		}
+		if key == nil {
+			timeout := c.Timeout
+			if timeout <= 0 {
+				timeout = 30 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			var err error
+			if key, err = c.Decryption.Key(ctx); err != nil {
+				return "", fmt.Errorf("failed to get the configuration decryption key: %w", err)
+			}
+		}
+		plaintext, err := secret.Decrypt(key, path, value)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		return plaintext, nil
+	}
+	return decryptValue(reflect.ValueOf(c).Elem(), "", decrypt)
+}
+
+// decryptValue walks the settings of a configuration value, naming them by
+// their JSON paths such as "webhook.token" or "groups.sre[0]"
+func decryptValue(v reflect.Value, path string, decrypt func(path, value string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return decryptValue(v.Elem(), path, decrypt)
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" || name == "" {
+				continue
+			}
+			if err := decryptValue(v.Field(i), joinPath(path, name), decrypt); err != nil {
+				return err
+			}
+		}
+
+	case reflect.String:
+		if !secret.IsEncrypted(v.String()) || !v.CanSet() {
+			return nil
+		}
+		plaintext, err := decrypt(path, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(plaintext)
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := decryptValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), decrypt); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range v.MapKeys() {
+			// Map values are not addressable: decrypt a copy and store it
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := decryptValue(elem, joinPath(path, key.String()), decrypt); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	}
+	return nil
+}
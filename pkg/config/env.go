@@ -0,0 +1,158 @@
+// Package config provides environment variable configuration for NotifyHub
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FromEnv reads settings from environment variables named after the JSON
+// keys of Config, upper-cased and joined with underscores after the prefix:
+//
+//	NOTIFYHUB_TIMEOUT=10s
+//	NOTIFYHUB_ASYNC_WORKERS=8
+//	NOTIFYHUB_PLATFORMS_FEISHU_WEBHOOK_URL=https://open.feishu.cn/...
+//	NOTIFYHUB_EMAIL_PORT=587
+//	NOTIFYHUB_GROUPS_SRE=alice,bob
+//	NOTIFYHUB_TARGET_RATE_LIMITS=[{"platform":"sms","max":5,"per":"1h"}]
+//
+// The PLATFORMS_ segment before a platform name is optional. Lists of
+// plain values are comma-separated; lists of settings and whole sections
+// are given as JSON. Map keys, such as group names, are lower-cased.
+//
+// Variables override the settings of code options and configuration files
+// wherever the option is passed, and are overridden by Override options;
+// see SourceEnv. Empty variables and variables that match no setting are
+// ignored.
+func FromEnv(prefix string) Option {
+	return func(c *Config) error {
+		c.envPrefixes = append(c.envPrefixes, prefix)
+		return nil
+	}
+}
+
+// applyEnv applies the prefixed variables of an environment
+func (c *Config) applyEnv(prefix string, environ []string) error {
+	prefix = strings.ToUpper(strings.TrimSuffix(prefix, "_")) + "_"
+
+	doc := make(map[string]interface{})
+	for _, entry := range environ {
+		name, value, _ := strings.Cut(entry, "=")
+		if len(name) <= len(prefix) || !strings.EqualFold(name[:len(prefix)], prefix) || value == "" {
+			continue
+		}
+
+		tokens := strings.Split(strings.ToLower(name[len(prefix):]), "_")
+		if tokens[0] == "platforms" && len(tokens) > 1 {
+			tokens = tokens[1:]
+		}
+		keys, typ, ok := envPath(reflect.TypeOf(Config{}), tokens)
+		if !ok {
+			continue
+		}
+
+		leaf, err := envValue(value, typ)
+		if err != nil {
+			return fmt.Errorf("environment variable %s: %w", name, err)
+		}
+		setPath(doc, keys, leaf)
+	}
+	if len(doc) == 0 {
+		return nil
+	}
+
+	converted, err := coerce(doc, reflect.TypeOf(Config{}), "")
+	if err != nil {
+		return fmt.Errorf("environment configuration: %w", err)
+	}
+	encoded, err := json.Marshal(converted)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(encoded, c); err != nil {
+		return err
+	}
+	c.markSources(converted.(map[string]interface{}), SourceEnv)
+	return nil
+}
+
+// envPath maps the lower-cased words of a variable name to the JSON keys of
+// a setting and the setting's type
+func envPath(t reflect.Type, tokens []string) ([]string, reflect.Type, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return []string{strings.Join(tokens, "_")}, t.Elem(), true
+
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" || name == "" {
+				continue
+			}
+			words := strings.Split(name, "_")
+			if len(tokens) < len(words) || strings.Join(tokens[:len(words)], "_") != name {
+				continue
+			}
+			if len(tokens) == len(words) {
+				return []string{name}, field.Type, true
+			}
+			if keys, typ, ok := envPath(field.Type, tokens[len(words):]); ok {
+				return append([]string{name}, keys...), typ, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// envValue converts a variable value for a setting of type t. Sections and
+// lists of settings are JSON; other lists are comma-separated.
+func envValue(value string, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	structured := t.Kind() == reflect.Struct && t != timeType || t.Kind() == reflect.Map
+	if t.Kind() == reflect.Slice {
+		elem := t.Elem()
+		structured = elem.Kind() == reflect.Struct || elem.Kind() == reflect.Map || strings.HasPrefix(strings.TrimSpace(value), "[")
+	}
+	if structured {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(value), &doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON value: %w", err)
+		}
+		return doc, nil
+	}
+
+	if t.Kind() == reflect.Slice {
+		parts := strings.Split(value, ",")
+		list := make([]interface{}, 0, len(parts))
+		for _, part := range parts {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, plainScalar(part))
+			}
+		}
+		return list, nil
+	}
+	return plainScalar(value), nil
+}
+
+// setPath stores a value in a nested document
+func setPath(doc map[string]interface{}, keys []string, value interface{}) {
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := doc[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			doc[key] = child
+		}
+		doc = child
+	}
+	doc[keys[len(keys)-1]] = value
+}
@@ -0,0 +1,393 @@
+// Package config provides configuration file loading for NotifyHub
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Configuration file formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// LoadFile builds a configuration from a JSON, YAML or TOML file, chosen by
+// the file extension, and opts. Settings in the file override the ones
+// options set, see SourceFile for the precedence of layers. Settings that
+// cannot be expressed in a file, such as the logger or contact directory
+// instances, are supplied as options.
+//
+// Keys follow the JSON field names of Config ("max_retries", "async",
+// "feishu", "target_rate_limits", ...). Durations are written as strings
+// like "30s" or "5m". String values may reference environment variables as
+// ${NAME}, ${NAME:-default} or ${NAME:?message}; a variable that is unset
+// and has no default is an error. "$$" stands for a literal "$". Files may
+// define profiles for different environments, see LoadProfile.
+//
+//	email:
+//	  host: smtp.example.com
+//	  port: 587
+//	  password: ${SMTP_PASSWORD}
+//	  timeout: 10s
+func LoadFile(path string, opts ...Option) (*Config, error) {
+	return loadFile(path, "", opts)
+}
+
+// loadFile reads and parses a configuration file with a profile
+func loadFile(path, profile string, opts []Option) (*Config, error) {
+	format, err := formatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	cfg, err := parse(data, format, profile, opts)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse builds a configuration from file contents in the given format and
+// opts. See LoadFile for the file syntax.
+func Parse(data []byte, format string, opts ...Option) (*Config, error) {
+	return parse(data, format, "", opts)
+}
+
+// parse builds a configuration with a profile, see selectProfile
+func parse(data []byte, format, profile string, opts []Option) (*Config, error) {
+	var (
+		doc interface{}
+		err error
+	)
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &doc)
+	case FormatYAML:
+		doc, err = parseYAML(data)
+	case FormatTOML:
+		doc, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	if settings, ok := doc.(map[string]interface{}); ok {
+		if doc, err = selectProfile(settings, profile); err != nil {
+			return nil, err
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q is not defined", profile)
+	}
+
+	if doc, err = interpolate(doc); err != nil {
+		return nil, err
+	}
+	if doc, err = coerce(doc, reflect.TypeOf(Config{}), ""); err != nil {
+		return nil, err
+	}
+	file, _ := doc.(map[string]interface{})
+	return defaultConfig().load(file, opts)
+}
+
+// formatOf returns the configuration format for a file extension
+func formatOf(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	default:
+		return "", fmt.Errorf("unsupported config file extension %q: use .json, .yaml, .yml or .toml", filepath.Ext(path))
+	}
+}
+
+// interpolate expands environment variable references in every string of
+// a parsed document
+func interpolate(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			expanded, err := interpolate(value)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = expanded
+		}
+	case []interface{}:
+		for i, value := range v {
+			expanded, err := interpolate(value)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	case string:
+		return expandEnv(v)
+	case plainScalar:
+		expanded, err := expandEnv(string(v))
+		return plainScalar(expanded), err
+	}
+	return v, nil
+}
+
+// expandEnv replaces ${NAME}, ${NAME:-default} and ${NAME:?message}
+// references with environment variable values
+func expandEnv(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] != '$':
+			b.WriteByte(s[i])
+		case strings.HasPrefix(s[i:], "$$"):
+			b.WriteByte('$')
+			i++
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", s)
+			}
+			value, err := lookupEnv(s[i+2 : i+end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// lookupEnv resolves the body of a ${...} reference
+func lookupEnv(ref string) (string, error) {
+	name, fallback, hasDefault := strings.Cut(ref, ":-")
+	message := ""
+	if !hasDefault {
+		name, message, _ = strings.Cut(ref, ":?")
+	}
+	if name == "" {
+		return "", fmt.Errorf("empty variable reference ${%s}", ref)
+	}
+
+	if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+		return value, nil
+	}
+	if hasDefault {
+		return fallback, nil
+	}
+	if message != "" {
+		return "", fmt.Errorf("environment variable %s: %s", name, message)
+	}
+	return "", fmt.Errorf("environment variable %s is not set", name)
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// coerce converts a parsed document to the shape encoding/json expects for
+// type t: durations are parsed from strings, times are RFC 3339 (TOML
+// date-times may separate the date and time with a space), untyped YAML scalars and
+// interpolated values are converted to the field's kind, and unknown keys
+// are rejected so that typos do not go unnoticed.
+func coerce(v interface{}, t reflect.Type, path string) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	text, isText := v.(string)
+	if plain, ok := v.(plainScalar); ok {
+		text, isText = string(plain), true
+	}
+
+	if t == durationType {
+		if !isText {
+			return v, nil
+		}
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid duration %q", path, text)
+		}
+		return int64(d), nil
+	}
+
+	if t == timeType {
+		if !isText {
+			return nil, fmt.Errorf("%s: expected a time", path)
+		}
+		parsed, err := time.Parse(time.RFC3339, strings.Replace(text, " ", "T", 1))
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid time %q, expected RFC 3339 such as 2006-01-02T15:04:05Z", path, text)
+		}
+		return parsed.Format(time.RFC3339Nano), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a table of settings", displayPath(path))
+		}
+		out := make(map[string]interface{}, len(m))
+		for key, value := range m {
+			field, ok := fieldByName(t, key)
+			if !ok {
+				return nil, fmt.Errorf("unknown setting %s", joinPath(path, key))
+			}
+			converted, err := coerce(value, field.Type, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			out[key] = converted
+		}
+		return out, nil
+
+	case reflect.Slice, reflect.Array:
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a list", path)
+		}
+		out := make([]interface{}, len(list))
+		for i, value := range list {
+			converted, err := coerce(value, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = converted
+		}
+		return out, nil
+
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a table", path)
+		}
+		out := make(map[string]interface{}, len(m))
+		for key, value := range m {
+			converted, err := coerce(value, t.Elem(), joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			out[key] = converted
+		}
+		return out, nil
+
+	case reflect.Interface:
+		return resolveScalars(v), nil
+
+	case reflect.Bool:
+		if !isText {
+			return v, nil
+		}
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid boolean %q", path, text)
+		}
+		return b, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !isText {
+			return v, nil
+		}
+		n, err := strconv.ParseInt(strings.ReplaceAll(text, "_", ""), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid integer %q", path, text)
+		}
+		return n, nil
+
+	case reflect.Float32, reflect.Float64:
+		if !isText {
+			return v, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid number %q", path, text)
+		}
+		return f, nil
+
+	case reflect.String:
+		if isText {
+			return text, nil
+		}
+	}
+	return v, nil
+}
+
+// resolveScalars types untyped YAML scalars stored in free-form values
+func resolveScalars(v interface{}) interface{} {
+	switch v := v.(type) {
+	case plainScalar:
+		return v.resolve()
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = resolveScalars(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = resolveScalars(value)
+		}
+	}
+	return v
+}
+
+// fieldByName finds the struct field encoded under a JSON key
+func fieldByName(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "config"
+	}
+	return path
+}
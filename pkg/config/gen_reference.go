@@ -0,0 +1,109 @@
+//go:build ignore
+
+// gen_reference writes the reference of configuration settings to
+// docs/configuration.md, describing each setting with the doc comment of
+// its struct field. Run it with go generate ./pkg/config.
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+)
+
+// module is the import path of the repository root, two levels up
+const module = "github.com/kart-io/notifyhub/"
+
+func main() {
+	comments := make(map[string]map[string]string) // package path -> "Type.Field" -> comment
+	describe := func(owner reflect.Type, field reflect.StructField) string {
+		pkg := owner.PkgPath()
+		if _, ok := comments[pkg]; !ok {
+			comments[pkg] = fieldComments(filepath.Join("..", "..", strings.TrimPrefix(pkg, module)))
+		}
+		return comments[pkg][owner.Name()+"."+field.Name]
+	}
+
+	var b bytes.Buffer
+	if err := config.WriteReference(&b, config.Reference(describe), config.ReferenceMarkdown); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("../../docs/configuration.md", b.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// fieldComments returns the comments of the struct fields declared in a
+// package directory by "Type.Field"
+func fieldComments(dir string) map[string]string {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	comments := make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			ast.Inspect(file, func(node ast.Node) bool {
+				spec, ok := node.(*ast.TypeSpec)
+				if !ok {
+					return true
+				}
+				st, ok := spec.Type.(*ast.StructType)
+				if !ok {
+					return false
+				}
+				var previous string
+				for _, field := range st.Fields.List {
+					for _, name := range field.Names {
+						text := fieldComment(field, name.Name, previous)
+						comments[spec.Name.Name+"."+name.Name] = text
+						previous = text
+					}
+				}
+				return false
+			})
+		}
+	}
+	return comments
+}
+
+// fieldComment returns the comment of a struct field: its doc comment when
+// it starts with the field name, rather than heading a group of fields, or
+// its line comment. A field without either shares the doc comment of the
+// field before it when that names it, as in "Username and Password ...".
+func fieldComment(field *ast.Field, name, previous string) string {
+	doc := strings.Join(strings.Fields(field.Doc.Text()), " ")
+	if doc != "" && strings.HasPrefix(doc, field.Names[0].Name+" ") {
+		return doc
+	}
+	if line := strings.Join(strings.Fields(field.Comment.Text()), " "); line != "" {
+		return line
+	}
+	if doc == "" && previous != "" && mentions(previous, name) {
+		return previous
+	}
+	return ""
+}
+
+// mentions reports whether a comment names a field as a word
+func mentions(comment, name string) bool {
+	for _, word := range strings.FieldsFunc(comment, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if word == name {
+			return true
+		}
+	}
+	return false
+}
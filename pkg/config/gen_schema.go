@@ -0,0 +1,22 @@
+//go:build ignore
+
+// gen_schema writes the JSON schema of configuration files to
+// docs/notifyhub.schema.json. Run it with go generate ./pkg/config.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+)
+
+func main() {
+	schema, err := config.JSONSchema()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("../../docs/notifyhub.schema.json", append(schema, '\n'), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
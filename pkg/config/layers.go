@@ -0,0 +1,281 @@
+// Package config provides layered configuration sources for NotifyHub
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Sources of settings, in increasing precedence. A configuration is built
+// from these layers, each overriding the settings of the layers before it
+// regardless of the order in which options are passed:
+//
+//  1. SourceDefault: the built-in defaults (see defaultConfig), and the
+//     per-setting defaults Validate fills in for unset values
+//  2. SourceCode: options such as WithTimeout or WithFeishu
+//  3. SourceFile: the document read by LoadFile or Parse
+//  4. SourceEnv: environment variables read by FromEnv
+//  5. SourceOverride: options wrapped in Override
+//
+// Sections are layered setting by setting, so an environment variable can
+// change the port of an email section configured in code. Lists and other
+// values are replaced as a whole.
+const (
+	SourceDefault  = "default"
+	SourceCode     = "code"
+	SourceFile     = "file"
+	SourceEnv      = "env"
+	SourceOverride = "override"
+)
+
+// Override applies options after the file and environment layers, for
+// settings that must win over both, such as a timeout chosen by a command
+// line flag
+func Override(opts ...Option) Option {
+	return func(c *Config) error {
+		c.overrides = append(c.overrides, opts...)
+		return nil
+	}
+}
+
+// load builds a configuration from its layers on top of c, which holds the
+// defaults, and validates the result. File is the coerced document of a
+// configuration file, or nil.
+func (c *Config) load(file map[string]interface{}, opts []Option) (*Config, error) {
+	c.sources = make(map[string]string)
+
+	if err := c.applyOptions(opts, SourceCode); err != nil {
+		return nil, err
+	}
+
+	if file != nil {
+		encoded, err := json.Marshal(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(encoded, c); err != nil {
+			return nil, err
+		}
+		c.markSources(file, SourceFile)
+	}
+
+	for _, prefix := range c.envPrefixes {
+		if err := c.applyEnv(prefix, os.Environ()); err != nil {
+			return nil, err
+		}
+	}
+
+	overrides := c.overrides
+	c.overrides = nil
+	if err := c.applyOptions(overrides, SourceOverride); err != nil {
+		return nil, err
+	}
+
+	if err := c.decryptValues(); err != nil {
+		return nil, err
+	}
+
+	before := c.settings()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	for path := range changedSettings(before, c.settings()) {
+		c.sources[path] = SourceDefault
+	}
+
+	return c, nil
+}
+
+// applyOptions applies options, recording the settings they change
+func (c *Config) applyOptions(opts []Option, source string) error {
+	for _, opt := range opts {
+		before := c.settings()
+		if err := opt(c); err != nil {
+			return err
+		}
+		for path := range changedSettings(before, c.settings()) {
+			c.sources[path] = source
+		}
+	}
+	return nil
+}
+
+// markSources records the source of the settings in a document
+func (c *Config) markSources(doc map[string]interface{}, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	for path := range flattenSettings(doc) {
+		c.sources[path] = source
+	}
+}
+
+// settings returns the settings of the configuration by path
+func (c *Config) settings() map[string]interface{} {
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return nil
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil
+	}
+	return flattenSettings(doc)
+}
+
+// flattenSettings returns the values of a document by path, e.g.
+// "email.port" or "groups.sre". Lists are single values.
+func flattenSettings(doc map[string]interface{}) map[string]interface{} {
+	settings := make(map[string]interface{})
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		if table, ok := v.(map[string]interface{}); ok && len(table) > 0 {
+			for key, value := range table {
+				walk(joinPath(path, key), value)
+			}
+			return
+		}
+		settings[path] = v
+	}
+	for key, value := range doc {
+		walk(key, value)
+	}
+	return settings
+}
+
+// changedSettings returns the paths whose values differ
+func changedSettings(before, after map[string]interface{}) map[string]bool {
+	changed := make(map[string]bool)
+	for path, value := range after {
+		if previous, ok := before[path]; !ok || !reflect.DeepEqual(previous, value) {
+			changed[path] = true
+		}
+	}
+	return changed
+}
+
+// Setting is one setting of an effective configuration
+type Setting struct {
+	Path   string      `json:"path"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// Effective returns every setting of the configuration in path order, with
+// the layer that set it, to answer questions like "which timeout applies".
+// Credentials are masked: passwords, tokens and secrets are replaced, the
+// path of webhook URLs that embed an access token is hidden, passwords in
+// URLs are removed and plugin settings are replaced.
+func (c *Config) Effective() []Setting {
+	values := c.settings()
+	effective := make([]Setting, 0, len(values))
+	for path, value := range values {
+		if value == nil {
+			continue
+		}
+		source, ok := c.sources[path]
+		if !ok {
+			source = SourceDefault
+		}
+		effective = append(effective, Setting{Path: path, Value: maskSetting(path, value), Source: source})
+	}
+	sort.Slice(effective, func(i, j int) bool { return effective[i].Path < effective[j].Path })
+	return effective
+}
+
+// sensitiveSettings are the names of settings holding credentials
+var sensitiveSettings = map[string]bool{
+	"password":          true,
+	"secret":            true,
+	"previous_secret":   true,
+	"app_secret":        true,
+	"token":             true,
+	"api_key":           true,
+	"bearer_token":      true,
+	"client_secret":     true,
+	"refresh_token":     true,
+	"secret_access_key": true,
+	"session_token":     true,
+	"authorization":     true, // request headers
+	"key":               true, // inline TLS private keys
+}
+
+// CredentialValues returns the credentials of the configuration: the
+// values of the settings Effective masks, such as passwords, tokens, the
+// webhook URLs that embed an access token and their paths, passwords in
+// URLs, plugin settings and alternative credentials. Errors and logs are
+// scrubbed of them, as providers sometimes echo them back.
+func (c *Config) CredentialValues() []string {
+	var values []string
+	for path, value := range c.settings() {
+		s, ok := value.(string)
+		if !ok || s == "" || maskSetting(path, value) == value {
+			continue
+		}
+		values = append(values, s)
+		if u, err := url.Parse(s); err == nil && u.Host != "" {
+			if password, ok := u.User.Password(); ok {
+				values = append(values, password)
+			}
+			if strings.HasSuffix(path, "webhook_url") && len(u.Path) > 1 {
+				values = append(values, u.Path)
+			}
+		}
+	}
+	for _, credentials := range c.Credentials {
+		for _, cred := range credentials {
+			for _, value := range cred.Settings {
+				values = append(values, value)
+			}
+		}
+	}
+	for _, regions := range c.Regions {
+		for _, region := range regions {
+			for name, value := range region.Settings {
+				if value != "" && maskSetting(name, value) != value {
+					values = append(values, value)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// maskSetting hides the credentials in a setting value
+func maskSetting(path string, value interface{}) interface{} {
+	if strings.HasPrefix(path, "credentials.") {
+		return maskCredentials(value)
+	}
+	if strings.HasPrefix(path, "regions.") {
+		return maskRegions(value)
+	}
+	if strings.HasPrefix(path, "plugins.") && strings.Contains(path, ".settings.") {
+		// Plugin settings are opaque, any of them may be a credential
+		return "******"
+	}
+	name := path[strings.LastIndex(path, ".")+1:]
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return value
+	}
+
+	switch {
+	case sensitiveSettings[strings.ToLower(name)]:
+		return "******"
+	case name == "webhook_url":
+		// Feishu and Slack webhook URLs carry their access token in the path
+		if u, err := url.Parse(s); err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host + "/******"
+		}
+		return "******"
+	case strings.Contains(s, "://"):
+		if u, err := url.Parse(s); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+	}
+	return value
+}
@@ -2,8 +2,19 @@
 package config
 
 import (
+	"strings"
 	"time"
 
+	"github.com/kart-io/notifyhub/pkg/archive"
+	"github.com/kart-io/notifyhub/pkg/audit"
+	"github.com/kart-io/notifyhub/pkg/contact"
+	"github.com/kart-io/notifyhub/pkg/flags"
+	"github.com/kart-io/notifyhub/pkg/preference"
+	"github.com/kart-io/notifyhub/pkg/quarantine"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+	"github.com/kart-io/notifyhub/pkg/secret"
+	"github.com/kart-io/notifyhub/pkg/suppression"
+	"github.com/kart-io/notifyhub/pkg/target"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
 )
 
@@ -48,6 +59,24 @@ func WithAsync(workers int) Option {
 	}
 }
 
+// WithQueue selects the backend of the async queue and enables async
+// sending through a worker pool: QueueMemory, or QueueRedis with the
+// configuration of its server, which keeps queued messages across
+// restarts:
+//
+//	config.WithQueue(config.QueueRedis, config.RedisQueueConfig{Addr: "localhost:6379"})
+func WithQueue(backend string, redis ...RedisQueueConfig) Option {
+	return func(c *Config) error {
+		c.Async.Enabled = true
+		c.Async.UsePool = true
+		c.Async.Queue = backend
+		if len(redis) > 0 {
+			c.Async.Redis = &redis[0]
+		}
+		return nil
+	}
+}
+
 // WithLogger sets the logger instance
 func WithLogger(logger logger.Logger) Option {
 	return func(c *Config) error {
@@ -56,6 +85,464 @@ func WithLogger(logger logger.Logger) Option {
 	}
 }
 
+// WithGroup defines a recipient group whose members are expanded at send time.
+// Members may be contact IDs, explicit targets ("email:ops@example.com")
+// or nested groups ("group:sre", "team:payments").
+func WithGroup(name string, members ...string) Option {
+	return func(c *Config) error {
+		if c.Groups == nil {
+			c.Groups = make(map[string][]string)
+		}
+		c.Groups[name] = members
+		return nil
+	}
+}
+
+// WithGroups defines multiple recipient groups
+func WithGroups(groups map[string][]string) Option {
+	return func(c *Config) error {
+		if c.Groups == nil {
+			c.Groups = make(map[string][]string)
+		}
+		for name, members := range groups {
+			c.Groups[name] = members
+		}
+		return nil
+	}
+}
+
+// WithContactDirectory sets the contact directory used to resolve
+// group members and contact IDs
+func WithContactDirectory(directory contact.Directory) Option {
+	return func(c *Config) error {
+		c.ContactDirectory = directory
+		return nil
+	}
+}
+
+// WithOnCallResolver sets the resolver used to expand on-call targets
+// ("oncall:payments") into whoever is on call when the message is sent
+func WithOnCallResolver(resolver contact.OnCallResolver) Option {
+	return func(c *Config) error {
+		c.OnCallResolver = resolver
+		return nil
+	}
+}
+
+// WithListProvider sets the provider that fetches the members of dynamic
+// list targets (target.NewList) at send time
+func WithListProvider(provider contact.ListProvider) Option {
+	return func(c *Config) error {
+		c.ListProvider = provider
+		return nil
+	}
+}
+
+// WithRecipientDedupe delivers each message once per person. Targets that
+// resolve to the same directory contact (for example their email address
+// and their Feishu ID) are reduced to the contact's most preferred platform.
+// Identities are linked through the contact directory.
+func WithRecipientDedupe() Option {
+	return func(c *Config) error {
+		c.DedupeRecipients = true
+		return nil
+	}
+}
+
+// WithSuppressionStore sets the opt-out store consulted before every send.
+// Suppressed targets are reported on the receipt with the "suppressed" status.
+func WithSuppressionStore(store suppression.Store) Option {
+	return func(c *Config) error {
+		c.Suppression = store
+		return nil
+	}
+}
+
+// WithPreferences sets the contact preference store consulted when
+// contact, group and team targets are resolved
+func WithPreferences(store preference.Store) Option {
+	return func(c *Config) error {
+		c.Preferences = store
+		return nil
+	}
+}
+
+// WithDigestBuffer sets the buffer that collects messages for contacts who
+// prefer digest delivery. Without it, digest preferences are ignored and
+// messages are delivered immediately.
+func WithDigestBuffer(buffer *preference.DigestBuffer) Option {
+	return func(c *Config) error {
+		c.Digests = buffer
+		return nil
+	}
+}
+
+// WithAlias defines a target alias usable as target.NewAlias(name).
+// Defining an alias again replaces its targets.
+func WithAlias(name string, targets ...target.Target) Option {
+	return func(c *Config) error {
+		if c.Aliases == nil {
+			c.Aliases = make(map[string][]target.Target)
+		}
+		c.Aliases[name] = targets
+		return nil
+	}
+}
+
+// WithTargetAccess adds allow and deny patterns for the target values a
+// platform may send to. Blocked sends are recorded in the audit log.
+func WithTargetAccess(rule target.AccessRule) Option {
+	return func(c *Config) error {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		c.TargetAccess = append(c.TargetAccess, rule)
+		return nil
+	}
+}
+
+// WithAuditRecorder sets the recorder for audit entries such as blocked sends
+func WithAuditRecorder(recorder audit.Recorder) Option {
+	return func(c *Config) error {
+		c.Audit = recorder
+		return nil
+	}
+}
+
+// WithAuditSends records an audit entry for every completed send, with the
+// title and body of the message and their hash, or with the hash only when
+// withContent is false
+func WithAuditSends(withContent bool) Option {
+	return func(c *Config) error {
+		c.AuditSends = true
+		c.AuditContent = &withContent
+		return nil
+	}
+}
+
+// WithQuarantine quarantines targets after threshold consecutive hard
+// failures. Quarantined targets are skipped with the "quarantined" status
+// until they are reinstated in the store. A threshold of zero uses
+// quarantine.DefaultThreshold.
+func WithQuarantine(store quarantine.Store, threshold int) Option {
+	return func(c *Config) error {
+		c.Quarantine = store
+		c.QuarantineThreshold = threshold
+		return nil
+	}
+}
+
+// WithMaxInFlight caps the concurrent sends of a platform at max; sends
+// beyond the cap wait for one to finish
+func WithMaxInFlight(platform string, max int) Option {
+	return func(c *Config) error {
+		if c.MaxInFlight == nil {
+			c.MaxInFlight = make(map[string]int)
+		}
+		c.MaxInFlight[platform] = max
+		return nil
+	}
+}
+
+// WithRetry retries failed sends with a retry policy, for every platform
+// or, when platforms are named, for those only
+func WithRetry(policy RetryPolicy, platforms ...string) Option {
+	return func(c *Config) error {
+		if len(platforms) == 0 {
+			c.Retry = &policy
+			return nil
+		}
+		if c.PlatformRetry == nil {
+			c.PlatformRetry = make(map[string]RetryPolicy)
+		}
+		for _, platform := range platforms {
+			c.PlatformRetry[platform] = policy
+		}
+		return nil
+	}
+}
+
+// WithProxy routes the HTTP requests of platforms through an outbound
+// proxy. Platforms with a proxy section of their own use that instead.
+func WithProxy(proxy ProxyConfig) Option {
+	return func(c *Config) error {
+		if err := proxy.Validate(); err != nil {
+			return err
+		}
+		c.Proxy = &proxy
+		return nil
+	}
+}
+
+// WithExternalPlatforms declares platforms that are registered with the
+// client at runtime, see Client.RegisterPlatform, so that targets, access
+// rules and rate limits may name them
+func WithExternalPlatforms(names ...string) Option {
+	return func(c *Config) error {
+		c.ExternalPlatforms = append(c.ExternalPlatforms, names...)
+		return nil
+	}
+}
+
+// WithPlugin adds a platform whose sender runs as a separate program
+func WithPlugin(name string, plugin Plugin) Option {
+	return func(c *Config) error {
+		if c.Plugins == nil {
+			c.Plugins = make(map[string]Plugin)
+		}
+		c.Plugins[name] = plugin
+		return nil
+	}
+}
+
+// WithDryRun records sends as delivered without calling the platforms,
+// logging the messages instead: every platform when no names are given,
+// otherwise the named ones
+func WithDryRun(platforms ...string) Option {
+	return func(c *Config) error {
+		if len(platforms) == 0 {
+			c.DryRun = true
+			return nil
+		}
+		c.DryRunPlatforms = append(c.DryRunPlatforms, platforms...)
+		return nil
+	}
+}
+
+// WithRedaction turns on the masking of personal data and secrets in logs,
+// errors and receipts, whatever the log level, adding patterns to mask to
+// the default rules
+func WithRedaction(patterns ...string) Option {
+	return func(c *Config) error {
+		enabled := true
+		c.Redaction.Enabled = &enabled
+		c.Redaction.Patterns = append(c.Redaction.Patterns, patterns...)
+		return nil
+	}
+}
+
+// WithoutRedaction turns off the masking of personal data and secrets
+func WithoutRedaction() Option {
+	return func(c *Config) error {
+		enabled := false
+		c.Redaction.Enabled = &enabled
+		return nil
+	}
+}
+
+// WithTargetMasking sets how receipts served by the APIs show target
+// addresses: redact.TargetMaskPartial, TargetMaskFull or TargetMaskNone
+func WithTargetMasking(mode string) Option {
+	return func(c *Config) error {
+		c.Redaction.Targets = mode
+		return nil
+	}
+}
+
+// WithSendDefaults sets the defaults of sends, such as the timeout, retries
+// and platform order, for messages that do not set their own
+func WithSendDefaults(defaults SendDefaults) Option {
+	return func(c *Config) error {
+		c.Defaults = defaults
+		return nil
+	}
+}
+
+// WithFlags sets the feature flag provider consulted before every send.
+// Sends to platforms it disables are skipped with the "disabled" status.
+func WithFlags(provider flags.Provider) Option {
+	return func(c *Config) error {
+		c.Flags = provider
+		return nil
+	}
+}
+
+// WithSecrets resolves secret references such as "secret://vault/smtp#password"
+// or "file:///run/secrets/smtp_pass" in platform settings with the given
+// resolver, and resolves them again every refresh interval when it is
+// positive
+func WithSecrets(resolver *secret.Resolver, refresh time.Duration) Option {
+	return func(c *Config) error {
+		c.Secrets = resolver
+		c.SecretRefresh = refresh
+		return nil
+	}
+}
+
+// WithCredentials adds alternative credentials for a configured platform,
+// e.g. a secondary API key to fall back to while the primary one is
+// rotated. The credentials are tried in order after the section's own.
+func WithCredentials(platform string, credentials ...Credential) Option {
+	return func(c *Config) error {
+		if c.Credentials == nil {
+			c.Credentials = make(map[string][]Credential)
+		}
+		c.Credentials[platform] = append(c.Credentials[platform], credentials...)
+		return nil
+	}
+}
+
+// WithRegions adds alternative endpoints for a configured platform in
+// other regions, e.g. Lark beside Feishu, tried in order after the
+// section's own when a region fails
+func WithRegions(platform string, regions ...Region) Option {
+	return func(c *Config) error {
+		if c.Regions == nil {
+			c.Regions = make(map[string][]Region)
+		}
+		c.Regions[platform] = append(c.Regions[platform], regions...)
+		return nil
+	}
+}
+
+// WithRegionFailover sets after how many consecutive failed sends a
+// platform region is skipped, and for how long
+func WithRegionFailover(threshold int, cooldown time.Duration) Option {
+	return func(c *Config) error {
+		c.RegionFailover = RegionFailoverConfig{FailureThreshold: threshold, Cooldown: cooldown}
+		return nil
+	}
+}
+
+// WithDecryptionKey decrypts the encrypted values of a configuration, such
+// as a webhook token stored as "ENC[AES256_GCM,data:...,type:str]", with
+// the key the provider supplies. Values are encrypted for their setting
+// with secret.Encrypt, e.g. secret.Encrypt(key, "webhook.token", token),
+// so a configuration file holding them can be committed safely. Values are
+// decrypted once every layer is applied, so they may come from the file,
+// the environment or options.
+func WithDecryptionKey(provider secret.KeyProvider) Option {
+	return func(c *Config) error {
+		c.Decryption = provider
+		return nil
+	}
+}
+
+// WithTargetRateLimit caps the sends to each recipient on a platform, for
+// example ratelimit.Limit{Platform: "sms", Max: 5, Per: time.Hour}
+func WithTargetRateLimit(limit ratelimit.Limit) Option {
+	return func(c *Config) error {
+		if err := limit.Validate(); err != nil {
+			return err
+		}
+		c.TargetRateLimits = append(c.TargetRateLimits, limit)
+		return nil
+	}
+}
+
+// WithRateLimiter sets the limiter that counts sends for target rate limits.
+// Without it counts are kept in memory per client.
+func WithRateLimiter(limiter ratelimit.Limiter) Option {
+	return func(c *Config) error {
+		c.RateLimiter = limiter
+		return nil
+	}
+}
+
+// WithQuota caps the sends of a platform to respect a provider quota, for
+// example ratelimit.Quota{Platform: "feishu", Rate: 300, Per: time.Minute}
+func WithQuota(quota ratelimit.Quota) Option {
+	return func(c *Config) error {
+		if err := quota.Validate(); err != nil {
+			return err
+		}
+		c.Quotas = append(c.Quotas, quota)
+		return nil
+	}
+}
+
+// WithUsageQuota caps the messages of tenants or apps per day or month,
+// for example ratelimit.UsageQuota{Tenant: "*", Platform: "sms", Max: 500,
+// Period: ratelimit.PeriodMonth}
+func WithUsageQuota(quota ratelimit.UsageQuota) Option {
+	return func(c *Config) error {
+		if err := quota.Validate(); err != nil {
+			return err
+		}
+		c.UsageQuotas = append(c.UsageQuotas, quota)
+		return nil
+	}
+}
+
+// WithUsageWarnings sends a warning to targets when a tenant or app
+// reaches the soft limit of a usage quota
+func WithUsageWarnings(targets ...target.Target) Option {
+	return func(c *Config) error {
+		c.UsageWarningTargets = append(c.UsageWarningTargets, targets...)
+		return nil
+	}
+}
+
+// WithUsageCounter sets the counter of the usage of usage quotas, such as
+// one kept in a database shared by a fleet of clients. Without it each
+// client counts usage in memory.
+func WithUsageCounter(counter ratelimit.UsageCounter) Option {
+	return func(c *Config) error {
+		c.UsageCounter = counter
+		return nil
+	}
+}
+
+// WithRateLimitState saves the state of the in-memory quotas and target
+// rate limits to a file every interval and when the client is closed, and
+// restores it when a client starts; a zero interval saves every minute
+func WithRateLimitState(file string, interval time.Duration) Option {
+	return func(c *Config) error {
+		c.RateLimitState = RateLimitStateConfig{File: file, Interval: interval}
+		return nil
+	}
+}
+
+// WithArchive writes completed messages and their receipts to long-term
+// storage on a schedule, as configured by cfg
+func WithArchive(cfg ArchiveConfig) Option {
+	return func(c *Config) error {
+		c.Archive = cfg
+		return nil
+	}
+}
+
+// WithArchiveStore writes the archive to a store, such as a bucket client
+// of a cloud SDK, instead of the directory or bucket of the archive
+// configuration
+func WithArchiveStore(store archive.Store) Option {
+	return func(c *Config) error {
+		c.ArchiveStore = store
+		return nil
+	}
+}
+
+// WithTokenBuckets sets the token buckets that count sends for quotas, such
+// as a ratelimit.RedisTokenBuckets shared by a fleet of clients. Without it
+// each client counts its sends in memory.
+func WithTokenBuckets(buckets ratelimit.TokenBuckets) Option {
+	return func(c *Config) error {
+		c.TokenBuckets = buckets
+		return nil
+	}
+}
+
+// WithDeliveryWindow holds deferrable messages until the window opens in
+// the recipient's time zone. Targets without a time zone use the window's.
+func WithDeliveryWindow(window preference.DeliveryWindow) Option {
+	return func(c *Config) error {
+		if err := window.Validate(); err != nil {
+			return err
+		}
+		c.DeliveryWindow = &window
+		return nil
+	}
+}
+
+// WithDefaultRegion sets the region assumed for phone numbers written
+// without a country calling code (e.g. "CN", "US")
+func WithDefaultRegion(region string) Option {
+	return func(c *Config) error {
+		c.DefaultRegion = strings.ToUpper(region)
+		return nil
+	}
+}
+
 // WithTimeout sets the default timeout
 func WithTimeout(timeout time.Duration) Option {
 	return func(c *Config) error {
@@ -151,6 +638,17 @@ func WithQuickFeishu(webhookURL, secret string) Option {
 	return WithFeishu(NewFeishuConfig(webhookURL, secret))
 }
 
+// WithFeishuApp configures Feishu to send as an app, which reaches user and
+// group targets through the IM API
+func WithFeishuApp(appID, appSecret string) Option {
+	return WithFeishu(FeishuConfig{
+		AppID:      appID,
+		AppSecret:  appSecret,
+		Timeout:    30 * time.Second,
+		MaxRetries: 3,
+	})
+}
+
 // WithQuickEmail is a convenience method for quick Email setup
 func WithQuickEmail(host string, port int, from string) Option {
 	return WithEmail(NewEmailConfig(host, port, from))
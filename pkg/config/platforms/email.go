@@ -2,7 +2,7 @@
 package platforms
 
 import (
-	"fmt"
+	"net/mail"
 	"time"
 )
 
@@ -19,42 +19,87 @@ type EmailConfig struct {
 	UseSSL    bool `json:"use_ssl" yaml:"use_ssl"`
 	VerifySSL bool `json:"verify_ssl" yaml:"verify_ssl"`
 
+	// TLS configures the certificates and versions of the implicit TLS or
+	// STARTTLS connection
+	TLS *TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+
+	// OAuth2 authenticates the username with XOAUTH2 access tokens instead
+	// of the password, as Gmail and Microsoft 365 require
+	OAuth2 *OAuth2Config `json:"oauth2,omitempty" yaml:"oauth2,omitempty"`
+
+	// BounceAddress is the envelope sender bounces are returned to. The
+	// message ID and recipient are encoded in it with VERP, e.g.
+	// bounces+<message id>=alice=example.org@example.com, so that bounces
+	// read back from its mailbox are correlated to them.
+	BounceAddress string `json:"bounce_address,omitempty" yaml:"bounce_address,omitempty"`
+
+	// MaxAttachmentSize is the largest total size in bytes of the
+	// attachments of a message; zero allows 10 MB
+	MaxAttachmentSize int64 `json:"max_attachment_size,omitempty" yaml:"max_attachment_size,omitempty"`
+
 	// Connection settings
 	Timeout    time.Duration `json:"timeout" yaml:"timeout"`
 	Retries    int           `json:"retries" yaml:"retries"`
 	MaxRetries int           `json:"max_retries" yaml:"max_retries"`
 	RateLimit  int           `json:"rate_limit" yaml:"rate_limit"`
+
+	// PoolSize is the most SMTP connections open at once, which are kept
+	// logged in and reused across sends; zero uses 10
+	PoolSize int `json:"pool_size,omitempty" yaml:"pool_size,omitempty"`
+
+	// IdleTimeout is how long an unused connection stays open for reuse;
+	// zero uses 30s and a negative value closes connections after each send
+	IdleTimeout time.Duration `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty"`
 }
 
 // Validate validates the Email configuration
 func (c *EmailConfig) Validate() error {
+	var p problems
 	if c.Host == "" {
-		return fmt.Errorf("host is required for Email platform")
+		p.add("host", "host is required for Email platform")
 	}
 
 	if c.Port <= 0 || c.Port > 65535 {
-		return fmt.Errorf("port must be between 1 and 65535")
+		p.add("port", "port must be between 1 and 65535")
 	}
 
 	if c.From == "" {
-		return fmt.Errorf("from address is required for Email platform")
+		p.add("from", "from address is required for Email platform")
+	}
+
+	// use_ssl connects with implicit TLS (port 465) while use_tls upgrades a
+	// plain connection with STARTTLS (port 587); a server speaks only one
+	if c.UseSSL && c.UseTLS {
+		p.add("use_ssl", "use_ssl and use_tls cannot both be enabled: use use_ssl for implicit TLS (port 465) or use_tls for STARTTLS (port 587)")
 	}
 
-	if c.Timeout < 0 {
-		return fmt.Errorf("timeout cannot be negative")
+	if c.OAuth2 != nil {
+		if c.Username == "" {
+			p.add("username", "username is required for OAuth2 authentication")
+		}
+		if c.Password != "" {
+			p.add("password", "password and oauth2 cannot both be set")
+		}
+		p.addSection("oauth2", c.OAuth2.Validate())
 	}
 
-	if c.Retries < 0 {
-		return fmt.Errorf("retries cannot be negative")
+	if c.BounceAddress != "" {
+		if _, err := mail.ParseAddress(c.BounceAddress); err != nil {
+			p.add("bounce_address", "bounce_address must be an email address, got %q", c.BounceAddress)
+		}
 	}
 
-	if c.MaxRetries < 0 {
-		return fmt.Errorf("max_retries cannot be negative")
+	if c.PoolSize < 0 {
+		p.add("pool_size", "pool_size cannot be negative")
 	}
 
-	if c.RateLimit < 0 {
-		return fmt.Errorf("rate_limit cannot be negative")
+	if c.MaxAttachmentSize < 0 {
+		p.add("max_attachment_size", "max_attachment_size cannot be negative")
 	}
 
-	return nil
+	p.checkConnection(c.Timeout, c.Retries, c.MaxRetries, c.RateLimit)
+	if c.TLS != nil {
+		p.addSection("tls", c.TLS.Validate())
+	}
+	return p.err()
 }
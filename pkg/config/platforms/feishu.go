@@ -2,7 +2,7 @@
 package platforms
 
 import (
-	"fmt"
+	"net/url"
 	"time"
 )
 
@@ -12,6 +12,17 @@ type FeishuConfig struct {
 	Secret     string   `json:"secret" yaml:"secret"`
 	Keywords   []string `json:"keywords" yaml:"keywords"`
 
+	// AppID and AppSecret are the credentials of a Feishu app, which sends
+	// through the IM API (im/v1/messages) to user and group (chat) targets
+	// that a webhook bot cannot reach. Either a webhook URL or the app
+	// credentials are required; with both, webhook targets use the bot.
+	AppID     string `json:"app_id,omitempty" yaml:"app_id,omitempty"`
+	AppSecret string `json:"app_secret,omitempty" yaml:"app_secret,omitempty"`
+
+	// BaseURL is the Open API endpoint of the app; empty uses
+	// https://open.feishu.cn, Lark uses https://open.larksuite.com
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+
 	// Connection settings
 	Timeout    time.Duration `json:"timeout" yaml:"timeout"`
 	Retries    int           `json:"retries" yaml:"retries"`
@@ -20,29 +31,35 @@ type FeishuConfig struct {
 
 	// Security settings
 	VerifySSL bool `json:"verify_ssl" yaml:"verify_ssl"`
+
+	// TLS configures the certificates and versions of TLS connections; with
+	// it verify_ssl is ignored
+	TLS *TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+
+	// Proxy overrides the global proxy for this platform
+	Proxy *ProxyConfig `json:"proxy,omitempty" yaml:"proxy,omitempty"`
 }
 
 // Validate validates the Feishu configuration
 func (c *FeishuConfig) Validate() error {
-	if c.WebhookURL == "" {
-		return fmt.Errorf("webhook_url is required for Feishu platform")
+	var p problems
+	if c.WebhookURL == "" && c.AppID == "" {
+		p.add("webhook_url", "webhook_url or app_id is required for Feishu platform")
 	}
-
-	if c.Timeout < 0 {
-		return fmt.Errorf("timeout cannot be negative")
+	if c.AppID != "" && c.AppSecret == "" {
+		p.add("app_secret", "app_secret is required with app_id")
 	}
-
-	if c.Retries < 0 {
-		return fmt.Errorf("retries cannot be negative")
+	if c.BaseURL != "" {
+		if u, err := url.Parse(c.BaseURL); err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			p.add("base_url", "base_url must be an http or https URL, got %q", c.BaseURL)
+		}
 	}
-
-	if c.MaxRetries < 0 {
-		return fmt.Errorf("max_retries cannot be negative")
+	p.checkConnection(c.Timeout, c.Retries, c.MaxRetries, c.RateLimit)
+	if c.TLS != nil {
+		p.addSection("tls", c.TLS.Validate())
 	}
-
-	if c.RateLimit < 0 {
-		return fmt.Errorf("rate_limit cannot be negative")
+	if c.Proxy != nil {
+		p.addSection("proxy", c.Proxy.Validate())
 	}
-
-	return nil
+	return p.err()
 }
@@ -0,0 +1,48 @@
+// Package platforms provides the OAuth2 settings of platforms
+package platforms
+
+import "net/url"
+
+// OAuth2Config configures the OAuth2 tokens a platform authenticates with,
+// such as the XOAUTH2 login of Gmail or Microsoft 365 SMTP. With a refresh
+// token the tokens are obtained with the refresh token grant, otherwise
+// with the client credentials grant. Tokens are cached and refreshed
+// shortly before they expire.
+type OAuth2Config struct {
+	// TokenURL is the token endpoint, e.g.
+	// https://oauth2.googleapis.com/token or
+	// https://login.microsoftonline.com/{tenant}/oauth2/v2.0/token
+	TokenURL string `json:"token_url" yaml:"token_url"`
+
+	// ClientID identifies the application registered with the provider
+	ClientID string `json:"client_id" yaml:"client_id"`
+
+	// ClientSecret authenticates the application; it is optional with a
+	// refresh token of a public client
+	ClientSecret string `json:"client_secret" yaml:"client_secret"`
+
+	// RefreshToken is the long-lived token of a user that consented to
+	// the access; empty uses the client credentials grant
+	RefreshToken string `json:"refresh_token" yaml:"refresh_token"`
+
+	// Scopes are requested with the client credentials grant, e.g.
+	// https://outlook.office365.com/.default
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+}
+
+// Validate validates the OAuth2 settings
+func (c *OAuth2Config) Validate() error {
+	var p problems
+	if c.TokenURL == "" {
+		p.add("token_url", "token_url is required for OAuth2")
+	} else if u, err := url.Parse(c.TokenURL); err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		p.add("token_url", "token_url must be an http or https URL, got %q", c.TokenURL)
+	}
+	if c.ClientID == "" {
+		p.add("client_id", "client_id is required for OAuth2")
+	}
+	if c.RefreshToken == "" && c.ClientSecret == "" {
+		p.add("client_secret", "client_secret is required for the client credentials grant")
+	}
+	return p.err()
+}
@@ -0,0 +1,70 @@
+// Package platforms provides validation helpers for platform configurations
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FieldError is a problem with one setting of a platform configuration.
+// Validate methods return every problem they find joined with errors.Join,
+// so callers can list them all with their setting names.
+type FieldError struct {
+	Field   string // JSON name of the setting, e.g. "port"
+	Message string
+}
+
+// Error implements the error interface
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// problems collects the setting problems of a configuration
+type problems []error
+
+// add records a problem with a setting
+func (p *problems) add(field, format string, args ...interface{}) {
+	*p = append(*p, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// addSection records the problems of a nested section of settings, such
+// as the proxy of a platform, with their field names below the section
+func (p *problems) addSection(section string, err error) {
+	if err == nil {
+		return
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		field := section
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			field += "." + fieldErr.Field
+		}
+		*p = append(*p, &FieldError{Field: field, Message: err.Error()})
+	}
+}
+
+// err returns the collected problems as one error, or nil
+func (p problems) err() error {
+	return errors.Join(p...)
+}
+
+// checkConnection validates the connection settings shared by all platforms
+func (p *problems) checkConnection(timeout time.Duration, retries, maxRetries, rateLimit int) {
+	if timeout < 0 {
+		p.add("timeout", "timeout cannot be negative")
+	}
+	if retries < 0 {
+		p.add("retries", "retries cannot be negative")
+	}
+	if maxRetries < 0 {
+		p.add("max_retries", "max_retries cannot be negative")
+	}
+	if rateLimit < 0 {
+		p.add("rate_limit", "rate_limit cannot be negative")
+	}
+}
@@ -0,0 +1,116 @@
+// Package platforms provides the outbound proxy settings of platforms
+package platforms
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyConfig routes the HTTP requests of a platform through a proxy
+type ProxyConfig struct {
+	// URL of the proxy, e.g. "http://proxy.corp:3128" or
+	// "socks5://proxy.corp:1080". Empty connects directly, which lets a
+	// platform opt out of the global proxy.
+	URL string `json:"url" yaml:"url"`
+
+	// Username and Password authenticate with the proxy
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+
+	// NoProxy lists the hosts reached directly: host names, which also
+	// match their subdomains ("corp.example.com" or ".corp.example.com"),
+	// IP addresses, CIDR ranges ("10.0.0.0/8") and "*" for every host. A
+	// host name or address may carry a port to match only that port.
+	NoProxy []string `json:"no_proxy" yaml:"no_proxy"`
+}
+
+// Validate validates the proxy settings
+func (c *ProxyConfig) Validate() error {
+	var p problems
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		switch {
+		case err != nil:
+			p.add("url", "proxy url is invalid: %v", err)
+		case u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5":
+			p.add("url", "proxy url must use http, https or socks5, got %q", c.URL)
+		case u.Host == "":
+			p.add("url", "proxy url must include a host")
+		}
+	}
+	if c.Password != "" && c.Username == "" {
+		p.add("username", "proxy username is required with a password")
+	}
+	for i, entry := range c.NoProxy {
+		if strings.TrimSpace(entry) == "" {
+			p.add(fmt.Sprintf("no_proxy[%d]", i), "no_proxy entries cannot be empty")
+		}
+	}
+	return p.err()
+}
+
+// Apply routes the requests of an HTTP transport through the proxy. A nil
+// configuration leaves the transport unchanged, so it keeps honoring the
+// HTTP_PROXY environment variables when it did.
+func (c *ProxyConfig) Apply(transport *http.Transport) error {
+	if c == nil {
+		return nil
+	}
+	if c.URL == "" {
+		transport.Proxy = nil
+		return nil
+	}
+
+	proxyURL, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy url: %w", err)
+	}
+	if c.Username != "" {
+		proxyURL.User = url.UserPassword(c.Username, c.Password)
+	}
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if c.bypass(req.URL) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+	return nil
+}
+
+// bypass reports whether a request URL matches the no-proxy list
+func (c *ProxyConfig) bypass(u *url.URL) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range c.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		entryHost = strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
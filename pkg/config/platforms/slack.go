@@ -2,7 +2,6 @@
 package platforms
 
 import (
-	"fmt"
 	"strings"
 	"time"
 )
@@ -23,6 +22,13 @@ type SlackConfig struct {
 	// Security settings
 	VerifySSL bool `json:"verify_ssl" yaml:"verify_ssl"`
 
+	// TLS configures the certificates and versions of TLS connections; with
+	// it verify_ssl is ignored
+	TLS *TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+
+	// Proxy overrides the global proxy for this platform
+	Proxy *ProxyConfig `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+
 	// Slack-specific settings
 	Username  string `json:"username" yaml:"username"`     // Bot username (for webhook)
 	IconEmoji string `json:"icon_emoji" yaml:"icon_emoji"` // Bot icon emoji
@@ -31,48 +37,36 @@ type SlackConfig struct {
 
 // Validate validates the Slack configuration
 func (c *SlackConfig) Validate() error {
+	var p problems
 	if c.WebhookURL == "" && c.Token == "" {
-		return fmt.Errorf("either webhook_url or token is required for Slack platform")
+		p.add("webhook_url", "either webhook_url or token is required for Slack platform")
 	}
 
 	// Validate webhook URL format if provided
-	if c.WebhookURL != "" {
-		if !strings.HasPrefix(c.WebhookURL, "https://hooks.slack.com/") {
-			return fmt.Errorf("webhook_url must be a valid Slack webhook URL")
-		}
+	if c.WebhookURL != "" && !strings.HasPrefix(c.WebhookURL, "https://hooks.slack.com/") {
+		p.add("webhook_url", "webhook_url must be a valid Slack webhook URL")
 	}
 
 	// Validate token format if provided
-	if c.Token != "" {
-		if !strings.HasPrefix(c.Token, "xoxb-") && !strings.HasPrefix(c.Token, "xoxp-") {
-			return fmt.Errorf("token must be a valid Slack bot token (xoxb-) or user token (xoxp-)")
-		}
+	if c.Token != "" && !strings.HasPrefix(c.Token, "xoxb-") && !strings.HasPrefix(c.Token, "xoxp-") {
+		p.add("token", "token must be a valid Slack bot token (xoxb-) or user token (xoxp-)")
 	}
 
 	// Validate channel format if provided
 	if c.Channel != "" {
 		if !strings.HasPrefix(c.Channel, "#") && !strings.HasPrefix(c.Channel, "@") && !strings.HasPrefix(c.Channel, "C") && !strings.HasPrefix(c.Channel, "D") {
-			return fmt.Errorf("channel must start with # (public), @ (user), C (channel ID), or D (DM ID)")
+			p.add("channel", "channel must start with # (public), @ (user), C (channel ID), or D (DM ID)")
 		}
 	}
 
-	if c.Timeout < 0 {
-		return fmt.Errorf("timeout cannot be negative")
+	p.checkConnection(c.Timeout, c.Retries, c.MaxRetries, c.RateLimit)
+	if c.TLS != nil {
+		p.addSection("tls", c.TLS.Validate())
 	}
-
-	if c.Retries < 0 {
-		return fmt.Errorf("retries cannot be negative")
-	}
-
-	if c.MaxRetries < 0 {
-		return fmt.Errorf("max_retries cannot be negative")
+	if c.Proxy != nil {
+		p.addSection("proxy", c.Proxy.Validate())
 	}
-
-	if c.RateLimit < 0 {
-		return fmt.Errorf("rate_limit cannot be negative")
-	}
-
-	return nil
+	return p.err()
 }
 
 // GetAuthMethod returns the preferred authentication method
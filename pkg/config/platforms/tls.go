@@ -0,0 +1,132 @@
+// Package platforms provides the TLS settings of platforms
+package platforms
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// tlsVersions maps the min_version setting to TLS versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig configures the TLS connections of a platform: HTTPS requests
+// of webhook and API platforms, and implicit TLS or STARTTLS of SMTP.
+// Certificates and keys are PEM encoded, given either as files or inline,
+// where they may be secret references.
+type TLSConfig struct {
+	// CAFile or CA adds certificate authorities to the system roots, e.g.
+	// for a webhook endpoint behind a corporate CA
+	CAFile string `json:"ca_file" yaml:"ca_file"`
+	CA     string `json:"ca" yaml:"ca"`
+
+	// CertFile and KeyFile, or Cert and Key, are the client certificate
+	// presented for mutual TLS
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+	Cert     string `json:"cert" yaml:"cert"`
+	Key      string `json:"key" yaml:"key"`
+
+	// MinVersion is the lowest TLS version accepted: "1.0", "1.1", "1.2"
+	// (the default) or "1.3"
+	MinVersion string `json:"min_version" yaml:"min_version"`
+
+	// ServerName overrides the name the server certificate is checked for
+	ServerName string `json:"server_name" yaml:"server_name"`
+
+	// InsecureSkipVerify accepts any server certificate. It is meant for
+	// test endpoints with self-signed certificates only.
+	InsecureSkipVerify bool `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+}
+
+// Validate validates the TLS settings
+func (c *TLSConfig) Validate() error {
+	var p problems
+	if c.CAFile != "" && c.CA != "" {
+		p.add("ca", "ca and ca_file cannot both be set")
+	}
+	if c.CertFile != "" && c.Cert != "" {
+		p.add("cert", "cert and cert_file cannot both be set")
+	}
+	if c.KeyFile != "" && c.Key != "" {
+		p.add("key", "key and key_file cannot both be set")
+	}
+	hasCert, hasKey := c.CertFile != "" || c.Cert != "", c.KeyFile != "" || c.Key != ""
+	if hasCert != hasKey {
+		p.add("cert", "a client certificate requires both a certificate and a key")
+	}
+	if _, ok := tlsVersions[c.MinVersion]; c.MinVersion != "" && !ok {
+		p.add("min_version", "min_version must be 1.0, 1.1, 1.2 or 1.3, got %q", c.MinVersion)
+	}
+	return p.err()
+}
+
+// Build returns the TLS configuration of the settings, reading the
+// certificate files. A nil configuration returns nil, leaving the defaults
+// of the connection in place.
+func (c *TLSConfig) Build() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.MinVersion != "" {
+		config.MinVersion = tlsVersions[c.MinVersion]
+	}
+
+	ca, err := pemSetting(c.CA, c.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tls ca: %w", err)
+	}
+	if ca != nil {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("tls ca contains no PEM certificates")
+		}
+		config.RootCAs = roots
+	}
+
+	cert, err := pemSetting(c.Cert, c.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tls client certificate: %w", err)
+	}
+	key, err := pemSetting(c.Key, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tls client key: %w", err)
+	}
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config, nil
+}
+
+// pemSetting returns PEM data given inline or as a file
+func pemSetting(inline, file string) ([]byte, error) {
+	switch {
+	case inline != "":
+		return []byte(inline), nil
+	case file != "":
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
@@ -2,7 +2,8 @@
 package platforms
 
 import (
-	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -14,15 +15,63 @@ type WebhookConfig struct {
 	Headers     map[string]string `json:"headers" yaml:"headers"`
 	ContentType string            `json:"content_type" yaml:"content_type"`
 
+	// PayloadFormat is the JSON shape of request bodies: "notifyhub" (the
+	// default) for the NotifyHub payload or "alertmanager" for Prometheus
+	// Alertmanager webhook notifications
+	PayloadFormat string `json:"payload_format,omitempty" yaml:"payload_format,omitempty"`
+
+	// Template is a Go text/template over the message that renders the
+	// request body in place of the standard JSON payload, for receivers
+	// that expect their own shape, e.g. {"summary": {{json .Title}}}
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+
+	// Endpoints are named receivers with their own URL, headers and
+	// template, which webhook targets select by name, e.g.
+	// target.NewWebhook("jira")
+	Endpoints map[string]WebhookEndpoint `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+
+	// PreflightURL is a no-op or test endpoint of the receiver that
+	// Preflight checks the credentials against; empty checks url
+	PreflightURL string `json:"preflight_url,omitempty" yaml:"preflight_url,omitempty"`
+
 	// Authentication
 	AuthType string `json:"auth_type" yaml:"auth_type"` // "none", "basic", "bearer", "custom"
 	Username string `json:"username" yaml:"username"`
 	Password string `json:"password" yaml:"password"`
 	Token    string `json:"token" yaml:"token"`
 
+	// Secret signs every request with HMAC-SHA256 in the X-Signature and
+	// X-Timestamp headers, which receivers check with webhook.Verify
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+
+	// PreviousSecret is a secret being rotated out: requests are signed
+	// with it as well until every receiver has the new secret
+	PreviousSecret string `json:"previous_secret,omitempty" yaml:"previous_secret,omitempty"`
+
 	// Security settings
 	VerifySSL bool `json:"verify_ssl" yaml:"verify_ssl"`
 
+	// TLS configures the certificates and versions of TLS connections; with
+	// it verify_ssl is ignored
+	TLS *TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+
+	// Proxy overrides the global proxy for this platform
+	Proxy *ProxyConfig `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+
+	// ExpectedStatus are the status codes of a successful send; empty
+	// accepts any 2xx status
+	ExpectedStatus []int `json:"expected_status,omitempty" yaml:"expected_status,omitempty"`
+
+	// ResponseMatch is a regular expression the response body must match
+	// for a send to succeed, for receivers that report errors with a 200
+	// status, e.g. `"ok":\s*true`
+	ResponseMatch string `json:"response_match,omitempty" yaml:"response_match,omitempty"`
+
+	// RedirectPolicy is "follow" (the default) to follow up to 10
+	// redirects, "same_host" to follow only redirects to the host of the
+	// request, or "none" to judge the redirect response itself
+	RedirectPolicy string `json:"redirect_policy,omitempty" yaml:"redirect_policy,omitempty"`
+
 	// Connection settings
 	Timeout    time.Duration `json:"timeout" yaml:"timeout"`
 	Retries    int           `json:"retries" yaml:"retries"`
@@ -30,44 +79,100 @@ type WebhookConfig struct {
 	RateLimit  int           `json:"rate_limit" yaml:"rate_limit"`
 }
 
+// WebhookEndpoint is a named receiver of a webhook platform. Its empty
+// settings fall back to those of the webhook, its headers are added to
+// the webhook headers, and authentication and signing are shared.
+type WebhookEndpoint struct {
+	URL         string            `json:"url" yaml:"url"`
+	Method      string            `json:"method,omitempty" yaml:"method,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	ContentType string            `json:"content_type,omitempty" yaml:"content_type,omitempty"`
+
+	// PayloadFormat and Template work as for the webhook; alertmanager
+	// notifications name the endpoint as their receiver
+	PayloadFormat string `json:"payload_format,omitempty" yaml:"payload_format,omitempty"`
+	Template      string `json:"template,omitempty" yaml:"template,omitempty"`
+}
+
 // Validate validates the Webhook configuration
 func (c *WebhookConfig) Validate() error {
+	var p problems
 	if c.URL == "" {
-		return fmt.Errorf("url is required for Webhook platform")
+		p.add("url", "url is required for Webhook platform")
 	}
 
 	if c.Method == "" {
 		c.Method = "POST" // Default to POST
-	} else {
-		method := strings.ToUpper(c.Method)
-		validMethods := []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
-		isValid := false
-		for _, valid := range validMethods {
-			if method == valid {
-				isValid = true
-				break
-			}
+	} else if !validWebhookMethod(c.Method) {
+		p.add("method", "invalid HTTP method: %s", c.Method)
+	}
+
+	p.checkPayload("", c.PayloadFormat, c.Template)
+	names := make([]string, 0, len(c.Endpoints))
+	for name := range c.Endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		endpoint := c.Endpoints[name]
+		if endpoint.URL == "" {
+			p.add("endpoints."+name+".url", "url is required for webhook endpoint %s", name)
 		}
-		if !isValid {
-			return fmt.Errorf("invalid HTTP method: %s", c.Method)
+		if endpoint.Method != "" && !validWebhookMethod(endpoint.Method) {
+			p.add("endpoints."+name+".method", "invalid HTTP method: %s", endpoint.Method)
 		}
+		p.checkPayload("endpoints."+name+".", endpoint.PayloadFormat, endpoint.Template)
 	}
 
-	if c.Timeout < 0 {
-		return fmt.Errorf("timeout cannot be negative")
+	for _, status := range c.ExpectedStatus {
+		if status < 100 || status > 599 {
+			p.add("expected_status", "invalid HTTP status code: %d", status)
+		}
+	}
+	if c.ResponseMatch != "" {
+		if _, err := regexp.Compile(c.ResponseMatch); err != nil {
+			p.add("response_match", "invalid regular expression: %v", err)
+		}
+	}
+	switch c.RedirectPolicy {
+	case "", "follow", "same_host", "none":
+	default:
+		p.add("redirect_policy", "invalid redirect policy %q, expected follow, same_host or none", c.RedirectPolicy)
 	}
 
-	if c.Retries < 0 {
-		return fmt.Errorf("retries cannot be negative")
+	if c.PreviousSecret != "" && c.Secret == "" {
+		p.add("previous_secret", "previous_secret requires secret")
 	}
 
-	if c.MaxRetries < 0 {
-		return fmt.Errorf("max_retries cannot be negative")
+	p.checkConnection(c.Timeout, c.Retries, c.MaxRetries, c.RateLimit)
+	if c.TLS != nil {
+		p.addSection("tls", c.TLS.Validate())
+	}
+	if c.Proxy != nil {
+		p.addSection("proxy", c.Proxy.Validate())
 	}
+	return p.err()
+}
 
-	if c.RateLimit < 0 {
-		return fmt.Errorf("rate_limit cannot be negative")
+// validWebhookMethod reports whether webhooks can send with a method
+func validWebhookMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "GET", "POST", "PUT", "PATCH", "DELETE":
+		return true
 	}
+	return false
+}
 
-	return nil
+// checkPayload checks the payload format and template of a webhook or an
+// endpoint, whose field names start with prefix
+func (p *problems) checkPayload(prefix, format, template string) {
+	switch format {
+	case "", "notifyhub":
+	case "alertmanager":
+		if template != "" {
+			p.add(prefix+"payload_format", "payload_format %s cannot be combined with a template", format)
+		}
+	default:
+		p.add(prefix+"payload_format", "invalid payload format %q, expected notifyhub or alertmanager", format)
+	}
 }
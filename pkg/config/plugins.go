@@ -0,0 +1,63 @@
+// Package config provides the configuration of platform plugins
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Plugin runs the sender of a platform as a separate program, so that a
+// proprietary channel can be added without recompiling the hub; see
+// package platform/plugin for the program side. The plugin's name is the
+// platform name targets and rules refer to.
+type Plugin struct {
+	// Command is the plugin program
+	Command string `json:"command"`
+
+	// Args are the arguments of the command
+	Args []string `json:"args,omitempty"`
+
+	// Env holds "KEY=value" variables added to the plugin's environment
+	Env []string `json:"env,omitempty"`
+
+	// Settings are handed to the plugin to create its platform. They may
+	// hold secret references or encrypted values like platform sections.
+	Settings map[string]string `json:"settings,omitempty"`
+
+	// StartTimeout bounds the start of the plugin; zero uses 10s
+	StartTimeout time.Duration `json:"start_timeout,omitempty"`
+}
+
+// validatePlugins records the problems of the plugin definitions
+func (c *Config) validatePlugins(problems *ValidationErrors) {
+	for _, name := range sortedKeys(c.Plugins) {
+		plugin := c.Plugins[name]
+		field := "plugins." + name
+		switch {
+		case name == "":
+			problems.add("plugins", "MISSING_VALUE", "plugin name cannot be empty")
+			continue
+		case c.platformSection(name) != nil:
+			problems.add(field, "CONFLICT", fmt.Sprintf("plugin %s conflicts with the configured %s section", name, name))
+		case containsPlatform(c.ExternalPlatforms, name):
+			problems.add(field, "CONFLICT", fmt.Sprintf("plugin %s is also declared as an external platform", name))
+		}
+		if strings.TrimSpace(plugin.Command) == "" {
+			problems.add(field+".command", "MISSING_VALUE", "plugin command is required")
+		}
+		if plugin.StartTimeout < 0 {
+			problems.add(field+".start_timeout", "INVALID_VALUE", fmt.Sprintf("start timeout cannot be negative, got %v", plugin.StartTimeout))
+		}
+	}
+}
+
+// containsPlatform reports whether names holds a platform name
+func containsPlatform(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+// Package config provides configuration profiles for NotifyHub
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ProfileEnv is the environment variable that selects the profile of a
+// configuration file
+const ProfileEnv = "NOTIFYHUB_PROFILE"
+
+// LoadProfile is LoadFile with an explicitly selected profile.
+//
+// A configuration file may define named profiles next to its regular
+// settings. The regular settings apply to every profile; the selected
+// profile is merged over them:
+//
+//	timeout: 30s
+//	email:
+//	  host: smtp.example.com
+//	  port: 587
+//	profiles:
+//	  development:
+//	    email:
+//	      host: localhost
+//	      port: 1025
+//	  staging:
+//	    async:
+//	      enabled: true
+//	  production:
+//	    extends: staging
+//	    timeout: 10s
+//
+// Sections are merged setting by setting, while lists and plain values are
+// replaced; a null value removes an inherited setting. A profile may extend
+// one or more other profiles, which are merged first in the order listed.
+//
+// LoadFile and Parse select the profile named by the NOTIFYHUB_PROFILE
+// environment variable or, without it, by the "profile" setting of the
+// file. Without either, only the regular settings are used.
+func LoadProfile(path, profile string, opts ...Option) (*Config, error) {
+	if profile == "" {
+		return nil, fmt.Errorf("profile name cannot be empty")
+	}
+	return loadFile(path, profile, opts)
+}
+
+// selectProfile returns the settings of the selected profile merged over
+// the regular settings of a document. An explicitly selected profile and
+// one named in a document that defines profiles must exist; a profile
+// selected by the environment for a document without profiles is ignored.
+func selectProfile(doc map[string]interface{}, explicit string) (map[string]interface{}, error) {
+	raw, hasProfiles := doc["profiles"]
+	delete(doc, "profiles")
+
+	name, fromEnv := explicit, false
+	if name == "" {
+		name, fromEnv = os.Getenv(ProfileEnv), true
+	}
+	if name == "" {
+		name, _ = scalarString(doc["profile"])
+		fromEnv = false
+	}
+	if name == "" || (!hasProfiles && fromEnv) {
+		return doc, nil
+	}
+
+	profiles, ok := raw.(map[string]interface{})
+	if hasProfiles && !ok {
+		return nil, fmt.Errorf("profiles: expected a table of profiles")
+	}
+	settings, err := resolveProfile(profiles, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := removeNulls(mergeSettings(doc, settings))
+	merged["profile"] = name
+	return merged, nil
+}
+
+// resolveProfile returns the settings of a profile with the profiles it
+// extends merged in
+func resolveProfile(profiles map[string]interface{}, name string, chain []string) (map[string]interface{}, error) {
+	for _, seen := range chain {
+		if seen == name {
+			return nil, fmt.Errorf("profile %s extends itself: %s", name, strings.Join(append(chain, name), " -> "))
+		}
+	}
+	raw, ok := profiles[name]
+	if !ok && len(profiles) == 0 {
+		return nil, fmt.Errorf("profile %q is not defined, the configuration has no profiles", name)
+	}
+	if !ok {
+		return nil, fmt.Errorf("profile %q is not defined, available profiles: %s", name, strings.Join(sortedKeys(profiles), ", "))
+	}
+	profile, ok := raw.(map[string]interface{})
+	if !ok {
+		if !isNull(raw) {
+			return nil, fmt.Errorf("profiles.%s: expected a table of settings", name)
+		}
+		profile = map[string]interface{}{}
+	}
+
+	var parents []string
+	switch extends := profile["extends"].(type) {
+	case []interface{}:
+		for _, parent := range extends {
+			s, ok := scalarString(parent)
+			if !ok {
+				return nil, fmt.Errorf("profiles.%s.extends: expected profile names", name)
+			}
+			parents = append(parents, s)
+		}
+	default:
+		if isNull(extends) {
+			break
+		}
+		s, ok := scalarString(extends)
+		if !ok {
+			return nil, fmt.Errorf("profiles.%s.extends: expected a profile name", name)
+		}
+		parents = append(parents, s)
+	}
+
+	settings := map[string]interface{}{}
+	for _, parent := range parents {
+		inherited, err := resolveProfile(profiles, parent, append(chain, name))
+		if err != nil {
+			return nil, err
+		}
+		settings = mergeSettings(settings, inherited)
+	}
+
+	own := make(map[string]interface{}, len(profile))
+	for key, value := range profile {
+		if key != "extends" {
+			own[key] = value
+		}
+	}
+	return mergeSettings(settings, own), nil
+}
+
+// mergeSettings returns base with override merged over it, without
+// modifying either. Tables are merged key by key; other values replace the
+// base value, and null removes it.
+func mergeSettings(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		if isNull(value) {
+			merged[key] = nil // kept so profiles extending this one remove it too
+			continue
+		}
+		overrideTable, isTable := value.(map[string]interface{})
+		baseTable, baseIsTable := merged[key].(map[string]interface{})
+		if isTable && baseIsTable {
+			merged[key] = mergeSettings(baseTable, overrideTable)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// scalarString returns a parsed string value
+func scalarString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case plainScalar:
+		return string(v), true
+	default:
+		return "", false
+	}
+}
+
+// removeNulls drops the settings a profile removed
+func removeNulls(settings map[string]interface{}) map[string]interface{} {
+	for key, value := range settings {
+		if value == nil {
+			delete(settings, key)
+		} else if table, ok := value.(map[string]interface{}); ok {
+			settings[key] = removeNulls(table)
+		}
+	}
+	return settings
+}
+
+// isNull reports whether a parsed value is null
+func isNull(v interface{}) bool {
+	if plain, ok := v.(plainScalar); ok {
+		return plain.resolve() == nil
+	}
+	return v == nil
+}
@@ -0,0 +1,299 @@
+// Package config provides the reference documentation of configuration settings
+package config
+
+//go:generate go run gen_reference.go
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Reference output formats
+const (
+	ReferenceMarkdown = "markdown"
+	ReferenceHTML     = "html"
+)
+
+// ReferenceEnvPrefix is the environment variable prefix of the reference,
+// the one passed to FromEnv in the documentation
+const ReferenceEnvPrefix = "NOTIFYHUB"
+
+// ReferenceSetting documents one setting of configuration files
+type ReferenceSetting struct {
+	// Path of the setting in configuration files, e.g. "email.port".
+	// Entries of lists end in "[]" and keys of maps are written <name>.
+	Path string `json:"path"`
+
+	// Type of the value, e.g. "duration" or "list of strings", with the
+	// accepted values of settings with a fixed set of choices
+	Type string `json:"type"`
+
+	// Default is the value of the setting when no layer sets it
+	Default string `json:"default,omitempty"`
+
+	// Env is the environment variable read by FromEnv(ReferenceEnvPrefix),
+	// empty for the settings of list entries
+	Env string `json:"env,omitempty"`
+
+	Description string `json:"description,omitempty"`
+}
+
+// Reference returns every setting of configuration files in the order of
+// the Config fields. Describe returns the description of a setting from its
+// struct field, such as the field's doc comment; it may be nil. The
+// repository keeps a generated reference in docs/configuration.md.
+func Reference(describe func(owner reflect.Type, field reflect.StructField) string) []ReferenceSetting {
+	var defaults reflect.Value
+	if cfg, err := New(); err == nil {
+		defaults = reflect.ValueOf(*cfg)
+	}
+
+	r := referenceWalker{describe: describe}
+	r.walk(reflect.TypeOf(Config{}), defaults, "", ReferenceEnvPrefix)
+	return r.settings
+}
+
+// referenceWalker collects the settings of the Config type
+type referenceWalker struct {
+	describe func(owner reflect.Type, field reflect.StructField) string
+	settings []ReferenceSetting
+}
+
+// walk adds the settings of a struct type. Defaults holds the default
+// values of the struct, or is invalid; env is the variable prefix of the
+// struct's settings, or empty inside lists.
+func (r *referenceWalker) walk(t reflect.Type, defaults reflect.Value, path, env string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		setting := ReferenceSetting{Path: joinPath(path, name)}
+		if env != "" {
+			setting.Env = env + "_" + strings.ToUpper(name)
+		}
+		if r.describe != nil {
+			setting.Description = r.describe(t, field)
+		}
+		var value reflect.Value
+		if defaults.IsValid() {
+			value = defaults.Field(i)
+		}
+		r.add(setting, field.Type, value)
+	}
+}
+
+// add adds a setting of type ft and the settings nested in it
+func (r *referenceWalker) add(setting ReferenceSetting, ft reflect.Type, value reflect.Value) {
+	for ft.Kind() == reflect.Pointer {
+		ft = ft.Elem()
+		if value.IsValid() {
+			value = value.Elem()
+		}
+	}
+
+	switch {
+	case ft.Kind() == reflect.Struct && ft != timeType:
+		// Sections only document their settings
+		r.walk(ft, value, setting.Path, setting.Env)
+		return
+
+	case ft.Kind() == reflect.Map:
+		setting.Path += ".<name>"
+		if setting.Env != "" {
+			setting.Env += "_<NAME>"
+		}
+		if element := ft.Elem(); element.Kind() == reflect.Struct && element != timeType {
+			// A variable sets an entry of sections as a whole, in JSON
+			setting.Env = ""
+		}
+		r.add(setting, ft.Elem(), reflect.Value{})
+		return
+	}
+
+	setting.Type = referenceType(ft)
+	if values, ok := schemaEnums[strings.ReplaceAll(setting.Path, "[]", "")]; ok {
+		setting.Type += ": " + strings.Join(values, ", ")
+	}
+	if value.IsValid() && !value.IsZero() {
+		setting.Default = referenceValue(value)
+	}
+	r.settings = append(r.settings, setting)
+
+	if ft.Kind() == reflect.Slice {
+		element := ft.Elem()
+		for element.Kind() == reflect.Pointer {
+			element = element.Elem()
+		}
+		if element.Kind() == reflect.Struct && element != timeType {
+			r.walk(element, reflect.Value{}, setting.Path+"[]", "")
+		}
+	}
+}
+
+// referenceType names the type of a setting
+func referenceType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType:
+		return "duration"
+	case t == timeType:
+		return "timestamp"
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		element := referenceType(t.Elem())
+		if strings.HasSuffix(element, "s") {
+			return "list of " + element
+		}
+		return "list of " + element + "s"
+	case reflect.Map:
+		return "map of " + referenceType(t.Elem())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return "string"
+}
+
+// referenceValue formats the default value of a setting
+func referenceValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		values := make([]string, v.Len())
+		for i := range values {
+			values[i] = referenceValue(v.Index(i))
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// referenceSection is a group of settings in the written reference
+type referenceSection struct {
+	Name     string
+	Settings []ReferenceSetting
+}
+
+// referenceSections groups settings by their top-level key, with the
+// top-level values that have no nested settings under "General"
+func referenceSections(settings []ReferenceSetting) []referenceSection {
+	nested := make(map[string]bool)
+	for _, setting := range settings {
+		if i := strings.IndexAny(setting.Path, ".["); i > 0 {
+			nested[setting.Path[:i]] = true
+		}
+	}
+
+	sections := []referenceSection{{Name: "General"}}
+	index := map[string]int{"General": 0}
+	for _, setting := range settings {
+		name := setting.Path
+		if i := strings.IndexAny(name, ".["); i > 0 {
+			name = name[:i]
+		}
+		if !nested[name] {
+			name = "General"
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(sections)
+			index[name] = i
+			sections = append(sections, referenceSection{Name: name})
+		}
+		sections[i].Settings = append(sections[i].Settings, setting)
+	}
+	return sections
+}
+
+// referenceIntro introduces the written reference
+const referenceIntro = `Every setting of NotifyHub configuration files (see config.LoadFile), in
+YAML, TOML or JSON. Each setting can also be set with the environment
+variable listed next to it when the configuration is built with
+config.FromEnv("` + ReferenceEnvPrefix + `"); lists of plain values are
+comma-separated and lists of objects are given as JSON. Platform sections
+have no defaults: a zero timeout or retry count means the platform's own
+default.`
+
+// WriteReference writes the reference of settings in a format,
+// ReferenceMarkdown or ReferenceHTML
+func WriteReference(w io.Writer, settings []ReferenceSetting, format string) error {
+	sections := referenceSections(settings)
+	switch format {
+	case ReferenceMarkdown:
+		return writeMarkdownReference(w, sections)
+	case ReferenceHTML:
+		return htmlReference.Execute(w, map[string]interface{}{"Intro": referenceIntro, "Sections": sections})
+	}
+	return fmt.Errorf("unknown reference format %q", format)
+}
+
+// writeMarkdownReference writes the reference as a Markdown document
+func writeMarkdownReference(w io.Writer, sections []referenceSection) error {
+	var b strings.Builder
+	b.WriteString("# NotifyHub configuration reference\n\n")
+	b.WriteString("<!-- Code generated by gen_reference.go; DO NOT EDIT. -->\n\n")
+	b.WriteString(referenceIntro + "\n")
+	for _, section := range sections {
+		fmt.Fprintf(&b, "\n## %s\n\n", section.Name)
+		b.WriteString("| Setting | Type | Default | Environment | Description |\n")
+		b.WriteString("|---|---|---|---|---|\n")
+		for _, s := range section.Settings {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+				markdownCode(s.Path), markdownText(s.Type), markdownCode(s.Default), markdownCode(s.Env), markdownText(s.Description))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCode formats a value as Markdown code, leaving empty values empty
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+// markdownText escapes text for a Markdown table cell
+func markdownText(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "<", `&lt;`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// htmlReference writes the reference as an HTML document
+var htmlReference = template.Must(template.New("reference").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>NotifyHub configuration reference</title>
+</head>
+<body>
+<h1>NotifyHub configuration reference</h1>
+<p>{{.Intro}}</p>
+{{range .Sections}}<h2>{{.Name}}</h2>
+<table>
+<tr><th>Setting</th><th>Type</th><th>Default</th><th>Environment</th><th>Description</th></tr>
+{{range .Settings}}<tr><td><code>{{.Path}}</code></td><td>{{.Type}}</td><td>{{with .Default}}<code>{{.}}</code>{{end}}</td><td>{{with .Env}}<code>{{.}}</code>{{end}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,138 @@
+// Package config provides alternative platform endpoints in other regions
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// PrimaryRegion names the endpoint of a platform section itself
+const PrimaryRegion = "primary"
+
+// Default region failover settings
+const (
+	DefaultRegionFailureThreshold = 3
+	DefaultRegionCooldown         = 30 * time.Second
+)
+
+// Region is an alternative endpoint of a platform section in another
+// region, such as Amazon SES in eu-west-1 beside us-east-1, or Lark
+// (https://open.larksuite.com) beside Feishu. Its settings replace the
+// section's settings of the same name, e.g. {"host": "..."} for email or
+// {"base_url": "...", "app_secret": "..."} for feishu.
+//
+// Targets are sent through the first region whose patterns they match,
+// their home region, or through the section's own endpoint. Patterns are
+// matched like those of target access rules: "*.de" matches email domains,
+// "+49" phone numbers. When a region fails, the targets are sent through
+// the other regions in order, the section's own endpoint first; a region
+// failing repeatedly is skipped until its cooldown passes.
+type Region struct {
+	Name     string            `json:"name"`
+	Settings map[string]string `json:"settings"`
+	Targets  []string          `json:"targets,omitempty"` // target patterns with affinity to the region
+}
+
+// RegionFailoverConfig configures when a platform region is considered
+// down
+type RegionFailoverConfig struct {
+	// FailureThreshold is the number of consecutive failed sends after
+	// which a region is skipped; zero uses DefaultRegionFailureThreshold
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+
+	// Cooldown is how long a failed region is skipped before it is tried
+	// again; zero uses DefaultRegionCooldown
+	Cooldown time.Duration `json:"cooldown,omitempty"`
+}
+
+// Apply returns a copy of a platform section, such as *FeishuConfig, with
+// the settings of the region
+func (r Region) Apply(section interface{}) (interface{}, error) {
+	applied, err := applySettings(section, r.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("region %s: %w", r.Name, err)
+	}
+	return applied, nil
+}
+
+// Matches reports whether a target has affinity to the region
+func (r Region) Matches(t target.Target) bool {
+	return target.MatchAny(r.Targets, t)
+}
+
+// validateRegions checks the alternative regions of each platform
+func (c *Config) validateRegions(problems *ValidationErrors) {
+	for _, platform := range sortedKeys(c.Regions) {
+		field := "regions." + platform
+		section := c.platformSection(platform)
+		if section == nil {
+			problems.add(field, "MISSING_VALUE", fmt.Sprintf("regions require a configured %s section", platform))
+			continue
+		}
+
+		fields := credentialFields(reflect.TypeOf(section).Elem())
+		seen := map[string]bool{PrimaryRegion: true}
+		for i, region := range c.Regions[platform] {
+			field := fmt.Sprintf("%s[%d]", field, i)
+			switch {
+			case region.Name == "":
+				problems.add(field+".name", "MISSING_VALUE", "region name cannot be empty")
+			case seen[region.Name]:
+				problems.add(field+".name", "CONFLICT", fmt.Sprintf("region name %q is already used", region.Name))
+			}
+			seen[region.Name] = true
+
+			if len(region.Settings) == 0 {
+				problems.add(field+".settings", "MISSING_VALUE", "regions must set at least one setting")
+			}
+			for _, name := range sortedKeys(region.Settings) {
+				if _, ok := fields[name]; !ok {
+					problems.add(field+".settings."+name, "INVALID_VALUE", fmt.Sprintf("%s has no text setting %q", platform, name))
+				}
+			}
+			if err := (target.AccessRule{Allow: region.Targets}).Validate(); err != nil {
+				problems.add(field+".targets", "INVALID_VALUE", err.Error())
+			}
+		}
+	}
+
+	if c.RegionFailover.FailureThreshold < 0 {
+		problems.add("region_failover.failure_threshold", "INVALID_VALUE", "failure threshold cannot be negative")
+	}
+	if c.RegionFailover.Cooldown < 0 {
+		problems.add("region_failover.cooldown", "INVALID_VALUE", "cooldown cannot be negative")
+	}
+}
+
+// maskRegions hides the credentials among the settings of a list of
+// regions in an effective configuration
+func maskRegions(value interface{}) interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+	masked := make([]interface{}, len(list))
+	for i, item := range list {
+		region, ok := item.(map[string]interface{})
+		if !ok {
+			masked[i] = item
+			continue
+		}
+		copied := make(map[string]interface{}, len(region))
+		for key, v := range region {
+			copied[key] = v
+		}
+		if settings, ok := region["settings"].(map[string]interface{}); ok {
+			hidden := make(map[string]interface{}, len(settings))
+			for name, v := range settings {
+				hidden[name] = maskSetting(name, v)
+			}
+			copied["settings"] = hidden
+		}
+		masked[i] = copied
+	}
+	return masked
+}
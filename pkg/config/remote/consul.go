@@ -0,0 +1,123 @@
+// Package remote provides a Consul KV configuration backend
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConsulConfig configures a Consul backend
+type ConsulConfig struct {
+	Address string        // defaults to CONSUL_HTTP_ADDR, then "http://127.0.0.1:8500"
+	Token   string        // ACL token, defaults to CONSUL_HTTP_TOKEN
+	Key     string        // e.g. "notifyhub/config"
+	Wait    time.Duration // longest blocking query, 5 minutes by default
+	Client  *http.Client
+}
+
+// Consul reads a configuration document from the Consul KV store, watching
+// it with blocking queries
+type Consul struct {
+	address string
+	token   string
+	key     string
+	wait    time.Duration
+	client  *http.Client
+}
+
+// NewConsul creates a Consul backend
+func NewConsul(cfg ConsulConfig) (*Consul, error) {
+	if cfg.Key == "" {
+		return nil, fmt.Errorf("consul key is required")
+	}
+	if cfg.Address == "" {
+		cfg.Address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if cfg.Address == "" {
+		cfg.Address = "http://127.0.0.1:8500"
+	}
+	if !strings.Contains(cfg.Address, "://") {
+		cfg.Address = "http://" + cfg.Address
+	}
+	if cfg.Token == "" {
+		cfg.Token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	if cfg.Wait <= 0 {
+		cfg.Wait = 5 * time.Minute
+	}
+	if cfg.Client == nil {
+		// No client timeout: blocking queries are bounded by the wait time
+		cfg.Client = &http.Client{}
+	}
+	return &Consul{
+		address: strings.TrimRight(cfg.Address, "/"),
+		token:   cfg.Token,
+		key:     strings.Trim(cfg.Key, "/"),
+		wait:    cfg.Wait,
+		client:  cfg.Client,
+	}, nil
+}
+
+// Fetch implements Backend
+func (c *Consul) Fetch(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	query := url.Values{}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", c.wait.String())
+	}
+	endpoint := c.address + "/v1/kv/" + escapeKey(c.key) + "?" + query.Encode()
+
+	// Consul adds up to wait/16 of jitter to a blocking query
+	ctx, cancel := context.WithTimeout(ctx, c.wait+c.wait/16+10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, 0, fmt.Errorf("consul key %s does not exist", c.key)
+	default:
+		return nil, 0, fmt.Errorf("consul returned status %d", resp.StatusCode)
+	}
+
+	var entries []struct {
+		Value       []byte // base64 in the response
+		ModifyIndex uint64
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("invalid consul response: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, 0, fmt.Errorf("consul key %s does not exist", c.key)
+	}
+
+	return entries[0].Value, entries[0].ModifyIndex, nil
+}
+
+// escapeKey escapes each segment of a key
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
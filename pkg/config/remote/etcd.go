@@ -0,0 +1,174 @@
+// Package remote provides an etcd configuration backend
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EtcdConfig configures an etcd backend
+type EtcdConfig struct {
+	Endpoint string        // defaults to "http://127.0.0.1:2379"
+	Key      string        // e.g. "/notifyhub/config"
+	Wait     time.Duration // longest watch before fetching again, 5 minutes by default
+	Client   *http.Client  // e.g. with client certificates for a TLS endpoint
+}
+
+// Etcd reads a configuration document from etcd through its v3 JSON API,
+// watching the key for changes
+type Etcd struct {
+	endpoint string
+	key      string
+	wait     time.Duration
+	client   *http.Client
+}
+
+// NewEtcd creates an etcd backend
+func NewEtcd(cfg EtcdConfig) (*Etcd, error) {
+	if cfg.Key == "" {
+		return nil, fmt.Errorf("etcd key is required")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "http://127.0.0.1:2379"
+	}
+	if cfg.Wait <= 0 {
+		cfg.Wait = 5 * time.Minute
+	}
+	if cfg.Client == nil {
+		// No client timeout: watches are bounded by the wait time
+		cfg.Client = &http.Client{}
+	}
+	return &Etcd{
+		endpoint: strings.TrimRight(cfg.Endpoint, "/"),
+		key:      cfg.Key,
+		wait:     cfg.Wait,
+		client:   cfg.Client,
+	}, nil
+}
+
+// etcdKeyValue is a key-value pair in etcd JSON responses; 64-bit numbers
+// are encoded as strings and bytes as base64
+type etcdKeyValue struct {
+	Value       []byte `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+// Fetch implements Backend. With an index it watches the key for a change
+// after that revision.
+func (e *Etcd) Fetch(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	if index == 0 {
+		return e.get(ctx)
+	}
+	return e.watch(ctx, index)
+}
+
+// get reads the current value of the key
+func (e *Etcd) get(ctx context.Context) ([]byte, uint64, error) {
+	resp, err := e.post(ctx, "/v3/kv/range", map[string]interface{}{"key": []byte(e.key)})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Kvs []etcdKeyValue `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, 0, fmt.Errorf("invalid etcd response: %w", err)
+	}
+	if len(body.Kvs) == 0 {
+		return nil, 0, fmt.Errorf("etcd key %s does not exist", e.key)
+	}
+	return revision(body.Kvs[0])
+}
+
+// watch waits for the next change of the key after a revision
+func (e *Etcd) watch(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	watchCtx, cancel := context.WithTimeout(ctx, e.wait)
+	defer cancel()
+
+	resp, err := e.post(watchCtx, "/v3/watch", map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            []byte(e.key),
+			"start_revision": strconv.FormatUint(index+1, 10),
+		},
+	})
+	if err != nil {
+		if watchCtx.Err() != nil && ctx.Err() == nil {
+			return nil, index, nil
+		}
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Result struct {
+				Canceled bool `json:"canceled"`
+				Events   []struct {
+					Type string       `json:"type"`
+					Kv   etcdKeyValue `json:"kv"`
+				} `json:"events"`
+			} `json:"result"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if watchCtx.Err() != nil && ctx.Err() == nil {
+				return nil, index, nil // no change within the wait time
+			}
+			return nil, 0, fmt.Errorf("etcd watch failed: %w", err)
+		}
+		if message.Result.Canceled {
+			// Typically the revision was compacted; read the current value
+			return e.get(ctx)
+		}
+
+		events := message.Result.Events
+		if len(events) == 0 {
+			continue
+		}
+		last := events[len(events)-1]
+		if last.Type == "DELETE" {
+			return nil, 0, fmt.Errorf("etcd key %s was deleted", e.key)
+		}
+		return revision(last.Kv)
+	}
+}
+
+// post sends a request to the JSON API
+func (e *Etcd) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+path, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("etcd request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd returned status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// revision returns the value and modification revision of a key-value pair
+func revision(kv etcdKeyValue) ([]byte, uint64, error) {
+	modified, err := strconv.ParseUint(kv.ModRevision, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid etcd revision %q", kv.ModRevision)
+	}
+	return kv.Value, modified, nil
+}
@@ -0,0 +1,105 @@
+// Package remote loads NotifyHub configuration from a key-value store such
+// as Consul or etcd and watches it for changes.
+//
+// The key holds a configuration document in JSON, YAML or TOML, in the
+// format config.LoadFile reads. A Watcher applies every new version of the
+// document through a reload function, usually Client.ReloadConfig, so a
+// fleet of clients sharing the key converges on the new settings without a
+// redeploy:
+//
+//	backend, _ := remote.NewConsul(remote.ConsulConfig{Key: "notifyhub/config"})
+//	cfg, index, _ := remote.Load(ctx, backend, config.FormatYAML)
+//	client, _ := notifyhub.NewClient(cfg)
+//	watcher := &remote.Watcher{Backend: backend, Format: config.FormatYAML, Reload: client.ReloadConfig}
+//	go watcher.Run(ctx, index)
+package remote
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// Backend reads a configuration document from a key-value store
+type Backend interface {
+	// Fetch returns the document and its modification index. With a
+	// non-zero index it blocks until the document has changed since that
+	// index, returning the same index if the store's wait time elapses
+	// first.
+	Fetch(ctx context.Context, index uint64) (data []byte, modified uint64, err error)
+}
+
+// Load reads and parses the current configuration document. The returned
+// index is passed to Watcher.Run to watch for later changes.
+func Load(ctx context.Context, backend Backend, format string, opts ...config.Option) (*config.Config, uint64, error) {
+	data, index, err := backend.Fetch(ctx, 0)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch remote configuration: %w", err)
+	}
+	cfg, err := config.Parse(data, format, opts...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid remote configuration: %w", err)
+	}
+	return cfg, index, nil
+}
+
+// Watcher applies changes of a remote configuration document
+type Watcher struct {
+	Backend Backend
+	Format  string          // config.FormatJSON, FormatYAML or FormatTOML
+	Options []config.Option // applied to every version of the document
+	Reload  func(*config.Config) error
+
+	// RetryInterval is the delay after a failed fetch, 5 seconds by default
+	RetryInterval time.Duration
+	Logger        logger.Logger // defaults to logger.Discard
+}
+
+// Run watches the document until ctx is done, starting after the version
+// at index; zero applies the current version first. A version that cannot
+// be parsed or is rejected by Reload is logged and skipped, so the running
+// configuration stays in place until a valid version is stored.
+func (w *Watcher) Run(ctx context.Context, index uint64) error {
+	log := w.Logger
+	if log == nil {
+		log = logger.Discard
+	}
+	retry := w.RetryInterval
+	if retry <= 0 {
+		retry = 5 * time.Second
+	}
+
+	for {
+		data, modified, err := w.Backend.Fetch(ctx, index)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Warn("Failed to fetch remote configuration", "index", index, "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retry):
+			}
+			continue
+		}
+		if modified == index {
+			continue
+		}
+		index = modified
+
+		cfg, err := config.Parse(data, w.Format, w.Options...)
+		if err != nil {
+			log.Error("Ignoring invalid remote configuration", "index", index, "error", err)
+			continue
+		}
+		if err := w.Reload(cfg); err != nil {
+			log.Error("Remote configuration was rejected", "index", index, "error", err)
+			continue
+		}
+		log.Info("Applied remote configuration", "index", index)
+	}
+}
@@ -0,0 +1,215 @@
+package remote
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+)
+
+// kvStore is an in-memory key with a modification index that fake servers
+// block on
+type kvStore struct {
+	mu      sync.Mutex
+	value   string
+	index   uint64
+	changed chan struct{}
+}
+
+func newKVStore(value string) *kvStore {
+	return &kvStore{value: value, index: 1, changed: make(chan struct{})}
+}
+
+func (s *kvStore) set(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value = value
+	s.index++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// waitAfter blocks until the index exceeds after or the wait elapses
+func (s *kvStore) waitAfter(after uint64, wait time.Duration) (string, uint64) {
+	deadline := time.After(wait)
+	for {
+		s.mu.Lock()
+		value, index, changed := s.value, s.index, s.changed
+		s.mu.Unlock()
+		if index > after {
+			return value, index
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return value, index
+		}
+	}
+}
+
+func newConsulServer(t *testing.T, store *kvStore) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/notifyhub/config" || r.Header.Get("X-Consul-Token") != "acl" {
+			http.NotFound(w, r)
+			return
+		}
+		after, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+		value, index := store.waitAfter(after, wait)
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"Key": "notifyhub/config", "Value": []byte(value), "ModifyIndex": index},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newEtcdServer(t *testing.T, store *kvStore, wait time.Duration) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kv := func(value string, index uint64) map[string]interface{} {
+			return map[string]interface{}{
+				"key":          base64.StdEncoding.EncodeToString([]byte("/notifyhub/config")),
+				"value":        base64.StdEncoding.EncodeToString([]byte(value)),
+				"mod_revision": strconv.FormatUint(index, 10),
+			}
+		}
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			value, index := store.waitAfter(0, 0)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"kvs": []interface{}{kv(value, index)}})
+		case "/v3/watch":
+			var req struct {
+				CreateRequest struct {
+					StartRevision string `json:"start_revision"`
+				} `json:"create_request"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			start, _ := strconv.ParseUint(req.CreateRequest.StartRevision, 10, 64)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"created": true}})
+			w.(http.Flusher).Flush()
+
+			value, index := store.waitAfter(start-1, wait)
+			if index < start {
+				<-r.Context().Done() // no change: the client gives up
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{
+				"events": []interface{}{map[string]interface{}{"kv": kv(value, index)}},
+			}})
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWatcher(t *testing.T) {
+	const initial = `{"webhook": {"url": "https://hooks.example.com/v1"}}`
+
+	backends := map[string]func(t *testing.T, store *kvStore) Backend{
+		"consul": func(t *testing.T, store *kvStore) Backend {
+			backend, err := NewConsul(ConsulConfig{
+				Address: newConsulServer(t, store).URL,
+				Token:   "acl",
+				Key:     "/notifyhub/config",
+				Wait:    100 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return backend
+		},
+		"etcd": func(t *testing.T, store *kvStore) Backend {
+			backend, err := NewEtcd(EtcdConfig{
+				Endpoint: newEtcdServer(t, store, time.Second).URL,
+				Key:      "/notifyhub/config",
+				Wait:     100 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return backend
+		},
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newKVStore(initial)
+			backend := newBackend(t, store)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			cfg, index, err := Load(ctx, backend, config.FormatJSON)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.Webhook == nil || cfg.Webhook.URL != "https://hooks.example.com/v1" || index != 1 {
+				t.Fatalf("Load() = %+v, index %d", cfg.Webhook, index)
+			}
+
+			reloaded := make(chan string, 4)
+			watcher := &Watcher{
+				Backend: backend,
+				Format:  config.FormatJSON,
+				Reload: func(cfg *config.Config) error {
+					reloaded <- cfg.Webhook.URL
+					return nil
+				},
+				RetryInterval: 10 * time.Millisecond,
+			}
+			done := make(chan error, 1)
+			go func() { done <- watcher.Run(ctx, index) }()
+
+			// Let a wait time elapse without changes, then store an invalid
+			// version, which is skipped, and a valid one
+			time.Sleep(150 * time.Millisecond)
+			store.set(`{"webhook": {"url": ""}}`)
+			time.Sleep(50 * time.Millisecond)
+			store.set(`{"webhook": {"url": "https://hooks.example.com/v2"}}`)
+
+			select {
+			case url := <-reloaded:
+				if url != "https://hooks.example.com/v2" {
+					t.Errorf("reloaded %s", url)
+				}
+			case <-ctx.Done():
+				t.Fatal("configuration was not reloaded")
+			}
+
+			cancel()
+			if err := <-done; err != context.Canceled {
+				t.Errorf("Run() error = %v", err)
+			}
+			if len(reloaded) != 0 {
+				t.Errorf("unexpected reload of %s", <-reloaded)
+			}
+		})
+	}
+}
+
+func TestNewConsul_Errors(t *testing.T) {
+	if _, err := NewConsul(ConsulConfig{}); err == nil {
+		t.Error("NewConsul() without a key error = nil")
+	}
+
+	store := newKVStore("{}")
+	backend, _ := NewConsul(ConsulConfig{Address: newConsulServer(t, store).URL, Key: "missing"})
+	if _, _, err := backend.Fetch(context.Background(), 0); err == nil {
+		t.Error("Fetch() of a missing key error = nil")
+	}
+	if _, _, err := Load(context.Background(), backend, config.FormatJSON); err == nil || err.Error() != "failed to fetch remote configuration: consul key missing does not exist" {
+		t.Errorf("Load() error = %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+// Package config provides the retry policy of sends
+package config
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// DefaultRetryBackoffFactor is the backoff factor of retry policies that
+// set none
+const DefaultRetryBackoffFactor = 2.0
+
+// RetryPolicy retries the sends to a platform that failed with a retryable
+// error before their results are marked failed, waiting InitialInterval
+// before the first retry and BackoffFactor times longer before each next
+// one, up to MaxInterval.
+//
+// Without a classifier, sends are retried unless they failed because a
+// target is invalid or the platform panicked.
+type RetryPolicy struct {
+	// MaxAttempts is the number of sends, the first included; zero or one
+	// sends once. A message's own max retries (see message.SetMaxRetries)
+	// replace it.
+	MaxAttempts int `json:"max_attempts"`
+
+	// InitialInterval is the delay before the first retry; zero retries
+	// at once
+	InitialInterval time.Duration `json:"initial_interval,omitempty"`
+
+	// MaxInterval caps the delays; zero leaves them uncapped
+	MaxInterval time.Duration `json:"max_interval,omitempty"`
+
+	// BackoffFactor multiplies the delay after each retry; zero uses 2,
+	// one keeps the delays constant
+	BackoffFactor float64 `json:"backoff_factor,omitempty"`
+
+	// Jitter varies the delays randomly by up to this fraction of them,
+	// e.g. 0.2 for ±20%, so that clients failing together do not retry
+	// together
+	Jitter float64 `json:"jitter,omitempty"`
+
+	// RetryableErrorClassifier reports whether a send that failed with err
+	// is retried, replacing the default classification
+	RetryableErrorClassifier func(err error) bool `json:"-"`
+}
+
+// Delay returns the delay before a retry, the first being retry 1
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	if p.InitialInterval <= 0 {
+		return 0
+	}
+	factor := p.BackoffFactor
+	if factor <= 0 {
+		factor = DefaultRetryBackoffFactor
+	}
+	if retry < 1 {
+		retry = 1
+	}
+
+	delay := float64(p.InitialInterval) * math.Pow(factor, float64(retry-1))
+	if p.MaxInterval > 0 && delay > float64(p.MaxInterval) {
+		delay = float64(p.MaxInterval)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(delay)
+}
+
+// validate adds the problems of a retry policy at field
+func (p RetryPolicy) validate(problems *ValidationErrors, field string) {
+	if p.MaxAttempts < 0 {
+		problems.add(field+".max_attempts", "INVALID_VALUE", fmt.Sprintf("max attempts cannot be negative, got %d", p.MaxAttempts))
+	}
+	if p.InitialInterval < 0 {
+		problems.add(field+".initial_interval", "INVALID_VALUE", fmt.Sprintf("initial interval cannot be negative, got %v", p.InitialInterval))
+	}
+	if p.MaxInterval < 0 {
+		problems.add(field+".max_interval", "INVALID_VALUE", fmt.Sprintf("max interval cannot be negative, got %v", p.MaxInterval))
+	}
+	if p.BackoffFactor != 0 && p.BackoffFactor < 1 {
+		problems.add(field+".backoff_factor", "INVALID_VALUE", fmt.Sprintf("backoff factor must be at least 1, got %v", p.BackoffFactor))
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		problems.add(field+".jitter", "INVALID_VALUE", fmt.Sprintf("jitter must be between 0 and 1, got %v", p.Jitter))
+	}
+}
+
+// validateRetry adds the problems of the retry policies
+func (c *Config) validateRetry(problems *ValidationErrors, known map[string]bool) {
+	if c.Retry != nil {
+		c.Retry.validate(problems, "retry")
+	}
+	for _, name := range sortedKeys(c.PlatformRetry) {
+		field := "platform_retry." + name
+		c.PlatformRetry[name].validate(problems, field)
+		problems.checkPlatform(field, name, known)
+	}
+}
+
+// RetryPolicyFor returns the retry policy of a platform: its override in
+// PlatformRetry, Retry, or else the max retries and retry interval of the
+// send defaults, retried at a constant interval
+func (c *Config) RetryPolicyFor(platform string) RetryPolicy {
+	if policy, ok := c.PlatformRetry[platform]; ok {
+		return policy
+	}
+	if c.Retry != nil {
+		return *c.Retry
+	}
+	return RetryPolicy{
+		MaxAttempts:     c.Defaults.MaxRetries + 1,
+		InitialInterval: c.Defaults.RetryInterval,
+		BackoffFactor:   1,
+	}
+}
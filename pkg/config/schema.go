@@ -0,0 +1,158 @@
+// Package config provides the JSON schema of configuration files
+package config
+
+//go:generate go run gen_schema.go
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+)
+
+// SchemaURI is the JSON schema dialect of JSONSchema
+const SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches the duration strings of configuration files
+const durationPattern = `^-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$|^0$`
+
+// schemaEnums lists the values of settings with a fixed set of choices
+var schemaEnums = map[string][]string{
+	"defaults.format":           {"text", "markdown", "html"},
+	"defaults.format_fallback":  {FormatFallbackNone, FormatFallbackText, FormatFallbackReject},
+	"target_rate_limits.policy": {ratelimit.PolicyDrop, ratelimit.PolicyWait, ratelimit.PolicyDefer},
+	"quotas.policy":             {ratelimit.PolicyWait, ratelimit.PolicyDrop, ratelimit.PolicyDefer},
+}
+
+// JSONSchema returns a JSON schema of configuration files, for CI checks
+// and editors. For the YAML extension of VS Code, point a file at a copy
+// of the schema with a comment on its first line:
+//
+//	# yaml-language-server: $schema=./notifyhub.schema.json
+//
+// The schema accepts ${NAME} references wherever a number or boolean is
+// expected and rejects unknown settings, like LoadFile. The repository
+// keeps a generated copy in docs/notifyhub.schema.json.
+func JSONSchema() ([]byte, error) {
+	profile := schemaObject(reflect.TypeOf(Config{}), "", true)
+	profile["properties"].(map[string]interface{})["extends"] = map[string]interface{}{
+		"description": "profiles merged before this one",
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+
+	schema := schemaObject(reflect.TypeOf(Config{}), "", false)
+	schema["$schema"] = SchemaURI
+	schema["title"] = "NotifyHub configuration"
+	schema["properties"].(map[string]interface{})["profiles"] = map[string]interface{}{
+		"description":          "named profiles merged over the regular settings, see LoadProfile",
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"$ref": "#/$defs/profile"},
+	}
+	schema["$defs"] = map[string]interface{}{
+		"interpolation": map[string]interface{}{
+			"description": "an environment variable reference such as ${NAME}",
+			"type":        "string",
+			"pattern":     `\$\{[^}]+\}`,
+		},
+		"profile": profile,
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// schemaObject returns the schema of a struct of settings. In profiles
+// every setting may be null to remove an inherited value.
+func schemaObject(t reflect.Type, path string, nullable bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := schemaFor(field.Type, joinPath(path, name), nullable)
+		if nullable {
+			property = orNull(property)
+		}
+		properties[name] = property
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// schemaFor returns the schema of a setting of type t
+func schemaFor(t reflect.Type, path string, nullable bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == durationType {
+		return map[string]interface{}{
+			"description": "a duration such as 30s or 5m, or nanoseconds",
+			"anyOf": []interface{}{
+				map[string]interface{}{"type": "string", "pattern": durationPattern},
+				map[string]interface{}{"type": "integer"},
+			},
+		}
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return schemaObject(t, path, nullable)
+
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), path, false)}
+
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), path, false)}
+
+	case reflect.Bool:
+		return interpolated(map[string]interface{}{"type": "boolean"})
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return interpolated(map[string]interface{}{"type": "integer"})
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return interpolated(map[string]interface{}{"type": "integer", "minimum": 0})
+
+	case reflect.Float32, reflect.Float64:
+		return interpolated(map[string]interface{}{"type": "number"})
+
+	case reflect.String:
+		if values, ok := schemaEnums[path]; ok {
+			return interpolated(map[string]interface{}{"enum": values})
+		}
+		return map[string]interface{}{"type": "string"}
+	}
+	return map[string]interface{}{}
+}
+
+// orNull also accepts null for a setting
+func orNull(schema map[string]interface{}) map[string]interface{} {
+	null := map[string]interface{}{"type": "null"}
+	if choices, ok := schema["anyOf"].([]interface{}); ok {
+		schema["anyOf"] = append(choices, null)
+		return schema
+	}
+	return map[string]interface{}{"anyOf": []interface{}{schema, null}}
+}
+
+// interpolated also accepts an environment variable reference for a
+// setting, which is converted to the setting's type when the file is read
+func interpolated(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"anyOf": []interface{}{schema, map[string]interface{}{"$ref": "#/$defs/interpolation"}}}
+}
@@ -0,0 +1,90 @@
+// Package config provides typed platform settings for NotifyHub
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// PlatformConfig returns the typed configuration of a platform from the
+// settings its factory received: a T such as FeishuConfig, a pointer to
+// one, or the legacy map form, which is decoded with DecodeSettings.
+// Platform factories use it so that passing a struct by value, or a map,
+// works instead of failing a type assertion at runtime.
+func PlatformConfig[T any](platform string, settings interface{}) (*T, error) {
+	switch s := settings.(type) {
+	case *T:
+		if s == nil {
+			return nil, fmt.Errorf("%s configuration cannot be nil", platform)
+		}
+		return s, nil
+	case T:
+		return &s, nil
+	case map[string]interface{}:
+		cfg := new(T)
+		if err := DecodeSettings(s, cfg); err != nil {
+			return nil, fmt.Errorf("invalid %s configuration: %w", platform, err)
+		}
+		return cfg, nil
+	default:
+		return nil, fmt.Errorf("invalid %s configuration type %T, expected %T", platform, settings, new(T))
+	}
+}
+
+// DecodeSettings decodes platform settings given as a map, keyed by the
+// JSON names of the settings, into the typed configuration dst points to.
+// Values are converted the way LoadFile converts them: durations may be
+// strings like "30s", and numbers and booleans may be strings. Unknown
+// keys are an error rather than being ignored.
+func DecodeSettings(settings map[string]interface{}, dst interface{}) error {
+	target := reflect.TypeOf(dst)
+	if target == nil || target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("DecodeSettings requires a pointer to a struct, got %T", dst)
+	}
+
+	// Round-trip through JSON so typed values such as map[string]string
+	// and []string take the generic form coerce expects
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return err
+	}
+
+	converted, err := coerce(doc, target.Elem(), "")
+	if err != nil {
+		return err
+	}
+	if encoded, err = json.Marshal(converted); err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, dst)
+}
+
+// WithPlatformSettings configures a platform by name from its typed
+// configuration or from the legacy map form:
+//
+//	config.WithPlatformSettings("email", map[string]interface{}{
+//		"host": "smtp.example.com", "port": 587, "from": "noreply@example.com",
+//	})
+func WithPlatformSettings(platform string, settings interface{}) Option {
+	return func(c *Config) error {
+		var err error
+		switch platform {
+		case "feishu":
+			c.Feishu, err = PlatformConfig[FeishuConfig](platform, settings)
+		case "email":
+			c.Email, err = PlatformConfig[EmailConfig](platform, settings)
+		case "webhook":
+			c.Webhook, err = PlatformConfig[WebhookConfig](platform, settings)
+		case "slack":
+			c.Slack, err = PlatformConfig[SlackConfig](platform, settings)
+		default:
+			err = fmt.Errorf("unknown platform %q", platform)
+		}
+		return err
+	}
+}
@@ -0,0 +1,449 @@
+// Package config provides a TOML reader for configuration files
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tomlParser reads TOML documents: tables, arrays of tables, dotted and
+// quoted keys, basic and literal strings (including multi-line ones),
+// integers, floats, booleans, arrays and inline tables. Dates and times are
+// kept as strings.
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+// parseTOML parses a TOML document into maps, slices and scalars
+func parseTOML(data []byte) (interface{}, error) {
+	p := &tomlParser{src: strings.ReplaceAll(string(data), "\r\n", "\n"), line: 1}
+	root := make(map[string]interface{})
+	current := root
+
+	for {
+		p.skipSpaceAndComments(true)
+		if p.pos >= len(p.src) {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseTableHeader(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpaceAndComments(false)
+		if p.pos < len(p.src) && p.peek() != '\n' {
+			return nil, p.errorf("expected end of line")
+		}
+	}
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("toml: line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) peek() byte {
+	return p.src[p.pos]
+}
+
+// skipSpaceAndComments moves past spaces and comments, and past newlines
+// when multiline is set
+func (p *tomlParser) skipSpaceAndComments(multiline bool) {
+	for p.pos < len(p.src) {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.peek() != '\n' {
+				p.pos++
+			}
+		case c == '\n' && multiline:
+			p.pos++
+			p.line++
+		default:
+			return
+		}
+	}
+}
+
+// parseTableHeader parses "[table]" or "[[array]]" and returns the table
+// that following key/value pairs belong to
+func (p *tomlParser) parseTableHeader(root map[string]interface{}) (map[string]interface{}, error) {
+	array := strings.HasPrefix(p.src[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, p.errorf("expected %q after table name", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.descend(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+
+	if array {
+		existing, ok := parent[last]
+		if !ok {
+			existing = make([]interface{}, 0)
+		}
+		list, ok := existing.([]interface{})
+		if !ok {
+			return nil, p.errorf("%s is not an array of tables", strings.Join(keys, "."))
+		}
+		table := make(map[string]interface{})
+		parent[last] = append(list, table)
+		return table, nil
+	}
+
+	switch existing := parent[last].(type) {
+	case nil:
+		table := make(map[string]interface{})
+		parent[last] = table
+		return table, nil
+	case map[string]interface{}:
+		return existing, nil
+	default:
+		return nil, p.errorf("%s is already defined", strings.Join(keys, "."))
+	}
+}
+
+// descend returns the table at a key path, creating missing tables. A path
+// through an array of tables continues in its last element.
+func (p *tomlParser) descend(table map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for i, key := range keys {
+		switch next := table[key].(type) {
+		case nil:
+			child := make(map[string]interface{})
+			table[key] = child
+			table = child
+		case map[string]interface{}:
+			table = next
+		case []interface{}:
+			if len(next) == 0 {
+				return nil, p.errorf("%s is not a table", strings.Join(keys[:i+1], "."))
+			}
+			last, ok := next[len(next)-1].(map[string]interface{})
+			if !ok {
+				return nil, p.errorf("%s is not a table", strings.Join(keys[:i+1], "."))
+			}
+			table = last
+		default:
+			return nil, p.errorf("%s is not a table", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return table, nil
+}
+
+// parseKeyValue parses "key = value" into a table
+func (p *tomlParser) parseKeyValue(table map[string]interface{}) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.pos >= len(p.src) || p.peek() != '=' {
+		return p.errorf("expected \"=\" after key %s", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipSpaceAndComments(false)
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	parent, err := p.descend(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, exists := parent[last]; exists {
+		return p.errorf("duplicate key %s", strings.Join(keys, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// parseKey parses a dotted key of bare and quoted parts
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpaceAndComments(false)
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("expected a key")
+		}
+
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			key, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		default:
+			start := p.pos
+			for p.pos < len(p.src) && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected a key")
+			}
+			keys = append(keys, p.src[start:p.pos])
+		}
+
+		p.skipSpaceAndComments(false)
+		if p.pos >= len(p.src) || p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue parses a string, number, boolean, array or inline table
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("expected a value")
+	}
+
+	switch c := p.peek(); c {
+	case '"', '\'':
+		return p.parseString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(",]} \t\n#", rune(p.peek())) {
+		p.pos++
+	}
+	// dates may contain a space between the date and the time
+	if p.pos+1 < len(p.src) && p.peek() == ' ' && isDate(p.src[start:p.pos]) && isDigit(p.src[p.pos+1]) {
+		p.pos++
+		for p.pos < len(p.src) && !strings.ContainsRune(",]} \t\n#", rune(p.peek())) {
+			p.pos++
+		}
+	}
+	token := p.src[start:p.pos]
+
+	switch {
+	case token == "true":
+		return true, nil
+	case token == "false":
+		return false, nil
+	case token == "":
+		return nil, p.errorf("expected a value")
+	}
+	if isDate(token) {
+		return token, nil
+	}
+
+	digits := strings.ReplaceAll(token, "_", "")
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' && strings.ContainsRune("xob", rune(digits[1])) {
+		base = 0
+	}
+	if n, err := strconv.ParseInt(digits, base, 64); err == nil {
+		return n, nil
+	}
+	switch digits {
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return nil, p.errorf("infinity and NaN are not supported")
+	}
+	if f, err := strconv.ParseFloat(digits, 64); err == nil {
+		return f, nil
+	}
+	return nil, p.errorf("invalid value %q", token)
+}
+
+// parseArray parses "[ value, ... ]", which may span lines
+func (p *tomlParser) parseArray() (interface{}, error) {
+	p.pos++
+	list := make([]interface{}, 0)
+	for {
+		p.skipSpaceAndComments(true)
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return list, nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+
+		p.skipSpaceAndComments(true)
+		if p.pos < len(p.src) && p.peek() == ',' {
+			p.pos++
+		} else if p.pos >= len(p.src) || p.peek() != ']' {
+			return nil, p.errorf("expected \",\" or \"]\" in array")
+		}
+	}
+}
+
+// parseInlineTable parses "{ key = value, ... }" on a single line
+func (p *tomlParser) parseInlineTable() (interface{}, error) {
+	p.pos++
+	table := make(map[string]interface{})
+	for {
+		p.skipSpaceAndComments(false)
+		if p.pos >= len(p.src) || p.peek() == '\n' {
+			return nil, p.errorf("unterminated inline table")
+		}
+		if p.peek() == '}' && len(table) == 0 {
+			p.pos++
+			return table, nil
+		}
+
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+
+		p.skipSpaceAndComments(false)
+		switch {
+		case p.pos < len(p.src) && p.peek() == ',':
+			p.pos++
+		case p.pos < len(p.src) && p.peek() == '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, p.errorf("expected \",\" or \"}\" in inline table")
+		}
+	}
+}
+
+// parseString parses basic, literal and multi-line strings
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.peek()
+	delimiter := string(quote)
+	if strings.HasPrefix(p.src[p.pos:], strings.Repeat(delimiter, 3)) {
+		delimiter = strings.Repeat(delimiter, 3)
+	}
+	multiline := len(delimiter) == 3
+	p.pos += len(delimiter)
+	if multiline && strings.HasPrefix(p.src[p.pos:], "\n") {
+		// a newline right after the opening delimiter is trimmed
+		p.pos++
+		p.line++
+	}
+
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		if strings.HasPrefix(p.src[p.pos:], delimiter) {
+			p.pos += len(delimiter)
+			return b.String(), nil
+		}
+
+		c := p.peek()
+		switch {
+		case c == '\n' && !multiline:
+			return "", p.errorf("unterminated string")
+		case c == '\n':
+			p.line++
+			b.WriteByte(c)
+			p.pos++
+		case c == '\\' && quote == '"':
+			if err := p.parseEscape(&b, multiline); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseEscape decodes an escape sequence in a basic string
+func (p *tomlParser) parseEscape(b *strings.Builder, multiline bool) error {
+	p.pos++
+	if p.pos >= len(p.src) {
+		return p.errorf("unterminated string")
+	}
+
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			return p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil {
+			return p.errorf("invalid unicode escape")
+		}
+		b.WriteRune(rune(code))
+		p.pos += size
+	case ' ', '\t', '\n':
+		if !multiline {
+			return p.errorf("invalid escape \\%c", c)
+		}
+		// a line ending backslash trims the following whitespace
+		p.pos--
+		for p.pos < len(p.src) && strings.ContainsRune(" \t\n", rune(p.peek())) {
+			if p.peek() == '\n' {
+				p.line++
+			}
+			p.pos++
+		}
+	default:
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+// isDate reports whether a token looks like a TOML date or time
+func isDate(token string) bool {
+	return len(token) >= 8 && isDigit(token[0]) && (len(token) > 4 && token[4] == '-' || token[2] == ':')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -2,9 +2,11 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,6 +28,92 @@ type ValidationError struct {
 	Message string `json:"message"`
 }
 
+// Error implements the error interface
+func (e ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists every problem Config.Validate found. Use
+// errors.As to inspect the individual problems and their field paths.
+type ValidationErrors []ValidationError
+
+// Error implements the error interface
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	problems := make([]string, len(e))
+	for i, problem := range e {
+		problems[i] = problem.Error()
+	}
+	return fmt.Sprintf("configuration has %d problems: %s", len(e), strings.Join(problems, "; "))
+}
+
+// add records a problem
+func (e *ValidationErrors) add(field, code, message string) {
+	*e = append(*e, ValidationError{Field: field, Code: code, Message: message})
+}
+
+// addPlatform records the problems a platform section's Validate returned,
+// with field paths below the section
+func (e *ValidationErrors) addPlatform(section string, err error) {
+	if err == nil {
+		return
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		field := section
+		var fieldErr *platforms.FieldError
+		if errors.As(err, &fieldErr) {
+			field += "." + fieldErr.Field
+		}
+		e.add(field, "INVALID_SETTING", err.Error())
+	}
+}
+
+// builtinPlatforms are the platform names targets and rules may refer to
+// without a configuration section of their own
+var builtinPlatforms = map[string]bool{
+	"feishu":   true,
+	"email":    true,
+	"webhook":  true,
+	"slack":    true,
+	"sms":      true,
+	"dingtalk": true,
+}
+
+// knownPlatforms returns the built-in platform names and the external
+// platforms and plugins declared in the configuration
+func (c *Config) knownPlatforms() map[string]bool {
+	known := make(map[string]bool, len(builtinPlatforms)+len(c.ExternalPlatforms))
+	for name := range builtinPlatforms {
+		known[name] = true
+	}
+	for _, name := range c.ExternalPlatforms {
+		known[name] = true
+	}
+	for name := range c.Plugins {
+		known[name] = true
+	}
+	return known
+}
+
+// checkPlatform records a problem when a rule or target names a platform
+// NotifyHub does not know, which usually is a typo that would make the
+// rule never apply
+func (e *ValidationErrors) checkPlatform(field, name string, known map[string]bool) {
+	if name == "" || known[name] {
+		return
+	}
+	e.add(field, "UNKNOWN_PLATFORM", fmt.Sprintf("unknown platform %q, expected one of %s", name, strings.Join(sortedKeys(known), ", ")))
+}
+
 // ValidationWarning represents a validation warning
 type ValidationWarning struct {
 	Field   string `json:"field"`
@@ -386,3 +474,14 @@ func IsValid(cfg *Config) bool {
 	result := ValidateQuick(cfg)
 	return result.Valid
 }
+
+// sortedKeys returns the keys of a map in order, so problems are reported
+// in the same order every time
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
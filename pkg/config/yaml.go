@@ -0,0 +1,502 @@
+// Package config provides a YAML reader for configuration files
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// plainScalar is an unquoted YAML scalar. Its type is decided by the
+// setting it is decoded into, so "587" can fill both a port and a password.
+type plainScalar string
+
+// resolve types the scalar by the YAML core schema, for free-form values
+func (s plainScalar) resolve() interface{} {
+	switch text := string(s); text {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	default:
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+		return text
+	}
+}
+
+// yamlLine is a line of a YAML document
+type yamlLine struct {
+	number int
+	indent int
+	raw    string // the line without its indentation
+}
+
+// yamlParser reads the subset of YAML used by configuration files: block
+// mappings and sequences, plain and quoted scalars, flow sequences and
+// mappings, literal (|) and folded (>) block scalars, and comments.
+// Anchors, aliases, tags and multiple documents are not supported.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses a YAML document into maps, slices and scalars
+func parseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+	for i, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "\t") {
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("yaml: line %d: tabs are not allowed for indentation", i+1)
+			}
+		}
+		trimmed := strings.TrimLeft(line, " ")
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(line) - len(trimmed), raw: trimmed})
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) && strings.TrimSpace(stripComment(p.lines[p.pos].raw)) == "---" {
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+
+	doc, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected content")
+	}
+	return doc, nil
+}
+
+// text returns the current line without its comment
+func (p *yamlParser) text() string {
+	return strings.TrimRight(stripComment(p.lines[p.pos].raw), " \t")
+}
+
+// skipBlank moves past empty and comment-only lines
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && strings.TrimSpace(stripComment(p.lines[p.pos].raw)) == "" {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	line := len(p.lines)
+	if p.pos < len(p.lines) {
+		line = p.lines[p.pos].number
+	}
+	return fmt.Errorf("yaml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// parseBlock parses the mapping or sequence starting at the current line
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if isSequenceItem(p.text()) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+// parseMapping parses block mapping entries at an indentation
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent < indent {
+			return m, nil
+		}
+		if p.lines[p.pos].indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		text := p.text()
+		if isSequenceItem(text) {
+			return nil, p.errorf("unexpected list item in a mapping")
+		}
+
+		key, rest, ok := splitMappingEntry(text)
+		if !ok {
+			return nil, p.errorf("expected \"key: value\"")
+		}
+		key, err := unquoteKey(key)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if _, exists := m[key]; exists {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+
+		value, err := p.parseValue(indent, rest, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+}
+
+// parseSequence parses block sequence items at an indentation
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	list := make([]interface{}, 0)
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent < indent {
+			return list, nil
+		}
+		if p.lines[p.pos].indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		text := p.text()
+		if !isSequenceItem(text) {
+			return list, nil
+		}
+
+		rest := strings.TrimLeft(text[1:], " ")
+		offset := len(text) - len(rest)
+		_, _, isMapping := splitMappingEntry(rest)
+		if rest != "" && (isMapping || isSequenceItem(rest)) {
+			// "- key: value" and "- - item" open a nested block whose first
+			// line starts after the dash
+			line := &p.lines[p.pos]
+			line.indent += offset
+			line.raw = line.raw[offset:]
+			value, err := p.parseBlock(line.indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+			continue
+		}
+
+		value, err := p.parseValue(indent, rest, false)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+}
+
+// parseValue parses the value following a mapping key or list dash on the
+// current line, which may continue in an indented block
+func (p *yamlParser) parseValue(indent int, rest string, inMapping bool) (interface{}, error) {
+	line := p.lines[p.pos]
+	p.pos++
+
+	switch {
+	case rest == "":
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return nil, nil
+		}
+		next := p.lines[p.pos]
+		if next.indent > indent {
+			return p.parseBlock(next.indent)
+		}
+		// a sequence may sit at its key's indentation
+		if inMapping && next.indent == indent && isSequenceItem(p.text()) {
+			return p.parseSequence(indent)
+		}
+		return nil, nil
+	case rest[0] == '|' || rest[0] == '>':
+		return p.parseBlockScalar(indent, rest)
+	case rest[0] == '[' || rest[0] == '{':
+		value, remaining, err := parseFlow(rest)
+		if err == nil && strings.TrimSpace(remaining) != "" {
+			err = fmt.Errorf("unexpected %q after flow collection", remaining)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: %v", line.number, err)
+		}
+		return value, nil
+	default:
+		value, err := parseScalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: %v", line.number, err)
+		}
+		return value, nil
+	}
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) block scalar
+func (p *yamlParser) parseBlockScalar(indent int, header string) (interface{}, error) {
+	folded := header[0] == '>'
+	chomp := strings.TrimSpace(header[1:])
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, fmt.Errorf("yaml: line %d: unsupported block scalar header %q", p.lines[p.pos-1].number, header)
+	}
+
+	var body []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line.raw) == "" {
+			body = append(body, "")
+			p.pos++
+			continue
+		}
+		if line.indent <= indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = line.indent
+		}
+		if line.indent < blockIndent {
+			break
+		}
+		body = append(body, strings.Repeat(" ", line.indent-blockIndent)+line.raw)
+		p.pos++
+	}
+
+	trailing := 0
+	for len(body) > 0 && body[len(body)-1] == "" {
+		body = body[:len(body)-1]
+		trailing++
+	}
+
+	var text string
+	if folded {
+		var b strings.Builder
+		for i, line := range body {
+			switch {
+			case i == 0:
+			case line == "" || body[i-1] == "":
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(body, "\n")
+	}
+
+	switch {
+	case len(body) == 0:
+		return "", nil
+	case chomp == "-":
+		return text, nil
+	case chomp == "+":
+		return text + strings.Repeat("\n", trailing+1), nil
+	default:
+		return text + "\n", nil
+	}
+}
+
+// isSequenceItem reports whether a line starts a block sequence item
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitMappingEntry splits "key: value" at the first colon followed by a
+// space or the end of the line, outside quotes
+func splitMappingEntry(text string) (key, value string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	end := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end = closingQuote(text)
+		if end < 0 {
+			return "", "", false
+		}
+	}
+	for i := end; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// unquoteKey returns a mapping key without its quotes
+func unquoteKey(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	if key[0] != '"' && key[0] != '\'' {
+		return key, nil
+	}
+	value, err := parseScalar(key)
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+// parseScalar parses a quoted or plain scalar
+func parseScalar(text string) (interface{}, error) {
+	switch text[0] {
+	case '"', '\'':
+		end := closingQuote(text)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string %s", text)
+		}
+		if strings.TrimSpace(text[end+1:]) != "" {
+			return nil, fmt.Errorf("unexpected %q after string", text[end+1:])
+		}
+		return unquote(text[:end+1])
+	case '&', '*', '!':
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported")
+	}
+	return plainScalar(text), nil
+}
+
+// closingQuote returns the index of the quote closing the string that
+// starts text, or -1
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote decodes a single- or double-quoted string
+func unquote(quoted string) (string, error) {
+	body := quoted[1 : len(quoted)-1]
+	if quoted[0] == '\'' {
+		return strings.ReplaceAll(body, "''", "'"), nil
+	}
+	value, err := strconv.Unquote(`"` + body + `"`)
+	if err != nil {
+		return "", fmt.Errorf("invalid escape in %s", quoted)
+	}
+	return value, nil
+}
+
+// stripComment removes a trailing "# comment" outside quotes
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:-", rune(line[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseFlow parses a flow sequence ("[a, b]") or mapping ("{a: 1}") and
+// returns the text following it
+func parseFlow(text string) (interface{}, string, error) {
+	text = strings.TrimLeft(text, " ")
+	if text == "" {
+		return nil, "", fmt.Errorf("unexpected end of flow collection")
+	}
+
+	switch text[0] {
+	case '[':
+		list := make([]interface{}, 0)
+		rest := strings.TrimLeft(text[1:], " ")
+		for {
+			if strings.HasPrefix(rest, "]") {
+				return list, rest[1:], nil
+			}
+			value, remaining, err := parseFlow(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			list = append(list, value)
+			if rest, err = flowSeparator(remaining, ']'); err != nil {
+				return nil, "", err
+			}
+		}
+	case '{':
+		m := make(map[string]interface{})
+		rest := strings.TrimLeft(text[1:], " ")
+		for {
+			if strings.HasPrefix(rest, "}") {
+				return m, rest[1:], nil
+			}
+			rawKey, remaining, err := flowToken(rest, ":")
+			if err != nil {
+				return nil, "", err
+			}
+			if !strings.HasPrefix(remaining, ":") {
+				return nil, "", fmt.Errorf("expected \":\" after key %q", rawKey)
+			}
+			key, err := unquoteKey(rawKey)
+			if err != nil {
+				return nil, "", err
+			}
+			value, remaining, err := parseFlow(remaining[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			m[key] = value
+			if rest, err = flowSeparator(remaining, '}'); err != nil {
+				return nil, "", err
+			}
+		}
+	default:
+		token, remaining, err := flowToken(text, ",]}")
+		if err != nil {
+			return nil, "", err
+		}
+		value, err := parseScalar(token)
+		return value, remaining, err
+	}
+}
+
+// flowSeparator consumes the comma between flow items, leaving a closing
+// bracket in place
+func flowSeparator(text string, closing byte) (string, error) {
+	text = strings.TrimLeft(text, " ")
+	switch {
+	case strings.HasPrefix(text, ","):
+		return strings.TrimLeft(text[1:], " "), nil
+	case text != "" && text[0] == closing:
+		return text, nil
+	default:
+		return "", fmt.Errorf("expected \",\" or %q in flow collection", closing)
+	}
+}
+
+// flowToken reads a scalar inside a flow collection up to one of the
+// terminator characters
+func flowToken(text, terminators string) (string, string, error) {
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		end := closingQuote(text)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", text)
+		}
+		return text[:end+1], strings.TrimLeft(text[end+1:], " "), nil
+	}
+	end := strings.IndexAny(text, terminators)
+	if end < 0 {
+		return "", "", fmt.Errorf("unterminated flow collection")
+	}
+	token := strings.TrimSpace(text[:end])
+	if token == "" {
+		return "", "", fmt.Errorf("empty value in flow collection")
+	}
+	return token, text[end:], nil
+}
@@ -0,0 +1,360 @@
+// Package contact provides contact and group directory support for NotifyHub
+package contact
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// Contact represents a person that can be reached on one or more platforms
+type Contact struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name,omitempty"`
+	Email     string            `json:"email,omitempty"`
+	Phone     string            `json:"phone,omitempty"`
+	Channels  map[string]string `json:"channels,omitempty"`  // platform -> address (e.g. "feishu": "ou_xxx")
+	Platforms []string          `json:"platforms,omitempty"` // preferred platform order
+	Timezone  string            `json:"timezone,omitempty"`  // IANA time zone, e.g. "Asia/Shanghai"
+	Tags      []string          `json:"tags,omitempty"`      // e.g. "role:sre", "region:eu"; selected with tag queries
+}
+
+// Group represents a named set of members
+// Members may be contact IDs, explicit targets ("email:ops@example.com")
+// or references to other groups ("group:sre", "team:payments")
+type Group struct {
+	Name     string   `json:"name"`
+	Members  []string `json:"members"`
+	Platform string   `json:"platform,omitempty"` // optional platform override for all members
+}
+
+// Address returns the contact address for a platform
+func (c *Contact) Address(platform string) string {
+	switch platform {
+	case target.PlatformEmail:
+		if c.Email != "" {
+			return c.Email
+		}
+	case "sms":
+		if c.Phone != "" {
+			return c.Phone
+		}
+	}
+	return c.Channels[platform]
+}
+
+// AvailablePlatforms returns the platforms the contact can be reached on,
+// preferred platforms first
+func (c *Contact) AvailablePlatforms() []string {
+	platforms := make([]string, 0, len(c.Channels)+2)
+	seen := make(map[string]bool)
+
+	add := func(p string) {
+		if p == "" || seen[p] || c.Address(p) == "" {
+			return
+		}
+		seen[p] = true
+		platforms = append(platforms, p)
+	}
+
+	for _, p := range c.Platforms {
+		add(p)
+	}
+	add(target.PlatformEmail)
+	add("sms")
+
+	// Remaining channels in stable order
+	names := make([]string, 0, len(c.Channels))
+	for p := range c.Channels {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	for _, p := range names {
+		add(p)
+	}
+
+	return platforms
+}
+
+// Target builds a target for the contact on the given platform
+func (c *Contact) Target(platform string) (target.Target, bool) {
+	address := c.Address(platform)
+	if address == "" {
+		return target.Target{}, false
+	}
+	return TargetFor(platform, address).WithTimezone(c.Timezone), true
+}
+
+// TargetFor builds a target for an address on a platform using the
+// target type conventions of the built-in platforms
+func TargetFor(platform, address string) target.Target {
+	switch platform {
+	case target.PlatformEmail:
+		return target.NewEmail(address)
+	case "sms":
+		return target.New(target.TargetTypePhone, address, "sms")
+	case target.PlatformWebhook:
+		return target.NewWebhook(address)
+	default:
+		return target.New(target.TargetTypeUser, address, platform)
+	}
+}
+
+// Directory provides read access to contacts and groups
+type Directory interface {
+	// GetContact returns a contact by ID
+	GetContact(ctx context.Context, id string) (*Contact, error)
+
+	// GetGroup returns a group by name
+	GetGroup(ctx context.Context, name string) (*Group, error)
+}
+
+// AddressLookup is implemented by directories that can find the contact
+// owning an address. It links a person's identities across platforms, so
+// that an email address and a Feishu ID are known to be the same person.
+type AddressLookup interface {
+	// FindContact returns the contact with the given address on a platform
+	FindContact(ctx context.Context, platform, address string) (*Contact, error)
+}
+
+// ContactLister is implemented by directories that can enumerate their
+// contacts. Tag query targets are resolved against it.
+type ContactLister interface {
+	ListContacts(ctx context.Context) ([]*Contact, error)
+}
+
+// Store is a writable contact directory
+type Store interface {
+	Directory
+
+	// PutContact creates or replaces a contact
+	PutContact(ctx context.Context, c *Contact) error
+
+	// PutGroup creates or replaces a group
+	PutGroup(ctx context.Context, g *Group) error
+
+	// DeleteContact removes a contact
+	DeleteContact(ctx context.Context, id string) error
+
+	// DeleteGroup removes a group
+	DeleteGroup(ctx context.Context, name string) error
+
+	// ListContacts returns all contacts
+	ListContacts(ctx context.Context) ([]*Contact, error)
+
+	// ListGroups returns all groups
+	ListGroups(ctx context.Context) ([]*Group, error)
+}
+
+// MemoryStore implements Store in memory
+type MemoryStore struct {
+	contacts map[string]*Contact
+	groups   map[string]*Group
+	mu       sync.RWMutex
+}
+
+// NewMemoryStore creates a new in-memory contact store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		contacts: make(map[string]*Contact),
+		groups:   make(map[string]*Group),
+	}
+}
+
+// GetContact returns a contact by ID
+func (s *MemoryStore) GetContact(ctx context.Context, id string) (*Contact, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, exists := s.contacts[id]
+	if !exists {
+		return nil, errors.Newf(errors.ErrNotFound, "contact %s not found", id)
+	}
+	return c, nil
+}
+
+// GetGroup returns a group by name
+func (s *MemoryStore) GetGroup(ctx context.Context, name string) (*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, exists := s.groups[name]
+	if !exists {
+		return nil, errors.Newf(errors.ErrNotFound, "group %s not found", name)
+	}
+	return g, nil
+}
+
+// FindContact returns the contact with the given address on a platform.
+// Email addresses are compared case-insensitively.
+func (s *MemoryStore) FindContact(ctx context.Context, platform, address string) (*Contact, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.contacts {
+		if sameAddress(platform, c.Address(platform), address) {
+			return c, nil
+		}
+	}
+	return nil, errors.Newf(errors.ErrNotFound, "no contact with %s address %s", platform, address)
+}
+
+// sameAddress compares two addresses on a platform
+func sameAddress(platform, a, b string) bool {
+	if a == "" {
+		return false
+	}
+	if platform == target.PlatformEmail {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// PutContact creates or replaces a contact
+func (s *MemoryStore) PutContact(ctx context.Context, c *Contact) error {
+	if c == nil || c.ID == "" {
+		return errors.New(errors.ErrInvalidTarget, "contact ID cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contacts[c.ID] = c
+	return nil
+}
+
+// PutGroup creates or replaces a group
+func (s *MemoryStore) PutGroup(ctx context.Context, g *Group) error {
+	if g == nil || g.Name == "" {
+		return errors.New(errors.ErrInvalidTarget, "group name cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups[g.Name] = g
+	return nil
+}
+
+// DeleteContact removes a contact
+func (s *MemoryStore) DeleteContact(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.contacts, id)
+	return nil
+}
+
+// DeleteGroup removes a group
+func (s *MemoryStore) DeleteGroup(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.groups, name)
+	return nil
+}
+
+// ListContacts returns all contacts sorted by ID
+func (s *MemoryStore) ListContacts(ctx context.Context) ([]*Contact, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	contacts := make([]*Contact, 0, len(s.contacts))
+	for _, c := range s.contacts {
+		contacts = append(contacts, c)
+	}
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].ID < contacts[j].ID })
+	return contacts, nil
+}
+
+// ListGroups returns all groups sorted by name
+func (s *MemoryStore) ListGroups(ctx context.Context) ([]*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := make([]*Group, 0, len(s.groups))
+	for _, g := range s.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+// MultiDirectory queries several directories in order and returns the first match
+type MultiDirectory []Directory
+
+// GetContact returns the contact from the first directory that has it
+func (m MultiDirectory) GetContact(ctx context.Context, id string) (*Contact, error) {
+	for _, dir := range m {
+		c, err := dir.GetContact(ctx, id)
+		if err == nil {
+			return c, nil
+		}
+		if !IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, errors.Newf(errors.ErrNotFound, "contact %s not found", id)
+}
+
+// FindContact returns the contact from the first directory that supports
+// address lookups and has one for the address
+func (m MultiDirectory) FindContact(ctx context.Context, platform, address string) (*Contact, error) {
+	for _, dir := range m {
+		lookup, ok := dir.(AddressLookup)
+		if !ok {
+			continue
+		}
+		c, err := lookup.FindContact(ctx, platform, address)
+		if err == nil {
+			return c, nil
+		}
+		if !IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, errors.Newf(errors.ErrNotFound, "no contact with %s address %s", platform, address)
+}
+
+// GetGroup returns the group from the first directory that has it
+func (m MultiDirectory) GetGroup(ctx context.Context, name string) (*Group, error) {
+	for _, dir := range m {
+		g, err := dir.GetGroup(ctx, name)
+		if err == nil {
+			return g, nil
+		}
+		if !IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, errors.Newf(errors.ErrNotFound, "group %s not found", name)
+}
+
+// ListContacts returns the contacts of every directory that can list them.
+// A contact ID found in several directories is taken from the first.
+func (m MultiDirectory) ListContacts(ctx context.Context) ([]*Contact, error) {
+	var contacts []*Contact
+	seen := make(map[string]bool)
+	for _, d := range m {
+		lister, ok := d.(ContactLister)
+		if !ok {
+			continue
+		}
+		listed, err := lister.ListContacts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range listed {
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				contacts = append(contacts, c)
+			}
+		}
+	}
+	return contacts, nil
+}
+
+// IsNotFound reports whether err indicates a missing contact or group
+func IsNotFound(err error) bool {
+	return err != nil && errors.GetErrorCode(err) == errors.ErrNotFound
+}
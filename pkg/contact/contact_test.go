@@ -0,0 +1,579 @@
+package contact
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kart-io/notifyhub/pkg/pii"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+func newTestStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	contacts := []*Contact{
+		{ID: "alice", Email: "alice@example.com", Channels: map[string]string{"feishu": "ou_alice"}, Platforms: []string{"feishu", "email"}},
+		{ID: "bob", Email: "bob@example.com", Phone: "+8613800138000"},
+		{ID: "carol", Phone: "+8613800138001"},
+	}
+	for _, c := range contacts {
+		if err := store.PutContact(ctx, c); err != nil {
+			t.Fatalf("PutContact() error = %v", err)
+		}
+	}
+
+	groups := []*Group{
+		{Name: "sre", Members: []string{"alice", "bob"}},
+		{Name: "payments", Members: []string{"group:sre", "carol", "email:oncall@example.com"}},
+		{Name: "loop-a", Members: []string{"alice", "group:loop-b"}},
+		{Name: "loop-b", Members: []string{"team:loop-a"}},
+		{Name: "ghosts", Members: []string{"nobody"}},
+	}
+	for _, g := range groups {
+		if err := store.PutGroup(ctx, g); err != nil {
+			t.Fatalf("PutGroup() error = %v", err)
+		}
+	}
+	return store
+}
+
+func TestContact_AvailablePlatforms(t *testing.T) {
+	c := &Contact{
+		ID:        "alice",
+		Email:     "alice@example.com",
+		Phone:     "+8613800138000",
+		Channels:  map[string]string{"slack": "U123", "feishu": "ou_alice"},
+		Platforms: []string{"slack"},
+	}
+
+	got := strings.Join(c.AvailablePlatforms(), ",")
+	want := "slack,email,sms,feishu"
+	if got != want {
+		t.Errorf("AvailablePlatforms() = %v, want %v", got, want)
+	}
+}
+
+func TestExpander_ExpandTarget(t *testing.T) {
+	store := newTestStore(t)
+	expander := NewExpander(store)
+	ctx := context.Background()
+
+	expansion, err := expander.ExpandTarget(ctx, target.NewTeam("payments"))
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+
+	var got []string
+	for _, tgt := range expansion.Targets {
+		got = append(got, tgt.String())
+	}
+	want := []string{
+		"feishu:user:ou_alice",
+		"email:email:bob@example.com",
+		"sms:phone:+8613800138001",
+		"email:email:oncall@example.com",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ExpandTarget() targets = %v, want %v", got, want)
+	}
+}
+
+func TestExpander_PlatformPreference(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	// feishu is not configured on the hub, so alice falls back to email
+	expander := NewExpander(store, WithPlatformFilter(func(p string) bool { return p != "feishu" }))
+	expansion, err := expander.ExpandTarget(ctx, target.NewTeam("sre"))
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if len(expansion.Targets) != 2 || expansion.Targets[0].Value != "alice@example.com" {
+		t.Errorf("ExpandTarget() targets = %v, want alice via email", expansion.Targets)
+	}
+
+	// an explicit platform on the group target overrides member preference
+	expander = NewExpander(store)
+	expansion, err = expander.ExpandTarget(ctx, target.New(target.TargetTypeGroup, "sre", "sms"))
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if len(expansion.Targets) != 2 || expansion.Targets[1].Value != "+8613800138000" {
+		t.Errorf("ExpandTarget() targets = %v, want bob via sms", expansion.Targets)
+	}
+}
+
+func TestExpander_CycleDetection(t *testing.T) {
+	expander := NewExpander(newTestStore(t))
+
+	_, err := expander.ExpandTarget(context.Background(), target.NewTeam("loop-a"))
+	if err == nil {
+		t.Fatal("ExpandTarget() expected cycle error")
+	}
+	if !strings.Contains(err.Error(), "loop-a -> loop-b -> loop-a") {
+		t.Errorf("ExpandTarget() error = %v, want cycle path", err)
+	}
+}
+
+func TestExpander_UnresolvedAndPassthrough(t *testing.T) {
+	expander := NewExpander(newTestStore(t))
+	ctx := context.Background()
+
+	expansion, err := expander.ExpandTarget(ctx, target.NewTeam("ghosts"))
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if len(expansion.Targets) != 0 || len(expansion.Unresolved) != 1 {
+		t.Errorf("ExpandTarget() = %+v, want one unresolved member", expansion)
+	}
+
+	if _, err := expander.ExpandTarget(ctx, target.NewTeam("missing")); err == nil {
+		t.Error("ExpandTarget() expected error for unknown team")
+	}
+
+	// platform-bound groups that the directory doesn't know are sent as-is
+	chat := target.NewFeishuGroup("oc_chat")
+	expansion, err = expander.ExpandTarget(ctx, chat)
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if len(expansion.Targets) != 1 || expansion.Targets[0] != chat {
+		t.Errorf("ExpandTarget() = %v, want passthrough", expansion.Targets)
+	}
+
+	// so are groups without a platform, which routing resolves as before
+	plain := target.New(target.TargetTypeGroup, "all-hands", "")
+	if expander.IsExpandable(ctx, plain) {
+		t.Error("IsExpandable() = true for a group the directory doesn't know")
+	}
+	expansion, err = expander.ExpandTarget(ctx, plain)
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if len(expansion.Targets) != 1 || expansion.Targets[0] != plain {
+		t.Errorf("ExpandTarget() = %v, want passthrough", expansion.Targets)
+	}
+	if !expander.IsExpandable(ctx, target.New(target.TargetTypeGroup, "sre", "")) {
+		t.Error("IsExpandable() = false for a directory group")
+	}
+}
+
+func TestMultiDirectory(t *testing.T) {
+	ctx := context.Background()
+	first := NewMemoryStore()
+	second := newTestStore(t)
+	_ = first.PutGroup(ctx, &Group{Name: "sre", Members: []string{"carol"}})
+
+	dir := MultiDirectory{first, second}
+	g, err := dir.GetGroup(ctx, "sre")
+	if err != nil || g.Members[0] != "carol" {
+		t.Errorf("GetGroup() = %v, %v, want first directory's group", g, err)
+	}
+	if _, err := dir.GetContact(ctx, "alice"); err != nil {
+		t.Errorf("GetContact() error = %v", err)
+	}
+	if _, err := dir.GetContact(ctx, "nobody"); !IsNotFound(err) {
+		t.Errorf("GetContact() error = %v, want not found", err)
+	}
+
+	lookups := []struct {
+		platform, address, want string
+	}{
+		{"email", "Alice@Example.com", "alice"},
+		{"feishu", "ou_alice", "alice"},
+		{"sms", "+8613800138000", "bob"},
+		{"feishu", "OU_ALICE", ""},
+	}
+	for _, tt := range lookups {
+		c, err := dir.FindContact(ctx, tt.platform, tt.address)
+		if tt.want == "" {
+			if !IsNotFound(err) {
+				t.Errorf("FindContact(%s, %s) error = %v, want not found", tt.platform, tt.address, err)
+			}
+			continue
+		}
+		if err != nil || c.ID != tt.want {
+			t.Errorf("FindContact(%s, %s) = %v, %v, want %s", tt.platform, tt.address, c, err, tt.want)
+		}
+	}
+}
+
+type staticOnCall map[string][]string
+
+func (s staticOnCall) OnCall(ctx context.Context, schedule string) ([]string, error) {
+	return s[schedule], nil
+}
+
+func TestExpander_OnCall(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	_ = store.PutGroup(ctx, &Group{Name: "escalation", Members: []string{"oncall:payments", "carol"}})
+
+	onCall := staticOnCall{"payments": {"bob", "pager@example.com"}, "quiet": nil}
+	expander := NewExpander(store, WithOnCall(onCall))
+
+	expansion, err := expander.ExpandTarget(ctx, target.NewOnCall("payments"))
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if len(expansion.Targets) != 2 || expansion.Targets[0].Value != "bob@example.com" || expansion.Targets[1].Value != "pager@example.com" {
+		t.Errorf("ExpandTarget() = %v, want bob and pager", expansion.Targets)
+	}
+
+	expansion, err = expander.ExpandTarget(ctx, target.NewTeam("escalation"))
+	if err != nil || len(expansion.Targets) != 3 {
+		t.Errorf("ExpandTarget() = %v, %v, want on-call members plus carol", expansion, err)
+	}
+
+	expansion, err = expander.ExpandTarget(ctx, target.NewOnCall("quiet"))
+	if err != nil || len(expansion.Unresolved) != 1 {
+		t.Errorf("ExpandTarget() = %+v, %v, want nobody on call", expansion, err)
+	}
+
+	if _, err := NewExpander(store).ExpandTarget(ctx, target.NewOnCall("payments")); err == nil {
+		t.Error("ExpandTarget() expected error without on-call resolver")
+	}
+}
+
+type staticLists map[string][]string
+
+func (s staticLists) Members(ctx context.Context, list string) ([]string, error) {
+	return s[list], nil
+}
+
+func TestExpander_List(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	_ = store.PutGroup(ctx, &Group{Name: "launch", Members: []string{"list:product-x", "carol"}})
+
+	lists := staticLists{"product-x": {"alice", "email:fan@example.com", "alice"}}
+	expander := NewExpander(store, WithListProvider(lists))
+
+	expansion, err := expander.ExpandTarget(ctx, target.NewList("product-x"))
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if len(expansion.Targets) != 2 || expansion.Targets[0].Value != "ou_alice" || expansion.Targets[1].Value != "fan@example.com" {
+		t.Errorf("ExpandTarget() = %v, want alice and fan once each", expansion.Targets)
+	}
+
+	expansion, err = expander.ExpandTarget(ctx, target.NewTeam("launch"))
+	if err != nil || len(expansion.Targets) != 3 {
+		t.Errorf("ExpandTarget() = %v, %v, want list members plus carol", expansion, err)
+	}
+
+	expansion, err = expander.ExpandTarget(ctx, target.NewList("empty"))
+	if err != nil || len(expansion.Targets) != 0 || len(expansion.Unresolved) != 0 {
+		t.Errorf("ExpandTarget() = %+v, %v, want no targets", expansion, err)
+	}
+
+	if _, err := NewExpander(store).ExpandTarget(ctx, target.NewList("product-x")); err == nil {
+		t.Error("ExpandTarget() expected error without list provider")
+	}
+}
+
+func TestExpander_ContactTarget(t *testing.T) {
+	expander := NewExpander(newTestStore(t))
+	ctx := context.Background()
+
+	expansion, err := expander.ExpandTarget(ctx, target.NewContact("alice"))
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if len(expansion.Targets) != 1 || expansion.Targets[0].Value != "ou_alice" {
+		t.Fatalf("ExpandTarget() = %v, want alice via feishu", expansion.Targets)
+	}
+	if c := expansion.Contact(expansion.Targets[0]); c == nil || c.ID != "alice" {
+		t.Errorf("Contact() = %v, want alice", c)
+	}
+
+	if _, err := expander.ExpandTarget(ctx, target.NewContact("nobody")); err == nil {
+		t.Error("ExpandTarget() expected error for unknown contact")
+	}
+}
+
+func TestImporter_Contacts(t *testing.T) {
+	ctx := context.Background()
+	csvData := strings.Join([]string{
+		"id,name,email,phone,timezone,platforms,feishu",
+		"alice,Alice,alice@Example.COM,,Asia/Shanghai,feishu;email,ou_alice",
+		"bob,Bob,,138 0013 8000,,,",
+		",Nobody,nobody@example.com,,,,",
+		"carol,Carol,not-an-email,,,,",
+		"dave,Dave,,,Mars/Olympus,,",
+		"erin,Erin,,,,,",
+		"alice,Alice Again,alice2@example.com,,,,",
+	}, "\n")
+
+	store := NewMemoryStore()
+	importer, err := NewImporter(store, ImportOptions{DefaultRegion: "CN"})
+	if err != nil {
+		t.Fatalf("NewImporter() error = %v", err)
+	}
+
+	report, err := importer.ImportContactsCSV(ctx, strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ImportContactsCSV() error = %v", err)
+	}
+	if report.Created != 2 || report.Accepted() != 2 {
+		t.Errorf("report = %+v, want 2 created", report)
+	}
+
+	wantRejected := []RejectedRow{
+		{Row: 4, Reason: "contact ID is required"},
+		{Row: 5, ID: "carol", Reason: "invalid email not-an-email"},
+		{Row: 6, ID: "dave", Reason: "invalid timezone Mars/Olympus"},
+		{Row: 7, ID: "erin", Reason: "contact has no address"},
+		{Row: 8, ID: "alice", Reason: "duplicate contact ID in import"},
+	}
+	if len(report.Rejected) != len(wantRejected) {
+		t.Fatalf("rejected = %+v, want %+v", report.Rejected, wantRejected)
+	}
+	for i, want := range wantRejected {
+		if report.Rejected[i] != want {
+			t.Errorf("rejected[%d] = %+v, want %+v", i, report.Rejected[i], want)
+		}
+	}
+
+	alice, err := store.GetContact(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetContact(alice) error = %v", err)
+	}
+	if alice.Email != "alice@example.com" || alice.Channels["feishu"] != "ou_alice" || alice.Timezone != "Asia/Shanghai" {
+		t.Errorf("alice = %+v, want normalized email, feishu channel and timezone", alice)
+	}
+	if bob, _ := store.GetContact(ctx, "bob"); bob == nil || bob.Phone != "+8613800138000" {
+		t.Errorf("bob = %+v, want normalized phone", bob)
+	}
+
+	tests := []struct {
+		name          string
+		opts          ImportOptions
+		data          string
+		wantCreated   int
+		wantUpdated   int
+		wantUnchanged int
+		wantRejected  int
+		wantEmail     string
+	}{
+		{
+			name:         "insert rejects existing",
+			data:         `[{"id": "alice", "email": "alice@example.com"}]`,
+			wantRejected: 1,
+			wantEmail:    "alice@example.com",
+		},
+		{
+			name:        "dry run reports without writing",
+			opts:        ImportOptions{Mode: ImportUpsert, DryRun: true},
+			data:        `[{"id": "alice", "email": "new@example.com"}, {"id": "zoe", "email": "zoe@example.com"}]`,
+			wantCreated: 1,
+			wantUpdated: 1,
+			wantEmail:   "alice@example.com",
+		},
+		{
+			name:        "upsert replaces changed contacts",
+			opts:        ImportOptions{Mode: ImportUpsert},
+			data:        `[{"id": "alice", "email": "new@example.com"}]`,
+			wantUpdated: 1,
+			wantEmail:   "new@example.com",
+		},
+		{
+			name:          "upsert is idempotent",
+			opts:          ImportOptions{Mode: ImportUpsert},
+			data:          `[{"id": "alice", "email": "new@EXAMPLE.com"}]`,
+			wantUnchanged: 1,
+			wantEmail:     "new@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			importer, err := NewImporter(store, tt.opts)
+			if err != nil {
+				t.Fatalf("NewImporter() error = %v", err)
+			}
+			report, err := importer.ImportContactsJSON(ctx, strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("ImportContactsJSON() error = %v", err)
+			}
+			if report.Created != tt.wantCreated || report.Updated != tt.wantUpdated ||
+				report.Unchanged != tt.wantUnchanged || len(report.Rejected) != tt.wantRejected {
+				t.Errorf("report = %+v, want created %d updated %d unchanged %d rejected %d",
+					report, tt.wantCreated, tt.wantUpdated, tt.wantUnchanged, tt.wantRejected)
+			}
+			if alice, _ := store.GetContact(ctx, "alice"); alice == nil || alice.Email != tt.wantEmail {
+				t.Errorf("alice = %+v, want email %s", alice, tt.wantEmail)
+			}
+		})
+	}
+
+	if _, err := NewImporter(store, ImportOptions{Mode: "merge"}); err == nil {
+		t.Error("NewImporter() expected error for unknown mode")
+	}
+	if _, err := importer.ImportContactsJSON(ctx, strings.NewReader(`{"id": "alice"}`)); err == nil {
+		t.Error("ImportContactsJSON() expected error for non-array JSON")
+	}
+}
+
+func TestImporter_Groups(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	importer, err := NewImporter(store, ImportOptions{Mode: ImportUpsert})
+	if err != nil {
+		t.Fatalf("NewImporter() error = %v", err)
+	}
+
+	csvData := "name,members,platform\n" +
+		"sre,alice;bob,\n" +
+		"oncall, alice ; group:sre ,feishu\n" +
+		",alice,\n" +
+		"empty, ; ,\n"
+	report, err := importer.ImportGroupsCSV(ctx, strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ImportGroupsCSV() error = %v", err)
+	}
+	if report.Unchanged != 1 || report.Created != 1 || len(report.Rejected) != 2 {
+		t.Errorf("report = %+v, want 1 unchanged, 1 created, 2 rejected", report)
+	}
+
+	oncall, err := store.GetGroup(ctx, "oncall")
+	if err != nil {
+		t.Fatalf("GetGroup(oncall) error = %v", err)
+	}
+	if strings.Join(oncall.Members, ",") != "alice,group:sre" || oncall.Platform != "feishu" {
+		t.Errorf("oncall = %+v, want trimmed members and feishu platform", oncall)
+	}
+
+	report, err = importer.ImportGroupsJSON(ctx, strings.NewReader(`[{"name": "sre", "members": ["alice"]}, null]`))
+	if err != nil {
+		t.Fatalf("ImportGroupsJSON() error = %v", err)
+	}
+	if report.Updated != 1 || len(report.Rejected) != 1 {
+		t.Errorf("report = %+v, want 1 updated, 1 rejected", report)
+	}
+}
+
+func TestParseTagQuery(t *testing.T) {
+	tags := []string{"role:SRE", "region:eu", "status:active"}
+
+	tests := []struct {
+		query   string
+		want    bool
+		wantErr bool
+	}{
+		{query: "role:sre", want: true},
+		{query: "role:sre AND region:eu", want: true},
+		{query: "role:sre and region:us", want: false},
+		{query: "region:us OR region:eu", want: true},
+		{query: "role:sre AND NOT status:active", want: false},
+		{query: "role:dev OR role:sre AND region:eu", want: true},
+		{query: "(role:dev OR role:sre) AND (region:us)", want: false},
+		{query: "region:*", want: true},
+		{query: "", wantErr: true},
+		{query: "role:sre AND", wantErr: true},
+		{query: "(role:sre", wantErr: true},
+		{query: "role:sre region:eu", wantErr: true},
+		{query: "region:[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseTagQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTagQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && q.Match(tags) != tt.want {
+				t.Errorf("Match() = %v, want %v", !tt.want, tt.want)
+			}
+		})
+	}
+}
+
+// readOnlyDirectory hides every method but those of Directory
+type readOnlyDirectory struct{ Directory }
+
+func TestExpander_Tags(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	contacts := []*Contact{
+		{ID: "alice", Email: "alice@example.com", Tags: []string{"role:sre", "region:eu"}},
+		{ID: "bob", Email: "bob@example.com", Tags: []string{"role:sre", "region:us"}},
+		{ID: "carol", Tags: []string{"role:sre", "region:eu"}},
+		{ID: "dave", Email: "dave@example.com", Tags: []string{"role:dev", "region:eu"}},
+	}
+	for _, c := range contacts {
+		_ = store.PutContact(ctx, c)
+	}
+	_ = store.PutGroup(ctx, &Group{Name: "eu-oncall", Members: []string{"tag:role:sre AND region:eu", "dave"}})
+	expander := NewExpander(MultiDirectory{store})
+
+	expansion, err := expander.ExpandTarget(ctx, target.NewTagQuery("role:sre AND region:eu"))
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if len(expansion.Targets) != 1 || expansion.Targets[0].Value != "alice@example.com" {
+		t.Errorf("ExpandTarget() = %v, want alice", expansion.Targets)
+	}
+	if len(expansion.Unresolved) != 1 || expansion.Unresolved[0].Member != "carol" {
+		t.Errorf("Unresolved = %+v, want carol without address", expansion.Unresolved)
+	}
+
+	expansion, err = expander.ExpandTarget(ctx, target.NewTeam("eu-oncall"))
+	if err != nil || len(expansion.Targets) != 2 {
+		t.Errorf("ExpandTarget() = %+v, %v, want alice and dave", expansion, err)
+	}
+
+	if _, err := expander.ExpandTarget(ctx, target.NewTagQuery("role:sre AND")); err == nil {
+		t.Error("ExpandTarget() expected error for invalid query")
+	}
+	if _, err := NewExpander(readOnlyDirectory{store}).ExpandTarget(ctx, target.NewTagQuery("role:sre")); err == nil {
+		t.Error("ExpandTarget() expected error for directory that cannot list contacts")
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	ctx := context.Background()
+	keys, _ := pii.NewLocalKeyService([]byte("0123456789abcdef0123456789abcdef"))
+	envelope, err := pii.NewEnvelope(pii.EnvelopeConfig{Keys: keys, IndexKey: []byte("0123456789abcdef")})
+	if err != nil {
+		t.Fatalf("NewEnvelope() error = %v", err)
+	}
+
+	inner := NewMemoryStore()
+	store := NewEncryptedStore(inner, envelope)
+	alice := &Contact{ID: "alice", Email: "alice@example.com", Phone: "+8613800138000", Channels: map[string]string{"feishu": "ou_alice"}}
+	if err := store.PutContact(ctx, alice); err != nil {
+		t.Fatalf("PutContact() error = %v", err)
+	}
+
+	raw, _ := inner.GetContact(ctx, "alice")
+	if !pii.IsEncrypted(raw.Email) || !pii.IsEncrypted(raw.Phone) || !pii.IsEncrypted(raw.Channels["feishu"]) {
+		t.Errorf("stored contact = %+v, want encrypted addresses", raw)
+	}
+	if alice.Email != "alice@example.com" {
+		t.Error("PutContact() must not modify the caller's contact")
+	}
+
+	got, err := store.GetContact(ctx, "alice")
+	if err != nil || got.Email != "alice@example.com" || got.Channels["feishu"] != "ou_alice" {
+		t.Errorf("GetContact() = %+v, %v, want decrypted contact", got, err)
+	}
+	if found, err := store.FindContact(ctx, "email", "ALICE@example.com"); err != nil || found.ID != "alice" {
+		t.Errorf("FindContact() = %+v, %v, want alice", found, err)
+	}
+	if _, err := store.FindContact(ctx, "sms", "+8613800138001"); !IsNotFound(err) {
+		t.Errorf("FindContact() error = %v, want not found", err)
+	}
+
+	expansion, err := NewExpander(store).ExpandTarget(ctx, target.NewContact("alice"))
+	if err != nil || len(expansion.Targets) != 1 || expansion.Targets[0].Value != "alice@example.com" {
+		t.Errorf("ExpandTarget() = %+v, %v, want decrypted email at dispatch", expansion, err)
+	}
+
+	if masked := got.Masked(); masked.Email != "a****@example.com" || masked.Phone != "+86*******8000" {
+		t.Errorf("Masked() = %+v, want masked addresses", masked)
+	}
+}
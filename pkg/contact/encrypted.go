@@ -0,0 +1,118 @@
+// Package contact provides encryption of contact addresses at rest
+package contact
+
+import (
+	"context"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+	"github.com/kart-io/notifyhub/pkg/pii"
+)
+
+// EncryptedStore encrypts contact email addresses, phone numbers and
+// channel addresses before they reach the underlying store, and decrypts
+// them when contacts are read back for dispatch. Groups are stored as-is.
+type EncryptedStore struct {
+	Store
+	envelope *pii.Envelope
+}
+
+// NewEncryptedStore wraps a store with envelope encryption
+func NewEncryptedStore(store Store, envelope *pii.Envelope) *EncryptedStore {
+	return &EncryptedStore{Store: store, envelope: envelope}
+}
+
+// GetContact returns a decrypted contact by ID
+func (s *EncryptedStore) GetContact(ctx context.Context, id string) (*Contact, error) {
+	c, err := s.Store.GetContact(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(ctx, c)
+}
+
+// FindContact returns the contact with the given address on a platform.
+// Encrypted addresses cannot be matched by the underlying store, so every
+// contact is decrypted and compared.
+func (s *EncryptedStore) FindContact(ctx context.Context, platform, address string) (*Contact, error) {
+	contacts, err := s.ListContacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range contacts {
+		if sameAddress(platform, c.Address(platform), address) {
+			return c, nil
+		}
+	}
+	return nil, errors.Newf(errors.ErrNotFound, "no contact with %s address %s", platform, pii.Mask(address))
+}
+
+// PutContact encrypts and stores a contact
+func (s *EncryptedStore) PutContact(ctx context.Context, c *Contact) error {
+	if c == nil {
+		return errors.New(errors.ErrInvalidTarget, "contact ID cannot be empty")
+	}
+	sealed, err := s.transform(ctx, c, s.envelope.Encrypt)
+	if err != nil {
+		return errors.Wrapf(err, errors.ErrInternal, "failed to encrypt contact %s", c.ID)
+	}
+	return s.Store.PutContact(ctx, sealed)
+}
+
+// ListContacts returns all contacts decrypted
+func (s *EncryptedStore) ListContacts(ctx context.Context) ([]*Contact, error) {
+	contacts, err := s.Store.ListContacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range contacts {
+		if contacts[i], err = s.decrypt(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+	return contacts, nil
+}
+
+// decrypt returns a decrypted copy of a stored contact
+func (s *EncryptedStore) decrypt(ctx context.Context, c *Contact) (*Contact, error) {
+	opened, err := s.transform(ctx, c, s.envelope.Decrypt)
+	if err != nil {
+		return nil, errors.Wrapf(err, errors.ErrInternal, "failed to decrypt contact %s", c.ID)
+	}
+	return opened, nil
+}
+
+// transform returns a copy of a contact with its addresses passed through fn
+func (s *EncryptedStore) transform(ctx context.Context, c *Contact, fn func(context.Context, string) (string, error)) (*Contact, error) {
+	out := *c
+	var err error
+	if out.Email, err = fn(ctx, c.Email); err != nil {
+		return nil, err
+	}
+	if out.Phone, err = fn(ctx, c.Phone); err != nil {
+		return nil, err
+	}
+	if c.Channels != nil {
+		out.Channels = make(map[string]string, len(c.Channels))
+		for platform, address := range c.Channels {
+			if out.Channels[platform], err = fn(ctx, address); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &out, nil
+}
+
+// Masked returns a copy of the contact with its addresses masked for logs
+// and exports
+func (c *Contact) Masked() *Contact {
+	out := *c
+	out.Email = pii.Mask(c.Email)
+	out.Phone = pii.Mask(c.Phone)
+	if c.Channels != nil {
+		out.Channels = make(map[string]string, len(c.Channels))
+		for platform, address := range c.Channels {
+			out.Channels[platform] = pii.Mask(address)
+		}
+	}
+	return &out
+}
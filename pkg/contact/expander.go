@@ -0,0 +1,470 @@
+// Package contact provides group expansion functionality for NotifyHub
+package contact
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// DefaultMaxDepth is the default maximum nesting depth for groups
+const DefaultMaxDepth = 10
+
+// Expansion is the result of expanding a group or team target
+type Expansion struct {
+	Targets    []target.Target `json:"targets"`
+	Unresolved []Unresolved    `json:"unresolved,omitempty"`
+
+	// Contacts maps target.String() of targets selected from a directory
+	// contact to that contact, so callers can apply contact preferences
+	Contacts map[string]*Contact `json:"-"`
+}
+
+// Contact returns the directory contact a target was selected from, if any
+func (x *Expansion) Contact(t target.Target) *Contact {
+	return x.Contacts[t.String()]
+}
+
+// add appends a target selected from a contact
+func (x *Expansion) add(t target.Target, c *Contact) {
+	x.Targets = append(x.Targets, t)
+	if c != nil {
+		if x.Contacts == nil {
+			x.Contacts = make(map[string]*Contact)
+		}
+		x.Contacts[t.String()] = c
+	}
+}
+
+// Unresolved describes a group member that could not be turned into a target
+type Unresolved struct {
+	Group  string `json:"group"`
+	Member string `json:"member"`
+	Reason string `json:"reason"`
+}
+
+// OnCallResolver resolves an on-call schedule to the members currently on call.
+// Members use the same reference forms as group members (contact IDs,
+// email addresses or explicit targets such as "email:ops@example.com").
+type OnCallResolver interface {
+	OnCall(ctx context.Context, schedule string) ([]string, error)
+}
+
+// ListProvider returns the current members of a dynamic target list, such
+// as all users subscribed to a product. Members use the same reference
+// forms as group members.
+type ListProvider interface {
+	Members(ctx context.Context, list string) ([]string, error)
+}
+
+// Expander expands group and team targets into member targets
+type Expander struct {
+	directory   Directory
+	onCall      OnCallResolver
+	lists       ListProvider
+	isAvailable func(platform string) bool
+	maxDepth    int
+}
+
+// ExpanderOption configures an Expander
+type ExpanderOption func(*Expander)
+
+// WithPlatformFilter restricts member platform selection to platforms
+// for which fn returns true (typically the platforms configured on the hub)
+func WithPlatformFilter(fn func(platform string) bool) ExpanderOption {
+	return func(e *Expander) {
+		e.isAvailable = fn
+	}
+}
+
+// WithOnCall enables expansion of on-call targets ("oncall:payments")
+func WithOnCall(resolver OnCallResolver) ExpanderOption {
+	return func(e *Expander) {
+		e.onCall = resolver
+	}
+}
+
+// WithListProvider enables expansion of dynamic list targets ("list:product-x")
+func WithListProvider(provider ListProvider) ExpanderOption {
+	return func(e *Expander) {
+		e.lists = provider
+	}
+}
+
+// WithMaxDepth sets the maximum group nesting depth
+func WithMaxDepth(depth int) ExpanderOption {
+	return func(e *Expander) {
+		if depth > 0 {
+			e.maxDepth = depth
+		}
+	}
+}
+
+// NewExpander creates a new group expander backed by a directory
+func NewExpander(directory Directory, opts ...ExpanderOption) *Expander {
+	e := &Expander{
+		directory:   directory,
+		isAvailable: func(string) bool { return true },
+		maxDepth:    DefaultMaxDepth,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// IsExpandable reports whether a target refers to a directory contact, group,
+// on-call schedule, dynamic list or tag query. Contact, team, on-call, list
+// and tag targets are always expanded; group targets are expanded when the
+// directory defines a group with that name, and are sent as-is otherwise,
+// such as a Feishu chat. A group without a platform is also expanded when
+// the directory fails, so that the failure is reported.
+func (e *Expander) IsExpandable(ctx context.Context, t target.Target) bool {
+	switch t.Type {
+	case target.TargetTypeContact, target.TargetTypeTeam, target.TargetTypeOnCall, target.TargetTypeList, target.TargetTypeTag:
+		return true
+	case target.TargetTypeGroup:
+		_, err := e.directory.GetGroup(ctx, t.Value)
+		if err == nil {
+			return true
+		}
+		return t.Platform == "" && !IsNotFound(err)
+	default:
+		return false
+	}
+}
+
+// ExpandTarget expands a single group or team target.
+// Non-expandable targets are returned unchanged.
+func (e *Expander) ExpandTarget(ctx context.Context, t target.Target) (*Expansion, error) {
+	if !e.IsExpandable(ctx, t) {
+		return &Expansion{Targets: []target.Target{t}}, nil
+	}
+
+	result := &Expansion{}
+	seen := make(map[string]bool)
+	if t.Type == target.TargetTypeContact {
+		if err := e.expandContact(ctx, t.Value, t.Platform, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if t.Type == target.TargetTypeOnCall {
+		if err := e.expandOnCall(ctx, t.Value, t.Platform, "", seen, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if t.Type == target.TargetTypeList {
+		if err := e.expandList(ctx, t.Value, t.Platform, "", seen, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if t.Type == target.TargetTypeTag {
+		if err := e.expandTags(ctx, t.Value, t.Platform, "", seen, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if err := e.expandGroup(ctx, t.Value, t.Platform, nil, seen, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Expand expands all group and team targets in the list
+func (e *Expander) Expand(ctx context.Context, targets []target.Target) (*Expansion, error) {
+	result := &Expansion{}
+	seen := make(map[string]bool)
+
+	for _, t := range targets {
+		expansion, err := e.ExpandTarget(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range expansion.Targets {
+			key := member.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result.add(member, expansion.Contact(member))
+		}
+		result.Unresolved = append(result.Unresolved, expansion.Unresolved...)
+	}
+
+	return result, nil
+}
+
+// expandGroup recursively expands a group, detecting cycles along the current path
+func (e *Expander) expandGroup(ctx context.Context, name, platform string, path []string, seen map[string]bool, result *Expansion) error {
+	for _, ancestor := range path {
+		if ancestor == name {
+			cycle := append(append([]string{}, path...), name)
+			return errors.Newf(errors.ErrTargetResolutionFailed, "group cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if len(path) >= e.maxDepth {
+		return errors.Newf(errors.ErrTargetResolutionFailed, "group %s exceeds maximum nesting depth %d", name, e.maxDepth)
+	}
+
+	group, err := e.directory.GetGroup(ctx, name)
+	if err != nil {
+		if IsNotFound(err) {
+			return errors.Wrapf(err, errors.ErrTargetResolutionFailed, "group %s does not exist", name)
+		}
+		return errors.Wrapf(err, errors.ErrTargetResolutionFailed, "failed to load group %s", name)
+	}
+
+	if platform == "" {
+		platform = group.Platform
+	}
+	path = append(path, name)
+
+	for _, member := range group.Members {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+
+		if nested, ok := parseGroupRef(member); ok {
+			if err := e.expandGroup(ctx, nested, platform, path, seen, result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if schedule, ok := strings.CutPrefix(member, target.TargetTypeOnCall+":"); ok {
+			if err := e.expandOnCall(ctx, schedule, platform, name, seen, result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if list, ok := strings.CutPrefix(member, target.TargetTypeList+":"); ok {
+			if err := e.expandList(ctx, list, platform, name, seen, result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if query, ok := strings.CutPrefix(member, target.TargetTypeTag+":"); ok {
+			if err := e.expandTags(ctx, query, platform, name, seen, result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		e.addMember(ctx, name, member, platform, seen, result)
+	}
+
+	return nil
+}
+
+// expandContact resolves a single contact to its preferred reachable target
+func (e *Expander) expandContact(ctx context.Context, id, platform string, result *Expansion) error {
+	c, err := e.directory.GetContact(ctx, id)
+	if err != nil {
+		if IsNotFound(err) {
+			return errors.Wrapf(err, errors.ErrTargetResolutionFailed, "contact %s does not exist", id)
+		}
+		return errors.Wrapf(err, errors.ErrTargetResolutionFailed, "failed to load contact %s", id)
+	}
+
+	t, ok := e.SelectTarget(c, platform)
+	if !ok {
+		return errors.Newf(errors.ErrTargetResolutionFailed, "contact %s has no address on an available platform", id)
+	}
+	result.add(t, c)
+	return nil
+}
+
+// expandOnCall resolves the members currently on call for a schedule.
+// The schedule is evaluated on every call so rotations take effect at dispatch time.
+func (e *Expander) expandOnCall(ctx context.Context, schedule, platform, group string, seen map[string]bool, result *Expansion) error {
+	if e.onCall == nil {
+		return errors.Newf(errors.ErrTargetResolutionFailed, "no on-call resolver configured for schedule %s", schedule)
+	}
+
+	members, err := e.onCall.OnCall(ctx, schedule)
+	if err != nil {
+		return errors.Wrapf(err, errors.ErrTargetResolutionFailed, "failed to resolve on-call schedule %s", schedule)
+	}
+
+	if group == "" {
+		group = target.TargetTypeOnCall + ":" + schedule
+	}
+	if len(members) == 0 {
+		result.Unresolved = append(result.Unresolved, Unresolved{Group: group, Member: schedule, Reason: "nobody is on call"})
+		return nil
+	}
+
+	for _, member := range members {
+		if member = strings.TrimSpace(member); member != "" {
+			e.addMember(ctx, group, member, platform, seen, result)
+		}
+	}
+	return nil
+}
+
+// expandList resolves the current members of a dynamic target list.
+// An empty list expands to no targets.
+func (e *Expander) expandList(ctx context.Context, list, platform, group string, seen map[string]bool, result *Expansion) error {
+	if e.lists == nil {
+		return errors.Newf(errors.ErrTargetResolutionFailed, "no list provider configured for list %s", list)
+	}
+
+	members, err := e.lists.Members(ctx, list)
+	if err != nil {
+		return errors.Wrapf(err, errors.ErrTargetResolutionFailed, "failed to fetch target list %s", list)
+	}
+
+	if group == "" {
+		group = target.TargetTypeList + ":" + list
+	}
+	for _, member := range members {
+		if member = strings.TrimSpace(member); member != "" {
+			e.addMember(ctx, group, member, platform, seen, result)
+		}
+	}
+	return nil
+}
+
+// expandTags resolves the directory contacts whose tags match a query.
+// A query matching nobody expands to no targets.
+func (e *Expander) expandTags(ctx context.Context, query, platform, group string, seen map[string]bool, result *Expansion) error {
+	q, err := ParseTagQuery(query)
+	if err != nil {
+		return errors.Wrapf(err, errors.ErrTargetResolutionFailed, "invalid tag query %q", query)
+	}
+	lister, ok := e.directory.(ContactLister)
+	if !ok {
+		return errors.Newf(errors.ErrTargetResolutionFailed, "contact directory cannot list contacts for tag query %q", query)
+	}
+	contacts, err := lister.ListContacts(ctx)
+	if err != nil {
+		return errors.Wrapf(err, errors.ErrTargetResolutionFailed, "failed to list contacts for tag query %q", query)
+	}
+
+	if group == "" {
+		group = target.TargetTypeTag + ":" + query
+	}
+	for _, c := range contacts {
+		if !q.Match(c.Tags) {
+			continue
+		}
+		t, ok := e.SelectTarget(c, platform)
+		if !ok {
+			result.Unresolved = append(result.Unresolved, Unresolved{Group: group, Member: c.ID, Reason: "contact has no address on an available platform"})
+			continue
+		}
+		if key := t.String(); !seen[key] {
+			seen[key] = true
+			result.add(t, c)
+		}
+	}
+	return nil
+}
+
+// addMember resolves a member and appends it to the result unless already present
+func (e *Expander) addMember(ctx context.Context, group, member, platform string, seen map[string]bool, result *Expansion) {
+	t, c, reason := e.resolveMember(ctx, member, platform)
+	if reason != "" {
+		result.Unresolved = append(result.Unresolved, Unresolved{Group: group, Member: member, Reason: reason})
+		return
+	}
+
+	key := t.String()
+	if seen[key] {
+		return
+	}
+	seen[key] = true
+	result.add(t, c)
+}
+
+// resolveMember resolves a non-group member reference into a target and,
+// when the member is a directory contact, the contact it was selected from.
+// It returns a non-empty reason when the member cannot be resolved.
+func (e *Expander) resolveMember(ctx context.Context, member, platform string) (target.Target, *Contact, string) {
+	if t, ok := parseTargetRef(member); ok {
+		return t, nil, ""
+	}
+
+	c, err := e.directory.GetContact(ctx, member)
+	if err != nil {
+		if IsNotFound(err) && strings.Contains(member, "@") {
+			return target.NewEmail(member), nil, ""
+		}
+		return target.Target{}, nil, err.Error()
+	}
+
+	t, ok := e.SelectTarget(c, platform)
+	if !ok {
+		return target.Target{}, nil, "contact has no address on an available platform"
+	}
+	return t, c, ""
+}
+
+// FindContact returns the directory contact that owns an address on a
+// platform, when the directory supports address lookups
+func (e *Expander) FindContact(ctx context.Context, platform, address string) (*Contact, bool) {
+	lookup, ok := e.directory.(AddressLookup)
+	if !ok {
+		return nil, false
+	}
+	c, err := lookup.FindContact(ctx, platform, address)
+	if err != nil {
+		return nil, false
+	}
+	return c, true
+}
+
+// SelectTarget picks the target for a contact, honoring an explicit platform
+// first and the contact's preferred platform order otherwise
+func (e *Expander) SelectTarget(c *Contact, platform string) (target.Target, bool) {
+	if platform != "" && e.isAvailable(platform) {
+		if t, ok := c.Target(platform); ok {
+			return t, true
+		}
+	}
+
+	for _, p := range c.AvailablePlatforms() {
+		if e.isAvailable(p) {
+			return c.Target(p)
+		}
+	}
+	return target.Target{}, false
+}
+
+// parseGroupRef parses "group:<name>" and "team:<name>" member references
+func parseGroupRef(member string) (string, bool) {
+	for _, prefix := range []string{target.TargetTypeGroup + ":", target.TargetTypeTeam + ":"} {
+		if strings.HasPrefix(member, prefix) {
+			return strings.TrimPrefix(member, prefix), true
+		}
+	}
+	return "", false
+}
+
+// parseTargetRef parses explicit target member references such as
+// "email:ops@example.com", "phone:+8613800138000" or "webhook:https://..."
+func parseTargetRef(member string) (target.Target, bool) {
+	parts := strings.SplitN(member, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return target.Target{}, false
+	}
+
+	switch parts[0] {
+	case target.TargetTypeEmail:
+		return target.NewEmail(parts[1]), true
+	case target.TargetTypePhone:
+		return TargetFor("sms", parts[1]), true
+	case target.TargetTypeWebhook:
+		return target.NewWebhook(parts[1]), true
+	default:
+		return target.Target{}, false
+	}
+}
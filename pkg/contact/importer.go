@@ -0,0 +1,360 @@
+// Package contact provides bulk contact and group import for NotifyHub
+package contact
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// Import modes
+const (
+	ImportInsert = "insert" // reject rows whose contact or group already exists
+	ImportUpsert = "upsert" // create or replace; re-importing the same file is a no-op
+)
+
+// ImportOptions configures an import
+type ImportOptions struct {
+	Mode          string // insert (default) or upsert
+	DryRun        bool   // validate and report without writing
+	DefaultRegion string // region for phone numbers without a country calling code
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Rejected  []RejectedRow `json:"rejected,omitempty"`
+}
+
+// Accepted returns the number of rows that were imported
+func (r *ImportReport) Accepted() int {
+	return r.Created + r.Updated + r.Unchanged
+}
+
+// RejectedRow describes a row that was not imported
+type RejectedRow struct {
+	Row    int    `json:"row"` // CSV line number or 1-based JSON array index
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// CSV columns. In contact files any other column is a channel address for the
+// platform named by the column header (e.g. "feishu").
+const (
+	columnID        = "id"
+	columnName      = "name"
+	columnEmail     = "email"
+	columnPhone     = "phone"
+	columnTimezone  = "timezone"
+	columnPlatforms = "platforms" // ";"-separated preferred platform order
+	columnTags      = "tags"      // ";"-separated contact tags
+	columnMembers   = "members"   // ";"-separated group members
+	columnPlatform  = "platform"
+)
+
+// Importer loads contacts and groups into a store in bulk
+type Importer struct {
+	store     Store
+	opts      ImportOptions
+	validator *target.Validator
+}
+
+// NewImporter creates an importer writing to store
+func NewImporter(store Store, opts ImportOptions) (*Importer, error) {
+	switch opts.Mode {
+	case "":
+		opts.Mode = ImportInsert
+	case ImportInsert, ImportUpsert:
+	default:
+		return nil, errors.Newf(errors.ErrInvalidConfig, "invalid import mode %q, expected insert or upsert", opts.Mode)
+	}
+	return &Importer{store: store, opts: opts, validator: target.NewValidator(opts.DefaultRegion)}, nil
+}
+
+// ImportContactsCSV imports contacts from CSV with a header row
+func (im *Importer) ImportContactsCSV(ctx context.Context, r io.Reader) (*ImportReport, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{}
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		c := &Contact{Channels: make(map[string]string)}
+		for column, value := range row.fields {
+			switch column {
+			case columnID:
+				c.ID = value
+			case columnName:
+				c.Name = value
+			case columnEmail:
+				c.Email = value
+			case columnPhone:
+				c.Phone = value
+			case columnTimezone:
+				c.Timezone = value
+			case columnPlatforms:
+				c.Platforms = splitList(value)
+			case columnTags:
+				c.Tags = splitList(value)
+			default:
+				if value != "" {
+					c.Channels[column] = value
+				}
+			}
+		}
+		if len(c.Channels) == 0 {
+			c.Channels = nil
+		}
+		im.importContact(ctx, row.line, c, seen, report)
+	}
+	return report, nil
+}
+
+// ImportContactsJSON imports contacts from a JSON array
+func (im *Importer) ImportContactsJSON(ctx context.Context, r io.Reader) (*ImportReport, error) {
+	var contacts []*Contact
+	if err := json.NewDecoder(r).Decode(&contacts); err != nil {
+		return nil, errors.Wrap(err, errors.ErrValidationFailed, "invalid contacts JSON")
+	}
+
+	report := &ImportReport{}
+	seen := make(map[string]bool)
+	for i, c := range contacts {
+		if c == nil {
+			report.Rejected = append(report.Rejected, RejectedRow{Row: i + 1, Reason: "contact cannot be null"})
+			continue
+		}
+		im.importContact(ctx, i+1, c, seen, report)
+	}
+	return report, nil
+}
+
+// ImportGroupsCSV imports groups from CSV with name, members and optional
+// platform columns
+func (im *Importer) ImportGroupsCSV(ctx context.Context, r io.Reader) (*ImportReport, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{}
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		g := &Group{
+			Name:     row.fields[columnName],
+			Members:  splitList(row.fields[columnMembers]),
+			Platform: row.fields[columnPlatform],
+		}
+		im.importGroup(ctx, row.line, g, seen, report)
+	}
+	return report, nil
+}
+
+// ImportGroupsJSON imports groups from a JSON array
+func (im *Importer) ImportGroupsJSON(ctx context.Context, r io.Reader) (*ImportReport, error) {
+	var groups []*Group
+	if err := json.NewDecoder(r).Decode(&groups); err != nil {
+		return nil, errors.Wrap(err, errors.ErrValidationFailed, "invalid groups JSON")
+	}
+
+	report := &ImportReport{}
+	seen := make(map[string]bool)
+	for i, g := range groups {
+		if g == nil {
+			report.Rejected = append(report.Rejected, RejectedRow{Row: i + 1, Reason: "group cannot be null"})
+			continue
+		}
+		im.importGroup(ctx, i+1, g, seen, report)
+	}
+	return report, nil
+}
+
+// importContact validates and stores a single contact
+func (im *Importer) importContact(ctx context.Context, row int, c *Contact, seen map[string]bool, report *ImportReport) {
+	c.ID = strings.TrimSpace(c.ID)
+	reject := func(reason string) {
+		report.Rejected = append(report.Rejected, RejectedRow{Row: row, ID: c.ID, Reason: reason})
+	}
+
+	if reason := im.normalizeContact(c); reason != "" {
+		reject(reason)
+		return
+	}
+	if seen[c.ID] {
+		reject("duplicate contact ID in import")
+		return
+	}
+	seen[c.ID] = true
+
+	existing, err := im.store.GetContact(ctx, c.ID)
+	if err != nil && !IsNotFound(err) {
+		reject("failed to load existing contact: " + err.Error())
+		return
+	}
+	im.write(existing != nil, reflect.DeepEqual(existing, c), reject, report, func() error {
+		return im.store.PutContact(ctx, c)
+	})
+}
+
+// importGroup validates and stores a single group
+func (im *Importer) importGroup(ctx context.Context, row int, g *Group, seen map[string]bool, report *ImportReport) {
+	g.Name = strings.TrimSpace(g.Name)
+	reject := func(reason string) {
+		report.Rejected = append(report.Rejected, RejectedRow{Row: row, ID: g.Name, Reason: reason})
+	}
+
+	if g.Name == "" {
+		reject("group name is required")
+		return
+	}
+	members := g.Members[:0]
+	for _, member := range g.Members {
+		if member = strings.TrimSpace(member); member != "" {
+			members = append(members, member)
+		}
+	}
+	g.Members = members
+	if len(g.Members) == 0 {
+		reject("group must have at least one member")
+		return
+	}
+	if seen[g.Name] {
+		reject("duplicate group name in import")
+		return
+	}
+	seen[g.Name] = true
+
+	existing, err := im.store.GetGroup(ctx, g.Name)
+	if err != nil && !IsNotFound(err) {
+		reject("failed to load existing group: " + err.Error())
+		return
+	}
+	im.write(existing != nil, reflect.DeepEqual(existing, g), reject, report, func() error {
+		return im.store.PutGroup(ctx, g)
+	})
+}
+
+// write applies the import mode to a validated row and updates the report
+func (im *Importer) write(exists, unchanged bool, reject func(string), report *ImportReport, put func() error) {
+	switch {
+	case exists && im.opts.Mode == ImportInsert:
+		reject("already exists")
+		return
+	case exists && unchanged:
+		report.Unchanged++
+		return
+	}
+
+	if !im.opts.DryRun {
+		if err := put(); err != nil {
+			reject("failed to store: " + err.Error())
+			return
+		}
+	}
+	if exists {
+		report.Updated++
+	} else {
+		report.Created++
+	}
+}
+
+// normalizeContact validates a contact and normalizes its addresses.
+// It returns the reason the contact is invalid, or an empty string.
+func (im *Importer) normalizeContact(c *Contact) string {
+	if c.ID == "" {
+		return "contact ID is required"
+	}
+
+	if c.Email = strings.TrimSpace(c.Email); c.Email != "" {
+		email, err := target.NormalizeEmail(c.Email)
+		if err != nil {
+			return "invalid email " + c.Email
+		}
+		c.Email = email
+	}
+	if c.Phone = strings.TrimSpace(c.Phone); c.Phone != "" {
+		phone, err := target.NormalizePhone(c.Phone, im.validator.DefaultRegion)
+		if err != nil {
+			return "invalid phone " + c.Phone
+		}
+		c.Phone = phone
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return "invalid timezone " + c.Timezone
+		}
+	}
+	for platform, address := range c.Channels {
+		if _, err := im.validator.Normalize(TargetFor(platform, address)); err != nil {
+			return fmt.Sprintf("invalid %s address %s", platform, address)
+		}
+	}
+	if len(c.AvailablePlatforms()) == 0 {
+		return "contact has no address"
+	}
+	return ""
+}
+
+// csvRow is a CSV record keyed by lower-cased header
+type csvRow struct {
+	line   int
+	fields map[string]string
+}
+
+// readCSV reads CSV records keyed by the header row
+func readCSV(r io.Reader) ([]csvRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrValidationFailed, "failed to read CSV header")
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var rows []csvRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrValidationFailed, "invalid CSV")
+		}
+
+		line, _ := reader.FieldPos(0)
+		fields := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				fields[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, csvRow{line: line, fields: fields})
+	}
+}
+
+// splitList splits a ";"-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
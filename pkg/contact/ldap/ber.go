@@ -0,0 +1,223 @@
+// Package ldap provides BER encoding for the LDAP client
+package ldap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// BER tag classes and flags
+const (
+	classUniversal   byte = 0x00
+	classApplication byte = 0x40
+	classContext     byte = 0x80
+	constructed      byte = 0x20
+)
+
+// Universal tags
+const (
+	tagBoolean     byte = 0x01
+	tagInteger     byte = 0x02
+	tagOctetString byte = 0x04
+	tagEnumerated  byte = 0x0a
+	tagSequence    byte = 0x30
+	tagSet         byte = 0x31
+)
+
+// maxPacketSize limits the size of a single BER element read from the server
+const maxPacketSize = 16 * 1024 * 1024
+
+// packet is a decoded BER element
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+// isConstructed reports whether the packet contains child elements
+func (p *packet) isConstructed() bool {
+	return p.tag&constructed != 0
+}
+
+// newSequence creates a constructed packet with the given tag
+func newSequence(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag | constructed, children: children}
+}
+
+// newString creates an octet string packet
+func newString(tag byte, s string) *packet {
+	return &packet{tag: tag, value: []byte(s)}
+}
+
+// newInteger creates an integer (or enumerated) packet
+func newInteger(tag byte, n int64) *packet {
+	return &packet{tag: tag, value: encodeInteger(n)}
+}
+
+// newBoolean creates a boolean packet
+func newBoolean(b bool) *packet {
+	if b {
+		return &packet{tag: tagBoolean, value: []byte{0xff}}
+	}
+	return &packet{tag: tagBoolean, value: []byte{0x00}}
+}
+
+// bytes encodes the packet
+func (p *packet) bytes() []byte {
+	content := p.value
+	if p.isConstructed() {
+		content = nil
+		for _, child := range p.children {
+			content = append(content, child.bytes()...)
+		}
+	}
+
+	out := []byte{p.tag}
+	out = append(out, encodeLength(len(content))...)
+	return append(out, content...)
+}
+
+// str returns the packet value as a string
+func (p *packet) str() string {
+	return string(p.value)
+}
+
+// int returns the packet value as an integer
+func (p *packet) int() int64 {
+	var n int64
+	for i, b := range p.value {
+		if i == 0 && b&0x80 != 0 {
+			n = -1
+		}
+		n = n<<8 | int64(b)
+	}
+	return n
+}
+
+// encodeLength encodes a BER definite length
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var buf []byte
+	for v := n; v > 0; v >>= 8 {
+		buf = append([]byte{byte(v)}, buf...)
+	}
+	return append([]byte{0x80 | byte(len(buf))}, buf...)
+}
+
+// encodeInteger encodes a two's complement integer with minimal octets
+func encodeInteger(n int64) []byte {
+	buf := []byte{byte(n)}
+	for {
+		next := n >> 8
+		if (next == 0 && buf[0]&0x80 == 0) || (next == -1 && buf[0]&0x80 != 0) {
+			return buf
+		}
+		n = next
+		buf = append([]byte{byte(n)}, buf...)
+	}
+}
+
+// readPacket reads a single BER element
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, fmt.Errorf("ldap: multi-byte BER tags are not supported")
+	}
+
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+
+	return parsePacket(tag, content)
+}
+
+// readLength reads a BER definite length
+func readLength(r *bufio.Reader) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if first&0x80 == 0 {
+		return int(first), nil
+	}
+
+	octets := int(first & 0x7f)
+	if octets == 0 || octets > 4 {
+		return 0, fmt.Errorf("ldap: unsupported BER length encoding")
+	}
+	length := 0
+	for i := 0; i < octets; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	if length > maxPacketSize {
+		return 0, fmt.Errorf("ldap: BER element of %d bytes exceeds limit", length)
+	}
+	return length, nil
+}
+
+// parsePacket decodes the content of a BER element
+func parsePacket(tag byte, content []byte) (*packet, error) {
+	p := &packet{tag: tag}
+	if tag&constructed == 0 {
+		p.value = content
+		return p, nil
+	}
+
+	for len(content) > 0 {
+		if len(content) < 2 {
+			return nil, fmt.Errorf("ldap: truncated BER element")
+		}
+		childTag := content[0]
+		length, header, err := parseLength(content[1:])
+		if err != nil {
+			return nil, err
+		}
+		start := 1 + header
+		if start+length > len(content) {
+			return nil, fmt.Errorf("ldap: truncated BER element")
+		}
+		child, err := parsePacket(childTag, content[start:start+length])
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		content = content[start+length:]
+	}
+	return p, nil
+}
+
+// parseLength decodes a BER length from a byte slice, returning the
+// length and the number of header bytes consumed
+func parseLength(b []byte) (int, int, error) {
+	if len(b) == 0 {
+		return 0, 0, fmt.Errorf("ldap: truncated BER length")
+	}
+	if b[0]&0x80 == 0 {
+		return int(b[0]), 1, nil
+	}
+	octets := int(b[0] & 0x7f)
+	if octets == 0 || octets > 4 || len(b) < 1+octets {
+		return 0, 0, fmt.Errorf("ldap: unsupported BER length encoding")
+	}
+	length := 0
+	for i := 1; i <= octets; i++ {
+		length = length<<8 | int(b[i])
+	}
+	return length, 1 + octets, nil
+}
@@ -0,0 +1,364 @@
+// Package ldap provides a minimal LDAPv3 client for directory lookups
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Search scopes
+const (
+	ScopeBaseObject   = 0
+	ScopeSingleLevel  = 1
+	ScopeWholeSubtree = 2
+)
+
+// LDAP result codes used by the client
+const (
+	ResultSuccess            = 0
+	ResultSizeLimitExceeded  = 4
+	ResultNoSuchObject       = 32
+	ResultInvalidCredentials = 49
+)
+
+// Protocol operation tags (RFC 4511 section 4.2 onwards)
+const (
+	opBindRequest          byte = classApplication | constructed | 0
+	opBindResponse         byte = classApplication | constructed | 1
+	opUnbindRequest        byte = classApplication | 2
+	opSearchRequest        byte = classApplication | constructed | 3
+	opSearchEntry          byte = classApplication | constructed | 4
+	opSearchDone           byte = classApplication | constructed | 5
+	opSearchReference      byte = classApplication | constructed | 19
+	opExtendedRequest      byte = classApplication | constructed | 23
+	opExtendedResponse     byte = classApplication | constructed | 24
+	startTLSOID                 = "1.3.6.1.4.1.1466.20037"
+	defaultSearchTimeLimit      = 30
+)
+
+// SearchRequest describes an LDAP search
+type SearchRequest struct {
+	BaseDN     string
+	Scope      int
+	Filter     string
+	Attributes []string
+	SizeLimit  int
+}
+
+// Entry is a single search result entry
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Get returns the first value of an attribute (case-insensitive)
+func (e *Entry) Get(name string) string {
+	values := e.GetAll(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// GetAll returns all values of an attribute (case-insensitive)
+func (e *Entry) GetAll(name string) []string {
+	if values, ok := e.Attributes[name]; ok {
+		return values
+	}
+	for attr, values := range e.Attributes {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// ResultError is returned when the server answers with a non-success result code
+type ResultError struct {
+	Code    int
+	Message string
+}
+
+// Error implements the error interface
+func (e *ResultError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.Code)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// Conn is an LDAP connection. The built-in client implements it; other
+// LDAP libraries can be adapted to it and injected with WithDialer.
+type Conn interface {
+	// Bind authenticates the connection with a simple bind
+	Bind(dn, password string) error
+
+	// Search runs a search and returns all result entries
+	Search(req *SearchRequest) ([]*Entry, error)
+
+	// Close closes the connection
+	Close() error
+}
+
+// client is the built-in Conn implementation
+type client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	nextID  int64
+	mu      sync.Mutex
+}
+
+// Dial connects to the server in cfg.URL. "ldaps://" URLs use TLS from the
+// start; "ldap://" URLs are upgraded with StartTLS when cfg.StartTLS is set.
+func Dial(ctx context.Context, cfg *Config) (Conn, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid URL %q: %w", cfg.URL, err)
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "ldaps":
+			host = net.JoinHostPort(u.Hostname(), "636")
+		default:
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldaps":
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", host)
+	case "ldap":
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: failed to connect to %s: %w", host, err)
+	}
+
+	c := newClient(conn, cfg.Timeout)
+	if u.Scheme == "ldap" && cfg.StartTLS {
+		if err := c.startTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// newClient wraps an established connection
+func newClient(conn net.Conn, timeout time.Duration) *client {
+	return &client{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+}
+
+// Bind authenticates the connection with a simple bind
+func (c *client) Bind(dn, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req := newSequence(opBindRequest,
+		newInteger(tagInteger, 3),
+		newString(tagOctetString, dn),
+		newString(classContext|0, password),
+	)
+	resp, err := c.roundTrip(req, opBindResponse)
+	if err != nil {
+		return err
+	}
+	return resultError(resp)
+}
+
+// Search runs a search and returns all result entries
+func (c *client) Search(req *SearchRequest) ([]*Entry, error) {
+	filter, err := compileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := &packet{tag: tagSequence}
+	for _, a := range req.Attributes {
+		attrs.children = append(attrs.children, newString(tagOctetString, a))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, err := c.send(newSequence(opSearchRequest,
+		newString(tagOctetString, req.BaseDN),
+		newInteger(tagEnumerated, int64(req.Scope)),
+		newInteger(tagEnumerated, 0), // neverDerefAliases
+		newInteger(tagInteger, int64(req.SizeLimit)),
+		newInteger(tagInteger, defaultSearchTimeLimit),
+		newBoolean(false),
+		filter,
+		attrs,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.tag {
+		case opSearchEntry:
+			entries = append(entries, parseEntry(op))
+		case opSearchReference:
+			// Referrals are not followed
+		case opSearchDone:
+			if err := resultError(op); err != nil {
+				if rerr, ok := err.(*ResultError); ok && (rerr.Code == ResultNoSuchObject || rerr.Code == ResultSizeLimitExceeded) {
+					return entries, nil
+				}
+				return nil, err
+			}
+			return entries, nil
+		default:
+			return nil, fmt.Errorf("ldap: unexpected response tag 0x%02x", op.tag)
+		}
+	}
+}
+
+// Close unbinds and closes the connection
+func (c *client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, _ = c.send(&packet{tag: opUnbindRequest})
+	return c.conn.Close()
+}
+
+// startTLS upgrades the connection using the StartTLS extended operation
+func (c *client) startTLS(config *tls.Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resp, err := c.roundTrip(newSequence(opExtendedRequest,
+		newString(classContext|0, startTLSOID),
+	), opExtendedResponse)
+	if err != nil {
+		return err
+	}
+	if err := resultError(resp); err != nil {
+		return fmt.Errorf("ldap: StartTLS rejected: %w", err)
+	}
+
+	tlsConn := tls.Client(c.conn, config)
+	if c.timeout > 0 {
+		_ = tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("ldap: TLS handshake failed: %w", err)
+	}
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// roundTrip sends a request and waits for a single response of the expected type
+func (c *client) roundTrip(op *packet, want byte) (*packet, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.receive(id)
+	if err != nil {
+		return nil, err
+	}
+	if resp.tag != want {
+		return nil, fmt.Errorf("ldap: unexpected response tag 0x%02x", resp.tag)
+	}
+	return resp, nil
+}
+
+// send writes an LDAPMessage and returns its message ID
+func (c *client) send(op *packet) (int64, error) {
+	c.nextID++
+	id := c.nextID
+
+	if c.timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	msg := newSequence(tagSequence, newInteger(tagInteger, id), op)
+	if _, err := c.conn.Write(msg.bytes()); err != nil {
+		return 0, fmt.Errorf("ldap: write failed: %w", err)
+	}
+	return id, nil
+}
+
+// receive reads the next LDAPMessage for the given message ID and returns its protocol op
+func (c *client) receive(id int64) (*packet, error) {
+	for {
+		msg, err := readPacket(c.reader)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: read failed: %w", err)
+		}
+		if msg.tag != tagSequence || len(msg.children) < 2 {
+			return nil, fmt.Errorf("ldap: malformed message")
+		}
+		if msg.children[0].int() != id {
+			// Unsolicited notifications and stale responses are skipped
+			continue
+		}
+		return msg.children[1], nil
+	}
+}
+
+// resultError converts an LDAPResult into an error
+func resultError(op *packet) error {
+	if len(op.children) < 3 {
+		return fmt.Errorf("ldap: malformed result")
+	}
+	code := int(op.children[0].int())
+	if code == ResultSuccess {
+		return nil
+	}
+	return &ResultError{Code: code, Message: op.children[2].str()}
+}
+
+// parseEntry decodes a SearchResultEntry
+func parseEntry(op *packet) *Entry {
+	entry := &Entry{Attributes: make(map[string][]string)}
+	if len(op.children) > 0 {
+		entry.DN = op.children[0].str()
+	}
+	if len(op.children) < 2 {
+		return entry
+	}
+
+	for _, attr := range op.children[1].children {
+		if len(attr.children) < 2 {
+			continue
+		}
+		name := attr.children[0].str()
+		for _, v := range attr.children[1].children {
+			entry.Attributes[name] = append(entry.Attributes[name], v.str())
+		}
+	}
+	return entry
+}
@@ -0,0 +1,199 @@
+// Package ldap provides RFC 4515 search filter compilation
+package ldap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter choice tags (RFC 4511 section 4.5.1)
+const (
+	filterAnd            byte = classContext | constructed | 0
+	filterOr             byte = classContext | constructed | 1
+	filterNot            byte = classContext | constructed | 2
+	filterEquality       byte = classContext | constructed | 3
+	filterSubstrings     byte = classContext | constructed | 4
+	filterGreaterOrEqual byte = classContext | constructed | 5
+	filterLessOrEqual    byte = classContext | constructed | 6
+	filterPresent        byte = classContext | 7
+	filterApprox         byte = classContext | constructed | 8
+)
+
+// EscapeFilter escapes a value for safe use inside a search filter
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// compileFilter compiles a string filter such as
+// "(&(objectClass=person)(mail=*))" into its BER representation
+func compileFilter(filter string) (*packet, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, fmt.Errorf("ldap: empty filter")
+	}
+	if filter[0] != '(' {
+		filter = "(" + filter + ")"
+	}
+
+	p, rest, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("ldap: unexpected trailing filter text %q", rest)
+	}
+	return p, nil
+}
+
+// parseFilter parses one parenthesized filter and returns the remaining input
+func parseFilter(s string) (*packet, string, error) {
+	if len(s) < 2 || s[0] != '(' {
+		return nil, "", fmt.Errorf("ldap: filter must start with '('")
+	}
+	s = s[1:]
+
+	switch s[0] {
+	case '&', '|':
+		tag := filterAnd
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		s = s[1:]
+		set := &packet{tag: tag}
+		for len(s) > 0 && s[0] == '(' {
+			child, rest, err := parseFilter(s)
+			if err != nil {
+				return nil, "", err
+			}
+			set.children = append(set.children, child)
+			s = rest
+		}
+		if len(s) == 0 || s[0] != ')' {
+			return nil, "", fmt.Errorf("ldap: unterminated filter set")
+		}
+		return set, s[1:], nil
+
+	case '!':
+		child, rest, err := parseFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		if len(rest) == 0 || rest[0] != ')' {
+			return nil, "", fmt.Errorf("ldap: unterminated not filter")
+		}
+		return &packet{tag: filterNot, children: []*packet{child}}, rest[1:], nil
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, "", fmt.Errorf("ldap: unterminated filter item")
+	}
+	item, err := parseItem(s[:end])
+	if err != nil {
+		return nil, "", err
+	}
+	return item, s[end+1:], nil
+}
+
+// parseItem parses a simple, presence or substring filter item
+func parseItem(item string) (*packet, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq <= 0 {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+	}
+
+	attr, value := item[:eq], item[eq+1:]
+	tag := filterEquality
+	switch attr[len(attr)-1] {
+	case '>':
+		tag, attr = filterGreaterOrEqual, attr[:len(attr)-1]
+	case '<':
+		tag, attr = filterLessOrEqual, attr[:len(attr)-1]
+	case '~':
+		tag, attr = filterApprox, attr[:len(attr)-1]
+	}
+	if attr == "" {
+		return nil, fmt.Errorf("ldap: missing attribute in filter item %q", item)
+	}
+
+	if tag == filterEquality && value == "*" {
+		return newString(filterPresent, attr), nil
+	}
+	if tag == filterEquality && strings.Contains(value, "*") {
+		return parseSubstrings(attr, value)
+	}
+
+	v, err := unescapeFilter(value)
+	if err != nil {
+		return nil, err
+	}
+	return &packet{tag: tag, children: []*packet{
+		newString(tagOctetString, attr),
+		newString(tagOctetString, v),
+	}}, nil
+}
+
+// parseSubstrings builds a substrings filter from a value containing '*'
+func parseSubstrings(attr, value string) (*packet, error) {
+	parts := strings.Split(value, "*")
+	subs := &packet{tag: tagSequence}
+
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		v, err := unescapeFilter(part)
+		if err != nil {
+			return nil, err
+		}
+		var tag byte = classContext | 1 // any
+		switch i {
+		case 0:
+			tag = classContext | 0 // initial
+		case len(parts) - 1:
+			tag = classContext | 2 // final
+		}
+		subs.children = append(subs.children, newString(tag, v))
+	}
+
+	return &packet{tag: filterSubstrings, children: []*packet{
+		newString(tagOctetString, attr),
+		subs,
+	}}, nil
+}
+
+// unescapeFilter decodes "\xx" hex escapes in a filter value
+func unescapeFilter(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", value)
+		}
+		n, err := strconv.ParseUint(value[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", value)
+		}
+		b.WriteByte(byte(n))
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,451 @@
+// Package ldap provides an LDAP / Active Directory backed contact directory
+// for NotifyHub. Groups such as "team:platform-eng" resolve to their
+// directory members, and member entries are mapped to contacts through a
+// configurable attribute mapping.
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/contact"
+	"github.com/kart-io/notifyhub/pkg/errors"
+)
+
+// Default settings
+const (
+	DefaultUserFilter  = "(&(objectClass=person)(|(sAMAccountName=%s)(uid=%s)))"
+	DefaultGroupFilter = "(&(|(objectClass=group)(objectClass=groupOfNames)(objectClass=groupOfUniqueNames))(cn=%s))"
+	DefaultCacheTTL    = 5 * time.Minute
+	DefaultTimeout     = 10 * time.Second
+)
+
+// AttributeMapping maps directory attributes to contact fields
+type AttributeMapping struct {
+	ID       string            `json:"id"`                 // default: sAMAccountName, falling back to uid
+	Name     string            `json:"name"`               // default: displayName, falling back to cn
+	Email    string            `json:"email"`              // default: mail
+	Phone    string            `json:"phone"`              // default: mobile
+	Member   string            `json:"member"`             // default: member, falling back to uniqueMember
+	Channels map[string]string `json:"channels,omitempty"` // platform -> attribute (e.g. "feishu": "feishuOpenId")
+}
+
+// Config holds the LDAP directory configuration
+type Config struct {
+	URL                string           `json:"url"` // ldap://host:389 or ldaps://host:636
+	BindDN             string           `json:"bind_dn"`
+	BindPassword       string           `json:"bind_password"`
+	BaseDN             string           `json:"base_dn"`
+	UserFilter         string           `json:"user_filter,omitempty"`  // "%s" is replaced by the escaped contact ID
+	GroupFilter        string           `json:"group_filter,omitempty"` // "%s" is replaced by the escaped group name
+	Attributes         AttributeMapping `json:"attributes"`
+	GroupClasses       []string         `json:"group_classes,omitempty"` // objectClass values that identify nested groups
+	CacheTTL           time.Duration    `json:"cache_ttl"`               // negative disables caching
+	Timeout            time.Duration    `json:"timeout"`
+	StartTLS           bool             `json:"start_tls"`
+	InsecureSkipVerify bool             `json:"insecure_skip_verify"`
+}
+
+// Validate checks the configuration
+func (c *Config) Validate() error {
+	if c.URL == "" {
+		return errors.New(errors.ErrInvalidConfig, "LDAP URL is required")
+	}
+	if c.BaseDN == "" {
+		return errors.New(errors.ErrInvalidConfig, "LDAP base DN is required")
+	}
+	if c.BindDN != "" && c.BindPassword == "" {
+		return errors.New(errors.ErrInvalidConfig, "LDAP bind password is required when bind DN is set")
+	}
+	return nil
+}
+
+// applyDefaults fills in unset fields
+func (c *Config) applyDefaults() {
+	if c.UserFilter == "" {
+		c.UserFilter = DefaultUserFilter
+	}
+	if c.GroupFilter == "" {
+		c.GroupFilter = DefaultGroupFilter
+	}
+	if c.CacheTTL == 0 {
+		c.CacheTTL = DefaultCacheTTL
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if len(c.GroupClasses) == 0 {
+		c.GroupClasses = []string{"group", "groupOfNames", "groupOfUniqueNames"}
+	}
+}
+
+// Dialer opens a new LDAP connection
+type Dialer func(ctx context.Context, cfg *Config) (Conn, error)
+
+// Option configures a Directory
+type Option func(*Directory)
+
+// WithDialer replaces the built-in LDAP client, e.g. with an adapter
+// around another LDAP library
+func WithDialer(dialer Dialer) Option {
+	return func(d *Directory) {
+		d.dial = dialer
+	}
+}
+
+// cacheEntry is a cached lookup result
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// Directory implements contact.Directory on top of LDAP / Active Directory
+type Directory struct {
+	config Config
+	base   []string // the RDNs of the base DN
+	dial   Dialer
+	now    func() time.Time
+
+	cache     map[string]cacheEntry
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// New creates a new LDAP directory
+func New(cfg Config, opts ...Option) (*Directory, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.applyDefaults()
+
+	d := &Directory{
+		config: cfg,
+		base:   splitDN(cfg.BaseDN),
+		dial:   Dial,
+		now:    time.Now,
+		cache:  make(map[string]cacheEntry),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d, nil
+}
+
+// GetContact returns a contact by ID or by a distinguished name under the
+// base DN
+func (d *Directory) GetContact(ctx context.Context, id string) (*contact.Contact, error) {
+	key := "contact:" + strings.ToLower(id)
+	if v, ok := d.cached(key); ok {
+		return v.(*contact.Contact), nil
+	}
+
+	conn, err := d.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := d.findEntry(conn, id, d.config.UserFilter)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.Newf(errors.ErrNotFound, "contact %s not found", id)
+	}
+
+	c := d.toContact(entry)
+	d.store(key, c)
+	return c, nil
+}
+
+// GetGroup returns a group by name (cn) or by a distinguished name under
+// the base DN. Members are
+// returned as DNs for people and "group:<dn>" references for nested groups,
+// and member contacts are cached so expansion needs no further lookups.
+func (d *Directory) GetGroup(ctx context.Context, name string) (*contact.Group, error) {
+	key := "group:" + strings.ToLower(name)
+	if v, ok := d.cached(key); ok {
+		return v.(*contact.Group), nil
+	}
+
+	conn, err := d.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := d.findEntry(conn, name, d.config.GroupFilter)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.Newf(errors.ErrNotFound, "group %s not found", name)
+	}
+
+	group := &contact.Group{Name: name}
+	for _, dn := range d.memberDNs(entry) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		member, err := d.lookupDN(conn, dn)
+		if err != nil {
+			return nil, err
+		}
+		if member == nil {
+			// Dangling member references are reported by the expander
+			group.Members = append(group.Members, dn)
+			continue
+		}
+		if d.isGroup(member) {
+			group.Members = append(group.Members, "group:"+member.DN)
+			continue
+		}
+
+		d.store("contact:"+strings.ToLower(member.DN), d.toContact(member))
+		group.Members = append(group.Members, member.DN)
+	}
+
+	d.store(key, group)
+	return group, nil
+}
+
+// Invalidate clears the lookup cache
+func (d *Directory) Invalidate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cache = make(map[string]cacheEntry)
+}
+
+// connect dials and binds a new connection
+func (d *Directory) connect(ctx context.Context) (Conn, error) {
+	conn, err := d.dial(ctx, &d.config)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrConnectionFailed, "failed to connect to LDAP server")
+	}
+
+	if d.config.BindDN != "" {
+		if err := conn.Bind(d.config.BindDN, d.config.BindPassword); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, errors.ErrInvalidCredentials, "LDAP bind failed")
+		}
+	}
+	return conn, nil
+}
+
+// findEntry looks up a single entry either directly by DN or by searching
+// the base DN with the given filter template. DNs outside the base DN are
+// not looked up, so that targets cannot read other parts of the directory.
+func (d *Directory) findEntry(conn Conn, value, filter string) (*Entry, error) {
+	if isDN(value) {
+		if !d.underBase(value) {
+			return nil, nil
+		}
+		return d.lookupDN(conn, value)
+	}
+
+	escaped := EscapeFilter(value)
+	entries, err := conn.Search(&SearchRequest{
+		BaseDN:     d.config.BaseDN,
+		Scope:      ScopeWholeSubtree,
+		Filter:     strings.ReplaceAll(filter, "%s", escaped),
+		Attributes: d.attributes(),
+		SizeLimit:  2,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrPlatformUnavailable, "LDAP search failed")
+	}
+	switch len(entries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return entries[0], nil
+	default:
+		return nil, errors.Newf(errors.ErrTargetResolutionFailed, "LDAP lookup for %s matched more than one entry", value)
+	}
+}
+
+// lookupDN reads an entry by distinguished name
+func (d *Directory) lookupDN(conn Conn, dn string) (*Entry, error) {
+	entries, err := conn.Search(&SearchRequest{
+		BaseDN:     dn,
+		Scope:      ScopeBaseObject,
+		Filter:     "(objectClass=*)",
+		Attributes: d.attributes(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrPlatformUnavailable, "LDAP search failed")
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return entries[0], nil
+}
+
+// attributes returns the attribute list requested in searches
+func (d *Directory) attributes() []string {
+	m := d.config.Attributes
+	attrs := []string{"objectClass", "cn", "uid", "sAMAccountName", "displayName", "mail", "mobile", "member", "uniqueMember"}
+	for _, a := range []string{m.ID, m.Name, m.Email, m.Phone, m.Member} {
+		if a != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	for _, a := range m.Channels {
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+// memberDNs returns the member DNs of a group entry
+func (d *Directory) memberDNs(entry *Entry) []string {
+	if d.config.Attributes.Member != "" {
+		return entry.GetAll(d.config.Attributes.Member)
+	}
+	return append(entry.GetAll("member"), entry.GetAll("uniqueMember")...)
+}
+
+// isGroup reports whether an entry is a group according to its objectClass
+func (d *Directory) isGroup(entry *Entry) bool {
+	for _, class := range entry.GetAll("objectClass") {
+		for _, groupClass := range d.config.GroupClasses {
+			if strings.EqualFold(class, groupClass) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toContact maps a directory entry to a contact
+func (d *Directory) toContact(entry *Entry) *contact.Contact {
+	m := d.config.Attributes
+	c := &contact.Contact{
+		ID:    firstValue(entry, m.ID, "sAMAccountName", "uid"),
+		Name:  firstValue(entry, m.Name, "displayName", "cn"),
+		Email: firstValue(entry, m.Email, "mail"),
+		Phone: firstValue(entry, m.Phone, "mobile"),
+	}
+	if c.ID == "" {
+		c.ID = entry.DN
+	}
+
+	for platform, attr := range m.Channels {
+		if v := entry.Get(attr); v != "" {
+			if c.Channels == nil {
+				c.Channels = make(map[string]string)
+			}
+			c.Channels[platform] = v
+		}
+	}
+	return c
+}
+
+// cached returns a cached value that has not expired
+func (d *Directory) cached(key string) (interface{}, bool) {
+	if d.config.CacheTTL < 0 {
+		return nil, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if d.now().After(entry.expires) {
+		delete(d.cache, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// store caches a value for the configured TTL. Expired entries are swept
+// at most once per TTL, so that lookups never repeated do not accumulate.
+func (d *Directory) store(key string, value interface{}) {
+	if d.config.CacheTTL < 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	if now.Sub(d.lastSweep) >= d.config.CacheTTL {
+		for k, entry := range d.cache {
+			if now.After(entry.expires) {
+				delete(d.cache, k)
+			}
+		}
+		d.lastSweep = now
+	}
+	d.cache[key] = cacheEntry{value: value, expires: now.Add(d.config.CacheTTL)}
+}
+
+// firstValue returns the value of the configured attribute, or of the first
+// fallback attribute that is present when no attribute is configured
+func firstValue(entry *Entry, configured string, fallbacks ...string) string {
+	if configured != "" {
+		return entry.Get(configured)
+	}
+	for _, attr := range fallbacks {
+		if v := entry.Get(attr); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// isDN reports whether a value looks like a distinguished name
+func isDN(value string) bool {
+	eq := strings.IndexByte(value, '=')
+	return eq > 0 && strings.Contains(value[eq:], ",")
+}
+
+// underBase reports whether a DN is the base DN or an entry below it
+func (d *Directory) underBase(dn string) bool {
+	rdns := splitDN(dn)
+	if len(rdns) < len(d.base) {
+		return false
+	}
+	offset := len(rdns) - len(d.base)
+	for i, rdn := range d.base {
+		if rdns[offset+i] != rdn {
+			return false
+		}
+	}
+	return true
+}
+
+// splitDN splits a DN into its RDNs, lowercased with the spaces around
+// them trimmed. Escaped separators stay in their RDN, so that a value such
+// as "CN=x\,DC=corp" is not taken for an entry under DC=corp.
+func splitDN(dn string) []string {
+	var rdns []string
+	var b strings.Builder
+	for i := 0; i < len(dn); i++ {
+		switch c := dn[i]; {
+		case c == '\\' && i+1 < len(dn):
+			b.WriteByte(c)
+			i++
+			b.WriteByte(dn[i])
+		case c == ',' || c == ';':
+			rdns = append(rdns, strings.ToLower(strings.TrimSpace(b.String())))
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(rdns, strings.ToLower(strings.TrimSpace(b.String())))
+}
+
+// Ensure Directory implements contact.Directory
+var _ contact.Directory = (*Directory)(nil)
+
+// String describes the directory without exposing credentials
+func (d *Directory) String() string {
+	return fmt.Sprintf("ldap.Directory{url=%s, base=%s}", d.config.URL, d.config.BaseDN)
+}
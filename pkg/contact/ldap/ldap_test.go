@@ -0,0 +1,334 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/contact"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// fakeConn serves searches from an in-memory set of entries
+type fakeConn struct {
+	entries  map[string]*Entry
+	searches *int
+	bound    string
+}
+
+func (f *fakeConn) Bind(dn, password string) error {
+	if password != "secret" {
+		return &ResultError{Code: ResultInvalidCredentials, Message: "invalid credentials"}
+	}
+	f.bound = dn
+	return nil
+}
+
+func (f *fakeConn) Search(req *SearchRequest) ([]*Entry, error) {
+	*f.searches++
+	if req.Scope == ScopeBaseObject {
+		if e, ok := f.entries[strings.ToLower(req.BaseDN)]; ok {
+			return []*Entry{e}, nil
+		}
+		return nil, nil
+	}
+
+	var out []*Entry
+	for _, e := range f.entries {
+		if strings.Contains(req.Filter, "(cn="+e.Get("cn")+")") && e.Get("mail") == "" {
+			out = append(out, e)
+		}
+		if strings.Contains(req.Filter, "(sAMAccountName="+e.Get("sAMAccountName")+")") && e.Get("mail") != "" {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeConn) Close() error { return nil }
+
+func newTestDirectory(t *testing.T, searches *int) *Directory {
+	t.Helper()
+	entries := []*Entry{
+		{DN: "CN=Platform Eng,OU=Groups,DC=corp,DC=example", Attributes: map[string][]string{
+			"objectClass": {"top", "group"},
+			"cn":          {"platform-eng"},
+			"member":      {"CN=Alice,OU=People,DC=corp,DC=example", "CN=SRE,OU=Groups,DC=corp,DC=example"},
+		}},
+		{DN: "CN=SRE,OU=Groups,DC=corp,DC=example", Attributes: map[string][]string{
+			"objectClass": {"top", "group"},
+			"cn":          {"sre"},
+			"member":      {"CN=Bob,OU=People,DC=corp,DC=example"},
+		}},
+		{DN: "CN=Alice,OU=People,DC=corp,DC=example", Attributes: map[string][]string{
+			"objectClass":    {"person", "user"},
+			"sAMAccountName": {"alice"},
+			"displayName":    {"Alice"},
+			"mail":           {"alice@corp.example"},
+			"feishuOpenId":   {"ou_alice"},
+		}},
+		{DN: "CN=Bob,OU=People,DC=corp,DC=example", Attributes: map[string][]string{
+			"objectClass":    {"person", "user"},
+			"sAMAccountName": {"bob"},
+			"mail":           {"bob@corp.example"},
+			"mobile":         {"+8613800138000"},
+		}},
+	}
+	byDN := make(map[string]*Entry)
+	for _, e := range entries {
+		byDN[strings.ToLower(e.DN)] = e
+	}
+
+	dir, err := New(Config{
+		URL:          "ldap://ad.corp.example",
+		BindDN:       "CN=notifyhub,OU=Service,DC=corp,DC=example",
+		BindPassword: "secret",
+		BaseDN:       "DC=corp,DC=example",
+		Attributes:   AttributeMapping{Channels: map[string]string{"feishu": "feishuOpenId"}},
+		CacheTTL:     time.Minute,
+	}, WithDialer(func(ctx context.Context, cfg *Config) (Conn, error) {
+		return &fakeConn{entries: byDN, searches: searches}, nil
+	}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return dir
+}
+
+func TestDirectory_ExpandTeam(t *testing.T) {
+	var searches int
+	dir := newTestDirectory(t, &searches)
+	expander := contact.NewExpander(dir)
+
+	expansion, err := expander.ExpandTarget(context.Background(), target.NewTeam("platform-eng"))
+	if err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+
+	var got []string
+	for _, tgt := range expansion.Targets {
+		got = append(got, tgt.String())
+	}
+	want := "email:email:alice@corp.example|email:email:bob@corp.example"
+	if strings.Join(got, "|") != want {
+		t.Errorf("ExpandTarget() = %v, want %v", got, want)
+	}
+
+	// a second expansion is served entirely from the cache
+	before := searches
+	if _, err := expander.ExpandTarget(context.Background(), target.NewTeam("platform-eng")); err != nil {
+		t.Fatalf("ExpandTarget() error = %v", err)
+	}
+	if searches != before {
+		t.Errorf("cached expansion performed %d searches, want 0", searches-before)
+	}
+}
+
+func TestDirectory_CacheTTL(t *testing.T) {
+	var searches int
+	dir := newTestDirectory(t, &searches)
+	now := time.Now()
+	dir.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	c, err := dir.GetContact(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetContact() error = %v", err)
+	}
+	if c.Name != "Alice" || c.Channels["feishu"] != "ou_alice" {
+		t.Errorf("GetContact() = %+v, want mapped attributes", c)
+	}
+
+	_, _ = dir.GetContact(ctx, "alice")
+	if searches != 1 {
+		t.Errorf("searches = %d, want 1 before expiry", searches)
+	}
+	_, _ = dir.GetContact(ctx, "bob")
+
+	now = now.Add(2 * time.Minute)
+	_, _ = dir.GetContact(ctx, "alice")
+	if searches != 3 {
+		t.Errorf("searches = %d, want 3 after expiry", searches)
+	}
+	// expired entries that are not looked up again are swept
+	if _, ok := dir.cache["contact:bob"]; ok || len(dir.cache) != 1 {
+		t.Errorf("cache holds %d entries, want the expired ones swept", len(dir.cache))
+	}
+
+	if _, err := dir.GetContact(ctx, "nobody"); !contact.IsNotFound(err) {
+		t.Errorf("GetContact() error = %v, want not found", err)
+	}
+}
+
+func TestDirectory_BaseDN(t *testing.T) {
+	var searches int
+	dir := newTestDirectory(t, &searches)
+	ctx := context.Background()
+
+	c, err := dir.GetContact(ctx, "cn=alice,ou=people,dc=corp,dc=example")
+	if err != nil || c.ID != "alice" {
+		t.Fatalf("GetContact() by DN = %+v, %v, want alice", c, err)
+	}
+
+	// DNs outside the base DN are not looked up
+	for _, dn := range []string{
+		"CN=Admin,OU=People,DC=other,DC=example",
+		"CN=Alice,OU=People,DC=corp,DC=example,DC=com",
+		`CN=x\,DC=corp,DC=example`,
+		"OU=People,DC=example",
+	} {
+		before := searches
+		if _, err := dir.GetContact(ctx, dn); !contact.IsNotFound(err) {
+			t.Errorf("GetContact(%s) error = %v, want not found", dn, err)
+		}
+		if _, err := dir.GetGroup(ctx, dn); !contact.IsNotFound(err) {
+			t.Errorf("GetGroup(%s) error = %v, want not found", dn, err)
+		}
+		if searches != before {
+			t.Errorf("lookups of %s performed %d searches, want 0", dn, searches-before)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"valid", Config{URL: "ldap://ad", BaseDN: "DC=corp"}, false},
+		{"missing url", Config{BaseDN: "DC=corp"}, true},
+		{"missing base", Config{URL: "ldap://ad"}, true},
+		{"bind without password", Config{URL: "ldap://ad", BaseDN: "DC=corp", BindDN: "CN=svc"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    []byte
+		wantErr bool
+	}{
+		{"equality", "(cn=a)", []byte{0xa3, 0x07, 0x04, 0x02, 'c', 'n', 0x04, 0x01, 'a'}, false},
+		{"present", "(mail=*)", []byte{0x87, 0x04, 'm', 'a', 'i', 'l'}, false},
+		{"escaped", `(cn=a\2a)`, []byte{0xa3, 0x08, 0x04, 0x02, 'c', 'n', 0x04, 0x02, 'a', '*'}, false},
+		{"substring", "(cn=a*)", []byte{0xa4, 0x09, 0x04, 0x02, 'c', 'n', 0x30, 0x03, 0x80, 0x01, 'a'}, false},
+		{"and", "(&(a=1)(!(b=2)))", []byte{0xa0, 0x12, 0xa3, 0x06, 0x04, 0x01, 'a', 0x04, 0x01, '1', 0xa2, 0x08, 0xa3, 0x06, 0x04, 0x01, 'b', 0x04, 0x01, '2'}, false},
+		{"unterminated", "(&(a=1)", nil, true},
+		{"bad item", "(abc)", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := compileFilter(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(p.bytes(), tt.want) {
+				t.Errorf("compileFilter() = % x, want % x", p.bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeFilter(t *testing.T) {
+	got := EscapeFilter(`a*(b)\`)
+	want := `a\2a\28b\29\5c`
+	if got != want {
+		t.Errorf("EscapeFilter() = %v, want %v", got, want)
+	}
+}
+
+func TestClient_BindAndSearch(t *testing.T) {
+	clientSide, serverSide := net.Pipe()
+	defer clientSide.Close()
+
+	go func() {
+		defer serverSide.Close()
+		r := bufio.NewReader(serverSide)
+		for {
+			msg, err := readPacket(r)
+			if err != nil {
+				return
+			}
+			id := msg.children[0].int()
+			reply := func(op *packet) {
+				_, _ = serverSide.Write(newSequence(tagSequence, newInteger(tagInteger, id), op).bytes())
+			}
+			result := func(tag byte) *packet {
+				return newSequence(tag,
+					newInteger(tagEnumerated, ResultSuccess),
+					newString(tagOctetString, ""),
+					newString(tagOctetString, ""),
+				)
+			}
+
+			switch msg.children[1].tag {
+			case opBindRequest:
+				reply(result(opBindResponse))
+			case opSearchRequest:
+				reply(newSequence(opSearchEntry,
+					newString(tagOctetString, "CN=Alice,DC=corp"),
+					newSequence(tagSequence,
+						newSequence(tagSequence,
+							newString(tagOctetString, "mail"),
+							newSequence(tagSet, newString(tagOctetString, "alice@corp.example")),
+						),
+					),
+				))
+				reply(result(opSearchDone))
+			case opUnbindRequest:
+				return
+			}
+		}
+	}()
+
+	c := newClient(clientSide, time.Second)
+	if err := c.Bind("CN=svc", "secret"); err != nil {
+		t.Fatalf("Bind() error = %v", err)
+	}
+	entries, err := c.Search(&SearchRequest{BaseDN: "DC=corp", Scope: ScopeWholeSubtree, Filter: "(mail=*)"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].DN != "CN=Alice,DC=corp" || entries[0].Get("MAIL") != "alice@corp.example" {
+		t.Errorf("Search() = %+v, want Alice entry", entries)
+	}
+	_ = c.Close()
+}
+
+func TestEncodeInteger(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x00, 0x80}},
+		{-1, []byte{0xff}},
+		{300, []byte{0x01, 0x2c}},
+	}
+
+	for _, tt := range tests {
+		got := encodeInteger(tt.n)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeInteger(%d) = % x, want % x", tt.n, got, tt.want)
+		}
+		if back := (&packet{value: got}).int(); back != tt.n {
+			t.Errorf("int() = %d, want %d", back, tt.n)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package contact provides tag queries for selecting contacts
+package contact
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+)
+
+// TagQuery is a boolean expression over contact tags, such as
+// "role:sre AND (region:eu OR region:us) AND NOT status:away".
+//
+// Terms are tags matched case-insensitively; they may use path.Match
+// wildcards ("region:*"). AND binds tighter than OR, NOT applies to the
+// following term and parentheses group expressions. Operators are
+// case-insensitive.
+type TagQuery struct {
+	raw  string
+	root tagNode
+}
+
+// tagNode is a node of a parsed tag query
+type tagNode interface {
+	match(tags []string) bool
+}
+
+type tagTerm string
+
+type tagNot struct{ operand tagNode }
+
+type tagAnd []tagNode
+
+type tagOr []tagNode
+
+func (t tagTerm) match(tags []string) bool {
+	for _, tag := range tags {
+		if matched, _ := path.Match(string(t), strings.ToLower(tag)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (n tagNot) match(tags []string) bool {
+	return !n.operand.match(tags)
+}
+
+func (a tagAnd) match(tags []string) bool {
+	for _, node := range a {
+		if !node.match(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o tagOr) match(tags []string) bool {
+	for _, node := range o {
+		if node.match(tags) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTagQuery parses a tag query expression
+func ParseTagQuery(query string) (*TagQuery, error) {
+	p := &tagParser{tokens: tokenizeTagQuery(query)}
+	if len(p.tokens) == 0 {
+		return nil, errors.New(errors.ErrInvalidTarget, "tag query cannot be empty")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, errors.Newf(errors.ErrInvalidTarget, "invalid tag query %q: %v", query, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, errors.Newf(errors.ErrInvalidTarget, "invalid tag query %q: unexpected %q", query, p.tokens[p.pos])
+	}
+	return &TagQuery{raw: query, root: root}, nil
+}
+
+// Match reports whether a set of tags satisfies the query
+func (q *TagQuery) Match(tags []string) bool {
+	return q.root.match(tags)
+}
+
+// String returns the query expression
+func (q *TagQuery) String() string {
+	return q.raw
+}
+
+// tokenizeTagQuery splits a query into terms, operators and parentheses
+func tokenizeTagQuery(query string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(query) {
+		for field != "" {
+			switch i := strings.IndexAny(field, "()"); {
+			case i < 0:
+				tokens = append(tokens, field)
+				field = ""
+			case i > 0:
+				tokens = append(tokens, field[:i])
+				field = field[i:]
+			default:
+				tokens = append(tokens, field[:1])
+				field = field[1:]
+			}
+		}
+	}
+	return tokens
+}
+
+// tagParser is a recursive descent parser for tag queries
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+// peek returns the next token, with operators upper-cased
+func (p *tagParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	token := p.tokens[p.pos]
+	if upper := strings.ToUpper(token); upper == "AND" || upper == "OR" || upper == "NOT" {
+		return upper
+	}
+	return token
+}
+
+func (p *tagParser) parseOr() (tagNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := tagOr{node}
+	for p.peek() == "OR" {
+		p.pos++
+		if node, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *tagParser) parseAnd() (tagNode, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := tagAnd{node}
+	for p.peek() == "AND" {
+		p.pos++
+		if node, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *tagParser) parseUnary() (tagNode, error) {
+	switch token := p.peek(); token {
+	case "":
+		return nil, fmt.Errorf("unexpected end of query")
+	case "NOT":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{operand}, nil
+	case "(":
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	case ")", "AND", "OR":
+		return nil, fmt.Errorf("unexpected %q", token)
+	default:
+		p.pos++
+		term := strings.ToLower(token)
+		if _, err := path.Match(term, ""); err != nil {
+			return nil, fmt.Errorf("invalid tag pattern %q", token)
+		}
+		return tagTerm(term), nil
+	}
+}
@@ -1,4 +1,8 @@
 // Package core provides core processing logic for NotifyHub
+//
+// Deprecated: the client of package notifyhub routes, sends and tracks
+// messages, and is the supported way to send them; create it with
+// notifyhub.NewClient or notifyhub.NewClientFromOptions.
 package core
 
 import (
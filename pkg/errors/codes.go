@@ -101,6 +101,9 @@ const (
 
 	// ErrPlatformRejected indicates the platform rejected the request
 	ErrPlatformRejected ErrorCode = "PLATFORM_REJECTED"
+
+	// ErrPlatformPanic indicates a platform sender panicked
+	ErrPlatformPanic ErrorCode = "PLATFORM_PANIC"
 )
 
 // Network Error Codes
@@ -298,6 +301,10 @@ var errorCodeInfoMap = map[ErrorCode]ErrorCodeInfo{
 		Code: ErrPlatformTimeout, Category: "platform", Description: "Platform operation timed out",
 		Priority: PriorityNormal, Retryable: true, UserFacing: true,
 	},
+	ErrPlatformPanic: {
+		Code: ErrPlatformPanic, Category: "platform", Description: "Platform sender panicked",
+		Priority: PriorityHigh, Retryable: false, UserFacing: false,
+	},
 
 	// Network errors
 	ErrNetworkTimeout: {
@@ -0,0 +1,88 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStatic(t *testing.T) {
+	ctx := context.Background()
+	static := NewStatic()
+	static.Disable("email", "")
+	static.Disable("slack", "rate limited by Slack")
+
+	tests := []struct {
+		platform    string
+		wantEnabled bool
+		wantReason  string
+	}{
+		{"email", false, "disabled by operator"},
+		{"slack", false, "rate limited by Slack"},
+		{"webhook", true, ""},
+	}
+	for _, tt := range tests {
+		enabled, reason, err := static.PlatformEnabled(ctx, Evaluation{Platform: tt.platform})
+		if err != nil || enabled != tt.wantEnabled || reason != tt.wantReason {
+			t.Errorf("PlatformEnabled(%s) = %v, %q, %v, want %v, %q", tt.platform, enabled, reason, err, tt.wantEnabled, tt.wantReason)
+		}
+	}
+
+	static.Enable("email")
+	if enabled, _, _ := static.PlatformEnabled(ctx, Evaluation{Platform: "email"}); !enabled {
+		t.Error("email is disabled after Enable()")
+	}
+	if disabled := static.Disabled(); len(disabled) != 1 || disabled["slack"] == "" {
+		t.Errorf("Disabled() = %v", disabled)
+	}
+}
+
+func TestFromEvaluator(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("flag service unavailable")
+
+	var attributes map[string]interface{}
+	provider := FromEvaluator(func(_ context.Context, key string, defaultValue bool, attrs map[string]interface{}) (bool, error) {
+		attributes = attrs
+		switch key {
+		case "notifyhub.platform.sms.enabled":
+			return false, nil
+		case "notifyhub.platform.feishu.enabled":
+			return false, failure
+		}
+		return defaultValue, nil
+	})
+
+	tests := []struct {
+		name        string
+		provider    Provider
+		platform    string
+		wantEnabled bool
+		wantReason  string
+		wantErr     error
+	}{
+		{"flag on by default", provider, "email", true, "", nil},
+		{"flag off", provider, "sms", false, `flag "notifyhub.platform.sms.enabled" is off`, nil},
+		{"evaluation error", provider, "feishu", true, "", failure},
+		{"static kill switch wins over an error", All(provider, staticDisabled("feishu")), "feishu", false, "maintenance", nil},
+		{"error after every provider enabled", All(staticDisabled("sms"), provider), "feishu", true, "", failure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled, reason, err := tt.provider.PlatformEnabled(ctx, Evaluation{Platform: tt.platform, MessageID: "msg-1", Priority: 2})
+			if enabled != tt.wantEnabled || reason != tt.wantReason || !errors.Is(err, tt.wantErr) {
+				t.Errorf("PlatformEnabled() = %v, %q, %v, want %v, %q, %v", enabled, reason, err, tt.wantEnabled, tt.wantReason, tt.wantErr)
+			}
+		})
+	}
+
+	if attributes["platform"] != "feishu" || attributes["message_id"] != "msg-1" || attributes["priority"] != 2 {
+		t.Errorf("attributes = %v", attributes)
+	}
+}
+
+func staticDisabled(platform string) *Static {
+	static := NewStatic()
+	static.Disable(platform, "maintenance")
+	return static
+}
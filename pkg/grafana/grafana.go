@@ -0,0 +1,187 @@
+// Package grafana provides the Grafana alerting webhook formats for
+// NotifyHub: the notifications of unified alerting, which extend those of
+// Alertmanager, and of legacy dashboard alerts convert to messages, which
+// the HTTP server (package server/http) receives.
+package grafana
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/alertmanager"
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// Payload is the body of a Grafana webhook notification: a unified
+// alerting notification has alerts, a legacy notification the rule and
+// state of a dashboard alert
+type Payload struct {
+	// Unified alerting
+	Receiver        string            `json:"receiver"`
+	Status          string            `json:"status"`
+	OrgID           int64             `json:"orgId"`
+	Alerts          []Alert           `json:"alerts"`
+	GroupLabels     map[string]string `json:"groupLabels"`
+	CommonLabels    map[string]string `json:"commonLabels"`
+	ExternalURL     string            `json:"externalURL"`
+	Version         string            `json:"version"`
+	GroupKey        string            `json:"groupKey"`
+	TruncatedAlerts int               `json:"truncatedAlerts"`
+
+	// Both formats
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	Message string `json:"message"`
+
+	// Legacy alerting
+	DashboardID int64             `json:"dashboardId"`
+	PanelID     int64             `json:"panelId"`
+	RuleID      int64             `json:"ruleId"`
+	RuleName    string            `json:"ruleName"`
+	RuleURL     string            `json:"ruleUrl"`
+	ImageURL    string            `json:"imageUrl"`
+	Tags        map[string]string `json:"tags"`
+	EvalMatches []EvalMatch       `json:"evalMatches"`
+}
+
+// Alert is an alert of a unified alerting notification
+type Alert struct {
+	alertmanager.Alert
+	SilenceURL   string             `json:"silenceURL"`
+	DashboardURL string             `json:"dashboardURL"`
+	PanelURL     string             `json:"panelURL"`
+	ImageURL     string             `json:"imageURL"`
+	Values       map[string]float64 `json:"values"`
+	ValueString  string             `json:"valueString"`
+}
+
+// EvalMatch is a series that triggered a legacy alert
+type EvalMatch struct {
+	Metric string            `json:"metric"`
+	Value  *float64          `json:"value"`
+	Tags   map[string]string `json:"tags"`
+}
+
+// Legacy alert states, of which ok resolves an alert
+const (
+	StateOK       = "ok"
+	StateAlerting = "alerting"
+	StateNoData   = "no_data"
+	StatePending  = "pending"
+	StatePaused   = "paused"
+)
+
+// Legacy reports whether the notification is of legacy alerting
+func (p *Payload) Legacy() bool {
+	return len(p.Alerts) == 0 && (p.RuleName != "" || p.RuleID != 0 || p.State != "")
+}
+
+// Messages returns a message for each alert of a notification. Unified
+// alerts convert as Alertmanager alerts do (see
+// alertmanager.Payload.Messages), with the values and the dashboard,
+// panel, image, silence and source URLs as links in the body. A legacy
+// notification is a single message with the title, message and matching
+// series of the alert and links to the rule and the panel image; its
+// labels are the tags and the rule name as alertname, and it is resolved
+// by the ok state. Every notification of an alert has the same alert
+// group, so that a resolved message updates the firing one.
+func (p *Payload) Messages() []*message.Message {
+	if p.Legacy() {
+		return []*message.Message{p.legacyMessage()}
+	}
+
+	alerts := make([]alertmanager.Alert, len(p.Alerts))
+	for i, alert := range p.Alerts {
+		alerts[i] = alert.Alert
+	}
+	am := alertmanager.Payload{GroupKey: p.GroupKey, Status: p.Status, Alerts: alerts}
+	messages := am.Messages()
+	for i, msg := range messages {
+		alert := p.Alerts[i]
+		description := alert.Annotations["description"]
+		if description == "" {
+			description = alert.Annotations["message"]
+		}
+		msg.Body = strings.TrimSpace(description + "\n\n" + links(
+			"Values", alert.ValueString,
+			"Dashboard", alert.DashboardURL,
+			"Panel", alert.PanelURL,
+			"Image", alert.ImageURL,
+			"Silence", alert.SilenceURL,
+			"Source", alert.GeneratorURL,
+		))
+		if p.GroupKey == "" {
+			msg.SetAlertGroup(alert.Fingerprint)
+		}
+	}
+	return messages
+}
+
+// legacyMessage returns the message of a legacy notification
+func (p *Payload) legacyMessage() *message.Message {
+	labels := make(map[string]string, len(p.Tags)+1)
+	for name, value := range p.Tags {
+		labels[name] = value
+	}
+	if p.RuleName != "" {
+		labels["alertname"] = p.RuleName
+	}
+
+	status := message.AlertFiring
+	if p.State == StateOK {
+		status = message.AlertResolved
+	}
+
+	title := p.Title
+	if title == "" {
+		title = p.RuleName
+	}
+
+	matches := make([]string, 0, len(p.EvalMatches))
+	for _, match := range p.EvalMatches {
+		value := "null"
+		if match.Value != nil {
+			value = fmt.Sprintf("%g", *match.Value)
+		}
+		matches = append(matches, match.Metric+formatTags(match.Tags)+" = "+value)
+	}
+	body := strings.TrimSpace(strings.Join(append([]string{p.Message}, matches...), "\n") + "\n\n" + links(
+		"Rule", p.RuleURL,
+		"Image", p.ImageURL,
+	))
+
+	msg := message.New().SetTitle(title).SetBody(body).SetPriority(alertmanager.Priority(labels["severity"]))
+	msg.SetAlertStatus(status).SetAlertLabels(labels)
+	msg.SetAlertGroup(fmt.Sprintf("grafana/%d/rule/%d", p.OrgID, p.RuleID))
+	return msg
+}
+
+// links formats the names and URLs of links given in pairs as lines,
+// leaving out empty URLs
+func links(pairs ...string) string {
+	lines := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			lines = append(lines, pairs[i]+": "+pairs[i+1])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatTags formats the tags of a series, e.g. {host="db-1"}
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%q", name, tags[name])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,118 @@
+package grafana
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+func TestPayload_Messages(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		legacy   bool
+		title    string
+		body     string
+		priority message.Priority
+		status   string
+		labels   map[string]string
+		group    string
+	}{
+		{
+			name: "unified firing",
+			payload: `{
+				"receiver": "notifyhub", "status": "firing", "orgId": 1, "version": "1",
+				"groupKey": "{}/{}:{alertname=\"HighCPU\"}", "state": "alerting",
+				"alerts": [{
+					"status": "firing",
+					"labels": {"alertname": "HighCPU", "instance": "web-1", "severity": "critical"},
+					"annotations": {"summary": "CPU above 90%", "description": "web-1 is busy"},
+					"generatorURL": "http://grafana/alerting/grafana/abc/view",
+					"fingerprint": "c6eadffa33fcdf37",
+					"silenceURL": "http://grafana/alerting/silence/new",
+					"dashboardURL": "http://grafana/d/xyz",
+					"panelURL": "http://grafana/d/xyz?viewPanel=2",
+					"imageURL": "http://grafana/render/panel.png",
+					"values": {"B": 95},
+					"valueString": "[ var='B' labels={instance=web-1} value=95 ]"
+				}]
+			}`,
+			title:    "CPU above 90%",
+			body:     "web-1 is busy\n\nValues: [ var='B' labels={instance=web-1} value=95 ]\nDashboard: http://grafana/d/xyz\nPanel: http://grafana/d/xyz?viewPanel=2\nImage: http://grafana/render/panel.png\nSilence: http://grafana/alerting/silence/new\nSource: http://grafana/alerting/grafana/abc/view",
+			priority: message.PriorityHigh,
+			status:   message.AlertFiring,
+			labels:   map[string]string{"alertname": "HighCPU", "instance": "web-1", "severity": "critical"},
+			group:    `{}/{}:{alertname="HighCPU"}`,
+		},
+		{
+			name: "unified resolved without group key",
+			payload: `{
+				"status": "resolved",
+				"alerts": [{
+					"status": "resolved",
+					"labels": {"alertname": "HighCPU"},
+					"annotations": {"message": "back to normal"},
+					"fingerprint": "c6eadffa33fcdf37"
+				}]
+			}`,
+			title:    "[RESOLVED] HighCPU",
+			body:     "back to normal",
+			priority: message.PriorityNormal,
+			status:   message.AlertResolved,
+			labels:   map[string]string{"alertname": "HighCPU"},
+			group:    "c6eadffa33fcdf37",
+		},
+		{
+			name: "legacy alerting",
+			payload: `{
+				"dashboardId": 1, "panelId": 2, "ruleId": 7, "orgId": 1,
+				"ruleName": "Panel Title alert", "ruleUrl": "http://grafana/d/xyz?panelId=2",
+				"state": "alerting", "title": "[Alerting] Panel Title alert", "message": "Disk almost full",
+				"imageUrl": "http://grafana/render/legacy.png",
+				"tags": {"severity": "warning"},
+				"evalMatches": [{"value": 95.5, "metric": "used", "tags": {"host": "db-1"}}, {"value": null, "metric": "free"}]
+			}`,
+			legacy:   true,
+			title:    "[Alerting] Panel Title alert",
+			body:     "Disk almost full\nused{host=\"db-1\"} = 95.5\nfree = null\n\nRule: http://grafana/d/xyz?panelId=2\nImage: http://grafana/render/legacy.png",
+			priority: message.PriorityNormal,
+			status:   message.AlertFiring,
+			labels:   map[string]string{"alertname": "Panel Title alert", "severity": "warning"},
+			group:    "grafana/1/rule/7",
+		},
+		{
+			name:     "legacy ok",
+			payload:  `{"orgId": 1, "ruleId": 7, "ruleName": "Panel Title alert", "state": "ok", "title": "[OK] Panel Title alert"}`,
+			legacy:   true,
+			title:    "[OK] Panel Title alert",
+			priority: message.PriorityNormal,
+			status:   message.AlertResolved,
+			labels:   map[string]string{"alertname": "Panel Title alert"},
+			group:    "grafana/1/rule/7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload Payload
+			if err := json.Unmarshal([]byte(tt.payload), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Legacy() != tt.legacy {
+				t.Errorf("Legacy() = %v, want %v", payload.Legacy(), tt.legacy)
+			}
+			messages := payload.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("Messages() = %d messages, want 1", len(messages))
+			}
+			msg := messages[0]
+			if msg.Title != tt.title || msg.Body != tt.body || msg.Priority != tt.priority {
+				t.Errorf("message = %q %q %d, want %q %q %d", msg.Title, msg.Body, msg.Priority, tt.title, tt.body, tt.priority)
+			}
+			if msg.AlertStatus() != tt.status || msg.AlertGroup() != tt.group || !reflect.DeepEqual(msg.AlertLabels(), tt.labels) {
+				t.Errorf("alert = %s %q %v, want %s %q %v", msg.AlertStatus(), msg.AlertGroup(), msg.AlertLabels(), tt.status, tt.group, tt.labels)
+			}
+		})
+	}
+}
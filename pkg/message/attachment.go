@@ -0,0 +1,82 @@
+// Package message provides message attachments for NotifyHub
+package message
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// Attachment is a file sent with a message, such as a report or a
+// screenshot. Platforms that support attachments send it the way they
+// send files: email attaches it, Feishu uploads it and sends it as an
+// image or file message.
+type Attachment struct {
+	Name string `json:"name"`
+
+	// ContentType is the media type of the content; empty detects it
+	// from the name
+	ContentType string `json:"content_type,omitempty"`
+
+	Content []byte `json:"content,omitempty"`
+
+	// Open opens the content to stream instead of Content, once for every
+	// send of the message; the reader is closed after it when it is an
+	// io.Closer
+	Open func() (io.Reader, error) `json:"-"`
+}
+
+// FileAttachment creates an attachment streamed from a file when the
+// message is sent, so that the file is not held in memory
+func FileAttachment(path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if info.IsDir() {
+		return Attachment{}, fmt.Errorf("attachment %s is a directory", path)
+	}
+	return Attachment{
+		Name: filepath.Base(path),
+		Open: func() (io.Reader, error) { return os.Open(path) },
+	}, nil
+}
+
+// MediaType returns the content type of the attachment, detected from its
+// name when none is set
+func (a Attachment) MediaType() string {
+	if a.ContentType != "" {
+		return a.ContentType
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(a.Name)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// Reader returns the reader of the attachment content, which the caller
+// closes when it is an io.Closer
+func (a Attachment) Reader() (io.Reader, error) {
+	if a.Open == nil {
+		return bytes.NewReader(a.Content), nil
+	}
+	r, err := a.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open attachment %s: %w", a.Name, err)
+	}
+	return r, nil
+}
+
+// AddAttachment adds attachments to the message
+func (m *Message) AddAttachment(attachments ...Attachment) *Message {
+	m.Attachments = append(m.Attachments, attachments...)
+	return m
+}
+
+// HasAttachments returns true if the message has attachments
+func (m *Message) HasAttachments() bool {
+	return len(m.Attachments) > 0
+}
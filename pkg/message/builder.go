@@ -93,6 +93,19 @@ func (b *Builder) SetMetadata(metadata map[string]interface{}) *Builder {
 	return b
 }
 
+// SetTopic sets the message topic used for per-topic contact preferences
+func (b *Builder) SetTopic(topic string) *Builder {
+	b.message.Metadata[MetadataTopic] = topic
+	return b
+}
+
+// Deferrable flags the message as non-urgent so that it may be held until
+// the recipient's delivery window opens
+func (b *Builder) Deferrable() *Builder {
+	b.message.Metadata[MetadataDeferrable] = true
+	return b
+}
+
 // AddVariable adds a template variable
 func (b *Builder) AddVariable(key string, value interface{}) *Builder {
 	b.message.Variables[key] = value
@@ -117,6 +130,12 @@ func (b *Builder) SetPlatformData(platformData map[string]interface{}) *Builder
 	return b
 }
 
+// AddAttachment adds attachments to the message
+func (b *Builder) AddAttachment(attachments ...Attachment) *Builder {
+	b.message.Attachments = append(b.message.Attachments, attachments...)
+	return b
+}
+
 // ScheduleAt sets when the message should be sent
 func (b *Builder) ScheduleAt(scheduledAt time.Time) *Builder {
 	b.message.ScheduledAt = &scheduledAt
@@ -162,6 +181,10 @@ func (b *Builder) Build() *Message {
 		}
 	}
 
+	if len(b.message.Attachments) > 0 {
+		msg.Attachments = append([]Attachment(nil), b.message.Attachments...)
+	}
+
 	return &msg
 }
 
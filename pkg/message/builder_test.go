@@ -273,6 +273,20 @@ func TestBuilder_SetPlatformData(t *testing.T) {
 	}
 }
 
+func TestBuilder_AddAttachment(t *testing.T) {
+	builder := NewBuilder()
+	result := builder.AddAttachment(Attachment{Name: "a.txt"}, Attachment{Name: "b.txt"})
+
+	if result != builder {
+		t.Error("AddAttachment should return builder for chaining")
+	}
+	msg := builder.Build()
+	builder.AddAttachment(Attachment{Name: "c.txt"})
+	if len(msg.Attachments) != 2 {
+		t.Errorf("Attachments length = %d, want 2", len(msg.Attachments))
+	}
+}
+
 func TestBuilder_Build(t *testing.T) {
 	builder := NewBuilder()
 	builder.SetTitle("Test").SetBody("Body")
@@ -327,3 +341,18 @@ func TestBuilder_Chaining(t *testing.T) {
 		t.Error("Variables not set correctly")
 	}
 }
+
+func BenchmarkBuilder_Build(b *testing.B) {
+	targets := []target.Target{target.NewEmail("ops@example.com"), target.NewWebhook("https://example.com/hook")}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewBuilder().
+			SetTitle("Disk full on db-1").
+			SetBody("The data volume of db-1 is 95% full").
+			SetPriority(PriorityHigh).
+			AddTargets(targets).
+			AddMetadata("team", "db").
+			AddVariable("host", "db-1").
+			Build()
+	}
+}
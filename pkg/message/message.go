@@ -21,6 +21,7 @@ type Message struct {
 	PlatformData map[string]interface{} `json:"platform_data,omitempty"`
 	CreatedAt    time.Time              `json:"created_at"`
 	ScheduledAt  *time.Time             `json:"scheduled_at,omitempty"`
+	Attachments  []Attachment           `json:"attachments,omitempty"`
 }
 
 // Format represents message format types
@@ -42,6 +43,65 @@ const (
 	PriorityUrgent Priority = 3
 )
 
+// MetadataTopic is the metadata key holding the message topic (e.g. "deploys"),
+// which selects per-topic contact preferences
+const MetadataTopic = "topic"
+
+// MetadataDeferrable is the metadata key flagging a non-urgent message that
+// may be held until the recipient's local delivery window
+const MetadataDeferrable = "deferrable"
+
+// MetadataTimeout, MetadataMaxRetries and MetadataPlatformOrder are the
+// metadata keys of per-message send settings, which override the client's
+// send defaults
+const (
+	MetadataTimeout       = "timeout"
+	MetadataMaxRetries    = "max_retries"
+	MetadataPlatformOrder = "platform_order"
+)
+
+// MetadataPlatforms is the metadata key holding the only platforms the
+// message may be delivered on
+const MetadataPlatforms = "platforms"
+
+// MetadataTenant is the metadata key holding the tenant the message is
+// sent for, which quotas may count separately
+const MetadataTenant = "tenant"
+
+// MetadataRequestID, MetadataUser and MetadataApp are the metadata keys
+// holding the request that caused the message, the user who sent it and
+// the application, or API key, it was sent through, see package sendctx
+const (
+	MetadataRequestID = "request_id"
+	MetadataUser      = "user"
+	MetadataApp       = "app"
+)
+
+// MetadataIdempotencyKey is the metadata key holding the key that
+// identifies a message sent again, such as by a replay, so that it is not
+// delivered twice
+const MetadataIdempotencyKey = "idempotency_key"
+
+// MetadataTags is the metadata key holding the tags of the message, which
+// label it for filtering and reporting
+const MetadataTags = "tags"
+
+// MetadataAlertStatus, MetadataAlertLabels and MetadataAlertGroup are the
+// metadata keys of a message reporting an alert: its status, AlertFiring
+// or AlertResolved, its labels, such as alertname and severity, and the
+// key of the group of alerts it belongs to
+const (
+	MetadataAlertStatus = "alert_status"
+	MetadataAlertLabels = "alert_labels"
+	MetadataAlertGroup  = "alert_group"
+)
+
+// Statuses of an alert
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
 // New creates a new message with default values
 func New() *Message {
 	return &Message{
@@ -91,6 +151,12 @@ func (m *Message) SetTargets(targets []target.Target) *Message {
 	return m
 }
 
+// ToTagQuery adds the directory contacts whose tags match a query, such as
+// "role:sre AND region:eu", as recipients
+func (m *Message) ToTagQuery(query string) *Message {
+	return m.AddTarget(target.NewTagQuery(query))
+}
+
 // SetMetadata sets metadata for the message
 func (m *Message) SetMetadata(key string, value interface{}) *Message {
 	if m.Metadata == nil {
@@ -100,6 +166,203 @@ func (m *Message) SetMetadata(key string, value interface{}) *Message {
 	return m
 }
 
+// SetTopic sets the message topic
+func (m *Message) SetTopic(topic string) *Message {
+	return m.SetMetadata(MetadataTopic, topic)
+}
+
+// Topic returns the message topic, or an empty string if none is set
+func (m *Message) Topic() string {
+	topic, _ := m.Metadata[MetadataTopic].(string)
+	return topic
+}
+
+// SetDeferrable flags the message as non-urgent so that it may be held
+// until the recipient's delivery window opens
+func (m *Message) SetDeferrable(deferrable bool) *Message {
+	return m.SetMetadata(MetadataDeferrable, deferrable)
+}
+
+// IsDeferrable reports whether the message may be held for a delivery
+// window. Urgent messages are never held.
+func (m *Message) IsDeferrable() bool {
+	deferrable, _ := m.Metadata[MetadataDeferrable].(bool)
+	return deferrable && m.Priority < PriorityUrgent
+}
+
+// SetTimeout bounds the delivery of the message to each target, overriding
+// the timeouts of the client's configuration but not a context deadline
+func (m *Message) SetTimeout(timeout time.Duration) *Message {
+	return m.SetMetadata(MetadataTimeout, timeout)
+}
+
+// Timeout returns the send timeout of the message, accepting durations and
+// duration strings such as "10s" from decoded messages
+func (m *Message) Timeout() (time.Duration, bool) {
+	switch v := m.Metadata[MetadataTimeout].(type) {
+	case time.Duration:
+		return v, true
+	case string:
+		timeout, err := time.ParseDuration(v)
+		return timeout, err == nil
+	}
+	return 0, false
+}
+
+// SetMaxRetries sets how often a failed send of the message is retried
+func (m *Message) SetMaxRetries(retries int) *Message {
+	return m.SetMetadata(MetadataMaxRetries, retries)
+}
+
+// MaxRetries returns the retry count of the message, if it sets one
+func (m *Message) MaxRetries() (int, bool) {
+	switch v := m.Metadata[MetadataMaxRetries].(type) {
+	case int:
+		return v, true
+	case float64: // decoded from JSON
+		return int(v), true
+	}
+	return 0, false
+}
+
+// SetPlatformOrder sets the order in which platforms are chosen for the
+// message's user and group targets that do not name one
+func (m *Message) SetPlatformOrder(platforms ...string) *Message {
+	return m.SetMetadata(MetadataPlatformOrder, platforms)
+}
+
+// PlatformOrder returns the platform order of the message, or nil
+func (m *Message) PlatformOrder() []string {
+	return m.stringsMetadata(MetadataPlatformOrder)
+}
+
+// SetPlatforms restricts the delivery of the message to platforms: targets
+// on any other platform are not delivered, whatever they or the routing
+// choose
+func (m *Message) SetPlatforms(platforms ...string) *Message {
+	return m.SetMetadata(MetadataPlatforms, platforms)
+}
+
+// Platforms returns the platforms the message is restricted to, or nil
+// when it may be delivered on any platform
+func (m *Message) Platforms() []string {
+	return m.stringsMetadata(MetadataPlatforms)
+}
+
+// SetTenant sets the tenant the message is sent for
+func (m *Message) SetTenant(tenant string) *Message {
+	return m.SetMetadata(MetadataTenant, tenant)
+}
+
+// Tenant returns the tenant of the message, or an empty string if none is set
+func (m *Message) Tenant() string {
+	tenant, _ := m.Metadata[MetadataTenant].(string)
+	return tenant
+}
+
+// SetTags sets the tags of the message
+func (m *Message) SetTags(tags ...string) *Message {
+	return m.SetMetadata(MetadataTags, tags)
+}
+
+// Tags returns the tags of the message, or nil
+func (m *Message) Tags() []string {
+	return m.stringsMetadata(MetadataTags)
+}
+
+// SetAlertStatus sets the status of the alert the message reports,
+// AlertFiring or AlertResolved
+func (m *Message) SetAlertStatus(status string) *Message {
+	return m.SetMetadata(MetadataAlertStatus, status)
+}
+
+// AlertStatus returns the status of the alert the message reports, or an
+// empty string if none is set
+func (m *Message) AlertStatus() string {
+	status, _ := m.Metadata[MetadataAlertStatus].(string)
+	return status
+}
+
+// SetAlertLabels sets the labels of the alert the message reports
+func (m *Message) SetAlertLabels(labels map[string]string) *Message {
+	return m.SetMetadata(MetadataAlertLabels, labels)
+}
+
+// AlertLabels returns the labels of the alert the message reports, or nil
+func (m *Message) AlertLabels() map[string]string {
+	switch v := m.Metadata[MetadataAlertLabels].(type) {
+	case map[string]string:
+		return v
+	case map[string]interface{}: // decoded from JSON
+		labels := make(map[string]string, len(v))
+		for name, value := range v {
+			if s, ok := value.(string); ok {
+				labels[name] = s
+			}
+		}
+		return labels
+	}
+	return nil
+}
+
+// SetAlertGroup sets the key of the group of alerts the message belongs to
+func (m *Message) SetAlertGroup(key string) *Message {
+	return m.SetMetadata(MetadataAlertGroup, key)
+}
+
+// AlertGroup returns the key of the group of alerts the message belongs
+// to, or an empty string if none is set
+func (m *Message) AlertGroup() string {
+	key, _ := m.Metadata[MetadataAlertGroup].(string)
+	return key
+}
+
+// stringsMetadata returns a list of strings held in the metadata
+func (m *Message) stringsMetadata(key string) []string {
+	switch v := m.Metadata[key].(type) {
+	case []string:
+		return v
+	case []interface{}: // decoded from JSON
+		values := make([]string, 0, len(v))
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Clone returns a copy of the message whose targets, metadata, variables,
+// platform data and attachments can be changed without changing the
+// message. Values held in the maps and attachment contents are not copied.
+func (m *Message) Clone() *Message {
+	c := *m
+	c.Targets = append([]target.Target(nil), m.Targets...)
+	c.Metadata = cloneMap(m.Metadata)
+	c.Variables = cloneMap(m.Variables)
+	c.PlatformData = cloneMap(m.PlatformData)
+	c.Attachments = append([]Attachment(nil), m.Attachments...)
+	if m.ScheduledAt != nil {
+		at := *m.ScheduledAt
+		c.ScheduledAt = &at
+	}
+	return &c
+}
+
+// cloneMap returns a shallow copy of a map, nil for nil
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
 // SetVariable sets a template variable
 func (m *Message) SetVariable(key string, value interface{}) *Message {
 	if m.Variables == nil {
@@ -131,7 +394,7 @@ func (m *Message) IsScheduled() bool {
 
 // Validate validates the message
 func (m *Message) Validate() error {
-	if m.Title == "" && m.Body == "" {
+	if m.Title == "" && m.Body == "" && len(m.Attachments) == 0 {
 		return errors.New(errors.ErrEmptyMessage, "message title and body cannot both be empty")
 	}
 	if len(m.Targets) == 0 {
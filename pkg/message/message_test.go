@@ -1,6 +1,11 @@
 package message
 
 import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -57,6 +62,17 @@ func TestMessage_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "attachments without title and body",
+			msg: &Message{
+				ID:          "msg-123",
+				Attachments: []Attachment{{Name: "chart.png", Content: []byte("png")}},
+				Targets: []target.Target{
+					target.NewEmail("test@example.com"),
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "missing targets",
 			msg: &Message{
@@ -286,3 +302,185 @@ func TestMessage_SetPlatformData(t *testing.T) {
 		t.Error("PlatformData[feishu] should not be nil")
 	}
 }
+
+func TestMessage_IsDeferrable(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *Message
+		want bool
+	}{
+		{"not flagged", New(), false},
+		{"flagged", New().SetDeferrable(true), true},
+		{"urgent is never deferred", New().SetDeferrable(true).SetPriority(PriorityUrgent), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.msg.IsDeferrable(); got != tt.want {
+			t.Errorf("%s: IsDeferrable() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMessage_SendSettings(t *testing.T) {
+	// Messages decoded from JSON carry strings, numbers and lists
+	var decoded Message
+	if err := json.Unmarshal([]byte(`{"metadata": {"timeout": "10s", "max_retries": 2, "platform_order": ["slack", "email"]}}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		msg         *Message
+		wantTimeout time.Duration
+		wantRetries int
+		wantOrder   []string
+		wantSet     bool
+	}{
+		{"unset", New(), 0, 0, nil, false},
+		{"set", New().SetTimeout(5 * time.Second).SetMaxRetries(0).SetPlatformOrder("feishu"), 5 * time.Second, 0, []string{"feishu"}, true},
+		{"decoded", &decoded, 10 * time.Second, 2, []string{"slack", "email"}, true},
+	}
+
+	for _, tt := range tests {
+		timeout, timeoutSet := tt.msg.Timeout()
+		retries, retriesSet := tt.msg.MaxRetries()
+		if timeout != tt.wantTimeout || retries != tt.wantRetries || timeoutSet != tt.wantSet || retriesSet != tt.wantSet {
+			t.Errorf("%s: Timeout() = %v, %v, MaxRetries() = %d, %v", tt.name, timeout, timeoutSet, retries, retriesSet)
+		}
+		if order := tt.msg.PlatformOrder(); !reflect.DeepEqual(order, tt.wantOrder) {
+			t.Errorf("%s: PlatformOrder() = %v, want %v", tt.name, order, tt.wantOrder)
+		}
+	}
+
+	if tags := New().SetTags("billing", "eu").Tags(); !reflect.DeepEqual(tags, []string{"billing", "eu"}) {
+		t.Errorf("Tags() = %v", tags)
+	}
+}
+
+func TestMessage_Alert(t *testing.T) {
+	msg := New().SetAlertStatus(AlertResolved).SetAlertLabels(map[string]string{"alertname": "DiskFull"}).SetAlertGroup("{}:{}")
+	if msg.AlertStatus() != AlertResolved || !reflect.DeepEqual(msg.AlertLabels(), map[string]string{"alertname": "DiskFull"}) {
+		t.Errorf("AlertStatus() = %q, AlertLabels() = %v", msg.AlertStatus(), msg.AlertLabels())
+	}
+	if msg.AlertGroup() != "{}:{}" {
+		t.Errorf("AlertGroup() = %q", msg.AlertGroup())
+	}
+
+	var decoded Message
+	if err := json.Unmarshal([]byte(`{"metadata": {"alert_status": "firing", "alert_labels": {"alertname": "DiskFull", "count": 2}}}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.AlertStatus() != AlertFiring || !reflect.DeepEqual(decoded.AlertLabels(), map[string]string{"alertname": "DiskFull"}) {
+		t.Errorf("decoded AlertStatus() = %q, AlertLabels() = %v", decoded.AlertStatus(), decoded.AlertLabels())
+	}
+	if New().AlertLabels() != nil {
+		t.Errorf("AlertLabels() of a message without labels is not nil")
+	}
+}
+
+func TestAttachment(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.7"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := FileAttachment(path)
+	if err != nil {
+		t.Fatalf("FileAttachment() error = %v", err)
+	}
+	if _, err := FileAttachment(dir); err == nil {
+		t.Error("FileAttachment() of a directory should fail")
+	}
+
+	tests := []struct {
+		name            string
+		att             Attachment
+		wantContentType string
+		wantContent     string
+	}{
+		{name: "in memory", att: Attachment{Name: "notes.txt", Content: []byte("hello")}, wantContentType: "text/plain; charset=utf-8", wantContent: "hello"},
+		{name: "explicit content type", att: Attachment{Name: "data", ContentType: "application/json", Content: []byte("{}")}, wantContentType: "application/json", wantContent: "{}"},
+		{name: "unknown extension", att: Attachment{Name: "blob.xyz123"}, wantContentType: "application/octet-stream"},
+		{name: "file", att: file, wantContentType: "application/pdf", wantContent: "%PDF-1.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.att.MediaType(); got != tt.wantContentType {
+				t.Errorf("MediaType() = %q, want %q", got, tt.wantContentType)
+			}
+			r, err := tt.att.Reader()
+			if err != nil {
+				t.Fatalf("Reader() error = %v", err)
+			}
+			if closer, ok := r.(io.Closer); ok {
+				defer func() { _ = closer.Close() }()
+			}
+			content, _ := io.ReadAll(r)
+			if string(content) != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestMessage_CloneAttachments(t *testing.T) {
+	msg := New()
+	msg.AddAttachment(Attachment{Name: "a.txt"})
+	clone := msg.Clone()
+	clone.AddAttachment(Attachment{Name: "b.txt"})
+	clone.Attachments[0].Name = "changed.txt"
+
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Name != "a.txt" {
+		t.Errorf("original attachments changed to %+v", msg.Attachments)
+	}
+	if !clone.HasAttachments() || len(clone.Attachments) != 2 {
+		t.Errorf("clone attachments = %+v", clone.Attachments)
+	}
+}
+
+func FuzzMessage_UnmarshalJSON(f *testing.F) {
+	f.Add([]byte(`{"id":"msg-1","title":"Deploy","body":"done","format":"markdown","priority":2,"targets":[{"type":"email","value":"ops@example.com","platform":"email"}],"created_at":"2026-01-02T03:04:05Z"}`))
+	f.Add([]byte(`{"metadata":{"timeout":"5s","max_retries":3,"platform_order":["feishu","email"],"tags":["a",1],"alert_labels":{"severity":"critical"},"deferrable":true}}`))
+	f.Add([]byte(`{"metadata":{"timeout":-1,"max_retries":"x","platforms":null},"variables":{"n":1e308},"scheduled_at":null}`))
+	f.Add([]byte(`{"attachments":[{"name":"a.txt","content":"aGk="}],"platform_data":{"feishu":{"card":{}}}}`))
+	f.Add([]byte(`[]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return
+		}
+
+		// Accessors of producer-supplied metadata must not panic on any type
+		msg.Topic()
+		msg.IsDeferrable()
+		msg.Timeout()
+		msg.MaxRetries()
+		msg.PlatformOrder()
+		msg.Platforms()
+		msg.Tenant()
+		msg.Tags()
+		msg.AlertStatus()
+		msg.AlertLabels()
+		msg.AlertGroup()
+		msg.IsScheduled()
+		_ = msg.Validate()
+		_ = DefaultValidator().Validate(&msg)
+
+		encoded, err := json.Marshal(msg.Clone())
+		if err != nil {
+			t.Fatalf("Marshal() of a decoded message error = %v", err)
+		}
+		var again Message
+		if err := json.Unmarshal(encoded, &again); err != nil {
+			t.Fatalf("Unmarshal() of %s error = %v", encoded, err)
+		}
+		reencoded, err := json.Marshal(&again)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(reencoded) != string(encoded) {
+			t.Errorf("round trip changed the message:\n%s\n%s", encoded, reencoded)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// Package notifyhub provides the archiving and auditing of completed messages
+package notifyhub
+
+import (
+	"context"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/archive"
+	"github.com/kart-io/notifyhub/pkg/audit"
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/sendctx"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// startArchiver starts writing completed messages to the configured
+// archive store. It returns nil when no archive is configured.
+func startArchiver(cfg *config.Config, log logger.Logger) *archive.Archiver {
+	store := cfg.ArchiveStore
+	if store == nil {
+		store = cfg.Archive.Store()
+	}
+	if store == nil {
+		return nil
+	}
+	archiver := archive.New(store, archive.Options{
+		Prefix:            cfg.Archive.Prefix,
+		Interval:          cfg.Archive.Interval,
+		MaxRecords:        cfg.Archive.MaxRecords,
+		PartitionByTenant: cfg.Archive.PartitionByTenant,
+		Logger:            log,
+	})
+	archiver.Start()
+	log.Info("Message archive enabled", "prefix", cfg.Archive.Prefix)
+	return archiver
+}
+
+// archive buffers a message whose send completed for the archive. Sends
+// that are still pending, such as scheduled ones, are archived by the send
+// that completes them.
+func (c *clientImpl) archive(msg *message.Message, rcpt *receipt.Receipt) {
+	if c.archiver == nil || rcpt == nil || !rcpt.IsComplete() {
+		return
+	}
+	c.archiver.Add(msg, rcpt)
+}
+
+// auditSend records the audit entry of a completed send
+func (c *clientImpl) auditSend(ctx context.Context, msg *message.Message, rcpt *receipt.Receipt) {
+	if !c.config.AuditSends || c.config.Audit == nil || rcpt == nil || !rcpt.IsComplete() {
+		return
+	}
+	entry := audit.Entry{
+		Time:        time.Now(),
+		Action:      audit.ActionMessageSent,
+		MessageID:   msg.ID,
+		Metadata:    sendctx.FromMessage(msg).Metadata(),
+		Status:      rcpt.Status,
+		ContentHash: audit.ContentHash(msg.Title, msg.Body),
+	}
+	if c.config.AuditContent == nil || *c.config.AuditContent {
+		entry.Content = &audit.Content{Title: msg.Title, Body: msg.Body}
+	}
+	if err := c.config.Audit.Record(ctx, entry); err != nil {
+		c.log(ctx).Error("Failed to record audit entry", "action", entry.Action, "error", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package notifyhub provides batch sends and their modes
+package notifyhub
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+)
+
+// BatchMode selects how SendBatch handles invalid and failed messages
+type BatchMode string
+
+// Batch modes
+const (
+	// BatchBestEffort sends every message and returns the last error
+	BatchBestEffort BatchMode = "best_effort"
+
+	// BatchAllOrNothing validates every message before any is sent, and
+	// sends none when one is invalid
+	BatchAllOrNothing BatchMode = "all_or_nothing"
+
+	// BatchStopOnError sends the messages in order and stops at the first
+	// that fails, or whose receipt records a failed target
+	BatchStopOnError BatchMode = "stop_on_error"
+)
+
+// BatchOption configures a SendBatch
+type BatchOption func(*batchOptions)
+
+// batchOptions are the settings of a SendBatch
+type batchOptions struct {
+	mode BatchMode
+}
+
+// WithBatchMode sets the mode of a batch, BatchBestEffort by default:
+//
+//	receipts, err := client.SendBatch(ctx, msgs, notifyhub.WithBatchMode(notifyhub.BatchAllOrNothing))
+func WithBatchMode(mode BatchMode) BatchOption {
+	return func(o *batchOptions) {
+		o.mode = mode
+	}
+}
+
+// SendBatch sends multiple messages synchronously. It returns a receipt for
+// each message, recording the mode of the batch; messages a batch does not
+// send have an "aborted" receipt.
+func (c *clientImpl) SendBatch(ctx context.Context, msgs []*message.Message, opts ...BatchOption) ([]*receiptpkg.Receipt, error) {
+	options := batchOptions{mode: BatchBestEffort}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	receipts := make([]*receiptpkg.Receipt, len(msgs))
+	switch options.mode {
+	case BatchBestEffort, BatchStopOnError:
+	case BatchAllOrNothing:
+		for i, msg := range msgs {
+			err := msg.Validate()
+			if err == nil {
+				err = c.validateTargets(msg)
+			}
+			if err != nil {
+				abortBatch(msgs, receipts, 0, options.mode)
+				return receipts, fmt.Errorf("batch rejected, message %d (%s) is invalid: %w", i, msg.ID, err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid batch mode %q", options.mode)
+	}
+
+	var lastErr error
+	for i, msg := range msgs {
+		receipt, err := c.Send(ctx, msg)
+		if receipt != nil {
+			receipt.BatchMode = string(options.mode)
+		}
+		receipts[i] = receipt
+		if err != nil {
+			lastErr = err
+		}
+
+		if options.mode != BatchStopOnError {
+			continue
+		}
+		if err == nil && receipt != nil && receipt.Failed > 0 {
+			err = fmt.Errorf("%d of %d targets failed", receipt.Failed, receipt.Total)
+		}
+		if err != nil {
+			abortBatch(msgs, receipts, i+1, options.mode)
+			return receipts, fmt.Errorf("batch stopped at message %d (%s): %w", i, msg.ID, err)
+		}
+	}
+
+	return receipts, lastErr
+}
+
+// SendStream sends the messages of a sequence in order, yielding the
+// receipt and error of each once it is sent, so that large batches are
+// neither built nor answered as whole slices:
+//
+//	for receipt, err := range client.(notifyhub.StreamSender).SendStream(ctx, messages) {
+//		...
+//	}
+//
+// A message is taken from msgs only when the receipt of the previous one
+// was consumed, and stopping the iteration stops the sends. Every message
+// is sent like with Send; when the context ends, its error is yielded once
+// and no more messages are sent.
+func (c *clientImpl) SendStream(ctx context.Context, msgs iter.Seq[*message.Message], opts ...SendOption) iter.Seq2[*receiptpkg.Receipt, error] {
+	return func(yield func(*receiptpkg.Receipt, error) bool) {
+		for msg := range msgs {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(c.Send(ctx, msg, opts...)) {
+				return
+			}
+		}
+	}
+}
+
+// abortBatch records the messages of a batch from index from on as not sent
+func abortBatch(msgs []*message.Message, receipts []*receiptpkg.Receipt, from int, mode BatchMode) {
+	for i := from; i < len(msgs); i++ {
+		receipt := receiptpkg.New(msgs[i].ID)
+		receipt.Status = receiptpkg.StatusAborted
+		receipt.BatchMode = string(mode)
+		receipts[i] = receipt
+	}
+}
@@ -0,0 +1,411 @@
+// Package callback receives the delivery callbacks of platforms, such as
+// Feishu read receipts, Twilio status callbacks and the bounces an email
+// provider posts, and correlates them to the receipts of the sends they
+// are about, which then report targets as delivered, read or bounced
+// rather than only sent:
+//
+//	receiver := callback.NewReceiver(
+//		callback.WithProvider("feishu", callback.Feishu{Handler: feishuCallbacks}),
+//		callback.WithProvider("twilio", callback.Twilio{AuthToken: token, URL: "https://hooks.example.org/callbacks/twilio"}),
+//		callback.WithProvider("email", callback.Bounces{Parser: &email.FeedbackParser{Domain: "example.org"}, Token: secret}),
+//	)
+//	client.Use(receiver.Track)
+//	http.Handle("/callbacks/", receiver)
+//
+// Each provider is served at the path ending in its name.
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// ErrUnauthorized is returned by providers for callbacks whose signature
+// or token is invalid
+var ErrUnauthorized = errors.New("callback is not authorized")
+
+// ErrNotFound is returned by stores for messages they do not track
+var ErrNotFound = errors.New("message not tracked")
+
+// DefaultRetention is how long a MemoryStore tracks a message by default
+const DefaultRetention = 24 * time.Hour
+
+// maxBody caps the size of a callback request, which may quote a bounced
+// email
+const maxBody = 10 << 20
+
+// Event is the outcome of a delivery that a platform reported after the
+// send
+type Event struct {
+	Platform string `json:"platform"`
+
+	// MessageID is the NotifyHub ID of the message, when the callback
+	// carries it; otherwise the message is found by ProviderID
+	MessageID string `json:"message_id,omitempty"`
+
+	// ProviderID is the ID the platform gave the message, the MessageID of
+	// its result
+	ProviderID string `json:"provider_id,omitempty"`
+
+	// Target is the address the event is about; empty matches the result
+	// of ProviderID
+	Target string `json:"target,omitempty"`
+
+	// Status is receipt.ResultDelivered, ResultRead, ResultReplied or
+	// ResultBounced
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Provider verifies and parses the callbacks of a platform
+type Provider interface {
+	// Parse returns the events of a callback, failing with ErrUnauthorized
+	// when it cannot be verified. A non-nil reply is written as the JSON
+	// response, such as the answer to a verification challenge.
+	Parse(r *http.Request, body []byte) (events []Event, reply interface{}, err error)
+}
+
+// Store keeps the receipts of tracked messages
+type Store interface {
+	// Save stores the receipt of a message, replacing the one it had
+	Save(ctx context.Context, r *receipt.Receipt) error
+
+	// Load returns the receipt of a message, ErrNotFound when it is not
+	// tracked
+	Load(ctx context.Context, messageID string) (*receipt.Receipt, error)
+
+	// Find returns the ID of the message a platform gave providerID,
+	// ErrNotFound when it is not tracked
+	Find(ctx context.Context, platform, providerID string) (string, error)
+}
+
+// MemoryStore implements Store in memory, forgetting messages after its
+// retention
+type MemoryStore struct {
+	retention time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	receipts map[string]*trackedReceipt
+	provider map[string]string // platform and provider ID to message ID
+}
+
+// trackedReceipt is a receipt of a MemoryStore
+type trackedReceipt struct {
+	receipt *receipt.Receipt
+	saved   time.Time
+}
+
+// NewMemoryStore creates an in-memory store tracking messages for a
+// retention, DefaultRetention when zero
+func NewMemoryStore(retention time.Duration) *MemoryStore {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &MemoryStore{
+		retention: retention,
+		now:       time.Now,
+		receipts:  make(map[string]*trackedReceipt),
+		provider:  make(map[string]string),
+	}
+}
+
+// Save implements Store
+func (s *MemoryStore) Save(ctx context.Context, r *receipt.Receipt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.receipts[r.MessageID] = &trackedReceipt{receipt: clone(r), saved: s.now()}
+	for _, result := range r.Results {
+		if result.MessageID != "" {
+			s.provider[result.Platform+"\x00"+result.MessageID] = r.MessageID
+		}
+	}
+	return nil
+}
+
+// Load implements Store
+func (s *MemoryStore) Load(ctx context.Context, messageID string) (*receipt.Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tracked, ok := s.receipts[messageID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(tracked.receipt), nil
+}
+
+// Find implements Store
+func (s *MemoryStore) Find(ctx context.Context, platform, providerID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.provider[platform+"\x00"+providerID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return id, nil
+}
+
+// prune forgets the messages saved before the retention; s.mu is held
+func (s *MemoryStore) prune() {
+	cutoff := s.now().Add(-s.retention)
+	for id, tracked := range s.receipts {
+		if !tracked.saved.Before(cutoff) {
+			continue
+		}
+		delete(s.receipts, id)
+		for _, result := range tracked.receipt.Results {
+			delete(s.provider, result.Platform+"\x00"+result.MessageID)
+		}
+	}
+}
+
+// clone returns a copy of a receipt that shares no results with it
+func clone(r *receipt.Receipt) *receipt.Receipt {
+	c := *r
+	c.Results = append([]receipt.PlatformResult(nil), r.Results...)
+	return &c
+}
+
+// Option configures a Receiver
+type Option func(*Receiver)
+
+// WithProvider serves the callbacks of a provider at the path ending in
+// name, such as /callbacks/twilio for "twilio"
+func WithProvider(name string, p Provider) Option {
+	return func(r *Receiver) {
+		r.providers[name] = p
+	}
+}
+
+// WithStore sets the store of the tracked receipts, a MemoryStore with
+// DefaultRetention by default; processes sharing a store correlate the
+// callbacks of each other's sends
+func WithStore(s Store) Option {
+	return func(r *Receiver) {
+		r.store = s
+	}
+}
+
+// WithLogger sets the logger of the receiver
+func WithLogger(l logger.Logger) Option {
+	return func(r *Receiver) {
+		r.logger = l
+	}
+}
+
+// WithUpdateHook calls hook with the updated receipt after each event that
+// changes it, such as to publish deliveries or alert on bounces
+func WithUpdateHook(hook func(ctx context.Context, r *receipt.Receipt, event Event)) Option {
+	return func(r *Receiver) {
+		r.hooks = append(r.hooks, hook)
+	}
+}
+
+// Receiver receives delivery callbacks and updates the receipts of the
+// messages they are about. It is an http.Handler to mount on a server, or
+// runs its own with ListenAndServe.
+type Receiver struct {
+	providers map[string]Provider
+	store     Store
+	logger    logger.Logger
+	hooks     []func(ctx context.Context, r *receipt.Receipt, event Event)
+
+	mu sync.Mutex // serializes the updates of receipts
+}
+
+// NewReceiver creates a callback receiver
+func NewReceiver(opts ...Option) *Receiver {
+	r := &Receiver{
+		providers: make(map[string]Provider),
+		logger:    logger.Discard,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.store == nil {
+		r.store = NewMemoryStore(DefaultRetention)
+	}
+	return r
+}
+
+// Track is a middleware tracking the receipt of every send, so that the
+// callbacks about it can be correlated:
+//
+//	client.Use(receiver.Track)
+func (r *Receiver) Track(ctx context.Context, msg *message.Message, next notifyhub.SendFunc) (*receipt.Receipt, error) {
+	rcpt, err := next(ctx, msg)
+	if rcpt != nil {
+		if saveErr := r.store.Save(ctx, rcpt); saveErr != nil {
+			r.logger.Warn("Failed to track receipt", "message_id", rcpt.MessageID, "error", saveErr)
+		}
+	}
+	return rcpt, err
+}
+
+// Receipt returns the receipt of a tracked message, updated with the
+// callbacks received about it
+func (r *Receiver) Receipt(ctx context.Context, messageID string) (*receipt.Receipt, error) {
+	return r.store.Load(ctx, messageID)
+}
+
+// Apply updates the receipts of the messages events are about and returns
+// how many results changed. Events about messages that are not tracked,
+// or that would not change their result, are ignored.
+func (r *Receiver) Apply(ctx context.Context, events ...Event) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var updated int
+	for _, event := range events {
+		id := event.MessageID
+		if id == "" && event.ProviderID != "" {
+			var err error
+			id, err = r.store.Find(ctx, event.Platform, event.ProviderID)
+			if errors.Is(err, ErrNotFound) {
+				r.logger.Debug("Callback about an untracked message", "platform", event.Platform, "provider_id", event.ProviderID)
+				continue
+			}
+			if err != nil {
+				return updated, err
+			}
+		}
+		rcpt, err := r.store.Load(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			r.logger.Debug("Callback about an untracked message", "platform", event.Platform, "message_id", id)
+			continue
+		}
+		if err != nil {
+			return updated, err
+		}
+
+		changed := applyEvent(rcpt, event)
+		if changed == 0 {
+			continue
+		}
+		if err := r.store.Save(ctx, rcpt); err != nil {
+			return updated, fmt.Errorf("failed to save receipt of %s: %w", id, err)
+		}
+		updated += changed
+		r.logger.Debug("Delivery callback applied", "platform", event.Platform, "message_id", id, "status", event.Status, "receipt_status", rcpt.Status)
+		for _, hook := range r.hooks {
+			hook(ctx, rcpt, event)
+		}
+	}
+	return updated, nil
+}
+
+// applyEvent updates the results of a receipt an event is about and
+// returns how many changed. A result is not moved back from read or
+// replied to delivered, as callbacks may arrive out of order.
+func applyEvent(rcpt *receipt.Receipt, event Event) int {
+	var changed int
+	for i, result := range rcpt.Results {
+		switch {
+		case result.IsSkipped(), event.Platform != "" && result.Platform != event.Platform:
+			continue
+		case event.ProviderID != "" && result.MessageID == event.ProviderID:
+		case event.Target != "" && result.Target == event.Target:
+		default:
+			continue
+		}
+		if result.Status == event.Status || (event.Status == receipt.ResultDelivered && result.IsConfirmed()) {
+			continue
+		}
+
+		result.Status = event.Status
+		result.Success = event.Status != receipt.ResultBounced
+		result.Error = ""
+		if !result.Success {
+			result.Error = event.Error
+		}
+		rcpt.UpdateResult(i, result)
+		changed++
+	}
+	return changed
+}
+
+// ServeHTTP implements http.Handler, passing a callback to the provider
+// named by the last element of its path
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Base(req.URL.Path)
+	provider, ok := r.providers[name]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBody))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	events, reply, err := provider.Parse(req, body)
+	if errors.Is(err, ErrUnauthorized) {
+		r.logger.Warn("Unauthorized delivery callback", "provider", name, "error", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		r.logger.Warn("Invalid delivery callback", "provider", name, "error", err)
+		http.Error(w, "invalid callback", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := r.Apply(req.Context(), events...)
+	if err != nil {
+		r.logger.Error("Failed to apply delivery callback", "provider", name, "error", err)
+		http.Error(w, "failed to apply callback", http.StatusInternalServerError)
+		return
+	}
+	if reply == nil {
+		reply = map[string]int{"events": len(events), "updated": updated}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+// ListenAndServe serves the callbacks on an address until the context
+// ends, then shuts the server down
+func (r *Receiver) ListenAndServe(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: r, ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdown); err != nil {
+			return err
+		}
+		return nil
+	}
+}
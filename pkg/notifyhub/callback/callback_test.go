@@ -0,0 +1,316 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platforms/email"
+	"github.com/kart-io/notifyhub/pkg/platforms/feishu"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+)
+
+// sentReceipt returns the receipt of a message sent to Feishu, SMS and
+// email
+func sentReceipt() *receipt.Receipt {
+	r := receipt.New("msg-1")
+	r.AddResult(receipt.PlatformResult{Platform: "feishu", Target: "oc_chat", Success: true, MessageID: "om_1"})
+	r.AddResult(receipt.PlatformResult{Platform: "sms", Target: "+15551234567", Success: true, MessageID: "SM1"})
+	r.AddResult(receipt.PlatformResult{Platform: "email", Target: "alice@example.org", Success: true})
+	return r
+}
+
+// bounce returns a delivery status notification about msg-1
+func bounce(recipient string) string {
+	return "From: MAILER-DAEMON@mx.example.org\r\n" +
+		"To: bounces@example.com\r\n" +
+		"Subject: Undelivered Mail Returned to Sender\r\n" +
+		"Date: Sun, 01 Mar 2026 10:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/report; report-type=delivery-status; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: message/delivery-status\r\n" +
+		"\r\n" +
+		"Reporting-MTA: dns; mx.example.org\r\n" +
+		"\r\n" +
+		"Final-Recipient: rfc822; " + recipient + "\r\n" +
+		"Action: failed\r\n" +
+		"Status: 5.1.1\r\n" +
+		"Diagnostic-Code: smtp; 550 5.1.1 mailbox unavailable\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/rfc822-headers\r\n" +
+		"\r\n" +
+		"Message-ID: <msg-1@example.com>\r\n" +
+		"X-NotifyHub-Message-ID: msg-1\r\n" +
+		"\r\n" +
+		"--b1--\r\n"
+}
+
+func TestReceiver(t *testing.T) {
+	const twilioURL = "https://hooks.example.org/callbacks/twilio"
+	feishuRead := `{"schema":"2.0","header":{"event_type":"im.message.message_read_v1","token":"verify-token"},"event":{"reader":{"reader_id":{"open_id":"ou_1"},"read_time":"1700000000000"},"message_id_list":["om_1","om_unknown"]}}`
+	twilioForm := func(status string) url.Values {
+		return url.Values{"MessageSid": {"SM1"}, "MessageStatus": {status}, "To": {"+15551234567"}, "ErrorCode": {"30003"}}
+	}
+	form := func() (string, string) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		_ = w.WriteField("email", bounce("alice@example.org"))
+		_ = w.Close()
+		return body.String(), w.FormDataContentType()
+	}
+	formBody, formType := form()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		contentType string
+		header      map[string]string
+		wantStatus  int
+		wantBody    string
+		wantResults map[string]string // status of the result of each platform
+		wantReceipt string
+	}{
+		{
+			name:        "feishu read receipt",
+			path:        "/callbacks/feishu",
+			body:        feishuRead,
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"events":2,"updated":1}`,
+			wantResults: map[string]string{"feishu": receipt.ResultRead},
+			wantReceipt: receipt.StatusSuccess,
+		},
+		{
+			name:       "feishu URL verification",
+			path:       "/callbacks/feishu",
+			body:       `{"challenge":"abc","token":"verify-token","type":"url_verification"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"challenge":"abc"}`,
+		},
+		{
+			name:       "feishu wrong token",
+			path:       "/callbacks/feishu",
+			body:       strings.Replace(feishuRead, "verify-token", "wrong", 1),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:        "twilio delivered",
+			path:        "/callbacks/twilio",
+			body:        twilioForm("delivered").Encode(),
+			header:      map[string]string{"X-Twilio-Signature": twilioSignature("auth-token", twilioURL, twilioForm("delivered"))},
+			wantStatus:  http.StatusOK,
+			wantResults: map[string]string{"sms": receipt.ResultDelivered},
+		},
+		{
+			name:        "twilio sent adds nothing",
+			path:        "/callbacks/twilio",
+			body:        twilioForm("sent").Encode(),
+			header:      map[string]string{"X-Twilio-Signature": twilioSignature("auth-token", twilioURL, twilioForm("sent"))},
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"events":0,"updated":0}`,
+			wantResults: map[string]string{"sms": ""},
+		},
+		{
+			name:        "twilio undelivered",
+			path:        "/callbacks/twilio",
+			body:        twilioForm("undelivered").Encode(),
+			header:      map[string]string{"X-Twilio-Signature": twilioSignature("auth-token", twilioURL, twilioForm("undelivered"))},
+			wantStatus:  http.StatusOK,
+			wantResults: map[string]string{"sms": receipt.ResultBounced},
+			wantReceipt: receipt.StatusPartial,
+		},
+		{
+			name:       "twilio wrong signature",
+			path:       "/callbacks/twilio",
+			body:       twilioForm("delivered").Encode(),
+			header:     map[string]string{"X-Twilio-Signature": twilioSignature("other-token", twilioURL, twilioForm("delivered"))},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:        "raw bounce",
+			path:        "/callbacks/email?token=secret",
+			body:        bounce("alice@example.org"),
+			wantStatus:  http.StatusOK,
+			wantResults: map[string]string{"email": receipt.ResultBounced},
+			wantReceipt: receipt.StatusPartial,
+		},
+		{
+			name:        "bounce in a form",
+			path:        "/callbacks/email",
+			body:        formBody,
+			contentType: formType,
+			header:      map[string]string{"Authorization": "Bearer secret"},
+			wantStatus:  http.StatusOK,
+			wantResults: map[string]string{"email": receipt.ResultBounced},
+		},
+		{
+			name:        "bounce of another recipient",
+			path:        "/callbacks/email?token=secret",
+			body:        bounce("bob@example.org"),
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"events":1,"updated":0}`,
+			wantResults: map[string]string{"email": ""},
+		},
+		{
+			name:       "bounce with a wrong token",
+			path:       "/callbacks/email?token=guess",
+			body:       bounce("alice@example.org"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unknown provider",
+			path:       "/callbacks/pager",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "GET",
+			method:     http.MethodGet,
+			path:       "/callbacks/feishu",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callbacks, err := feishu.NewCallbackHandler(feishu.CallbackConfig{VerificationToken: "verify-token"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			receiver := NewReceiver(
+				WithProvider("feishu", Feishu{Handler: callbacks}),
+				WithProvider("twilio", Twilio{AuthToken: "auth-token", URL: twilioURL}),
+				WithProvider("email", Bounces{Parser: &email.FeedbackParser{}, Token: "secret"}),
+			)
+			_, err = receiver.Track(context.Background(), message.New(), func(context.Context, *message.Message) (*receipt.Receipt, error) {
+				return sentReceipt(), nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tt.path, strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" && strings.Contains(tt.path, "twilio") {
+				contentType = "application/x-www-form-urlencoded"
+			}
+			if contentType != "" {
+				req.Header.Set("Content-Type", contentType)
+			}
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantBody != "" && strings.TrimSpace(rec.Body.String()) != tt.wantBody {
+				t.Errorf("body = %s, want %s", rec.Body, tt.wantBody)
+			}
+
+			got, err := receiver.Receipt(context.Background(), "msg-1")
+			if err != nil {
+				t.Fatalf("Receipt() error = %v", err)
+			}
+			for _, result := range got.Results {
+				want := tt.wantResults[result.Platform]
+				if result.Status != want {
+					t.Errorf("%s result status = %q, want %q", result.Platform, result.Status, want)
+				}
+				if result.Status == receipt.ResultBounced && (result.Success || result.Error == "") {
+					t.Errorf("bounced %s result = %+v", result.Platform, result)
+				}
+			}
+			if tt.wantReceipt != "" && got.Status != tt.wantReceipt {
+				t.Errorf("receipt status = %s, want %s", got.Status, tt.wantReceipt)
+			}
+		})
+	}
+}
+
+func TestReceiver_Apply(t *testing.T) {
+	var hooked []string
+	receiver := NewReceiver(WithUpdateHook(func(ctx context.Context, r *receipt.Receipt, event Event) {
+		hooked = append(hooked, event.Platform+" "+r.Status)
+	}))
+	ctx := context.Background()
+	if err := receiver.store.Save(ctx, sentReceipt()); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		event       Event
+		wantUpdated int
+		wantStatus  string
+	}{
+		{Event{Platform: "feishu", ProviderID: "om_1", Status: receipt.ResultRead}, 1, receipt.StatusSuccess},
+		{Event{Platform: "feishu", ProviderID: "om_1", Status: receipt.ResultDelivered}, 0, receipt.StatusSuccess}, // late, not moved back
+		{Event{Platform: "sms", ProviderID: "SM1", Status: receipt.ResultDelivered}, 1, receipt.StatusSuccess},
+		{Event{Platform: "email", MessageID: "msg-1", Target: "alice@example.org", Status: receipt.ResultDelivered}, 1, receipt.StatusDelivered},
+		{Event{Platform: "email", MessageID: "msg-2", Target: "alice@example.org", Status: receipt.ResultBounced}, 0, receipt.StatusDelivered}, // untracked
+		{Event{Platform: "sms", ProviderID: "SM2", Status: receipt.ResultBounced}, 0, receipt.StatusDelivered},                                 // untracked
+	}
+	for i, step := range steps {
+		updated, err := receiver.Apply(ctx, step.event)
+		if err != nil || updated != step.wantUpdated {
+			t.Fatalf("step %d: Apply() = %d, %v, want %d", i, updated, err, step.wantUpdated)
+		}
+		got, _ := receiver.Receipt(ctx, "msg-1")
+		if got.Status != step.wantStatus {
+			t.Errorf("step %d: receipt status = %s, want %s", i, got.Status, step.wantStatus)
+		}
+	}
+	want := []string{"feishu success", "sms success", "email delivered"}
+	if strings.Join(hooked, ", ") != strings.Join(want, ", ") {
+		t.Errorf("hook calls = %q, want %q", hooked, want)
+	}
+
+	bounced := receipt.New("msg-3")
+	bounced.AddResult(receipt.PlatformResult{Platform: "sms", Target: "+1555", Success: true, MessageID: "SM3"})
+	_ = receiver.store.Save(ctx, bounced)
+	if _, err := receiver.Apply(ctx, Event{Platform: "sms", ProviderID: "SM3", Status: receipt.ResultBounced, Error: "twilio failed"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := receiver.Receipt(ctx, "msg-3"); !got.IsFailed() || got.Status != receipt.StatusBounced {
+		t.Errorf("bounced receipt status = %s", got.Status)
+	}
+}
+
+func TestMemoryStore_Retention(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore(time.Hour)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_ = store.Save(ctx, sentReceipt())
+	saved, _ := store.Load(ctx, "msg-1")
+	saved.Results[0].Status = receipt.ResultRead
+	if again, _ := store.Load(ctx, "msg-1"); again.Results[0].Status != "" {
+		t.Error("Load() returned a receipt sharing results with the store")
+	}
+
+	now = now.Add(2 * time.Hour)
+	_ = store.Save(ctx, receipt.New("msg-2"))
+	if _, err := store.Load(ctx, "msg-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() after the retention error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Find(ctx, "sms", "SM1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find() after the retention error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,196 @@
+package callback
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/platforms/email"
+	"github.com/kart-io/notifyhub/pkg/platforms/feishu"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+)
+
+// Feishu receives the read receipts of the event subscription of a Feishu
+// app, verified by its callback handler, and reports the messages of the
+// app as read. Read receipts are only sent for messages of the app, not of
+// custom bot webhooks.
+type Feishu struct {
+	Handler *feishu.CallbackHandler
+}
+
+// Parse implements Provider
+func (f Feishu) Parse(r *http.Request, body []byte) ([]Event, interface{}, error) {
+	read, challenge, err := f.Handler.ReadEvent(r.Header, body)
+	if errors.Is(err, feishu.ErrInvalidCallback) {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge != "" {
+		return nil, map[string]string{"challenge": challenge}, nil
+	}
+	if read == nil {
+		return nil, nil, nil
+	}
+	events := make([]Event, 0, len(read.MessageIDs))
+	for _, id := range read.MessageIDs {
+		events = append(events, Event{Platform: "feishu", ProviderID: id, Status: receipt.ResultRead, Time: read.ReadAt})
+	}
+	return events, nil, nil
+}
+
+// Twilio receives the status callbacks of Twilio messages, verified by the
+// X-Twilio-Signature of the account's auth token, and reports them as
+// delivered, read or bounced. Messages are found by the MessageSid their
+// results recorded as MessageID.
+type Twilio struct {
+	AuthToken string
+
+	// URL is the status callback URL configured in Twilio, which the
+	// signature covers; empty uses the URL of the request, which differs
+	// behind a proxy
+	URL string
+
+	// Platform is the name of the platform sending through Twilio, "sms"
+	// when empty
+	Platform string
+}
+
+// Parse implements Provider
+func (t Twilio) Parse(r *http.Request, body []byte) ([]Event, interface{}, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	callbackURL := t.URL
+	if callbackURL == "" {
+		scheme := "https"
+		if r.TLS == nil {
+			scheme = "http"
+		}
+		callbackURL = scheme + "://" + r.Host + r.URL.RequestURI()
+	}
+	if !hmac.Equal([]byte(r.Header.Get("X-Twilio-Signature")), []byte(twilioSignature(t.AuthToken, callbackURL, form))) {
+		return nil, nil, fmt.Errorf("%w: twilio signature mismatch", ErrUnauthorized)
+	}
+
+	event := Event{
+		Platform:   t.Platform,
+		ProviderID: form.Get("MessageSid"),
+		Target:     form.Get("To"),
+		Time:       time.Now(),
+	}
+	if event.Platform == "" {
+		event.Platform = "sms"
+	}
+	switch form.Get("MessageStatus") {
+	case "delivered":
+		event.Status = receipt.ResultDelivered
+	case "read":
+		event.Status = receipt.ResultRead
+	case "undelivered", "failed":
+		event.Status = receipt.ResultBounced
+		event.Error = "twilio " + form.Get("MessageStatus")
+		if code := form.Get("ErrorCode"); code != "" {
+			event.Error += ", error " + code
+		}
+	default:
+		// queued, sending and sent add nothing to the receipt
+		return nil, nil, nil
+	}
+	if event.ProviderID == "" {
+		return nil, nil, fmt.Errorf("twilio callback has no MessageSid")
+	}
+	return []Event{event}, nil, nil
+}
+
+// twilioSignature returns the signature of a Twilio request: the base64
+// HMAC-SHA1 of the URL followed by the sorted names and values of its form
+func twilioSignature(authToken, callbackURL string, form url.Values) string {
+	names := make([]string, 0, len(form))
+	for name := range form {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(callbackURL)
+	for _, name := range names {
+		for _, value := range form[name] {
+			b.WriteString(name + value)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Bounces receives the bounces and replies an email provider posts, as
+// the raw email in the body or in the "email" field of a multipart form,
+// such as an inbound parse webhook, and reports the recipients as bounced
+// or replied. The sent messages are recognized as by a MailboxPoller.
+type Bounces struct {
+	Parser *email.FeedbackParser
+
+	// Token, when set, must be given as the "token" query parameter or as
+	// a bearer token
+	Token string
+}
+
+// Parse implements Provider
+func (b Bounces) Parse(r *http.Request, body []byte) ([]Event, interface{}, error) {
+	if b.Token != "" {
+		token := r.URL.Query().Get("token")
+		if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+			token = strings.TrimPrefix(bearer, "Bearer ")
+		}
+		if !hmac.Equal([]byte(token), []byte(b.Token)) {
+			return nil, nil, fmt.Errorf("%w: invalid token", ErrUnauthorized)
+		}
+	}
+
+	raw := body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err := r.ParseMultipartForm(maxBody); err != nil {
+			return nil, nil, err
+		}
+		raw = []byte(r.FormValue("email"))
+	}
+
+	feedback, err := b.Parser.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, err
+	}
+	var events []Event
+	for _, f := range feedback {
+		event := Event{Platform: "email", MessageID: f.MessageID, Target: f.Recipient, Time: f.ReceivedAt}
+		switch f.Kind {
+		case email.FeedbackHardBounce, email.FeedbackSoftBounce:
+			event.Status = receipt.ResultBounced
+			event.Error = f.Diagnostic
+			if event.Error == "" {
+				event.Error = "bounced " + f.Status
+			}
+		case email.FeedbackReply:
+			event.Status = receipt.ResultReplied
+		default:
+			continue
+		}
+		if f.MessageID != "" && f.Recipient != "" {
+			events = append(events, event)
+		}
+	}
+	return events, nil, nil
+}
@@ -4,27 +4,107 @@ package notifyhub
 
 import (
 	"context"
+	"iter"
 
 	"github.com/kart-io/notifyhub/pkg/async"
+	"github.com/kart-io/notifyhub/pkg/config"
 	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
 	"github.com/kart-io/notifyhub/pkg/receipt"
 )
 
 // Client represents the unified notification client interface
 // This interface implements the 3-layer architecture: Client → Dispatcher → Platform
-// replacing the complex 6-layer calling chain from the previous implementation
+// replacing the complex 6-layer calling chain from the previous implementation.
+// It is composed of smaller interfaces, so that consumers can depend on, and
+// mock, only the methods they use.
 type Client interface {
-	// Synchronous interface - immediate message sending
-	Send(ctx context.Context, msg *message.Message) (*receipt.Receipt, error)
-	SendBatch(ctx context.Context, msgs []*message.Message) ([]*receipt.Receipt, error)
+	Sender
+	AsyncSender
+	HealthChecker
+	PlatformRegistry
+	Pipeline
+	Controller
+	Replayer
 
-	// Asynchronous interface - true async processing with real queue support
+	// Management interface - configuration and lifecycle management
+	ReloadConfig(cfg *config.Config) error
+	Close() error
+
+	// Scoping - child clients applying defaults to their messages
+	With(defaults ...Default) Client
+}
+
+// Sender sends messages synchronously - immediate message sending
+type Sender interface {
+	Send(ctx context.Context, msg *message.Message, opts ...SendOption) (*receipt.Receipt, error)
+	SendBatch(ctx context.Context, msgs []*message.Message, opts ...BatchOption) ([]*receipt.Receipt, error)
+}
+
+// StreamSender sends the messages of a sequence as they are produced,
+// yielding their receipts in order. It is not part of Client; the clients
+// returned by NewClient and With implement it:
+//
+//	receipts := client.(notifyhub.StreamSender).SendStream(ctx, msgs)
+type StreamSender interface {
+	SendStream(ctx context.Context, msgs iter.Seq[*message.Message], opts ...SendOption) iter.Seq2[*receipt.Receipt, error]
+}
+
+// AsyncSender sends messages asynchronously - true async processing with
+// real queue support
+type AsyncSender interface {
 	SendAsync(ctx context.Context, msg *message.Message, opts ...async.Option) (async.Handle, error)
 	SendAsyncBatch(ctx context.Context, msgs []*message.Message, opts ...async.Option) (async.BatchHandle, error)
+}
 
-	// Management interface - health monitoring and lifecycle management
+// HealthChecker reports the health of a client
+type HealthChecker interface {
 	Health(ctx context.Context) (*HealthStatus, error)
-	Close() error
+}
+
+// Preflighter verifies the credentials and endpoints of the configured
+// platforms before the first send. It is not part of Client; the clients
+// returned by NewClient and With implement it.
+type Preflighter interface {
+	Preflight(ctx context.Context) (*PreflightReport, error)
+}
+
+// UsageReader reports the usage of tenants and apps against their usage
+// quotas. It is not part of Client; the clients returned by NewClient and
+// With implement it.
+type UsageReader interface {
+	Usage(ctx context.Context, tenant, app string) ([]ratelimit.Usage, error)
+}
+
+// PlatformRegistry manages external platforms - platforms implemented
+// outside NotifyHub
+type PlatformRegistry interface {
+	RegisterPlatform(name string, factory platform.Factory) error
+	SetPlatformConfig(name string, cfg interface{}) error
+	ReplacePlatform(name string, factory platform.Factory, cfg interface{}) error
+	UnregisterPlatform(name string) error
+}
+
+// Pipeline extends the send pipeline - middleware wrapped around every send
+// and enrichment of the message once its platform is chosen
+type Pipeline interface {
+	Use(mw ...Middleware)
+	AddEnricher(platform string, enrichers ...Enricher)
+}
+
+// Controller pauses platforms and drains queues at runtime, so that
+// incident responders can stop a flood without a restart
+type Controller interface {
+	PausePlatform(name string) error
+	ResumePlatform(name string) (int, error)
+	DrainQueue(name string) (int, error)
+	Controls() ControlStatus
+}
+
+// Replayer sends again the messages of a dead letter queue or an archive
+type Replayer interface {
+	Replay(ctx context.Context, source ReplaySource, filter ReplayFilter, opts ReplayOptions) (*ReplayReport, error)
 }
 
 // HealthStatus represents the comprehensive health status of the NotifyHub client
@@ -37,4 +117,8 @@ type HealthStatus struct {
 	TotalSent   int64                  `json:"total_sent"`   // Total messages sent
 	SuccessRate float64                `json:"success_rate"` // Success rate percentage
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+
+	// ConfigVersion identifies the platform configuration in use; it
+	// changes when the configuration is reloaded
+	ConfigVersion string `json:"config_version,omitempty"`
 }
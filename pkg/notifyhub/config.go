@@ -11,6 +11,9 @@ import (
 
 // Config represents the unified configuration for NotifyHub
 // This replaces the complex map-based configuration with strong typing
+//
+// Deprecated: use config.Config, which NewClient accepts; ConfigOptions converts
+// existing settings.
 type Config struct {
 	// Core settings
 	Timeout    time.Duration `json:"timeout" yaml:"timeout"`
@@ -32,9 +35,13 @@ type Config struct {
 }
 
 // Option represents a functional configuration option
+//
+// Deprecated: use config.Option and NewClientFromOptions.
 type Option func(*Config) error
 
 // FeishuConfig represents Feishu platform configuration
+//
+// Deprecated: use config.FeishuConfig.
 type FeishuConfig struct {
 	// Webhook configuration
 	WebhookURL string   `json:"webhook_url" yaml:"webhook_url" validate:"required,url"`
@@ -56,6 +63,8 @@ type FeishuConfig struct {
 }
 
 // EmailConfig represents email platform configuration
+//
+// Deprecated: use config.EmailConfig.
 type EmailConfig struct {
 	Host     string        `json:"host" yaml:"host"`
 	Port     int           `json:"port" yaml:"port"`
@@ -67,6 +76,8 @@ type EmailConfig struct {
 }
 
 // WebhookConfig represents webhook platform configuration
+//
+// Deprecated: use config.WebhookConfig.
 type WebhookConfig struct {
 	URL     string            `json:"url" yaml:"url"`
 	Method  string            `json:"method" yaml:"method"`
@@ -75,12 +86,16 @@ type WebhookConfig struct {
 }
 
 // AsyncConfig represents asynchronous processing configuration
+//
+// Deprecated: use config.AsyncConfig.
 type AsyncConfig struct {
 	Enabled bool `json:"enabled" yaml:"enabled"`
 	Workers int  `json:"workers" yaml:"workers"`
 }
 
 // LoggerConfig represents logger configuration
+//
+// Deprecated: use config.LoggerConfig.
 type LoggerConfig struct {
 	Level  string `json:"level" yaml:"level"`
 	Format string `json:"format" yaml:"format"`
@@ -89,6 +104,8 @@ type LoggerConfig struct {
 // Core configuration options
 
 // WithFeishu configures Feishu platform
+//
+// Deprecated: use config.WithFeishu.
 func WithFeishu(config FeishuConfig) Option {
 	return func(c *Config) error {
 		// Apply defaults first
@@ -185,6 +202,8 @@ func applyFeishuDefaults(config *FeishuConfig) {
 }
 
 // WithEmail configures email platform
+//
+// Deprecated: use config.WithEmail.
 func WithEmail(config EmailConfig) Option {
 	return func(c *Config) error {
 		if config.Host == "" {
@@ -202,6 +221,8 @@ func WithEmail(config EmailConfig) Option {
 }
 
 // WithWebhook configures webhook platform
+//
+// Deprecated: use config.WithWebhook.
 func WithWebhook(config WebhookConfig) Option {
 	return func(c *Config) error {
 		if config.URL == "" {
@@ -219,6 +240,8 @@ func WithWebhook(config WebhookConfig) Option {
 }
 
 // WithAsync configures asynchronous processing
+//
+// Deprecated: use config.WithAsync.
 func WithAsync(workers int) Option {
 	return func(c *Config) error {
 		if workers <= 0 {
@@ -231,6 +254,8 @@ func WithAsync(workers int) Option {
 }
 
 // WithTimeout sets the global timeout
+//
+// Deprecated: use config.WithTimeout.
 func WithTimeout(timeout time.Duration) Option {
 	return func(c *Config) error {
 		if timeout <= 0 {
@@ -242,6 +267,8 @@ func WithTimeout(timeout time.Duration) Option {
 }
 
 // WithMaxRetries sets the maximum retry count
+//
+// Deprecated: use config.WithMaxRetries.
 func WithMaxRetries(retries int) Option {
 	return func(c *Config) error {
 		if retries < 0 {
@@ -253,6 +280,8 @@ func WithMaxRetries(retries int) Option {
 }
 
 // WithLogger sets the logger instance
+//
+// Deprecated: use config.WithLogger.
 func WithLogger(logger logger.Logger) Option {
 	return func(c *Config) error {
 		c.LoggerInstance = logger
@@ -261,6 +290,8 @@ func WithLogger(logger logger.Logger) Option {
 }
 
 // WithLoggerConfig configures logging settings
+//
+// Deprecated: use the Logger settings of config.Config.
 func WithLoggerConfig(level, format string) Option {
 	return func(c *Config) error {
 		c.Logger.Level = level
@@ -272,6 +303,8 @@ func WithLoggerConfig(level, format string) Option {
 // Convenience configuration functions
 
 // WithFeishuWebhook creates a simplified Feishu webhook configuration
+//
+// Deprecated: use config.WithQuickFeishu.
 func WithFeishuWebhook(webhookURL, secret string) Option {
 	return WithFeishu(FeishuConfig{
 		WebhookURL: webhookURL,
@@ -281,6 +314,8 @@ func WithFeishuWebhook(webhookURL, secret string) Option {
 }
 
 // WithFeishuApp creates a Feishu app-based configuration
+//
+// Deprecated: use config.WithFeishuApp.
 func WithFeishuApp(appID, appSecret string) Option {
 	return WithFeishu(FeishuConfig{
 		AppID:     appID,
@@ -290,6 +325,8 @@ func WithFeishuApp(appID, appSecret string) Option {
 }
 
 // WithFeishuAdvanced creates an advanced Feishu configuration with all options
+//
+// Deprecated: use config.WithFeishu.
 func WithFeishuAdvanced(webhookURL, secret string, keywords []string, timeout time.Duration, maxRetries, rateLimit int, signVerify bool) Option {
 	return WithFeishu(FeishuConfig{
 		WebhookURL: webhookURL,
@@ -304,6 +341,8 @@ func WithFeishuAdvanced(webhookURL, secret string, keywords []string, timeout ti
 }
 
 // WithEmailSMTP creates a simplified email SMTP configuration
+//
+// Deprecated: use config.WithEmail.
 func WithEmailSMTP(host string, port int, username, password, from string) Option {
 	return WithEmail(EmailConfig{
 		Host:     host,
@@ -317,6 +356,8 @@ func WithEmailSMTP(host string, port int, username, password, from string) Optio
 }
 
 // WithWebhookBasic creates a simplified webhook configuration
+//
+// Deprecated: use config.WithQuickWebhook.
 func WithWebhookBasic(url string) Option {
 	return WithWebhook(WebhookConfig{
 		URL:     url,
@@ -329,6 +370,8 @@ func WithWebhookBasic(url string) Option {
 // Default configurations
 
 // WithDefaults applies sensible default configurations
+//
+// Deprecated: use config.WithDefaults.
 func WithDefaults() Option {
 	return func(c *Config) error {
 		c.Timeout = 30 * time.Second
@@ -342,6 +385,8 @@ func WithDefaults() Option {
 }
 
 // WithTestDefaults applies test-safe default configurations
+//
+// Deprecated: use config.WithTestDefaults.
 func WithTestDefaults() Option {
 	return func(c *Config) error {
 		c.Timeout = 5 * time.Second
@@ -411,7 +456,7 @@ func (c *Config) ValidateComprehensive() error {
 
 	// Validate platform configurations with basic checks
 	if c.Feishu != nil {
-		if c.Feishu.WebhookURL == "" {
+		if c.Feishu.WebhookURL == "" && c.Feishu.AuthType != "app" {
 			return fmt.Errorf("feishu webhook URL is required")
 		}
 	}
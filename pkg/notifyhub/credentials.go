@@ -0,0 +1,230 @@
+// Package notifyhub provides switching between alternative platform credentials
+package notifyhub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// withCredentials wraps the factory of a platform with alternative
+// credentials, so that it creates one instance per set of credentials
+func withCredentials(name string, factory platform.Factory, credentials []config.Credential, logger logger.Logger) platform.Factory {
+	if len(credentials) == 0 {
+		return factory
+	}
+	return func(section interface{}) (platform.Platform, error) {
+		primary, err := factory(section)
+		if err != nil {
+			return nil, err
+		}
+		p := &credentialPlatform{
+			name:     name,
+			logger:   logger,
+			now:      time.Now,
+			rejected: make(map[string]bool),
+		}
+		p.credentials = append(p.credentials, credentialInstance{Credential: config.Credential{Name: config.PrimaryCredential}, platform: primary})
+
+		for _, cred := range credentials {
+			settings, err := cred.Apply(section)
+			var instance platform.Platform
+			if err == nil {
+				instance, err = factory(settings)
+			}
+			if err != nil {
+				_ = p.Close()
+				return nil, fmt.Errorf("credentials %s: %w", cred.Name, err)
+			}
+			p.credentials = append(p.credentials, credentialInstance{Credential: cred, platform: instance})
+		}
+		return p, nil
+	}
+}
+
+// credentialInstance is a platform instance created with one set of
+// credentials
+type credentialInstance struct {
+	config.Credential
+	platform platform.Platform
+}
+
+// credentialPlatform sends through the instance with the preferred
+// credentials, and retries the targets whose send was rejected for its
+// credentials with the next ones (see config.Credential)
+type credentialPlatform struct {
+	name        string
+	credentials []credentialInstance // the section's own credentials first
+	logger      logger.Logger
+	now         func() time.Time
+
+	mu       sync.Mutex
+	rejected map[string]bool
+	current  string
+}
+
+// choose returns the preferred active credentials not yet tried by a send,
+// favoring credentials that were not rejected, or nil
+func (p *credentialPlatform) choose(tried map[string]bool) *credentialInstance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var chosen *credentialInstance
+	for i := range p.credentials {
+		candidate := &p.credentials[i]
+		if tried[candidate.Name] || !candidate.Active(now) {
+			continue
+		}
+		if chosen == nil {
+			chosen = candidate
+			continue
+		}
+		if rejected, chosenRejected := p.rejected[candidate.Name], p.rejected[chosen.Name]; rejected != chosenRejected {
+			if chosenRejected {
+				chosen = candidate
+			}
+			continue
+		}
+		if candidate.ActiveFrom.After(chosen.ActiveFrom) {
+			chosen = candidate
+		}
+	}
+	return chosen
+}
+
+// use records the credentials a send uses, logging switches
+func (p *credentialPlatform) use(cred *credentialInstance) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current != "" && p.current != cred.Name {
+		p.logger.Info("Switched platform credentials", "platform", p.name, "credentials", cred.Name, "previous", p.current)
+	}
+	p.current = cred.Name
+}
+
+// reject records that the platform rejected credentials
+func (p *credentialPlatform) reject(cred *credentialInstance, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rejected[cred.Name] = true
+	p.logger.Warn("Platform rejected credentials", "platform", p.name, "credentials", cred.Name, "error", err)
+}
+
+// accept records that the platform accepted credentials again
+func (p *credentialPlatform) accept(cred *credentialInstance) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.rejected, cred.Name)
+}
+
+// Name implements platform.Platform
+func (p *credentialPlatform) Name() string {
+	return p.credentials[0].platform.Name()
+}
+
+// GetCapabilities implements platform.Platform
+func (p *credentialPlatform) GetCapabilities() platform.Capabilities {
+	return p.credentials[0].platform.GetCapabilities()
+}
+
+// ValidateTarget implements platform.Platform
+func (p *credentialPlatform) ValidateTarget(tgt target.Target) error {
+	return p.credentials[0].platform.ValidateTarget(tgt)
+}
+
+// Send implements platform.Platform
+func (p *credentialPlatform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	var delivered []*platform.SendResult
+	pending := targets
+	tried := make(map[string]bool)
+	for {
+		cred := p.choose(tried)
+		tried[cred.Name] = true
+		p.use(cred)
+
+		results, err := cred.platform.Send(ctx, msg, pending)
+		kept, retry := splitAuthFailures(pending, results, err)
+		if len(retry) == 0 {
+			p.accept(cred)
+			return append(delivered, results...), err
+		}
+
+		p.reject(cred, authFailure(results, err))
+		if p.choose(tried) == nil || ctx.Err() != nil {
+			return append(delivered, results...), err
+		}
+		delivered = append(delivered, kept...)
+		pending = retry
+	}
+}
+
+// splitAuthFailures separates the results of a send from the targets whose
+// send was rejected for the credentials
+func splitAuthFailures(targets []target.Target, results []*platform.SendResult, err error) ([]*platform.SendResult, []target.Target) {
+	if platform.IsAuthFailure(err) {
+		return nil, targets
+	}
+	var kept []*platform.SendResult
+	var retry []target.Target
+	for _, result := range results {
+		if result != nil && platform.IsAuthFailure(result.Error) {
+			retry = append(retry, result.Target)
+		} else {
+			kept = append(kept, result)
+		}
+	}
+	return kept, retry
+}
+
+// authFailure returns the rejection of a send's credentials
+func authFailure(results []*platform.SendResult, err error) error {
+	if platform.IsAuthFailure(err) {
+		return err
+	}
+	for _, result := range results {
+		if result != nil && platform.IsAuthFailure(result.Error) {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// IsHealthy implements platform.Platform for the preferred credentials
+func (p *credentialPlatform) IsHealthy(ctx context.Context) error {
+	return p.choose(nil).platform.IsHealthy(ctx)
+}
+
+// Preflight implements platform.Preflighter for every set of credentials
+// that is active, so that a rotation does not switch to credentials that
+// were never verified
+func (p *credentialPlatform) Preflight(ctx context.Context) error {
+	now := p.now()
+	for _, cred := range p.credentials {
+		if !cred.Active(now) {
+			continue
+		}
+		if err := platform.Preflight(ctx, cred.platform); err != nil {
+			return fmt.Errorf("credentials %s: %w", cred.Name, err)
+		}
+	}
+	return nil
+}
+
+// Close implements platform.Platform
+func (p *credentialPlatform) Close() error {
+	var lastErr error
+	for _, cred := range p.credentials {
+		if err := cred.platform.Close(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
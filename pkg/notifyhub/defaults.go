@@ -0,0 +1,194 @@
+// Package notifyhub provides the send defaults of the NotifyHub client
+package notifyhub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/errors"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// applyDefaults returns the message with the configured default priority
+// and format filled in. The caller's message is not modified.
+func (c *clientImpl) applyDefaults(msg *message.Message) *message.Message {
+	defaults := c.config.Defaults
+	setPriority := defaults.Priority != nil && msg.Priority == message.PriorityNormal
+	setFormat := defaults.Format != "" && msg.Format == ""
+	if !setPriority && !setFormat {
+		return msg
+	}
+
+	m := *msg
+	if setPriority {
+		m.Priority = *defaults.Priority
+	}
+	if setFormat {
+		m.Format = defaults.Format
+	}
+	return &m
+}
+
+// sendTimeout bounds a delivery to a platform, returning the context of
+// the delivery and its effective timeout. The timeout is taken from the
+// first of these that sets one:
+//
+//  1. the deadline of the caller's context, which is left unchanged
+//  2. the message (see message.SetTimeout)
+//  3. the platform's section, e.g. email.timeout
+//  4. the send defaults, then the client timeout
+//
+// The timeout covers the retries of the delivery.
+func (c *clientImpl) sendTimeout(ctx context.Context, msg *message.Message, cfg *config.Config, platformName string) (context.Context, context.CancelFunc, time.Duration) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return ctx, func() {}, time.Until(deadline)
+	}
+
+	timeout, ok := msg.Timeout()
+	if !ok || timeout <= 0 {
+		timeout = platformTimeout(cfg, platformName)
+	}
+	if timeout <= 0 {
+		timeout = c.config.Defaults.Timeout
+	}
+	if timeout <= 0 {
+		timeout = c.config.Timeout
+	}
+	if timeout <= 0 {
+		return ctx, func() {}, 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+// platformTimeout returns the timeout of a built-in platform's section, or
+// zero when it has none
+func platformTimeout(cfg *config.Config, platformName string) time.Duration {
+	switch {
+	case platformName == "feishu" && cfg.Feishu != nil:
+		return cfg.Feishu.Timeout
+	case platformName == "email" && cfg.Email != nil:
+		return cfg.Email.Timeout
+	case platformName == "webhook" && cfg.Webhook != nil:
+		return cfg.Webhook.Timeout
+	case platformName == "slack" && cfg.Slack != nil:
+		return cfg.Slack.Timeout
+	}
+	return 0
+}
+
+// platformOrder returns the order in which platforms are chosen for the
+// message's user and group targets. A message restricted to platforms
+// chooses among them only, in the order of its platform order or the send
+// defaults where those name them.
+func (c *clientImpl) platformOrder(msg *message.Message) []string {
+	order := msg.PlatformOrder()
+	if len(order) == 0 {
+		order = c.config.Defaults.Platforms
+	}
+	allowed := msg.Platforms()
+	if allowed == nil {
+		return order
+	}
+
+	restricted := make([]string, 0, len(allowed))
+	for _, name := range order {
+		if containsString(allowed, name) && !containsString(restricted, name) {
+			restricted = append(restricted, name)
+		}
+	}
+	for _, name := range allowed {
+		if !containsString(restricted, name) {
+			restricted = append(restricted, name)
+		}
+	}
+	return restricted
+}
+
+// sendWithRetries sends to a platform, retrying failed sends with the
+// platform's retry policy, as often as the message or the policy allows
+func (c *clientImpl) sendWithRetries(ctx context.Context, p platform.Platform, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	name := p.Name()
+	policy := c.config.RetryPolicyFor(name)
+	retries := policy.MaxAttempts - 1
+	if n, ok := msg.MaxRetries(); ok {
+		retries = n
+	}
+
+	results, err := c.safeSend(ctx, p, name, msg, targets)
+	for attempt := 1; attempt <= retries && isRetryableFailure(results, err, policy.RetryableErrorClassifier); attempt++ {
+		delay := policy.Delay(attempt)
+		c.log(ctx).Debug("Retrying send", "platform", name, "message_id", msg.ID, "attempt", attempt, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return results, err
+		case <-time.After(delay):
+		}
+		results, err = c.safeSend(ctx, p, name, msg, targets)
+	}
+	return results, err
+}
+
+// isRetryableFailure reports whether a send failed with an error the
+// classifier deems retryable or, without a classifier, transient
+func isRetryableFailure(results []*platform.SendResult, err error, classify func(error) bool) bool {
+	if classify == nil {
+		return isTransientFailure(results, err)
+	}
+	if err != nil {
+		return classify(err)
+	}
+	for _, result := range results {
+		if !result.Success && !isPlatformPanic(result.Error) && classify(result.Error) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTransientFailure reports whether a send failed for a reason other than
+// an unreachable target or a panic of the sender
+func isTransientFailure(results []*platform.SendResult, err error) bool {
+	if err != nil {
+		return !platform.IsTargetInvalid(err)
+	}
+	for _, result := range results {
+		if !result.Success && !platform.IsTargetInvalid(result.Error) && !isPlatformPanic(result.Error) {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackFormat applies the format fallback to a message in a format the
+// platform does not support
+func (c *clientImpl) fallbackFormat(msg *message.Message, p platform.Platform) (*message.Message, error) {
+	mode := c.config.Defaults.FormatFallback
+	if mode == "" || mode == config.FormatFallbackNone || msg.Format == "" {
+		return msg, nil
+	}
+	supported := p.GetCapabilities().SupportedFormats
+	if len(supported) == 0 {
+		return msg, nil
+	}
+	for _, format := range supported {
+		if format == string(msg.Format) {
+			return msg, nil
+		}
+	}
+
+	if mode == config.FormatFallbackReject {
+		return nil, &errors.NotifyError{
+			Code:     errors.ErrInvalidMessage,
+			Message:  fmt.Sprintf("message format %s not supported by platform %s", msg.Format, p.Name()),
+			Platform: p.Name(),
+		}
+	}
+	m := *msg
+	m.Format = message.FormatText
+	return &m, nil
+}
@@ -0,0 +1,62 @@
+// Package notifyhub provides the dry-run mode of platforms
+package notifyhub
+
+import (
+	"context"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// withDryRun wraps the factory of a platform in dry-run mode so that the
+// platforms it creates log their sends instead of making them
+func withDryRun(name string, factory platform.Factory, cfg *config.Config, logger logger.Logger) platform.Factory {
+	if !cfg.IsDryRun(name) {
+		return factory
+	}
+	return func(section interface{}) (platform.Platform, error) {
+		p, err := factory(section)
+		if err != nil {
+			return nil, err
+		}
+		return &dryRunPlatform{Platform: p, name: name, logger: logger}, nil
+	}
+}
+
+// dryRunPlatform reports every send as delivered without calling the
+// platform it wraps, which still validates targets and describes its
+// capabilities so that messages are processed as they would be for real
+type dryRunPlatform struct {
+	platform.Platform
+	name   string
+	logger logger.Logger
+}
+
+// Send implements platform.Platform, logging the message for each target
+func (p *dryRunPlatform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	results := make([]*platform.SendResult, 0, len(targets))
+	for _, tgt := range targets {
+		p.logger.Info("Dry run, message not sent", "platform", p.name, "message_id", msg.ID, "target", tgt.Value, "title", msg.Title, "body", msg.Body)
+		results = append(results, &platform.SendResult{Target: tgt, Success: true, MessageID: msg.ID, Response: "dry run"})
+	}
+	return results, nil
+}
+
+// IsHealthy implements platform.Platform without contacting the platform
+func (p *dryRunPlatform) IsHealthy(context.Context) error {
+	return nil
+}
+
+// Preflight implements platform.Preflighter without contacting the platform
+func (p *dryRunPlatform) Preflight(context.Context) error {
+	return nil
+}
+
+// isDryRun reports whether a platform only pretends to send
+func isDryRun(p platform.Platform) bool {
+	_, ok := p.(*dryRunPlatform)
+	return ok
+}
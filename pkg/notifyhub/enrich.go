@@ -0,0 +1,75 @@
+// Package notifyhub provides the enrichment of messages before delivery
+package notifyhub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// Enricher changes a message just before it is sent on a platform, e.g. to
+// add an environment banner to the title, append a trace link to the body
+// or set default metadata:
+//
+//	client.AddEnricher("", func(ctx context.Context, platform string, msg *message.Message) error {
+//		msg.Title = "[staging] " + msg.Title
+//		return nil
+//	})
+//
+// Enrichers run for each target once its platform is chosen, before the
+// message is rendered and delivered, on a copy of the message that other
+// targets do not see. An error fails the delivery to the target.
+type Enricher func(ctx context.Context, platform string, msg *message.Message) error
+
+// AddEnricher adds enrichers for the messages sent on a platform, or on
+// every platform when platform is empty. Enrichers for every platform run
+// before those of a platform, each in the order they were added.
+func (c *clientImpl) AddEnricher(platform string, enrichers ...Enricher) {
+	c.enrichersMu.Lock()
+	defer c.enrichersMu.Unlock()
+	next := make(map[string][]Enricher, len(c.enrichers)+1)
+	for name, list := range c.enrichers {
+		next[name] = list
+	}
+	list := append([]Enricher(nil), next[platform]...)
+	for _, e := range enrichers {
+		if e != nil {
+			list = append(list, e)
+		}
+	}
+	next[platform] = list
+	c.enrichers = next
+}
+
+// enrich returns a copy of the message changed by the enrichers of a
+// platform, or the message itself when none apply. A failing enricher is
+// recorded on the receipt and reported as false.
+func (c *clientImpl) enrich(ctx context.Context, msg *message.Message, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) (*message.Message, bool) {
+	c.enrichersMu.RLock()
+	global, specific := c.enrichers[""], c.enrichers[platformName]
+	c.enrichersMu.RUnlock()
+	if len(global) == 0 && len(specific) == 0 {
+		return msg, true
+	}
+
+	enriched := msg.Clone()
+	for _, list := range [][]Enricher{global, specific} {
+		for _, e := range list {
+			if err := e(ctx, platformName, enriched); err != nil {
+				c.log(ctx).Warn("Failed to enrich message", "message_id", msg.ID, "platform", platformName, "error", err)
+				receipt.AddResult(receiptpkg.PlatformResult{
+					Platform:  platformName,
+					Target:    tgt.Value,
+					Success:   false,
+					Error:     fmt.Sprintf("enrich message: %v", err),
+					Timestamp: receipt.Timestamp,
+				})
+				return nil, false
+			}
+		}
+	}
+	return enriched, true
+}
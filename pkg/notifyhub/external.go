@@ -0,0 +1,174 @@
+// Package notifyhub provides runtime registration of external platforms
+package notifyhub
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kart-io/notifyhub/pkg/platform"
+)
+
+// builtinPlatforms are the platforms configured through the sections of
+// config.Config; their names cannot be registered at runtime
+var builtinPlatforms = map[string]bool{
+	"feishu":  true,
+	"email":   true,
+	"webhook": true,
+	"slack":   true,
+}
+
+// externalPlatform is a platform registered with RegisterPlatform. It is
+// created once a configuration is set.
+type externalPlatform struct {
+	factory platform.Factory
+	config  interface{}
+}
+
+// RegisterPlatform adds a platform implemented outside NotifyHub, such as
+// an SMS or DingTalk sender. The platform is used once SetPlatformConfig
+// gives it a configuration; declare its name with
+// config.WithExternalPlatforms to refer to it in rules and defaults.
+// External platforms are kept when the configuration is reloaded.
+func (c *clientImpl) RegisterPlatform(name string, factory platform.Factory) error {
+	if name == "" {
+		return fmt.Errorf("platform name cannot be empty")
+	}
+	if factory == nil {
+		return fmt.Errorf("platform %s: factory cannot be nil", name)
+	}
+	if builtinPlatforms[name] {
+		return fmt.Errorf("platform %s is built in and configured through the client configuration", name)
+	}
+
+	c.reconfigureMu.Lock()
+	defer c.reconfigureMu.Unlock()
+
+	current := c.currentPlatforms()
+	if _, exists := current.external[name]; exists {
+		return fmt.Errorf("platform %s already registered", name)
+	}
+	if _, exists := current.config.Plugins[name]; exists {
+		return fmt.Errorf("platform %s is a plugin configured through the client configuration", name)
+	}
+
+	external := copyExternal(current.external)
+	external[name] = externalPlatform{factory: factory}
+	return c.updateExternal(current, external)
+}
+
+// SetPlatformConfig configures a platform registered with RegisterPlatform.
+// The platform is created from the configuration before it is used, so a
+// configuration its factory rejects leaves the platform unchanged.
+func (c *clientImpl) SetPlatformConfig(name string, cfg interface{}) error {
+	if cfg == nil {
+		return fmt.Errorf("platform %s: configuration cannot be nil", name)
+	}
+
+	c.reconfigureMu.Lock()
+	defer c.reconfigureMu.Unlock()
+
+	current := c.currentPlatforms()
+	ext, exists := current.external[name]
+	if !exists {
+		if builtinPlatforms[name] {
+			return fmt.Errorf("platform %s is built in, use ReloadConfig to change its configuration", name)
+		}
+		return fmt.Errorf("platform %s not registered", name)
+	}
+
+	external := copyExternal(current.external)
+	external[name] = externalPlatform{factory: ext.factory, config: cfg}
+	return c.updateExternal(current, external)
+}
+
+// UnregisterPlatform removes a platform. For an external platform the
+// registration is dropped; for a built-in platform its configuration
+// section is removed until the next ReloadConfig. Sends in flight finish
+// on the platform, which is closed once they complete; later sends to it
+// fail.
+func (c *clientImpl) UnregisterPlatform(name string) error {
+	c.reconfigureMu.Lock()
+	defer c.reconfigureMu.Unlock()
+
+	current := c.currentPlatforms()
+	if _, exists := current.external[name]; exists {
+		external := copyExternal(current.external)
+		delete(external, name)
+		return c.updateExternal(current, external)
+	}
+
+	if !builtinPlatforms[name] || !c.isPlatformConfigured(name) {
+		return fmt.Errorf("platform %s not registered", name)
+	}
+	source := *current.source
+	switch name {
+	case "feishu":
+		source.Feishu = nil
+	case "email":
+		source.Email = nil
+	case "webhook":
+		source.Webhook = nil
+	case "slack":
+		source.Slack = nil
+	}
+	next, err := newPlatformSet(&source, current.external, c.logger)
+	if err != nil {
+		return err
+	}
+	return c.swapPlatforms(next, nil)
+}
+
+// ReplacePlatform atomically replaces an external platform with a new
+// implementation and configuration, e.g. to migrate to another SMS
+// provider. The new platform is created before the switch, so one that
+// fails to start leaves the current one in place. Sends in flight finish on
+// the previous platform, which is closed once they complete; sends that
+// start after ReplacePlatform returns use the new one.
+func (c *clientImpl) ReplacePlatform(name string, factory platform.Factory, cfg interface{}) error {
+	if factory == nil || cfg == nil {
+		return fmt.Errorf("platform %s: factory and configuration are required", name)
+	}
+
+	c.reconfigureMu.Lock()
+	defer c.reconfigureMu.Unlock()
+
+	current := c.currentPlatforms()
+	if _, exists := current.external[name]; !exists {
+		if builtinPlatforms[name] {
+			return fmt.Errorf("platform %s is built in, use ReloadConfig to replace it", name)
+		}
+		return fmt.Errorf("platform %s not registered", name)
+	}
+
+	external := copyExternal(current.external)
+	external[name] = externalPlatform{factory: factory, config: cfg}
+	return c.updateExternal(current, external)
+}
+
+// updateExternal switches to a platform set with new external platforms
+func (c *clientImpl) updateExternal(current *platformSet, external map[string]externalPlatform) error {
+	next, err := newPlatformSet(current.source, external, c.logger)
+	if err != nil {
+		return err
+	}
+	return c.swapPlatforms(next, nil)
+}
+
+// copyExternal copies the external platforms of a platform set
+func copyExternal(external map[string]externalPlatform) map[string]externalPlatform {
+	copied := make(map[string]externalPlatform, len(external)+1)
+	for name, ext := range external {
+		copied[name] = ext
+	}
+	return copied
+}
+
+// sortedPlatforms returns the names of external platforms in order
+func sortedPlatforms(external map[string]externalPlatform) []string {
+	names := make([]string, 0, len(external))
+	for name := range external {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -4,29 +4,55 @@ package notifyhub
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/kart-io/notifyhub/pkg/archive"
 	"github.com/kart-io/notifyhub/pkg/async"
 	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/contact"
 	"github.com/kart-io/notifyhub/pkg/message"
 	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/platform/plugin"
 	"github.com/kart-io/notifyhub/pkg/platforms/email"
 	"github.com/kart-io/notifyhub/pkg/platforms/feishu"
 	"github.com/kart-io/notifyhub/pkg/platforms/slack"
 	"github.com/kart-io/notifyhub/pkg/platforms/webhook"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
 	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/redact"
+	"github.com/kart-io/notifyhub/pkg/secret"
+	"github.com/kart-io/notifyhub/pkg/sendctx"
 	"github.com/kart-io/notifyhub/pkg/target"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
+	"github.com/kart-io/notifyhub/pkg/utils/redis"
 )
 
 // clientImpl implements the Client interface
 type clientImpl struct {
-	config           *config.Config
-	platformRegistry platform.Registry
-	asyncQueue       *async.MemoryQueue
-	logger           logger.Logger
+	config      *config.Config
+	platforms   *platformSet
+	platformsMu sync.RWMutex
+	asyncQueue  async.ProcessingQueue
+	expander    *contact.Expander
+	validator   *target.Validator
+	holds       *holdQueue
+	limiter     ratelimit.Limiter
+	logger      logger.Logger
+	redactor    *redact.Redactor // nil when redaction is disabled
+	stopRefresh chan struct{}
+
+	// reconfigureMu serializes the changes of the platform set
+	reconfigureMu sync.Mutex
+
+	middleware   []Middleware // replaced, never modified, by Use
+	middlewareMu sync.RWMutex
+
+	enrichers   map[string][]Enricher // by platform, "" for every platform; replaced, never modified, by AddEnricher
+	enrichersMu sync.RWMutex
 
 	// Metrics
 	startTime    time.Time
@@ -34,6 +60,23 @@ type clientImpl struct {
 	totalSent    atomic.Int64
 	totalSuccess atomic.Int64
 	totalFailed  atomic.Int64
+
+	platformPanics atomic.Int64 // sends whose platform panicked
+
+	sendSlots map[string]chan struct{} // by platform, for the platforms with a MaxInFlight cap
+
+	buckets ratelimit.TokenBuckets // counts sends for Quotas
+
+	usage ratelimit.UsageCounter // counts messages for UsageQuotas
+
+	paused  pausedPlatforms // the platforms paused by PausePlatform
+	pauseMu sync.Mutex
+
+	replayKeys IdempotencyStore // the keys of the messages replayed without a store of their own
+
+	rateState *rateLimitState // saves the state of limiter and buckets, nil when not configured
+
+	archiver *archive.Archiver // writes completed messages to long-term storage, nil when not configured
 }
 
 // NewClient creates a new NotifyHub client with the given configuration
@@ -53,49 +96,87 @@ func NewClient(cfg *config.Config) (Client, error) {
 		return nil, fmt.Errorf("logger instance is required")
 	}
 
-	// Create platform registry
-	registry := platform.NewRegistry(logger)
-
-	// Register platform factories
-	if err := registerPlatformFactories(registry, cfg, logger); err != nil {
-		return nil, fmt.Errorf("failed to register platform factories: %w", err)
+	// Mask personal data and secrets in logs, errors and receipts
+	redactor, err := cfg.Redactor()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	logger = redactor.Logger(logger)
 
-	// Set platform configurations
-	if err := setPlatformConfigurations(registry, cfg); err != nil {
-		return nil, fmt.Errorf("failed to set platform configurations: %w", err)
+	// Create the platform registry
+	platforms, err := newPlatformSet(cfg, nil, logger)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get async configuration with defaults
 	asyncConfig := cfg.GetAsyncDefaults()
 
 	// Create async queue if pool mode is enabled
-	var asyncQueue *async.MemoryQueue
+	var asyncQueue async.ProcessingQueue
+	var redisQueue *async.RedisQueue
 	if cfg.IsPoolModeEnabled() {
 		queueConfig := async.QueueConfig{
 			Workers:    asyncConfig.Workers,
 			BufferSize: asyncConfig.BufferSize,
 			Timeout:    asyncConfig.Timeout,
 		}
-		asyncQueue = async.NewMemoryQueue(queueConfig)
-
-		// Start the queue
-		ctx := context.Background()
-		if err := asyncQueue.Start(ctx); err != nil {
-			return nil, fmt.Errorf("failed to start async queue: %w", err)
+		if asyncConfig.Queue == config.QueueRedis {
+			// Failed messages are retried with the backoff of the retry
+			// policy
+			retry := cfg.RetryPolicyFor("")
+			if retry.BackoffFactor <= 0 {
+				retry.BackoffFactor = config.DefaultRetryBackoffFactor
+			}
+			queueConfig.RetryPolicy = async.RetryPolicy{
+				InitialInterval: retry.InitialInterval,
+				MaxInterval:     retry.MaxInterval,
+				Multiplier:      retry.BackoffFactor,
+			}
+			var cipher *secret.PayloadCipher
+			if asyncConfig.Redis.Encryption != nil {
+				cipher = secret.NewPayloadCipher(asyncConfig.Redis.Encryption)
+			}
+			redisQueue, err = async.NewRedisQueue(async.RedisQueueConfig{
+				QueueConfig: queueConfig,
+				Redis: redis.Options{
+					Addr:     asyncConfig.Redis.Addr,
+					Username: asyncConfig.Redis.Username,
+					Password: asyncConfig.Redis.Password,
+					DB:       asyncConfig.Redis.DB,
+				},
+				KeyPrefix:         asyncConfig.Redis.KeyPrefix,
+				VisibilityTimeout: asyncConfig.Redis.VisibilityTimeout,
+				MaxAttempts:       asyncConfig.Redis.MaxAttempts,
+				Cipher:            cipher,
+				Logger:            logger,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to create redis queue: %w", err)
+			}
+			asyncQueue = redisQueue
+		} else {
+			asyncQueue = async.NewMemoryQueue(queueConfig)
 		}
-
-		logger.Info("Goroutine pool enabled", "workers", asyncConfig.Workers, "buffer_size", asyncConfig.BufferSize)
-	} else {
-		logger.Info("Using direct goroutine mode (pool disabled)")
 	}
 
 	client := &clientImpl{
-		config:           cfg,
-		platformRegistry: registry,
-		asyncQueue:       asyncQueue,
-		logger:           logger,
-		startTime:        time.Now(),
+		config:     cfg,
+		platforms:  platforms,
+		asyncQueue: asyncQueue,
+		validator:  target.NewValidator(cfg.DefaultRegion),
+		logger:     logger,
+		redactor:   redactor,
+		startTime:  time.Now(),
+	}
+
+	// Create group expander if groups or a contact directory are configured
+	if cfg.HasGroups() {
+		client.expander, err = newGroupExpander(cfg, client.isPlatformConfigured)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create group expander: %w", err)
+		}
+		logger.Info("Group expansion enabled", "groups", len(cfg.Groups), "directory", cfg.ContactDirectory != nil)
 	}
 	// Initialize atomic counters
 	client.activeTasks.Store(0)
@@ -103,7 +184,77 @@ func NewClient(cfg *config.Config) (Client, error) {
 	client.totalSuccess.Store(0)
 	client.totalFailed.Store(0)
 
-	logger.Info("NotifyHub client created successfully")
+	// Count sends per recipient for target rate limits
+	if len(cfg.TargetRateLimits) > 0 {
+		client.limiter = cfg.RateLimiter
+		if client.limiter == nil {
+			client.limiter = ratelimit.NewMemoryLimiter()
+		}
+		logger.Info("Target rate limits enabled", "limits", len(cfg.TargetRateLimits))
+	}
+
+	// Count sends for platform quotas
+	if len(cfg.Quotas) > 0 {
+		client.buckets = cfg.TokenBuckets
+		if client.buckets == nil {
+			client.buckets = ratelimit.NewMemoryTokenBuckets()
+		}
+		logger.Info("Platform quotas enabled", "quotas", len(cfg.Quotas))
+	}
+
+	client.replayKeys = NewMemoryIdempotencyStore()
+
+	// Count messages for usage quotas
+	client.usage = cfg.UsageCounter
+	if client.usage == nil {
+		client.usage = ratelimit.NewMemoryUsageCounter()
+	}
+	if len(cfg.UsageQuotas) > 0 {
+		logger.Info("Usage quotas enabled", "quotas", len(cfg.UsageQuotas))
+	}
+
+	// Restore the budgets spent before a restart
+	client.rateState = startRateLimitState(cfg.RateLimitState, client.limiter, client.buckets, logger)
+
+	// Archive completed messages for long-term retention
+	client.archiver = startArchiver(cfg, logger)
+
+	// Cap the concurrent sends of platforms
+	if len(cfg.MaxInFlight) > 0 {
+		client.sendSlots = newSendSlots(cfg.MaxInFlight)
+		logger.Info("Platform concurrency caps enabled", "max_in_flight", cfg.MaxInFlight)
+	}
+
+	// Hold deferrable messages outside the recipients' delivery window and
+	// sends deferred by rate limits
+	if cfg.DeliveryWindow != nil || hasDeferLimit(cfg.TargetRateLimits, cfg.Quotas) {
+		client.holds = newHoldQueue(client.releaseHeld)
+	}
+	if cfg.DeliveryWindow != nil {
+		logger.Info("Delivery window enabled", "start", cfg.DeliveryWindow.Start, "end", cfg.DeliveryWindow.End)
+	}
+
+	// Resolve platform secrets again to pick up rotated credentials
+	if cfg.Secrets != nil && cfg.SecretRefresh > 0 {
+		client.stopRefresh = make(chan struct{})
+		go client.refreshSecrets(cfg.SecretRefresh, client.stopRefresh)
+	}
+
+	// Start the async queue once the client can process what earlier runs
+	// left in it
+	if asyncQueue != nil {
+		if redisQueue != nil {
+			redisQueue.SetProcessor(client.processQueued)
+		}
+		if err := asyncQueue.Start(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to start async queue: %w", err)
+		}
+		logger.Info("Goroutine pool enabled", "workers", asyncConfig.Workers, "buffer_size", asyncConfig.BufferSize, "queue", asyncConfig.Queue)
+	} else {
+		logger.Info("Using direct goroutine mode (pool disabled)")
+	}
+
+	logger.Info("NotifyHub client created successfully", "config_version", platforms.version)
 	return client, nil
 }
 
@@ -129,7 +280,7 @@ func registerPlatformFactories(registry platform.Registry, cfg *config.Config, l
 			return feishu.NewPlatform(config, logger)
 		}
 
-		if err := registry.RegisterFactory("feishu", factory); err != nil {
+		if err := registry.RegisterFactory("feishu", withDryRun("feishu", withRegions("feishu", withCredentials("feishu", factory, cfg.Credentials["feishu"], logger), cfg.Regions["feishu"], cfg.RegionFailover, logger), cfg, logger)); err != nil {
 			return fmt.Errorf("failed to register feishu factory: %w", err)
 		}
 	}
@@ -140,7 +291,7 @@ func registerPlatformFactories(registry platform.Registry, cfg *config.Config, l
 			return email.NewPlatform(config, logger)
 		}
 
-		if err := registry.RegisterFactory("email", factory); err != nil {
+		if err := registry.RegisterFactory("email", withDryRun("email", withRegions("email", withCredentials("email", factory, cfg.Credentials["email"], logger), cfg.Regions["email"], cfg.RegionFailover, logger), cfg, logger)); err != nil {
 			return fmt.Errorf("failed to register email factory: %w", err)
 		}
 	}
@@ -151,7 +302,7 @@ func registerPlatformFactories(registry platform.Registry, cfg *config.Config, l
 			return webhook.NewPlatform(config, logger)
 		}
 
-		if err := registry.RegisterFactory("webhook", factory); err != nil {
+		if err := registry.RegisterFactory("webhook", withDryRun("webhook", withRegions("webhook", withCredentials("webhook", factory, cfg.Credentials["webhook"], logger), cfg.Regions["webhook"], cfg.RegionFailover, logger), cfg, logger)); err != nil {
 			return fmt.Errorf("failed to register webhook factory: %w", err)
 		}
 	}
@@ -162,19 +313,37 @@ func registerPlatformFactories(registry platform.Registry, cfg *config.Config, l
 			return slack.NewPlatform(config, logger)
 		}
 
-		if err := registry.RegisterFactory("slack", factory); err != nil {
+		if err := registry.RegisterFactory("slack", withDryRun("slack", withRegions("slack", withCredentials("slack", factory, cfg.Credentials["slack"], logger), cfg.Regions["slack"], cfg.RegionFailover, logger), cfg, logger)); err != nil {
 			return fmt.Errorf("failed to register slack factory: %w", err)
 		}
 	}
 
+	// Register the plugins, which run their senders as separate programs
+	for name, p := range cfg.Plugins {
+		factory := plugin.Factory(plugin.Config{
+			Command:      p.Command,
+			Args:         p.Args,
+			Env:          p.Env,
+			StartTimeout: p.StartTimeout,
+		})
+		if err := registry.RegisterFactory(name, withDryRun(name, factory, cfg, logger)); err != nil {
+			return fmt.Errorf("failed to register plugin %s: %w", name, err)
+		}
+	}
+
 	return nil
 }
 
-// setPlatformConfigurations sets platform configurations in the registry
+// setPlatformConfigurations sets platform configurations in the registry.
+// Sections without a proxy of their own use the global one.
 func setPlatformConfigurations(registry platform.Registry, cfg *config.Config) error {
 	// Set Feishu configuration
 	if cfg.Feishu != nil {
-		if err := registry.SetConfig("feishu", cfg.Feishu); err != nil {
+		feishu := *cfg.Feishu
+		if feishu.Proxy == nil {
+			feishu.Proxy = cfg.Proxy
+		}
+		if err := registry.SetConfig("feishu", &feishu); err != nil {
 			return fmt.Errorf("failed to set feishu configuration: %w", err)
 		}
 	}
@@ -188,26 +357,52 @@ func setPlatformConfigurations(registry platform.Registry, cfg *config.Config) e
 
 	// Set Webhook configuration
 	if cfg.Webhook != nil {
-		if err := registry.SetConfig("webhook", cfg.Webhook); err != nil {
+		webhook := *cfg.Webhook
+		if webhook.Proxy == nil {
+			webhook.Proxy = cfg.Proxy
+		}
+		if err := registry.SetConfig("webhook", &webhook); err != nil {
 			return fmt.Errorf("failed to set webhook configuration: %w", err)
 		}
 	}
 
 	// Set Slack configuration
 	if cfg.Slack != nil {
-		if err := registry.SetConfig("slack", cfg.Slack); err != nil {
+		slack := *cfg.Slack
+		if slack.Proxy == nil {
+			slack.Proxy = cfg.Proxy
+		}
+		if err := registry.SetConfig("slack", &slack); err != nil {
 			return fmt.Errorf("failed to set slack configuration: %w", err)
 		}
 	}
 
+	// Set the plugin settings
+	for name, p := range cfg.Plugins {
+		settings := p.Settings
+		if settings == nil {
+			settings = map[string]string{}
+		}
+		if err := registry.SetConfig(name, settings); err != nil {
+			return fmt.Errorf("failed to set plugin %s settings: %w", name, err)
+		}
+	}
+
 	return nil
 }
 
 // Client interface implementation
 
-// Send sends a message synchronously
-func (c *clientImpl) Send(ctx context.Context, msg *message.Message) (*receiptpkg.Receipt, error) {
-	c.logger.Debug("NotifyHub.Send() called", "message_id", msg.ID, "targets_count", len(msg.Targets))
+// send sends a message synchronously, after the middleware
+func (c *clientImpl) send(ctx context.Context, msg *message.Message) (*receiptpkg.Receipt, error) {
+	// Deliveries, platforms and enrichers see the values of the message
+	ctx = sendctx.With(ctx, sendctx.FromMessage(msg))
+	log := c.log(ctx)
+	// Debug entries are built only when logged, as they are per target
+	debug := logger.Enabled(log, logger.Debug)
+	if debug {
+		log.Debug("NotifyHub.Send() called", "message_id", msg.ID, "targets_count", len(msg.Targets))
+	}
 
 	// Track active task
 	c.activeTasks.Add(1)
@@ -216,19 +411,27 @@ func (c *clientImpl) Send(ctx context.Context, msg *message.Message) (*receiptpk
 	// Track total messages sent
 	c.totalSent.Add(1)
 
+	msg = c.applyDefaults(msg)
+
 	// Create receipt
 	receipt := receiptpkg.New(msg.ID)
 
+	// Expand group and team targets into their members
+	targets := c.expandTargets(ctx, msg, receipt)
+	receipt.Results = slices.Grow(receipt.Results, len(targets))
+
 	// Send to all platforms configured in message targets
-	for i, tgt := range msg.Targets {
-		c.logger.Debug("处理目标 %d: Type=%s, Value=%s, Platform=%s", i+1, tgt.Type, tgt.Value, tgt.Platform)
+	for i, tgt := range targets {
+		if debug {
+			log.Debug("Processing target", "index", i+1, "type", tgt.Type, "value", tgt.Value, "platform", tgt.Platform)
+		}
 
 		platformName := tgt.Platform
 		if platformName == "" {
 			// Auto-detect platform based on target type
-			platformName = c.determinePlatformByTargetType(&tgt)
+			platformName = c.determinePlatformByTargetType(msg, &tgt)
 			if platformName == "" {
-				c.logger.Warn("无法确定目标 %d 的平台类型，跳过", i+1)
+				log.Warn("无法确定目标 %d 的平台类型，跳过", i+1)
 				receipt.AddResult(receiptpkg.PlatformResult{
 					Platform:  "unknown",
 					Target:    tgt.Value,
@@ -238,28 +441,26 @@ func (c *clientImpl) Send(ctx context.Context, msg *message.Message) (*receiptpk
 				})
 				continue
 			}
-			c.logger.Debug("自动检测到平台类型", "target_type", tgt.Type, "platform", platformName)
+			if debug {
+				log.Debug("自动检测到平台类型", "target_type", tgt.Type, "platform", platformName)
+			}
 		}
 
-		platform, err := c.platformRegistry.GetPlatform(platformName)
-		if err != nil {
-			c.logger.Error("Failed to get platform", "platform", platformName, "error", err)
-			receipt.AddResult(receiptpkg.PlatformResult{
-				Platform:  platformName,
-				Target:    tgt.Value,
-				Success:   false,
-				Error:     err.Error(),
-				Timestamp: receipt.Timestamp,
-			})
+		if c.isExcluded(msg, platformName, tgt, receipt) {
+			continue
+		}
+
+		enriched, ok := c.enrich(ctx, msg, platformName, tgt, receipt)
+		if !ok {
 			continue
 		}
 
-		c.logger.Debug("Calling platform send method", "platform", platformName, "target", tgt.Value)
-		results, err := platform.Send(ctx, msg, []target.Target{tgt})
-		c.logger.Debug("Platform send completed", "platform", platformName, "success", err == nil, "results_count", len(results))
+		normalized, err := expandTemplate(enriched, tgt)
+		if err == nil {
+			normalized, err = c.validator.Normalize(normalized)
+		}
 		if err != nil {
-			c.logger.Error("Failed to send message", "platform", platformName, "error", err)
-			c.totalFailed.Add(1) // Track failed send
+			log.Warn("Invalid target", "type", tgt.Type, "error", err)
 			receipt.AddResult(receiptpkg.PlatformResult{
 				Platform:  platformName,
 				Target:    tgt.Value,
@@ -269,71 +470,195 @@ func (c *clientImpl) Send(ctx context.Context, msg *message.Message) (*receiptpk
 			})
 			continue
 		}
+		tgt = normalized
 
-		// Add results to receipt
-		for _, result := range results {
-			if result.Success {
-				c.totalSuccess.Add(1) // Track successful send
-			} else {
-				c.totalFailed.Add(1) // Track failed send
-			}
-			receipt.AddResult(receiptpkg.PlatformResult{
-				Platform:  platformName,
-				Target:    result.Target.Value,
-				Success:   result.Success,
-				MessageID: result.MessageID,
-				Error:     "",
-				Timestamp: receipt.Timestamp,
-			})
+		if c.isDisabled(ctx, enriched, platformName, tgt, receipt) {
+			continue
+		}
+
+		if c.isBlocked(ctx, enriched, platformName, tgt, receipt) {
+			continue
+		}
+
+		if c.isSuppressed(ctx, platformName, tgt, receipt) {
+			continue
+		}
+
+		if c.isQuarantined(ctx, platformName, tgt, receipt) {
+			continue
+		}
+
+		if c.holdForPause(enriched, platformName, tgt, receipt) {
+			continue
 		}
+
+		if c.holdForWindow(enriched, platformName, tgt, receipt) {
+			continue
+		}
+
+		if c.isRateLimited(ctx, enriched, platformName, tgt, receipt) {
+			continue
+		}
+
+		c.deliver(ctx, enriched, platformName, tgt, receipt)
 	}
 
+	c.redactReceipt(receipt)
 	return receipt, nil
 }
 
-// SendBatch sends multiple messages synchronously
-func (c *clientImpl) SendBatch(ctx context.Context, msgs []*message.Message) ([]*receiptpkg.Receipt, error) {
-	receipts := make([]*receiptpkg.Receipt, len(msgs))
-	var lastErr error
+// redactReceipt masks the personal data and secrets in the errors of a
+// receipt. Targets are kept, as callers match the results with them.
+func (c *clientImpl) redactReceipt(receipt *receiptpkg.Receipt) {
+	if c.redactor == nil {
+		return
+	}
+	for i := range receipt.Results {
+		receipt.Results[i].Error = c.redactor.String(receipt.Results[i].Error)
+	}
+}
 
-	for i, msg := range msgs {
-		receipt, err := c.Send(ctx, msg)
-		if err != nil {
-			lastErr = err
-		}
-		receipts[i] = receipt
+// deliver sends a message to a single target on a platform and records the
+// outcome on the receipt
+func (c *clientImpl) deliver(ctx context.Context, msg *message.Message, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) {
+	log := c.log(ctx)
+	platforms := c.acquirePlatforms()
+	defer platforms.inflight.Done()
+
+	platform, err := platforms.registry.GetPlatform(platformName)
+	if err != nil {
+		err = platforms.scrubber.Error(err)
+		log.Error("Failed to get platform", "platform", platformName, "config_version", platforms.version, "error", err)
+		receipt.AddResult(receiptpkg.PlatformResult{
+			Platform:  platformName,
+			Target:    tgt.Value,
+			Success:   false,
+			Error:     err.Error(),
+			Timestamp: receipt.Timestamp,
+		})
+		return
+	}
+
+	msg, err = c.fallbackFormat(msg, platform)
+	if err != nil {
+		receipt.AddResult(receiptpkg.PlatformResult{
+			Platform:  platformName,
+			Target:    tgt.Value,
+			Success:   false,
+			Error:     err.Error(),
+			Timestamp: receipt.Timestamp,
+		})
+		return
+	}
+
+	if !c.takeQuota(ctx, msg, platformName, tgt, receipt) {
+		return
+	}
+
+	release, err := c.acquireSlot(ctx, platformName)
+	if err != nil {
+		log.Warn("Send not started", "platform", platformName, "error", err)
+		receipt.AddResult(receiptpkg.PlatformResult{
+			Platform:  platformName,
+			Target:    tgt.Value,
+			Success:   false,
+			Error:     err.Error(),
+			Timestamp: receipt.Timestamp,
+		})
+		return
+	}
+	defer release()
+
+	sendCtx, cancel, timeout := c.sendTimeout(ctx, msg, platforms.config, platformName)
+	defer cancel()
+
+	debug := logger.Enabled(log, logger.Debug)
+	if debug {
+		log.Debug("Calling platform send method", "platform", platformName, "target", tgt.Value, "timeout", timeout)
+	}
+	results, err := c.sendWithRetries(sendCtx, platform, msg, []target.Target{tgt})
+	err = platforms.scrubber.Error(err)
+	for _, result := range results {
+		result.Error = platforms.scrubber.Error(result.Error)
+	}
+	if debug {
+		log.Debug("Platform send completed", "platform", platformName, "success", err == nil, "results_count", len(results))
+	}
+	if err != nil {
+		log.Error("Failed to send message", "platform", platformName, "config_version", platforms.version, "error", err)
+		c.totalFailed.Add(1) // Track failed send
+		c.trackDelivery(ctx, msg, platformName, tgt, err)
+		receipt.AddResult(receiptpkg.PlatformResult{
+			Platform:  platformName,
+			Target:    tgt.Value,
+			Success:   false,
+			Error:     err.Error(),
+			Timeout:   timeout,
+			Timestamp: receipt.Timestamp,
+		})
+		return
 	}
 
-	return receipts, lastErr
+	// Add results to receipt
+	for _, result := range results {
+		result.Timeout = timeout
+		errMsg := ""
+		if result.Success {
+			c.totalSuccess.Add(1) // Track successful send
+			c.trackDelivery(ctx, msg, platformName, tgt, nil)
+		} else {
+			c.totalFailed.Add(1) // Track failed send
+			if result.Error != nil {
+				errMsg = result.Error.Error()
+				c.trackDelivery(ctx, msg, platformName, tgt, result.Error)
+			}
+		}
+		receipt.AddResult(receiptpkg.PlatformResult{
+			Platform:  platformName,
+			Target:    result.Target.Value,
+			Success:   result.Success,
+			MessageID: result.MessageID,
+			Error:     errMsg,
+			Timeout:   result.Timeout,
+			DryRun:    isDryRun(platform),
+			Timestamp: receipt.Timestamp,
+		})
+	}
 }
 
 // SendAsync sends a message asynchronously using the goroutine pool
 func (c *clientImpl) SendAsync(ctx context.Context, msg *message.Message, opts ...async.Option) (async.Handle, error) {
-	c.logger.Debug("NotifyHub.SendAsync() called", "message_id", msg.ID, "targets_count", len(msg.Targets))
+	c.log(ctx).Debug("NotifyHub.SendAsync() called", "message_id", msg.ID, "targets_count", len(msg.Targets))
+
+	// Reject invalid targets before anything is enqueued
+	if err := c.validateTargets(msg); err != nil {
+		return nil, err
+	}
+	// Carry the context values with the message, as async sends run
+	// under another context
+	msg = sendctx.From(ctx).Attach(msg)
+
+	// Count usage quotas before anything is enqueued
+	charged, err := c.chargeUsage(ctx, msg)
+	if err != nil {
+		return nil, c.redactor.Error(err)
+	}
 
 	// Check if async queue is enabled
 	if c.asyncQueue != nil && c.config.IsPoolModeEnabled() {
 		// Use goroutine pool via async queue
-		processor := func(ctx context.Context, message *message.Message, targets []target.Target) async.Result {
-			// Call the synchronous Send method
-			receipt, err := c.Send(ctx, message)
-			return async.Result{
-				Receipt: receipt,
-				Error:   err,
-			}
-		}
-
-		handle, err := c.asyncQueue.EnqueueWithProcessor(ctx, msg, msg.Targets, processor, opts...)
+		handle, err := c.asyncQueue.EnqueueWithProcessor(ctx, msg, msg.Targets, c.processQueued, opts...)
 		if err != nil {
-			c.logger.Error("Failed to enqueue message for async processing", "message_id", msg.ID, "error", err)
+			c.log(ctx).Error("Failed to enqueue message for async processing", "message_id", msg.ID, "error", err)
+			c.refundUsage(ctx, charged)
 			return nil, err
 		}
 
-		c.logger.Debug("Message enqueued for async processing", "message_id", msg.ID)
+		c.log(ctx).Debug("Message enqueued for async processing", "message_id", msg.ID)
 		return handle, nil
 	} else {
 		// Fallback to direct goroutine (legacy mode)
-		c.logger.Debug("Using legacy async mode (direct goroutine)", "message_id", msg.ID)
+		c.log(ctx).Debug("Using legacy async mode (direct goroutine)", "message_id", msg.ID)
 
 		var handle async.Handle = async.NewMemoryHandle(msg.ID)
 
@@ -347,8 +672,8 @@ func (c *clientImpl) SendAsync(ctx context.Context, msg *message.Message, opts .
 				defer cancel()
 			}
 
-			// Call the synchronous Send method
-			receipt, err := c.Send(asyncCtx, message)
+			// Send synchronously, the usage being counted already
+			receipt, err := c.dispatch(asyncCtx, message)
 
 			// Create result
 			result := async.Result{
@@ -360,7 +685,7 @@ func (c *clientImpl) SendAsync(ctx context.Context, msg *message.Message, opts .
 			if memHandle, ok := asyncHandle.(*async.MemoryHandle); ok {
 				memHandle.SetResultWithCallback(result, message)
 			}
-			c.logger.Debug("Async result sent successfully", "message_id", message.ID)
+			c.log(ctx).Debug("Async result sent successfully", "message_id", message.ID)
 		}(ctx, msg, handle)
 
 		return handle, nil
@@ -369,16 +694,42 @@ func (c *clientImpl) SendAsync(ctx context.Context, msg *message.Message, opts .
 
 // SendAsyncBatch sends multiple messages asynchronously using the goroutine pool
 func (c *clientImpl) SendAsyncBatch(ctx context.Context, msgs []*message.Message, opts ...async.Option) (async.BatchHandle, error) {
-	c.logger.Debug("NotifyHub.SendAsyncBatch() called", "message_count", len(msgs))
+	c.log(ctx).Debug("NotifyHub.SendAsyncBatch() called", "message_count", len(msgs))
 
 	if len(msgs) == 0 {
 		return nil, fmt.Errorf("no messages provided for batch processing")
 	}
 
+	// Reject invalid targets before anything is enqueued
+	for _, msg := range msgs {
+		if err := c.validateTargets(msg); err != nil {
+			return nil, err
+		}
+	}
+	values := sendctx.From(ctx)
+	attached := make([]*message.Message, len(msgs))
+	for i, msg := range msgs {
+		attached[i] = values.Attach(msg)
+	}
+	msgs = attached
+
+	// Count usage quotas before anything is enqueued, rejecting the batch
+	// when a message is over a quota
+	charged := make([][]usageCharge, len(msgs))
+	for i, msg := range msgs {
+		var err error
+		if charged[i], err = c.chargeUsage(ctx, msg); err != nil {
+			for _, refund := range charged[:i] {
+				c.refundUsage(ctx, refund)
+			}
+			return nil, c.redactor.Error(fmt.Errorf("batch rejected, message %d (%s): %w", i, msg.ID, err))
+		}
+	}
+
 	// Check if async queue is enabled
 	if c.asyncQueue != nil && c.config.IsPoolModeEnabled() {
 		// Use goroutine pool via async queue
-		c.logger.Debug("Using goroutine pool for batch processing", "message_count", len(msgs))
+		c.log(ctx).Debug("Using goroutine pool for batch processing", "message_count", len(msgs))
 
 		handles := make([]async.Handle, len(msgs))
 
@@ -388,18 +739,12 @@ func (c *clientImpl) SendAsyncBatch(ctx context.Context, msgs []*message.Message
 			msg := currentMsg
 			msgIndex := i
 
-			processor := func(ctx context.Context, message *message.Message, targets []target.Target) async.Result {
-				// Call the synchronous Send method
-				receipt, err := c.Send(ctx, message)
-				return async.Result{
-					Receipt: receipt,
-					Error:   err,
-				}
-			}
-
-			handle, err := c.asyncQueue.EnqueueWithProcessor(ctx, msg, msg.Targets, processor, opts...)
+			handle, err := c.asyncQueue.EnqueueWithProcessor(ctx, msg, msg.Targets, c.processQueued, opts...)
 			if err != nil {
-				c.logger.Error("Failed to enqueue batch message", "message_id", msg.ID, "index", msgIndex, "error", err)
+				c.log(ctx).Error("Failed to enqueue batch message", "message_id", msg.ID, "index", msgIndex, "error", err)
+				for _, refund := range charged[msgIndex:] {
+					c.refundUsage(ctx, refund)
+				}
 				return nil, fmt.Errorf("failed to enqueue message %d: %w", msgIndex, err)
 			}
 			handles[msgIndex] = handle
@@ -407,12 +752,12 @@ func (c *clientImpl) SendAsyncBatch(ctx context.Context, msgs []*message.Message
 
 		// Create batch handle
 		batchHandle := async.NewBatchHandle(handles)
-		c.logger.Debug("Batch messages enqueued for pool processing", "batch_id", batchHandle.BatchID())
+		c.log(ctx).Debug("Batch messages enqueued for pool processing", "batch_id", batchHandle.BatchID())
 
 		return batchHandle, nil
 	} else {
 		// Fallback to direct goroutines (legacy mode)
-		c.logger.Debug("Using legacy batch async mode (direct goroutines)", "message_count", len(msgs))
+		c.log(ctx).Debug("Using legacy batch async mode (direct goroutines)", "message_count", len(msgs))
 
 		// Create individual handles for each message
 		handles := make([]async.Handle, len(msgs))
@@ -436,8 +781,8 @@ func (c *clientImpl) SendAsyncBatch(ctx context.Context, msgs []*message.Message
 						defer cancel()
 					}
 
-					// Call the synchronous Send method
-					receipt, err := c.Send(asyncCtx, msg)
+					// Send synchronously, the usage being counted already
+					receipt, err := c.dispatch(asyncCtx, msg)
 
 					// Create result
 					result := async.Result{
@@ -454,7 +799,7 @@ func (c *clientImpl) SendAsyncBatch(ctx context.Context, msgs []*message.Message
 					if batchMemHandle, ok := batchAsyncHandle.(*async.MemoryBatchHandle); ok {
 						batchMemHandle.AddResult(result)
 					}
-					c.logger.Debug("Batch result sent successfully", "message_id", msg.ID, "batch_id", batchAsyncHandle.BatchID())
+					c.log(ctx).Debug("Batch result sent successfully", "message_id", msg.ID, "batch_id", batchAsyncHandle.BatchID())
 				}(idx, msgItem)
 			}
 		}(ctx, msgs, handles, batchHandle)
@@ -463,22 +808,34 @@ func (c *clientImpl) SendAsyncBatch(ctx context.Context, msgs []*message.Message
 	}
 }
 
+// log returns the logger of the client with the context values of a send
+func (c *clientImpl) log(ctx context.Context) logger.Logger {
+	return logger.With(c.logger, sendctx.From(ctx).LogFields()...)
+}
+
 // Health returns the health status of the client
 func (c *clientImpl) Health(ctx context.Context) (*HealthStatus, error) {
-	platformHealth := c.platformRegistry.Health(ctx)
+	platforms := c.currentPlatforms()
+	platformHealth := platforms.registry.Health(ctx)
 
-	platforms := make(map[string]string)
+	statuses := make(map[string]string)
 	allHealthy := true
 
 	for name, err := range platformHealth {
 		if err != nil {
-			platforms[name] = "unhealthy: " + err.Error()
+			statuses[name] = "unhealthy: " + platforms.scrubber.String(err.Error())
 			allHealthy = false
 		} else {
-			platforms[name] = "healthy"
+			statuses[name] = "healthy"
 		}
 	}
 
+	// Paused platforms do not deliver, whatever their health
+	for _, name := range c.pausedNames() {
+		statuses[name] = "paused"
+		allHealthy = false
+	}
+
 	status := "healthy"
 	if !allHealthy {
 		status = "degraded"
@@ -492,15 +849,78 @@ func (c *clientImpl) Health(ctx context.Context) (*HealthStatus, error) {
 		queueDepth = stats.Pending
 	}
 
-	return &HealthStatus{
-		Status:      status,
-		Platforms:   platforms,
-		Uptime:      uptime,
-		ActiveTasks: c.activeTasks.Load(),
-		QueueDepth:  queueDepth,
-		TotalSent:   c.totalSent.Load(),
-		SuccessRate: c.calculateSuccessRate(),
-	}, nil
+	health := &HealthStatus{
+		Status:        status,
+		Platforms:     statuses,
+		ConfigVersion: platforms.version,
+		Uptime:        uptime,
+		ActiveTasks:   c.activeTasks.Load(),
+		QueueDepth:    queueDepth,
+		TotalSent:     c.totalSent.Load(),
+		SuccessRate:   c.calculateSuccessRate(),
+	}
+	if c.holds != nil {
+		health.Metadata = map[string]interface{}{"held_deliveries": c.holds.Len()}
+	}
+	if panics := c.platformPanics.Load(); panics > 0 {
+		if health.Metadata == nil {
+			health.Metadata = make(map[string]interface{})
+		}
+		health.Metadata["platform_panics"] = panics
+	}
+	return health, nil
+}
+
+// processQueued sends a message taken from the async queue synchronously
+// to the queued targets, which are fewer than the message's when only its
+// failed targets are retried, the usage being counted when it was
+// enqueued. It reports the targets whose delivery failed.
+func (c *clientImpl) processQueued(ctx context.Context, msg *message.Message, targets []target.Target) async.Result {
+	if len(targets) > 0 && len(targets) != len(msg.Targets) {
+		msg = msg.Clone()
+		msg.Targets = targets
+	}
+	receipt, err := c.dispatch(ctx, msg)
+	return async.Result{
+		Receipt: receipt,
+		Error:   err,
+		Failed:  failedTargets(msg.Targets, receipt),
+	}
+}
+
+// failedTargets returns the targets of a message that failed, matched to
+// the results by value. A target that has no result of its own, such as a
+// group expanded into its members, is failed when a result that matches
+// no target failed.
+func failedTargets(targets []target.Target, rcpt *receiptpkg.Receipt) []target.Target {
+	if rcpt == nil || rcpt.Failed == 0 {
+		return nil
+	}
+	values := make(map[string]bool, len(targets))
+	for _, tgt := range targets {
+		values[tgt.Value] = true
+	}
+	failed := make(map[string]bool)
+	results := make(map[string]bool)
+	var unmatched bool
+	for _, result := range rcpt.Results {
+		results[result.Target] = true
+		if result.Success || result.IsSkipped() {
+			continue
+		}
+		if values[result.Target] {
+			failed[result.Target] = true
+		} else {
+			unmatched = true
+		}
+	}
+	var retry []target.Target
+	for _, tgt := range targets {
+		if failed[tgt.Value] || (unmatched && !results[tgt.Value]) {
+			retry = append(retry, tgt)
+		}
+	}
+	return retry
 }
 
 // calculateSuccessRate calculates the success rate percentage
@@ -517,6 +937,26 @@ func (c *clientImpl) calculateSuccessRate() float64 {
 func (c *clientImpl) Close() error {
 	var lastErr error
 
+	// Stop releasing held deliveries
+	if c.holds != nil {
+		if held := c.holds.Close(); held > 0 {
+			c.logger.Warn("Discarding held deliveries on close", "count", held)
+		}
+	}
+
+	c.pauseMu.Lock()
+	for name, held := range c.paused {
+		if len(held) > 0 {
+			c.logger.Warn("Discarding deliveries held for paused platform on close", "platform", name, "count", len(held))
+		}
+	}
+	c.pauseMu.Unlock()
+
+	// Stop refreshing platform secrets
+	if c.stopRefresh != nil {
+		close(c.stopRefresh)
+	}
+
 	// Stop async queue
 	if c.asyncQueue != nil {
 		ctx := context.Background()
@@ -526,8 +966,24 @@ func (c *clientImpl) Close() error {
 		}
 	}
 
+	// Save the rate limit state for the next client
+	if c.rateState != nil {
+		if err := c.rateState.Close(); err != nil {
+			c.logger.Error("Failed to save rate limit state", "error", err)
+			lastErr = err
+		}
+	}
+
+	// Write the messages not archived yet
+	if c.archiver != nil {
+		if err := c.archiver.Close(context.Background()); err != nil {
+			c.logger.Error("Failed to archive messages", "pending", c.archiver.Pending(), "error", err)
+			lastErr = err
+		}
+	}
+
 	// Close platform registry
-	if err := c.platformRegistry.Close(); err != nil {
+	if err := c.currentPlatforms().registry.Close(); err != nil {
 		c.logger.Error("Failed to close platform registry", "error", err)
 		lastErr = err
 	}
@@ -537,7 +993,7 @@ func (c *clientImpl) Close() error {
 }
 
 // determinePlatformByTargetType determines the platform based on target type
-func (c *clientImpl) determinePlatformByTargetType(tgt *target.Target) string {
+func (c *clientImpl) determinePlatformByTargetType(msg *message.Message, tgt *target.Target) string {
 	// Map of direct type to platform mappings
 	directMappings := map[string]string{
 		"email":   "email",
@@ -556,9 +1012,12 @@ func (c *clientImpl) determinePlatformByTargetType(tgt *target.Target) string {
 	case "phone":
 		return c.determinePlatformForPhone()
 	case "dingtalk":
-		return "" // DingTalk requires external platform configuration
+		if c.isPlatformConfigured("dingtalk") {
+			return "dingtalk"
+		}
+		return "" // DingTalk is an external platform, see RegisterPlatform
 	case "user", "group":
-		return c.determinePlatformForUserGroup()
+		return c.determinePlatformForUserGroup(c.platformOrder(msg))
 	default:
 		return c.inferPlatformFromValue(tgt.Value)
 	}
@@ -566,25 +1025,33 @@ func (c *clientImpl) determinePlatformByTargetType(tgt *target.Target) string {
 
 // determinePlatformForPhone determines platform for phone targets
 func (c *clientImpl) determinePlatformForPhone() string {
-	// Check if SMS is configured (via external platform or custom)
-	if c.config.Slack != nil { // Placeholder - should check for SMS config
+	// SMS is an external platform, see RegisterPlatform
+	if c.isPlatformConfigured("sms") {
 		return "sms"
 	}
 	c.logger.Debug("SMS platform not configured, checking alternatives")
 	return ""
 }
 
-// determinePlatformForUserGroup determines platform for user/group targets
-func (c *clientImpl) determinePlatformForUserGroup() string {
+// determinePlatformForUserGroup determines platform for user/group targets,
+// trying the configured platforms of order before the built-in preference
+func (c *clientImpl) determinePlatformForUserGroup(order []string) string {
+	for _, name := range order {
+		if c.isPlatformConfigured(name) {
+			return name
+		}
+	}
+
 	// Try platforms in order of preference
+	cfg := c.currentPlatforms().config
 	platformChecks := []struct {
 		check func() bool
 		name  string
 	}{
-		{c.config.HasEmail, "email"},
-		{c.config.HasFeishu, "feishu"},
-		{c.config.HasSlack, "slack"},
-		{c.config.HasWebhook, "webhook"},
+		{cfg.HasEmail, "email"},
+		{cfg.HasFeishu, "feishu"},
+		{cfg.HasSlack, "slack"},
+		{cfg.HasWebhook, "webhook"},
 	}
 
 	for _, pc := range platformChecks {
@@ -597,14 +1064,16 @@ func (c *clientImpl) determinePlatformForUserGroup() string {
 
 // inferPlatformFromValue infers platform from target value format
 func (c *clientImpl) inferPlatformFromValue(value string) string {
+	cfg := c.currentPlatforms().config
+
 	// Check if value looks like an email
-	if c.looksLikeEmail(value) && c.config.HasEmail() {
+	if c.looksLikeEmail(value) && cfg.HasEmail() {
 		c.logger.Debug("Value looks like email, using email platform", "value", value)
 		return "email"
 	}
 
 	// Check if value looks like a URL
-	if c.looksLikeURL(value) && c.config.HasWebhook() {
+	if c.looksLikeURL(value) && cfg.HasWebhook() {
 		c.logger.Debug("Value looks like URL, using webhook platform", "value", value)
 		return "webhook"
 	}
@@ -1,11 +1,41 @@
 package notifyhub
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/kart-io/notifyhub/pkg/archive"
+	"github.com/kart-io/notifyhub/pkg/audit"
 	"github.com/kart-io/notifyhub/pkg/config"
 	"github.com/kart-io/notifyhub/pkg/config/platforms"
+	"github.com/kart-io/notifyhub/pkg/contact"
+	"github.com/kart-io/notifyhub/pkg/flags"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/preference"
+	"github.com/kart-io/notifyhub/pkg/quarantine"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/redact"
+	"github.com/kart-io/notifyhub/pkg/secret"
+	"github.com/kart-io/notifyhub/pkg/sendctx"
+	"github.com/kart-io/notifyhub/pkg/suppression"
+	"github.com/kart-io/notifyhub/pkg/target"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
 )
 
@@ -185,7 +215,7 @@ func TestRegisterPlatformFactories(t *testing.T) {
 
 	// Verify all platforms are registered
 	impl := client.(*clientImpl)
-	platformNames := impl.platformRegistry.ListPlatforms()
+	platformNames := impl.currentPlatforms().registry.ListPlatforms()
 
 	expectedPlatforms := []string{"email", "feishu", "webhook"}
 	for _, name := range expectedPlatforms {
@@ -685,3 +715,2860 @@ func TestConfig_HasWebhook(t *testing.T) {
 		})
 	}
 }
+
+func TestClientImpl_SendSuppressed(t *testing.T) {
+	store := suppression.NewMemoryStore()
+	_ = store.Add(context.Background(), suppression.Entry{Channel: "email", Address: "optout@example.com", Reason: suppression.ReasonUnsubscribe})
+
+	client, err := NewClient(&config.Config{
+		Email: &platforms.EmailConfig{
+			Host:     "smtp.example.com",
+			Port:     587,
+			Username: "user@example.com",
+			Password: "password",
+			From:     "sender@example.com",
+		},
+		Suppression:    store,
+		LoggerInstance: logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	msg := message.New()
+	msg.Title = "Deploy finished"
+	msg.Body = "v1.2.3 is live"
+	msg.Targets = []target.Target{target.NewEmail("OptOut@example.com")}
+
+	receipt, err := client.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if receipt.Status != receiptpkg.StatusSkipped || receipt.Skipped != 1 || receipt.Failed != 0 {
+		t.Errorf("Send() receipt = %+v, want one suppressed target", receipt)
+	}
+	if receipt.Results[0].Status != receiptpkg.ResultSuppressed {
+		t.Errorf("result status = %v, want %v", receipt.Results[0].Status, receiptpkg.ResultSuppressed)
+	}
+}
+
+func TestClientImpl_SendBlockedByAccessRules(t *testing.T) {
+	recorder := audit.NewMemoryRecorder(0)
+	client, err := NewClientFromOptions(
+		config.WithQuickEmail("smtp.example.com", 587, "sender@example.com"),
+		config.WithTargetAccess(target.AccessRule{Platform: "email", Allow: []string{"example.com"}}),
+		config.WithAuditRecorder(recorder),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	msg := message.New().SetTitle("Invoice").SetBody("attached")
+	msg.Targets = []target.Target{target.NewEmail("someone@gmail.com")}
+
+	receipt, err := client.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(receipt.Results) != 1 || receipt.Results[0].Status != receiptpkg.ResultBlocked {
+		t.Errorf("Send() receipt = %+v, want blocked target", receipt)
+	}
+
+	entries := recorder.Entries()
+	if len(entries) != 1 || entries[0].Action != audit.ActionTargetBlocked || entries[0].MessageID != msg.ID || entries[0].Target != "someone@gmail.com" {
+		t.Errorf("audit entries = %+v, want one blocked send", entries)
+	}
+}
+
+func TestClientImpl_SendContactPreferences(t *testing.T) {
+	ctx := context.Background()
+	directory := contact.NewMemoryStore()
+	_ = directory.PutContact(ctx, &contact.Contact{ID: "alice", Email: "alice@example.com"})
+
+	prefs := preference.NewMemoryStore()
+	_ = prefs.Put(ctx, &preference.Preferences{
+		ContactID: "alice",
+		Topics: map[string]preference.Rule{
+			"deploys":   {QuietHours: &preference.QuietHours{Start: "00:00", End: "23:59"}},
+			"reports":   {Delivery: preference.DeliveryDigest},
+			"marketing": {Muted: true},
+		},
+	})
+	digests := preference.NewDigestBuffer()
+
+	client, err := NewClientFromOptions(
+		config.WithQuickEmail("smtp.example.com", 587, "sender@example.com"),
+		config.WithContactDirectory(directory),
+		config.WithPreferences(prefs),
+		config.WithDigestBuffer(digests),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		topic  string
+		status string
+	}{
+		{"deploys", receiptpkg.ResultQuietHours},
+		{"reports", receiptpkg.ResultDigested},
+		{"marketing", receiptpkg.ResultSuppressed},
+	}
+	for _, tt := range tests {
+		msg := message.New().SetTitle("Update").SetBody("details").SetTopic(tt.topic)
+		msg.Targets = []target.Target{target.NewContact("alice")}
+
+		receipt, err := client.Send(ctx, msg)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if len(receipt.Results) != 1 || receipt.Results[0].Status != tt.status || receipt.Failed != 0 {
+			t.Errorf("Send(%s) receipt = %+v, want %s", tt.topic, receipt, tt.status)
+		}
+	}
+	if digests.Len() != 1 {
+		t.Errorf("digest buffer Len() = %d, want 1", digests.Len())
+	}
+}
+
+func TestClientImpl_SendAsyncRejectsInvalidTargets(t *testing.T) {
+	client, err := NewClientFromOptions(
+		config.WithQuickEmail("smtp.example.com", 587, "sender@example.com"),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	msg := message.New().SetTitle("Hello").SetBody("world")
+	msg.Targets = []target.Target{target.NewEmail("not-an-address")}
+
+	if _, err := client.SendAsync(context.Background(), msg); err == nil {
+		t.Error("SendAsync() expected error for invalid email target")
+	}
+}
+
+func TestClientImpl_SendAliases(t *testing.T) {
+	ctx := context.Background()
+	billing := []target.Target{
+		target.NewEmail("ap@example.com"),
+		target.NewEmail("ar@example.com"),
+		target.NewEmail("finance@example.com"),
+	}
+
+	// Suppress the alias targets so that the test does not need an SMTP server
+	store := suppression.NewMemoryStore()
+	for _, tgt := range billing {
+		_ = store.Add(ctx, suppression.Entry{Channel: suppression.AllChannels, Address: tgt.Value})
+	}
+
+	client, err := NewClientFromOptions(
+		config.WithQuickEmail("smtp.example.com", 587, "sender@example.com"),
+		config.WithAlias("billing-dl", billing...),
+		config.WithSuppressionStore(store),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	msg := message.New().SetTitle("Invoice run").SetBody("done")
+	msg.Targets = []target.Target{target.NewAlias("billing-dl"), target.NewAlias("missing")}
+
+	receipt, err := client.Send(ctx, msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if receipt.Total != 4 || receipt.Skipped != 3 || receipt.Failed != 1 {
+		t.Errorf("Send() receipt = %+v, want three alias targets and one unknown alias", receipt)
+	}
+
+	if _, err := client.SendAsync(ctx, msg); err == nil {
+		t.Error("SendAsync() expected error for unknown alias")
+	}
+}
+
+func TestClientImpl_SendDedupeRecipients(t *testing.T) {
+	ctx := context.Background()
+	directory := contact.NewMemoryStore()
+	_ = directory.PutContact(ctx, &contact.Contact{
+		ID:        "alice",
+		Email:     "alice@example.com",
+		Channels:  map[string]string{"feishu": "ou_alice"},
+		Platforms: []string{"feishu", "email"},
+	})
+
+	// Suppress alice so that the test does not need Feishu or SMTP servers
+	store := suppression.NewMemoryStore()
+	_ = store.Add(ctx, suppression.Entry{Channel: suppression.AllChannels, Address: "ou_alice"})
+
+	client, err := NewClientFromOptions(
+		config.WithQuickFeishu("https://open.feishu.cn/open-apis/bot/v2/hook/test", ""),
+		config.WithQuickEmail("smtp.example.com", 587, "sender@example.com"),
+		config.WithContactDirectory(directory),
+		config.WithRecipientDedupe(),
+		config.WithSuppressionStore(store),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	msg := message.New().SetTitle("Incident").SetBody("resolved")
+	msg.Targets = []target.Target{
+		target.NewEmail("Alice@example.com"),
+		target.NewFeishuUser("ou_alice"),
+		target.NewContact("alice"),
+	}
+
+	receipt, err := client.Send(ctx, msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	statuses := make(map[string]int)
+	for _, result := range receipt.Results {
+		statuses[result.Platform+"/"+result.Status]++
+	}
+	if statuses["feishu/"+receiptpkg.ResultSuppressed] != 1 || statuses["email/"+receiptpkg.ResultDuplicate] != 1 || statuses["feishu/"+receiptpkg.ResultDuplicate] != 1 {
+		t.Errorf("Send() results = %+v, want alice reached once on feishu", receipt.Results)
+	}
+}
+
+func TestClientImpl_SendTargetRateLimits(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		limit  ratelimit.Limit
+		status string
+	}{
+		{"drop", ratelimit.Limit{Platform: "webhook", Max: 1, Per: time.Hour}, receiptpkg.ResultRateLimited},
+		{"defer", ratelimit.Limit{Platform: "webhook", Max: 1, Per: 50 * time.Millisecond, Policy: ratelimit.PolicyDefer}, receiptpkg.ResultHeld},
+		{"wait", ratelimit.Limit{Platform: "webhook", Max: 1, Per: 50 * time.Millisecond, Policy: ratelimit.PolicyWait}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client, err := NewClientFromOptions(
+				config.WithQuickWebhook(server.URL),
+				config.WithTargetRateLimit(tt.limit),
+				config.WithLogger(logger.Discard),
+			)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+
+			var receipts []*receiptpkg.Receipt
+			for i := 0; i < 2; i++ {
+				msg := message.New().SetTitle("Alert").SetBody("disk full")
+				msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+				receipt, err := client.Send(context.Background(), msg)
+				if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+				receipts = append(receipts, receipt)
+			}
+
+			if receipts[0].Successful != 1 {
+				t.Errorf("first Send() receipt = %+v, want delivered", receipts[0])
+			}
+			if len(receipts[1].Results) != 1 || receipts[1].Results[0].Status != tt.status || receipts[1].Failed != 0 {
+				t.Errorf("second Send() receipt = %+v, want %s", receipts[1], tt.status)
+			}
+
+			wantRequests := int32(1)
+			if tt.limit.Policy != "" {
+				wantRequests = 2
+			}
+			deadline := time.Now().Add(time.Second)
+			for requests.Load() < wantRequests && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if requests.Load() != wantRequests {
+				t.Errorf("webhook requests = %d, want %d", requests.Load(), wantRequests)
+			}
+		})
+	}
+}
+
+func TestClientImpl_SendTemplatedWebhook(t *testing.T) {
+	paths := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.EscapedPath()
+	}))
+	defer server.Close()
+
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook(server.URL),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	msg := message.New().SetTitle("Alert").SetBody("disk full").SetVariable("tenant_id", "acme/eu")
+	msg.Targets = []target.Target{target.NewWebhook(server.URL + "/hooks/{{tenant_id}}")}
+	receipt, err := client.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if receipt.Successful != 1 {
+		t.Fatalf("Send() receipt = %+v, want delivered", receipt)
+	}
+	if got := <-paths; got != "/hooks/acme%2Feu" {
+		t.Errorf("webhook path = %q, want /hooks/acme%%2Feu", got)
+	}
+
+	missing := message.New().SetTitle("Alert").SetBody("disk full")
+	missing.Targets = []target.Target{target.NewWebhook(server.URL + "/hooks/{{tenant_id}}")}
+	receipt, err = client.Send(context.Background(), missing)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if receipt.Failed != 1 || !strings.Contains(receipt.Results[0].Error, "tenant_id") {
+		t.Errorf("Send() receipt = %+v, want failure naming the missing variable", receipt)
+	}
+}
+
+func TestClientImpl_ReloadConfig(t *testing.T) {
+	tokens := make(chan string, 2)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return // health check
+		}
+		tokens <- r.Header.Get("Authorization")
+		<-release
+	}))
+	defer server.Close()
+
+	webhookConfig := func(token string) *config.WebhookConfig {
+		return &config.WebhookConfig{URL: server.URL, AuthType: "bearer", Token: token}
+	}
+	client, err := NewClient(&config.Config{Webhook: webhookConfig("old"), LoggerInstance: logger.Discard})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	send := func() *receiptpkg.Receipt {
+		msg := message.New().SetTitle("Alert").SetBody("disk full")
+		msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+		receipt, err := client.Send(context.Background(), msg)
+		if err != nil {
+			t.Errorf("Send() error = %v", err)
+		}
+		return receipt
+	}
+
+	before, _ := client.Health(context.Background())
+
+	// A send in flight during the reload completes on the old credentials
+	inflight := make(chan *receiptpkg.Receipt)
+	go func() { inflight <- send() }()
+	if got := <-tokens; got != "Bearer old" {
+		t.Errorf("in-flight Authorization = %q, want old token", got)
+	}
+	if err := client.ReloadConfig(&config.Config{Webhook: webhookConfig("new"), LoggerInstance: logger.Discard}); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+	close(release)
+	if receipt := <-inflight; receipt.Successful != 1 {
+		t.Errorf("in-flight Send() receipt = %+v, want delivered", receipt)
+	}
+
+	if receipt := send(); receipt.Successful != 1 {
+		t.Errorf("Send() after reload receipt = %+v, want delivered", receipt)
+	}
+	if got := <-tokens; got != "Bearer new" {
+		t.Errorf("Authorization after reload = %q, want new token", got)
+	}
+
+	after, _ := client.Health(context.Background())
+	if before.ConfigVersion == "" || after.ConfigVersion == before.ConfigVersion {
+		t.Errorf("ConfigVersion = %q -> %q, want a new version after reload", before.ConfigVersion, after.ConfigVersion)
+	}
+
+	invalid := &config.Config{Email: &config.EmailConfig{Host: "smtp.example.com"}, LoggerInstance: logger.Discard}
+	if err := client.ReloadConfig(invalid); err == nil {
+		t.Error("ReloadConfig() expected error for invalid configuration")
+	}
+	if current, _ := client.Health(context.Background()); current.ConfigVersion != after.ConfigVersion {
+		t.Errorf("ConfigVersion = %q after rejected reload, want %q", current.ConfigVersion, after.ConfigVersion)
+	}
+}
+
+func TestClientImpl_SecretRotation(t *testing.T) {
+	tokens := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return // health check
+		}
+		tokens <- r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "webhook_token")
+	if err := os.WriteFile(tokenFile, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClientFromOptions(
+		config.WithWebhook(config.WebhookConfig{URL: server.URL, AuthType: "bearer", Token: "file://" + tokenFile}),
+		config.WithSecrets(secret.NewResolver(), 10*time.Millisecond),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	send := func() string {
+		msg := message.New().SetTitle("Alert").SetBody("disk full")
+		msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+		if _, err := client.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return <-tokens
+	}
+
+	if got := send(); got != "Bearer first" {
+		t.Errorf("Authorization = %q, want resolved secret", got)
+	}
+	before, _ := client.Health(context.Background())
+
+	if err := os.WriteFile(tokenFile, []byte("second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if health, _ := client.Health(context.Background()); health.ConfigVersion != before.ConfigVersion {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := send(); got != "Bearer second" {
+		t.Errorf("Authorization after rotation = %q, want rotated secret", got)
+	}
+}
+
+func TestClientImpl_SendHeldForDeliveryWindow(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	now := time.Now().In(shanghai)
+	window := preference.DeliveryWindow{
+		Start: now.Add(2 * time.Hour).Format("15:04"),
+		End:   now.Add(3 * time.Hour).Format("15:04"),
+	}
+
+	client, err := NewClientFromOptions(
+		config.WithQuickEmail("smtp.example.com", 587, "sender@example.com"),
+		config.WithDeliveryWindow(window),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	msg := message.New().SetTitle("Weekly report").SetBody("details").SetDeferrable(true)
+	msg.Targets = []target.Target{target.NewEmail("alice@example.com").WithTimezone("Asia/Shanghai")}
+
+	receipt, err := client.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if receipt.Status != receiptpkg.StatusHeld || receipt.Held != 1 || receipt.Failed != 0 {
+		t.Fatalf("Send() receipt = %+v, want one held target", receipt)
+	}
+	until := receipt.Results[0].HeldUntil
+	if until == nil || until.Sub(now) < 119*time.Minute || until.Sub(now) > 2*time.Hour {
+		t.Errorf("HeldUntil = %v, want about two hours from now", until)
+	}
+
+	health, _ := client.Health(context.Background())
+	if held := health.Metadata["held_deliveries"]; held != 1 {
+		t.Errorf("held_deliveries = %v, want 1", held)
+	}
+}
+
+func TestHoldQueue_Release(t *testing.T) {
+	released := make(chan string, 2)
+	q := newHoldQueue(func(d heldDelivery) { released <- d.msg.ID })
+
+	now := time.Now()
+	later := message.New()
+	sooner := message.New()
+	q.Add(heldDelivery{msg: later, until: now.Add(40 * time.Millisecond)})
+	q.Add(heldDelivery{msg: sooner, until: now.Add(10 * time.Millisecond)})
+	q.Add(heldDelivery{msg: message.New(), until: now.Add(time.Hour)})
+
+	for _, want := range []string{sooner.ID, later.ID} {
+		select {
+		case got := <-released:
+			if got != want {
+				t.Errorf("released %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("held delivery was not released")
+		}
+	}
+	if remaining := q.Close(); remaining != 1 {
+		t.Errorf("Close() = %d, want 1 still held", remaining)
+	}
+}
+
+func TestClientImpl_SendQuarantine(t *testing.T) {
+	var requests atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusNotFound)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	store := quarantine.NewMemoryStore()
+	recorder := audit.NewMemoryRecorder(0)
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook(server.URL),
+		config.WithQuarantine(store, 2),
+		config.WithAuditRecorder(recorder),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	send := func() *receiptpkg.Receipt {
+		t.Helper()
+		msg := message.New().SetTitle("Alert").SetBody("disk full")
+		msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+		receipt, err := client.Send(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return receipt
+	}
+
+	steps := []struct {
+		name         string
+		status       int
+		wantStatus   string
+		wantFailed   int
+		wantRequests int32
+	}{
+		{"transient failure is not counted", http.StatusServiceUnavailable, "", 1, 1},
+		{"first hard failure", http.StatusNotFound, "", 1, 2},
+		{"second hard failure quarantines", http.StatusNotFound, "", 1, 3},
+		{"quarantined target is skipped", http.StatusOK, receiptpkg.ResultQuarantined, 0, 3},
+	}
+
+	for _, step := range steps {
+		status.Store(int32(step.status))
+		receipt := send()
+		if len(receipt.Results) != 1 || receipt.Results[0].Status != step.wantStatus || receipt.Failed != step.wantFailed {
+			t.Errorf("%s: receipt = %+v, want status %q and %d failed", step.name, receipt, step.wantStatus, step.wantFailed)
+		}
+		if requests.Load() != step.wantRequests {
+			t.Errorf("%s: webhook requests = %d, want %d", step.name, requests.Load(), step.wantRequests)
+		}
+	}
+
+	entries := recorder.Entries()
+	if len(entries) != 1 || entries[0].Action != audit.ActionTargetQuarantined {
+		t.Errorf("audit entries = %+v, want one quarantine entry", entries)
+	}
+
+	if err := store.Reinstate(context.Background(), "webhook", server.URL); err != nil {
+		t.Fatalf("Reinstate() error = %v", err)
+	}
+	if receipt := send(); receipt.Successful != 1 {
+		t.Errorf("Send() after Reinstate() receipt = %+v, want delivered", receipt)
+	}
+}
+
+func TestClientImpl_SendDisabledPlatform(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			requests.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	static := flags.NewStatic()
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook(server.URL),
+		config.WithFlags(static),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	send := func() *receiptpkg.Receipt {
+		t.Helper()
+		msg := message.New().SetTitle("Alert").SetBody("disk full")
+		msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+		receipt, err := client.Send(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return receipt
+	}
+
+	static.Disable("webhook", "endpoint returns 500s")
+	receipt := send()
+	if receipt.Status != receiptpkg.StatusSkipped || receipt.Results[0].Status != receiptpkg.ResultDisabled {
+		t.Errorf("Send() to a disabled platform receipt = %+v, want skipped", receipt)
+	}
+	if receipt.Results[0].Error != "platform webhook disabled: endpoint returns 500s" {
+		t.Errorf("result error = %q", receipt.Results[0].Error)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("webhook requests = %d, want 0", requests.Load())
+	}
+
+	static.Enable("webhook")
+	if receipt := send(); receipt.Successful != 1 || requests.Load() != 1 {
+		t.Errorf("Send() after Enable() receipt = %+v, want delivered", receipt)
+	}
+}
+
+func TestClientImpl_SendDefaults(t *testing.T) {
+	var requests atomic.Int32
+	var failures atomic.Int32
+	var payload atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		requests.Add(1)
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		payload.Store(body)
+	}))
+	defer server.Close()
+
+	high := message.PriorityHigh
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook(server.URL),
+		config.WithQuickEmail("smtp.example.com", 587, "sender@example.com"),
+		config.WithSendDefaults(config.SendDefaults{
+			Priority:   &high,
+			Format:     message.FormatMarkdown,
+			MaxRetries: 2,
+			Platforms:  []string{"slack", "webhook"},
+		}),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		name         string
+		build        func(msg *message.Message)
+		failures     int32
+		wantSuccess  bool
+		wantRequests int32
+		wantPriority float64
+		wantFormat   string
+	}{
+		{"defaults fill unset fields", func(msg *message.Message) { msg.Format = "" }, 0, true, 1, 2, "markdown"},
+		{"message settings win", func(msg *message.Message) { msg.SetPriority(message.PriorityUrgent) }, 0, true, 1, 3, "text"},
+		{"transient failures are retried", func(msg *message.Message) {}, 2, true, 3, 2, "text"},
+		{"retries exhausted", func(msg *message.Message) {}, 3, false, 3, 0, ""},
+		{"message disables retries", func(msg *message.Message) { msg.SetMaxRetries(0) }, 1, false, 1, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			failures.Store(tt.failures)
+			payload.Store(map[string]interface{}{})
+
+			msg := message.New().SetTitle("Alert").SetBody("disk full")
+			msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+			tt.build(msg)
+
+			receipt, err := client.Send(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if len(receipt.Results) != 1 || receipt.Results[0].Platform != "webhook" || receipt.Results[0].Success != tt.wantSuccess {
+				t.Fatalf("Send() receipt = %+v, want webhook success %v", receipt, tt.wantSuccess)
+			}
+			if requests.Load() != tt.wantRequests {
+				t.Errorf("webhook requests = %d, want %d", requests.Load(), tt.wantRequests)
+			}
+			body := payload.Load().(map[string]interface{})
+			if tt.wantSuccess && (body["priority"] != tt.wantPriority || body["format"] != tt.wantFormat) {
+				t.Errorf("payload priority = %v, format = %v, want %v and %v", body["priority"], body["format"], tt.wantPriority, tt.wantFormat)
+			}
+			if msg.Priority == high && tt.wantPriority != 2 {
+				t.Error("Send() modified the caller's message")
+			}
+		})
+	}
+
+	// User targets go to the first configured platform of the default
+	// order, or of the message's own order
+	for _, order := range [][]string{nil, {"email"}} {
+		msg := message.New().SetTitle("Alert").SetBody("disk full")
+		msg.Targets = []target.Target{target.New(target.TargetTypeUser, "alice", "")}
+		want := "webhook"
+		if order != nil {
+			msg.SetPlatformOrder(order...)
+			want = order[0]
+		}
+		receipt, err := client.Send(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if len(receipt.Results) != 1 || receipt.Results[0].Platform != want {
+			t.Errorf("Send() with platform order %v receipt = %+v, want platform %s", order, receipt, want)
+		}
+	}
+}
+
+// recordingPlatform is an external platform that records the targets it
+// sends to. With a release channel, sends wait until it is closed.
+type recordingPlatform struct {
+	name     string
+	sent     chan string
+	messages chan *message.Message // optional, receives the message of each target
+	release  chan struct{}
+	closed   atomic.Bool
+}
+
+func (p *recordingPlatform) Name() string { return p.name }
+
+func (p *recordingPlatform) GetCapabilities() platform.Capabilities {
+	return platform.Capabilities{Name: p.name, SupportedTargetTypes: []string{"phone"}}
+}
+
+func (p *recordingPlatform) Send(_ context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	results := make([]*platform.SendResult, 0, len(targets))
+	for _, tgt := range targets {
+		p.sent <- p.name + ":" + tgt.Value
+		if p.messages != nil {
+			p.messages <- msg
+		}
+		if p.release != nil {
+			<-p.release
+		}
+		results = append(results, &platform.SendResult{Target: tgt, Success: true})
+	}
+	return results, nil
+}
+
+func (p *recordingPlatform) ValidateTarget(target.Target) error { return nil }
+func (p *recordingPlatform) IsHealthy(context.Context) error    { return nil }
+func (p *recordingPlatform) Close() error                       { p.closed.Store(true); return nil }
+
+func TestClientImpl_RegisterPlatform(t *testing.T) {
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook("https://hooks.example.com/notify"),
+		config.WithExternalPlatforms("sms"),
+		config.WithTargetRateLimit(ratelimit.Limit{Platform: "sms", Max: 10, Per: time.Hour}),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	sent := make(chan string, 4)
+	factory := func(cfg interface{}) (platform.Platform, error) {
+		sender, ok := cfg.(string)
+		if !ok || sender == "" {
+			return nil, fmt.Errorf("sender ID required")
+		}
+		return &recordingPlatform{name: sender, sent: sent}, nil
+	}
+
+	send := func() *receiptpkg.Receipt {
+		t.Helper()
+		msg := message.New().SetTitle("Code").SetBody("123456")
+		msg.Targets = []target.Target{{Type: "phone", Value: "+8613800138000"}}
+		receipt, err := client.Send(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return receipt
+	}
+
+	registerErrors := []struct {
+		name    string
+		factory platform.Factory
+	}{
+		{"", factory},
+		{"sms", nil},
+		{"email", factory},
+	}
+	for _, tt := range registerErrors {
+		if err := client.RegisterPlatform(tt.name, tt.factory); err == nil {
+			t.Errorf("RegisterPlatform(%q) error = nil", tt.name)
+		}
+	}
+
+	if err := client.RegisterPlatform("sms", factory); err != nil {
+		t.Fatalf("RegisterPlatform() error = %v", err)
+	}
+	if err := client.RegisterPlatform("sms", factory); err == nil {
+		t.Error("RegisterPlatform() twice error = nil")
+	}
+	if receipt := send(); receipt.Failed != 1 {
+		t.Errorf("Send() before SetPlatformConfig() receipt = %+v, want failed", receipt)
+	}
+
+	if err := client.SetPlatformConfig("pager", "x"); err == nil {
+		t.Error("SetPlatformConfig() of an unregistered platform error = nil")
+	}
+	if err := client.SetPlatformConfig("sms", ""); err == nil {
+		t.Error("SetPlatformConfig() with a configuration the factory rejects error = nil")
+	}
+	if err := client.SetPlatformConfig("sms", "NotifyHub"); err != nil {
+		t.Fatalf("SetPlatformConfig() error = %v", err)
+	}
+	if receipt := send(); receipt.Successful != 1 || <-sent != "NotifyHub:+8613800138000" {
+		t.Errorf("Send() receipt = %+v, want delivered through the external platform", receipt)
+	}
+
+	// External platforms survive a reload of the built-in platforms
+	reloaded, _ := config.New(config.WithQuickWebhook("https://hooks.example.com/v2"), config.WithLogger(logger.Discard))
+	if err := client.ReloadConfig(reloaded); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+	if receipt := send(); receipt.Successful != 1 || <-sent != "NotifyHub:+8613800138000" {
+		t.Errorf("Send() after ReloadConfig() receipt = %+v, want delivered through the external platform", receipt)
+	}
+}
+
+func TestClientImpl_ReplacePlatform(t *testing.T) {
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook("https://hooks.example.com/notify"),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	sent := make(chan string, 4)
+	release := make(chan struct{})
+	blue := &recordingPlatform{name: "blue", sent: sent, release: release}
+	green := &recordingPlatform{name: "green", sent: sent}
+	fixed := func(p platform.Platform) platform.Factory {
+		return func(interface{}) (platform.Platform, error) { return p, nil }
+	}
+
+	send := func() *receiptpkg.Receipt {
+		msg := message.New().SetTitle("Code").SetBody("123456")
+		msg.Targets = []target.Target{{Type: "phone", Value: "+8613800138000"}}
+		receipt, _ := client.Send(context.Background(), msg)
+		return receipt
+	}
+
+	if err := client.ReplacePlatform("sms", fixed(green), "green"); err == nil {
+		t.Error("ReplacePlatform() of an unregistered platform error = nil")
+	}
+	if err := client.RegisterPlatform("sms", fixed(blue)); err != nil {
+		t.Fatalf("RegisterPlatform() error = %v", err)
+	}
+	if err := client.SetPlatformConfig("sms", "blue"); err != nil {
+		t.Fatalf("SetPlatformConfig() error = %v", err)
+	}
+
+	// A send in flight on the blue platform keeps it open across the swap
+	inflight := make(chan *receiptpkg.Receipt, 1)
+	go func() { inflight <- send() }()
+	if got := <-sent; got != "blue:+8613800138000" {
+		t.Fatalf("sent %s, want through the blue platform", got)
+	}
+	if err := client.ReplacePlatform("sms", fixed(green), "green"); err != nil {
+		t.Fatalf("ReplacePlatform() error = %v", err)
+	}
+	if receipt := send(); receipt.Successful != 1 || <-sent != "green:+8613800138000" {
+		t.Errorf("Send() after ReplacePlatform() receipt = %+v, want delivered through the green platform", receipt)
+	}
+	if blue.closed.Load() {
+		t.Error("blue platform closed while a send was in flight")
+	}
+
+	close(release)
+	if receipt := <-inflight; receipt.Successful != 1 {
+		t.Errorf("in-flight Send() receipt = %+v, want delivered", receipt)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !blue.closed.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !blue.closed.Load() {
+		t.Error("blue platform not closed after its sends completed")
+	}
+
+	if err := client.UnregisterPlatform("sms"); err != nil {
+		t.Fatalf("UnregisterPlatform() error = %v", err)
+	}
+	if receipt := send(); receipt.Successful != 0 {
+		t.Errorf("Send() after UnregisterPlatform() receipt = %+v, want not delivered", receipt)
+	}
+	if err := client.UnregisterPlatform("sms"); err == nil {
+		t.Error("UnregisterPlatform() twice error = nil")
+	}
+
+	if err := client.UnregisterPlatform("webhook"); err != nil {
+		t.Fatalf("UnregisterPlatform(webhook) error = %v", err)
+	}
+	msg := message.New().SetTitle("Alert").SetBody("disk full")
+	msg.Targets = []target.Target{target.NewWebhook("https://hooks.example.com/notify")}
+	if receipt, _ := client.Send(context.Background(), msg); receipt.Failed != 1 {
+		t.Errorf("Send() to an unregistered built-in platform receipt = %+v, want failed", receipt)
+	}
+}
+
+func TestClientImpl_SendRotatesCredentials(t *testing.T) {
+	var mu sync.Mutex
+	used := make(map[string]int)
+	valid := map[string]bool{"Bearer standby": true, "Bearer rotated": true}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return // health check
+		}
+		token := r.Header.Get("Authorization")
+		mu.Lock()
+		used[token]++
+		mu.Unlock()
+		if !valid[token] {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	send := func(client Client) (*receiptpkg.Receipt, map[string]int) {
+		mu.Lock()
+		used = make(map[string]int)
+		mu.Unlock()
+		msg := message.New().SetTitle("Alert").SetBody("disk full")
+		msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+		receipt, _ := client.Send(context.Background(), msg)
+		mu.Lock()
+		defer mu.Unlock()
+		return receipt, used
+	}
+
+	standby := config.Credential{Name: "standby", Settings: map[string]string{"token": "standby"}}
+	tests := []struct {
+		name        string
+		credentials []config.Credential
+		first, next []string // tokens used by two consecutive sends
+	}{
+		{
+			name:        "switch to the standby when the primary is rejected",
+			credentials: []config.Credential{standby},
+			first:       []string{"Bearer expired", "Bearer standby"},
+			next:        []string{"Bearer standby"},
+		},
+		{
+			name: "prefer credentials once their window starts",
+			credentials: []config.Credential{
+				standby,
+				{Name: "rotated", Settings: map[string]string{"token": "rotated"}, ActiveFrom: time.Now().Add(-time.Hour)},
+				{Name: "scheduled", Settings: map[string]string{"token": "scheduled"}, ActiveFrom: time.Now().Add(time.Hour)},
+			},
+			first: []string{"Bearer rotated"},
+			next:  []string{"Bearer rotated"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClientFromOptions(
+				config.WithWebhook(config.WebhookConfig{URL: server.URL, AuthType: "bearer", Token: "expired", MaxRetries: 1}),
+				config.WithCredentials("webhook", tt.credentials...),
+				config.WithLogger(logger.Discard),
+			)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+
+			for i, want := range [][]string{tt.first, tt.next} {
+				receipt, used := send(client)
+				if receipt.Successful != 1 {
+					t.Errorf("send %d receipt = %+v, want delivered", i+1, receipt)
+				}
+				var tokens []string
+				for token := range used {
+					tokens = append(tokens, token)
+				}
+				sort.Strings(tokens)
+				if !reflect.DeepEqual(tokens, want) {
+					t.Errorf("send %d used %v, want %v", i+1, tokens, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClientImpl_SendThroughProxy(t *testing.T) {
+	proxied := make(chan string, 4)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return // health check
+		}
+		proxied <- r.Host + " " + r.Header.Get("Proxy-Authorization")
+	}))
+	defer proxy.Close()
+
+	direct := make(chan struct{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			direct <- struct{}{}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook("http://hooks.example.invalid/notify"),
+		config.WithProxy(config.ProxyConfig{URL: proxy.URL, Username: "svc", Password: "secret", NoProxy: []string{"127.0.0.1"}}),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	send := func(url string) *receiptpkg.Receipt {
+		msg := message.New().SetTitle("Alert").SetBody("disk full")
+		msg.Targets = []target.Target{target.NewWebhook(url)}
+		receipt, _ := client.Send(context.Background(), msg)
+		return receipt
+	}
+
+	if receipt := send("http://hooks.example.invalid/notify"); receipt.Successful != 1 {
+		t.Fatalf("Send() receipt = %+v, want delivered through the proxy", receipt)
+	}
+	if got := <-proxied; got != "hooks.example.invalid Basic c3ZjOnNlY3JldA==" {
+		t.Errorf("proxy received %q", got)
+	}
+
+	if receipt := send(server.URL); receipt.Successful != 1 || len(direct) != 1 || len(proxied) != 0 {
+		t.Errorf("Send() to a no_proxy host receipt = %+v, direct %d, proxied %d", receipt, len(direct), len(proxied))
+	}
+}
+
+func TestClientImpl_SendTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	tests := []struct {
+		name            string
+		platformTimeout time.Duration
+		defaults        time.Duration
+		message         time.Duration
+		deadline        time.Duration
+		want            time.Duration
+	}{
+		{"global", 0, 0, 0, 0, 45 * time.Second},
+		{"send defaults", 0, 20 * time.Second, 0, 0, 20 * time.Second},
+		{"platform", 10 * time.Second, 20 * time.Second, 0, 0, 10 * time.Second},
+		{"message", 10 * time.Second, 20 * time.Second, 5 * time.Second, 0, 5 * time.Second},
+		{"context deadline", 10 * time.Second, 20 * time.Second, 5 * time.Second, time.Minute, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClientFromOptions(
+				config.WithWebhook(config.WebhookConfig{URL: server.URL, Method: http.MethodPost, Timeout: tt.platformTimeout}),
+				config.WithTimeout(45*time.Second),
+				config.WithSendDefaults(config.SendDefaults{Timeout: tt.defaults}),
+				config.WithLogger(logger.Discard),
+			)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+
+			msg := message.New().SetTitle("Alert").SetBody("disk full")
+			if tt.message > 0 {
+				msg.SetTimeout(tt.message)
+			}
+			msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+			receipt, err := client.Send(ctx, msg)
+			if err != nil || len(receipt.Results) != 1 || !receipt.Results[0].Success {
+				t.Fatalf("Send() = %+v, %v", receipt, err)
+			}
+			if got := receipt.Results[0].Timeout; got > tt.want || got < tt.want-time.Second {
+				t.Errorf("effective timeout = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientImpl_Use(t *testing.T) {
+	titles := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Title string `json:"title"`
+		}
+		if r.Method != http.MethodHead && json.NewDecoder(r.Body).Decode(&payload) == nil {
+			titles <- payload.Title
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClientFromOptions(config.WithQuickWebhook(server.URL), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) Middleware {
+		return func(ctx context.Context, msg *message.Message, next SendFunc) (*receiptpkg.Receipt, error) {
+			mu.Lock()
+			calls = append(calls, name+">")
+			mu.Unlock()
+			receipt, err := next(ctx, msg)
+			mu.Lock()
+			calls = append(calls, "<"+name)
+			mu.Unlock()
+			return receipt, err
+		}
+	}
+	errQuota := fmt.Errorf("tenant quota exceeded")
+	client.Use(record("outer"), func(ctx context.Context, msg *message.Message, next SendFunc) (*receiptpkg.Receipt, error) {
+		if msg.Metadata["tenant"] == "over-quota" {
+			return nil, errQuota
+		}
+		enriched := *msg
+		enriched.Title = "[prod] " + msg.Title
+		return next(ctx, &enriched)
+	})
+	client.Use(record("inner"))
+
+	newMessage := func(tenant string) *message.Message {
+		msg := message.New().SetTitle("Alert").SetBody("disk full").SetMetadata("tenant", tenant)
+		msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+		return msg
+	}
+
+	receipt, err := client.Send(context.Background(), newMessage("acme"))
+	if err != nil || receipt.Successful != 1 {
+		t.Fatalf("Send() = %+v, %v", receipt, err)
+	}
+	if got := <-titles; got != "[prod] Alert" {
+		t.Errorf("delivered title = %q, want the enriched title", got)
+	}
+	if want := []string{"outer>", "inner>", "<inner", "<outer"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("middleware calls = %v, want %v", calls, want)
+	}
+
+	if _, err := client.Send(context.Background(), newMessage("over-quota")); err != errQuota {
+		t.Errorf("Send() over quota error = %v, want %v", err, errQuota)
+	}
+
+	handle, err := client.SendAsync(context.Background(), newMessage("acme"))
+	if err != nil {
+		t.Fatalf("SendAsync() error = %v", err)
+	}
+	if receipt, err := handle.Wait(context.Background()); err != nil || receipt.Successful != 1 {
+		t.Fatalf("Wait() = %+v, %v", receipt, err)
+	}
+	if got := <-titles; got != "[prod] Alert" {
+		t.Errorf("delivered async title = %q, want the enriched title", got)
+	}
+	if len(titles) != 0 {
+		t.Errorf("the rejected send was delivered")
+	}
+}
+
+func TestClientImpl_DryRun(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			requests.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		dryRun       []string
+		wantRequests int32
+		wantSMS      int
+		wantDryRun   map[string]bool
+	}{
+		{name: "off", dryRun: nil, wantRequests: 1, wantSMS: 1, wantDryRun: map[string]bool{"webhook": false, "sms": false}},
+		{name: "every platform", dryRun: []string{}, wantRequests: 0, wantSMS: 0, wantDryRun: map[string]bool{"webhook": true, "sms": true}},
+		{name: "external platform", dryRun: []string{"sms"}, wantRequests: 1, wantSMS: 0, wantDryRun: map[string]bool{"webhook": false, "sms": true}},
+		{name: "webhook", dryRun: []string{"webhook"}, wantRequests: 0, wantSMS: 1, wantDryRun: map[string]bool{"webhook": true, "sms": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			opts := []config.Option{
+				config.WithQuickWebhook(server.URL),
+				config.WithExternalPlatforms("sms"),
+				config.WithLogger(logger.Discard),
+			}
+			if tt.dryRun != nil {
+				opts = append(opts, config.WithDryRun(tt.dryRun...))
+			}
+			client, err := NewClientFromOptions(opts...)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+			sent := make(chan string, 4)
+			if err := client.RegisterPlatform("sms", func(interface{}) (platform.Platform, error) {
+				return &recordingPlatform{name: "sms", sent: sent}, nil
+			}); err != nil {
+				t.Fatalf("RegisterPlatform() error = %v", err)
+			}
+			if err := client.SetPlatformConfig("sms", "sender"); err != nil {
+				t.Fatalf("SetPlatformConfig() error = %v", err)
+			}
+
+			msg := message.New()
+			msg.Title = "Deploy"
+			msg.Targets = []target.Target{target.NewWebhook(server.URL), target.New(target.TargetTypePhone, "+8613800138000", "sms")}
+			receipt, err := client.Send(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if receipt.Status != receiptpkg.StatusSuccess || receipt.Successful != 2 {
+				t.Errorf("Send() receipt = %+v, want both delivered", receipt)
+			}
+			for _, result := range receipt.Results {
+				if result.DryRun != tt.wantDryRun[result.Platform] {
+					t.Errorf("result %s DryRun = %v, want %v", result.Platform, result.DryRun, tt.wantDryRun[result.Platform])
+				}
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("webhook requests = %d, want %d", got, tt.wantRequests)
+			}
+			if len(sent) != tt.wantSMS {
+				t.Errorf("sms sends = %d, want %d", len(sent), tt.wantSMS)
+			}
+		})
+	}
+}
+
+func TestClientImpl_SendWithPlatforms(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			requests.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook(server.URL),
+		config.WithExternalPlatforms("sms"),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	sent := make(chan string, 8)
+	if err := client.RegisterPlatform("sms", func(interface{}) (platform.Platform, error) {
+		return &recordingPlatform{name: "sms", sent: sent}, nil
+	}); err != nil {
+		t.Fatalf("RegisterPlatform() error = %v", err)
+	}
+	if err := client.SetPlatformConfig("sms", "sender"); err != nil {
+		t.Fatalf("SetPlatformConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		opts         []SendOption
+		targets      []target.Target
+		wantRequests int32
+		wantSMS      []string
+		wantStatus   map[string]string // result status by platform
+	}{
+		{
+			name:         "unrestricted",
+			targets:      []target.Target{target.NewWebhook(server.URL), target.New(target.TargetTypePhone, "+8613800138000", "sms")},
+			wantRequests: 1,
+			wantSMS:      []string{"sms:+8613800138000"},
+			wantStatus:   map[string]string{"webhook": "", "sms": ""},
+		},
+		{
+			name:         "webhook excluded",
+			opts:         []SendOption{WithPlatforms("email", "sms")},
+			targets:      []target.Target{target.NewWebhook(server.URL), target.New(target.TargetTypePhone, "+8613800138000", "sms")},
+			wantRequests: 0,
+			wantSMS:      []string{"sms:+8613800138000"},
+			wantStatus:   map[string]string{"webhook": receiptpkg.ResultExcluded, "sms": ""},
+		},
+		{
+			name:         "every target excluded",
+			opts:         []SendOption{WithPlatforms("email")},
+			targets:      []target.Target{target.NewWebhook(server.URL), target.New(target.TargetTypePhone, "+8613800138000", "sms")},
+			wantRequests: 0,
+			wantStatus:   map[string]string{"webhook": receiptpkg.ResultExcluded, "sms": receiptpkg.ResultExcluded},
+		},
+		{
+			name:       "routed to an allowed platform",
+			opts:       []SendOption{WithPlatforms("sms")},
+			targets:    []target.Target{target.New(target.TargetTypeUser, "oncall", "")},
+			wantSMS:    []string{"sms:oncall"},
+			wantStatus: map[string]string{"sms": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			msg := message.New()
+			msg.Title, msg.Targets = "Audit", tt.targets
+			receipt, err := client.Send(context.Background(), msg, tt.opts...)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if len(receipt.Results) != len(tt.wantStatus) {
+				t.Fatalf("Send() results = %+v, want %d", receipt.Results, len(tt.wantStatus))
+			}
+			for _, result := range receipt.Results {
+				want, ok := tt.wantStatus[result.Platform]
+				if !ok || result.Status != want {
+					t.Errorf("result %s status = %q, want %q", result.Platform, result.Status, want)
+				}
+				if want == "" && !result.Success {
+					t.Errorf("result %s = %+v, want delivered", result.Platform, result)
+				}
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("webhook requests = %d, want %d", got, tt.wantRequests)
+			}
+			var gotSMS []string
+			for len(sent) > 0 {
+				gotSMS = append(gotSMS, <-sent)
+			}
+			if strings.Join(gotSMS, ",") != strings.Join(tt.wantSMS, ",") {
+				t.Errorf("sms sends = %v, want %v", gotSMS, tt.wantSMS)
+			}
+			if msg.Platforms() != nil {
+				t.Errorf("Send() modified the message metadata: %v", msg.Metadata)
+			}
+		})
+	}
+}
+
+func TestClientImpl_AddEnricher(t *testing.T) {
+	banner := func(_ context.Context, _ string, msg *message.Message) error {
+		msg.Title = "[staging] " + msg.Title
+		return nil
+	}
+	traceLink := func(_ context.Context, platform string, msg *message.Message) error {
+		msg.Body += "\ntrace: https://trace.example.com/" + msg.ID
+		msg.SetMetadata("enriched_for", platform)
+		return nil
+	}
+	failing := func(context.Context, string, *message.Message) error {
+		return fmt.Errorf("trace service down")
+	}
+
+	tests := []struct {
+		name       string
+		enrichers  map[string][]Enricher
+		wantTitles map[string]string
+		wantBodies map[string]string
+		wantFailed string // platform whose delivery fails
+	}{
+		{
+			name:       "none",
+			wantTitles: map[string]string{"sms": "Deploy", "chat": "Deploy"},
+			wantBodies: map[string]string{"sms": "v2 rolled out", "chat": "v2 rolled out"},
+		},
+		{
+			name:       "global and per platform",
+			enrichers:  map[string][]Enricher{"": {banner}, "sms": {traceLink}},
+			wantTitles: map[string]string{"sms": "[staging] Deploy", "chat": "[staging] Deploy"},
+			wantBodies: map[string]string{"sms": "v2 rolled out\ntrace: https://trace.example.com/m1", "chat": "v2 rolled out"},
+		},
+		{
+			name:       "failing enricher",
+			enrichers:  map[string][]Enricher{"": {banner}, "chat": {failing}},
+			wantTitles: map[string]string{"sms": "[staging] Deploy"},
+			wantBodies: map[string]string{"sms": "v2 rolled out"},
+			wantFailed: "chat",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClientFromOptions(config.WithExternalPlatforms("sms", "chat"), config.WithLogger(logger.Discard))
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+			sent := make(chan string, 4)
+			messages := map[string]chan *message.Message{"sms": make(chan *message.Message, 2), "chat": make(chan *message.Message, 2)}
+			for name, ch := range messages {
+				name, ch := name, ch
+				if err := client.RegisterPlatform(name, func(interface{}) (platform.Platform, error) {
+					return &recordingPlatform{name: name, sent: sent, messages: ch}, nil
+				}); err != nil {
+					t.Fatalf("RegisterPlatform(%s) error = %v", name, err)
+				}
+				if err := client.SetPlatformConfig(name, "sender"); err != nil {
+					t.Fatalf("SetPlatformConfig(%s) error = %v", name, err)
+				}
+			}
+			for platform, enrichers := range tt.enrichers {
+				client.AddEnricher(platform, enrichers...)
+			}
+
+			msg := message.New().SetTitle("Deploy").SetBody("v2 rolled out")
+			msg.ID = "m1"
+			msg.Targets = []target.Target{target.New(target.TargetTypePhone, "+8613800138000", "sms"), target.New(target.TargetTypeUser, "ops", "chat")}
+			receipt, err := client.Send(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			for _, result := range receipt.Results {
+				if failed := result.Platform == tt.wantFailed; result.Success == failed {
+					t.Errorf("result %s = %+v, want failed %v", result.Platform, result, failed)
+				}
+			}
+			for name, ch := range messages {
+				if len(ch) == 0 {
+					if _, ok := tt.wantTitles[name]; ok {
+						t.Errorf("%s received no message", name)
+					}
+					continue
+				}
+				got := <-ch
+				if got.Title != tt.wantTitles[name] || got.Body != tt.wantBodies[name] {
+					t.Errorf("%s received %q / %q, want %q / %q", name, got.Title, got.Body, tt.wantTitles[name], tt.wantBodies[name])
+				}
+			}
+			if msg.Title != "Deploy" || msg.Body != "v2 rolled out" || msg.Metadata["enriched_for"] != nil {
+				t.Errorf("Send() modified the message: %+v", msg)
+			}
+		})
+	}
+}
+
+func TestClientImpl_With(t *testing.T) {
+	client, err := NewClientFromOptions(config.WithExternalPlatforms("chat"), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	sent := make(chan string, 8)
+	messages := make(chan *message.Message, 8)
+	if err := client.RegisterPlatform("chat", func(interface{}) (platform.Platform, error) {
+		return &recordingPlatform{name: "chat", sent: sent, messages: messages}, nil
+	}); err != nil {
+		t.Fatalf("RegisterPlatform() error = %v", err)
+	}
+	if err := client.SetPlatformConfig("chat", "sender"); err != nil {
+		t.Fatalf("SetPlatformConfig() error = %v", err)
+	}
+
+	payments := client.With(
+		DefaultMetadata("team", "payments"),
+		DefaultTags("billing"),
+		DefaultPriority(message.PriorityHigh),
+		DefaultTargetPrefix(target.TargetTypeChannel, "payments-"),
+	)
+	refunds := payments.With(DefaultTags("refunds"))
+
+	tests := []struct {
+		name         string
+		client       Client
+		msg          func() *message.Message
+		wantTeam     interface{}
+		wantTags     []string
+		wantPriority message.Priority
+		wantTarget   string
+	}{
+		{
+			name:         "parent",
+			client:       client,
+			msg:          func() *message.Message { return message.New() },
+			wantPriority: message.PriorityNormal,
+			wantTarget:   "alerts",
+		},
+		{
+			name:         "defaults",
+			client:       payments,
+			msg:          func() *message.Message { return message.New() },
+			wantTeam:     "payments",
+			wantTags:     []string{"billing"},
+			wantPriority: message.PriorityHigh,
+			wantTarget:   "payments-alerts",
+		},
+		{
+			name:   "message settings kept",
+			client: payments,
+			msg: func() *message.Message {
+				return message.New().SetMetadata("team", "risk").SetTags("fraud").SetPriority(message.PriorityUrgent)
+			},
+			wantTeam:     "risk",
+			wantTags:     []string{"billing", "fraud"},
+			wantPriority: message.PriorityUrgent,
+			wantTarget:   "payments-alerts",
+		},
+		{
+			name:         "nested",
+			client:       refunds,
+			msg:          func() *message.Message { return message.New() },
+			wantTeam:     "payments",
+			wantTags:     []string{"refunds", "billing"},
+			wantPriority: message.PriorityHigh,
+			wantTarget:   "payments-alerts",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg().SetTitle("Payout failed")
+			msg.Targets = []target.Target{target.New(target.TargetTypeChannel, "alerts", "chat")}
+			if _, err := tt.client.Send(context.Background(), msg); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			got := <-messages
+			<-sent
+			if got.Metadata["team"] != tt.wantTeam || !reflect.DeepEqual(got.Tags(), tt.wantTags) || got.Priority != tt.wantPriority {
+				t.Errorf("sent metadata %v, priority %v, want team %v, tags %v, priority %v", got.Metadata, got.Priority, tt.wantTeam, tt.wantTags, tt.wantPriority)
+			}
+			if len(got.Targets) != 1 || got.Targets[0].Value != tt.wantTarget {
+				t.Errorf("sent to %v, want %q", got.Targets, tt.wantTarget)
+			}
+			if msg.Targets[0].Value != "alerts" {
+				t.Errorf("Send() modified the message targets: %v", msg.Targets)
+			}
+		})
+	}
+
+	for _, c := range []Client{client, payments, refunds} {
+		if _, ok := c.(StreamSender); !ok {
+			t.Errorf("%T does not implement StreamSender", c)
+		}
+		if _, ok := c.(Preflighter); !ok {
+			t.Errorf("%T does not implement Preflighter", c)
+		}
+		if _, ok := c.(UsageReader); !ok {
+			t.Errorf("%T does not implement UsageReader", c)
+		}
+	}
+
+	if err := payments.Close(); err != nil {
+		t.Fatalf("Close() of a child error = %v", err)
+	}
+	if _, err := client.Send(context.Background(), message.New().AddTarget(target.New(target.TargetTypeChannel, "alerts", "chat"))); err != nil {
+		t.Errorf("Send() after closing a child error = %v", err)
+	}
+}
+
+// panickingPlatform panics on every send and health check
+type panickingPlatform struct {
+	recordingPlatform
+	sends atomic.Int32
+}
+
+func (p *panickingPlatform) Send(context.Context, *message.Message, []target.Target) ([]*platform.SendResult, error) {
+	p.sends.Add(1)
+	panic("nil map in custom sender")
+}
+
+func (p *panickingPlatform) IsHealthy(context.Context) error { panic("health check bug") }
+
+func TestClientImpl_SendPlatformPanic(t *testing.T) {
+	client, err := NewClientFromOptions(
+		config.WithExternalPlatforms("broken", "chat"),
+		config.WithSendDefaults(config.SendDefaults{MaxRetries: 2, RetryInterval: time.Millisecond}),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	sent := make(chan string, 4)
+	broken := &panickingPlatform{recordingPlatform: recordingPlatform{name: "broken", sent: sent}}
+	factories := map[string]platform.Factory{
+		"broken": func(interface{}) (platform.Platform, error) { return broken, nil },
+		"chat": func(interface{}) (platform.Platform, error) {
+			return &recordingPlatform{name: "chat", sent: sent}, nil
+		},
+	}
+	for name, factory := range factories {
+		if err := client.RegisterPlatform(name, factory); err != nil {
+			t.Fatalf("RegisterPlatform(%s) error = %v", name, err)
+		}
+		if err := client.SetPlatformConfig(name, "sender"); err != nil {
+			t.Fatalf("SetPlatformConfig(%s) error = %v", name, err)
+		}
+	}
+
+	msg := message.New().SetTitle("Deploy")
+	msg.Targets = []target.Target{target.New(target.TargetTypeUser, "ops", "broken"), target.New(target.TargetTypeUser, "ops", "chat")}
+	receipt, err := client.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	for _, result := range receipt.Results {
+		switch result.Platform {
+		case "broken":
+			if result.Success || !strings.Contains(result.Error, "PLATFORM_PANIC") || !strings.Contains(result.Error, "nil map in custom sender") {
+				t.Errorf("broken result = %+v, want a PLATFORM_PANIC failure", result)
+			}
+		case "chat":
+			if !result.Success {
+				t.Errorf("chat result = %+v, want success", result)
+			}
+		}
+	}
+	if n := broken.sends.Load(); n != 1 {
+		t.Errorf("panicking send called %d times, want no retries", n)
+	}
+
+	handle, err := client.SendAsync(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("SendAsync() error = %v", err)
+	}
+	if receipt, err := handle.Wait(context.Background()); err != nil || receipt.Successful != 1 || receipt.Failed != 1 {
+		t.Fatalf("Wait() = %+v, %v", receipt, err)
+	}
+
+	health, err := client.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+	if health.Metadata["platform_panics"] != int64(2) || !strings.Contains(health.Platforms["broken"], "health check panicked") {
+		t.Errorf("Health() = %+v, want 2 platform panics and broken unhealthy", health)
+	}
+}
+
+// flakyPlatform fails its first sends with err
+type flakyPlatform struct {
+	recordingPlatform
+	failures int32
+	err      error
+	sends    atomic.Int32
+	at       []time.Time
+}
+
+func (p *flakyPlatform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	p.at = append(p.at, time.Now())
+	if p.sends.Add(1) <= p.failures {
+		return []*platform.SendResult{{Target: targets[0], Error: p.err}}, nil
+	}
+	return p.recordingPlatform.Send(ctx, msg, targets)
+}
+
+func TestClientImpl_SendRetryPolicy(t *testing.T) {
+	errThrottled := errors.New("429 too many requests")
+	tests := []struct {
+		name      string
+		options   []config.Option
+		setup     func(*message.Message)
+		err       error
+		wantSends int32
+		wantOK    bool
+	}{
+		{
+			name:      "retried with backoff",
+			options:   []config.Option{config.WithRetry(config.RetryPolicy{MaxAttempts: 3, InitialInterval: 10 * time.Millisecond, BackoffFactor: 3})},
+			err:       errThrottled,
+			wantSends: 3,
+			wantOK:    true,
+		},
+		{
+			name:      "attempts exhausted",
+			options:   []config.Option{config.WithRetry(config.RetryPolicy{MaxAttempts: 2})},
+			err:       errThrottled,
+			wantSends: 2,
+		},
+		{
+			name: "platform override",
+			options: []config.Option{
+				config.WithRetry(config.RetryPolicy{MaxAttempts: 1}),
+				config.WithRetry(config.RetryPolicy{MaxAttempts: 3}, "chat"),
+			},
+			err:       errThrottled,
+			wantSends: 3,
+			wantOK:    true,
+		},
+		{
+			name: "not retryable",
+			options: []config.Option{config.WithRetry(config.RetryPolicy{
+				MaxAttempts:              3,
+				RetryableErrorClassifier: func(err error) bool { return strings.Contains(err.Error(), "429") },
+			})},
+			err:       errors.New("400 bad request"),
+			wantSends: 1,
+		},
+		{
+			name:      "message retries",
+			options:   []config.Option{config.WithRetry(config.RetryPolicy{MaxAttempts: 3})},
+			setup:     func(msg *message.Message) { msg.SetMaxRetries(0) },
+			err:       errThrottled,
+			wantSends: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]config.Option{config.WithExternalPlatforms("chat"), config.WithLogger(logger.Discard)}, tt.options...)
+			client, err := NewClientFromOptions(options...)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+			flaky := &flakyPlatform{recordingPlatform: recordingPlatform{name: "chat", sent: make(chan string, 1)}, failures: 2, err: tt.err}
+			if err := client.RegisterPlatform("chat", func(interface{}) (platform.Platform, error) { return flaky, nil }); err != nil {
+				t.Fatalf("RegisterPlatform() error = %v", err)
+			}
+			if err := client.SetPlatformConfig("chat", "sender"); err != nil {
+				t.Fatalf("SetPlatformConfig() error = %v", err)
+			}
+
+			msg := message.New().SetTitle("Deploy")
+			msg.Targets = []target.Target{target.New(target.TargetTypeUser, "ops", "chat")}
+			if tt.setup != nil {
+				tt.setup(msg)
+			}
+			receipt, err := client.Send(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if got := flaky.sends.Load(); got != tt.wantSends {
+				t.Errorf("sends = %d, want %d", got, tt.wantSends)
+			}
+			if ok := receipt.Successful == 1; ok != tt.wantOK {
+				t.Errorf("receipt = %+v, want success %v", receipt, tt.wantOK)
+			}
+			if tt.name == "retried with backoff" {
+				if first, second := flaky.at[1].Sub(flaky.at[0]), flaky.at[2].Sub(flaky.at[1]); first < 10*time.Millisecond || second < 30*time.Millisecond {
+					t.Errorf("retry delays = %v, %v, want 10ms then 30ms", first, second)
+				}
+			}
+		})
+	}
+}
+
+func TestClientImpl_SendMaxInFlight(t *testing.T) {
+	client, err := NewClientFromOptions(
+		config.WithExternalPlatforms("chat", "sms"),
+		config.WithMaxInFlight("chat", 2),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	sent := make(chan string, 8)
+	release := map[string]chan struct{}{"chat": make(chan struct{}), "sms": make(chan struct{})}
+	for name, ch := range release {
+		name, ch := name, ch
+		if err := client.RegisterPlatform(name, func(interface{}) (platform.Platform, error) {
+			return &recordingPlatform{name: name, sent: sent, release: ch}, nil
+		}); err != nil {
+			t.Fatalf("RegisterPlatform(%s) error = %v", name, err)
+		}
+		if err := client.SetPlatformConfig(name, "sender"); err != nil {
+			t.Fatalf("SetPlatformConfig(%s) error = %v", name, err)
+		}
+	}
+	send := func(ctx context.Context, platformName, user string) (*receiptpkg.Receipt, error) {
+		msg := message.New().SetTitle("Deploy")
+		msg.Targets = []target.Target{target.New(target.TargetTypeUser, user, platformName)}
+		return client.Send(ctx, msg)
+	}
+
+	// Three sends on chat: two start, the third waits for a slot
+	done := make(chan error, 3)
+	for _, user := range []string{"a", "b", "c"} {
+		user := user
+		go func() {
+			_, err := send(context.Background(), "chat", user)
+			done <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		<-sent
+	}
+	select {
+	case got := <-sent:
+		t.Fatalf("send %s started beyond the cap", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Other platforms are not held up by the cap of chat
+	smsDone := make(chan error, 1)
+	go func() {
+		_, err := send(context.Background(), "sms", "d")
+		smsDone <- err
+	}()
+	if got := <-sent; got != "sms:d" {
+		t.Errorf("sent %s, want sms:d", got)
+	}
+	release["sms"] <- struct{}{}
+	if err := <-smsDone; err != nil {
+		t.Errorf("sms Send() error = %v", err)
+	}
+
+	// A send waiting for a slot gives up with its context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	receipt, err := send(ctx, "chat", "e")
+	if err != nil || len(receipt.Results) != 1 || receipt.Results[0].Success || !strings.Contains(receipt.Results[0].Error, "limit of 2 in flight") {
+		t.Errorf("Send() waiting for a slot = %+v, %v", receipt, err)
+	}
+
+	// Releasing a send lets the waiting one start
+	release["chat"] <- struct{}{}
+	if got := <-sent; !strings.HasPrefix(got, "chat:") {
+		t.Errorf("sent %s, want the waiting chat send", got)
+	}
+	release["chat"] <- struct{}{}
+	release["chat"] <- struct{}{}
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("Send() error = %v", err)
+		}
+	}
+}
+
+func TestClientImpl_SendQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		quotas      []ratelimit.Quota
+		tenants     []string
+		wantLimited int
+		wantHeld    int
+		wantWait    time.Duration
+	}{
+		{name: "drop over quota", quotas: []ratelimit.Quota{{Platform: "chat", Rate: 1, Per: time.Hour, Policy: ratelimit.PolicyDrop}}, tenants: []string{"", ""}, wantLimited: 1},
+		{name: "per tenant", quotas: []ratelimit.Quota{{Platform: "chat", Rate: 1, Per: time.Hour, PerTenant: true, Policy: ratelimit.PolicyDrop}}, tenants: []string{"acme", "globex", "acme"}, wantLimited: 1},
+		{name: "other platform", quotas: []ratelimit.Quota{{Platform: "sms", Rate: 1, Per: time.Hour, Policy: ratelimit.PolicyDrop}}, tenants: []string{"", ""}},
+		{name: "wait for quota", quotas: []ratelimit.Quota{{Platform: "chat", Rate: 1, Per: 50 * time.Millisecond}}, tenants: []string{"", ""}, wantWait: 40 * time.Millisecond},
+		{name: "denied sends are refunded to earlier quotas", quotas: []ratelimit.Quota{{Platform: "chat", Rate: 2, Per: time.Hour, Policy: ratelimit.PolicyDrop}, {Platform: "chat", Rate: 1, Per: time.Hour, PerTenant: true, Policy: ratelimit.PolicyDrop}}, tenants: []string{"acme", "acme", "globex", "initech"}, wantLimited: 2},
+		{name: "defer over quota", quotas: []ratelimit.Quota{{Platform: "chat", Rate: 1, Per: 50 * time.Millisecond, Policy: ratelimit.PolicyDefer}}, tenants: []string{"", ""}, wantHeld: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []config.Option{
+				config.WithExternalPlatforms("chat", "sms"),
+				config.WithTokenBuckets(ratelimit.NewMemoryTokenBuckets()),
+				config.WithLogger(logger.Discard),
+			}
+			for _, quota := range tt.quotas {
+				opts = append(opts, config.WithQuota(quota))
+			}
+			client, err := NewClientFromOptions(opts...)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+			sent := make(chan string, len(tt.tenants))
+			if err := client.RegisterPlatform("chat", func(interface{}) (platform.Platform, error) {
+				return &recordingPlatform{name: "chat", sent: sent}, nil
+			}); err != nil {
+				t.Fatalf("RegisterPlatform() error = %v", err)
+			}
+			if err := client.SetPlatformConfig("chat", "sender"); err != nil {
+				t.Fatalf("SetPlatformConfig() error = %v", err)
+			}
+
+			start := time.Now()
+			limited, held := 0, 0
+			for _, tenant := range tt.tenants {
+				msg := message.New().SetTitle("Deploy").SetTenant(tenant)
+				msg.Targets = []target.Target{target.New(target.TargetTypeUser, "ops", "chat")}
+				receipt, err := client.Send(context.Background(), msg)
+				if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+				switch receipt.Results[0].Status {
+				case receiptpkg.ResultRateLimited:
+					limited++
+				case receiptpkg.ResultHeld:
+					held++
+				}
+			}
+			if limited != tt.wantLimited || held != tt.wantHeld || len(sent) != len(tt.tenants)-tt.wantLimited-tt.wantHeld {
+				t.Errorf("rate limited %d, held %d, delivered %d, want %d rate limited and %d held", limited, held, len(sent), tt.wantLimited, tt.wantHeld)
+			}
+			// Deferred sends are delivered once the quota refills
+			deadline := time.Now().Add(time.Second)
+			for len(sent) < len(tt.tenants)-tt.wantLimited && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if len(sent) != len(tt.tenants)-tt.wantLimited {
+				t.Errorf("delivered %d after the quota refilled, want %d", len(sent), len(tt.tenants)-tt.wantLimited)
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantWait {
+				t.Errorf("sends took %s, want at least %s", elapsed, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestClientImpl_SendContextValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctx        sendctx.Values
+		msgTenant  string
+		wantValues sendctx.Values
+	}{
+		{name: "no values", wantValues: sendctx.Values{}},
+		{name: "context values", ctx: sendctx.Values{RequestID: "req-1", Tenant: "acme", User: "alice"}, wantValues: sendctx.Values{RequestID: "req-1", Tenant: "acme", User: "alice"}},
+		{name: "message values take precedence", ctx: sendctx.Values{RequestID: "req-2", Tenant: "acme"}, msgTenant: "globex", wantValues: sendctx.Values{RequestID: "req-2", Tenant: "globex"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := audit.NewMemoryRecorder(0)
+			client, err := NewClientFromOptions(
+				config.WithExternalPlatforms("chat"),
+				config.WithTargetAccess(target.AccessRule{Platform: "chat", Deny: []string{"blocked"}}),
+				config.WithAuditRecorder(recorder),
+				config.WithLogger(logger.Discard),
+			)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+			messages := make(chan *message.Message, 1)
+			if err := client.RegisterPlatform("chat", func(interface{}) (platform.Platform, error) {
+				return &recordingPlatform{name: "chat", sent: make(chan string, 1), messages: messages}, nil
+			}); err != nil {
+				t.Fatalf("RegisterPlatform() error = %v", err)
+			}
+			if err := client.SetPlatformConfig("chat", "sender"); err != nil {
+				t.Fatalf("SetPlatformConfig() error = %v", err)
+			}
+
+			msg := message.New().SetTitle("Deploy").SetTenant(tt.msgTenant)
+			msg.Targets = []target.Target{target.New(target.TargetTypeUser, "ops", "chat"), target.New(target.TargetTypeUser, "blocked", "chat")}
+			if _, err := client.Send(sendctx.With(context.Background(), tt.ctx), msg); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			if got := sendctx.FromMessage(<-messages); got != tt.wantValues {
+				t.Errorf("platform message values = %+v, want %+v", got, tt.wantValues)
+			}
+			entries := recorder.Entries()
+			if len(entries) != 1 || !reflect.DeepEqual(entries[0].Metadata, tt.wantValues.Metadata()) {
+				t.Errorf("audit entries = %+v, want metadata %v", entries, tt.wantValues.Metadata())
+			}
+			if _, ok := msg.Metadata[message.MetadataRequestID]; ok {
+				t.Error("Send() changed the metadata of the caller's message")
+			}
+		})
+	}
+}
+
+func TestClientImpl_SendBatchModes(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         BatchMode
+		paths        []string // webhook path of each message; "" sends an invalid message
+		wantStatuses []string
+		wantRequests int32
+		wantErr      bool
+	}{
+		{name: "best effort", paths: []string{"/ok", "", "/ok"}, wantStatuses: []string{receiptpkg.StatusSuccess, receiptpkg.StatusPending, receiptpkg.StatusSuccess}, wantRequests: 2},
+		{name: "all or nothing sends valid batch", mode: BatchAllOrNothing, paths: []string{"/ok", "/down"}, wantStatuses: []string{receiptpkg.StatusSuccess, receiptpkg.StatusFailed}, wantRequests: 2},
+		{name: "all or nothing rejects invalid batch", mode: BatchAllOrNothing, paths: []string{"/ok", ""}, wantStatuses: []string{receiptpkg.StatusAborted, receiptpkg.StatusAborted}, wantErr: true},
+		{name: "stop on error", mode: BatchStopOnError, paths: []string{"/ok", "/down", "/ok"}, wantStatuses: []string{receiptpkg.StatusSuccess, receiptpkg.StatusFailed, receiptpkg.StatusAborted}, wantRequests: 2, wantErr: true},
+		{name: "stop on error without errors", mode: BatchStopOnError, paths: []string{"/ok", "/ok"}, wantStatuses: []string{receiptpkg.StatusSuccess, receiptpkg.StatusSuccess}, wantRequests: 2},
+		{name: "invalid mode", mode: "sometimes", paths: []string{"/ok"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				if r.URL.Path == "/down" {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+			client, err := NewClientFromOptions(config.WithQuickWebhook(server.URL), config.WithLogger(logger.Discard))
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+
+			msgs := make([]*message.Message, len(tt.paths))
+			for i, path := range tt.paths {
+				msgs[i] = message.New()
+				msgs[i].ID = fmt.Sprintf("msg-%d", i)
+				if path != "" {
+					msgs[i].SetTitle("Deploy")
+					msgs[i].Targets = []target.Target{target.NewWebhook(server.URL + path)}
+				}
+			}
+			var opts []BatchOption
+			if tt.mode != "" {
+				opts = append(opts, WithBatchMode(tt.mode))
+			}
+
+			receipts, err := client.SendBatch(context.Background(), msgs, opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendBatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantStatuses == nil {
+				return
+			}
+			wantMode := string(tt.mode)
+			if wantMode == "" {
+				wantMode = string(BatchBestEffort)
+			}
+			if len(receipts) != len(tt.wantStatuses) {
+				t.Fatalf("SendBatch() returned %d receipts, want %d", len(receipts), len(tt.wantStatuses))
+			}
+			for i, receipt := range receipts {
+				if receipt.Status != tt.wantStatuses[i] || receipt.BatchMode != wantMode || receipt.MessageID != msgs[i].ID {
+					t.Errorf("receipt %d = %+v, want status %s in mode %s", i, receipt, tt.wantStatuses[i], wantMode)
+				}
+			}
+			if requests.Load() != tt.wantRequests {
+				t.Errorf("webhook requests = %d, want %d", requests.Load(), tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestClientImpl_SendStream(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+	client, err := NewClientFromOptions(config.WithQuickWebhook(server.URL), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	var produced int
+	msgs := func(yield func(*message.Message) bool) {
+		for i := 0; i < 3; i++ {
+			produced++
+			msg := message.New().SetTitle("Deploy")
+			msg.ID = fmt.Sprintf("msg-%d", i)
+			msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+			if !yield(msg) {
+				return
+			}
+		}
+	}
+
+	var ids []string
+	for receipt, err := range client.(StreamSender).SendStream(context.Background(), msgs) {
+		if err != nil {
+			t.Fatalf("SendStream() error = %v", err)
+		}
+		ids = append(ids, receipt.MessageID)
+		if len(ids) == 2 {
+			break
+		}
+	}
+	if !reflect.DeepEqual(ids, []string{"msg-0", "msg-1"}) || produced != 2 || requests.Load() != 2 {
+		t.Errorf("SendStream() sent %v of %d produced messages in %d requests, want 2", ids, produced, requests.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var errs []error
+	for receipt, err := range client.(StreamSender).SendStream(ctx, msgs) {
+		if receipt != nil {
+			t.Errorf("SendStream() with an ended context sent %s", receipt.MessageID)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("SendStream() with an ended context yielded %v, want context.Canceled once", errs)
+	}
+}
+
+// preflightPlatform is a platform verifying its credentials with a
+// preflight that fails with err
+func TestClientImpl_RateLimitStateSurvivesRestart(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "ratelimit.json")
+	sent := make(chan string, 4)
+
+	// send starts a client, sends once and closes it, as a restarted process would
+	send := func() string {
+		t.Helper()
+		client, err := NewClientFromOptions(
+			config.WithExternalPlatforms("chat"),
+			config.WithQuota(ratelimit.Quota{Platform: "chat", Rate: 1, Per: time.Hour, Policy: ratelimit.PolicyDrop}),
+			config.WithRateLimitState(state, time.Hour),
+			config.WithLogger(logger.Discard),
+		)
+		if err != nil {
+			t.Fatalf("NewClientFromOptions() error = %v", err)
+		}
+		if err := client.RegisterPlatform("chat", func(interface{}) (platform.Platform, error) {
+			return &recordingPlatform{name: "chat", sent: sent}, nil
+		}); err != nil {
+			t.Fatalf("RegisterPlatform() error = %v", err)
+		}
+		if err := client.SetPlatformConfig("chat", "sender"); err != nil {
+			t.Fatalf("SetPlatformConfig() error = %v", err)
+		}
+		msg := message.New().SetTitle("Deploy")
+		msg.Targets = []target.Target{target.New(target.TargetTypeUser, "ops", "chat")}
+		receipt, err := client.Send(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if err := client.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		return receipt.Results[0].Status
+	}
+
+	if status := send(); status != "" {
+		t.Fatalf("first send status = %q, want delivered", status)
+	}
+	if status := send(); status != receiptpkg.ResultRateLimited {
+		t.Errorf("send after restart status = %q, want %q", status, receiptpkg.ResultRateLimited)
+	}
+	if len(sent) != 1 {
+		t.Errorf("delivered %d sends, want 1", len(sent))
+	}
+}
+
+func TestClientImpl_ArchivesCompletedMessages(t *testing.T) {
+	dir := t.TempDir()
+	sent := make(chan string, 4)
+	client, err := NewClientFromOptions(
+		config.WithExternalPlatforms("chat"),
+		config.WithArchive(config.ArchiveConfig{Dir: dir, Prefix: "notifyhub", Interval: time.Hour, PartitionByTenant: true}),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	if err := client.RegisterPlatform("chat", func(interface{}) (platform.Platform, error) {
+		return &recordingPlatform{name: "chat", sent: sent}, nil
+	}); err != nil {
+		t.Fatalf("RegisterPlatform() error = %v", err)
+	}
+	if err := client.SetPlatformConfig("chat", "sender"); err != nil {
+		t.Fatalf("SetPlatformConfig() error = %v", err)
+	}
+	for _, tenant := range []string{"acme", "globex"} {
+		msg := message.New().SetTitle("Deploy").SetTenant(tenant)
+		msg.Targets = []target.Target{target.New(target.TargetTypeUser, "ops", "chat")}
+		if _, err := client.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	// Messages are written when the client closes, before the interval
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	objects, err := filepath.Glob(filepath.Join(dir, "notifyhub", "date=*", "tenant=*", "*.jsonl.gz"))
+	if err != nil || len(objects) != 2 {
+		t.Fatalf("archive objects = %v, %v, want one per tenant", objects, err)
+	}
+	for _, object := range objects {
+		data, err := os.ReadFile(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := archive.Read(data)
+		if err != nil || len(records) != 1 {
+			t.Fatalf("Read(%s) = %d records, %v", object, len(records), err)
+		}
+		if r := records[0]; !strings.Contains(object, "tenant="+r.Message.Tenant()) || r.Receipt.Status != receiptpkg.StatusSuccess {
+			t.Errorf("%s holds %+v", object, r.Receipt)
+		}
+	}
+}
+
+type preflightPlatform struct {
+	recordingPlatform
+	err error
+}
+
+func (p *preflightPlatform) Preflight(context.Context) error { return p.err }
+
+func TestClientImpl_Preflight(t *testing.T) {
+	client, err := NewClientFromOptions(config.WithExternalPlatforms("chat", "sms"), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	factories := map[string]platform.Factory{
+		"chat": func(interface{}) (platform.Platform, error) { return &recordingPlatform{name: "chat"}, nil },
+		"sms": func(interface{}) (platform.Platform, error) {
+			return &preflightPlatform{recordingPlatform: recordingPlatform{name: "sms"}, err: fmt.Errorf("invalid API key")}, nil
+		},
+	}
+	for name, factory := range factories {
+		if err := client.RegisterPlatform(name, factory); err != nil {
+			t.Fatalf("RegisterPlatform(%s) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"chat", "sms"} {
+		if err := client.SetPlatformConfig(name, "sender"); err != nil {
+			t.Fatalf("SetPlatformConfig(%s) error = %v", name, err)
+		}
+	}
+
+	report, err := client.(Preflighter).Preflight(context.Background())
+	if err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+	if report.Ready || !reflect.DeepEqual(report.Failed(), []string{"sms"}) || len(report.Platforms) != 2 {
+		t.Errorf("Preflight() = %+v, want sms failed", report)
+	}
+	tests := []struct {
+		platform  string
+		wantReady bool
+		wantCheck string
+		wantErr   string
+	}{
+		{platform: "chat", wantReady: true, wantCheck: PreflightCheckHealth},
+		{platform: "sms", wantCheck: PreflightCheckCredentials, wantErr: "invalid API key"},
+	}
+	for _, tt := range tests {
+		result := report.Platforms[tt.platform]
+		if result.Ready != tt.wantReady || result.Check != tt.wantCheck || !strings.Contains(result.Error, tt.wantErr) {
+			t.Errorf("Preflight() %s = %+v, want ready %v with %q check and error %q", tt.platform, result, tt.wantReady, tt.wantCheck, tt.wantErr)
+		}
+	}
+}
+
+func TestConfigOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		check   func(*config.Config) bool
+		wantErr string
+	}{
+		{
+			name: "feishu webhook",
+			opts: []Option{WithFeishuWebhook("https://open.feishu.cn/hook/abc", "s3cret"), WithTimeout(10 * time.Second), WithMaxRetries(5)},
+			check: func(c *config.Config) bool {
+				return c.Feishu != nil && c.Feishu.WebhookURL == "https://open.feishu.cn/hook/abc" && c.Feishu.Secret == "s3cret" &&
+					c.Feishu.Timeout == 30*time.Second && c.Timeout == 10*time.Second && c.MaxRetries == 5
+			},
+		},
+		{
+			name: "email and webhook",
+			opts: []Option{WithEmailSMTP("smtp.example.com", 587, "user", "pass", "ops@example.com"), WithWebhookBasic("https://hooks.example.com/notify")},
+			check: func(c *config.Config) bool {
+				return c.Email != nil && c.Email.Host == "smtp.example.com" && c.Email.Password == "pass" && c.Email.UseTLS &&
+					c.Webhook != nil && c.Webhook.Method == "POST" && c.Webhook.Headers["Content-Type"] == "application/json"
+			},
+		},
+		{
+			name: "async and logging",
+			opts: []Option{WithWebhookBasic("https://hooks.example.com/notify"), WithAsync(8), WithLoggerConfig("debug", "text"), WithLogger(logger.Discard)},
+			check: func(c *config.Config) bool {
+				return c.Async.Enabled && c.Async.Workers == 8 && c.Logger.Level == "debug" && c.Logger.Format == "text" && c.LoggerInstance == logger.Discard
+			},
+		},
+		{
+			name: "feishu app",
+			opts: []Option{WithFeishuApp("cli_123", "secret")},
+			check: func(c *config.Config) bool {
+				return c.Feishu != nil && c.Feishu.AppID == "cli_123" && c.Feishu.AppSecret == "secret" && c.Feishu.WebhookURL == ""
+			},
+		},
+		{name: "invalid option", opts: []Option{WithTimeout(-time.Second)}, wantErr: "timeout must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := ConfigOptions(tt.opts...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ConfigOptions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConfigOptions() error = %v", err)
+			}
+			cfg, err := config.New(opts...)
+			if err != nil {
+				t.Fatalf("config.New() error = %v", err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("converted configuration = %+v", cfg)
+			}
+			client, err := NewClientFromOptions(append(opts, config.WithLogger(logger.Discard))...)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			client.Close()
+		})
+	}
+}
+
+type rejectingPlatform struct {
+	recordingPlatform
+}
+
+func (p *rejectingPlatform) Send(_ context.Context, _ *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	results := make([]*platform.SendResult, 0, len(targets))
+	for _, tgt := range targets {
+		err := fmt.Errorf("number %s rejected, see https://hooks.slack.com/services/T0/B0/xoxb", tgt.Value)
+		results = append(results, &platform.SendResult{Target: tgt, Error: err})
+	}
+	return results, nil
+}
+
+func TestClientImpl_SendRedaction(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []config.Option
+		wantMasked bool
+	}{
+		{name: "production level", wantMasked: true},
+		{name: "debug level", opts: []config.Option{config.WithTestDefaults()}},
+		{name: "forced on", opts: []config.Option{config.WithTestDefaults(), config.WithRedaction()}, wantMasked: true},
+		{name: "turned off", opts: []config.Option{config.WithoutRedaction()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			opts := append([]config.Option{
+				config.WithExternalPlatforms("sms"),
+				config.WithLogger(logger.NewStandardLogger(stdlog.New(&logs, "", 0), logger.Debug, "")),
+			}, tt.opts...)
+			client, err := NewClientFromOptions(opts...)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+			if err := client.RegisterPlatform("sms", func(interface{}) (platform.Platform, error) {
+				return &rejectingPlatform{recordingPlatform{name: "sms"}}, nil
+			}); err != nil {
+				t.Fatalf("RegisterPlatform() error = %v", err)
+			}
+			if err := client.SetPlatformConfig("sms", "sender"); err != nil {
+				t.Fatalf("SetPlatformConfig() error = %v", err)
+			}
+
+			msg := message.New().SetTitle("Code")
+			msg.Targets = []target.Target{target.New("phone", "+8613800138000", "sms")}
+			receipt, err := client.Send(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if len(receipt.Results) != 1 || receipt.Results[0].Target != "+8613800138000" {
+				t.Fatalf("Send() results = %+v, want the target unmasked", receipt.Results)
+			}
+
+			leaked := strings.Contains(receipt.Results[0].Error, "+8613800138000") || strings.Contains(receipt.Results[0].Error, "xoxb")
+			if leaked == tt.wantMasked {
+				t.Errorf("receipt error = %q, masked = %v", receipt.Results[0].Error, tt.wantMasked)
+			}
+			if tt.wantMasked && !strings.Contains(receipt.Results[0].Error, "+86138****8000") {
+				t.Errorf("receipt error = %q, want the number partially masked", receipt.Results[0].Error)
+			}
+			if tt.wantMasked && (logs.Len() == 0 || strings.Contains(logs.String(), "+8613800138000")) {
+				t.Errorf("logs = %q, want the number masked", logs.String())
+			}
+		})
+	}
+}
+
+func TestClientImpl_SendScrubsCredentials(t *testing.T) {
+	const hook, secret = "/open-apis/bot/v2/hook/tok-1a2b3c", "sign-s3cret"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A provider echoing the credentials it rejects
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"code":19021,"msg":"sign match fail for %s with secret %s"}`, r.URL.Path, secret)
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	feishu := config.NewFeishuConfig(server.URL+hook, secret)
+	feishu.MaxRetries = 0
+	client, err := NewClientFromOptions(
+		config.WithFeishu(feishu),
+		config.WithoutRedaction(),
+		config.WithLogger(logger.NewStandardLogger(stdlog.New(&logs, "", 0), logger.Debug, "")),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	msg := message.New().SetTitle("Deploy").SetBody("done")
+	msg.Targets = []target.Target{target.New("feishu", "ops", "feishu")}
+	receipt, err := client.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(receipt.Results) != 1 || receipt.Results[0].Success || receipt.Results[0].Error == "" {
+		t.Fatalf("Send() results = %+v, want one failure", receipt.Results)
+	}
+	for _, leaked := range []string{"tok-1a2b3c", secret} {
+		if strings.Contains(receipt.Results[0].Error, leaked) {
+			t.Errorf("receipt error = %q, should not contain %q", receipt.Results[0].Error, leaked)
+		}
+		if strings.Contains(logs.String(), leaked) {
+			t.Errorf("logs = %q, should not contain %q", logs.String(), leaked)
+		}
+	}
+	if !strings.Contains(receipt.Results[0].Error, redact.Redacted) {
+		t.Errorf("receipt error = %q, want the credentials replaced", receipt.Results[0].Error)
+	}
+}
+
+func TestClientImpl_AuditsSends(t *testing.T) {
+	tests := []struct {
+		name        string
+		withContent bool
+	}{
+		{"with content", true},
+		{"hash only", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := audit.NewMemoryRecorder(0)
+			client, err := NewClientFromOptions(
+				config.WithExternalPlatforms("chat"),
+				config.WithAuditRecorder(recorder),
+				config.WithAuditSends(tt.withContent),
+				config.WithLogger(logger.Discard),
+			)
+			if err != nil {
+				t.Fatalf("NewClientFromOptions() error = %v", err)
+			}
+			defer client.Close()
+			if err := client.RegisterPlatform("chat", func(interface{}) (platform.Platform, error) {
+				return &recordingPlatform{name: "chat", sent: make(chan string, 1)}, nil
+			}); err != nil {
+				t.Fatalf("RegisterPlatform() error = %v", err)
+			}
+			if err := client.SetPlatformConfig("chat", "sender"); err != nil {
+				t.Fatalf("SetPlatformConfig() error = %v", err)
+			}
+			msg := message.New().SetTitle("Deploy").SetBody("v2 is live").SetTenant("acme")
+			msg.Targets = []target.Target{target.New(target.TargetTypeUser, "ops", "chat")}
+			if _, err := client.Send(context.Background(), msg); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			entries := recorder.Entries()
+			if len(entries) != 1 {
+				t.Fatalf("audit entries = %+v, want one", entries)
+			}
+			e := entries[0]
+			if e.Action != audit.ActionMessageSent || e.MessageID != msg.ID || e.Metadata["tenant"] != "acme" || e.Status != receiptpkg.StatusSuccess {
+				t.Errorf("entry = %+v", e)
+			}
+			if e.ContentHash != audit.ContentHash("Deploy", "v2 is live") || (e.Content != nil) != tt.withContent {
+				t.Errorf("entry content = %+v, hash %s", e.Content, e.ContentHash)
+			}
+		})
+	}
+}
+
+// regionalPlatform sends through the URL of its webhook section, failing
+// while the URL is down
+type regionalPlatform struct {
+	url  string
+	down *sync.Map
+	sent chan string
+}
+
+func (p *regionalPlatform) Name() string { return "webhook" }
+func (p *regionalPlatform) GetCapabilities() platform.Capabilities {
+	return platform.Capabilities{Name: "webhook"}
+}
+func (p *regionalPlatform) ValidateTarget(target.Target) error { return nil }
+func (p *regionalPlatform) Close() error                       { return nil }
+
+func (p *regionalPlatform) IsHealthy(context.Context) error {
+	if _, down := p.down.Load(p.url); down {
+		return fmt.Errorf("%s is down", p.url)
+	}
+	return nil
+}
+
+func (p *regionalPlatform) Send(_ context.Context, _ *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	if _, down := p.down.Load(p.url); down {
+		return nil, fmt.Errorf("%s is down", p.url)
+	}
+	results := make([]*platform.SendResult, 0, len(targets))
+	for _, tgt := range targets {
+		p.sent <- p.url + " " + tgt.Value
+		results = append(results, &platform.SendResult{Target: tgt, Success: true})
+	}
+	return results, nil
+}
+
+func TestWithRegions(t *testing.T) {
+	down := &sync.Map{}
+	sent := make(chan string, 8)
+	factory := withRegions("webhook", func(section interface{}) (platform.Platform, error) {
+		return &regionalPlatform{url: section.(*config.WebhookConfig).URL, down: down, sent: sent}, nil
+	}, []config.Region{
+		{Name: "eu", Settings: map[string]string{"url": "https://eu.example.com"}, Targets: []string{"*.de"}},
+		{Name: "ap", Settings: map[string]string{"url": "https://ap.example.com"}},
+	}, config.RegionFailoverConfig{FailureThreshold: 2, Cooldown: time.Minute}, logger.Discard)
+	p, err := factory(&config.WebhookConfig{URL: "https://us.example.com"})
+	if err != nil {
+		t.Fatalf("factory error = %v", err)
+	}
+	regional := p.(*regionPlatform)
+	now := time.Now()
+	regional.now = func() time.Time { return now }
+
+	send := func(targets ...string) []string {
+		var tgts []target.Target
+		for _, value := range targets {
+			tgts = append(tgts, target.NewEmail(value))
+		}
+		results, err := p.Send(context.Background(), message.New(), tgts)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if len(results) != len(targets) {
+			t.Fatalf("Send() results = %d, want %d", len(results), len(targets))
+		}
+		var got []string
+		for range targets {
+			got = append(got, <-sent)
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	steps := []struct {
+		name    string
+		down    []string
+		advance time.Duration
+		targets []string
+		want    []string
+	}{
+		{"affinity", nil, 0, []string{"ops@example.com", "ops@example.de"}, []string{"https://eu.example.com ops@example.de", "https://us.example.com ops@example.com"}},
+		{"home region fails over to primary", []string{"https://eu.example.com"}, 0, []string{"ops@example.de"}, []string{"https://us.example.com ops@example.de"}},
+		{"failover in order", []string{"https://us.example.com"}, 0, []string{"ops@example.com"}, []string{"https://eu.example.com ops@example.com"}},
+		{"failover again", []string{"https://us.example.com"}, 0, []string{"ops@example.com"}, []string{"https://eu.example.com ops@example.com"}},
+		// us failed twice in a row, reaching the threshold, and is skipped
+		// although it is back
+		{"down region skipped", nil, 0, []string{"ops@example.com"}, []string{"https://eu.example.com ops@example.com"}},
+		{"down region tried after cooldown", nil, 2 * time.Minute, []string{"ops@example.com"}, []string{"https://us.example.com ops@example.com"}},
+	}
+	for _, step := range steps {
+		down.Range(func(key, _ interface{}) bool { down.Delete(key); return true })
+		for _, url := range step.down {
+			down.Store(url, true)
+		}
+		now = now.Add(step.advance)
+		if got := send(step.targets...); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: sent %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	down.Store("https://us.example.com", true)
+	if err := p.IsHealthy(context.Background()); err != nil {
+		t.Errorf("IsHealthy() error = %v with two regions up", err)
+	}
+	down.Store("https://eu.example.com", true)
+	down.Store("https://ap.example.com", true)
+	if err := p.IsHealthy(context.Background()); err == nil {
+		t.Error("IsHealthy() error = nil with every region down")
+	}
+}
+
+func TestClientImpl_UsageQuotas(t *testing.T) {
+	client, err := NewClientFromOptions(
+		config.WithExternalPlatforms("chat", "sms"),
+		config.WithUsageQuota(ratelimit.UsageQuota{Tenant: ratelimit.AnySubject, Max: 2, Period: ratelimit.PeriodDay, SoftLimit: 2}),
+		config.WithUsageQuota(ratelimit.UsageQuota{App: "billing", Platform: "sms", Max: 1, Period: ratelimit.PeriodMonth}),
+		config.WithUsageWarnings(target.New(target.TargetTypeUser, "quota-watch", "chat")),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	sent := make(chan string, 10)
+	for _, name := range []string{"chat", "sms"} {
+		name := name
+		if err := client.RegisterPlatform(name, func(interface{}) (platform.Platform, error) {
+			return &recordingPlatform{name: name, sent: sent}, nil
+		}); err != nil {
+			t.Fatalf("RegisterPlatform() error = %v", err)
+		}
+		if err := client.SetPlatformConfig(name, "sender"); err != nil {
+			t.Fatalf("SetPlatformConfig() error = %v", err)
+		}
+	}
+
+	chat := []target.Target{target.New(target.TargetTypeUser, "ops", "chat")}
+	sms := func(phones ...string) []target.Target {
+		var targets []target.Target
+		for _, phone := range phones {
+			targets = append(targets, target.New(target.TargetTypePhone, phone, "sms"))
+		}
+		return targets
+	}
+	billing := sendctx.WithApp(context.Background(), "billing")
+	steps := []struct {
+		name     string
+		ctx      context.Context
+		tenant   string
+		targets  []target.Target
+		async    bool
+		exceeded bool
+	}{
+		{name: "first message", tenant: "acme", targets: chat},
+		{name: "soft limit", tenant: "acme", targets: chat},
+		{name: "over the tenant quota", tenant: "acme", targets: chat, exceeded: true},
+		{name: "other tenant", tenant: "globex", targets: chat},
+		{name: "targets over the app quota", ctx: billing, targets: sms("+8613800138000", "+8613800138001"), exceeded: true},
+		{name: "app quota", ctx: billing, targets: sms("+8613800138000")},
+		{name: "queued over the app quota", ctx: billing, targets: sms("+8613800138001"), async: true, exceeded: true},
+		{name: "other app", ctx: sendctx.WithApp(context.Background(), "crm"), targets: sms("+8613800138001")},
+	}
+	for _, step := range steps {
+		ctx := step.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		msg := message.New().SetTitle("Invoice").SetBody("Your invoice is ready")
+		if step.tenant != "" {
+			msg.SetTenant(step.tenant)
+		}
+		msg.Targets = step.targets
+		if step.async {
+			_, err = client.SendAsync(ctx, msg)
+		} else {
+			_, err = client.Send(ctx, msg)
+		}
+		if got := errors.Is(err, ErrQuotaExceeded); got != step.exceeded {
+			t.Errorf("%s: error = %v, want quota exceeded %v", step.name, err, step.exceeded)
+		}
+	}
+
+	got := map[string]int{}
+	for want := 6; want > 0; want-- {
+		select {
+		case s := <-sent:
+			got[s]++
+		case <-time.After(time.Second):
+			t.Fatalf("sent %v, want 6 sends", got)
+		}
+	}
+	if want := map[string]int{"chat:ops": 3, "chat:quota-watch": 1, "sms:+8613800138000": 1, "sms:+8613800138001": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+
+	usage, err := client.(UsageReader).Usage(context.Background(), "acme", "")
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	if len(usage) != 1 || usage[0].Tenant != "acme" || usage[0].Used != 2 || usage[0].Remaining != 0 {
+		t.Errorf("Usage(acme) = %+v, want 2 messages used", usage)
+	}
+	usage, _ = client.(UsageReader).Usage(context.Background(), "", "billing")
+	if len(usage) != 1 || usage[0].App != "billing" || usage[0].Used != 1 || usage[0].Quota.Platform != "sms" {
+		t.Errorf("Usage(billing) = %+v, want 1 sms used", usage)
+	}
+}
+
+func TestClientImpl_PausePlatform(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			requests.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook(server.URL),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	send := func() *receiptpkg.Receipt {
+		t.Helper()
+		msg := message.New().SetTitle("Alert").SetBody("disk full")
+		msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+		receipt, err := client.Send(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return receipt
+	}
+
+	if err := client.PausePlatform("feishu"); err == nil {
+		t.Error("PausePlatform() of an unconfigured platform error = nil")
+	}
+	if err := client.PausePlatform("webhook"); err != nil {
+		t.Fatalf("PausePlatform() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if receipt := send(); receipt.Status != receiptpkg.StatusHeld || receipt.Results[0].Error != "held while platform webhook is paused" {
+			t.Errorf("Send() to a paused platform receipt = %+v, want held", receipt)
+		}
+	}
+	if status := client.Controls(); status.PausedPlatforms["webhook"] != 2 {
+		t.Errorf("Controls() = %+v, want 2 deliveries held for webhook", status)
+	}
+	health, _ := client.Health(context.Background())
+	if health.Status != "degraded" || health.Platforms["webhook"] != "paused" {
+		t.Errorf("Health() = %+v, want webhook paused", health)
+	}
+
+	if drained, err := client.DrainQueue("webhook"); err != nil || drained != 2 {
+		t.Errorf("DrainQueue() = %d, %v, want 2 drained", drained, err)
+	}
+	if _, err := client.DrainQueue("nope"); err == nil {
+		t.Error("DrainQueue() of an unknown queue error = nil")
+	}
+	send()
+	if released, err := client.ResumePlatform("webhook"); err != nil || released != 1 {
+		t.Fatalf("ResumePlatform() = %d, %v, want 1 released", released, err)
+	}
+	if _, err := client.ResumePlatform("webhook"); err == nil {
+		t.Error("ResumePlatform() of a running platform error = nil")
+	}
+	deadline := time.Now().Add(time.Second)
+	for requests.Load() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("webhook requests after ResumePlatform() = %d, want the 1 held delivery", requests.Load())
+	}
+	if receipt := send(); receipt.Successful != 1 || requests.Load() != 2 {
+		t.Errorf("Send() after ResumePlatform() receipt = %+v, want delivered", receipt)
+	}
+}
+
+func TestClientImpl_Replay(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		delivered = append(delivered, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	// An archive of a delivered message, a partially failed one and one of
+	// another tenant
+	at := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	store := archive.DirStore{Dir: t.TempDir()}
+	archiver := archive.New(store, archive.Options{Prefix: "nh"})
+	add := func(id, tenant string, targets map[string]bool) {
+		msg := message.New().SetTitle("Deploy " + id).SetBody("done").SetTenant(tenant)
+		msg.ID = id
+		rcpt := receiptpkg.New(id)
+		for _, path := range []string{"/a", "/b", "/down"} {
+			if ok, found := targets[path]; found {
+				msg.Targets = append(msg.Targets, target.NewWebhook(server.URL+path))
+				rcpt.AddResult(receiptpkg.PlatformResult{Platform: "webhook", Target: server.URL + path, Success: ok})
+			}
+		}
+		rcpt.Timestamp = at
+		archiver.Add(msg, rcpt)
+	}
+	add("m1", "acme", map[string]bool{"/a": true})
+	add("m2", "acme", map[string]bool{"/a": true, "/b": false})
+	add("m3", "globex", map[string]bool{"/b": false})
+	add("m4", "acme", map[string]bool{"/down": false})
+	if err := archiver.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClientFromOptions(
+		config.WithQuickWebhook(server.URL),
+		config.WithMaxRetries(0),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	source := ArchiveSource{Reader: store, Prefix: "nh"}
+	filter := ReplayFilter{Tenant: "acme", FailedOnly: true}
+	statuses := func(report *ReplayReport) string {
+		var got []string
+		for _, m := range report.Messages {
+			got = append(got, m.ID+":"+m.Status)
+		}
+		return strings.Join(got, ",")
+	}
+
+	report, err := client.Replay(context.Background(), source, filter, ReplayOptions{DryRun: true})
+	if err != nil || statuses(report) != "m2:preview,m4:preview" || len(delivered) != 0 {
+		t.Fatalf("Replay() dry run = %+v, %v, delivered %v", report, err, delivered)
+	}
+
+	report, err = client.Replay(context.Background(), source, filter, ReplayOptions{Rate: 100})
+	if err != nil || statuses(report) != "m2:sent,m4:failed" || report.Sent != 1 || report.Failed != 1 {
+		t.Fatalf("Replay() = %+v, %v", report, err)
+	}
+	if strings.Join(delivered, ",") != "/b" {
+		t.Errorf("delivered %v, want only the failed target of m2", delivered)
+	}
+	if key := report.Messages[0].Key; !strings.HasPrefix(key, "m2@") {
+		t.Errorf("idempotency key = %q", key)
+	}
+
+	report, err = client.Replay(context.Background(), source, filter, ReplayOptions{})
+	if err != nil || statuses(report) != "m2:duplicate,m4:failed" || report.Duplicates != 1 {
+		t.Errorf("Replay() again = %+v, %v, want m2 skipped and m4 retried", report, err)
+	}
+	if len(delivered) != 1 {
+		t.Errorf("delivered %v after a second replay", delivered)
+	}
+}
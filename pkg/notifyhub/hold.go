@@ -0,0 +1,198 @@
+// Package notifyhub provides delivery window holds for the NotifyHub client
+package notifyhub
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// heldDelivery is a target of a deferrable message waiting for the
+// recipient's delivery window
+type heldDelivery struct {
+	msg      *message.Message
+	platform string
+	target   target.Target
+	until    time.Time
+	quota    bool // deferred by a platform quota after the target checks
+}
+
+// holdQueue keeps held deliveries in memory and releases each one when its
+// delivery window opens. Held deliveries are lost when the client is closed.
+type holdQueue struct {
+	items   []heldDelivery // sorted by until
+	release func(heldDelivery)
+	mu      sync.Mutex
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// newHoldQueue creates a hold queue and starts its release loop
+func newHoldQueue(release func(heldDelivery)) *holdQueue {
+	q := &holdQueue{
+		release: release,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Add holds a delivery until its release time
+func (q *holdQueue) Add(d heldDelivery) {
+	q.mu.Lock()
+	i := sort.Search(len(q.items), func(i int) bool { return q.items[i].until.After(d.until) })
+	q.items = append(q.items, heldDelivery{})
+	copy(q.items[i+1:], q.items[i:])
+	q.items[i] = d
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Len returns the number of held deliveries
+func (q *holdQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Drain removes every held delivery and returns how many it removed
+func (q *holdQueue) Drain() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := len(q.items)
+	q.items = nil
+	return n
+}
+
+// Close stops the release loop and returns the number of deliveries that
+// were still held
+func (q *holdQueue) Close() int {
+	q.once.Do(func() { close(q.stop) })
+	<-q.done
+	return q.Len()
+}
+
+// run releases held deliveries as they become due
+func (q *holdQueue) run() {
+	defer close(q.done)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		for _, d := range q.due(time.Now()) {
+			q.release(d)
+		}
+
+		wait := time.Hour
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			wait = time.Until(q.items[0].until)
+		}
+		q.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// due removes and returns the deliveries whose release time has passed
+func (q *holdQueue) due(now time.Time) []heldDelivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := sort.Search(len(q.items), func(i int) bool { return q.items[i].until.After(now) })
+	if n == 0 {
+		return nil
+	}
+	due := make([]heldDelivery, n)
+	copy(due, q.items[:n])
+	q.items = q.items[n:]
+	return due
+}
+
+// holdForWindow holds a deferrable message for a target outside the
+// recipient's local delivery window and records a "held" result on the
+// receipt with the time the target will be delivered
+func (c *clientImpl) holdForWindow(msg *message.Message, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) bool {
+	if c.config.DeliveryWindow == nil || !msg.IsDeferrable() {
+		return false
+	}
+
+	window := c.config.DeliveryWindow
+	loc, err := window.Location(tgt.Timezone)
+	if err != nil {
+		c.logger.Warn("Invalid target timezone, using delivery window default", "timezone", tgt.Timezone, "error", err)
+		if loc, err = window.Location(""); err != nil {
+			return false
+		}
+	}
+
+	now := time.Now()
+	opens, err := window.NextOpen(now, loc)
+	if err != nil || !opens.After(now) {
+		return false
+	}
+
+	c.holds.Add(heldDelivery{msg: msg, platform: platformName, target: tgt, until: opens})
+	c.logger.Debug("Target held for delivery window", "platform", platformName, "until", opens)
+	receipt.AddResult(receiptpkg.PlatformResult{
+		Platform:  platformName,
+		Target:    tgt.Value,
+		Success:   false,
+		Status:    receiptpkg.ResultHeld,
+		Error:     "held until the recipient's delivery window opens",
+		HeldUntil: &opens,
+		Timestamp: now,
+	})
+	return true
+}
+
+// releaseHeld delivers a held target once its window has opened, or its
+// platform was resumed, unless the platform is paused again. The
+// platform flags, suppression list, quarantine and rate limits are checked
+// again since the platform may have been disabled, or the recipient may
+// have opted out, failed or been sent other messages while this one was
+// held. Deliveries deferred by a platform quota passed those checks and only
+// take the quota again.
+func (c *clientImpl) releaseHeld(d heldDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	defer cancel()
+
+	receipt := receiptpkg.New(d.msg.ID)
+	if c.holdForPause(d.msg, d.platform, d.target, receipt) {
+		return
+	}
+	if d.quota {
+		c.deliver(ctx, d.msg, d.platform, d.target, receipt)
+	} else if !c.isDisabled(ctx, d.msg, d.platform, d.target, receipt) && !c.isSuppressed(ctx, d.platform, d.target, receipt) && !c.isQuarantined(ctx, d.platform, d.target, receipt) &&
+		!c.isRateLimited(ctx, d.msg, d.platform, d.target, receipt) {
+		c.deliver(ctx, d.msg, d.platform, d.target, receipt)
+	}
+	c.logger.Info("Released held delivery", "message_id", d.msg.ID, "platform", d.platform, "status", receipt.Status)
+}
@@ -0,0 +1,42 @@
+// Package notifyhub provides the per-platform caps on concurrent sends
+package notifyhub
+
+import (
+	"context"
+	"fmt"
+)
+
+// newSendSlots returns the slots of the platforms whose concurrent sends are
+// capped, see config.Config.MaxInFlight
+func newSendSlots(maxInFlight map[string]int) map[string]chan struct{} {
+	if len(maxInFlight) == 0 {
+		return nil
+	}
+	slots := make(map[string]chan struct{}, len(maxInFlight))
+	for name, max := range maxInFlight {
+		slots[name] = make(chan struct{}, max)
+	}
+	return slots
+}
+
+// acquireSlot waits for a send slot of a platform, until ctx is done. The
+// returned function releases the slot.
+func (c *clientImpl) acquireSlot(ctx context.Context, platformName string) (func(), error) {
+	slots, ok := c.sendSlots[platformName]
+	if !ok {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+	}
+
+	c.log(ctx).Debug("Waiting for a send slot", "platform", platformName, "max_in_flight", cap(slots))
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no send slot of platform %s within the limit of %d in flight: %w", platformName, cap(slots), ctx.Err())
+	}
+}
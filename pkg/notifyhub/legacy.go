@@ -0,0 +1,84 @@
+// Package notifyhub provides the conversion of the legacy configuration
+package notifyhub
+
+import "github.com/kart-io/notifyhub/pkg/config"
+
+// ConfigOptions converts legacy options to the config.Option values that
+// NewClientFromOptions accepts, so that code written against the legacy
+// Config can create a client while it migrates:
+//
+//	opts, err := notifyhub.ConfigOptions(notifyhub.WithFeishuWebhook(url, secret), notifyhub.WithTimeout(10*time.Second))
+//	...
+//	client, err := notifyhub.NewClientFromOptions(opts...)
+func ConfigOptions(opts ...Option) ([]config.Option, error) {
+	var legacy Config
+	for _, opt := range opts {
+		if err := opt(&legacy); err != nil {
+			return nil, err
+		}
+	}
+	return legacy.ConfigOptions()
+}
+
+// ConfigOptions returns the config.Option values of the settings.
+// SignVerify is dropped, since Feishu messages are signed whenever a secret
+// is set.
+func (c *Config) ConfigOptions() ([]config.Option, error) {
+	var opts []config.Option
+	if c.Timeout > 0 {
+		opts = append(opts, config.WithTimeout(c.Timeout))
+	}
+	if c.MaxRetries > 0 {
+		opts = append(opts, config.WithMaxRetries(c.MaxRetries))
+	}
+
+	if c.Feishu != nil {
+		feishu := config.FeishuConfig{
+			WebhookURL: c.Feishu.WebhookURL,
+			Secret:     c.Feishu.Secret,
+			Keywords:   c.Feishu.Keywords,
+			Timeout:    c.Feishu.Timeout,
+			MaxRetries: c.Feishu.MaxRetries,
+			RateLimit:  c.Feishu.RateLimit,
+		}
+		if c.Feishu.AuthType == "app" {
+			feishu.WebhookURL = ""
+			feishu.AppID, feishu.AppSecret = c.Feishu.AppID, c.Feishu.AppSecret
+		}
+		opts = append(opts, config.WithFeishu(feishu))
+	}
+	if c.Email != nil {
+		opts = append(opts, config.WithEmail(config.EmailConfig{
+			Host:     c.Email.Host,
+			Port:     c.Email.Port,
+			Username: c.Email.Username,
+			Password: c.Email.Password,
+			From:     c.Email.From,
+			UseTLS:   c.Email.UseTLS,
+			Timeout:  c.Email.Timeout,
+		}))
+	}
+	if c.Webhook != nil {
+		opts = append(opts, config.WithWebhook(config.WebhookConfig{
+			URL:     c.Webhook.URL,
+			Method:  c.Webhook.Method,
+			Headers: c.Webhook.Headers,
+			Timeout: c.Webhook.Timeout,
+		}))
+	}
+
+	if c.Async.Enabled {
+		opts = append(opts, config.WithAsync(c.Async.Workers))
+	}
+	if c.Logger != (LoggerConfig{}) {
+		logger := c.Logger
+		opts = append(opts, func(cfg *config.Config) error {
+			cfg.Logger = config.LoggerConfig{Level: logger.Level, Format: logger.Format}
+			return nil
+		})
+	}
+	if c.LoggerInstance != nil {
+		opts = append(opts, config.WithLogger(c.LoggerInstance))
+	}
+	return opts, nil
+}
@@ -0,0 +1,78 @@
+// Package notifyhub provides the middleware chain of the send pipeline
+package notifyhub
+
+import (
+	"context"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/sendctx"
+)
+
+// SendFunc sends a message, as Client.Send does
+type SendFunc func(ctx context.Context, msg *message.Message) (*receipt.Receipt, error)
+
+// Middleware intercepts the sends of a client, for cross-cutting concerns
+// such as authorization, enrichment, tenant quotas or custom metrics. It
+// continues the send by calling next, and may change the context or the
+// message before, and inspect or replace the receipt after. Returning
+// without calling next rejects the send.
+type Middleware func(ctx context.Context, msg *message.Message, next SendFunc) (*receipt.Receipt, error)
+
+// Use adds middleware to the sends of the client. Middleware runs around
+// every message sent: by Send, for each message of SendBatch, and for the
+// messages of SendAsync and SendAsyncBatch when they are processed. The
+// middleware added first runs outermost. Deliveries held for a delivery
+// window are released without running the middleware again.
+func (c *clientImpl) Use(mw ...Middleware) {
+	c.middlewareMu.Lock()
+	defer c.middlewareMu.Unlock()
+	chain := make([]Middleware, 0, len(c.middleware)+len(mw))
+	chain = append(chain, c.middleware...)
+	for _, m := range mw {
+		if m != nil {
+			chain = append(chain, m)
+		}
+	}
+	c.middleware = chain
+}
+
+// Send sends a message synchronously through the middleware chain, which
+// sees the message with the options and the context values applied.
+// Messages over a usage quota are rejected with ErrQuotaExceeded before
+// the middleware runs. The error returned is masked when redaction is
+// enabled.
+func (c *clientImpl) Send(ctx context.Context, msg *message.Message, opts ...SendOption) (*receipt.Receipt, error) {
+	// Options and context values share one copy of the message
+	if len(opts) > 0 {
+		msg = applySendOptions(msg, opts)
+		sendctx.From(ctx).Fill(msg)
+	} else {
+		msg = sendctx.From(ctx).Attach(msg)
+	}
+
+	if _, err := c.chargeUsage(ctx, msg); err != nil {
+		return nil, c.redactor.Error(err)
+	}
+	return c.dispatch(ctx, msg)
+}
+
+// dispatch sends a message whose usage is counted through the middleware
+// chain
+func (c *clientImpl) dispatch(ctx context.Context, msg *message.Message) (*receipt.Receipt, error) {
+	c.middlewareMu.RLock()
+	chain := c.middleware
+	c.middlewareMu.RUnlock()
+
+	next := c.send
+	for i := len(chain) - 1; i >= 0; i-- {
+		mw, inner := chain[i], next
+		next = func(ctx context.Context, msg *message.Message) (*receipt.Receipt, error) {
+			return mw(ctx, msg, inner)
+		}
+	}
+	rcpt, err := next(ctx, msg)
+	c.archive(msg, rcpt)
+	c.auditSend(ctx, msg, rcpt)
+	return rcpt, c.redactor.Error(err)
+}
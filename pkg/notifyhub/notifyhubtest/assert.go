@@ -0,0 +1,87 @@
+package notifyhubtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// AssertSent fails the test unless the platform delivered a message that
+// match accepts, and returns the last such message. A nil match accepts
+// every message.
+func AssertSent(t testing.TB, p *Platform, match func(*message.Message) bool) *message.Message {
+	t.Helper()
+	messages := p.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if match == nil || match(messages[i]) {
+			return messages[i]
+		}
+	}
+	t.Errorf("notifyhubtest: %s delivered no matching message among %d: %s", p.Name(), len(messages), describe(messages))
+	return nil
+}
+
+// AssertSentTo fails the test unless the platform delivered a message to
+// a target value, and returns the last such message
+func AssertSentTo(t testing.TB, p *Platform, value string) *message.Message {
+	t.Helper()
+	sent := p.Sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].Err == nil && sent[i].Target.Value == value {
+			return sent[i].Message
+		}
+	}
+	t.Errorf("notifyhubtest: %s delivered no message to %q among %d: %s", p.Name(), value, len(p.Messages()), describe(p.Messages()))
+	return nil
+}
+
+// AssertNotSent fails the test if the platform delivered any message
+func AssertNotSent(t testing.TB, p *Platform) {
+	t.Helper()
+	if messages := p.Messages(); len(messages) > 0 {
+		t.Errorf("notifyhubtest: %s delivered %d messages, want none: %s", p.Name(), len(messages), describe(messages))
+	}
+}
+
+// AssertCount fails the test unless the platform delivered n messages,
+// counting a message once per target
+func AssertCount(t testing.TB, p *Platform, n int) {
+	t.Helper()
+	if messages := p.Messages(); len(messages) != n {
+		t.Errorf("notifyhubtest: %s delivered %d messages, want %d: %s", p.Name(), len(messages), n, describe(messages))
+	}
+}
+
+// WaitForMessages waits until the platform delivered n messages, e.g.
+// after SendAsync, and fails the test when the timeout passes first
+func WaitForMessages(t testing.TB, p *Platform, n int, timeout time.Duration) []*message.Message {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		messages := p.Messages()
+		if len(messages) >= n {
+			return messages
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("notifyhubtest: %s delivered %d messages within %s, want %d", p.Name(), len(messages), timeout, n)
+			return messages
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// describe lists the titles of messages for failure output
+func describe(messages []*message.Message) string {
+	titles := make([]string, len(messages))
+	for i, msg := range messages {
+		title := msg.Title
+		if title == "" {
+			title = msg.Body
+		}
+		titles[i] = fmt.Sprintf("%s %q", msg.ID, title)
+	}
+	return "[" + strings.Join(titles, ", ") + "]"
+}
@@ -0,0 +1,193 @@
+package notifyhubtest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// ErrChaos is the error of failures a Chaos platform injects without a
+// configured error
+var ErrChaos = errors.New("notifyhubtest: chaos failure")
+
+// ChaosPanic is the value a Chaos platform panics with
+const ChaosPanic = "notifyhubtest: chaos panic"
+
+// Latency returns the latency of one send, drawn from a distribution
+type Latency func(r *rand.Rand) time.Duration
+
+// FixedLatency delays every send by d
+func FixedLatency(d time.Duration) Latency {
+	return func(*rand.Rand) time.Duration { return d }
+}
+
+// UniformLatency delays sends by a duration between min and max
+func UniformLatency(min, max time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int63n(int64(max-min)))
+	}
+}
+
+// NormalLatency delays sends by a normally distributed duration, never
+// less than zero
+func NormalLatency(mean, stddev time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(math.Max(0, r.NormFloat64()*float64(stddev)+float64(mean)))
+	}
+}
+
+// TailLatency delays a fraction of sends by slow and the others by
+// the latency of base, modeling a provider with occasional stalls
+func TailLatency(base Latency, rate float64, slow time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		if r.Float64() < rate {
+			return slow
+		}
+		return base(r)
+	}
+}
+
+// ChaosConfig is the failure behavior of a Chaos platform. Rates are
+// probabilities between 0 and 1, drawn for every send attempt.
+type ChaosConfig struct {
+	FailureRate       float64 // the whole send fails with Err
+	TargetFailureRate float64 // a target fails with Err while the others are delivered
+	PanicRate         float64 // the send panics with ChaosPanic
+	Latency           Latency // the latency of sends, none when nil
+	Err               error   // the injected error, ErrChaos when nil
+
+	// Seed makes the injected failures reproducible; 0 picks a random
+	// seed
+	Seed int64
+}
+
+// ChaosStats counts the failures a Chaos platform injected
+type ChaosStats struct {
+	Sends          int // send attempts
+	Failures       int // failed sends
+	TargetFailures int // failed targets of sends that did not fail as a whole
+	Panics         int // panicked sends
+}
+
+// Chaos is a mock platform that injects failures, latency and panics at
+// random, to check that retry, fallback and escalation settings hold up
+// against an unreliable provider:
+//
+//	chaos := notifyhubtest.NewChaos("primary", notifyhubtest.ChaosConfig{FailureRate: 0.3, Seed: 1})
+//
+// The failures of Platform, such as FailNext, apply as well. Sends are
+// recorded like those of Platform, except for failed and panicked sends,
+// which reach no target.
+type Chaos struct {
+	*Platform
+
+	mu    sync.Mutex
+	cfg   ChaosConfig
+	rng   *rand.Rand
+	stats ChaosStats
+}
+
+// NewChaos creates a chaos platform with a name
+func NewChaos(name string, cfg ChaosConfig) *Chaos {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Chaos{Platform: NewPlatform(name), cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+// NewChaosClient creates a client with a chaos platform registered as
+// DefaultPlatform, closed when the test ends, like NewClient
+func NewChaosClient(t testing.TB, cfg ChaosConfig, opts ...config.Option) (notifyhub.Client, *Chaos) {
+	t.Helper()
+	chaos := NewChaos(DefaultPlatform, cfg)
+	return newClient(t, chaos.Factory(), opts), chaos
+}
+
+// Factory returns a factory that creates the chaos platform, ignoring the
+// configuration, for notifyhub.Client.RegisterPlatform
+func (c *Chaos) Factory() platform.Factory {
+	return func(interface{}) (platform.Platform, error) {
+		return c, nil
+	}
+}
+
+// SetConfig replaces the failure behavior, keeping the random source
+func (c *Chaos) SetConfig(cfg ChaosConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg
+}
+
+// Stats returns the counts of injected failures
+func (c *Chaos) Stats() ChaosStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Send implements platform.Platform, failing, delaying or panicking at
+// random before recording each target's message
+func (c *Chaos) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	c.mu.Lock()
+	c.stats.Sends++
+	var latency time.Duration
+	if c.cfg.Latency != nil {
+		latency = c.cfg.Latency(c.rng)
+	}
+	panics := c.chance(c.cfg.PanicRate)
+	fails := !panics && c.chance(c.cfg.FailureRate)
+	switch {
+	case panics:
+		c.stats.Panics++
+	case fails:
+		c.stats.Failures++
+	}
+	injected := orChaos(c.cfg.Err)
+	c.mu.Unlock()
+
+	if err := wait(ctx, latency); err != nil {
+		return nil, err
+	}
+	if panics {
+		panic(ChaosPanic)
+	}
+	if fails {
+		return nil, injected
+	}
+	return c.record(msg, targets, func(target.Target) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !c.chance(c.cfg.TargetFailureRate) {
+			return nil
+		}
+		c.stats.TargetFailures++
+		return injected
+	}), nil
+}
+
+// chance reports true with the probability rate; c.mu must be held
+func (c *Chaos) chance(rate float64) bool {
+	return rate > 0 && c.rng.Float64() < rate
+}
+
+// orChaos returns err, or ErrChaos when it is nil
+func orChaos(err error) error {
+	if err == nil {
+		return ErrChaos
+	}
+	return err
+}
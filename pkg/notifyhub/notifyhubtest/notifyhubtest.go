@@ -0,0 +1,296 @@
+// Package notifyhubtest provides an in-memory platform and assertions for
+// testing code that sends notifications, without real providers:
+//
+//	client, mock := notifyhubtest.NewClient(t)
+//	alerts := NewAlerter(client)
+//	alerts.DiskFull("db1")
+//	msg := notifyhubtest.AssertSentTo(t, mock, "oncall")
+//	if !strings.Contains(msg.Body, "db1") { ... }
+//
+// Messages reach the mock through targets of its platform, see Target.
+// Failures and latency are controlled per platform, e.g. FailNext to test
+// retries or SetLatency to test timeouts. A Chaos platform injects them at
+// random, to check retry, fallback and escalation settings under load.
+package notifyhubtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// DefaultPlatform is the platform name NewClient registers the mock as
+const DefaultPlatform = "mock"
+
+// ErrInjected is the error of failures injected with a nil error
+var ErrInjected = errors.New("notifyhubtest: injected failure")
+
+// Target returns a target delivered by the DefaultPlatform mock
+func Target(value string) target.Target {
+	return target.New(target.TargetTypeUser, value, DefaultPlatform)
+}
+
+// Sent is one send attempt to a target
+type Sent struct {
+	Message *message.Message
+	Target  target.Target
+	Time    time.Time
+	Err     error // the injected failure, nil for delivered messages
+}
+
+// Platform is an in-memory platform that records the messages it is asked
+// to send. It is safe for concurrent use.
+type Platform struct {
+	name string
+
+	mu          sync.Mutex
+	sent        []Sent
+	latency     time.Duration
+	failNext    int
+	failNextErr error
+	failAll     error
+	failTargets map[string]error
+	health      error
+	closed      bool
+}
+
+// NewPlatform creates a mock platform with a name
+func NewPlatform(name string) *Platform {
+	return &Platform{name: name, failTargets: make(map[string]error)}
+}
+
+// Factory returns a factory that creates the platform, ignoring the
+// configuration, for notifyhub.Client.RegisterPlatform
+func (p *Platform) Factory() platform.Factory {
+	return func(interface{}) (platform.Platform, error) {
+		return p, nil
+	}
+}
+
+// SetLatency delays every send, or the context deadline, whichever is
+// first; a send past its deadline fails with the context error
+func (p *Platform) SetLatency(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latency = d
+}
+
+// FailNext fails the next n send attempts with err, or ErrInjected when err
+// is nil. Every retry of the client is an attempt.
+func (p *Platform) FailNext(n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failNext, p.failNextErr = n, orInjected(err)
+}
+
+// FailAlways fails every send with err until it is called with nil
+func (p *Platform) FailAlways(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failAll = err
+}
+
+// FailTarget fails the sends to a target value with err, or ErrInjected
+// when err is nil
+func (p *Platform) FailTarget(value string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failTargets[value] = orInjected(err)
+}
+
+// SetHealth sets the error of health checks, nil for healthy
+func (p *Platform) SetHealth(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.health = err
+}
+
+// Reset forgets the recorded sends and the injected failures and latency
+func (p *Platform) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = nil
+	p.latency = 0
+	p.failNext, p.failNextErr, p.failAll = 0, nil, nil
+	p.failTargets = make(map[string]error)
+	p.health = nil
+}
+
+// Sent returns every send attempt in order, including failed ones
+func (p *Platform) Sent() []Sent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Sent(nil), p.sent...)
+}
+
+// Messages returns the messages delivered, once per target
+func (p *Platform) Messages() []*message.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var messages []*message.Message
+	for _, s := range p.sent {
+		if s.Err == nil {
+			messages = append(messages, s.Message)
+		}
+	}
+	return messages
+}
+
+// LastMessage returns the message delivered last, or nil
+func (p *Platform) LastMessage() *message.Message {
+	messages := p.Messages()
+	if len(messages) == 0 {
+		return nil
+	}
+	return messages[len(messages)-1]
+}
+
+// Closed reports whether the client closed the platform
+func (p *Platform) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// Name implements platform.Platform
+func (p *Platform) Name() string {
+	return p.name
+}
+
+// GetCapabilities implements platform.Platform
+func (p *Platform) GetCapabilities() platform.Capabilities {
+	return platform.Capabilities{
+		Name:                 p.name,
+		SupportedTargetTypes: []string{target.TargetTypeUser, target.TargetTypeGroup, target.TargetTypeChannel, target.TargetTypeEmail, target.TargetTypePhone, target.TargetTypeWebhook},
+		SupportedFormats:     []string{string(message.FormatText), string(message.FormatMarkdown), string(message.FormatHTML)},
+	}
+}
+
+// Send implements platform.Platform, recording each target's message
+func (p *Platform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	p.mu.Lock()
+	latency := p.latency
+	p.mu.Unlock()
+	if err := wait(ctx, latency); err != nil {
+		return nil, err
+	}
+	return p.record(msg, targets, nil), nil
+}
+
+// record records the send of a message to targets and returns their
+// results. Targets fail with their injected failure, or else with the
+// error of fail when it is not nil.
+func (p *Platform) record(msg *message.Message, targets []target.Target, fail func(target.Target) error) []*platform.SendResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	results := make([]*platform.SendResult, 0, len(targets))
+	for _, tgt := range targets {
+		copied := *msg
+		err := p.failure(tgt)
+		if err == nil && fail != nil {
+			err = fail(tgt)
+		}
+		p.sent = append(p.sent, Sent{Message: &copied, Target: tgt, Time: time.Now(), Err: err})
+		result := &platform.SendResult{Target: tgt, Success: err == nil, Error: err}
+		if err == nil {
+			result.MessageID = msg.ID
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// wait sleeps for d, or until the context is done and returns its error
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// failure returns the injected failure of a send to a target
+func (p *Platform) failure(tgt target.Target) error {
+	switch {
+	case p.failNext > 0:
+		p.failNext--
+		return p.failNextErr
+	case p.failAll != nil:
+		return p.failAll
+	}
+	return p.failTargets[tgt.Value]
+}
+
+// ValidateTarget implements platform.Platform, accepting every target
+func (p *Platform) ValidateTarget(target.Target) error {
+	return nil
+}
+
+// IsHealthy implements platform.Platform
+func (p *Platform) IsHealthy(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.health
+}
+
+// Close implements platform.Platform. A closed mock keeps sending, since a
+// configuration reload creates the platform again from the same instance.
+func (p *Platform) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// NewClient creates a client with a mock registered as DefaultPlatform,
+// closed when the test ends. Options configure the client further, e.g.
+// with send defaults or built-in platforms; logging is discarded unless an
+// option sets a logger.
+func NewClient(t testing.TB, opts ...config.Option) (notifyhub.Client, *Platform) {
+	t.Helper()
+	mock := NewPlatform(DefaultPlatform)
+	return newClient(t, mock.Factory(), opts), mock
+}
+
+// newClient creates a client with the platform of a factory registered as
+// DefaultPlatform, closed when the test ends
+func newClient(t testing.TB, factory platform.Factory, opts []config.Option) notifyhub.Client {
+	t.Helper()
+	opts = append([]config.Option{config.WithLogger(logger.Discard), config.WithExternalPlatforms(DefaultPlatform)}, opts...)
+	client, err := notifyhub.NewClientFromOptions(opts...)
+	if err != nil {
+		t.Fatalf("notifyhubtest: NewClientFromOptions() error = %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	if err := client.RegisterPlatform(DefaultPlatform, factory); err != nil {
+		t.Fatalf("notifyhubtest: RegisterPlatform() error = %v", err)
+	}
+	if err := client.SetPlatformConfig(DefaultPlatform, struct{}{}); err != nil {
+		t.Fatalf("notifyhubtest: SetPlatformConfig() error = %v", err)
+	}
+	return client
+}
+
+// orInjected returns err, or ErrInjected when it is nil
+func orInjected(err error) error {
+	if err == nil {
+		return ErrInjected
+	}
+	return err
+}
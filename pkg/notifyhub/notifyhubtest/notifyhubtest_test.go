@@ -0,0 +1,282 @@
+package notifyhubtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// recorder is a testing.TB that records failures instead of failing
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func TestPlatform(t *testing.T) {
+	client, mock := NewClient(t, config.WithSendDefaults(config.SendDefaults{MaxRetries: 1}))
+	send := func(title string, targets ...target.Target) {
+		t.Helper()
+		msg := message.New()
+		msg.ID, msg.Title, msg.Body, msg.Targets = title, title, "body of "+title, targets
+		if _, err := client.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Send(%s) error = %v", title, err)
+		}
+	}
+	errDown := errors.New("provider down")
+
+	tests := []struct {
+		name          string
+		setup         func()
+		targets       []target.Target
+		wantDelivered int
+		wantAttempts  int
+		wantError     string
+	}{
+		{name: "delivered", targets: []target.Target{Target("alice"), Target("bob")}, wantDelivered: 2, wantAttempts: 2},
+		{name: "retried", setup: func() { mock.FailNext(1, nil) }, targets: []target.Target{Target("alice")}, wantDelivered: 1, wantAttempts: 2},
+		{name: "retries exhausted", setup: func() { mock.FailNext(2, errDown) }, targets: []target.Target{Target("alice")}, wantAttempts: 2, wantError: "provider down"},
+		{name: "always failing", setup: func() { mock.FailAlways(errDown) }, targets: []target.Target{Target("alice")}, wantAttempts: 2, wantError: "provider down"},
+		{name: "failing target", setup: func() { mock.FailTarget("bob", nil) }, targets: []target.Target{Target("alice"), Target("bob")}, wantDelivered: 1, wantAttempts: 3, wantError: ErrInjected.Error()},
+		{name: "latency past deadline", setup: func() { mock.SetLatency(time.Second) }, targets: []target.Target{Target("alice")}, wantError: "context deadline exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.Reset()
+			if tt.setup != nil {
+				tt.setup()
+			}
+			msg := message.New()
+			msg.Title, msg.Targets = tt.name, tt.targets
+			msg.SetTimeout(50 * time.Millisecond)
+			rcpt, err := client.Send(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if got := len(mock.Messages()); got != tt.wantDelivered {
+				t.Errorf("Messages() = %d, want %d", got, tt.wantDelivered)
+			}
+			if got := len(mock.Sent()); got != tt.wantAttempts {
+				t.Errorf("Sent() = %d attempts, want %d", got, tt.wantAttempts)
+			}
+			if errs := strings.Join(rcpt.GetErrors(), "; "); !strings.Contains(errs, tt.wantError) || (tt.wantError == "" && errs != "") {
+				t.Errorf("receipt errors = %q, want %q", errs, tt.wantError)
+			}
+		})
+	}
+
+	mock.Reset()
+	AssertNotSent(t, mock)
+	send("disk full", Target("oncall"))
+	send("backup done", Target("ops"), Target("oncall"))
+	AssertCount(t, mock, 3)
+	if msg := AssertSentTo(t, mock, "ops"); msg == nil || msg.Title != "backup done" {
+		t.Errorf("AssertSentTo(ops) = %+v", msg)
+	}
+	if msg := AssertSent(t, mock, func(m *message.Message) bool { return strings.Contains(m.Body, "disk") }); msg == nil || msg.Title != "disk full" {
+		t.Errorf("AssertSent(disk) = %+v", msg)
+	}
+	if msg := mock.LastMessage(); msg == nil || msg.Title != "backup done" {
+		t.Errorf("LastMessage() = %+v", msg)
+	}
+
+	// Failing assertions report what was delivered
+	r := &recorder{}
+	AssertNotSent(r, mock)
+	AssertCount(r, mock, 1)
+	AssertSentTo(r, mock, "nobody")
+	AssertSent(r, mock, func(*message.Message) bool { return false })
+	if len(r.failures) != 4 {
+		t.Fatalf("failing assertions reported %d failures, want 4: %v", len(r.failures), r.failures)
+	}
+	for _, failure := range r.failures {
+		if !strings.Contains(failure, `"disk full"`) {
+			t.Errorf("failure %q does not list the delivered messages", failure)
+		}
+	}
+
+	msg := message.New()
+	msg.Title, msg.Targets = "async", []target.Target{Target("ops")}
+	if _, err := client.SendAsync(context.Background(), msg); err != nil {
+		t.Fatalf("SendAsync() error = %v", err)
+	}
+	WaitForMessages(t, mock, 4, 5*time.Second)
+	r = &recorder{}
+	WaitForMessages(r, mock, 5, 20*time.Millisecond)
+	if len(r.failures) != 1 {
+		t.Errorf("WaitForMessages() past the timeout reported %v", r.failures)
+	}
+
+	mock.SetHealth(errDown)
+	health, err := client.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+	if health.Platforms[DefaultPlatform] == "healthy" {
+		t.Errorf("Health() with a failing mock = %+v", health.Platforms)
+	}
+}
+
+func TestChaos(t *testing.T) {
+	targets := make([]target.Target, 100)
+	for i := range targets {
+		targets[i] = Target(fmt.Sprintf("user-%d", i))
+	}
+	errDown := errors.New("provider down")
+
+	tests := []struct {
+		name          string
+		cfg           ChaosConfig
+		retries       int
+		targets       []target.Target
+		wantDelivered func(ChaosStats) int
+		wantStats     func(ChaosStats) bool
+		wantError     string
+	}{
+		{
+			name: "failing sends are retried", cfg: ChaosConfig{FailureRate: 1, Err: errDown}, retries: 2, targets: targets[:1],
+			wantDelivered: func(ChaosStats) int { return 0 },
+			wantStats:     func(s ChaosStats) bool { return s.Sends == 3 && s.Failures == 3 },
+			wantError:     "provider down",
+		},
+		{
+			name: "panics are not retried", cfg: ChaosConfig{PanicRate: 1}, retries: 2, targets: targets[:1],
+			wantDelivered: func(ChaosStats) int { return 0 },
+			wantStats:     func(s ChaosStats) bool { return s.Sends == 1 && s.Panics == 1 },
+			wantError:     "panicked",
+		},
+		{
+			name: "partial failures", cfg: ChaosConfig{TargetFailureRate: 0.3}, targets: targets,
+			wantDelivered: func(s ChaosStats) int { return 100 - s.TargetFailures },
+			wantStats:     func(s ChaosStats) bool { return s.Sends == 100 && s.TargetFailures >= 10 && s.TargetFailures <= 50 },
+			wantError:     ErrChaos.Error(),
+		},
+		{
+			name: "latency past deadline", cfg: ChaosConfig{Latency: FixedLatency(time.Second)}, targets: targets[:1],
+			wantDelivered: func(ChaosStats) int { return 0 },
+			wantStats:     func(s ChaosStats) bool { return s.Sends == 1 },
+			wantError:     "context deadline exceeded",
+		},
+		{
+			name: "no chaos", cfg: ChaosConfig{Latency: UniformLatency(time.Millisecond, 2*time.Millisecond)}, targets: targets,
+			wantDelivered: func(ChaosStats) int { return 100 },
+			wantStats:     func(s ChaosStats) bool { return s == ChaosStats{Sends: 100} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Seed = 1
+			client, chaos := NewChaosClient(t, tt.cfg, config.WithSendDefaults(config.SendDefaults{MaxRetries: tt.retries}))
+			msg := message.New()
+			msg.Title, msg.Targets = tt.name, tt.targets
+			msg.SetTimeout(100 * time.Millisecond)
+			rcpt, err := client.Send(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			stats := chaos.Stats()
+			if !tt.wantStats(stats) {
+				t.Errorf("Stats() = %+v", stats)
+			}
+			if got, want := len(chaos.Messages()), tt.wantDelivered(stats); got != want {
+				t.Errorf("Messages() = %d, want %d", got, want)
+			}
+			if errs := strings.Join(rcpt.GetErrors(), "; "); !strings.Contains(errs, tt.wantError) || (tt.wantError == "" && errs != "") {
+				t.Errorf("receipt errors = %q, want %q", errs, tt.wantError)
+			}
+		})
+	}
+
+	// The same seed injects the same failures
+	failures := func() []bool {
+		chaos := NewChaos("chaos", ChaosConfig{TargetFailureRate: 0.5, Seed: 42})
+		if _, err := chaos.Send(context.Background(), message.New(), targets); err != nil {
+			t.Fatal(err)
+		}
+		var failed []bool
+		for _, sent := range chaos.Sent() {
+			failed = append(failed, sent.Err != nil)
+		}
+		return failed
+	}
+	if first, second := failures(), failures(); fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Error("chaos platforms with the same seed injected different failures")
+	}
+
+	for _, latency := range []Latency{NormalLatency(10*time.Millisecond, 50*time.Millisecond), TailLatency(FixedLatency(0), 0.5, time.Second)} {
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 100; i++ {
+			if d := latency(r); d < 0 || d > time.Second {
+				t.Fatalf("latency = %v, want between 0 and 1s", d)
+			}
+		}
+	}
+}
+
+// BenchmarkClient_SendFanOut measures the dispatch of a message to a
+// number of targets of the mock platform, reporting delivered messages
+func BenchmarkClient_SendFanOut(b *testing.B) {
+	for _, n := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("targets=%d", n), func(b *testing.B) {
+			client, mock := NewClient(b)
+			targets := make([]target.Target, n)
+			for i := range targets {
+				targets[i] = Target(fmt.Sprintf("user-%d", i))
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				msg := message.New()
+				msg.ID, msg.Title, msg.Body, msg.Targets = fmt.Sprintf("bench-%d", i), "Disk full", "db-1 is 95% full", targets
+				if _, err := client.Send(context.Background(), msg); err != nil {
+					b.Fatal(err)
+				}
+				if i%1000 == 999 {
+					mock.Reset()
+				}
+			}
+			b.ReportMetric(float64(b.N*n)/b.Elapsed().Seconds(), "msg/s")
+		})
+	}
+}
+
+// BenchmarkClient_SendBatch measures a batch of 10,000 messages, each to a
+// target of the mock platform, as the goroutine pool examples send
+func BenchmarkClient_SendBatch(b *testing.B) {
+	client, mock := NewClient(b)
+	msgs := make([]*message.Message, 10000)
+	for i := range msgs {
+		msgs[i] = message.New()
+		msgs[i].Title, msgs[i].Body = fmt.Sprintf("Report %d", i), "nightly report"
+		msgs[i].Targets = []target.Target{Target(fmt.Sprintf("user-%d", i%100))}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.SendBatch(context.Background(), msgs); err != nil {
+			b.Fatal(err)
+		}
+		mock.Reset()
+	}
+	b.ReportMetric(float64(b.N*len(msgs))/b.Elapsed().Seconds(), "msg/s")
+}
@@ -0,0 +1,162 @@
+// Package notifyhub provides the runtime controls of the NotifyHub client,
+// which pause platforms and drain queues without a restart
+package notifyhub
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// Queues of a client that DrainQueue drains besides the deliveries held
+// for a paused platform
+const (
+	QueueAsync = "async" // messages waiting for an asynchronous worker
+	QueueHeld  = "held"  // deliveries held for a delivery window or a deferring rate limit
+)
+
+// ControlStatus is the state of the runtime controls of a client
+type ControlStatus struct {
+	// PausedPlatforms holds the number of deliveries held for each paused
+	// platform
+	PausedPlatforms map[string]int `json:"paused_platforms"`
+
+	// Queues holds the number of items waiting in each queue
+	Queues map[string]int `json:"queues"`
+}
+
+// pausedPlatforms holds the deliveries of paused platforms until they are
+// resumed; guarded by clientImpl.pauseMu
+type pausedPlatforms map[string][]heldDelivery
+
+// PausePlatform stops the deliveries of a platform, holding them until
+// ResumePlatform, so that a flood can be stopped without a restart. Held
+// deliveries are lost when the client is closed.
+func (c *clientImpl) PausePlatform(name string) error {
+	if !c.isPlatformConfigured(name) {
+		return fmt.Errorf("platform %s is not configured", name)
+	}
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if _, ok := c.paused[name]; ok {
+		return nil
+	}
+	if c.paused == nil {
+		c.paused = make(pausedPlatforms)
+	}
+	c.paused[name] = nil
+	c.logger.Warn("Platform paused", "platform", name)
+	return nil
+}
+
+// ResumePlatform resumes the deliveries of a paused platform, releasing
+// the deliveries held while it was paused in the order they were held, and
+// returns how many it releases
+func (c *clientImpl) ResumePlatform(name string) (int, error) {
+	c.pauseMu.Lock()
+	held, ok := c.paused[name]
+	delete(c.paused, name)
+	c.pauseMu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("platform %s is not paused", name)
+	}
+
+	c.logger.Info("Platform resumed", "platform", name, "held_deliveries", len(held))
+	if len(held) > 0 {
+		go func() {
+			for _, d := range held {
+				c.releaseHeld(d)
+			}
+		}()
+	}
+	return len(held), nil
+}
+
+// DrainQueue removes the items waiting in a queue, QueueAsync or
+// QueueHeld, or the deliveries held for a paused platform, and returns
+// how many it removed. Drained asynchronous sends complete with
+// async.ErrDrained; drained deliveries are not sent.
+func (c *clientImpl) DrainQueue(name string) (int, error) {
+	var drained int
+	switch name {
+	case QueueAsync:
+		if c.asyncQueue != nil {
+			drained = c.asyncQueue.Drain()
+		}
+	case QueueHeld:
+		if c.holds != nil {
+			drained = c.holds.Drain()
+		}
+	default:
+		c.pauseMu.Lock()
+		held, ok := c.paused[name]
+		if ok {
+			c.paused[name] = nil
+		}
+		c.pauseMu.Unlock()
+		if !ok {
+			return 0, fmt.Errorf("unknown queue %q, expected %s, %s or a paused platform", name, QueueAsync, QueueHeld)
+		}
+		drained = len(held)
+	}
+	c.logger.Warn("Queue drained", "queue", name, "drained", drained)
+	return drained, nil
+}
+
+// Controls returns the state of the runtime controls
+func (c *clientImpl) Controls() ControlStatus {
+	status := ControlStatus{PausedPlatforms: make(map[string]int), Queues: make(map[string]int)}
+	c.pauseMu.Lock()
+	for name, held := range c.paused {
+		status.PausedPlatforms[name] = len(held)
+	}
+	c.pauseMu.Unlock()
+	if c.asyncQueue != nil {
+		status.Queues[QueueAsync] = c.asyncQueue.Len()
+	}
+	if c.holds != nil {
+		status.Queues[QueueHeld] = c.holds.Len()
+	}
+	return status
+}
+
+// pausedNames returns the sorted names of the paused platforms
+func (c *clientImpl) pausedNames() []string {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	names := make([]string, 0, len(c.paused))
+	for name := range c.paused {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// holdForPause holds the delivery of a target on a paused platform and
+// records a "held" result on the receipt
+func (c *clientImpl) holdForPause(msg *message.Message, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) bool {
+	c.pauseMu.Lock()
+	held, paused := c.paused[platformName]
+	if paused {
+		c.paused[platformName] = append(held, heldDelivery{msg: msg, platform: platformName, target: tgt})
+	}
+	c.pauseMu.Unlock()
+	if !paused {
+		return false
+	}
+
+	c.logger.Debug("Target held for paused platform", "platform", platformName)
+	receipt.AddResult(receiptpkg.PlatformResult{
+		Platform:  platformName,
+		Target:    tgt.Value,
+		Success:   false,
+		Status:    receiptpkg.ResultHeld,
+		Error:     fmt.Sprintf("held while platform %s is paused", platformName),
+		Timestamp: time.Now(),
+	})
+	return true
+}
@@ -0,0 +1,112 @@
+// Package notifyhub provides preflight checks of the configured platforms
+package notifyhub
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/platform"
+)
+
+// Checks a preflight runs on a platform
+const (
+	PreflightCheckCredentials = "credentials" // the platform verified its credentials and endpoints
+	PreflightCheckHealth      = "health"      // the platform has no preflight, its health check ran
+)
+
+// PreflightReport is the readiness of the platforms of a client
+type PreflightReport struct {
+	Ready     bool                       `json:"ready"` // every platform passed
+	Platforms map[string]PreflightResult `json:"platforms"`
+	CheckedAt time.Time                  `json:"checked_at"`
+	Duration  time.Duration              `json:"duration"`
+}
+
+// PreflightResult is the readiness of a platform
+type PreflightResult struct {
+	Ready    bool          `json:"ready"`
+	Check    string        `json:"check,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Failed returns the names of the platforms that are not ready, sorted
+func (r *PreflightReport) Failed() []string {
+	var failed []string
+	for name, result := range r.Platforms {
+		if !result.Ready {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// Preflight creates the instance of every configured platform and verifies
+// it, so that an application can check its credentials before it accepts
+// traffic:
+//
+//	report, err := client.(notifyhub.Preflighter).Preflight(ctx)
+//	if err != nil || !report.Ready {
+//		log.Fatalf("notification platforms not ready: %v", report.Failed())
+//	}
+//
+// Platforms implementing platform.Preflighter verify their credentials,
+// such as the SMTP login of email; the others run their health check. The
+// platforms are checked concurrently, each within the client timeout.
+func (c *clientImpl) Preflight(ctx context.Context) (*PreflightReport, error) {
+	platforms := c.acquirePlatforms()
+	defer platforms.inflight.Done()
+
+	start := time.Now()
+	report := &PreflightReport{Ready: true, Platforms: make(map[string]PreflightResult), CheckedAt: start}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range platforms.registry.ListPlatforms() {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			result := c.preflight(ctx, platforms.registry, name)
+			result.Error = platforms.scrubber.String(result.Error)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Platforms[name] = result
+			report.Ready = report.Ready && result.Ready
+		}(name)
+	}
+	wg.Wait()
+	report.Duration = time.Since(start)
+
+	if !report.Ready {
+		c.log(ctx).Warn("Preflight failed", "platforms", report.Failed())
+	}
+	return report, ctx.Err()
+}
+
+// preflight creates and verifies a platform instance
+func (c *clientImpl) preflight(ctx context.Context, registry platform.Registry, name string) PreflightResult {
+	start := time.Now()
+	p, err := registry.GetPlatform(name)
+	if err != nil {
+		return PreflightResult{Error: err.Error(), Duration: time.Since(start)}
+	}
+
+	result := PreflightResult{Check: PreflightCheckHealth}
+	if _, ok := p.(platform.Preflighter); ok {
+		result.Check = PreflightCheckCredentials
+	}
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+	if err := platform.Preflight(ctx, p); err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Ready = true
+	}
+	result.Duration = time.Since(start)
+	return result
+}
@@ -0,0 +1,90 @@
+// Package notifyhub provides the platform quotas of the NotifyHub client
+package notifyhub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// takeQuota takes a send from the quotas of a platform before it is
+// delivered. Under the wait policy it waits until the quota allows the send
+// or ctx is done; under the defer policy it holds the send until then.
+// Under the drop policy, and when ctx is done, it records the send as rate
+// limited and returns false. A send denied by one quota is refunded to the
+// quotas it was taken from, when the buckets support it. Quotas fail open
+// like the target rate limits when their buckets cannot be reached.
+func (c *clientImpl) takeQuota(ctx context.Context, msg *message.Message, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) bool {
+	if c.buckets == nil {
+		return true
+	}
+
+	var taken []ratelimit.Quota
+	for _, quota := range c.config.Quotas {
+		if quota.Platform != platformName {
+			continue
+		}
+		key := quota.Key(msg.Tenant())
+		allowed, retryAt, err := c.buckets.Take(ctx, key, quota)
+		if err != nil {
+			c.log(ctx).Warn("Failed to check platform quota", "platform", platformName, "error", err)
+			continue
+		}
+		if allowed {
+			taken = append(taken, quota)
+			continue
+		}
+
+		reason := fmt.Sprintf("platform quota of %d per %s reached", quota.Rate, quota.Per)
+		result := receiptpkg.PlatformResult{
+			Platform: platformName,
+			Target:   tgt.Value,
+			Success:  false,
+			Status:   receiptpkg.ResultRateLimited,
+		}
+		switch quota.Policy {
+		case "", ratelimit.PolicyWait:
+			c.log(ctx).Debug("Waiting for platform quota", "platform", platformName, "retry_at", retryAt)
+			allowed, reason = c.waitRateLimit(ctx, retryAt, reason, func() (bool, time.Time, error) {
+				return c.buckets.Take(ctx, key, quota)
+			})
+			if allowed {
+				taken = append(taken, quota)
+				continue
+			}
+		case ratelimit.PolicyDefer:
+			// The target checks are done, only the quota is taken again
+			c.holds.Add(heldDelivery{msg: msg, platform: platformName, target: tgt, until: retryAt, quota: true})
+			result.Status = receiptpkg.ResultHeld
+			result.HeldUntil = &retryAt
+			reason += ", deferred"
+		}
+
+		c.refundQuotas(ctx, msg, taken)
+		c.log(ctx).Debug("Platform quota exceeded", "platform", platformName, "policy", quota.Policy)
+		result.Error = reason
+		result.Timestamp = time.Now()
+		receipt.AddResult(result)
+		return false
+	}
+	return true
+}
+
+// refundQuotas returns a denied send to the quotas it was taken from
+func (c *clientImpl) refundQuotas(ctx context.Context, msg *message.Message, quotas []ratelimit.Quota) {
+	refunder, ok := c.buckets.(ratelimit.TokenRefunder)
+	if !ok {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	for _, quota := range quotas {
+		if err := refunder.Refund(ctx, quota.Key(msg.Tenant()), quota); err != nil {
+			c.log(ctx).Warn("Failed to refund platform quota", "platform", quota.Platform, "error", err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Package notifyhub provides the saving of in-memory rate limit state
+package notifyhub
+
+import (
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// defaultRateLimitStateInterval is how often the rate limit state is saved
+// when no interval is configured
+const defaultRateLimitStateInterval = time.Minute
+
+// rateLimitState saves the in-memory quota and rate limit state of a
+// client to a file, so that a restart does not reset the budgets spent
+type rateLimitState struct {
+	file    string
+	buckets *ratelimit.MemoryTokenBuckets
+	limiter *ratelimit.MemoryLimiter
+	logger  logger.Logger
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// startRateLimitState restores the saved state into the in-memory limiter
+// and token buckets of a client and saves it every interval. It returns
+// nil when no state file is configured or no state is kept in memory.
+func startRateLimitState(cfg config.RateLimitStateConfig, limiter ratelimit.Limiter, buckets ratelimit.TokenBuckets, log logger.Logger) *rateLimitState {
+	if cfg.File == "" {
+		return nil
+	}
+	s := &rateLimitState{file: cfg.File, logger: log, stop: make(chan struct{}), done: make(chan struct{})}
+	s.buckets, _ = buckets.(*ratelimit.MemoryTokenBuckets)
+	s.limiter, _ = limiter.(*ratelimit.MemoryLimiter)
+	if s.buckets == nil && s.limiter == nil {
+		return nil
+	}
+
+	if err := ratelimit.LoadState(s.file, s.buckets, s.limiter); err != nil {
+		// Starting with full budgets beats not starting
+		log.Warn("Failed to restore rate limit state", "file", s.file, "error", err)
+	} else {
+		log.Info("Rate limit state restored", "file", s.file)
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = defaultRateLimitStateInterval
+	}
+	go s.run(interval)
+	return s
+}
+
+// run saves the state every interval until the state is closed
+func (s *rateLimitState) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.save(); err != nil {
+				s.logger.Warn("Failed to save rate limit state", "file", s.file, "error", err)
+			}
+		}
+	}
+}
+
+// save writes the state to the file
+func (s *rateLimitState) save() error {
+	return ratelimit.SaveState(s.file, s.buckets, s.limiter)
+}
+
+// Close stops the periodic saving and saves the state a last time
+func (s *rateLimitState) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.save()
+}
@@ -0,0 +1,45 @@
+// Package notifyhub provides the isolation of panics in platform senders
+package notifyhub
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// safeSend calls the Send of a platform, turning a panic into a failed
+// result for each target with an ErrPlatformPanic error, so that a faulty
+// sender cannot take down the caller or an async worker
+func (c *clientImpl) safeSend(ctx context.Context, p platform.Platform, name string, msg *message.Message, targets []target.Target) (results []*platform.SendResult, err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		c.platformPanics.Add(1)
+		scrubber := c.currentPlatforms().scrubber
+		c.log(ctx).Error("Platform send panicked", "platform", name, "message_id", msg.ID, "panic", scrubber.String(fmt.Sprint(recovered)), "stack", string(debug.Stack()))
+		panicErr := &errors.NotifyError{
+			Code:     errors.ErrPlatformPanic,
+			Message:  fmt.Sprintf("platform send panicked: %v", recovered),
+			Platform: name,
+		}
+		results = make([]*platform.SendResult, 0, len(targets))
+		for _, tgt := range targets {
+			results = append(results, &platform.SendResult{Target: tgt, Success: false, Error: panicErr})
+		}
+		err = nil
+	}()
+	return p.Send(ctx, msg, targets)
+}
+
+// isPlatformPanic reports whether a send error was recovered from a panic
+func isPlatformPanic(err error) bool {
+	notifyErr, ok := err.(*errors.NotifyError)
+	return ok && notifyErr.Code == errors.ErrPlatformPanic
+}
@@ -0,0 +1,312 @@
+// Package notifyhub provides failover between platform endpoints in
+// different regions
+package notifyhub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// withRegions wraps the factory of a platform with alternative regions,
+// so that it creates one instance per region
+func withRegions(name string, factory platform.Factory, regions []config.Region, failover config.RegionFailoverConfig, logger logger.Logger) platform.Factory {
+	if len(regions) == 0 {
+		return factory
+	}
+	threshold, cooldown := failover.FailureThreshold, failover.Cooldown
+	if threshold <= 0 {
+		threshold = config.DefaultRegionFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = config.DefaultRegionCooldown
+	}
+	return func(section interface{}) (platform.Platform, error) {
+		primary, err := factory(section)
+		if err != nil {
+			return nil, err
+		}
+		p := &regionPlatform{
+			name:      name,
+			threshold: threshold,
+			cooldown:  cooldown,
+			logger:    logger,
+			now:       time.Now,
+		}
+		p.regions = append(p.regions, &regionInstance{Region: config.Region{Name: config.PrimaryRegion}, platform: primary})
+
+		for _, region := range regions {
+			settings, err := region.Apply(section)
+			var instance platform.Platform
+			if err == nil {
+				instance, err = factory(settings)
+			}
+			if err != nil {
+				_ = p.Close()
+				return nil, fmt.Errorf("region %s: %w", region.Name, err)
+			}
+			p.regions = append(p.regions, &regionInstance{Region: region, platform: instance})
+		}
+		return p, nil
+	}
+}
+
+// regionInstance is a platform instance sending through the endpoint of
+// one region
+type regionInstance struct {
+	config.Region
+	platform platform.Platform
+
+	// failures counts the consecutive failed sends of the region, which is
+	// skipped until downUntil; guarded by regionPlatform.mu
+	failures  int
+	downUntil time.Time
+}
+
+// regionPlatform sends each target through its home region (see
+// config.Region), and fails over to the other regions when a region
+// fails. Regions that failed threshold consecutive sends, or their health
+// check, are tried last until the cooldown passes.
+type regionPlatform struct {
+	name      string
+	regions   []*regionInstance // the section's own endpoint first
+	threshold int
+	cooldown  time.Duration
+	logger    logger.Logger
+	now       func() time.Time
+
+	mu sync.Mutex
+}
+
+// home returns the region a target has affinity to
+func (p *regionPlatform) home(t target.Target) *regionInstance {
+	for _, region := range p.regions[1:] {
+		if region.Matches(t) {
+			return region
+		}
+	}
+	return p.regions[0]
+}
+
+// order returns the regions to try for the targets of a home region: the
+// home region, then the others in order, with the regions that are down
+// last
+func (p *regionPlatform) order(home *regionInstance) []*regionInstance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	candidates := []*regionInstance{home}
+	for _, region := range p.regions {
+		if region != home {
+			candidates = append(candidates, region)
+		}
+	}
+	now := p.now()
+	var up, down []*regionInstance
+	for _, region := range candidates {
+		if now.Before(region.downUntil) {
+			down = append(down, region)
+		} else {
+			up = append(up, region)
+		}
+	}
+	return append(up, down...)
+}
+
+// record records the outcome of a send through a region, taking the
+// region down after threshold consecutive failures
+func (p *regionPlatform) record(region *regionInstance, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		if region.failures >= p.threshold {
+			p.logger.Info("Platform region recovered", "platform", p.name, "region", region.Name)
+		}
+		region.failures, region.downUntil = 0, time.Time{}
+		return
+	}
+	region.failures++
+	if region.failures >= p.threshold {
+		region.downUntil = p.now().Add(p.cooldown)
+		p.logger.Warn("Platform region down", "platform", p.name, "region", region.Name, "failures", region.failures, "cooldown", p.cooldown, "error", err)
+	}
+}
+
+// Name implements platform.Platform
+func (p *regionPlatform) Name() string {
+	return p.regions[0].platform.Name()
+}
+
+// GetCapabilities implements platform.Platform
+func (p *regionPlatform) GetCapabilities() platform.Capabilities {
+	return p.regions[0].platform.GetCapabilities()
+}
+
+// ValidateTarget implements platform.Platform
+func (p *regionPlatform) ValidateTarget(tgt target.Target) error {
+	return p.regions[0].platform.ValidateTarget(tgt)
+}
+
+// Send implements platform.Platform, sending the targets of each home
+// region separately
+func (p *regionPlatform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	var homes []*regionInstance
+	groups := make(map[*regionInstance][]target.Target)
+	for _, tgt := range targets {
+		home := p.home(tgt)
+		if _, ok := groups[home]; !ok {
+			homes = append(homes, home)
+		}
+		groups[home] = append(groups[home], tgt)
+	}
+	if len(homes) == 1 {
+		return p.sendFrom(ctx, homes[0], msg, targets)
+	}
+
+	// The error of one group must not fail the targets of the others
+	var results []*platform.SendResult
+	for _, home := range homes {
+		groupResults, err := p.sendFrom(ctx, home, msg, groups[home])
+		if err != nil {
+			groupResults = failedResults(groups[home], err)
+		}
+		results = append(results, groupResults...)
+	}
+	return results, nil
+}
+
+// sendFrom sends targets through their home region, and the targets whose
+// send failed transiently through the next regions
+func (p *regionPlatform) sendFrom(ctx context.Context, home *regionInstance, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	var delivered []*platform.SendResult
+	pending := targets
+	regions := p.order(home)
+	for i, region := range regions {
+		if i > 0 {
+			p.logger.Info("Failing over to platform region", "platform", p.name, "region", region.Name, "previous", regions[i-1].Name, "targets", len(pending))
+		}
+		results, err := region.platform.Send(ctx, msg, pending)
+		kept, retry := splitRegionFailures(pending, results, err)
+		if len(retry) == 0 {
+			p.record(region, nil)
+			return mergeResults(delivered, pending, results, err)
+		}
+
+		p.record(region, regionFailure(results, err))
+		if i == len(regions)-1 || ctx.Err() != nil {
+			return mergeResults(delivered, pending, results, err)
+		}
+		delivered = append(delivered, kept...)
+		pending = retry
+	}
+	return delivered, nil
+}
+
+// mergeResults adds the outcome of the last send of pending targets to the
+// results of the targets delivered before, as the error of that send must
+// not fail them
+func mergeResults(delivered []*platform.SendResult, pending []target.Target, results []*platform.SendResult, err error) ([]*platform.SendResult, error) {
+	if err != nil && len(delivered) > 0 {
+		return append(delivered, failedResults(pending, err)...), nil
+	}
+	return append(delivered, results...), err
+}
+
+// splitRegionFailures separates the results of a send from the targets
+// whose send failed for a reason another region may not have, that is
+// for any reason but an unreachable target or a panic of the sender
+func splitRegionFailures(targets []target.Target, results []*platform.SendResult, err error) ([]*platform.SendResult, []target.Target) {
+	if err != nil {
+		if platform.IsTargetInvalid(err) {
+			return nil, nil
+		}
+		return nil, targets
+	}
+	var kept []*platform.SendResult
+	var retry []target.Target
+	for _, result := range results {
+		if result != nil && !result.Success && !platform.IsTargetInvalid(result.Error) && !isPlatformPanic(result.Error) {
+			retry = append(retry, result.Target)
+		} else {
+			kept = append(kept, result)
+		}
+	}
+	return kept, retry
+}
+
+// regionFailure returns the failure of a send through a region
+func regionFailure(results []*platform.SendResult, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result != nil && !result.Success && result.Error != nil {
+			return result.Error
+		}
+	}
+	return errors.New("send failed")
+}
+
+// failedResults returns the results of targets whose send failed as a whole
+func failedResults(targets []target.Target, err error) []*platform.SendResult {
+	results := make([]*platform.SendResult, len(targets))
+	for i, tgt := range targets {
+		results[i] = &platform.SendResult{Target: tgt, Error: err}
+	}
+	return results
+}
+
+// IsHealthy implements platform.Platform, taking down the regions whose
+// health check fails; the platform is healthy while one region is
+func (p *regionPlatform) IsHealthy(ctx context.Context) error {
+	var firstErr error
+	healthy := false
+	for _, region := range p.regions {
+		err := region.platform.IsHealthy(ctx)
+		if err != nil {
+			p.mu.Lock()
+			region.failures = p.threshold
+			region.downUntil = p.now().Add(p.cooldown)
+			p.mu.Unlock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("region %s: %w", region.Name, err)
+			}
+			continue
+		}
+		healthy = true
+	}
+	if healthy {
+		return nil
+	}
+	return firstErr
+}
+
+// Preflight implements platform.Preflighter for every region, so that a
+// failover does not switch to an endpoint that was never verified
+func (p *regionPlatform) Preflight(ctx context.Context) error {
+	for _, region := range p.regions {
+		if err := platform.Preflight(ctx, region.platform); err != nil {
+			return fmt.Errorf("region %s: %w", region.Name, err)
+		}
+	}
+	return nil
+}
+
+// Close implements platform.Platform
+func (p *regionPlatform) Close() error {
+	var lastErr error
+	for _, region := range p.regions {
+		if err := region.platform.Close(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
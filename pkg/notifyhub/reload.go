@@ -0,0 +1,300 @@
+// Package notifyhub provides runtime reloading of platform configuration
+package notifyhub
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/redact"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// platformSet is one generation of platform senders, built from the
+// platform sections of a configuration. Sends hold the set they started
+// with, so a reload never changes a platform under an in-flight send.
+type platformSet struct {
+	registry platform.Registry
+	source   *config.Config // as configured, with secret references
+	config   *config.Config // with secret references resolved
+	external map[string]externalPlatform
+	version  string
+	scrubber *redact.Redactor // removes the credentials of config from errors and logs
+	inflight sync.WaitGroup
+}
+
+// newPlatformSet registers the platforms configured in cfg, resolving the
+// secret references in their settings, and the external platforms that
+// have a configuration
+func newPlatformSet(cfg *config.Config, external map[string]externalPlatform, logger logger.Logger) (*platformSet, error) {
+	source := cfg
+	if cfg.Secrets != nil {
+		var err error
+		if cfg, err = resolveSecrets(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Providers sometimes echo credentials back in their errors, so the
+	// errors and logs of the platforms are always scrubbed of them
+	scrubber := redact.NewScrubber(cfg.CredentialValues()...)
+	logger = scrubber.Logger(logger)
+
+	registry := platform.NewRegistry(logger)
+
+	if err := registerPlatformFactories(registry, cfg, logger); err != nil {
+		return nil, fmt.Errorf("failed to register platform factories: %w", err)
+	}
+	if err := setPlatformConfigurations(registry, cfg); err != nil {
+		return nil, fmt.Errorf("failed to set platform configurations: %w", err)
+	}
+	for name, ext := range external {
+		if ext.config == nil {
+			continue
+		}
+		if err := registry.RegisterFactory(name, withDryRun(name, ext.factory, cfg, logger)); err != nil {
+			return nil, err
+		}
+		if err := registry.SetConfig(name, ext.config); err != nil {
+			return nil, err
+		}
+	}
+
+	version, err := configVersion(cfg, external)
+	if err != nil {
+		return nil, err
+	}
+	return &platformSet{registry: registry, source: source, config: cfg, external: external, version: version, scrubber: scrubber}, nil
+}
+
+// resolveSecrets returns a copy of cfg whose platform settings have their
+// secret references replaced by the secrets
+func resolveSecrets(cfg *config.Config) (*config.Config, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resolved := *cfg
+	if cfg.Feishu != nil {
+		feishu := *cfg.Feishu
+		feishu.Proxy = copyProxy(feishu.Proxy)
+		resolved.Feishu = &feishu
+	}
+	if cfg.Email != nil {
+		email := *cfg.Email
+		resolved.Email = &email
+	}
+	if cfg.Webhook != nil {
+		webhook := *cfg.Webhook
+		webhook.Proxy = copyProxy(webhook.Proxy)
+		resolved.Webhook = &webhook
+	}
+	if cfg.Slack != nil {
+		slack := *cfg.Slack
+		slack.Proxy = copyProxy(slack.Proxy)
+		resolved.Slack = &slack
+	}
+
+	resolved.Proxy = copyProxy(cfg.Proxy)
+
+	for _, section := range []interface{}{resolved.Feishu, resolved.Email, resolved.Webhook, resolved.Slack, resolved.Proxy} {
+		if reflect.ValueOf(section).IsNil() {
+			continue
+		}
+		if err := cfg.Secrets.ResolveFields(ctx, section); err != nil {
+			return nil, fmt.Errorf("failed to resolve platform secrets: %w", err)
+		}
+	}
+	if len(cfg.Plugins) > 0 {
+		resolved.Plugins = make(map[string]config.Plugin, len(cfg.Plugins))
+		for name, p := range cfg.Plugins {
+			settings := make(map[string]string, len(p.Settings))
+			for key, value := range p.Settings {
+				settings[key] = value
+			}
+			p.Settings = settings
+			if err := cfg.Secrets.ResolveFields(ctx, &p); err != nil {
+				return nil, fmt.Errorf("failed to resolve plugin %s secrets: %w", name, err)
+			}
+			resolved.Plugins[name] = p
+		}
+	}
+	if len(cfg.Credentials) > 0 {
+		resolved.Credentials = make(map[string][]config.Credential, len(cfg.Credentials))
+		for name, credentials := range cfg.Credentials {
+			copied := make([]config.Credential, len(credentials))
+			for i, cred := range credentials {
+				if err := cfg.Secrets.ResolveFields(ctx, &cred); err != nil {
+					return nil, fmt.Errorf("failed to resolve %s credentials %s: %w", name, cred.Name, err)
+				}
+				copied[i] = cred
+			}
+			resolved.Credentials[name] = copied
+		}
+	}
+	if err := resolved.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration after resolving secrets: %w", err)
+	}
+	return &resolved, nil
+}
+
+// copyProxy copies proxy settings, so their secrets can be resolved
+// without changing the original
+func copyProxy(proxy *config.ProxyConfig) *config.ProxyConfig {
+	if proxy == nil {
+		return nil
+	}
+	copied := *proxy
+	return &copied
+}
+
+// configVersion stamps the platform sections of a configuration, their
+// alternative credentials, the plugins and the external platform
+// configurations with a short content hash, so logs and health output show
+// which credentials and settings are in use without revealing them
+func configVersion(cfg *config.Config, external map[string]externalPlatform) (string, error) {
+	sections, err := json.Marshal(struct {
+		Feishu      *config.FeishuConfig
+		Email       *config.EmailConfig
+		Webhook     *config.WebhookConfig
+		Slack       *config.SlackConfig
+		Proxy       *config.ProxyConfig
+		Credentials map[string][]config.Credential
+		Plugins     map[string]config.Plugin
+	}{cfg.Feishu, cfg.Email, cfg.Webhook, cfg.Slack, cfg.Proxy, cfg.Credentials, cfg.Plugins})
+	if err != nil {
+		return "", fmt.Errorf("failed to compute configuration version: %w", err)
+	}
+	hash := sha256.New()
+	hash.Write(sections)
+	for _, name := range sortedPlatforms(external) {
+		// External configurations may not be JSON encodable
+		fmt.Fprintf(hash, "%s=%+v;", name, external[name].config)
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:6]), nil
+}
+
+// acquirePlatforms returns the current platform set for a send; the caller
+// must call inflight.Done on it when the send completes
+func (c *clientImpl) acquirePlatforms() *platformSet {
+	c.platformsMu.RLock()
+	defer c.platformsMu.RUnlock()
+	c.platforms.inflight.Add(1)
+	return c.platforms
+}
+
+// currentPlatforms returns the current platform set
+func (c *clientImpl) currentPlatforms() *platformSet {
+	c.platformsMu.RLock()
+	defer c.platformsMu.RUnlock()
+	return c.platforms
+}
+
+// isPlatformConfigured reports whether a platform is currently configured
+func (c *clientImpl) isPlatformConfigured(name string) bool {
+	for _, registered := range c.currentPlatforms().registry.ListPlatforms() {
+		if registered == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadConfig replaces the platform credentials and settings (the Feishu,
+// Email, Webhook and Slack sections and the plugins of cfg) at runtime. The new senders are
+// created before the switch, so an invalid configuration is rejected and
+// the running one stays in place. Sends already in flight finish on the
+// previous senders, which are closed once they complete. Other settings of
+// cfg are ignored; they require a new client.
+func (c *clientImpl) ReloadConfig(cfg *config.Config) error {
+	if cfg == nil {
+		return fmt.Errorf("configuration cannot be nil")
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if cfg.Secrets == nil && c.config.Secrets != nil {
+		withSecrets := *cfg
+		withSecrets.Secrets = c.config.Secrets
+		cfg = &withSecrets
+	}
+
+	c.reconfigureMu.Lock()
+	defer c.reconfigureMu.Unlock()
+
+	next, err := newPlatformSet(cfg, c.currentPlatforms().external, c.logger)
+	if err != nil {
+		return err
+	}
+	return c.swapPlatforms(next, nil)
+}
+
+// swapPlatforms creates the senders of a platform set and makes it current.
+// When replace is set, the swap only happens if replace is still current.
+// The previous set is closed once the sends using it complete.
+func (c *clientImpl) swapPlatforms(next, replace *platformSet) error {
+	for _, name := range next.registry.ListPlatforms() {
+		if _, err := next.registry.GetPlatform(name); err != nil {
+			_ = next.registry.Close()
+			c.logger.Error("Rejected platform configuration", "config_version", next.version, "error", err)
+			return err
+		}
+	}
+
+	c.platformsMu.Lock()
+	previous := c.platforms
+	if replace != nil && previous != replace {
+		c.platformsMu.Unlock()
+		return next.registry.Close()
+	}
+	c.platforms = next
+	c.platformsMu.Unlock()
+
+	c.logger.Info("Platform configuration updated", "config_version", next.version, "previous_version", previous.version, "platforms", len(next.registry.ListPlatforms()))
+
+	go func() {
+		previous.inflight.Wait()
+		if err := previous.registry.Close(); err != nil {
+			c.logger.Error("Failed to close previous platforms", "config_version", previous.version, "error", err)
+		}
+	}()
+	return nil
+}
+
+// refreshSecrets resolves the secret references of the platform settings
+// every interval and switches to new senders when a secret has rotated
+func (c *clientImpl) refreshSecrets(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := c.currentPlatforms()
+		next, err := newPlatformSet(current.source, current.external, c.logger)
+		if err != nil {
+			c.logger.Warn("Failed to refresh platform secrets, keeping current ones", "config_version", current.version, "error", err)
+			continue
+		}
+		if next.version == current.version {
+			continue
+		}
+		if err := c.swapPlatforms(next, current); err != nil {
+			c.logger.Warn("Rotated platform secrets were rejected, keeping current ones", "config_version", current.version, "error", err)
+		}
+	}
+}
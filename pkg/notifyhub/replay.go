@@ -0,0 +1,339 @@
+// Package notifyhub provides the replay of dead letters and archived
+// messages
+package notifyhub
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/archive"
+	"github.com/kart-io/notifyhub/pkg/message"
+	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/secret"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// Statuses of a replayed message
+const (
+	ReplaySent      = "sent"      // sent again
+	ReplayFailed    = "failed"    // sent again and failed, and may be replayed again
+	ReplayDuplicate = "duplicate" // not sent, as it was replayed before
+	ReplayPreview   = "preview"   // would be sent, in a dry run
+)
+
+// ReplayFilter selects the messages a replay sends again; empty fields
+// select every message
+type ReplayFilter struct {
+	From   time.Time `json:"from,omitempty"`   // messages that failed or completed at or after
+	To     time.Time `json:"to,omitempty"`     // messages that failed or completed before
+	Tenant string    `json:"tenant,omitempty"` // messages of the tenant
+
+	// FailedOnly selects the messages whose receipt failed or partially
+	// failed; dead letters always failed
+	FailedOnly bool `json:"failed_only,omitempty"`
+}
+
+// Match reports whether the filter selects a record
+func (f ReplayFilter) Match(r ReplayRecord) bool {
+	switch {
+	case r.Message == nil:
+		return false
+	case !f.From.IsZero() && r.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !r.Time.Before(f.To):
+		return false
+	case f.Tenant != "" && r.Message.Tenant() != f.Tenant:
+		return false
+	case f.FailedOnly && r.Receipt != nil && !r.Receipt.IsFailed() && !r.Receipt.IsPartial():
+		return false
+	}
+	return true
+}
+
+// ReplayRecord is a message a replay may send again, with its receipt
+type ReplayRecord struct {
+	Message *message.Message    `json:"message"`
+	Receipt *receiptpkg.Receipt `json:"receipt,omitempty"`
+	Time    time.Time           `json:"time"` // when the message failed or completed
+}
+
+// ReplaySource provides the messages a replay selects, in order
+type ReplaySource interface {
+	Records(ctx context.Context, filter ReplayFilter) ([]ReplayRecord, error)
+}
+
+// ArchiveSource reads the messages of an archive, see package archive.
+// Prefix is the Prefix of the archive configuration.
+type ArchiveSource struct {
+	Reader archive.Reader
+	Prefix string
+}
+
+// Records implements ReplaySource
+func (s ArchiveSource) Records(ctx context.Context, filter ReplayFilter) ([]ReplayRecord, error) {
+	archived, err := archive.Query(ctx, s.Reader, s.Prefix, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	var records []ReplayRecord
+	for _, a := range archived {
+		r := ReplayRecord{Message: a.Message, Receipt: a.Receipt, Time: a.Time()}
+		if filter.Match(r) {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// DeadLetterSource reads the dead letters written by the send command of
+// the CLI with --dead-letter, decrypting them with Cipher when they were
+// encrypted
+type DeadLetterSource struct {
+	Path   string
+	Cipher *secret.PayloadCipher
+}
+
+// Records implements ReplaySource
+func (s DeadLetterSource) Records(ctx context.Context, filter ReplayFilter) ([]ReplayRecord, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []ReplayRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if secret.IsSealed(data) {
+			if s.Cipher == nil {
+				return nil, fmt.Errorf("%s:%d: %w", s.Path, line, secret.ErrPayloadEncrypted)
+			}
+			if data, err = s.Cipher.Open(ctx, data); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", s.Path, line, err)
+			}
+		}
+		var letter struct {
+			Message  *message.Message    `json:"message"`
+			Receipt  *receiptpkg.Receipt `json:"receipt"`
+			FailedAt time.Time           `json:"failed_at"`
+		}
+		if err := json.Unmarshal(data, &letter); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.Path, line, err)
+		}
+		r := ReplayRecord{Message: letter.Message, Receipt: letter.Receipt, Time: letter.FailedAt}
+		if filter.Match(r) {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// IdempotencyStore remembers the idempotency keys of replayed messages, so
+// that a message is sent again once however many replays select it
+type IdempotencyStore interface {
+	// Claim records a key, reporting false when it was recorded before
+	Claim(ctx context.Context, key string) (bool, error)
+
+	// Release forgets a key, so that a message whose replay failed can be
+	// replayed again
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore implements IdempotencyStore in memory, for a
+// single client
+type MemoryIdempotencyStore struct {
+	keys map[string]bool
+	mu   sync.Mutex
+}
+
+// NewMemoryIdempotencyStore creates an in-memory idempotency store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{keys: make(map[string]bool)}
+}
+
+// Claim implements IdempotencyStore
+func (m *MemoryIdempotencyStore) Claim(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.keys[key] {
+		return false, nil
+	}
+	m.keys[key] = true
+	return true, nil
+}
+
+// Release implements IdempotencyStore
+func (m *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, key)
+	return nil
+}
+
+// ReplayOptions configures a replay
+type ReplayOptions struct {
+	// Rate is the number of messages sent per second; zero sends them as
+	// fast as the client does
+	Rate float64
+
+	// DryRun reports the messages that would be sent without sending them
+	DryRun bool
+
+	// Keys remembers the replayed messages; nil uses a store of the
+	// client, which forgets them when it is closed
+	Keys IdempotencyStore
+}
+
+// ReplayedMessage is the outcome of the replay of a message
+type ReplayedMessage struct {
+	ID      string              `json:"id"`
+	Title   string              `json:"title,omitempty"`
+	Key     string              `json:"idempotency_key"`
+	Status  string              `json:"status"`
+	Targets int                 `json:"targets"`
+	Error   string              `json:"error,omitempty"`
+	Receipt *receiptpkg.Receipt `json:"receipt,omitempty"`
+}
+
+// ReplayReport is the outcome of a replay
+type ReplayReport struct {
+	DryRun     bool              `json:"dry_run,omitempty"`
+	Matched    int               `json:"matched"`
+	Sent       int               `json:"sent"`
+	Failed     int               `json:"failed"`
+	Duplicates int               `json:"duplicates"`
+	Messages   []ReplayedMessage `json:"messages"`
+}
+
+// Replay sends again the messages of a source that a filter selects, such
+// as the dead letters of an outage or the archived messages of a time
+// range, in order and throttled to opts.Rate. Each message carries its
+// idempotency key in its metadata, the key it had or its ID and creation
+// time, and is skipped when the key was replayed before. Only the failed
+// targets of partially failed messages are sent again.
+//
+// Replay stops when the context ends, returning the report so far with
+// the error of the context.
+func (c *clientImpl) Replay(ctx context.Context, source ReplaySource, filter ReplayFilter, opts ReplayOptions) (*ReplayReport, error) {
+	records, err := source.Records(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replay source: %w", err)
+	}
+	keys := opts.Keys
+	if keys == nil {
+		keys = c.replayKeys
+	}
+	var interval time.Duration
+	if opts.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / opts.Rate)
+	}
+
+	report := &ReplayReport{DryRun: opts.DryRun, Matched: len(records)}
+	var last time.Time
+	for _, record := range records {
+		msg := replayMessage(record)
+		replayed := ReplayedMessage{ID: msg.ID, Title: msg.Title, Key: replayKey(record.Message), Targets: len(msg.Targets)}
+		msg.SetMetadata(message.MetadataIdempotencyKey, replayed.Key)
+
+		claimed, err := keys.Claim(ctx, replayed.Key)
+		if err != nil {
+			return report, fmt.Errorf("failed to claim idempotency key %s: %w", replayed.Key, err)
+		}
+		if !claimed {
+			replayed.Status = ReplayDuplicate
+			report.Duplicates++
+			report.Messages = append(report.Messages, replayed)
+			continue
+		}
+		if opts.DryRun {
+			if err := keys.Release(ctx, replayed.Key); err != nil {
+				return report, fmt.Errorf("failed to release idempotency key %s: %w", replayed.Key, err)
+			}
+			replayed.Status = ReplayPreview
+			report.Messages = append(report.Messages, replayed)
+			continue
+		}
+
+		if wait := time.Until(last.Add(interval)); !last.IsZero() && wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				_ = keys.Release(ctx, replayed.Key)
+				return report, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		last = time.Now()
+
+		rcpt, err := c.Send(ctx, msg)
+		replayed.Receipt = rcpt
+		if err == nil && rcpt != nil && rcpt.IsFailed() {
+			err = errors.New(strings.Join(rcpt.GetErrors(), "; "))
+		}
+		if err != nil {
+			replayed.Status, replayed.Error = ReplayFailed, err.Error()
+			report.Failed++
+			if err := keys.Release(context.Background(), replayed.Key); err != nil {
+				c.log(ctx).Warn("Failed to release idempotency key", "key", replayed.Key, "error", err)
+			}
+		} else {
+			replayed.Status = ReplaySent
+			report.Sent++
+		}
+		report.Messages = append(report.Messages, replayed)
+	}
+
+	c.log(ctx).Info("Replay completed", "matched", report.Matched, "sent", report.Sent, "failed", report.Failed, "duplicates", report.Duplicates, "dry_run", opts.DryRun)
+	return report, nil
+}
+
+// replayMessage returns a copy of the message of a record to send again,
+// without the targets its receipt reports delivered
+func replayMessage(r ReplayRecord) *message.Message {
+	msg := r.Message.Clone()
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]interface{})
+	}
+	if r.Receipt == nil || !r.Receipt.IsPartial() {
+		return msg
+	}
+	delivered := make(map[string]bool)
+	for _, result := range r.Receipt.Results {
+		if result.Success {
+			delivered[result.Target] = true
+		}
+	}
+	var pending []target.Target
+	for _, tgt := range msg.Targets {
+		if !delivered[tgt.Value] {
+			pending = append(pending, tgt)
+		}
+	}
+	if len(pending) > 0 {
+		msg.Targets = pending
+	}
+	return msg
+}
+
+// replayKey returns the idempotency key of a message: the key it carries,
+// or its ID and creation time, as IDs may be reused
+func replayKey(msg *message.Message) string {
+	if key, ok := msg.Metadata[message.MetadataIdempotencyKey].(string); ok && key != "" {
+		return key
+	}
+	return msg.ID + "@" + msg.CreatedAt.UTC().Format(time.RFC3339Nano)
+}
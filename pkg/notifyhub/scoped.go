@@ -0,0 +1,153 @@
+// Package notifyhub provides scoped clients with default message settings
+package notifyhub
+
+import (
+	"context"
+	"iter"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/async"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+)
+
+// Default sets a default on a message sent through a scoped client. It
+// applies to a copy of the message and does not override what the message
+// sets itself.
+type Default func(*message.Message)
+
+// DefaultMetadata sets a metadata value on messages that do not set the key,
+// e.g. DefaultMetadata("team", "payments")
+func DefaultMetadata(key string, value interface{}) Default {
+	return func(msg *message.Message) {
+		if _, ok := msg.Metadata[key]; !ok {
+			msg.SetMetadata(key, value)
+		}
+	}
+}
+
+// DefaultTags adds tags to those of the messages, see message.SetTags
+func DefaultTags(tags ...string) Default {
+	return func(msg *message.Message) {
+		merged := append([]string(nil), tags...)
+		for _, tag := range msg.Tags() {
+			if !containsString(merged, tag) {
+				merged = append(merged, tag)
+			}
+		}
+		msg.SetTags(merged...)
+	}
+}
+
+// DefaultPriority sets the priority of messages left at the normal priority
+// that message.New gives them
+func DefaultPriority(priority message.Priority) Default {
+	return func(msg *message.Message) {
+		if msg.Priority == message.PriorityNormal {
+			msg.Priority = priority
+		}
+	}
+}
+
+// DefaultTargetPrefix prefixes the values of the targets of a type that do
+// not already start with prefix, e.g. DefaultTargetPrefix("channel",
+// "payments-") sends to channel "alerts" as "payments-alerts"
+func DefaultTargetPrefix(targetType, prefix string) Default {
+	return func(msg *message.Message) {
+		for i, tgt := range msg.Targets {
+			if tgt.Type == targetType && !strings.HasPrefix(tgt.Value, prefix) {
+				msg.Targets[i].Value = prefix + tgt.Value
+			}
+		}
+	}
+}
+
+// With returns a child client whose sends apply defaults to their messages,
+// so that a service gets a handle scoped to it:
+//
+//	payments := client.With(
+//		notifyhub.DefaultMetadata("team", "payments"),
+//		notifyhub.DefaultTags("billing"),
+//	)
+//
+// The child shares the platforms, middleware and enrichers of the client;
+// changing them through either changes both. Closing the child does
+// nothing; the client must still be closed.
+func (c *clientImpl) With(defaults ...Default) Client {
+	return &scopedClient{clientImpl: c, defaults: defaults}
+}
+
+// scopedClient is a client that applies defaults to the messages it sends.
+// Embedding the parent client keeps the optional interfaces it implements,
+// such as StreamSender, available on the child.
+type scopedClient struct {
+	*clientImpl
+	defaults []Default
+}
+
+// With returns a child client applying the defaults of c before defaults
+func (c *scopedClient) With(defaults ...Default) Client {
+	all := make([]Default, 0, len(c.defaults)+len(defaults))
+	all = append(all, c.defaults...)
+	all = append(all, defaults...)
+	return &scopedClient{clientImpl: c.clientImpl, defaults: all}
+}
+
+// Send implements Client
+func (c *scopedClient) Send(ctx context.Context, msg *message.Message, opts ...SendOption) (*receipt.Receipt, error) {
+	return c.clientImpl.Send(ctx, c.apply(msg), opts...)
+}
+
+// SendBatch implements Client
+func (c *scopedClient) SendBatch(ctx context.Context, msgs []*message.Message, opts ...BatchOption) ([]*receipt.Receipt, error) {
+	return c.clientImpl.SendBatch(ctx, c.applyAll(msgs), opts...)
+}
+
+// SendStream implements StreamSender
+func (c *scopedClient) SendStream(ctx context.Context, msgs iter.Seq[*message.Message], opts ...SendOption) iter.Seq2[*receipt.Receipt, error] {
+	return c.clientImpl.SendStream(ctx, func(yield func(*message.Message) bool) {
+		for msg := range msgs {
+			if !yield(c.apply(msg)) {
+				return
+			}
+		}
+	}, opts...)
+}
+
+// SendAsync implements Client
+func (c *scopedClient) SendAsync(ctx context.Context, msg *message.Message, opts ...async.Option) (async.Handle, error) {
+	return c.clientImpl.SendAsync(ctx, c.apply(msg), opts...)
+}
+
+// SendAsyncBatch implements Client
+func (c *scopedClient) SendAsyncBatch(ctx context.Context, msgs []*message.Message, opts ...async.Option) (async.BatchHandle, error) {
+	return c.clientImpl.SendAsyncBatch(ctx, c.applyAll(msgs), opts...)
+}
+
+// Close implements Client; the parent client owns the resources
+func (c *scopedClient) Close() error {
+	return nil
+}
+
+// apply returns a copy of the message with the defaults applied
+func (c *scopedClient) apply(msg *message.Message) *message.Message {
+	if msg == nil || len(c.defaults) == 0 {
+		return msg
+	}
+	m := msg.Clone()
+	for _, d := range c.defaults {
+		if d != nil {
+			d(m)
+		}
+	}
+	return m
+}
+
+// applyAll applies the defaults to each message
+func (c *scopedClient) applyAll(msgs []*message.Message) []*message.Message {
+	scoped := make([]*message.Message, len(msgs))
+	for i, msg := range msgs {
+		scoped[i] = c.apply(msg)
+	}
+	return scoped
+}
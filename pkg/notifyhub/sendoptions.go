@@ -0,0 +1,75 @@
+// Package notifyhub provides the options of a single send
+package notifyhub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// SendOption configures a single Client.Send. Options are recorded on a
+// copy of the message before the middleware runs; the caller's message is
+// not modified.
+type SendOption func(*message.Message)
+
+// WithPlatforms restricts a send to platforms, e.g. for channels that
+// compliance rules control:
+//
+//	client.Send(ctx, msg, notifyhub.WithPlatforms("email", "sms"))
+//
+// Targets on any other platform are not delivered and are recorded as
+// "excluded" on the receipt, whichever platform they name or routing would
+// choose. User and group targets that name no platform are sent on the
+// first configured platform of the restriction. See message.SetPlatforms.
+func WithPlatforms(platforms ...string) SendOption {
+	return func(msg *message.Message) {
+		msg.SetPlatforms(platforms...)
+	}
+}
+
+// applySendOptions returns a copy of the message with the options applied
+func applySendOptions(msg *message.Message, opts []SendOption) *message.Message {
+	if len(opts) == 0 {
+		return msg
+	}
+	m := msg.Clone()
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
+	}
+	return m
+}
+
+// isExcluded records an "excluded" result when the message is restricted
+// to platforms that do not include the target's
+func (c *clientImpl) isExcluded(msg *message.Message, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) bool {
+	allowed := msg.Platforms()
+	if allowed == nil || containsString(allowed, platformName) {
+		return false
+	}
+
+	c.logger.Debug("Platform excluded from send", "message_id", msg.ID, "platform", platformName, "allowed", allowed)
+	receipt.AddResult(receiptpkg.PlatformResult{
+		Platform:  platformName,
+		Target:    tgt.Value,
+		Success:   false,
+		Status:    receiptpkg.ResultExcluded,
+		Error:     fmt.Sprintf("platform %s excluded: send restricted to %v", platformName, allowed),
+		Timestamp: time.Now(),
+	})
+	return true
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,576 @@
+// Package notifyhub provides target processing for the NotifyHub client
+package notifyhub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/audit"
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/contact"
+	"github.com/kart-io/notifyhub/pkg/errors"
+	"github.com/kart-io/notifyhub/pkg/flags"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/preference"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+	receiptpkg "github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/sendctx"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// newGroupExpander creates a group expander from configured groups, the contact
+// directory, the on-call resolver and the list provider
+func newGroupExpander(cfg *config.Config, isConfigured func(platform string) bool) (*contact.Expander, error) {
+	var directories contact.MultiDirectory
+
+	// Groups defined in configuration take precedence over the contact directory
+	if len(cfg.Groups) > 0 {
+		store := contact.NewMemoryStore()
+		for name, members := range cfg.Groups {
+			if err := store.PutGroup(context.Background(), &contact.Group{Name: name, Members: members}); err != nil {
+				return nil, err
+			}
+		}
+		directories = append(directories, store)
+	}
+	if cfg.ContactDirectory != nil {
+		directories = append(directories, cfg.ContactDirectory)
+	}
+
+	opts := []contact.ExpanderOption{contact.WithPlatformFilter(isConfigured)}
+	if cfg.OnCallResolver != nil {
+		opts = append(opts, contact.WithOnCall(cfg.OnCallResolver))
+	}
+	if cfg.ListProvider != nil {
+		opts = append(opts, contact.WithListProvider(cfg.ListProvider))
+	}
+	return contact.NewExpander(directories, opts...), nil
+}
+
+// expandTargets resolves target aliases, expands contact, group, team,
+// on-call and list targets into member targets and applies contact preferences to them. Targets that cannot be
+// expanded are recorded as failed results on the receipt.
+func (c *clientImpl) expandTargets(ctx context.Context, msg *message.Message, receipt *receiptpkg.Receipt) []target.Target {
+	targets := c.resolveAliases(msg.Targets, receipt)
+	if c.expander == nil {
+		return targets
+	}
+
+	expanded := make([]target.Target, 0, len(targets))
+	contacts := make(map[string]*contact.Contact)
+	for _, tgt := range targets {
+		expansion, err := c.expander.ExpandTarget(ctx, tgt)
+		if err != nil {
+			c.log(ctx).Error("Failed to expand target", "type", tgt.Type, "value", tgt.Value, "error", err)
+			receipt.AddResult(receiptpkg.PlatformResult{
+				Platform:  tgt.Platform,
+				Target:    tgt.Value,
+				Success:   false,
+				Error:     err.Error(),
+				Timestamp: time.Now(),
+			})
+			continue
+		}
+
+		for _, unresolved := range expansion.Unresolved {
+			c.log(ctx).Warn("Group member could not be resolved", "group", unresolved.Group, "member", unresolved.Member, "reason", unresolved.Reason)
+			receipt.AddResult(receiptpkg.PlatformResult{
+				Platform:  tgt.Platform,
+				Target:    unresolved.Member,
+				Success:   false,
+				Error:     "group " + unresolved.Group + ": " + unresolved.Reason,
+				Timestamp: time.Now(),
+			})
+		}
+
+		c.log(ctx).Debug("Target expanded", "type", tgt.Type, "value", tgt.Value, "members", len(expansion.Targets))
+		for _, member := range expansion.Targets {
+			ct := expansion.Contact(member)
+			if resolved, ok := c.applyPreferences(ctx, msg, member, ct, tgt.Platform != "", receipt); ok {
+				expanded = append(expanded, resolved)
+				if ct != nil {
+					contacts[resolved.String()] = ct
+				}
+			}
+		}
+	}
+
+	if c.config.DedupeRecipients {
+		expanded = c.dedupeRecipients(ctx, msg, expanded, contacts, receipt)
+	}
+	return expanded
+}
+
+// dedupeRecipients keeps one target per person. Targets linked to the same
+// directory contact are reduced to the one on the contact's most preferred
+// platform and repeated targets are dropped; dropped targets are recorded as
+// "duplicate" results on the receipt.
+func (c *clientImpl) dedupeRecipients(ctx context.Context, msg *message.Message, targets []target.Target, contacts map[string]*contact.Contact, receipt *receiptpkg.Receipt) []target.Target {
+	type choice struct {
+		index int
+		rank  int
+	}
+
+	identities := make([]string, len(targets))
+	platforms := make([]string, len(targets))
+	best := make(map[string]choice)
+	for i := range targets {
+		tgt := targets[i]
+		platformName := tgt.Platform
+		if platformName == "" {
+			platformName = c.determinePlatformByTargetType(msg, &tgt)
+		}
+
+		identity, rank := "target:"+platformName+":"+tgt.Value, 0
+		ct := contacts[tgt.String()]
+		if ct == nil {
+			ct, _ = c.expander.FindContact(ctx, platformName, tgt.Value)
+		}
+		if ct != nil {
+			identity, rank = "contact:"+ct.ID, platformRank(ct, platformName)
+		}
+
+		identities[i], platforms[i] = identity, platformName
+		if current, ok := best[identity]; !ok || rank < current.rank {
+			best[identity] = choice{index: i, rank: rank}
+		}
+	}
+
+	kept := make([]target.Target, 0, len(best))
+	for i, tgt := range targets {
+		chosen := best[identities[i]].index
+		if chosen == i {
+			kept = append(kept, tgt)
+			continue
+		}
+		c.log(ctx).Debug("Duplicate recipient dropped", "target", tgt.Value, "kept", targets[chosen].Value)
+		receipt.AddResult(receiptpkg.PlatformResult{
+			Platform:  platforms[i],
+			Target:    tgt.Value,
+			Success:   false,
+			Status:    receiptpkg.ResultDuplicate,
+			Error:     "recipient already notified via " + platforms[chosen],
+			Timestamp: time.Now(),
+		})
+	}
+	return kept
+}
+
+// platformRank returns the position of a platform in a contact's preferred
+// platform order; unknown platforms rank last
+func platformRank(ct *contact.Contact, platformName string) int {
+	platforms := ct.AvailablePlatforms()
+	for i, p := range platforms {
+		if p == platformName {
+			return i
+		}
+	}
+	return len(platforms)
+}
+
+// resolveAliases replaces alias targets with the targets configured for the
+// alias. Unknown aliases are recorded as failed results on the receipt.
+func (c *clientImpl) resolveAliases(targets []target.Target, receipt *receiptpkg.Receipt) []target.Target {
+	if len(c.config.Aliases) == 0 {
+		return targets
+	}
+
+	resolved := make([]target.Target, 0, len(targets))
+	for _, tgt := range targets {
+		if tgt.Type != target.TargetTypeAlias {
+			resolved = append(resolved, tgt)
+			continue
+		}
+
+		aliased, ok := c.config.Aliases[tgt.Value]
+		if !ok {
+			c.logger.Warn("Unknown target alias", "alias", tgt.Value)
+			receipt.AddResult(receiptpkg.PlatformResult{
+				Platform:  tgt.Platform,
+				Target:    tgt.Value,
+				Success:   false,
+				Error:     "unknown target alias: " + tgt.Value,
+				Timestamp: time.Now(),
+			})
+			continue
+		}
+		c.logger.Debug("Target alias resolved", "alias", tgt.Value, "targets", len(aliased))
+		resolved = append(resolved, aliased...)
+	}
+	return resolved
+}
+
+// applyPreferences applies a contact's preferences to a target selected for it.
+// It returns false when the target must not be sent now, after recording why
+// on the receipt. pinned is true when the caller fixed the platform explicitly,
+// in which case the preferred channel order is not applied.
+func (c *clientImpl) applyPreferences(ctx context.Context, msg *message.Message, tgt target.Target, ct *contact.Contact, pinned bool, receipt *receiptpkg.Receipt) (target.Target, bool) {
+	if ct == nil || c.config.Preferences == nil {
+		return tgt, true
+	}
+
+	prefs, err := c.config.Preferences.Get(ctx, ct.ID)
+	if err != nil {
+		c.log(ctx).Warn("Failed to load contact preferences", "contact", ct.ID, "error", err)
+		return tgt, true
+	}
+	if prefs == nil {
+		return tgt, true
+	}
+
+	decision := prefs.Decide(msg.Topic(), msg.Priority >= message.PriorityUrgent, time.Now())
+
+	if len(decision.Channels) > 0 && !pinned {
+		preferred := *ct
+		preferred.Platforms = decision.Channels
+		if selected, ok := c.expander.SelectTarget(&preferred, ""); ok {
+			tgt = selected
+		}
+	}
+
+	skip := func(status, reason string) (target.Target, bool) {
+		c.log(ctx).Debug("Target held by contact preferences", "contact", ct.ID, "status", status)
+		receipt.AddResult(receiptpkg.PlatformResult{
+			Platform:  tgt.Platform,
+			Target:    tgt.Value,
+			Success:   false,
+			Status:    status,
+			Error:     reason,
+			Timestamp: time.Now(),
+		})
+		return tgt, false
+	}
+
+	switch decision.Action {
+	case preference.ActionMuted:
+		return skip(receiptpkg.ResultSuppressed, "recipient turned off topic "+msg.Topic())
+	case preference.ActionQuiet:
+		return skip(receiptpkg.ResultQuietHours, "recipient quiet hours are active")
+	case preference.ActionDigest:
+		if c.config.Digests != nil {
+			c.config.Digests.Add(ct.ID, tgt, msg)
+			return skip(receiptpkg.ResultDigested, "added to recipient digest")
+		}
+	}
+	return tgt, true
+}
+
+// isDisabled consults the feature flags for a platform and records a
+// "disabled" result when it is switched off
+func (c *clientImpl) isDisabled(ctx context.Context, msg *message.Message, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) bool {
+	if c.config.Flags == nil {
+		return false
+	}
+
+	enabled, reason, err := c.config.Flags.PlatformEnabled(ctx, flags.Evaluation{
+		Platform:   platformName,
+		TargetType: tgt.Type,
+		MessageID:  msg.ID,
+		Priority:   int(msg.Priority),
+	})
+	if err != nil {
+		// Fail open: an unreachable flag service must not stop notifications
+		c.log(ctx).Warn("Failed to evaluate platform flag", "platform", platformName, "error", err)
+		return false
+	}
+	if enabled {
+		return false
+	}
+
+	receipt.AddResult(receiptpkg.PlatformResult{
+		Platform:  platformName,
+		Target:    tgt.Value,
+		Success:   false,
+		Status:    receiptpkg.ResultDisabled,
+		Error:     fmt.Sprintf("platform %s disabled: %s", platformName, reason),
+		Timestamp: time.Now(),
+	})
+	return true
+}
+
+// isBlocked applies the target access rules and records a "blocked" result
+// on the receipt and an audit entry when a target is denied
+func (c *clientImpl) isBlocked(ctx context.Context, msg *message.Message, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) bool {
+	if len(c.config.TargetAccess) == 0 {
+		return false
+	}
+
+	allowed, reason := target.AccessPolicy(c.config.TargetAccess).Check(platformName, tgt)
+	if allowed {
+		return false
+	}
+
+	c.log(ctx).Warn("Target blocked by access rules", "platform", platformName, "reason", reason)
+	now := time.Now()
+	if c.config.Audit != nil {
+		entry := audit.Entry{
+			Time:      now,
+			Action:    audit.ActionTargetBlocked,
+			MessageID: msg.ID,
+			Platform:  platformName,
+			Target:    tgt.Value,
+			Reason:    reason,
+			Metadata:  sendctx.From(ctx).Metadata(),
+		}
+		if err := c.config.Audit.Record(ctx, entry); err != nil {
+			c.log(ctx).Error("Failed to record audit entry", "action", entry.Action, "error", err)
+		}
+	}
+	receipt.AddResult(receiptpkg.PlatformResult{
+		Platform:  platformName,
+		Target:    tgt.Value,
+		Success:   false,
+		Status:    receiptpkg.ResultBlocked,
+		Error:     reason,
+		Timestamp: now,
+	})
+	return true
+}
+
+// isSuppressed checks the suppression store for a target and records a
+// "suppressed" result on the receipt when the recipient has opted out
+func (c *clientImpl) isSuppressed(ctx context.Context, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) bool {
+	if c.config.Suppression == nil {
+		return false
+	}
+
+	entry, err := c.config.Suppression.Lookup(ctx, platformName, tgt.Value)
+	if err != nil {
+		// Fail open: a store outage should not block notifications
+		c.log(ctx).Warn("Failed to check suppression list", "platform", platformName, "error", err)
+		return false
+	}
+	if entry == nil {
+		return false
+	}
+
+	c.log(ctx).Debug("Target suppressed", "platform", platformName, "reason", entry.Reason)
+	reason := "recipient opted out"
+	if entry.Reason != "" {
+		reason += ": " + entry.Reason
+	}
+	receipt.AddResult(receiptpkg.PlatformResult{
+		Platform:  platformName,
+		Target:    tgt.Value,
+		Success:   false,
+		Status:    receiptpkg.ResultSuppressed,
+		Error:     reason,
+		Timestamp: time.Now(),
+	})
+	return true
+}
+
+// isQuarantined checks the quarantine store for a target and records a
+// "quarantined" result on the receipt while the target is quarantined
+func (c *clientImpl) isQuarantined(ctx context.Context, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) bool {
+	if c.config.Quarantine == nil {
+		return false
+	}
+
+	entry, err := c.config.Quarantine.Lookup(ctx, platformName, tgt.Value)
+	if err != nil {
+		// Fail open like the suppression list
+		c.log(ctx).Warn("Failed to check quarantine", "platform", platformName, "error", err)
+		return false
+	}
+	if entry == nil {
+		return false
+	}
+
+	receipt.AddResult(receiptpkg.PlatformResult{
+		Platform:  platformName,
+		Target:    tgt.Value,
+		Success:   false,
+		Status:    receiptpkg.ResultQuarantined,
+		Error:     fmt.Sprintf("target quarantined after %d hard failures: %s", entry.Failures, entry.Reason),
+		Timestamp: time.Now(),
+	})
+	return true
+}
+
+// trackDelivery updates the quarantine store with the outcome of a send.
+// Hard failures count towards the threshold; transient failures are
+// ignored and a successful send resets the count.
+func (c *clientImpl) trackDelivery(ctx context.Context, msg *message.Message, platformName string, tgt target.Target, sendErr error) {
+	if c.config.Quarantine == nil {
+		return
+	}
+
+	if sendErr == nil {
+		if err := c.config.Quarantine.RecordSuccess(ctx, platformName, tgt.Value); err != nil {
+			c.log(ctx).Warn("Failed to reset target failures", "platform", platformName, "error", err)
+		}
+		return
+	}
+	if !platform.IsTargetInvalid(sendErr) {
+		return
+	}
+
+	entry, err := c.config.Quarantine.RecordFailure(ctx, platformName, tgt.Value, sendErr.Error(), c.config.QuarantineThreshold)
+	if err != nil {
+		c.log(ctx).Warn("Failed to record target failure", "platform", platformName, "error", err)
+		return
+	}
+	if !entry.QuarantinedAt.Equal(entry.LastFailureAt) {
+		return
+	}
+
+	c.log(ctx).Warn("Target quarantined", "platform", platformName, "failures", entry.Failures)
+	if c.config.Audit != nil {
+		record := audit.Entry{
+			Time:      entry.QuarantinedAt,
+			Action:    audit.ActionTargetQuarantined,
+			MessageID: msg.ID,
+			Platform:  platformName,
+			Target:    tgt.Value,
+			Reason:    entry.Reason,
+			Metadata:  sendctx.From(ctx).Metadata(),
+		}
+		if err := c.config.Audit.Record(ctx, record); err != nil {
+			c.log(ctx).Error("Failed to record audit entry", "action", record.Action, "error", err)
+		}
+	}
+}
+
+// isRateLimited applies the target rate limits to a target. Over-limit sends
+// are dropped, waited for until the limit allows them, or deferred,
+// according to the limit's policy, and recorded on the receipt.
+func (c *clientImpl) isRateLimited(ctx context.Context, msg *message.Message, platformName string, tgt target.Target, receipt *receiptpkg.Receipt) bool {
+	if c.limiter == nil {
+		return false
+	}
+
+	for _, limit := range c.config.TargetRateLimits {
+		if !limit.Applies(platformName) {
+			continue
+		}
+
+		allowed, retryAt, err := c.limiter.Allow(ctx, limit.Key(platformName, tgt.Value), limit)
+		if err != nil {
+			// Fail open like the suppression check
+			c.log(ctx).Warn("Failed to check target rate limit", "platform", platformName, "error", err)
+			continue
+		}
+		if allowed {
+			continue
+		}
+
+		reason := fmt.Sprintf("recipient rate limit of %d per %s reached", limit.Max, limit.Per)
+		if limit.Policy == ratelimit.PolicyWait {
+			c.log(ctx).Debug("Waiting for target rate limit", "platform", platformName, "retry_at", retryAt)
+			allowed, reason = c.waitRateLimit(ctx, retryAt, reason, func() (bool, time.Time, error) {
+				return c.limiter.Allow(ctx, limit.Key(platformName, tgt.Value), limit)
+			})
+			if allowed {
+				continue
+			}
+		}
+		result := receiptpkg.PlatformResult{
+			Platform:  platformName,
+			Target:    tgt.Value,
+			Success:   false,
+			Status:    receiptpkg.ResultRateLimited,
+			Error:     reason,
+			Timestamp: time.Now(),
+		}
+		if limit.Policy == ratelimit.PolicyDefer {
+			c.holds.Add(heldDelivery{msg: msg, platform: platformName, target: tgt, until: retryAt})
+			result.Status = receiptpkg.ResultHeld
+			result.Error = reason + ", deferred"
+			result.HeldUntil = &retryAt
+		}
+
+		c.log(ctx).Debug("Target rate limited", "platform", platformName, "policy", limit.Policy)
+		receipt.AddResult(result)
+		return true
+	}
+	return false
+}
+
+// waitRateLimit waits until retryAt and takes the send again with take
+// until it is allowed or ctx is done, returning the reason extended with
+// the error of ctx in that case. Failures to take it fail open.
+func (c *clientImpl) waitRateLimit(ctx context.Context, retryAt time.Time, reason string, take func() (bool, time.Time, error)) (bool, string) {
+	for {
+		timer := time.NewTimer(time.Until(retryAt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false, fmt.Sprintf("%s: %v", reason, ctx.Err())
+		}
+		allowed, next, err := take()
+		if err != nil {
+			c.log(ctx).Warn("Failed to check rate limit", "error", err)
+			return true, reason
+		}
+		if allowed {
+			return true, reason
+		}
+		retryAt = next
+	}
+}
+
+// hasDeferLimit reports whether any target rate limit or platform quota
+// defers over-limit sends
+func hasDeferLimit(limits []ratelimit.Limit, quotas []ratelimit.Quota) bool {
+	for _, limit := range limits {
+		if limit.Policy == ratelimit.PolicyDefer {
+			return true
+		}
+	}
+	for _, quota := range quotas {
+		if quota.Policy == ratelimit.PolicyDefer {
+			return true
+		}
+	}
+	return false
+}
+
+// expandTemplate resolves the placeholders of a templated webhook target,
+// such as "https://api.example.com/hooks/{{tenant_id}}", from the message
+// variables. Other targets are returned unchanged.
+func expandTemplate(msg *message.Message, tgt target.Target) (target.Target, error) {
+	if (tgt.Type != target.TargetTypeWebhook && tgt.Type != "url") || !target.IsTemplated(tgt.Value) {
+		return tgt, nil
+	}
+	value, err := target.ExpandURL(tgt.Value, msg.Variables)
+	if err != nil {
+		return tgt, err
+	}
+	tgt.Value = value
+	return tgt, nil
+}
+
+// validateTargets strictly validates the message targets so that malformed
+// addresses are rejected before the message is enqueued
+func (c *clientImpl) validateTargets(msg *message.Message) error {
+	multi := errors.NewMultiError()
+	for _, tgt := range msg.Targets {
+		if tgt.Type == target.TargetTypeAlias {
+			if _, ok := c.config.Aliases[tgt.Value]; !ok {
+				multi.Add(errors.Newf(errors.ErrInvalidTarget, "unknown target alias %q", tgt.Value))
+			}
+			continue
+		}
+		if tgt.Type == target.TargetTypeTag {
+			if _, err := contact.ParseTagQuery(tgt.Value); err != nil {
+				multi.Add(err)
+			}
+			continue
+		}
+		resolved, err := expandTemplate(msg, tgt)
+		if err == nil {
+			err = c.validator.Validate(resolved)
+		}
+		if err != nil {
+			multi.Add(err)
+		}
+	}
+	if multi.Count() == 1 {
+		return multi.First()
+	}
+	return multi.ErrorOrNil()
+}
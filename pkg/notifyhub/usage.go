@@ -0,0 +1,189 @@
+// Package notifyhub provides the usage quotas of tenants and apps
+package notifyhub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+	"github.com/kart-io/notifyhub/pkg/sendctx"
+)
+
+// ErrQuotaExceeded is the error of sends rejected by a usage quota, which
+// errors.Is matches
+var ErrQuotaExceeded = errors.New(errors.ErrQuotaExceeded, "usage quota exceeded")
+
+// usageCharge is the usage a message added to a quota
+type usageCharge struct {
+	key     string
+	n       int64
+	expires time.Time
+}
+
+// chargeUsage counts a message against the usage quotas of its tenant and
+// app before it is sent or queued, returning the usage added. When the
+// message would take a quota over its max, it adds nothing and returns an
+// ErrQuotaExceeded error. Usage quotas fail open like platform quotas when
+// their counter cannot be reached.
+func (c *clientImpl) chargeUsage(ctx context.Context, msg *message.Message) ([]usageCharge, error) {
+	if len(c.config.UsageQuotas) == 0 {
+		return nil, nil
+	}
+
+	values := sendctx.FromMessage(msg)
+	now := time.Now()
+	var charged []usageCharge
+	var warnings []ratelimit.Usage
+	for _, quota := range c.config.UsageQuotas {
+		if !quota.Applies(values.Tenant, values.App) {
+			continue
+		}
+		n := c.usageOf(msg, quota.Platform)
+		if n == 0 {
+			continue
+		}
+		start, end := quota.Window(now)
+		charge := usageCharge{key: quota.Key(values.Tenant, values.App, start), n: n, expires: end}
+		used, ok, err := c.usage.Add(ctx, charge.key, n, quota.Max, end)
+		if err != nil {
+			c.log(ctx).Warn("Failed to count usage quota", "period", quota.Period, "platform", quota.Platform, "error", err)
+			continue
+		}
+		if !ok {
+			c.refundUsage(ctx, charged)
+			c.log(ctx).Info("Usage quota exceeded", "message_id", msg.ID, "period", quota.Period, "platform", quota.Platform, "max", quota.Max, "used", used)
+			return nil, quotaExceeded(quota, values, used, end)
+		}
+		charged = append(charged, charge)
+		if quota.SoftLimit > 0 && used >= quota.SoftLimit && used-n < quota.SoftLimit {
+			warnings = append(warnings, ratelimit.Usage{
+				Quota: quota, Tenant: values.Tenant, App: values.App,
+				PeriodStart: start, PeriodEnd: end, Used: used, Remaining: quota.Max - used,
+			})
+		}
+	}
+
+	for _, usage := range warnings {
+		c.warnUsage(ctx, usage)
+	}
+	return charged, nil
+}
+
+// refundUsage gives back the usage of a message that was not queued
+func (c *clientImpl) refundUsage(ctx context.Context, charged []usageCharge) {
+	for _, charge := range charged {
+		if _, _, err := c.usage.Add(ctx, charge.key, -charge.n, 0, charge.expires); err != nil {
+			c.log(ctx).Warn("Failed to refund usage quota", "error", err)
+		}
+	}
+}
+
+// usageOf returns the usage of a message under a quota of a platform: one
+// message, or its targets on the platform
+func (c *clientImpl) usageOf(msg *message.Message, platformName string) int64 {
+	if platformName == "" {
+		return 1
+	}
+	var n int64
+	for _, tgt := range msg.Targets {
+		name := tgt.Platform
+		if name == "" {
+			name = c.determinePlatformByTargetType(msg, &tgt)
+		}
+		if name == platformName {
+			n++
+		}
+	}
+	return n
+}
+
+// quotaExceeded returns the error of a message rejected by a quota
+func quotaExceeded(quota ratelimit.UsageQuota, values sendctx.Values, used int64, end time.Time) error {
+	err := errors.Newf(errors.ErrQuotaExceeded, "%s quota of %d per %s exceeded%s, %d used",
+		usageUnit(quota), quota.Max, quota.Period, usageSubject(quota, values.Tenant, values.App), used).
+		WithRetryAfter(time.Until(end))
+	if quota.Tenant != "" {
+		err = err.WithMetadata(message.MetadataTenant, values.Tenant)
+	}
+	if quota.App != "" {
+		err = err.WithMetadata(message.MetadataApp, values.App)
+	}
+	return err
+}
+
+// warnUsage logs that a tenant or app reached the soft limit of a quota,
+// and sends a warning to the usage warning targets. Warnings do not count
+// against usage quotas.
+func (c *clientImpl) warnUsage(ctx context.Context, usage ratelimit.Usage) {
+	subject := usageSubject(usage.Quota, usage.Tenant, usage.App)
+	c.log(ctx).Warn("Usage quota soft limit reached", "period", usage.Quota.Period, "platform", usage.Quota.Platform, "soft_limit", usage.Quota.SoftLimit, "max", usage.Quota.Max, "used", usage.Used)
+	if len(c.config.UsageWarningTargets) == 0 {
+		return
+	}
+
+	warning := message.New()
+	warning.Title = fmt.Sprintf("Usage quota warning%s", subject)
+	warning.Body = fmt.Sprintf("%d of %d %s allowed per %s have been used%s since %s, passing the soft limit of %d.",
+		usage.Used, usage.Quota.Max, usageUnit(usage.Quota), usage.Quota.Period, subject, usage.PeriodStart.Format(time.DateOnly), usage.Quota.SoftLimit)
+	warning.Targets = append(warning.Targets, c.config.UsageWarningTargets...)
+	go func() {
+		if _, err := c.dispatch(context.Background(), warning); err != nil {
+			c.logger.Warn("Failed to send usage quota warning", "error", err)
+		}
+	}()
+}
+
+// usageUnit names what a quota counts
+func usageUnit(quota ratelimit.UsageQuota) string {
+	if quota.Platform == "" {
+		return "message"
+	}
+	return quota.Platform
+}
+
+// usageSubject names the tenant and app whose usage a quota counts
+func usageSubject(quota ratelimit.UsageQuota, tenant, app string) string {
+	var subject string
+	if quota.Tenant != "" {
+		subject += " for tenant " + tenant
+	}
+	if quota.App != "" {
+		if subject == "" {
+			subject = " for"
+		} else {
+			subject += " and"
+		}
+		subject += " app " + app
+	}
+	return subject
+}
+
+// Usage reports the usage of the usage quotas counting the sends of a
+// tenant and app in their current period; empty tenant and app report the
+// quotas counting every send together
+func (c *clientImpl) Usage(ctx context.Context, tenant, app string) ([]ratelimit.Usage, error) {
+	now := time.Now()
+	var usage []ratelimit.Usage
+	for _, quota := range c.config.UsageQuotas {
+		if !quota.Applies(tenant, app) {
+			continue
+		}
+		start, end := quota.Window(now)
+		used, err := c.usage.Usage(ctx, quota.Key(tenant, app, start))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read usage: %w", err)
+		}
+		u := ratelimit.Usage{Quota: quota, PeriodStart: start, PeriodEnd: end, Used: used, Remaining: max(0, quota.Max-used)}
+		if quota.Tenant != "" {
+			u.Tenant = tenant
+		}
+		if quota.App != "" {
+			u.App = app
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
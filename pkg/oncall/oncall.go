@@ -0,0 +1,101 @@
+// Package oncall resolves on-call schedules for NotifyHub.
+//
+// A target such as target.NewOnCall("payments") is expanded at send time
+// into whoever is currently on call for the "payments" schedule. Schedules
+// can come from PagerDuty, Opsgenie or the built-in Rota, and a Registry
+// routes schedule names to the resolver that owns them:
+//
+//	registry := oncall.NewRegistry()
+//	registry.Register("payments", oncall.NewPagerDuty(oncall.PagerDutyConfig{...}))
+//	registry.Register("infra", oncall.NewRotaResolver(rota))
+//	client, _ := notifyhub.NewClientFromOptions(config.WithOnCallResolver(registry), ...)
+package oncall
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Resolver returns the members currently on call for a schedule.
+// It satisfies contact.OnCallResolver.
+type Resolver interface {
+	OnCall(ctx context.Context, schedule string) ([]string, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface
+type ResolverFunc func(ctx context.Context, schedule string) ([]string, error)
+
+// OnCall calls f(ctx, schedule)
+func (f ResolverFunc) OnCall(ctx context.Context, schedule string) ([]string, error) {
+	return f(ctx, schedule)
+}
+
+// Registry routes schedule names to resolvers
+type Registry struct {
+	resolvers map[string]Resolver
+	fallback  Resolver
+	mu        sync.RWMutex
+}
+
+// NewRegistry creates an empty schedule registry
+func NewRegistry() *Registry {
+	return &Registry{resolvers: make(map[string]Resolver)}
+}
+
+// Register routes a schedule name to a resolver
+func (r *Registry) Register(schedule string, resolver Resolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resolvers[schedule] = resolver
+}
+
+// SetDefault sets the resolver used for schedules without an explicit route
+func (r *Registry) SetDefault(resolver Resolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = resolver
+}
+
+// Schedules returns the explicitly registered schedule names
+func (r *Registry) Schedules() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.resolvers))
+	for name := range r.resolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OnCall resolves the schedule with its registered resolver
+func (r *Registry) OnCall(ctx context.Context, schedule string) ([]string, error) {
+	r.mu.RLock()
+	resolver, ok := r.resolvers[schedule]
+	if !ok {
+		resolver = r.fallback
+	}
+	r.mu.RUnlock()
+
+	if resolver == nil {
+		return nil, fmt.Errorf("unknown on-call schedule: %s", schedule)
+	}
+	return resolver.OnCall(ctx, schedule)
+}
+
+// dedupe removes empty and duplicate members while preserving order
+func dedupe(members []string) []string {
+	seen := make(map[string]bool, len(members))
+	out := make([]string, 0, len(members))
+	for _, m := range members {
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		out = append(out, m)
+	}
+	return out
+}
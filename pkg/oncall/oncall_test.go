@@ -0,0 +1,135 @@
+package oncall
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRota_OnCallAt(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	rota := &Rota{
+		Name:         "payments",
+		Participants: []string{"alice", "bob", "carol"},
+		Start:        start,
+		ShiftLength:  7 * 24 * time.Hour,
+		Overrides: []Override{
+			{Start: start.Add(24 * time.Hour), End: start.Add(48 * time.Hour), Participant: "dave"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"before start", start.Add(-time.Hour), ""},
+		{"first shift", start, "alice"},
+		{"override", start.Add(36 * time.Hour), "dave"},
+		{"after override", start.Add(48 * time.Hour), "alice"},
+		{"second shift", start.Add(8 * 24 * time.Hour), "bob"},
+		{"wraps around", start.Add(21 * 24 * time.Hour), "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rota.OnCallAt(tt.at); got != tt.want {
+				t.Errorf("OnCallAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotaResolver(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	resolver := NewRotaResolver(&Rota{Name: "infra", Participants: []string{"alice", "bob"}, Start: start, ShiftLength: 24 * time.Hour})
+	resolver.now = func() time.Time { return start.Add(30 * time.Hour) }
+
+	got, err := resolver.OnCall(context.Background(), "infra")
+	if err != nil || len(got) != 1 || got[0] != "bob" {
+		t.Errorf("OnCall() = %v, %v, want [bob]", got, err)
+	}
+
+	if _, err := resolver.OnCall(context.Background(), "missing"); err == nil {
+		t.Error("OnCall() expected error for unknown rota")
+	}
+	if err := resolver.Add(&Rota{Name: "empty", ShiftLength: time.Hour}); err == nil {
+		t.Error("Add() expected error for rota without participants")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("payments", ResolverFunc(func(ctx context.Context, schedule string) ([]string, error) {
+		return []string{"alice"}, nil
+	}))
+
+	if got, err := registry.OnCall(context.Background(), "payments"); err != nil || got[0] != "alice" {
+		t.Errorf("OnCall() = %v, %v, want [alice]", got, err)
+	}
+	if _, err := registry.OnCall(context.Background(), "infra"); err == nil {
+		t.Error("OnCall() expected error for unknown schedule")
+	}
+
+	registry.SetDefault(ResolverFunc(func(ctx context.Context, schedule string) ([]string, error) {
+		return []string{schedule + "@example.com"}, nil
+	}))
+	if got, err := registry.OnCall(context.Background(), "infra"); err != nil || got[0] != "infra@example.com" {
+		t.Errorf("OnCall() = %v, %v, want default resolver result", got, err)
+	}
+}
+
+func TestPagerDuty_OnCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token token=pd-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/oncalls" || r.URL.Query().Get("schedule_ids[]") != "PSCHED1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"oncalls":[
+			{"escalation_level":2,"user":{"id":"P2","email":"manager@example.com"}},
+			{"escalation_level":1,"user":{"id":"P1","email":"alice@example.com"}},
+			{"escalation_level":1,"user":{"id":"P1","email":"alice@example.com"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	pd := NewPagerDuty(PagerDutyConfig{Token: "pd-token", BaseURL: server.URL, Schedules: map[string]string{"payments": "PSCHED1"}})
+	got, err := pd.OnCall(context.Background(), "payments")
+	if err != nil {
+		t.Fatalf("OnCall() error = %v", err)
+	}
+	if strings.Join(got, ",") != "alice@example.com" {
+		t.Errorf("OnCall() = %v, want [alice@example.com]", got)
+	}
+
+	if _, err := pd.OnCall(context.Background(), "unknown"); err == nil {
+		t.Error("OnCall() expected error for failed request")
+	}
+}
+
+func TestOpsgenie_OnCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey og-key" || r.URL.Path != "/v2/schedules/Payments Primary/on-calls" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"onCallRecipients":["bob@example.com","carol@example.com"]}}`))
+	}))
+	defer server.Close()
+
+	og := NewOpsgenie(OpsgenieConfig{APIKey: "og-key", BaseURL: server.URL, Schedules: map[string]string{"payments": "Payments Primary"}})
+	got, err := og.OnCall(context.Background(), "payments")
+	if err != nil {
+		t.Fatalf("OnCall() error = %v", err)
+	}
+	if strings.Join(got, ",") != "bob@example.com,carol@example.com" {
+		t.Errorf("OnCall() = %v, want bob and carol", got)
+	}
+}
@@ -0,0 +1,73 @@
+// Package oncall provides Opsgenie schedule resolution
+package oncall
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultOpsgenieURL is the Opsgenie API base URL (use https://api.eu.opsgenie.com for EU accounts)
+const DefaultOpsgenieURL = "https://api.opsgenie.com"
+
+// OpsgenieConfig configures the Opsgenie resolver
+type OpsgenieConfig struct {
+	APIKey    string            `json:"api_key"`
+	BaseURL   string            `json:"base_url,omitempty"`  // defaults to DefaultOpsgenieURL
+	Schedules map[string]string `json:"schedules,omitempty"` // schedule name -> Opsgenie schedule name; unmapped names are used as-is
+	Timeout   time.Duration     `json:"timeout,omitempty"`
+}
+
+// Opsgenie resolves schedules through the Opsgenie schedule on-calls API
+type Opsgenie struct {
+	config OpsgenieConfig
+	client *http.Client
+}
+
+// NewOpsgenie creates an Opsgenie schedule resolver
+func NewOpsgenie(cfg OpsgenieConfig) *Opsgenie {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultOpsgenieURL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Opsgenie{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// opsgenieOnCalls is the subset of the flat on-calls response used here
+type opsgenieOnCalls struct {
+	Data struct {
+		OnCallRecipients []string `json:"onCallRecipients"`
+	} `json:"data"`
+}
+
+// OnCall returns the usernames (email addresses) currently on call for the schedule
+func (o *Opsgenie) OnCall(ctx context.Context, schedule string) ([]string, error) {
+	name := schedule
+	if mapped, ok := o.config.Schedules[schedule]; ok {
+		name = mapped
+	}
+
+	query := url.Values{}
+	query.Set("scheduleIdentifierType", "name")
+	query.Set("flat", "true")
+
+	endpoint := fmt.Sprintf("%s/v2/schedules/%s/on-calls?%s", o.config.BaseURL, url.PathEscape(name), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Opsgenie request: %w", err)
+	}
+	req.Header.Set("Authorization", "GenieKey "+o.config.APIKey)
+
+	var body opsgenieOnCalls
+	if err := doJSON(o.client, req, &body); err != nil {
+		return nil, fmt.Errorf("Opsgenie schedule %s: %w", schedule, err)
+	}
+	return dedupe(body.Data.OnCallRecipients), nil
+}
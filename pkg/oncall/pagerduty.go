@@ -0,0 +1,121 @@
+// Package oncall provides PagerDuty schedule resolution
+package oncall
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultPagerDutyURL is the PagerDuty REST API base URL
+const DefaultPagerDutyURL = "https://api.pagerduty.com"
+
+// PagerDutyConfig configures the PagerDuty resolver
+type PagerDutyConfig struct {
+	Token     string            `json:"token"`               // REST API token
+	BaseURL   string            `json:"base_url,omitempty"`  // defaults to DefaultPagerDutyURL
+	Schedules map[string]string `json:"schedules,omitempty"` // schedule name -> PagerDuty schedule ID; unmapped names are used as IDs
+	Timeout   time.Duration     `json:"timeout,omitempty"`
+}
+
+// PagerDuty resolves schedules through the PagerDuty on-calls API
+type PagerDuty struct {
+	config PagerDutyConfig
+	client *http.Client
+}
+
+// NewPagerDuty creates a PagerDuty schedule resolver
+func NewPagerDuty(cfg PagerDutyConfig) *PagerDuty {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultPagerDutyURL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &PagerDuty{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// pagerDutyOnCalls is the subset of the /oncalls response used here
+type pagerDutyOnCalls struct {
+	OnCalls []struct {
+		EscalationLevel int `json:"escalation_level"`
+		User            struct {
+			ID    string `json:"id"`
+			Email string `json:"email"`
+		} `json:"user"`
+	} `json:"oncalls"`
+}
+
+// OnCall returns the email addresses of the users currently on call for
+// the schedule's first escalation level
+func (p *PagerDuty) OnCall(ctx context.Context, schedule string) ([]string, error) {
+	scheduleID := schedule
+	if id, ok := p.config.Schedules[schedule]; ok {
+		scheduleID = id
+	}
+
+	query := url.Values{}
+	query.Set("schedule_ids[]", scheduleID)
+	query.Set("include[]", "users")
+	query.Set("earliest", "true")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.BaseURL+"/oncalls?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PagerDuty request: %w", err)
+	}
+	req.Header.Set("Authorization", "Token token="+p.config.Token)
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+
+	var body pagerDutyOnCalls
+	if err := doJSON(p.client, req, &body); err != nil {
+		return nil, fmt.Errorf("PagerDuty schedule %s: %w", schedule, err)
+	}
+
+	minLevel := 0
+	for _, oc := range body.OnCalls {
+		if minLevel == 0 || oc.EscalationLevel < minLevel {
+			minLevel = oc.EscalationLevel
+		}
+	}
+
+	var members []string
+	for _, oc := range body.OnCalls {
+		if oc.EscalationLevel != minLevel {
+			continue
+		}
+		if oc.User.Email != "" {
+			members = append(members, oc.User.Email)
+		} else {
+			members = append(members, oc.User.ID)
+		}
+	}
+	return dedupe(members), nil
+}
+
+// doJSON performs a request and decodes a successful JSON response
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Package oncall provides a built-in rotation schedule
+package oncall
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Rota is a simple fixed-length rotation through a list of participants
+type Rota struct {
+	Name         string        `json:"name"`
+	Participants []string      `json:"participants"` // contact IDs or email addresses, in rotation order
+	Start        time.Time     `json:"start"`        // first handoff; Participants[0] is on call from here
+	ShiftLength  time.Duration `json:"shift_length"` // e.g. 7 * 24 * time.Hour for weekly rotations
+	Overrides    []Override    `json:"overrides,omitempty"`
+}
+
+// Override temporarily replaces the scheduled participant
+type Override struct {
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Participant string    `json:"participant"`
+}
+
+// Validate checks the rota definition
+func (r *Rota) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rota name cannot be empty")
+	}
+	if len(r.Participants) == 0 {
+		return fmt.Errorf("rota %s has no participants", r.Name)
+	}
+	if r.ShiftLength <= 0 {
+		return fmt.Errorf("rota %s shift length must be positive", r.Name)
+	}
+	for i, o := range r.Overrides {
+		if o.Participant == "" || !o.End.After(o.Start) {
+			return fmt.Errorf("rota %s override %d is invalid", r.Name, i)
+		}
+	}
+	return nil
+}
+
+// OnCallAt returns the participant on call at the given time.
+// The latest matching override wins over the regular rotation.
+func (r *Rota) OnCallAt(at time.Time) string {
+	for i := len(r.Overrides) - 1; i >= 0; i-- {
+		o := r.Overrides[i]
+		if !at.Before(o.Start) && at.Before(o.End) {
+			return o.Participant
+		}
+	}
+
+	if len(r.Participants) == 0 || r.ShiftLength <= 0 || at.Before(r.Start) {
+		return ""
+	}
+	shift := int64(at.Sub(r.Start) / r.ShiftLength)
+	return r.Participants[shift%int64(len(r.Participants))]
+}
+
+// RotaResolver resolves schedules from built-in rotas
+type RotaResolver struct {
+	rotas map[string]*Rota
+	now   func() time.Time
+	mu    sync.RWMutex
+}
+
+// NewRotaResolver creates a resolver for the given rotas.
+// Invalid rotas are rejected by Add and skipped here.
+func NewRotaResolver(rotas ...*Rota) *RotaResolver {
+	r := &RotaResolver{
+		rotas: make(map[string]*Rota),
+		now:   time.Now,
+	}
+	for _, rota := range rotas {
+		_ = r.Add(rota)
+	}
+	return r
+}
+
+// Add registers or replaces a rota
+func (r *RotaResolver) Add(rota *Rota) error {
+	if err := rota.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotas[rota.Name] = rota
+	return nil
+}
+
+// OnCall returns the participant currently on call for the rota
+func (r *RotaResolver) OnCall(ctx context.Context, schedule string) ([]string, error) {
+	r.mu.RLock()
+	rota, ok := r.rotas[schedule]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown rota: %s", schedule)
+	}
+	if member := rota.OnCallAt(r.now()); member != "" {
+		return []string{member}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,285 @@
+// Package pii protects personal data such as email addresses and phone
+// numbers stored by NotifyHub.
+//
+// Values are encrypted with envelope encryption: each value is sealed with
+// AES-256-GCM under a data key, and the data key is stored next to the
+// ciphertext wrapped by a KeyService, typically a KMS. Data keys are reused
+// for a rotation period so that the KMS is not called for every value, and
+// unwrapped keys are cached for decryption.
+//
+// Encrypted values cannot be compared, so stores that look values up use a
+// blind index: a keyed HMAC of the value that is stable across data keys.
+package pii
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefix marks encrypted values and their format version
+const prefix = "pii:v1:"
+
+// DefaultKeyRotation is how long a data key encrypts new values when no
+// rotation period is configured
+const DefaultKeyRotation = 24 * time.Hour
+
+// KeyService generates and unwraps data keys, typically backed by a KMS
+type KeyService interface {
+	// GenerateDataKey returns a new 32-byte data key and its wrapped form
+	GenerateDataKey(ctx context.Context) (plaintext, wrapped []byte, err error)
+
+	// DecryptDataKey unwraps a data key returned by GenerateDataKey
+	DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyService wraps data keys with a local AES-256 master key. It is
+// meant for development and tests; production deployments should wrap data
+// keys with a KMS.
+type LocalKeyService struct {
+	master cipher.AEAD
+}
+
+// NewLocalKeyService creates a key service from a 32-byte master key
+func NewLocalKeyService(masterKey []byte) (*LocalKeyService, error) {
+	if len(masterKey) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, got %d", len(masterKey))
+	}
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKeyService{master: aead}, nil
+}
+
+// GenerateDataKey implements KeyService
+func (l *LocalKeyService) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := seal(l.master, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, wrapped, nil
+}
+
+// DecryptDataKey implements KeyService
+func (l *LocalKeyService) DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return open(l.master, wrapped)
+}
+
+// EnvelopeConfig configures an Envelope
+type EnvelopeConfig struct {
+	Keys        KeyService
+	IndexKey    []byte        // HMAC key for blind indexes, at least 16 bytes
+	KeyRotation time.Duration // how long a data key encrypts new values, defaults to DefaultKeyRotation
+}
+
+// Envelope encrypts and decrypts values with envelope encryption
+type Envelope struct {
+	keys     KeyService
+	indexKey []byte
+	rotation time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	current   cipher.AEAD
+	wrapped   []byte
+	createdAt time.Time
+	unwrapped map[string]cipher.AEAD
+}
+
+// NewEnvelope creates an envelope encrypter
+func NewEnvelope(cfg EnvelopeConfig) (*Envelope, error) {
+	if cfg.Keys == nil {
+		return nil, fmt.Errorf("key service is required")
+	}
+	if len(cfg.IndexKey) < 16 {
+		return nil, fmt.Errorf("index key must be at least 16 bytes")
+	}
+	if cfg.KeyRotation <= 0 {
+		cfg.KeyRotation = DefaultKeyRotation
+	}
+	return &Envelope{
+		keys:      cfg.Keys,
+		indexKey:  cfg.IndexKey,
+		rotation:  cfg.KeyRotation,
+		now:       time.Now,
+		unwrapped: make(map[string]cipher.AEAD),
+	}, nil
+}
+
+// Encrypt seals a value. Empty values stay empty.
+func (e *Envelope) Encrypt(ctx context.Context, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	aead, wrapped, err := e.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 2, 2+len(wrapped)+len(sealed))
+	binary.BigEndian.PutUint16(buf, uint16(len(wrapped)))
+	buf = append(append(buf, wrapped...), sealed...)
+	return prefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Decrypt opens a value sealed by Encrypt. Values that are not encrypted
+// are returned unchanged, so stores can be migrated incrementally.
+func (e *Envelope) Decrypt(ctx context.Context, value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return value, nil
+	}
+
+	buf, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(buf) < 2 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	n := int(binary.BigEndian.Uint16(buf))
+	if len(buf) < 2+n {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	wrapped, sealed := buf[2:2+n], buf[2+n:]
+
+	aead, err := e.unwrap(ctx, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Index returns the blind index of a value: a deterministic keyed hash
+// that can be stored and compared in place of the value
+func (e *Envelope) Index(value string) string {
+	mac := hmac.New(sha256.New, e.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// dataKey returns the current data key, generating a new one when the
+// rotation period has passed
+func (e *Envelope) dataKey(ctx context.Context) (cipher.AEAD, []byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.current != nil && e.now().Sub(e.createdAt) < e.rotation {
+		return e.current, e.wrapped, nil
+	}
+
+	key, wrapped, err := e.keys.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	if len(wrapped) > 0xffff {
+		return nil, nil, fmt.Errorf("wrapped data key is too long")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.current, e.wrapped, e.createdAt = aead, wrapped, e.now()
+	e.unwrapped[string(wrapped)] = aead
+	return aead, wrapped, nil
+}
+
+// unwrap returns the cipher for a wrapped data key
+func (e *Envelope) unwrap(ctx context.Context, wrapped []byte) (cipher.AEAD, error) {
+	e.mu.Lock()
+	aead, ok := e.unwrapped[string(wrapped)]
+	e.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	key, err := e.keys.DecryptDataKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	if aead, err = newAEAD(key); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.unwrapped[string(wrapped)] = aead
+	e.mu.Unlock()
+	return aead, nil
+}
+
+// IsEncrypted reports whether a value was sealed by an Envelope
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Mask hides most of a value for logs and exports: email addresses keep
+// the first character of the local part and the domain, other values keep
+// their first three and last four characters when long enough.
+//
+//	alice@example.com -> a****@example.com
+//	+8613800138000    -> +86*******8000
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	if IsEncrypted(value) {
+		return "[encrypted]"
+	}
+	if at := strings.LastIndexByte(value, '@'); at > 0 {
+		return value[:1] + strings.Repeat("*", at-1) + value[at:]
+	}
+
+	runes := []rune(value)
+	if len(runes) <= 8 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:3]) + strings.Repeat("*", len(runes)-7) + string(runes[len(runes)-4:])
+}
+
+// newAEAD creates an AES-GCM cipher
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce prepended to the ciphertext
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a value produced by seal
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,110 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingKeys counts the calls made to a key service
+type countingKeys struct {
+	KeyService
+	generated, decrypted int
+}
+
+func (c *countingKeys) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	c.generated++
+	return c.KeyService.GenerateDataKey(ctx)
+}
+
+func (c *countingKeys) DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	c.decrypted++
+	return c.KeyService.DecryptDataKey(ctx, wrapped)
+}
+
+func newTestEnvelope(t *testing.T) (*Envelope, *countingKeys) {
+	t.Helper()
+	local, err := NewLocalKeyService(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("NewLocalKeyService() error = %v", err)
+	}
+	keys := &countingKeys{KeyService: local}
+	envelope, err := NewEnvelope(EnvelopeConfig{Keys: keys, IndexKey: []byte("0123456789abcdef"), KeyRotation: time.Hour})
+	if err != nil {
+		t.Fatalf("NewEnvelope() error = %v", err)
+	}
+	return envelope, keys
+}
+
+func TestEnvelope_EncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	envelope, keys := newTestEnvelope(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	envelope.now = func() time.Time { return now }
+
+	first, err := envelope.Encrypt(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	second, _ := envelope.Encrypt(ctx, "alice@example.com")
+	if !IsEncrypted(first) || strings.Contains(first, "alice") || first == second {
+		t.Errorf("Encrypt() = %q, %q, want distinct ciphertexts without plaintext", first, second)
+	}
+
+	now = now.Add(2 * time.Hour)
+	rotated, _ := envelope.Encrypt(ctx, "+8613800138000")
+	if keys.generated != 2 {
+		t.Errorf("data keys generated = %d, want 2 after rotation", keys.generated)
+	}
+
+	// A fresh envelope has to unwrap each data key once
+	reader, _ := NewEnvelope(EnvelopeConfig{Keys: keys, IndexKey: []byte("0123456789abcdef")})
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{first, "alice@example.com"},
+		{second, "alice@example.com"},
+		{rotated, "+8613800138000"},
+		{"legacy@example.com", "legacy@example.com"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := reader.Decrypt(ctx, tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("Decrypt(%.20q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+	if keys.decrypted != 2 {
+		t.Errorf("data keys unwrapped = %d, want 2", keys.decrypted)
+	}
+
+	tampered := first[:len(first)-2] + "AA"
+	if _, err := reader.Decrypt(ctx, tampered); err == nil {
+		t.Error("Decrypt() expected error for tampered value")
+	}
+
+	if envelope.Index("alice@example.com") != reader.Index("alice@example.com") || envelope.Index("a") == envelope.Index("b") {
+		t.Error("Index() must be deterministic per value")
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"alice@example.com", "a****@example.com"},
+		{"+8613800138000", "+86*******8000"},
+		{"ou_12345", "********"},
+		{"pii:v1:abc", "[encrypted]"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Mask(tt.value); got != tt.want {
+			t.Errorf("Mask(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
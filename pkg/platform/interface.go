@@ -3,6 +3,8 @@ package platform
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/kart-io/notifyhub/pkg/message"
 	"github.com/kart-io/notifyhub/pkg/target"
@@ -41,6 +43,47 @@ type SendResult struct {
 	MessageID string        `json:"message_id,omitempty"`
 	Response  string        `json:"response,omitempty"`
 	Error     error         `json:"error,omitempty"`
+
+	// Timeout is the effective timeout the send was bounded by, recorded by
+	// the client (zero when the send was unbounded)
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// TargetError is implemented by send errors that can tell whether the
+// target itself is unreachable, such as a bounced mailbox or a webhook
+// endpoint that no longer exists, rather than temporarily failing
+type TargetError interface {
+	error
+	TargetInvalid() bool
+}
+
+// IsTargetInvalid reports whether err, or an error it wraps, marks the
+// target as permanently unreachable
+func IsTargetInvalid(err error) bool {
+	var te TargetError
+	return errors.As(err, &te) && te.TargetInvalid()
+}
+
+// AuthError is implemented by send errors that can tell whether the
+// platform rejected the credentials it was configured with, such as an
+// expired API key, rather than the message or the target
+type AuthError interface {
+	error
+	AuthFailed() bool
+}
+
+// IsAuthFailure reports whether err, or an error it wraps, is a rejection
+// of the platform credentials
+func IsAuthFailure(err error) bool {
+	var ae AuthError
+	return errors.As(err, &ae) && ae.AuthFailed()
+}
+
+// Preflighter is implemented by platforms that can verify their
+// credentials and endpoints before the first send, such as by logging in to
+// an SMTP server or checking an API token, more thoroughly than IsHealthy
+type Preflighter interface {
+	Preflight(ctx context.Context) error
 }
 
 // Factory represents a platform factory function
@@ -66,3 +109,14 @@ type Registry interface {
 	// Close all platforms
 	Close() error
 }
+
+// WithTimeout bounds a send by the timeout of a platform's configuration
+// when the context has no deadline yet. Sends through the client always
+// have one, since the client resolves the timeout of each send; this
+// bounds platforms used on their own.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
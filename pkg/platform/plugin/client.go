@@ -0,0 +1,350 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// DefaultStartTimeout bounds the start of a plugin up to its handshake
+const DefaultStartTimeout = 10 * time.Second
+
+// stopTimeout is how long a plugin may take to exit after it was closed
+// before it is killed
+const stopTimeout = 5 * time.Second
+
+// Config configures how the hub runs a plugin
+type Config struct {
+	// Command is the plugin program, and Args its arguments
+	Command string
+	Args    []string
+
+	// Env holds "KEY=value" variables added to the hub's environment
+	Env []string
+
+	// StartTimeout bounds the start up to the handshake; zero uses
+	// DefaultStartTimeout
+	StartTimeout time.Duration
+
+	// Stderr receives the plugin's output other than the handshake; nil
+	// uses the hub's standard error
+	Stderr io.Writer
+}
+
+// Factory returns a factory of platforms served by a plugin, for
+// notifyhub.Client.RegisterPlatform. The settings passed to the factory
+// are JSON encoded and handed to the plugin, which creates its platform
+// from them.
+func Factory(cfg Config) platform.Factory {
+	return func(settings interface{}) (platform.Platform, error) {
+		return Start(cfg, settings)
+	}
+}
+
+// Start starts a plugin and creates its platform from settings. The plugin
+// runs until the platform is closed; a plugin that exits is started again
+// by the next call to the platform.
+func Start(cfg Config, settings interface{}) (*Platform, error) {
+	if cfg.Command == "" {
+		return nil, errors.New("plugin command is required")
+	}
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plugin settings: %w", err)
+	}
+	p := &Platform{cfg: cfg, settings: encoded}
+	if _, err := p.process(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Platform is a platform served by a plugin process. It is safe for
+// concurrent use.
+type Platform struct {
+	cfg      Config
+	settings json.RawMessage
+
+	mu     sync.Mutex
+	proc   *process
+	name   string
+	caps   platform.Capabilities
+	closed bool
+}
+
+// process is a running plugin
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	client *rpc.Client
+	exited chan struct{}
+	lost   atomic.Bool // the connection to the plugin broke
+}
+
+// running reports whether the plugin has not exited
+func (proc *process) running() bool {
+	if proc.lost.Load() {
+		return false
+	}
+	select {
+	case <-proc.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop asks the plugin to exit by closing its connection and standard
+// input, and kills it when it does not
+func (proc *process) stop() {
+	if proc.client != nil {
+		_ = proc.client.Close()
+	}
+	_ = proc.stdin.Close()
+	select {
+	case <-proc.exited:
+	case <-time.After(stopTimeout):
+		_ = proc.cmd.Process.Kill()
+		<-proc.exited
+	}
+}
+
+// process returns the running plugin, starting it when it is not
+func (p *Platform) process() (*process, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, errors.New("plugin platform closed")
+	}
+	if p.proc != nil {
+		if p.proc.running() {
+			return p.proc, nil
+		}
+		go p.proc.stop()
+	}
+
+	proc, reply, err := start(p.cfg, p.settings)
+	if err != nil {
+		return nil, err
+	}
+	p.proc, p.name, p.caps = proc, reply.Name, reply.Capabilities
+	return proc, nil
+}
+
+// start runs a plugin, connects to it and configures its platform
+func start(cfg Config, settings json.RawMessage) (*process, *ConfigureReply, error) {
+	timeout := cfg.StartTimeout
+	if timeout <= 0 {
+		timeout = DefaultStartTimeout
+	}
+	stderr := cfg.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = append(append(os.Environ(), cfg.Env...), CookieKey+"="+CookieValue)
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start plugin %s: %w", cfg.Command, err)
+	}
+	proc := &process{cmd: cmd, stdin: stdin, exited: make(chan struct{})}
+
+	type handshake struct {
+		network, address string
+		err              error
+	}
+	reader := bufio.NewReader(stdout)
+	handshakes := make(chan handshake, 1)
+	go func() {
+		var h handshake
+		h.network, h.address, h.err = readHandshake(reader)
+		handshakes <- h
+		// The rest of the output must be read before the plugin is waited for
+		_, _ = io.Copy(stderr, reader)
+		_ = cmd.Wait()
+		close(proc.exited)
+	}()
+
+	fail := func(err error) (*process, *ConfigureReply, error) {
+		proc.stop()
+		return nil, nil, fmt.Errorf("plugin %s: %w", cfg.Command, err)
+	}
+	deadline := time.Now().Add(timeout)
+	var h handshake
+	select {
+	case h = <-handshakes:
+	case <-time.After(timeout):
+		return fail(fmt.Errorf("no handshake within %s", timeout))
+	}
+	if h.err != nil {
+		return fail(h.err)
+	}
+
+	conn, err := net.DialTimeout(h.network, h.address, time.Until(deadline))
+	if err != nil {
+		return fail(err)
+	}
+	proc.client = jsonrpc.NewClient(conn)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	var reply ConfigureReply
+	if err := call(ctx, proc, "Configure", ConfigureArgs{Settings: settings}, &reply); err != nil {
+		return fail(fmt.Errorf("failed to configure platform: %w", err))
+	}
+	return proc, &reply, nil
+}
+
+// call calls a method of the plugin, giving up when ctx is done
+func call(ctx context.Context, proc *process, method string, args, reply interface{}) error {
+	c := proc.client.Go(serviceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-c.Done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	var serverErr rpc.ServerError
+	switch {
+	case c.Error == nil:
+		return nil
+	case errors.As(c.Error, &serverErr):
+		return errors.New(string(serverErr))
+	case errors.Is(c.Error, rpc.ErrShutdown) || errors.Is(c.Error, io.ErrUnexpectedEOF) || errors.Is(c.Error, io.EOF):
+		proc.lost.Store(true)
+		return fmt.Errorf("plugin exited: %w", c.Error)
+	}
+	return c.Error
+}
+
+// call calls a method of the plugin, starting it when it is not running
+func (p *Platform) call(ctx context.Context, method string, args, reply interface{}) error {
+	proc, err := p.process()
+	if err != nil {
+		return err
+	}
+	return call(ctx, proc, method, args, reply)
+}
+
+// Name implements platform.Platform
+func (p *Platform) Name() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.name
+}
+
+// GetCapabilities implements platform.Platform
+func (p *Platform) GetCapabilities() platform.Capabilities {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.caps
+}
+
+// Send implements platform.Platform. The plugin's send is bounded by the
+// deadline of ctx; errors keep whether they mark the target invalid or the
+// credentials rejected.
+func (p *Platform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	args := SendArgs{Message: msg, Targets: targets, Timeout: remaining(ctx)}
+	var reply SendReply
+	if err := p.call(ctx, "Send", args, &reply); err != nil {
+		return nil, err
+	}
+
+	results := make([]*platform.SendResult, 0, len(reply.Results))
+	for _, r := range reply.Results {
+		result := &platform.SendResult{Target: r.Target, Success: r.Success, MessageID: r.MessageID, Response: r.Response}
+		if r.Error != "" {
+			result.Error = &Error{Message: r.Error, Invalid: r.TargetInvalid, Auth: r.AuthFailed}
+		}
+		results = append(results, result)
+	}
+	if reply.Error != "" {
+		return results, errors.New(reply.Error)
+	}
+	return results, nil
+}
+
+// ValidateTarget implements platform.Platform
+func (p *Platform) ValidateTarget(tgt target.Target) error {
+	return p.call(context.Background(), "ValidateTarget", TargetArgs{Target: tgt}, &Empty{})
+}
+
+// IsHealthy implements platform.Platform, starting a plugin that exited
+func (p *Platform) IsHealthy(ctx context.Context) error {
+	return p.call(ctx, "IsHealthy", HealthArgs{Timeout: remaining(ctx)}, &Empty{})
+}
+
+// Close implements platform.Platform, closing the plugin's platform and
+// stopping the plugin
+func (p *Platform) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.proc == nil {
+		return nil
+	}
+	if !p.proc.running() {
+		p.proc.stop()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	err := call(ctx, p.proc, "Close", Empty{}, &Empty{})
+	p.proc.stop()
+	return err
+}
+
+// Error is a send error reported by a plugin
+type Error struct {
+	Message string
+	Invalid bool // the plugin marked the target permanently unreachable
+	Auth    bool // the plugin's credentials were rejected
+}
+
+// Error implements error
+func (e *Error) Error() string { return e.Message }
+
+// TargetInvalid implements platform.TargetError
+func (e *Error) TargetInvalid() bool { return e.Invalid }
+
+// AuthFailed implements platform.AuthError
+func (e *Error) AuthFailed() bool { return e.Auth }
+
+// remaining returns the time left until the deadline of ctx, zero for none
+func remaining(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	if d := time.Until(deadline); d > 0 {
+		return d
+	}
+	return time.Nanosecond
+}
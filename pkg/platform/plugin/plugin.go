@@ -0,0 +1,319 @@
+// Package plugin runs platform senders as separate processes, so that
+// teams can add proprietary channels without recompiling the hub. A plugin
+// is a program that serves a platform:
+//
+//	func main() {
+//		plugin.Serve(func(settings interface{}) (platform.Platform, error) {
+//			var cfg PagerConfig
+//			if err := json.Unmarshal(settings.(json.RawMessage), &cfg); err != nil {
+//				return nil, err
+//			}
+//			return NewPager(cfg), nil
+//		})
+//	}
+//
+// and the hub starts it with Factory, or from the plugins section of its
+// configuration. The hub runs the command with a magic cookie in its
+// environment; the plugin listens on a loopback port and announces it on
+// its first line of output:
+//
+//	1|tcp|127.0.0.1:41234|jsonrpc
+//
+// (protocol version, network, address, RPC protocol). The hub then calls
+// the platform methods over JSON-RPC. Anything else the plugin writes to
+// its standard output or error goes to the hub's standard error. The
+// plugin exits when the hub closes the platform or goes away.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// ProtocolVersion is the version of the handshake and RPC protocol; the
+// hub refuses plugins that announce another version
+const ProtocolVersion = 1
+
+// CookieKey and CookieValue are the environment variable the hub sets for
+// the plugins it starts, so that a plugin run by hand explains itself
+// instead of waiting for a hub
+const (
+	CookieKey   = "NOTIFYHUB_PLUGIN_COOKIE"
+	CookieValue = "b3c1e7a2f5d94e0c8a6f2d1e9b7c4a53"
+)
+
+// serviceName is the name of the RPC service a plugin registers
+const serviceName = "Plugin"
+
+// ConfigureArgs carries the platform settings, JSON encoded
+type ConfigureArgs struct {
+	Settings json.RawMessage
+}
+
+// ConfigureReply describes the platform a plugin created
+type ConfigureReply struct {
+	Name         string
+	Capabilities platform.Capabilities
+}
+
+// SendArgs carries a send; Timeout is what remained of the hub's deadline
+// when it was made, zero for none
+type SendArgs struct {
+	Message *message.Message
+	Targets []target.Target
+	Timeout time.Duration
+}
+
+// SendReply carries the results of a send, and the error of a send that
+// failed as a whole
+type SendReply struct {
+	Results []Result
+	Error   string
+}
+
+// Result is a platform.SendResult in a form that crosses the process
+// boundary: the error is reduced to its message and classification
+type Result struct {
+	Target        target.Target
+	Success       bool
+	MessageID     string
+	Response      string
+	Error         string
+	TargetInvalid bool
+	AuthFailed    bool
+}
+
+// TargetArgs carries a target to validate
+type TargetArgs struct {
+	Target target.Target
+}
+
+// HealthArgs carries a health check
+type HealthArgs struct {
+	Timeout time.Duration
+}
+
+// Empty is the argument and reply of calls that carry nothing
+type Empty struct{}
+
+// Serve serves a platform to the hub that started the program, creating it
+// with factory from the settings the hub sends as a json.RawMessage. It
+// returns when the hub closes the platform or goes away, and exits the
+// program when it was not started by a hub.
+func Serve(factory platform.Factory) {
+	if os.Getenv(CookieKey) != CookieValue {
+		fmt.Fprintln(os.Stderr, "This program is a NotifyHub platform plugin. It is started by NotifyHub, see the plugins section of the configuration.")
+		os.Exit(1)
+	}
+	if err := serve(factory, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "notifyhub plugin: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// serve announces a loopback listener on out and serves the hub's
+// connection until the hub closes the platform or in reaches its end
+func serve(factory platform.Factory, in io.Reader, out io.Writer) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	svc := &service{factory: factory, done: make(chan struct{})}
+	server := rpc.NewServer()
+	if err := server.RegisterName(serviceName, svc); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(out, "%d|tcp|%s|jsonrpc\n", ProtocolVersion, listener.Addr()); err != nil {
+		return err
+	}
+
+	// The hub holds our standard input open while it runs
+	hubGone := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, in)
+		close(hubGone)
+	}()
+
+	conns := make(chan net.Conn)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conns <- conn
+		}
+	}()
+	var conn net.Conn
+	select {
+	case conn = <-conns:
+	case <-hubGone:
+		return nil
+	}
+	defer conn.Close()
+
+	served := make(chan struct{})
+	go func() {
+		server.ServeCodec(jsonrpc.NewServerCodec(conn))
+		close(served)
+	}()
+	select {
+	case <-svc.done:
+		// Let the reply to Close reach the hub, which then hangs up
+		select {
+		case <-served:
+		case <-hubGone:
+		case <-time.After(time.Second):
+		}
+	case <-served:
+	case <-hubGone:
+	}
+	_ = svc.close()
+	return nil
+}
+
+// service exposes a platform over RPC. Calls are served concurrently.
+type service struct {
+	factory  platform.Factory
+	done     chan struct{}
+	doneOnce sync.Once
+
+	mu       sync.Mutex
+	platform platform.Platform
+	closed   bool
+}
+
+// current returns the configured platform
+func (s *service) current() (platform.Platform, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.platform == nil || s.closed {
+		return nil, errNotConfigured
+	}
+	return s.platform, nil
+}
+
+// close closes the platform once
+func (s *service) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.platform == nil || s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.platform.Close()
+}
+
+// Configure creates the platform from its settings
+func (s *service) Configure(args ConfigureArgs, reply *ConfigureReply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.platform != nil {
+		return errors.New("platform already configured")
+	}
+	p, err := s.factory(args.Settings)
+	if err != nil {
+		return err
+	}
+	s.platform = p
+	reply.Name = p.Name()
+	reply.Capabilities = p.GetCapabilities()
+	return nil
+}
+
+// Send sends a message to targets
+func (s *service) Send(args SendArgs, reply *SendReply) error {
+	p, err := s.current()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(args.Timeout)
+	defer cancel()
+	results, err := p.Send(ctx, args.Message, args.Targets)
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		result := Result{Target: r.Target, Success: r.Success, MessageID: r.MessageID, Response: r.Response}
+		if r.Error != nil {
+			result.Error = r.Error.Error()
+			result.TargetInvalid = platform.IsTargetInvalid(r.Error)
+			result.AuthFailed = platform.IsAuthFailure(r.Error)
+		}
+		reply.Results = append(reply.Results, result)
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	return nil
+}
+
+// ValidateTarget validates a target
+func (s *service) ValidateTarget(args TargetArgs, _ *Empty) error {
+	p, err := s.current()
+	if err != nil {
+		return err
+	}
+	return p.ValidateTarget(args.Target)
+}
+
+// IsHealthy checks the health of the platform
+func (s *service) IsHealthy(args HealthArgs, _ *Empty) error {
+	p, err := s.current()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(args.Timeout)
+	defer cancel()
+	return p.IsHealthy(ctx)
+}
+
+// Close closes the platform and ends the plugin
+func (s *service) Close(_ Empty, _ *Empty) error {
+	defer s.doneOnce.Do(func() { close(s.done) })
+	return s.close()
+}
+
+var errNotConfigured = errors.New("platform not configured")
+
+// withTimeout returns a context bounded by timeout, unbounded for zero
+func withTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// readHandshake reads the plugin's announcement of its address
+func readHandshake(r *bufio.Reader) (network, address string, err error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", "", fmt.Errorf("plugin exited before its handshake: %w", err)
+	}
+	parts := strings.Split(strings.TrimSpace(line), "|")
+	if len(parts) != 4 {
+		return "", "", fmt.Errorf("invalid plugin handshake %q", line)
+	}
+	if version, err := strconv.Atoi(parts[0]); err != nil || version != ProtocolVersion {
+		return "", "", fmt.Errorf("plugin speaks protocol version %s, want %d", parts[0], ProtocolVersion)
+	}
+	if parts[3] != "jsonrpc" {
+		return "", "", fmt.Errorf("plugin speaks RPC protocol %q, want jsonrpc", parts[3])
+	}
+	return parts[1], parts[2], nil
+}
@@ -0,0 +1,221 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// helperEnv makes the test binary run as a plugin, see TestMain
+const helperEnv = "NOTIFYHUB_PLUGIN_TEST_MODE"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(helperEnv) {
+	case "serve":
+		Serve(newPager)
+		os.Exit(0)
+	case "silent":
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// pager is the platform served by the test plugin
+type pager struct {
+	prefix string
+}
+
+func newPager(settings interface{}) (platform.Platform, error) {
+	var cfg struct {
+		Prefix string `json:"prefix"`
+	}
+	if err := json.Unmarshal(settings.(json.RawMessage), &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Prefix == "" {
+		return nil, errors.New("prefix is required")
+	}
+	return &pager{prefix: cfg.Prefix}, nil
+}
+
+func (p *pager) Name() string { return "pager" }
+
+func (p *pager) GetCapabilities() platform.Capabilities {
+	return platform.Capabilities{Name: "pager", SupportedTargetTypes: []string{target.TargetTypeUser}}
+}
+
+func (p *pager) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	results := make([]*platform.SendResult, 0, len(targets))
+	for _, tgt := range targets {
+		switch tgt.Value {
+		case "crash":
+			os.Exit(3)
+		case "slow":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "gone":
+			results = append(results, &platform.SendResult{Target: tgt, Error: goneError{}})
+		default:
+			results = append(results, &platform.SendResult{Target: tgt, Success: true, MessageID: p.prefix + msg.ID, Response: msg.Title})
+		}
+	}
+	return results, nil
+}
+
+func (p *pager) ValidateTarget(tgt target.Target) error {
+	if tgt.Value == "" {
+		return errors.New("pager id is required")
+	}
+	return nil
+}
+
+func (p *pager) IsHealthy(context.Context) error { return nil }
+func (p *pager) Close() error                    { return nil }
+
+type goneError struct{}
+
+func (goneError) Error() string       { return "pager id not found" }
+func (goneError) TargetInvalid() bool { return true }
+
+func helperConfig(mode string) Config {
+	return Config{
+		Command:      os.Args[0],
+		Env:          []string{helperEnv + "=" + mode},
+		StartTimeout: 5 * time.Second,
+		Stderr:       io.Discard,
+	}
+}
+
+func TestPlatform(t *testing.T) {
+	p, err := Start(helperConfig("serve"), map[string]string{"prefix": "pg-"})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer p.Close()
+
+	if p.Name() != "pager" || len(p.GetCapabilities().SupportedTargetTypes) != 1 {
+		t.Errorf("Name() = %q, GetCapabilities() = %+v", p.Name(), p.GetCapabilities())
+	}
+	if err := p.ValidateTarget(target.New(target.TargetTypeUser, "", "pager")); err == nil || err.Error() != "pager id is required" {
+		t.Errorf("ValidateTarget(empty) error = %v", err)
+	}
+	if err := p.IsHealthy(context.Background()); err != nil {
+		t.Errorf("IsHealthy() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		timeout     time.Duration
+		wantResult  string // MessageID of a success, or the result error
+		wantInvalid bool
+		wantErr     string
+	}{
+		{name: "delivered", target: "alice", wantResult: "pg-m1"},
+		{name: "target invalid", target: "gone", wantResult: "pager id not found", wantInvalid: true},
+		{name: "deadline", target: "slow", timeout: 50 * time.Millisecond, wantErr: "deadline exceeded"},
+		{name: "plugin crashed", target: "crash", wantErr: "plugin exited"},
+		{name: "restarted", target: "bob", wantResult: "pg-m1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			msg := message.New()
+			msg.ID, msg.Title = "m1", "Disk full"
+			results, err := p.Send(ctx, msg, []target.Target{target.New(target.TargetTypeUser, tt.target, "pager")})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Send() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(results) != 1 {
+				t.Fatalf("Send() = %v, %v", results, err)
+			}
+			r := results[0]
+			if r.Success {
+				if r.MessageID != tt.wantResult || r.Response != "Disk full" {
+					t.Errorf("result = %+v, want message ID %q", r, tt.wantResult)
+				}
+				return
+			}
+			if r.Error == nil || r.Error.Error() != tt.wantResult || platform.IsTargetInvalid(r.Error) != tt.wantInvalid {
+				t.Errorf("result error = %v, want %q (invalid %v)", r.Error, tt.wantResult, tt.wantInvalid)
+			}
+		})
+	}
+
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if _, err := p.Send(context.Background(), message.New(), nil); err == nil {
+		t.Error("Send() after Close() succeeded")
+	}
+}
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		settings interface{}
+		wantErr  string
+	}{
+		{name: "no command", cfg: Config{}, wantErr: "command is required"},
+		{name: "missing program", cfg: Config{Command: "/nonexistent/notifyhub-pager"}, wantErr: "failed to start plugin"},
+		{name: "no handshake", cfg: helperConfig("silent"), wantErr: "exited before its handshake"},
+		{name: "factory error", cfg: helperConfig("serve"), settings: map[string]string{}, wantErr: "prefix is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Start(tt.cfg, tt.settings)
+			if err == nil {
+				p.Close()
+				t.Fatal("Start() succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Start() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadHandshake(t *testing.T) {
+	tests := []struct {
+		line    string
+		wantErr string
+	}{
+		{line: "1|tcp|127.0.0.1:4000|jsonrpc\n"},
+		{line: "2|tcp|127.0.0.1:4000|jsonrpc\n", wantErr: "protocol version 2"},
+		{line: "1|tcp|127.0.0.1:4000|grpc\n", wantErr: `RPC protocol "grpc"`},
+		{line: "listening on 4000\n", wantErr: "invalid plugin handshake"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			network, address, err := readHandshake(bufio.NewReader(strings.NewReader(tt.line)))
+			if tt.wantErr == "" {
+				if err != nil || network != "tcp" || address != "127.0.0.1:4000" {
+					t.Errorf("readHandshake() = %q, %q, %v", network, address, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readHandshake() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -107,11 +107,35 @@ func (r *registryImpl) Health(ctx context.Context) map[string]error {
 
 	health := make(map[string]error)
 	for name, instance := range r.instances {
-		health[name] = instance.IsHealthy(ctx)
+		health[name] = checkHealth(ctx, instance)
 	}
 	return health
 }
 
+// checkHealth checks a platform instance, reporting a panic as unhealthy
+func checkHealth(ctx context.Context, p Platform) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("health check panicked: %v", recovered)
+		}
+	}()
+	return p.IsHealthy(ctx)
+}
+
+// Preflight verifies a platform instance with its Preflighter, or its
+// health check when it has none, reporting a panic as a failure
+func Preflight(ctx context.Context, p Platform) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("preflight check panicked: %v", recovered)
+		}
+	}()
+	if preflighter, ok := p.(Preflighter); ok {
+		return preflighter.Preflight(ctx)
+	}
+	return p.IsHealthy(ctx)
+}
+
 // Close closes all platform instances
 func (r *registryImpl) Close() error {
 	r.mu.Lock()
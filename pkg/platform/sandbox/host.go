@@ -0,0 +1,234 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// Policy defaults
+const (
+	DefaultMaxRequests      = 10
+	DefaultMaxRequestBytes  = 1 << 20
+	DefaultMaxResponseBytes = 1 << 20
+	DefaultRequestTimeout   = 10 * time.Second
+)
+
+// Policy limits what a guest may do through its host
+type Policy struct {
+	// AllowedHosts are the hosts the guest may send HTTP requests to: host
+	// names, host:port pairs, or names starting with a dot, which match
+	// their subdomains. Requests to any other host are refused.
+	AllowedHosts []string
+
+	// AllowHTTP permits plain HTTP; by default only HTTPS is allowed
+	AllowHTTP bool
+
+	// MaxRequests caps the HTTP requests of one call into the guest; zero
+	// uses DefaultMaxRequests
+	MaxRequests int
+
+	// MaxRequestBytes and MaxResponseBytes cap the bodies of requests and
+	// responses; zero uses the defaults
+	MaxRequestBytes  int64
+	MaxResponseBytes int64
+
+	// RequestTimeout bounds each request; zero uses DefaultRequestTimeout
+	RequestTimeout time.Duration
+}
+
+// allows reports whether the policy permits a request URL
+func (p Policy) allows(u *url.URL) error {
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && p.AllowHTTP:
+	default:
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		switch {
+		case strings.HasPrefix(allowed, "."):
+			if strings.HasSuffix(host, allowed) || host == allowed[1:] {
+				return nil
+			}
+		case strings.Contains(allowed, ":"):
+			if strings.ToLower(u.Host) == allowed {
+				return nil
+			}
+		case host == allowed:
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is not allowed", u.Host)
+}
+
+// HTTPRequest is the input of the host's "http_request"
+type HTTPRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// HTTPResponse is the output of the host's "http_request". Error is set
+// when the request was refused or failed.
+type HTTPResponse struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Host provides the host functions of a guest, enforcing its policy
+type Host struct {
+	name   string
+	policy Policy
+	client *http.Client
+	logger logger.Logger
+}
+
+// NewHost creates the host of a guest running a platform
+func NewHost(name string, policy Policy, log logger.Logger) *Host {
+	if policy.MaxRequests <= 0 {
+		policy.MaxRequests = DefaultMaxRequests
+	}
+	if policy.MaxRequestBytes <= 0 {
+		policy.MaxRequestBytes = DefaultMaxRequestBytes
+	}
+	if policy.MaxResponseBytes <= 0 {
+		policy.MaxResponseBytes = DefaultMaxResponseBytes
+	}
+	if policy.RequestTimeout <= 0 {
+		policy.RequestTimeout = DefaultRequestTimeout
+	}
+	if log == nil {
+		log = logger.Discard
+	}
+
+	h := &Host{name: name, policy: policy, logger: log}
+	h.client = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return h.policy.allows(req.URL)
+		},
+	}
+	return h
+}
+
+// budgetKey holds the remaining requests of a call into the guest
+type budgetKey struct{}
+
+// withBudget bounds the requests made during a call into the guest
+func (h *Host) withBudget(ctx context.Context) context.Context {
+	budget := new(atomic.Int64)
+	budget.Store(int64(h.policy.MaxRequests))
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// Call runs a host function for a runtime: FunctionHTTPRequest takes and
+// returns JSON, FunctionLog takes the JSON of {"level": ..., "message": ...}.
+// Refusals are reported to the guest in the output, not as errors.
+func (h *Host) Call(ctx context.Context, function string, input []byte) ([]byte, error) {
+	switch function {
+	case FunctionHTTPRequest:
+		var req HTTPRequest
+		if err := json.Unmarshal(input, &req); err != nil {
+			return json.Marshal(HTTPResponse{Error: "invalid request: " + err.Error()})
+		}
+		return json.Marshal(h.HTTP(ctx, req))
+	case FunctionLog:
+		var entry struct {
+			Level   string `json:"level"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(input, &entry); err != nil {
+			return nil, err
+		}
+		h.Log(entry.Level, entry.Message)
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown host function %s", function)
+}
+
+// HTTP sends a request for the guest if the policy allows it
+func (h *Host) HTTP(ctx context.Context, req HTTPRequest) HTTPResponse {
+	refuse := func(err error) HTTPResponse {
+		h.logger.Warn("Sandboxed request refused", "platform", h.name, "url", req.URL, "error", err)
+		return HTTPResponse{Error: err.Error()}
+	}
+
+	if budget, ok := ctx.Value(budgetKey{}).(*atomic.Int64); ok && budget.Add(-1) < 0 {
+		return refuse(fmt.Errorf("request limit of %d reached", h.policy.MaxRequests))
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return refuse(err)
+	}
+	if err := h.policy.allows(u); err != nil {
+		return refuse(err)
+	}
+	if int64(len(req.Body)) > h.policy.MaxRequestBytes {
+		return refuse(fmt.Errorf("request body of %d bytes exceeds %d", len(req.Body), h.policy.MaxRequestBytes))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.policy.RequestTimeout)
+	defer cancel()
+	method := req.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader([]byte(req.Body)))
+	if err != nil {
+		return refuse(err)
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return HTTPResponse{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, h.policy.MaxResponseBytes+1))
+	if err != nil {
+		return HTTPResponse{Status: resp.StatusCode, Error: err.Error()}
+	}
+	if int64(len(body)) > h.policy.MaxResponseBytes {
+		return HTTPResponse{Status: resp.StatusCode, Error: fmt.Sprintf("response body exceeds %d bytes", h.policy.MaxResponseBytes)}
+	}
+
+	headers := make(map[string]string, len(resp.Header))
+	for k := range resp.Header {
+		headers[k] = resp.Header.Get(k)
+	}
+	return HTTPResponse{Status: resp.StatusCode, Headers: headers, Body: string(body)}
+}
+
+// Log writes a message of the guest to the hub's log
+func (h *Host) Log(level, msg string) {
+	switch level {
+	case "error":
+		h.logger.Error(msg, "platform", h.name, "sandbox", true)
+	case "warn":
+		h.logger.Warn(msg, "platform", h.name, "sandbox", true)
+	case "debug":
+		h.logger.Debug(msg, "platform", h.name, "sandbox", true)
+	default:
+		h.logger.Info(msg, "platform", h.name, "sandbox", true)
+	}
+}
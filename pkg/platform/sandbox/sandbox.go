@@ -0,0 +1,270 @@
+// Package sandbox runs platform senders compiled to WebAssembly, so that
+// untrusted integrations, such as those provided by tenants, cannot reach
+// the memory, files or network of the hub. A guest module reaches the
+// outside only through the host functions of a Host, whose Policy limits
+// the hosts it may call and the size and number of its requests.
+//
+// NotifyHub does not link a WebAssembly runtime itself; a Runtime adapts
+// one to the guest. The wazero subpackage, a module of its own, adapts
+// wazero, and examples/sandbox-guest is a guest written in Go.
+//
+// Guests and the host exchange JSON through the linear memory of the
+// guest, as a 32-bit pointer and a length. Functions returning data return
+// both packed in an i64, ptr<<32 | len, where 0 means no data. The guest
+// exports:
+//
+//   - "memory", its linear memory
+//   - "alloc(size i32) i32", returning size bytes the host may write, or 0
+//     when it cannot allocate them
+//   - "free(ptr i32, size i32)", optional, releasing memory the guest
+//     handed to the host or that the host allocated with alloc
+//   - "capabilities() i64", returning the JSON of a platform.Capabilities
+//   - "send(ptr i32, len i32) i64", taking the JSON of a SendRequest and
+//     returning the JSON of a SendResponse
+//
+// The host writes the input of an export to memory from alloc and frees it
+// after the call; it copies the output out and frees it. The host provides
+// the module "notifyhub" with:
+//
+//   - "http_request(ptr i32, len i32) i64", taking the JSON of an
+//     HTTPRequest and returning the JSON of an HTTPResponse, which the host
+//     writes to memory from alloc and the guest frees
+//   - "log(ptr i32, len i32)", taking the JSON of {"level": ..., "message": ...}
+//
+// Runtimes implement them with Host.Call, passing the context of the call.
+// They must bound the memory of modules and stop them when the context of
+// a call is done.
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// HostModule is the name of the module of host functions guests import
+const HostModule = "notifyhub"
+
+// Host function names
+const (
+	FunctionHTTPRequest = "http_request"
+	FunctionLog         = "log"
+)
+
+// Runtime instantiates WebAssembly modules
+type Runtime interface {
+	// Instantiate compiles a module and links the host functions of host
+	Instantiate(ctx context.Context, wasm []byte, host *Host) (Module, error)
+}
+
+// Module is an instance of a guest module
+type Module interface {
+	// Call calls an exported function with a JSON argument and returns its
+	// JSON result
+	Call(ctx context.Context, function string, input []byte) ([]byte, error)
+
+	// Close releases the instance
+	Close(ctx context.Context) error
+}
+
+// SendRequest is the input of the guest's "send"
+type SendRequest struct {
+	Message  *message.Message  `json:"message"`
+	Targets  []target.Target   `json:"targets"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// SendResponse is the output of the guest's "send"
+type SendResponse struct {
+	Results []Result `json:"results"`
+	Error   string   `json:"error,omitempty"` // the send failed as a whole
+}
+
+// Result is the outcome of a send to a target
+type Result struct {
+	Target        target.Target `json:"target"`
+	Success       bool          `json:"success"`
+	MessageID     string        `json:"message_id,omitempty"`
+	Response      string        `json:"response,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	TargetInvalid bool          `json:"target_invalid,omitempty"`
+}
+
+// Config configures a sandboxed platform
+type Config struct {
+	// Module is the compiled guest
+	Module []byte
+
+	// Policy limits what the guest may do through the host
+	Policy Policy
+
+	// Settings are handed to every send of the guest
+	Settings map[string]string
+}
+
+// Factory returns a factory of sandboxed platforms running module, for
+// notifyhub.Client.RegisterPlatform. The factory expects a Config, or
+// settings as a map[string]string that are added to cfg's.
+func Factory(name string, rt Runtime, cfg Config, log logger.Logger) platform.Factory {
+	return func(section interface{}) (platform.Platform, error) {
+		c := cfg
+		switch s := section.(type) {
+		case Config:
+			c = s
+		case *Config:
+			c = *s
+		case map[string]string:
+			settings := make(map[string]string, len(cfg.Settings)+len(s))
+			for k, v := range cfg.Settings {
+				settings[k] = v
+			}
+			for k, v := range s {
+				settings[k] = v
+			}
+			c.Settings = settings
+		case nil:
+		default:
+			return nil, fmt.Errorf("sandbox %s: unsupported configuration %T", name, section)
+		}
+		return NewPlatform(context.Background(), name, rt, c, log)
+	}
+}
+
+// Platform is a platform whose sender runs in a sandbox. Calls into the
+// guest are serialized, as a module instance runs one call at a time.
+type Platform struct {
+	name     string
+	caps     platform.Capabilities
+	settings map[string]string
+	host     *Host
+
+	mu     sync.Mutex
+	module Module
+	closed bool
+}
+
+// NewPlatform instantiates a guest module and reads its capabilities
+func NewPlatform(ctx context.Context, name string, rt Runtime, cfg Config, log logger.Logger) (*Platform, error) {
+	if rt == nil {
+		return nil, errors.New("sandbox runtime is required")
+	}
+	if len(cfg.Module) == 0 {
+		return nil, fmt.Errorf("sandbox %s: module is required", name)
+	}
+	if log == nil {
+		log = logger.Discard
+	}
+
+	host := NewHost(name, cfg.Policy, log)
+	module, err := rt.Instantiate(ctx, cfg.Module, host)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox %s: failed to instantiate module: %w", name, err)
+	}
+
+	output, err := module.Call(host.withBudget(ctx), "capabilities", nil)
+	if err != nil {
+		_ = module.Close(ctx)
+		return nil, fmt.Errorf("sandbox %s: capabilities: %w", name, err)
+	}
+	var caps platform.Capabilities
+	if err := json.Unmarshal(output, &caps); err != nil {
+		_ = module.Close(ctx)
+		return nil, fmt.Errorf("sandbox %s: invalid capabilities: %w", name, err)
+	}
+	caps.Name = name
+	return &Platform{name: name, caps: caps, settings: cfg.Settings, host: host, module: module}, nil
+}
+
+// Name implements platform.Platform
+func (p *Platform) Name() string {
+	return p.name
+}
+
+// GetCapabilities implements platform.Platform
+func (p *Platform) GetCapabilities() platform.Capabilities {
+	return p.caps
+}
+
+// Send implements platform.Platform
+func (p *Platform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	input, err := json.Marshal(SendRequest{Message: msg, Targets: targets, Settings: p.settings})
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("sandbox %s: platform closed", p.name)
+	}
+	output, err := p.module.Call(p.host.withBudget(ctx), "send", input)
+	p.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("sandbox %s: %w", p.name, err)
+	}
+
+	var response SendResponse
+	if err := json.Unmarshal(output, &response); err != nil {
+		return nil, fmt.Errorf("sandbox %s: invalid send response: %w", p.name, err)
+	}
+	results := make([]*platform.SendResult, 0, len(response.Results))
+	for _, r := range response.Results {
+		result := &platform.SendResult{Target: r.Target, Success: r.Success, MessageID: r.MessageID, Response: r.Response}
+		if r.Error != "" {
+			result.Error = &guestError{message: r.Error, invalid: r.TargetInvalid}
+		}
+		results = append(results, result)
+	}
+	if response.Error != "" {
+		return results, errors.New(response.Error)
+	}
+	return results, nil
+}
+
+// ValidateTarget implements platform.Platform, accepting the target types
+// of the guest's capabilities
+func (p *Platform) ValidateTarget(tgt target.Target) error {
+	for _, t := range p.caps.SupportedTargetTypes {
+		if t == tgt.Type {
+			return nil
+		}
+	}
+	return fmt.Errorf("sandbox %s does not support target type %s", p.name, tgt.Type)
+}
+
+// IsHealthy implements platform.Platform
+func (p *Platform) IsHealthy(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("sandbox %s: platform closed", p.name)
+	}
+	return nil
+}
+
+// Close implements platform.Platform, releasing the module instance
+func (p *Platform) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	return p.module.Close(context.Background())
+}
+
+// guestError is a send error reported by a guest
+type guestError struct {
+	message string
+	invalid bool
+}
+
+func (e *guestError) Error() string       { return e.message }
+func (e *guestError) TargetInvalid() bool { return e.invalid }
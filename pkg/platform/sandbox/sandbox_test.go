@@ -0,0 +1,176 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// fakeRuntime stands in for a WebAssembly runtime: its guest posts the
+// message title to each target URL through the host
+type fakeRuntime struct{}
+
+func (fakeRuntime) Instantiate(_ context.Context, wasm []byte, host *Host) (Module, error) {
+	if string(wasm) != "\x00asm" {
+		return nil, errors.New("invalid module")
+	}
+	return &fakeModule{host: host}, nil
+}
+
+type fakeModule struct {
+	host   *Host
+	closed bool
+}
+
+func (m *fakeModule) Call(ctx context.Context, function string, input []byte) ([]byte, error) {
+	switch function {
+	case "capabilities":
+		return []byte(`{"supported_target_types":["webhook"]}`), nil
+	case "send":
+		var req SendRequest
+		if err := json.Unmarshal(input, &req); err != nil {
+			return nil, err
+		}
+		var resp SendResponse
+		for _, tgt := range req.Targets {
+			in, _ := json.Marshal(HTTPRequest{URL: tgt.Value, Body: req.Settings["prefix"] + req.Message.Title})
+			out, err := m.host.Call(ctx, FunctionHTTPRequest, in)
+			if err != nil {
+				return nil, err
+			}
+			var httpResp HTTPResponse
+			if err := json.Unmarshal(out, &httpResp); err != nil {
+				return nil, err
+			}
+			result := Result{Target: tgt, Success: httpResp.Error == "" && httpResp.Status == http.StatusOK, Response: httpResp.Body, Error: httpResp.Error}
+			if httpResp.Status == http.StatusNotFound {
+				result.Error, result.TargetInvalid = "endpoint not found", true
+			}
+			resp.Results = append(resp.Results, result)
+		}
+		return json.Marshal(resp)
+	}
+	return nil, errors.New("unknown export " + function)
+}
+
+func (m *fakeModule) Close(context.Context) error {
+	m.closed = true
+	return nil
+}
+
+func TestPlatform(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/large":
+			_, _ = w.Write([]byte(strings.Repeat("x", 64)))
+		case "/escape":
+			http.Redirect(w, r, "http://evil.example.com/", http.StatusFound)
+		default:
+			buf := make([]byte, 64)
+			n, _ := r.Body.Read(buf)
+			_, _ = w.Write(buf[:n])
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	policy := Policy{AllowedHosts: []string{u.Hostname()}, AllowHTTP: true, MaxRequests: 2, MaxResponseBytes: 32}
+
+	p, err := NewPlatform(context.Background(), "tenant-chat", fakeRuntime{}, Config{Module: []byte("\x00asm"), Policy: policy, Settings: map[string]string{"prefix": "> "}}, nil)
+	if err != nil {
+		t.Fatalf("NewPlatform() error = %v", err)
+	}
+	if caps := p.GetCapabilities(); caps.Name != "tenant-chat" || p.ValidateTarget(target.NewWebhook(server.URL)) != nil || p.ValidateTarget(target.NewEmail("a@example.com")) == nil {
+		t.Errorf("GetCapabilities() = %+v", caps)
+	}
+
+	tests := []struct {
+		name        string
+		targets     []string
+		wantResults []string // response, or error
+		wantInvalid bool
+	}{
+		{name: "allowed host", targets: []string{server.URL + "/hook"}, wantResults: []string{"> Deploy"}},
+		{name: "other host", targets: []string{"https://evil.example.com/hook"}, wantResults: []string{`host "evil.example.com" is not allowed`}},
+		{name: "plain http to an allowed name on another port", targets: []string{"http://" + u.Hostname() + ":1/hook"}, wantResults: []string{"connection refused"}},
+		{name: "request limit", targets: []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"}, wantResults: []string{"> Deploy", "> Deploy", "request limit of 2 reached"}},
+		{name: "response too large", targets: []string{server.URL + "/large"}, wantResults: []string{"response body exceeds 32 bytes"}},
+		{name: "redirect to another host", targets: []string{server.URL + "/escape"}, wantResults: []string{`host "evil.example.com" is not allowed`}},
+		{name: "target invalid", targets: []string{server.URL + "/missing"}, wantResults: []string{"endpoint not found"}, wantInvalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := message.New()
+			msg.Title = "Deploy"
+			var targets []target.Target
+			for _, value := range tt.targets {
+				targets = append(targets, target.NewWebhook(value))
+			}
+			results, err := p.Send(context.Background(), msg, targets)
+			if err != nil || len(results) != len(tt.wantResults) {
+				t.Fatalf("Send() = %v, %v", results, err)
+			}
+			for i, r := range results {
+				got := r.Response
+				if r.Error != nil {
+					got = r.Error.Error()
+					if invalid := r.Error.(*guestError).TargetInvalid(); invalid != tt.wantInvalid {
+						t.Errorf("result %d TargetInvalid() = %v, want %v", i, invalid, tt.wantInvalid)
+					}
+				}
+				if !strings.Contains(got, tt.wantResults[i]) {
+					t.Errorf("result %d = %q, want %q", i, got, tt.wantResults[i])
+				}
+			}
+		})
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := p.Send(context.Background(), message.New(), nil); err == nil {
+		t.Error("Send() after Close() succeeded")
+	}
+	if _, err := NewPlatform(context.Background(), "broken", fakeRuntime{}, Config{Module: []byte("ELF")}, nil); err == nil {
+		t.Error("NewPlatform() with an invalid module succeeded")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := Policy{AllowedHosts: []string{"api.example.com", ".slack.com", "hooks.example.org:8443"}}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://api.example.com/v1", want: true},
+		{url: "https://API.example.com/v1", want: true},
+		{url: "https://hooks.slack.com/services/x", want: true},
+		{url: "https://slack.com/", want: true},
+		{url: "https://notslack.com/", want: false},
+		{url: "https://hooks.example.org:8443/", want: true},
+		{url: "https://hooks.example.org/", want: false},
+		{url: "http://api.example.com/v1", want: false},
+		{url: "file:///etc/passwd", want: false},
+		{url: "https://169.254.169.254/latest/meta-data", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := policy.allows(u); (err == nil) != tt.want {
+				t.Errorf("allows(%s) = %v, want allowed %v", tt.url, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/kart-io/notifyhub/pkg/platform/sandbox/wazero
+
+go 1.23.0
+
+// 使用本地 NotifyHub 包进行开发
+replace github.com/kart-io/notifyhub => ../../../../
+
+require (
+	github.com/kart-io/notifyhub v0.0.0-00010101000000-000000000000
+	github.com/tetratelabs/wazero v1.10.1
+)
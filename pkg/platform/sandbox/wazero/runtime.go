@@ -0,0 +1,272 @@
+// Package wazero runs sandboxed platforms with wazero, a WebAssembly
+// runtime written in Go, implementing sandbox.Runtime with the ptr/len ABI
+// described by the sandbox package.
+//
+// Guests compiled for wasip1 are given the WASI imports without files,
+// environment or arguments; their standard output and error are discarded.
+// Reactors, such as Go guests built with -buildmode=c-shared, are
+// initialized through their "_initialize" export.
+//
+// The adapter is a module of its own, so that NotifyHub does not depend on
+// wazero:
+//
+//	go get github.com/kart-io/notifyhub/pkg/platform/sandbox/wazero
+package wazero
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/kart-io/notifyhub/pkg/platform/sandbox"
+)
+
+// DefaultMemoryLimitPages bounds the memory of a guest to 64 MiB, in pages
+// of 64 KiB
+const DefaultMemoryLimitPages = 1024
+
+// Guest exports of the ABI besides those called by sandbox.Platform
+const (
+	exportAlloc      = "alloc"
+	exportFree       = "free"
+	exportInitialize = "_initialize"
+)
+
+// Option configures a Runtime
+type Option func(*Runtime)
+
+// WithMemoryLimit bounds the memory of each guest, in pages of 64 KiB. A
+// guest declaring more memory fails to instantiate, and one growing beyond
+// it fails its allocation.
+func WithMemoryLimit(pages uint32) Option {
+	return func(r *Runtime) {
+		r.memoryLimitPages = pages
+	}
+}
+
+// WithCompilationCache shares compiled guests between runtimes, such as
+// the platforms of several tenants running the same guest
+func WithCompilationCache(cache wazero.CompilationCache) Option {
+	return func(r *Runtime) {
+		r.cache = cache
+	}
+}
+
+// Runtime is a sandbox.Runtime backed by wazero. Each instantiated guest
+// runs in a wazero runtime of its own, which is closed with it.
+type Runtime struct {
+	memoryLimitPages uint32
+	cache            wazero.CompilationCache
+}
+
+// New creates a runtime
+func New(opts ...Option) *Runtime {
+	r := &Runtime{memoryLimitPages: DefaultMemoryLimitPages}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Instantiate implements sandbox.Runtime
+func (r *Runtime) Instantiate(ctx context.Context, wasm []byte, host *sandbox.Host) (sandbox.Module, error) {
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(r.memoryLimitPages).
+		WithCloseOnContextDone(true)
+	if r.cache != nil {
+		config = config.WithCompilationCache(r.cache)
+	}
+	rt := wazero.NewRuntimeWithConfig(ctx, config)
+
+	m := &Module{runtime: rt}
+	if err := m.link(ctx, host); err != nil {
+		_ = rt.Close(ctx)
+		return nil, err
+	}
+	compiled, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		_ = rt.Close(ctx)
+		return nil, err
+	}
+	m.compiled = compiled
+	if err := m.instantiate(ctx); err != nil {
+		_ = rt.Close(ctx)
+		return nil, err
+	}
+	return m, nil
+}
+
+// Module is a guest instantiated by a Runtime. Its calls must not overlap,
+// which sandbox.Platform ensures.
+type Module struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	module   api.Module
+}
+
+// link instantiates the WASI and host functions the guest may import
+func (m *Module) link(ctx context.Context, host *sandbox.Host) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, m.runtime); err != nil {
+		return err
+	}
+	i32, i64 := api.ValueTypeI32, api.ValueTypeI64
+	_, err := m.runtime.NewHostModuleBuilder(sandbox.HostModule).
+		NewFunctionBuilder().
+		WithGoModuleFunction(hostFunction(host, sandbox.FunctionHTTPRequest, true), []api.ValueType{i32, i32}, []api.ValueType{i64}).
+		Export(sandbox.FunctionHTTPRequest).
+		NewFunctionBuilder().
+		WithGoModuleFunction(hostFunction(host, sandbox.FunctionLog, false), []api.ValueType{i32, i32}, nil).
+		Export(sandbox.FunctionLog).
+		Instantiate(ctx)
+	return err
+}
+
+// instantiate starts a fresh instance of the guest, as one whose call was
+// stopped by its context is closed by wazero
+func (m *Module) instantiate(ctx context.Context) error {
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions(exportInitialize).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+	module, err := m.runtime.InstantiateModule(ctx, m.compiled, config)
+	if err != nil {
+		return err
+	}
+	if module.ExportedFunction(exportAlloc) == nil {
+		_ = module.Close(ctx)
+		return fmt.Errorf("guest does not export %s", exportAlloc)
+	}
+	m.module = module
+	return nil
+}
+
+// Call implements sandbox.Module. Exports taking two parameters receive
+// input as a pointer and a length; others are called without parameters.
+func (m *Module) Call(ctx context.Context, function string, input []byte) ([]byte, error) {
+	if m.module == nil || m.module.IsClosed() {
+		if err := m.instantiate(ctx); err != nil {
+			return nil, fmt.Errorf("failed to restart guest: %w", err)
+		}
+	}
+	module := m.module
+	fn := module.ExportedFunction(function)
+	if fn == nil {
+		return nil, fmt.Errorf("guest does not export %s", function)
+	}
+
+	var params []uint64
+	if len(fn.Definition().ParamTypes()) == 2 {
+		packed, err := write(ctx, module, input)
+		if err != nil {
+			return nil, m.callError(ctx, function, err)
+		}
+		ptr, size := unpack(packed)
+		defer free(ctx, module, ptr, size)
+		params = []uint64{api.EncodeU32(ptr), api.EncodeU32(size)}
+	}
+	results, err := fn.Call(ctx, params...)
+	if err != nil {
+		return nil, m.callError(ctx, function, err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	ptr, size := unpack(results[0])
+	output, err := read(module, ptr, size)
+	if err != nil {
+		return nil, m.callError(ctx, function, err)
+	}
+	free(ctx, module, ptr, size)
+	return output, nil
+}
+
+// callError reports a failed call, preferring the error of its context
+func (m *Module) callError(ctx context.Context, function string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	return fmt.Errorf("%s: %w", function, err)
+}
+
+// Close implements sandbox.Module, closing the wazero runtime of the guest
+func (m *Module) Close(ctx context.Context) error {
+	return m.runtime.Close(ctx)
+}
+
+// hostFunction adapts a host function of host to the ABI. Its failures
+// trap, failing the call into the guest.
+func hostFunction(host *sandbox.Host, function string, returns bool) api.GoModuleFunc {
+	return func(ctx context.Context, module api.Module, stack []uint64) {
+		input, err := read(module, api.DecodeU32(stack[0]), api.DecodeU32(stack[1]))
+		if err != nil {
+			panic(err)
+		}
+		output, err := host.Call(ctx, function, input)
+		if err != nil {
+			panic(fmt.Errorf("%s: %w", function, err))
+		}
+		if !returns {
+			return
+		}
+		packed, err := write(ctx, module, output)
+		if err != nil {
+			panic(fmt.Errorf("%s: %w", function, err))
+		}
+		stack[0] = packed
+	}
+}
+
+// read copies data out of the memory of the guest
+func read(module api.Module, ptr, size uint32) ([]byte, error) {
+	if size == 0 {
+		return nil, nil
+	}
+	data, ok := module.Memory().Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("guest memory %d+%d is out of range", ptr, size)
+	}
+	return bytes.Clone(data), nil
+}
+
+// write copies data into memory allocated by the guest, returning the
+// packed pointer and length
+func write(ctx context.Context, module api.Module, data []byte) (uint64, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	results, err := module.ExportedFunction(exportAlloc).Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", exportAlloc, err)
+	}
+	ptr := api.DecodeU32(results[0])
+	if ptr == 0 {
+		return 0, fmt.Errorf("guest failed to allocate %d bytes", len(data))
+	}
+	if !module.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("guest memory %d+%d is out of range", ptr, len(data))
+	}
+	return uint64(ptr)<<32 | uint64(len(data)), nil
+}
+
+// free releases memory the guest allocated, if it exports free. Errors are
+// ignored, as the instance may have been closed by the context.
+func free(ctx context.Context, module api.Module, ptr, size uint32) {
+	fn := module.ExportedFunction(exportFree)
+	if fn == nil || size == 0 || module.IsClosed() {
+		return
+	}
+	_, _ = fn.Call(ctx, api.EncodeU32(ptr), api.EncodeU32(size))
+}
+
+// unpack splits a packed pointer and length
+func unpack(packed uint64) (ptr, size uint32) {
+	return uint32(packed >> 32), uint32(packed)
+}
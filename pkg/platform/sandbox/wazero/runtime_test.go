@@ -0,0 +1,111 @@
+package wazero
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform/sandbox"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// buildGuest compiles the example guest to WebAssembly
+func buildGuest(t *testing.T) []byte {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not available")
+	}
+	out := filepath.Join(t.TempDir(), "guest.wasm")
+	cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", out, ".")
+	cmd.Dir = filepath.Join("..", "..", "..", "..", "examples", "sandbox-guest")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building the guest: %v\n%s", err, output)
+	}
+	wasm, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wasm
+}
+
+func TestRuntime(t *testing.T) {
+	wasm := buildGuest(t)
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	cfg := sandbox.Config{
+		Module:   wasm,
+		Policy:   sandbox.Policy{AllowedHosts: []string{u.Host}, AllowHTTP: true},
+		Settings: map[string]string{"prefix": "> "},
+	}
+	p, err := sandbox.NewPlatform(context.Background(), "guest", New(), cfg, nil)
+	if err != nil {
+		t.Fatalf("NewPlatform() error = %v", err)
+	}
+	defer p.Close()
+	if err := p.ValidateTarget(target.NewWebhook(server.URL)); err != nil {
+		t.Errorf("ValidateTarget() error = %v", err)
+	}
+
+	msg := message.New()
+	msg.Title = "Deploy"
+	targets := []target.Target{
+		target.NewWebhook(server.URL + "/hook"),
+		target.NewWebhook(server.URL + "/missing"),
+		target.NewWebhook("https://evil.example.com/hook"),
+	}
+	results, err := p.Send(context.Background(), msg, targets)
+	if err != nil || len(results) != 3 {
+		t.Fatalf("Send() = %v, %v", results, err)
+	}
+	if !results[0].Success || results[0].Response != "ok" {
+		t.Errorf("result 0 = %+v, want a success", results[0])
+	}
+	if results[1].Error == nil || !results[1].Error.(interface{ TargetInvalid() bool }).TargetInvalid() {
+		t.Errorf("result 1 error = %v, want an invalid target", results[1].Error)
+	}
+	if results[2].Error == nil || !strings.Contains(results[2].Error.Error(), "is not allowed") {
+		t.Errorf("result 2 error = %v, want a refusal", results[2].Error)
+	}
+	if len(received) != 1 || received[0] != "> Deploy" {
+		t.Errorf("received %q, want the prefixed title", received)
+	}
+
+	// A call stopped by its context closes the instance; the next call
+	// runs in a fresh one
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Send(ctx, msg, targets[:1]); err == nil {
+		t.Error("Send() with a cancelled context succeeded")
+	}
+	if results, err := p.Send(context.Background(), msg, targets[:1]); err != nil || len(results) != 1 || !results[0].Success {
+		t.Errorf("Send() after a cancelled call = %v, %v", results, err)
+	}
+
+	if _, err := sandbox.NewPlatform(context.Background(), "small", New(WithMemoryLimit(1)), cfg, nil); err == nil {
+		t.Error("NewPlatform() beyond the memory limit succeeded")
+	}
+	if _, err := sandbox.NewPlatform(context.Background(), "broken", New(), sandbox.Config{Module: []byte("\x00asm")}, nil); err == nil {
+		t.Error("NewPlatform() with an invalid module succeeded")
+	}
+}
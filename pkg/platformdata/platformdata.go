@@ -0,0 +1,86 @@
+// Package platformdata provides typed access to the platform-specific data
+// of messages, instead of type assertions on Message.PlatformData:
+//
+//	platformdata.Set(msg, feishu.Card{Elements: elements})
+//	card, ok := platformdata.Get[feishu.Card](msg)
+//
+// Each type is held under one key of PlatformData: the key a platform
+// registered for it with Register, such as "feishu_card", or the package
+// path and name of the type. Get also decodes the values of messages that
+// went through JSON, such as queued or archived ones, whose data became
+// maps and slices.
+package platformdata
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// keys holds the registered keys by type
+var keys sync.Map
+
+// Register sets the PlatformData key of values of type T, for platforms
+// whose data predates this package; it is called from init functions
+func Register[T any](key string) {
+	keys.Store(reflect.TypeFor[T](), key)
+}
+
+// Key returns the PlatformData key of values of type T
+func Key[T any]() string {
+	t := reflect.TypeFor[T]()
+	if key, ok := keys.Load(t); ok {
+		return key.(string)
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+	return t.String()
+}
+
+// Set sets the value of type T of a message
+func Set[T any](msg *message.Message, value T) *message.Message {
+	return msg.SetPlatformData(Key[T](), value)
+}
+
+// Get returns the value of type T of a message, reporting false when it
+// has none or it cannot be converted to T
+func Get[T any](msg *message.Message) (T, bool) {
+	var zero T
+	if msg == nil {
+		return zero, false
+	}
+	data, ok := msg.PlatformData[Key[T]()]
+	if !ok || data == nil {
+		return zero, false
+	}
+	switch v := data.(type) {
+	case T:
+		return v, true
+	case *T:
+		if v != nil {
+			return *v, true
+		}
+		return zero, false
+	}
+
+	// Decoded from JSON
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return zero, false
+	}
+	var value T
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return zero, false
+	}
+	return value, true
+}
+
+// Delete removes the value of type T of a message
+func Delete[T any](msg *message.Message) {
+	if msg != nil {
+		delete(msg.PlatformData, Key[T]())
+	}
+}
@@ -0,0 +1,85 @@
+package platformdata
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+type card struct {
+	Title    string   `json:"title"`
+	Elements []string `json:"elements"`
+}
+
+type registered struct {
+	Level int `json:"level"`
+}
+
+func init() {
+	Register[registered]("test_registered")
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"named type", Key[card](), "github.com/kart-io/notifyhub/pkg/platformdata.card"},
+		{"registered type", Key[registered](), "test_registered"},
+		{"unnamed type", Key[[]string](), "[]string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("Key() = %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	want := card{Title: "Deploy", Elements: []string{"a", "b"}}
+
+	msg := message.New()
+	if _, ok := Get[card](msg); ok {
+		t.Error("Get() of a message without data reported ok")
+	}
+	Set(msg, want)
+	Set(msg, registered{Level: 2})
+	if got, ok := Get[card](msg); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %+v, %v, want %+v", got, ok, want)
+	}
+	if got, ok := Get[registered](msg); !ok || got.Level != 2 || msg.PlatformData["test_registered"] == nil {
+		t.Errorf("Get() of a registered type = %+v, %v", got, ok)
+	}
+
+	// Pointers and values decoded from JSON are converted
+	pointer := message.New().SetPlatformData(Key[card](), &want)
+	if got, ok := Get[card](pointer); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() of a pointer = %+v, %v", got, ok)
+	}
+	data, _ := json.Marshal(msg)
+	var decoded message.Message
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := Get[card](&decoded); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() after JSON = %+v, %v", got, ok)
+	}
+
+	mismatched := message.New().SetPlatformData(Key[registered](), "not a struct")
+	if _, ok := Get[registered](mismatched); ok {
+		t.Error("Get() of data of another type reported ok")
+	}
+
+	Delete[card](msg)
+	if _, ok := Get[card](msg); ok {
+		t.Error("Get() after Delete() reported ok")
+	}
+	if _, ok := Get[card](nil); ok {
+		t.Error("Get() of a nil message reported ok")
+	}
+}
@@ -0,0 +1,165 @@
+// Package email provides email attachments for NotifyHub
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// DefaultMaxAttachmentSize is the largest total size of the attachments of
+// a message when the configuration sets none
+const DefaultMaxAttachmentSize = 10 * 1024 * 1024 // 10MB
+
+// ErrAttachmentTooLarge is returned when the attachments of a message
+// exceed the configured size
+var ErrAttachmentTooLarge = errors.New("attachments too large")
+
+// FileAttachment creates an attachment streamed from a file when the
+// message is sent, so that the file is not held in memory
+func FileAttachment(path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if info.IsDir() {
+		return Attachment{}, fmt.Errorf("attachment %s is a directory", path)
+	}
+	return ReaderAttachment(filepath.Base(path), func() (io.Reader, error) {
+		return os.Open(path)
+	}), nil
+}
+
+// ReaderAttachment creates an attachment streamed from the reader open
+// returns. open is called for every send of the message, such as once per
+// recipient, and the reader is closed after it when it is an io.Closer.
+func ReaderAttachment(name string, open func() (io.Reader, error)) Attachment {
+	return Attachment{Name: name, ContentType: contentTypeOf(name), Open: open}
+}
+
+// InlineFile creates an image attachment streamed from a file that an HTML
+// body shows with a cid: URL, such as <img src="cid:logo">
+func InlineFile(path, contentID string) (Attachment, error) {
+	att, err := FileAttachment(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	att.Inline = true
+	att.ContentID = contentID
+	return att, nil
+}
+
+// AddAttachment adds attachments to the email data of a message:
+//
+//	att, err := email.FileAttachment("report.pdf")
+//	if err != nil {
+//		return err
+//	}
+//	email.AddAttachment(msg, att)
+func AddAttachment(msg *message.Message, attachments ...Attachment) {
+	if len(attachments) == 0 {
+		return
+	}
+	data, _ := msg.PlatformData["email"].(map[string]interface{})
+	if data == nil {
+		data = make(map[string]interface{})
+		msg.SetPlatformData("email", data)
+	}
+
+	var list []interface{}
+	switch existing := data["attachments"].(type) {
+	case []interface{}:
+		list = existing
+	case []Attachment:
+		for _, att := range existing {
+			list = append(list, att)
+		}
+	}
+	for _, att := range attachments {
+		list = append(list, att)
+	}
+	data["attachments"] = list
+}
+
+// open returns the reader of the attachment content
+func (a Attachment) open() (io.Reader, error) {
+	if a.Open == nil {
+		return bytes.NewReader(a.Content), nil
+	}
+	r, err := a.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open attachment %s: %w", a.Name, err)
+	}
+	return r, nil
+}
+
+// contentTypeOf returns the content type of a file name
+func contentTypeOf(name string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// checkAttachmentSize fails fast when the in-memory attachments already
+// exceed limit; streamed attachments are counted while they are written
+func (m *Message) checkAttachmentSize(limit int64) error {
+	var size int64
+	for _, att := range m.Attachments {
+		if att.Open != nil {
+			continue
+		}
+		size += int64(len(att.Content))
+		if size > limit {
+			return fmt.Errorf("%w: %s exceeds the limit of %d bytes", ErrAttachmentTooLarge, att.Name, limit)
+		}
+	}
+	return nil
+}
+
+// lineWriter breaks base64 output into lines of 76 characters
+type lineWriter struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := 76 - l.col
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		l.col += n
+		p = p[n:]
+		if l.col == 76 {
+			if _, err := io.WriteString(l.w, "\r\n"); err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+	}
+	return written, nil
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
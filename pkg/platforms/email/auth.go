@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/smtp"
 	"strings"
+	"time"
 )
 
 // AuthHandler handles email authentication
@@ -27,6 +28,10 @@ func (a *AuthHandler) GetAuth() smtp.Auth {
 		return nil
 	}
 
+	if a.config.TokenSource != nil {
+		return a.xoauth2Auth()
+	}
+
 	switch strings.ToLower(a.config.AuthMethod) {
 	case "plain":
 		return smtp.PlainAuth("", a.config.Username, a.config.Password, a.config.SMTPHost)
@@ -46,8 +51,30 @@ func (a *AuthHandler) GetAuth() smtp.Auth {
 	}
 }
 
+// xoauth2Auth returns the XOAUTH2 auth of the token source, which gets
+// its token within the connection timeout
+func (a *AuthHandler) xoauth2Auth() smtp.Auth {
+	timeout := 30 * time.Second
+	if a.config.Timeout != nil && *a.config.Timeout > 0 {
+		timeout = *a.config.Timeout
+	}
+	return &xoauth2Auth{
+		username: a.config.Username,
+		source:   a.config.TokenSource,
+		host:     a.config.SMTPHost,
+		timeout:  timeout,
+	}
+}
+
 // GetTLSConfig returns the TLS configuration
 func (a *AuthHandler) GetTLSConfig() *tls.Config {
+	if a.config.TLS != nil {
+		config := a.config.TLS.Clone()
+		if config.ServerName == "" {
+			config.ServerName = a.config.SMTPHost
+		}
+		return config
+	}
 	return &tls.Config{
 		ServerName:         a.config.SMTPHost,
 		InsecureSkipVerify: a.config.SkipCertVerify,
@@ -65,6 +92,11 @@ func (a *AuthHandler) ValidateAuth() error {
 		return fmt.Errorf("username is required for authentication")
 	}
 
+	// OAuth2 replaces the password
+	if a.config.TokenSource != nil {
+		return nil
+	}
+
 	if a.config.Password == "" {
 		return fmt.Errorf("password is required for authentication")
 	}
@@ -211,6 +243,7 @@ const (
 	AuthMethodPlain   AuthMethod = "plain"
 	AuthMethodLogin   AuthMethod = "login"
 	AuthMethodCRAMMD5 AuthMethod = "cram-md5"
+	AuthMethodXOAUTH2 AuthMethod = "xoauth2"
 )
 
 // GetSupportedAuthMethods returns list of supported authentication methods
@@ -219,6 +252,7 @@ func GetSupportedAuthMethods() []AuthMethod {
 		AuthMethodPlain,
 		AuthMethodLogin,
 		AuthMethodCRAMMD5,
+		AuthMethodXOAUTH2,
 	}
 }
 
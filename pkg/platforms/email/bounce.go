@@ -0,0 +1,378 @@
+// Package email provides bounce, complaint and reply recognition for NotifyHub
+package email
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// HeaderMessageID is the header carrying the NotifyHub message ID of sent
+// emails, which bounces that quote the original headers are correlated by
+const HeaderMessageID = "X-NotifyHub-Message-ID"
+
+// Kinds of feedback about sent messages
+const (
+	FeedbackHardBounce = "hard_bounce" // permanently undeliverable, e.g. unknown mailbox
+	FeedbackSoftBounce = "soft_bounce" // given up after transient failures, e.g. full mailbox
+	FeedbackDelayed    = "delayed"     // still being retried by a server
+	FeedbackComplaint  = "complaint"   // recipient reported the message as spam
+	FeedbackReply      = "reply"       // recipient answered the message
+	FeedbackAutoReply  = "auto_reply"  // automatic answer, such as an out-of-office notice
+)
+
+// Feedback is a bounce, complaint or reply received about a sent message
+type Feedback struct {
+	Kind string `json:"kind"`
+
+	// MessageID is the NotifyHub ID of the sent message, empty when the
+	// feedback could not be correlated
+	MessageID string `json:"message_id,omitempty"`
+
+	// Recipient is the address that bounced, complained or replied
+	Recipient string `json:"recipient,omitempty"`
+
+	Status     string    `json:"status,omitempty"`     // enhanced status code of a bounce, e.g. 5.1.1
+	Diagnostic string    `json:"diagnostic,omitempty"` // server response of a bounce
+	Subject    string    `json:"subject,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// FeedbackParser recognizes delivery status notifications (RFC 3464),
+// abuse reports (RFC 5965) and replies among received emails and
+// correlates them to the messages they are about
+type FeedbackParser struct {
+	// BounceAddress is the envelope sender of sent messages; with VERP the
+	// message ID and recipient are read back from the address a bounce was
+	// sent to
+	BounceAddress string
+
+	// Domain is the domain of the Message-IDs of sent messages, the domain
+	// of the From address. Replies are correlated through In-Reply-To and
+	// References only to Message-IDs of this domain; empty accepts any.
+	Domain string
+}
+
+// Parse returns the feedback in a received email, none when it is neither
+// a bounce, a complaint nor a reply. A bounce reports one feedback per
+// recipient.
+func (p *FeedbackParser) Parse(r io.Reader) ([]Feedback, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email: %w", err)
+	}
+
+	received := time.Now()
+	if date, err := msg.Header.Date(); err == nil {
+		received = date
+	}
+	subject := decodeHeader(msg.Header.Get("Subject"))
+
+	// VERP addresses carry the message ID and recipient of a bounce
+	var verpID, verpRecipient string
+	for _, field := range []string{"Delivered-To", "X-Original-To", "To"} {
+		addresses, _ := msg.Header.AddressList(field)
+		for _, address := range addresses {
+			if id, rcpt, ok := ParseVERP(p.BounceAddress, address.Address); ok {
+				verpID, verpRecipient = id, rcpt
+				break
+			}
+		}
+		if verpID != "" {
+			break
+		}
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType == "multipart/report" && params["boundary"] != "" {
+		feedback, err := p.parseReport(msg.Body, params)
+		if err != nil {
+			return nil, err
+		}
+		for i := range feedback {
+			if feedback[i].MessageID == "" {
+				feedback[i].MessageID = verpID
+			}
+			if feedback[i].Recipient == "" {
+				feedback[i].Recipient = verpRecipient
+			}
+			feedback[i].Subject = subject
+			feedback[i].ReceivedAt = received
+		}
+		return feedback, nil
+	}
+
+	from := ""
+	if address, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		from = address.Address
+	}
+
+	// Automatic answers are sent to the envelope sender as well (RFC
+	// 3834), so they are told apart before anything at a VERP address is
+	// taken for a bounce
+	if isAutoReply(msg.Header) {
+		id := verpID
+		if id == "" {
+			id = p.referencedID(msg.Header)
+		}
+		if id == "" {
+			return nil, nil
+		}
+		return []Feedback{{Kind: FeedbackAutoReply, MessageID: id, Recipient: from, Subject: subject, ReceivedAt: received}}, nil
+	}
+
+	// Servers that predate delivery status notifications still return
+	// undeliverable messages to the envelope sender
+	if verpID != "" {
+		return []Feedback{{Kind: FeedbackHardBounce, MessageID: verpID, Recipient: verpRecipient, Diagnostic: subject, Subject: subject, ReceivedAt: received}}, nil
+	}
+
+	if id := p.referencedID(msg.Header); id != "" {
+		return []Feedback{{Kind: FeedbackReply, MessageID: id, Recipient: from, Subject: subject, ReceivedAt: received}}, nil
+	}
+	return nil, nil
+}
+
+// parseReport parses the parts of a multipart/report
+func (p *FeedbackParser) parseReport(body io.Reader, params map[string]string) ([]Feedback, error) {
+	var (
+		status   []textproto.MIMEHeader
+		original textproto.MIMEHeader
+	)
+
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report: %w", err)
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch mediaType {
+		case "message/delivery-status", "message/global-delivery-status", "message/feedback-report":
+			if status, err = readFieldBlocks(partReader(part)); err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", mediaType, err)
+			}
+		case "message/rfc822", "message/global", "text/rfc822-headers", "message/rfc822-headers":
+			// Only the headers of the original message are of interest
+			blocks, err := readFieldBlocks(io.LimitReader(partReader(part), 64*1024))
+			if err == nil && len(blocks) > 0 {
+				original = blocks[0]
+			}
+		}
+	}
+
+	id := ""
+	if original != nil {
+		id = strings.Trim(original.Get(HeaderMessageID), " <>")
+		if id == "" {
+			id = messageIDLocalPart(original.Get("Message-ID"), "")
+		}
+	}
+
+	if strings.EqualFold(params["report-type"], "feedback-report") {
+		if len(status) == 0 {
+			return nil, nil
+		}
+		recipient := addressOf(status[0].Get("Original-Rcpt-To"))
+		if recipient == "" && original != nil {
+			recipient = addressOf(original.Get("To"))
+		}
+		return []Feedback{{Kind: FeedbackComplaint, MessageID: id, Recipient: recipient}}, nil
+	}
+
+	// The first block describes the message, the others one recipient each
+	var feedback []Feedback
+	for i := 1; i < len(status); i++ {
+		fields := status[i]
+		var kind string
+		code := strings.TrimSpace(fields.Get("Status"))
+		switch strings.ToLower(strings.TrimSpace(fields.Get("Action"))) {
+		case "failed":
+			kind = FeedbackHardBounce
+			if strings.HasPrefix(code, "4") {
+				kind = FeedbackSoftBounce
+			}
+		case "delayed":
+			kind = FeedbackDelayed
+		default:
+			// delivered, relayed and expanded are not failures
+			continue
+		}
+
+		recipient := addressOf(fields.Get("Final-Recipient"))
+		if recipient == "" {
+			recipient = addressOf(fields.Get("Original-Recipient"))
+		}
+		feedback = append(feedback, Feedback{
+			Kind:       kind,
+			MessageID:  id,
+			Recipient:  recipient,
+			Status:     code,
+			Diagnostic: diagnosticOf(fields.Get("Diagnostic-Code")),
+		})
+	}
+	return feedback, nil
+}
+
+// referencedID returns the NotifyHub message ID a reply refers to
+func (p *FeedbackParser) referencedID(header mail.Header) string {
+	references := header.Get("In-Reply-To") + " " + header.Get("References")
+	for _, ref := range strings.Fields(references) {
+		if id := messageIDLocalPart(ref, p.Domain); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// VERPAddress returns the envelope sender of a message to a recipient with
+// the message ID and the recipient encoded in the bounce address (variable
+// envelope return path): bounces@example.com becomes
+// bounces+<message id>=alice=example.org@example.com. Without a recipient
+// only the message ID is encoded; message IDs containing "=" cannot be
+// encoded and leave the bounce address as is.
+func VERPAddress(bounceAddress, messageID, recipient string) string {
+	at := strings.LastIndex(bounceAddress, "@")
+	if at < 0 || messageID == "" || strings.ContainsAny(messageID, "=@ ") {
+		return bounceAddress
+	}
+	token := messageID
+	if recipient != "" {
+		token += "=" + strings.Replace(recipient, "@", "=", 1)
+	}
+	return bounceAddress[:at] + "+" + token + bounceAddress[at:]
+}
+
+// ParseVERP returns the message ID and recipient encoded by VERPAddress in
+// an address, reporting whether address is a VERP address of bounceAddress
+func ParseVERP(bounceAddress, address string) (messageID, recipient string, ok bool) {
+	at := strings.LastIndex(bounceAddress, "@")
+	addrAt := strings.LastIndex(address, "@")
+	if at < 0 || addrAt < 0 || !strings.EqualFold(address[addrAt:], bounceAddress[at:]) {
+		return "", "", false
+	}
+	prefix := bounceAddress[:at] + "+"
+	local := address[:addrAt]
+	if len(local) <= len(prefix) || !strings.EqualFold(local[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	token := local[len(prefix):]
+	messageID, rest, found := strings.Cut(token, "=")
+	if found {
+		if i := strings.LastIndex(rest, "="); i > 0 {
+			recipient = rest[:i] + "@" + rest[i+1:]
+		}
+	}
+	return messageID, recipient, messageID != ""
+}
+
+// isAutoReply reports whether a message was sent automatically
+func isAutoReply(header mail.Header) bool {
+	if auto := strings.ToLower(header.Get("Auto-Submitted")); auto != "" && auto != "no" {
+		return true
+	}
+	if header.Get("X-Autoreply") != "" || header.Get("X-Autorespond") != "" {
+		return true
+	}
+	precedence := strings.ToLower(header.Get("Precedence"))
+	return precedence == "auto_reply" || precedence == "bulk" || precedence == "junk"
+}
+
+// messageIDLocalPart returns the local part of a <local@domain> message ID,
+// which is the NotifyHub message ID of sent messages, when domain is empty
+// or matches
+func messageIDLocalPart(messageID, domain string) string {
+	messageID = strings.Trim(strings.TrimSpace(messageID), "<>")
+	at := strings.LastIndex(messageID, "@")
+	if at <= 0 {
+		return ""
+	}
+	if domain != "" && !strings.EqualFold(messageID[at+1:], domain) {
+		return ""
+	}
+	return messageID[:at]
+}
+
+// addressOf returns the address of a DSN recipient field such as
+// "rfc822; alice@example.com", or of an address header
+func addressOf(field string) string {
+	if _, address, found := strings.Cut(field, ";"); found {
+		field = address
+	}
+	field = strings.TrimSpace(field)
+	if address, err := mail.ParseAddress(field); err == nil {
+		return address.Address
+	}
+	return strings.Trim(field, "<>")
+}
+
+// diagnosticOf returns the server response of a Diagnostic-Code field such
+// as "smtp; 550 5.1.1 user unknown"
+func diagnosticOf(field string) string {
+	if _, diagnostic, found := strings.Cut(field, ";"); found {
+		field = diagnostic
+	}
+	return strings.Join(strings.Fields(field), " ")
+}
+
+// decodeHeader decodes RFC 2047 encoded words of a header value
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// partReader returns the content of a part decoded from base64; the
+// multipart reader decodes quoted-printable itself
+func partReader(part *multipart.Part) io.Reader {
+	if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+		return base64.NewDecoder(base64.StdEncoding, part)
+	}
+	return part
+}
+
+// readFieldBlocks reads blocks of header fields separated by blank lines,
+// the format of delivery status and feedback reports
+func readFieldBlocks(r io.Reader) ([]textproto.MIMEHeader, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+	var blocks []textproto.MIMEHeader
+	for {
+		// Blank lines between blocks are not always single
+		line, err := tp.R.Peek(1)
+		if err == nil && (line[0] == '\r' || line[0] == '\n') {
+			if _, err := tp.ReadLine(); err != nil {
+				return blocks, nil
+			}
+			continue
+		}
+
+		fields, err := tp.ReadMIMEHeader()
+		if len(fields) > 0 {
+			blocks = append(blocks, fields)
+		}
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			if len(blocks) > 0 {
+				// Tolerate trailing garbage after the status blocks
+				return blocks, nil
+			}
+			return nil, err
+		}
+	}
+}
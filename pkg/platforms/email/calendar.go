@@ -0,0 +1,170 @@
+// Package email provides iCalendar invitations for NotifyHub
+package email
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// Calendar methods of an invitation
+const (
+	CalendarMethodRequest = "REQUEST" // invite, or update with a higher sequence
+	CalendarMethodCancel  = "CANCEL"  // cancel an invitation sent before
+)
+
+// CalendarEvent is a meeting or maintenance window sent as an iCalendar
+// invitation, which mail clients show with Accept and Decline buttons
+type CalendarEvent struct {
+	// UID identifies the event across updates and cancellations; empty
+	// derives it from the message ID
+	UID string `json:"uid,omitempty"`
+
+	Summary     string    `json:"summary,omitempty"` // empty uses the message title
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+
+	// Organizer is the address replies go to; empty uses the sender
+	Organizer     string `json:"organizer,omitempty"`
+	OrganizerName string `json:"organizer_name,omitempty"`
+
+	// Attendees are invited; empty invites the To and CC recipients
+	Attendees []string `json:"attendees,omitempty"`
+
+	// Method is REQUEST (the default) or CANCEL
+	Method string `json:"method,omitempty"`
+
+	// Sequence increases with each update of the event
+	Sequence int `json:"sequence,omitempty"`
+}
+
+// AddCalendarEvent sends a message as an invitation to an event:
+//
+//	email.AddCalendarEvent(msg, email.CalendarEvent{
+//		Summary:  "Database maintenance",
+//		Start:    start,
+//		End:      start.Add(2 * time.Hour),
+//		Location: "https://meet.example.com/ops",
+//	})
+func AddCalendarEvent(msg *message.Message, event CalendarEvent) {
+	data, _ := msg.PlatformData["email"].(map[string]interface{})
+	if data == nil {
+		data = make(map[string]interface{})
+		msg.SetPlatformData("email", data)
+	}
+	data["calendar"] = event
+}
+
+// Validate checks the event
+func (e *CalendarEvent) Validate() error {
+	if e.Start.IsZero() || e.End.IsZero() {
+		return fmt.Errorf("calendar event start and end are required")
+	}
+	if !e.End.After(e.Start) {
+		return fmt.Errorf("calendar event must end after it starts")
+	}
+	switch e.method() {
+	case CalendarMethodRequest, CalendarMethodCancel:
+	default:
+		return fmt.Errorf("invalid calendar method %q, expected REQUEST or CANCEL", e.Method)
+	}
+	if e.Organizer == "" {
+		return fmt.Errorf("calendar event organizer is required")
+	}
+	return nil
+}
+
+// method returns the calendar method of the event
+func (e *CalendarEvent) method() string {
+	if e.Method == "" {
+		return CalendarMethodRequest
+	}
+	return strings.ToUpper(e.Method)
+}
+
+// ICS returns the event as an iCalendar (RFC 5545) object stamped at now
+func (e *CalendarEvent) ICS(now time.Time) []byte {
+	var b strings.Builder
+	line := func(content string) {
+		b.WriteString(foldICSLine(content))
+		b.WriteString("\r\n")
+	}
+
+	status := "CONFIRMED"
+	if e.method() == CalendarMethodCancel {
+		status = "CANCELLED"
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("PRODID:-//NotifyHub//NotifyHub//EN")
+	line("VERSION:2.0")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:" + e.method())
+	line("BEGIN:VEVENT")
+	line("UID:" + escapeICSText(e.UID))
+	line("DTSTAMP:" + formatICSTime(now))
+	line("DTSTART:" + formatICSTime(e.Start))
+	line("DTEND:" + formatICSTime(e.End))
+	line(fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+	line("STATUS:" + status)
+	line("SUMMARY:" + escapeICSText(e.Summary))
+	if e.Description != "" {
+		line("DESCRIPTION:" + escapeICSText(e.Description))
+	}
+	if e.Location != "" {
+		line("LOCATION:" + escapeICSText(e.Location))
+	}
+	organizer := "ORGANIZER"
+	if e.OrganizerName != "" {
+		organizer += ";CN=" + quoteICSParam(e.OrganizerName)
+	}
+	line(organizer + ":mailto:" + e.Organizer)
+	for _, attendee := range e.Attendees {
+		line("ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:" + attendee)
+	}
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// formatICSTime formats a time in UTC as iCalendar requires
+func formatICSTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escapeICSText escapes a TEXT value
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// quoteICSParam quotes a parameter value, which cannot hold double quotes
+func quoteICSParam(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
+
+// foldICSLine folds a content line into lines of at most 75 octets, the
+// continuations starting with a space, without splitting UTF-8 characters
+func foldICSLine(s string) string {
+	if len(s) <= 75 {
+		return s
+	}
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // the leading space counts
+	}
+	b.WriteString(s)
+	return b.String()
+}
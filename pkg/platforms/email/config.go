@@ -2,6 +2,7 @@
 package email
 
 import (
+	"crypto/tls"
 	"fmt"
 	"strings"
 	"time"
@@ -17,23 +18,36 @@ type Config struct {
 	Username string `json:"username" yaml:"username"`
 	Password string `json:"password" yaml:"password"`
 
+	// TokenSource authenticates the username with XOAUTH2 access tokens
+	// instead of the password
+	TokenSource TokenSource `json:"-" yaml:"-"`
+
 	// Email settings
 	From       string `json:"from" yaml:"from"`
 	FromName   string `json:"from_name,omitempty" yaml:"from_name,omitempty"`
 	ReplyTo    string `json:"reply_to,omitempty" yaml:"reply_to,omitempty"`
 	ReturnPath string `json:"return_path,omitempty" yaml:"return_path,omitempty"`
 
+	// VERP encodes the message ID and recipient in the ReturnPath envelope
+	// sender of every message, so that bounces are correlated to them
+	VERP bool `json:"verp,omitempty" yaml:"verp,omitempty"`
+
 	// Security settings
 	UseTLS         bool `json:"use_tls" yaml:"use_tls"`
 	UseStartTLS    bool `json:"use_starttls" yaml:"use_starttls"`
 	SkipCertVerify bool `json:"skip_cert_verify,omitempty" yaml:"skip_cert_verify,omitempty"`
 
+	// TLS replaces the default TLS configuration of SMTP connections, e.g.
+	// with a private CA or a client certificate
+	TLS *tls.Config `json:"-" yaml:"-"`
+
 	// Connection settings
 	Timeout      *time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
 	MaxRetries   *int           `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
-	KeepAlive    bool           `json:"keep_alive,omitempty" yaml:"keep_alive,omitempty"`
-	PoolSize     int            `json:"pool_size,omitempty" yaml:"pool_size,omitempty"`
-	MaxIdleConns int            `json:"max_idle_conns,omitempty" yaml:"max_idle_conns,omitempty"`
+	KeepAlive    bool           `json:"keep_alive,omitempty" yaml:"keep_alive,omitempty"`         // reuse connections across sends
+	PoolSize     int            `json:"pool_size,omitempty" yaml:"pool_size,omitempty"`           // most connections open at once
+	MaxIdleConns int            `json:"max_idle_conns,omitempty" yaml:"max_idle_conns,omitempty"` // most connections kept for reuse
+	IdleTimeout  time.Duration  `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty"`     // zero uses DefaultIdleTimeout
 
 	// Message settings
 	DefaultSubject string            `json:"default_subject,omitempty" yaml:"default_subject,omitempty"`
@@ -41,6 +55,10 @@ type Config struct {
 	Encoding       string            `json:"encoding,omitempty" yaml:"encoding,omitempty"`
 	ContentType    string            `json:"content_type,omitempty" yaml:"content_type,omitempty"`
 
+	// MaxAttachmentSize is the largest total size in bytes of the
+	// attachments of a message; zero uses DefaultMaxAttachmentSize
+	MaxAttachmentSize int64 `json:"max_attachment_size,omitempty" yaml:"max_attachment_size,omitempty"`
+
 	// Template settings
 	TemplateDir     string `json:"template_dir,omitempty" yaml:"template_dir,omitempty"`
 	DefaultTemplate string `json:"default_template,omitempty" yaml:"default_template,omitempty"`
@@ -53,7 +71,7 @@ type Config struct {
 	// Advanced settings
 	LocalName   string `json:"local_name,omitempty" yaml:"local_name,omitempty"`
 	Helo        string `json:"helo,omitempty" yaml:"helo,omitempty"`
-	AuthMethod  string `json:"auth_method,omitempty" yaml:"auth_method,omitempty"` // "plain", "login", "cram-md5"
+	AuthMethod  string `json:"auth_method,omitempty" yaml:"auth_method,omitempty"` // "plain", "login", "cram-md5", "xoauth2"
 	DSN         bool   `json:"dsn,omitempty" yaml:"dsn,omitempty"`                 // Delivery Status Notification
 	TrackOpens  bool   `json:"track_opens,omitempty" yaml:"track_opens,omitempty"`
 	TrackClicks bool   `json:"track_clicks,omitempty" yaml:"track_clicks,omitempty"`
@@ -89,6 +107,12 @@ func validateStringInList(value string, validList []string, fieldName string) er
 
 // validateAuthFields validates authentication fields
 func (c *Config) validateAuthFields() error {
+	if c.TokenSource != nil {
+		if c.Username == "" {
+			return fmt.Errorf("username is required for OAuth2 authentication")
+		}
+		return nil
+	}
 	if c.Username != "" || c.Password != "" {
 		if c.Username == "" {
 			return fmt.Errorf("username is required when password is provided")
@@ -104,10 +128,13 @@ func (c *Config) validateAuthFields() error {
 func (c *Config) validateOptionalFields() error {
 	// Validate auth method
 	if c.AuthMethod != "" {
-		validMethods := []string{"plain", "login", "cram-md5"}
+		validMethods := []string{"plain", "login", "cram-md5", "xoauth2"}
 		if err := validateStringInList(c.AuthMethod, validMethods, "auth_method"); err != nil {
 			return err
 		}
+		if c.AuthMethod == "xoauth2" && c.TokenSource == nil {
+			return fmt.Errorf("auth_method xoauth2 requires a token source")
+		}
 	}
 
 	// Validate encoding
@@ -135,9 +162,26 @@ func (c *Config) validateOptionalFields() error {
 		return fmt.Errorf("burst_limit cannot be negative")
 	}
 
+	if c.MaxAttachmentSize < 0 {
+		return fmt.Errorf("max_attachment_size cannot be negative")
+	}
+
+	if c.PoolSize < 0 || c.MaxIdleConns < 0 || c.IdleTimeout < 0 {
+		return fmt.Errorf("pool_size, max_idle_conns and idle_timeout cannot be negative")
+	}
+
 	return nil
 }
 
+// attachmentLimit returns the largest total size of the attachments of a
+// message
+func (c *Config) attachmentLimit() int64 {
+	if c.MaxAttachmentSize > 0 {
+		return c.MaxAttachmentSize
+	}
+	return DefaultMaxAttachmentSize
+}
+
 // applyDefaults sets default values for optional configuration fields
 func (c *Config) applyDefaults() {
 	if c.Timeout == nil {
@@ -212,7 +256,7 @@ func (c *Config) GetRateLimitWindow() time.Duration {
 
 // IsAuthRequired returns true if authentication is configured
 func (c *Config) IsAuthRequired() bool {
-	return c.Username != "" && c.Password != ""
+	return c.Username != "" && (c.Password != "" || c.TokenSource != nil)
 }
 
 // GetServerAddress returns the complete server address
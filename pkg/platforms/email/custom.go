@@ -34,6 +34,7 @@ type CustomEmailConfig struct {
 	// Custom settings
 	Templates       map[string]*EmailTemplate `json:"templates,omitempty"`
 	DefaultTemplate string                    `json:"default_template,omitempty"`
+	Assets          map[string]string         `json:"assets,omitempty"` // image files HTML templates embed, by content ID
 
 	// Advanced features
 	EnableTracking bool              `json:"enable_tracking,omitempty"`
@@ -105,6 +106,13 @@ func NewCustomEmailSender(config *CustomEmailConfig, logger logger.Logger) (*Cus
 		}
 	}
 
+	// Add the images templates embed
+	for contentID, path := range config.Assets {
+		if err := templateMgr.AddAssetFile(contentID, path); err != nil {
+			logger.Warn("添加模板资源失败", "asset", contentID, "error", err)
+		}
+	}
+
 	// Create rate limiter if configured
 	var rateLimiter *RateLimiter
 	if config.RateLimit > 0 {
@@ -190,7 +198,11 @@ func (ces *CustomEmailSender) SendCustomEmail(ctx context.Context, options *Cust
 		msg.PlatformData = make(map[string]interface{})
 	}
 
-	emailData := make(map[string]interface{})
+	// Keep the data the template set, such as its inline images
+	emailData, _ := msg.PlatformData["email"].(map[string]interface{})
+	if emailData == nil {
+		emailData = make(map[string]interface{})
+	}
 	if ces.config.CustomHeaders != nil {
 		emailData["headers"] = ces.config.CustomHeaders
 	}
@@ -218,6 +230,7 @@ func (ces *CustomEmailSender) SendCustomEmail(ctx context.Context, options *Cust
 	}
 
 	msg.PlatformData["email"] = emailData
+	AddAttachment(msg, options.Attachments...)
 
 	// Send to each recipient
 	successCount := 0
@@ -364,6 +377,10 @@ type CustomEmailOptions struct {
 	Variables  map[string]interface{} `json:"variables,omitempty"`
 	CustomData map[string]interface{} `json:"custom_data,omitempty"`
 	ScheduleAt *time.Time             `json:"schedule_at,omitempty"`
+
+	// Attachments are sent to every recipient; streamed attachments are
+	// opened once per recipient
+	Attachments []Attachment `json:"attachments,omitempty"`
 }
 
 // CustomEmailResult represents the result of sending custom emails
@@ -81,6 +81,17 @@ func (e *EmailError) IsRetryable() bool {
 	return e.Retryable
 }
 
+// TargetInvalid reports whether the recipient address was rejected, so that
+// retrying the same address is pointless
+func (e *EmailError) TargetInvalid() bool {
+	return e.Type == ErrorTypeRecipient
+}
+
+// AuthFailed reports whether the server rejected the login credentials
+func (e *EmailError) AuthFailed() bool {
+	return e.Type == ErrorTypeAuth || e.Type == ErrorTypeCredentials
+}
+
 // GetSuggestions returns troubleshooting suggestions for the error
 func (e *EmailError) GetSuggestions() []string {
 	return e.Suggestions
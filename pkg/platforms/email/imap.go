@@ -0,0 +1,273 @@
+// Package email provides a minimal IMAP client for NotifyHub
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxIMAPLiteral caps the size of a fetched message
+const maxIMAPLiteral = 32 * 1024 * 1024
+
+// imapResponse is an untagged server response with the literals it holds
+type imapResponse struct {
+	text     string
+	literals [][]byte
+}
+
+// imapClient speaks the subset of IMAP4rev1 (RFC 3501) needed to read a
+// mailbox: login, select, search, fetch, store and expunge
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+	tls  bool
+}
+
+// dialIMAP connects and logs in to an IMAP server. Without implicit TLS the
+// connection is upgraded with STARTTLS when the server offers it; the
+// password is only sent unencrypted to localhost.
+func dialIMAP(ctx context.Context, config *IMAPConfig) (*imapClient, error) {
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	tlsConfig := config.TLS
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = config.Host
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if config.UseTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to IMAP server: %w", err)
+	}
+
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn), tls: config.UseTLS}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if err := c.login(config, tlsConfig); err != nil {
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// login reads the greeting, upgrades to TLS and authenticates
+func (c *imapClient) login(config *IMAPConfig, tlsConfig *tls.Config) error {
+	greeting, _, err := c.readLine()
+	if err != nil {
+		return fmt.Errorf("failed to read IMAP greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("IMAP server refused the connection: %s", greeting)
+	}
+
+	if !c.tls {
+		responses, err := c.command("CAPABILITY")
+		if err != nil {
+			return err
+		}
+		if hasCapability(responses, "STARTTLS") {
+			if _, err := c.command("STARTTLS"); err != nil {
+				return err
+			}
+			tlsConn := tls.Client(c.conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return fmt.Errorf("IMAP STARTTLS handshake failed: %w", err)
+			}
+			c.conn, c.r, c.tls = tlsConn, bufio.NewReader(tlsConn), true
+		}
+	}
+	if !c.tls && !isLocalhost(config.Host) {
+		return fmt.Errorf("IMAP server %s offers no TLS, refusing to send the password unencrypted", config.Host)
+	}
+
+	username, err := imapQuote(config.Username)
+	if err != nil {
+		return err
+	}
+	password, err := imapQuote(config.Password)
+	if err != nil {
+		return err
+	}
+	if _, err := c.command("LOGIN " + username + " " + password); err != nil {
+		return fmt.Errorf("IMAP login failed: %w", err)
+	}
+	return nil
+}
+
+// command sends a command and returns its untagged responses, failing
+// unless the server completes it with OK
+func (c *imapClient) command(cmd string) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("N%d", c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, fmt.Errorf("failed to send IMAP command: %w", err)
+	}
+
+	var responses []imapResponse
+	for {
+		line, literals, err := c.readLine()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read IMAP response: %w", err)
+		}
+		switch {
+		case strings.HasPrefix(line, "* "):
+			responses = append(responses, imapResponse{text: line[2:], literals: literals})
+		case strings.HasPrefix(line, tag+" "):
+			status := line[len(tag)+1:]
+			if !strings.HasPrefix(status, "OK") {
+				verb, _, _ := strings.Cut(cmd, " ")
+				return nil, fmt.Errorf("IMAP %s failed: %s", verb, status)
+			}
+			return responses, nil
+		}
+		// Continuation requests and responses of other tags are ignored
+	}
+}
+
+// readLine reads a response line, reading the literals it announces with
+// {n} at the end of a line into literals and their place in the line
+func (c *imapClient) readLine() (string, [][]byte, error) {
+	var (
+		b        strings.Builder
+		literals [][]byte
+	)
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		b.WriteString(line)
+
+		open := strings.LastIndexByte(line, '{')
+		if open < 0 || !strings.HasSuffix(line, "}") {
+			return b.String(), literals, nil
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(line[open+1:len(line)-1], "+"))
+		if err != nil {
+			return b.String(), literals, nil
+		}
+		if size < 0 || size > maxIMAPLiteral {
+			return "", nil, fmt.Errorf("IMAP literal of %d bytes exceeds the limit", size)
+		}
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return "", nil, err
+		}
+		literals = append(literals, literal)
+	}
+}
+
+// selectMailbox opens a mailbox for reading and writing
+func (c *imapClient) selectMailbox(mailbox string) error {
+	name, err := imapQuote(mailbox)
+	if err != nil {
+		return err
+	}
+	_, err = c.command("SELECT " + name)
+	return err
+}
+
+// searchUnseen returns the UIDs of the unseen messages of the mailbox
+func (c *imapClient) searchUnseen() ([]uint32, error) {
+	responses, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, response := range responses {
+		fields := strings.Fields(response.text)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "SEARCH") {
+			continue
+		}
+		for _, field := range fields[1:] {
+			uid, err := strconv.ParseUint(field, 10, 32)
+			if err == nil {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// fetch returns the raw message of a UID without flagging it as seen
+func (c *imapClient) fetch(uid uint32) ([]byte, error) {
+	responses, err := c.command(fmt.Sprintf("UID FETCH %d BODY.PEEK[]", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, response := range responses {
+		if strings.Contains(strings.ToUpper(response.text), "FETCH") && len(response.literals) > 0 {
+			return response.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("IMAP server returned no message for UID %d", uid)
+}
+
+// store adds a flag to a message
+func (c *imapClient) store(uid uint32, flag string) error {
+	_, err := c.command(fmt.Sprintf("UID STORE %d +FLAGS.SILENT (%s)", uid, flag))
+	return err
+}
+
+// expunge removes the messages flagged as deleted
+func (c *imapClient) expunge() error {
+	_, err := c.command("EXPUNGE")
+	return err
+}
+
+// logout ends the session and closes the connection
+func (c *imapClient) logout() error {
+	_, err := c.command("LOGOUT")
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// hasCapability reports whether a CAPABILITY response lists capability
+func hasCapability(responses []imapResponse, capability string) bool {
+	for _, response := range responses {
+		fields := strings.Fields(response.text)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "CAPABILITY") {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if strings.EqualFold(field, capability) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imapQuote quotes a string argument
+func imapQuote(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return "", fmt.Errorf("IMAP argument cannot contain line breaks")
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`, nil
+}
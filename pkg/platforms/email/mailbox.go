@@ -0,0 +1,261 @@
+// Package email provides the bounce and reply mailbox poller for NotifyHub
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/suppression"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// DefaultPollInterval is how often the mailbox is read when no interval is
+// configured
+const DefaultPollInterval = time.Minute
+
+// IMAPConfig configures the mailbox bounces and replies are read from
+type IMAPConfig struct {
+	Host     string
+	Port     int // zero uses 993 with UseTLS and 143 otherwise
+	Username string
+	Password string
+
+	// UseTLS connects with implicit TLS; otherwise the connection is
+	// upgraded with STARTTLS when the server offers it
+	UseTLS bool
+	TLS    *tls.Config
+
+	Mailbox string // zero uses INBOX
+
+	// Delete removes processed messages from the mailbox; otherwise they
+	// are flagged as seen, as only unseen messages are read
+	Delete bool
+}
+
+// MailboxPollerConfig configures a MailboxPoller
+type MailboxPollerConfig struct {
+	IMAP     IMAPConfig
+	Interval time.Duration // zero uses DefaultPollInterval
+
+	// Parser correlates feedback to sent messages; set its BounceAddress
+	// to the VERP bounce address and its Domain to the From domain
+	Parser FeedbackParser
+
+	// Suppressions receives hard-bouncing and complaining recipients,
+	// which later sends skip
+	Suppressions suppression.Store
+
+	// Receipts receives a receipt for every correlated bounce and reply,
+	// with a failed result of the "bounced" status for a bounce and a
+	// successful result of the "replied" status for a reply
+	Receipts receipt.Processor
+
+	// OnFeedback is called with every feedback, including delays and
+	// automatic replies
+	OnFeedback func(ctx context.Context, feedback Feedback)
+
+	Logger logger.Logger
+}
+
+// MailboxPoller reads the mailbox bounces and replies of sent emails
+// arrive at, typically the mailbox of the VERP bounce address, and feeds
+// them to the suppression and receipt subsystems:
+//
+//	poller, err := email.NewMailboxPoller(email.MailboxPollerConfig{
+//		IMAP:         email.IMAPConfig{Host: "imap.example.com", UseTLS: true, Username: "bounces@example.com", Password: password},
+//		Parser:       email.FeedbackParser{BounceAddress: "bounces@example.com", Domain: "example.com"},
+//		Suppressions: store,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	go poller.Run(ctx)
+type MailboxPoller struct {
+	config MailboxPollerConfig
+	logger logger.Logger
+}
+
+// NewMailboxPoller creates a mailbox poller
+func NewMailboxPoller(config MailboxPollerConfig) (*MailboxPoller, error) {
+	if config.IMAP.Host == "" {
+		return nil, fmt.Errorf("IMAP host is required")
+	}
+	if config.IMAP.Username == "" {
+		return nil, fmt.Errorf("IMAP username is required")
+	}
+	if config.IMAP.Port == 0 {
+		config.IMAP.Port = 143
+		if config.IMAP.UseTLS {
+			config.IMAP.Port = 993
+		}
+	}
+	if config.IMAP.Mailbox == "" {
+		config.IMAP.Mailbox = "INBOX"
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultPollInterval
+	}
+
+	log := config.Logger
+	if log == nil {
+		log = logger.Discard
+	}
+	return &MailboxPoller{config: config, logger: log}, nil
+}
+
+// Run polls the mailbox every interval until ctx is done. Failed polls are
+// logged and retried at the next interval.
+func (p *MailboxPoller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := p.Poll(ctx); err != nil {
+			p.logger.Error("读取退信邮箱失败", "mailbox", p.config.IMAP.Mailbox, "error", err)
+		} else if n > 0 {
+			p.logger.Info("退信邮箱已处理", "mailbox", p.config.IMAP.Mailbox, "messages", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll reads the unseen messages of the mailbox once and returns how many
+// it processed. A message whose feedback could not be recorded stays
+// unseen and is read again by the next poll.
+func (p *MailboxPoller) Poll(ctx context.Context) (int, error) {
+	client, err := dialIMAP(ctx, &p.config.IMAP)
+	if err != nil {
+		return 0, err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = client.conn.Close() })
+	defer stop()
+	defer func() { _ = client.logout() }()
+
+	if err := client.selectMailbox(p.config.IMAP.Mailbox); err != nil {
+		return 0, err
+	}
+	uids, err := client.searchUnseen()
+	if err != nil {
+		return 0, err
+	}
+
+	flag := `\Seen`
+	if p.config.IMAP.Delete {
+		flag = `\Deleted`
+	}
+
+	processed := 0
+	var firstErr error
+	for _, uid := range uids {
+		raw, err := client.fetch(uid)
+		if err != nil {
+			return processed, err
+		}
+		if err := p.process(ctx, raw); err != nil {
+			p.logger.Error("退信处理失败", "uid", uid, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if err := client.store(uid, flag); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+
+	if p.config.IMAP.Delete && processed > 0 {
+		if err := client.expunge(); err != nil {
+			return processed, err
+		}
+	}
+	return processed, firstErr
+}
+
+// process records the feedback in a received message. Messages that hold
+// none, such as mail unrelated to sent messages, are processed as well.
+func (p *MailboxPoller) process(ctx context.Context, raw []byte) error {
+	feedback, err := p.config.Parser.Parse(bytes.NewReader(raw))
+	if err != nil {
+		// An unparsable message will not parse the next time either
+		p.logger.Warn("无法解析退信邮件", "error", err)
+		return nil
+	}
+
+	for _, f := range feedback {
+		p.logger.Debug("收到邮件反馈", "kind", f.Kind, "message_id", f.MessageID, "recipient", f.Recipient, "status", f.Status)
+		if err := p.suppress(ctx, f); err != nil {
+			return err
+		}
+		if err := p.report(ctx, f); err != nil {
+			return err
+		}
+		if p.config.OnFeedback != nil {
+			p.config.OnFeedback(ctx, f)
+		}
+	}
+	return nil
+}
+
+// suppress adds hard-bouncing and complaining recipients to the
+// suppression store
+func (p *MailboxPoller) suppress(ctx context.Context, f Feedback) error {
+	if p.config.Suppressions == nil || f.Recipient == "" {
+		return nil
+	}
+	var reason string
+	switch f.Kind {
+	case FeedbackHardBounce:
+		reason = suppression.ReasonBounce
+	case FeedbackComplaint:
+		reason = suppression.ReasonComplaint
+	default:
+		return nil
+	}
+	if err := p.config.Suppressions.Add(ctx, suppression.Entry{Channel: "email", Address: f.Recipient, Reason: reason}); err != nil {
+		return fmt.Errorf("failed to suppress %s: %w", f.Recipient, err)
+	}
+	return nil
+}
+
+// report sends a receipt for a correlated bounce or reply
+func (p *MailboxPoller) report(ctx context.Context, f Feedback) error {
+	if p.config.Receipts == nil || f.MessageID == "" {
+		return nil
+	}
+
+	result := receipt.PlatformResult{
+		Platform:  "email",
+		Target:    f.Recipient,
+		Timestamp: f.ReceivedAt,
+	}
+	switch f.Kind {
+	case FeedbackHardBounce, FeedbackSoftBounce:
+		result.Status = receipt.ResultBounced
+		result.Error = f.Diagnostic
+		if result.Error == "" {
+			result.Error = "bounced " + f.Status
+		}
+	case FeedbackReply:
+		result.Status = receipt.ResultReplied
+		result.Success = true
+	default:
+		return nil
+	}
+
+	r := receipt.New(f.MessageID)
+	r.AddResult(result)
+	if err := p.config.Receipts.ProcessReceipt(ctx, r); err != nil {
+		return fmt.Errorf("failed to process receipt of %s: %w", f.MessageID, err)
+	}
+	return nil
+}
@@ -2,12 +2,18 @@
 package email
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/base64"
 	"fmt"
 	"html/template"
+	"io"
 	"mime"
-	"path/filepath"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
 	"strings"
 	"time"
 
@@ -33,6 +39,9 @@ type Message struct {
 	// Attachments
 	Attachments []Attachment `json:"attachments,omitempty"`
 
+	// Calendar makes the message an invitation to an event
+	Calendar *CalendarEvent `json:"calendar,omitempty"`
+
 	// Metadata
 	MessageID  string                 `json:"message_id,omitempty"`
 	References string                 `json:"references,omitempty"`
@@ -58,6 +67,10 @@ type Attachment struct {
 	Inline      bool              `json:"inline,omitempty"`
 	ContentID   string            `json:"content_id,omitempty"`
 	Headers     map[string]string `json:"headers,omitempty"`
+
+	// Open opens the content to stream instead of Content, once for every
+	// send of the message
+	Open func() (io.Reader, error) `json:"-"`
 }
 
 // MessageBuilder builds email messages from NotifyHub messages
@@ -104,6 +117,16 @@ func (b *MessageBuilder) BuildMessage(msg *message.Message, targets []target.Tar
 	// Set tracking options
 	b.setTrackingOptions(emailMsg)
 
+	// Attach the attachments of the message
+	for _, att := range msg.Attachments {
+		emailMsg.Attachments = append(emailMsg.Attachments, Attachment{
+			Name:        att.Name,
+			ContentType: att.MediaType(),
+			Content:     att.Content,
+			Open:        att.Open,
+		})
+	}
+
 	// Process platform-specific data
 	if err := b.processPlatformData(emailMsg, msg); err != nil {
 		return nil, err
@@ -168,6 +191,9 @@ func (b *MessageBuilder) setHeaders(emailMsg *Message, msg *message.Message) {
 	// Set default headers
 	emailMsg.Headers["X-Mailer"] = "NotifyHub"
 	emailMsg.Headers["X-Priority"] = b.priorityToHeader(msg.Priority)
+	if msg.ID != "" {
+		emailMsg.Headers[HeaderMessageID] = msg.ID
+	}
 
 	// Set reply-to if configured
 	if b.config.ReplyTo != "" {
@@ -223,6 +249,13 @@ func (b *MessageBuilder) processPlatformData(emailMsg *Message, msg *message.Mes
 		}
 	}
 
+	// Process calendar invitation
+	if calendar, exists := data["calendar"]; exists {
+		if err := b.processCalendar(emailMsg, msg, calendar); err != nil {
+			return err
+		}
+	}
+
 	// Process delivery options
 	if deliveryReceipt, exists := data["delivery_receipt"]; exists {
 		if receipt, ok := deliveryReceipt.(bool); ok {
@@ -239,14 +272,64 @@ func (b *MessageBuilder) processPlatformData(emailMsg *Message, msg *message.Mes
 	return nil
 }
 
+// processCalendar sets the calendar event of the message, defaulting its
+// identity to the message, its organizer to the sender and its attendees
+// to the recipients
+func (b *MessageBuilder) processCalendar(emailMsg *Message, msg *message.Message, calendar interface{}) error {
+	var event CalendarEvent
+	switch c := calendar.(type) {
+	case CalendarEvent:
+		event = c
+	case *CalendarEvent:
+		if c == nil {
+			return nil
+		}
+		event = *c
+	default:
+		return nil
+	}
+
+	if event.UID == "" {
+		event.UID = fmt.Sprintf("%s@%s", msg.ID, b.extractDomain(b.config.From))
+	}
+	if event.Summary == "" {
+		event.Summary = msg.Title
+	}
+	if event.Organizer == "" {
+		event.Organizer = b.config.From
+		event.OrganizerName = b.config.FromName
+	}
+	if len(event.Attendees) == 0 {
+		for _, recipient := range append(append([]string(nil), emailMsg.To...), emailMsg.CC...) {
+			if addr, err := mail.ParseAddress(recipient); err == nil {
+				recipient = addr.Address
+			}
+			event.Attendees = append(event.Attendees, recipient)
+		}
+	}
+	if err := event.Validate(); err != nil {
+		return err
+	}
+	emailMsg.Calendar = &event
+	return nil
+}
+
 // processAttachments processes email attachments
 func (b *MessageBuilder) processAttachments(emailMsg *Message, attachments interface{}) error {
+	if typed, ok := attachments.([]Attachment); ok {
+		emailMsg.Attachments = append(emailMsg.Attachments, typed...)
+		return nil
+	}
 	attachmentList, ok := attachments.([]interface{})
 	if !ok {
 		return nil
 	}
 
 	for _, attachment := range attachmentList {
+		if typed, ok := attachment.(Attachment); ok {
+			emailMsg.Attachments = append(emailMsg.Attachments, typed)
+			continue
+		}
 		attachmentData, ok := attachment.(map[string]interface{})
 		if !ok {
 			continue
@@ -295,10 +378,7 @@ func (b *MessageBuilder) processAttachments(emailMsg *Message, attachments inter
 
 		// Detect content type if not provided
 		if att.ContentType == "" && att.Name != "" {
-			att.ContentType = mime.TypeByExtension(filepath.Ext(att.Name))
-			if att.ContentType == "" {
-				att.ContentType = "application/octet-stream"
-			}
+			att.ContentType = contentTypeOf(att.Name)
 		}
 
 		emailMsg.Attachments = append(emailMsg.Attachments, att)
@@ -388,105 +468,286 @@ func (b *MessageBuilder) markdownToHTML(markdown string) (string, error) {
 // ToRFC2822 converts the email message to RFC2822 format
 func (m *Message) ToRFC2822() ([]byte, error) {
 	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-	// Write headers
-	buf.WriteString(fmt.Sprintf("From: %s\r\n", m.From))
+// WriteTo writes the message in RFC2822 format to w, streaming the
+// content of its attachments
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := m.write(cw, 0)
+	return cw.n, err
+}
+
+// write writes the message to w. The body is a multipart/alternative of
+// the text and HTML versions, with the inline images in a multipart/related
+// of the HTML version; with attachments it is the first part of a
+// multipart/mixed. A positive limit caps the total size of the
+// attachments.
+func (m *Message) write(w io.Writer, limit int64) error {
+	bw := bufio.NewWriter(w)
 
+	// Write headers
+	fmt.Fprintf(bw, "From: %s\r\n", m.From)
 	if len(m.To) > 0 {
-		buf.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(m.To, ", ")))
+		fmt.Fprintf(bw, "To: %s\r\n", strings.Join(m.To, ", "))
 	}
-
 	if len(m.CC) > 0 {
-		buf.WriteString(fmt.Sprintf("CC: %s\r\n", strings.Join(m.CC, ", ")))
+		fmt.Fprintf(bw, "CC: %s\r\n", strings.Join(m.CC, ", "))
 	}
-
 	if m.ReplyTo != "" {
-		buf.WriteString(fmt.Sprintf("Reply-To: %s\r\n", m.ReplyTo))
+		fmt.Fprintf(bw, "Reply-To: %s\r\n", m.ReplyTo)
 	}
-
-	buf.WriteString(fmt.Sprintf("Subject: %s\r\n", m.Subject))
-	buf.WriteString(fmt.Sprintf("Date: %s\r\n", m.Date.Format(time.RFC1123Z)))
-
+	fmt.Fprintf(bw, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", m.Subject))
+	fmt.Fprintf(bw, "Date: %s\r\n", m.Date.Format(time.RFC1123Z))
 	if m.MessageID != "" {
-		buf.WriteString(fmt.Sprintf("Message-ID: %s\r\n", m.MessageID))
+		fmt.Fprintf(bw, "Message-ID: %s\r\n", m.MessageID)
 	}
+	for _, k := range sortedKeys(m.Headers) {
+		fmt.Fprintf(bw, "%s: %s\r\n", k, m.Headers[k])
+	}
+	bw.WriteString("MIME-Version: 1.0\r\n")
 
-	// Write custom headers
-	for k, v := range m.Headers {
-		buf.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+	budget := &sizeBudget{limit: limit, remaining: limit}
+	inline, attached := m.splitAttachments()
+	if m.Calendar != nil {
+		// Clients that ignore the text/calendar version open the file
+		attached = append(attached, Attachment{Name: "invite.ics", ContentType: "application/ics", Content: m.Calendar.ICS(m.Date)})
 	}
+	bodyHeader, writeBody := m.body(inline, budget)
 
-	// MIME headers for multipart message
-	boundary := fmt.Sprintf("boundary_%d", time.Now().Unix())
-	buf.WriteString("MIME-Version: 1.0\r\n")
+	if len(attached) == 0 {
+		for _, k := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+			if v := bodyHeader.Get(k); v != "" {
+				fmt.Fprintf(bw, "%s: %s\r\n", k, v)
+			}
+		}
+		bw.WriteString("\r\n")
+		if err := writeBody(bw); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
 
-	if len(m.Attachments) > 0 {
-		buf.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\r\n", boundary))
-	} else {
-		buf.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=\"%s\"\r\n", boundary))
+	mixed := multipart.NewWriter(bw)
+	fmt.Fprintf(bw, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mixed.Boundary())
+	part, err := mixed.CreatePart(bodyHeader)
+	if err != nil {
+		return err
+	}
+	if err := writeBody(part); err != nil {
+		return err
+	}
+	for _, att := range attached {
+		if err := writeAttachment(mixed, att, budget); err != nil {
+			return err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return err
 	}
+	return bw.Flush()
+}
 
-	buf.WriteString("\r\n")
+// splitAttachments separates the inline images the HTML body references by
+// Content-ID from the other attachments
+func (m *Message) splitAttachments() (inline, attached []Attachment) {
+	for _, att := range m.Attachments {
+		if att.Inline && att.ContentID != "" && m.HTMLBody != "" {
+			inline = append(inline, att)
+		} else {
+			attached = append(attached, att)
+		}
+	}
+	return inline, attached
+}
 
-	// Write body parts
-	if m.TextBody != "" {
-		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
-		buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
-		buf.WriteString(m.TextBody)
-		buf.WriteString("\r\n\r\n")
+// relatedBody wraps an HTML body in a multipart/related with the inline
+// images it references as cid: URLs
+func relatedBody(header textproto.MIMEHeader, writeBody func(io.Writer) error, inline []Attachment, budget *sizeBudget) (textproto.MIMEHeader, func(io.Writer) error) {
+	rootType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	related := textproto.MIMEHeader{}
+	related.Set("Content-Type", mime.FormatMediaType("multipart/related", map[string]string{"type": rootType, "boundary": boundary}))
+	return related, func(w io.Writer) error {
+		mw := multipart.NewWriter(w)
+		if err := mw.SetBoundary(boundary); err != nil {
+			return err
+		}
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if err := writeBody(part); err != nil {
+			return err
+		}
+		for _, att := range inline {
+			if err := writeAttachment(mw, att, budget); err != nil {
+				return err
+			}
+		}
+		return mw.Close()
 	}
+}
 
+// body returns the header of the body and the function writing it: the
+// text or HTML version, or a multipart/alternative of the versions. The
+// HTML version is a multipart/related with the inline images it
+// references, and an invitation adds a text/calendar version.
+func (m *Message) body(inline []Attachment, budget *sizeBudget) (textproto.MIMEHeader, func(io.Writer) error) {
+	type version struct {
+		header textproto.MIMEHeader
+		write  func(io.Writer) error
+	}
+	var versions []version
+	if m.TextBody != "" || m.HTMLBody == "" {
+		header, write := textPart("text/plain", m.TextBody)
+		versions = append(versions, version{header, write})
+	}
 	if m.HTMLBody != "" {
-		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
-		buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
-		buf.WriteString(m.HTMLBody)
-		buf.WriteString("\r\n\r\n")
+		header, write := textPart("text/html", m.HTMLBody)
+		if len(inline) > 0 {
+			header, write = relatedBody(header, write, inline, budget)
+		}
+		versions = append(versions, version{header, write})
+	}
+	if m.Calendar != nil {
+		header, write := textPart("text/calendar", string(m.Calendar.ICS(m.Date)))
+		header.Set("Content-Type", mime.FormatMediaType("text/calendar", map[string]string{"charset": "UTF-8", "method": m.Calendar.method()}))
+		versions = append(versions, version{header, write})
+	}
+	if len(versions) == 1 {
+		return versions[0].header, versions[0].write
 	}
 
-	// Write attachments
-	for _, attachment := range m.Attachments {
-		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		buf.WriteString(fmt.Sprintf("Content-Type: %s\r\n", attachment.ContentType))
-		buf.WriteString("Content-Transfer-Encoding: base64\r\n")
-
-		if attachment.Name != "" {
-			disposition := "attachment"
-			if attachment.Inline {
-				disposition = "inline"
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", "multipart/alternative; boundary="+boundary)
+	return header, func(w io.Writer) error {
+		alternative := multipart.NewWriter(w)
+		if err := alternative.SetBoundary(boundary); err != nil {
+			return err
+		}
+		for _, v := range versions {
+			part, err := alternative.CreatePart(v.header)
+			if err != nil {
+				return err
+			}
+			if err := v.write(part); err != nil {
+				return err
 			}
-			buf.WriteString(fmt.Sprintf("Content-Disposition: %s; filename=\"%s\"\r\n", disposition, attachment.Name))
 		}
+		return alternative.Close()
+	}
+}
 
-		if attachment.ContentID != "" {
-			buf.WriteString(fmt.Sprintf("Content-ID: <%s>\r\n", attachment.ContentID))
-		}
+// textPart returns the header and the writer of a UTF-8 text part
+func textPart(contentType, text string) (textproto.MIMEHeader, func(io.Writer) error) {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=UTF-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return header, func(w io.Writer) error {
+		return writeQuotedPrintable(w, text)
+	}
+}
 
-		// Write custom attachment headers
-		for k, v := range attachment.Headers {
-			buf.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
-		}
+// writeQuotedPrintable writes text to w in quoted-printable encoding
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, text); err != nil {
+		return err
+	}
+	return qp.Close()
+}
 
-		buf.WriteString("\r\n")
+// sizeBudget tracks the attachment bytes a message may still hold; a
+// non-positive limit allows any size
+type sizeBudget struct {
+	limit, remaining int64
+}
 
-		// Encode attachment content as base64
-		encoded := base64.StdEncoding.EncodeToString(attachment.Content)
-		for i := 0; i < len(encoded); i += 76 {
-			end := i + 76
-			if end > len(encoded) {
-				end = len(encoded)
-			}
-			buf.WriteString(encoded[i:end])
-			buf.WriteString("\r\n")
+// writeAttachment writes an attachment as a base64 part of mw, streaming
+// its content, and fails once the attachments exceed the budget
+func writeAttachment(mw *multipart.Writer, att Attachment, budget *sizeBudget) error {
+	contentType := att.ContentType
+	if contentType == "" {
+		contentType = contentTypeOf(att.Name)
+	}
+	disposition := "attachment"
+	if att.Inline {
+		disposition = "inline"
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mediaType(contentType, "name", att.Name))
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Disposition", mediaType(disposition, "filename", att.Name))
+	if att.ContentID != "" {
+		header.Set("Content-ID", "<"+strings.Trim(att.ContentID, "<>")+">")
+	}
+	for k, v := range att.Headers {
+		header.Set(k, v)
+	}
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	r, err := att.open()
+	if err != nil {
+		return err
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if budget.limit > 0 {
+		r = io.LimitReader(r, budget.remaining+1)
+	}
+
+	lines := &lineWriter{w: part}
+	encoder := base64.NewEncoder(base64.StdEncoding, lines)
+	n, err := io.Copy(encoder, r)
+	if err != nil {
+		return fmt.Errorf("failed to write attachment %s: %w", att.Name, err)
+	}
+	if budget.limit > 0 {
+		if n > budget.remaining {
+			return fmt.Errorf("%w: %s exceeds the limit of %d bytes", ErrAttachmentTooLarge, att.Name, budget.limit)
 		}
-		buf.WriteString("\r\n")
+		budget.remaining -= n
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	if lines.col > 0 {
+		_, err = io.WriteString(part, "\r\n")
 	}
+	return err
+}
 
-	// End boundary
-	buf.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+// mediaType formats a media type with a parameter, encoding non-ASCII
+// values as RFC 2231 requires
+func mediaType(value, param, paramValue string) string {
+	if paramValue == "" {
+		return value
+	}
+	if formatted := mime.FormatMediaType(value, map[string]string{param: paramValue}); formatted != "" {
+		return formatted
+	}
+	return value
+}
 
-	return buf.Bytes(), nil
+// sortedKeys returns the keys of a header map in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // Validate validates the email message
@@ -0,0 +1,195 @@
+// Package email provides OAuth2 (XOAUTH2) SMTP authentication for NotifyHub
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before its expiry a token is refreshed
+const tokenRefreshMargin = time.Minute
+
+// Token is an OAuth2 access token
+type Token struct {
+	AccessToken string
+	Expiry      time.Time // zero when the token does not expire
+}
+
+// valid reports whether the token can still be used
+func (t *Token) valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(tokenRefreshMargin).Before(t.Expiry))
+}
+
+// TokenSource supplies the access tokens of XOAUTH2 authentication. It is
+// called for every SMTP login, so implementations cache their tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// OAuth2Options configure a token source fetching tokens from an OAuth2
+// token endpoint
+type OAuth2Options struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string   // empty uses the client credentials grant
+	Scopes       []string // requested with the client credentials grant
+
+	// HTTPClient sends the token requests; nil uses a client with a 30s
+	// timeout
+	HTTPClient *http.Client
+}
+
+// oauth2TokenSource fetches tokens from a token endpoint and caches them
+// until shortly before they expire
+type oauth2TokenSource struct {
+	opts OAuth2Options
+	now  func() time.Time
+
+	mu    sync.Mutex
+	token *Token
+}
+
+// NewOAuth2TokenSource creates a token source using the refresh token
+// grant, or the client credentials grant when there is no refresh token.
+// It is safe for concurrent use.
+func NewOAuth2TokenSource(opts OAuth2Options) TokenSource {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &oauth2TokenSource{opts: opts, now: time.Now}
+}
+
+// Token implements TokenSource
+func (s *oauth2TokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.valid(s.now()) {
+		return s.token, nil
+	}
+	token, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
+
+// fetch requests a new token from the token endpoint
+func (s *oauth2TokenSource) fetch(ctx context.Context) (*Token, error) {
+	form := url.Values{"client_id": {s.opts.ClientID}}
+	if s.opts.ClientSecret != "" {
+		form.Set("client_secret", s.opts.ClientSecret)
+	}
+	if s.opts.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", s.opts.RefreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+		if len(s.opts.Scopes) > 0 {
+			form.Set("scope", strings.Join(s.opts.Scopes, " "))
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	start := s.now()
+	resp, err := s.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2 token request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read oauth2 token response: %w", err)
+	}
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		RefreshToken     string `json:"refresh_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("invalid oauth2 token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		if result.Error != "" {
+			return nil, fmt.Errorf("oauth2 token request failed with status %d: %s %s", resp.StatusCode, result.Error, result.ErrorDescription)
+		}
+		return nil, fmt.Errorf("oauth2 token request failed with status %d", resp.StatusCode)
+	}
+
+	// Providers may rotate the refresh token with each use
+	if result.RefreshToken != "" && s.opts.RefreshToken != "" {
+		s.opts.RefreshToken = result.RefreshToken
+	}
+	token := &Token{AccessToken: result.AccessToken}
+	if result.ExpiresIn > 0 {
+		token.Expiry = start.Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// xoauth2Auth implements the XOAUTH2 SMTP authentication mechanism of
+// Gmail and Microsoft 365
+type xoauth2Auth struct {
+	username string
+	source   TokenSource
+	host     string
+	timeout  time.Duration
+}
+
+// Start implements smtp.Auth interface
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like PLAIN, only send the token over TLS or to localhost
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, fmt.Errorf("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, fmt.Errorf("wrong host name")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+	token, err := a.source.Token(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get oauth2 token: %w", err)
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + token.AccessToken + "\x01\x01"), nil
+}
+
+// Next implements smtp.Auth interface
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// The server sends the details of a failure as a challenge, which
+		// is answered with an empty response to receive the error
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// isLocalhost reports whether a host name is the local host
+func isLocalhost(name string) bool {
+	if name == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(name)
+	return ip != nil && ip.IsLoopback()
+}
@@ -42,6 +42,11 @@ func NewEmailPlatform(emailConfig *config.EmailConfig, logger logger.Logger) (pl
 
 	// Convert NotifyHub email config to internal email config
 	internalConfig := convertToInternalConfig(emailConfig)
+	tlsConfig, err := emailConfig.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+	internalConfig.TLS = tlsConfig
 
 	// Create SMTP sender
 	smtpSender, err := NewSMTPSender(internalConfig, logger)
@@ -70,6 +75,9 @@ func (e *EmailPlatform) Send(ctx context.Context, msg *message.Message, targets
 		return nil, err
 	}
 
+	ctx, cancel := platform.WithTimeout(ctx, e.smtpSender.config.GetTimeout())
+	defer cancel()
+
 	e.logger.Info("开始发送邮件", "message_title", msg.Title, "targets_count", len(targets), "smtp_host", e.config.Host)
 
 	// Create error analyzer for enhanced error handling
@@ -231,11 +239,12 @@ func (e *EmailPlatform) Close() error {
 }
 
 // NewPlatform is the factory function for creating Email platforms
-// This function will be called by the platform registry
+// This function will be called by the platform registry with a config.EmailConfig,
+// a pointer to one, or the settings in map form
 func NewPlatform(cfg interface{}, log logger.Logger) (platform.Platform, error) {
-	emailConfig, ok := cfg.(*config.EmailConfig)
-	if !ok {
-		return nil, fmt.Errorf("invalid email configuration type")
+	emailConfig, err := config.PlatformConfig[config.EmailConfig]("email", cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	return NewEmailPlatform(emailConfig, log)
@@ -396,6 +405,11 @@ func convertToInternalConfig(nhConfig *config.EmailConfig) *Config {
 	internalConfig.Password = nhConfig.Password
 	internalConfig.From = nhConfig.From
 	internalConfig.UseTLS = nhConfig.UseTLS
+	internalConfig.MaxAttachmentSize = nhConfig.MaxAttachmentSize
+	if nhConfig.BounceAddress != "" {
+		internalConfig.ReturnPath = nhConfig.BounceAddress
+		internalConfig.VERP = true
+	}
 
 	// Apply provider-specific settings
 	if settings := getProviderSettings(nhConfig.Host, nhConfig.Port); settings != nil {
@@ -413,6 +427,29 @@ func convertToInternalConfig(nhConfig *config.EmailConfig) *Config {
 		internalConfig.Timeout = &timeout
 	}
 
+	// Map connection pooling
+	if nhConfig.PoolSize > 0 {
+		internalConfig.PoolSize = nhConfig.PoolSize
+		internalConfig.MaxIdleConns = nhConfig.PoolSize
+	}
+	if nhConfig.IdleTimeout < 0 {
+		internalConfig.KeepAlive = false
+	} else {
+		internalConfig.IdleTimeout = nhConfig.IdleTimeout
+	}
+
+	// OAuth2 replaces password authentication with XOAUTH2
+	if oauth := nhConfig.OAuth2; oauth != nil {
+		internalConfig.TokenSource = NewOAuth2TokenSource(OAuth2Options{
+			TokenURL:     oauth.TokenURL,
+			ClientID:     oauth.ClientID,
+			ClientSecret: oauth.ClientSecret,
+			RefreshToken: oauth.RefreshToken,
+			Scopes:       oauth.Scopes,
+		})
+		internalConfig.AuthMethod = string(AuthMethodXOAUTH2)
+	}
+
 	return internalConfig
 }
 
@@ -1,11 +1,35 @@
 package email
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/suppression"
 	"github.com/kart-io/notifyhub/pkg/target"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
 )
@@ -403,6 +427,1237 @@ func TestFormatErrorForUser(t *testing.T) {
 	}
 }
 
+func TestMessage_WriteTo(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         *Message
+		wantType    string
+		wantBodies  []string
+		attachments map[string]string // file name to content
+	}{
+		{
+			name:       "text only",
+			msg:        &Message{From: "a@example.com", To: []string{"b@example.com"}, Subject: "Hi", TextBody: "hello"},
+			wantType:   "text/plain",
+			wantBodies: []string{"hello"},
+		},
+		{
+			name:       "alternative",
+			msg:        &Message{From: "a@example.com", To: []string{"b@example.com"}, Subject: "Hi", TextBody: "hello", HTMLBody: "<b>hello</b>"},
+			wantType:   "multipart/alternative",
+			wantBodies: []string{"hello", "<b>hello</b>"},
+		},
+		{
+			name: "attachments",
+			msg: &Message{From: "a@example.com", To: []string{"b@example.com"}, Subject: "报告", TextBody: "hello", HTMLBody: "<b>hello</b>", Attachments: []Attachment{
+				{Name: "report.csv", Content: []byte("a,b\n1,2\n")},
+				ReaderAttachment("月报.txt", func() (io.Reader, error) {
+					return strings.NewReader(strings.Repeat("streamed ", 100)), nil
+				}),
+			}},
+			wantType:    "multipart/mixed",
+			wantBodies:  []string{"hello", "<b>hello</b>"},
+			attachments: map[string]string{"report.csv": "a,b\n1,2\n", "月报.txt": strings.Repeat("streamed ", 100)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := tt.msg.ToRFC2822()
+			if err != nil {
+				t.Fatalf("ToRFC2822() error = %v", err)
+			}
+			parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("ReadMessage() error = %v", err)
+			}
+			if subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject")); subject != tt.msg.Subject {
+				t.Errorf("Subject = %q, want %q", subject, tt.msg.Subject)
+			}
+
+			var bodies []string
+			attachments := make(map[string]string)
+			mediaType := walkParts(t, textproto.MIMEHeader(parsed.Header), parsed.Body, &bodies, attachments)
+			if mediaType != tt.wantType {
+				t.Errorf("Content-Type = %s, want %s", mediaType, tt.wantType)
+			}
+			if !reflect.DeepEqual(bodies, tt.wantBodies) {
+				t.Errorf("bodies = %q, want %q", bodies, tt.wantBodies)
+			}
+			if len(attachments) != len(tt.attachments) {
+				t.Errorf("attachments = %v, want %v", attachments, tt.attachments)
+			}
+			for name, want := range tt.attachments {
+				if attachments[name] != want {
+					t.Errorf("attachment %s = %q, want %q", name, attachments[name], want)
+				}
+			}
+		})
+	}
+}
+
+// walkParts collects the text bodies and the attachments of a MIME entity
+// and returns its media type
+func walkParts(t *testing.T, header textproto.MIMEHeader, body io.Reader, bodies *[]string, attachments map[string]string) string {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("ParseMediaType() error = %v", err)
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("NextRawPart() error = %v", err)
+			}
+			walkParts(t, part.Header, part, bodies, attachments)
+		}
+		return mediaType
+	}
+
+	var content []byte
+	switch header.Get("Content-Transfer-Encoding") {
+	case "base64":
+		content, err = io.ReadAll(base64.NewDecoder(base64.StdEncoding, body))
+	case "quoted-printable":
+		content, err = io.ReadAll(quotedprintable.NewReader(body))
+	default:
+		content, err = io.ReadAll(body)
+	}
+	if err != nil {
+		t.Fatalf("failed to decode %s part: %v", mediaType, err)
+	}
+	if _, disposition, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		attachments[disposition["filename"]] = string(content)
+	} else {
+		*bodies = append(*bodies, string(content))
+	}
+	return mediaType
+}
+
+func TestMessage_InlineImages(t *testing.T) {
+	logo := Attachment{Name: "logo.png", Content: []byte("png"), Inline: true, ContentID: "logo"}
+	report := Attachment{Name: "report.pdf", Content: []byte("pdf")}
+	tests := []struct {
+		name string
+		msg  *Message
+		want string
+	}{
+		{
+			name: "related HTML",
+			msg:  &Message{TextBody: "hi", HTMLBody: `<img src="cid:logo">`, Attachments: []Attachment{logo}},
+			want: "multipart/alternative[text/plain,multipart/related(text/html)[text/html,image/png<logo>]]",
+		},
+		{
+			name: "related HTML and attachments",
+			msg:  &Message{TextBody: "hi", HTMLBody: `<img src="cid:logo">`, Attachments: []Attachment{report, logo}},
+			want: "multipart/mixed[multipart/alternative[text/plain,multipart/related(text/html)[text/html,image/png<logo>]],application/pdf]",
+		},
+		{
+			name: "HTML only",
+			msg:  &Message{HTMLBody: `<img src="cid:logo">`, Attachments: []Attachment{logo}},
+			want: "multipart/related(text/html)[text/html,image/png<logo>]",
+		},
+		{
+			name: "text only keeps inline images as attachments",
+			msg:  &Message{TextBody: "hi", Attachments: []Attachment{logo}},
+			want: "multipart/mixed[text/plain,image/png<logo>]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.From, tt.msg.To, tt.msg.Subject = "a@example.com", []string{"b@example.com"}, "Hi"
+			raw, err := tt.msg.ToRFC2822()
+			if err != nil {
+				t.Fatalf("ToRFC2822() error = %v", err)
+			}
+			parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("ReadMessage() error = %v", err)
+			}
+			if got := mimeTree(t, textproto.MIMEHeader(parsed.Header), parsed.Body); got != tt.want {
+				t.Errorf("structure = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// mimeTree describes the structure of a MIME entity: the media types of its
+// parts, the root type of a multipart/related and the Content-ID of images
+func mimeTree(t *testing.T, header textproto.MIMEHeader, body io.Reader) string {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("ParseMediaType() error = %v", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		if id := header.Get("Content-ID"); id != "" {
+			return mediaType + id
+		}
+		return mediaType
+	}
+
+	var parts []string
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextRawPart() error = %v", err)
+		}
+		parts = append(parts, mimeTree(t, part.Header, part))
+	}
+	if root := params["type"]; root != "" {
+		mediaType += "(" + root + ")"
+	}
+	return mediaType + "[" + strings.Join(parts, ",") + "]"
+}
+
+func TestMessageBuilder_Calendar(t *testing.T) {
+	start := time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		event     CalendarEvent
+		wantErr   bool
+		wantLines []string
+	}{
+		{
+			name:  "defaults from the message",
+			event: CalendarEvent{Start: start, End: start.Add(2 * time.Hour), Location: "Room 1, Floor 2"},
+			wantLines: []string{
+				"METHOD:REQUEST",
+				"UID:msg-1@example.com",
+				"DTSTART:20260301T220000Z",
+				"DTEND:20260302T000000Z",
+				"SUMMARY:DB maintenance",
+				`LOCATION:Room 1\, Floor 2`,
+				`ORGANIZER;CN="Ops":mailto:ops@example.com`,
+				"ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:alice@example.com",
+				"ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:bob@example.com",
+				"STATUS:CONFIRMED",
+			},
+		},
+		{
+			name:      "cancellation",
+			event:     CalendarEvent{UID: "window-7", Start: start, End: start.Add(time.Hour), Method: "cancel", Sequence: 2, Attendees: []string{"carol@example.com"}},
+			wantLines: []string{"METHOD:CANCEL", "UID:window-7", "SEQUENCE:2", "STATUS:CANCELLED", "ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:carol@example.com"},
+		},
+		{name: "ends before it starts", event: CalendarEvent{Start: start, End: start.Add(-time.Hour)}, wantErr: true},
+		{name: "invalid method", event: CalendarEvent{Start: start, End: start.Add(time.Hour), Method: "PUBLISH"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.From, cfg.FromName = "ops@example.com", "Ops"
+			msg := message.New()
+			msg.ID = "msg-1"
+			msg.Title = "DB maintenance"
+			msg.Body = "The database is read-only during the window."
+			AddCalendarEvent(msg, tt.event)
+
+			emailMsg, err := NewMessageBuilder(cfg).BuildMessage(msg, []target.Target{target.NewEmail("alice@example.com"), {Type: "cc", Value: "bob@example.com"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			ics := strings.ReplaceAll(string(emailMsg.Calendar.ICS(time.Now())), "\r\n ", "")
+			for _, line := range tt.wantLines {
+				if !strings.Contains(ics, "\r\n"+line+"\r\n") {
+					t.Errorf("ICS is missing %q:\n%s", line, ics)
+				}
+			}
+
+			raw, err := emailMsg.ToRFC2822()
+			if err != nil {
+				t.Fatalf("ToRFC2822() error = %v", err)
+			}
+			parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("ReadMessage() error = %v", err)
+			}
+			want := "multipart/mixed[multipart/alternative[text/plain,text/html,text/calendar],application/ics]"
+			if got := mimeTree(t, textproto.MIMEHeader(parsed.Header), parsed.Body); got != want {
+				t.Errorf("structure = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestFoldICSLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{name: "short", line: "SUMMARY:Standup"},
+		{name: "long ASCII", line: "DESCRIPTION:" + strings.Repeat("maintenance ", 20)},
+		{name: "long UTF-8", line: "DESCRIPTION:" + strings.Repeat("数据库维护窗口", 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := foldICSLine(tt.line)
+			for _, line := range strings.Split(folded, "\r\n") {
+				if len(line) > 75 || !utf8.ValidString(line) {
+					t.Errorf("folded line %q is longer than 75 octets or splits a character", line)
+				}
+			}
+			if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("unfolded = %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
+
+func TestTemplateManager_Assets(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"welcome.html":    "Subject: Welcome {{.Title}}\n<img src=\"{{cid \"logo.png\"}}\"><p>{{.Body}}</p>",
+		"broken.html":     "Subject: Broken\n<img src=\"{{cid \"missing.png\"}}\">",
+		"plain.html":      "Subject: Plain\n<p>{{.Body}}</p>",
+		"assets/logo.png": "png",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tm := NewTemplateManager(dir, &mockLogger{})
+	if err := tm.LoadTemplatesFromDir(); err != nil {
+		t.Fatalf("LoadTemplatesFromDir() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		template   string
+		wantBody   string
+		wantInline []string
+		wantErr    bool
+	}{
+		{name: "referenced asset", template: "welcome", wantBody: `<img src="cid:logo.png">`, wantInline: []string{"logo.png"}},
+		{name: "no assets", template: "plain", wantBody: "<p>hello</p>"},
+		{name: "unknown asset", template: "broken", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := tm.RenderTemplate(tt.template, &TemplateData{Title: "Ann", Body: "hello"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !strings.Contains(msg.Body, tt.wantBody) {
+				t.Errorf("body = %q, want to contain %q", msg.Body, tt.wantBody)
+			}
+
+			emailMsg, err := NewMessageBuilder(NewConfig()).BuildMessage(msg, []target.Target{target.NewEmail("b@example.com")})
+			if err != nil {
+				t.Fatalf("BuildMessage() error = %v", err)
+			}
+			var inline []string
+			for _, att := range emailMsg.Attachments {
+				if att.Inline {
+					inline = append(inline, att.ContentID)
+				}
+			}
+			if !reflect.DeepEqual(inline, tt.wantInline) {
+				t.Errorf("inline attachments = %v, want %v", inline, tt.wantInline)
+			}
+		})
+	}
+}
+
+func TestSMTPSender_Attachments(t *testing.T) {
+	large := func() (io.Reader, error) {
+		return bytes.NewReader(make([]byte, 2048)), nil
+	}
+	tests := []struct {
+		name        string
+		attachments []Attachment
+		msgAttached []message.Attachment // the first-class attachments of the message
+		wantErr     bool
+	}{
+		{name: "within limit", attachments: []Attachment{{Name: "a.txt", Content: []byte("hello")}, ReaderAttachment("b.bin", func() (io.Reader, error) {
+			return bytes.NewReader(make([]byte, 1000)), nil
+		})}},
+		{name: "in-memory too large", attachments: []Attachment{{Name: "a.bin", Content: make([]byte, 2048)}}, wantErr: true},
+		{name: "streamed too large", attachments: []Attachment{ReaderAttachment("a.bin", large)}, wantErr: true},
+		{name: "together too large", attachments: []Attachment{{Name: "a.bin", Content: make([]byte, 600)}, ReaderAttachment("b.bin", func() (io.Reader, error) {
+			return bytes.NewReader(make([]byte, 600)), nil
+		})}, wantErr: true},
+		{name: "message attachments", msgAttached: []message.Attachment{{Name: "a.txt", Content: []byte("hello")}}},
+		{name: "message attachments too large", msgAttached: []message.Attachment{{Name: "a.bin", Content: make([]byte, 2048)}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, "")
+			cfg := NewConfig()
+			cfg.SMTPHost, cfg.SMTPPort = server.host, server.port
+			cfg.From = "sender@example.com"
+			cfg.UseTLS, cfg.UseStartTLS = false, false
+			cfg.MaxAttachmentSize = 1024
+			sender, err := NewSMTPSender(cfg, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewSMTPSender() error = %v", err)
+			}
+
+			msg := message.New()
+			msg.Title = "Report"
+			msg.Body = "see attached"
+			AddAttachment(msg, tt.attachments...)
+			msg.AddAttachment(tt.msgAttached...)
+			err = sender.SendMessage(context.Background(), msg, []target.Target{target.NewEmail("user@example.com")})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var emailErr *EmailError
+			if tt.wantErr && (!errors.Is(err, ErrAttachmentTooLarge) || !errors.As(err, &emailErr) || emailErr.Type != ErrorTypeSize || emailErr.Retryable) {
+				t.Errorf("SendMessage() error = %v, want a non-retryable size error", err)
+			}
+
+			// A message stopped while streaming must not be completed
+			delivered := server.messages()
+			if tt.wantErr && len(delivered) != 0 {
+				t.Errorf("server received %d messages, want none", len(delivered))
+			}
+			if !tt.wantErr && (len(delivered) != 1 || !strings.Contains(delivered[0], "multipart/mixed")) {
+				t.Errorf("server received %q, want one multipart message", delivered)
+			}
+		})
+	}
+}
+
+func TestOAuth2TokenSource(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         OAuth2Options
+		status       int
+		response     string
+		wantForm     url.Values
+		wantToken    string
+		wantErr      string
+		wantRequests int // after a second call within and one after the expiry
+	}{
+		{
+			name:         "refresh token",
+			opts:         OAuth2Options{ClientID: "id", ClientSecret: "secret", RefreshToken: "refresh-1"},
+			status:       http.StatusOK,
+			response:     `{"access_token":"access-1","expires_in":3600,"refresh_token":"refresh-2"}`,
+			wantForm:     url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"refresh-1"}, "client_id": {"id"}, "client_secret": {"secret"}},
+			wantToken:    "access-1",
+			wantRequests: 2,
+		},
+		{
+			name:         "client credentials",
+			opts:         OAuth2Options{ClientID: "id", ClientSecret: "secret", Scopes: []string{"https://outlook.office365.com/.default"}},
+			status:       http.StatusOK,
+			response:     `{"access_token":"access-1","expires_in":3600}`,
+			wantForm:     url.Values{"grant_type": {"client_credentials"}, "scope": {"https://outlook.office365.com/.default"}, "client_id": {"id"}, "client_secret": {"secret"}},
+			wantToken:    "access-1",
+			wantRequests: 2,
+		},
+		{
+			name:         "error response",
+			opts:         OAuth2Options{ClientID: "id", RefreshToken: "revoked"},
+			status:       http.StatusBadRequest,
+			response:     `{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`,
+			wantErr:      "invalid_grant Token has been expired or revoked.",
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forms []url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = r.ParseForm()
+				forms = append(forms, r.PostForm)
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			tt.opts.TokenURL = server.URL
+			source := NewOAuth2TokenSource(tt.opts).(*oauth2TokenSource)
+			now := time.Now()
+			source.now = func() time.Time { return now }
+
+			token, err := source.Token(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Token() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(forms) != tt.wantRequests {
+					t.Errorf("token requests = %d, want %d", len(forms), tt.wantRequests)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+			if token.AccessToken != tt.wantToken {
+				t.Errorf("Token() = %q, want %q", token.AccessToken, tt.wantToken)
+			}
+			if !reflect.DeepEqual(forms[0], tt.wantForm) {
+				t.Errorf("token request = %v, want %v", forms[0], tt.wantForm)
+			}
+
+			// Cached until shortly before the expiry
+			now = now.Add(58 * time.Minute)
+			if _, err := source.Token(context.Background()); err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+			now = now.Add(time.Minute + time.Second)
+			if _, err := source.Token(context.Background()); err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+			if len(forms) != tt.wantRequests {
+				t.Errorf("token requests = %d, want %d", len(forms), tt.wantRequests)
+			}
+			if tt.opts.RefreshToken != "" && forms[1].Get("refresh_token") != "refresh-2" {
+				t.Errorf("refresh request used %q, want the rotated refresh token", forms[1].Get("refresh_token"))
+			}
+		})
+	}
+}
+
+func TestSMTPSender_XOAUTH2(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "accepted", token: "access-1"},
+		{name: "rejected", token: "stale", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, "access-1")
+			cfg := NewConfig()
+			cfg.SMTPHost, cfg.SMTPPort = server.host, server.port
+			cfg.From = "sender@example.com"
+			cfg.Username = "sender@example.com"
+			cfg.UseTLS, cfg.UseStartTLS = false, false
+			cfg.TokenSource = staticTokenSource(tt.token)
+			sender, err := NewSMTPSender(cfg, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewSMTPSender() error = %v", err)
+			}
+
+			msg := message.New()
+			msg.Title = "Hello"
+			msg.Body = "hi"
+			err = sender.SendMessage(context.Background(), msg, []target.Target{target.NewEmail("user@example.com")})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := []string{"user=sender@example.com\x01auth=Bearer " + tt.token + "\x01\x01"}
+			if got := server.loginAttempts(); !reflect.DeepEqual(got, want) {
+				t.Errorf("logins = %q, want %q", got, want)
+			}
+			wantDelivered := 1
+			if tt.wantErr {
+				wantDelivered = 0
+			}
+			if delivered := len(server.messages()); delivered != wantDelivered {
+				t.Errorf("server received %d messages, want %d", delivered, wantDelivered)
+			}
+		})
+	}
+}
+
+func TestSMTPSender_Pool(t *testing.T) {
+	tests := []struct {
+		name       string
+		configure  func(*Config)
+		maxPerConn int
+		idle       time.Duration // between sends
+		concurrent bool
+		wantConns  int
+	}{
+		{name: "reused", wantConns: 1},
+		{name: "keep alive disabled", configure: func(c *Config) { c.KeepAlive = false }, wantConns: 3},
+		{name: "server closes connections", maxPerConn: 1, wantConns: 3},
+		{name: "idle timeout", idle: DefaultIdleTimeout + time.Second, wantConns: 3},
+		{name: "pinged after idling", idle: 10 * time.Second, wantConns: 1},
+		{name: "pool size caps connections", configure: func(c *Config) { c.PoolSize = 1 }, concurrent: true, wantConns: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, "")
+			server.mu.Lock()
+			server.maxPerConn = tt.maxPerConn
+			server.mu.Unlock()
+
+			cfg := NewConfig()
+			cfg.SMTPHost, cfg.SMTPPort = server.host, server.port
+			cfg.From = "sender@example.com"
+			cfg.UseTLS, cfg.UseStartTLS = false, false
+			if tt.configure != nil {
+				tt.configure(cfg)
+			}
+			sender, err := NewSMTPSender(cfg, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewSMTPSender() error = %v", err)
+			}
+			defer sender.Close()
+			now := time.Now()
+			var clock sync.Mutex
+			sender.pool.now = func() time.Time {
+				clock.Lock()
+				defer clock.Unlock()
+				return now
+			}
+
+			const sends = 3
+			send := func() error {
+				msg := message.New()
+				msg.Title = "Hello"
+				msg.Body = "hi"
+				return sender.SendMessage(context.Background(), msg, []target.Target{target.NewEmail("user@example.com")})
+			}
+			errs := make(chan error, sends)
+			var wg sync.WaitGroup
+			for i := 0; i < sends; i++ {
+				if !tt.concurrent {
+					errs <- send()
+					clock.Lock()
+					now = now.Add(tt.idle)
+					clock.Unlock()
+					continue
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- send()
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("SendMessage() error = %v", err)
+				}
+			}
+
+			if got := len(server.messages()); got != sends {
+				t.Errorf("server received %d messages, want %d", got, sends)
+			}
+			if got := server.connections(); got != tt.wantConns {
+				t.Errorf("server accepted %d connections, want %d", got, tt.wantConns)
+			}
+		})
+	}
+}
+
+func TestSMTPSender_BounceAddress(t *testing.T) {
+	tests := []struct {
+		name       string
+		returnPath string
+		verp       bool
+		targets    []target.Target
+		want       string
+	}{
+		{name: "from address", targets: []target.Target{target.NewEmail("alice@example.org")}, want: "sender@example.com"},
+		{name: "return path", returnPath: "bounces@example.com", targets: []target.Target{target.NewEmail("alice@example.org")}, want: "bounces@example.com"},
+		{name: "VERP", returnPath: "bounces@example.com", verp: true, targets: []target.Target{target.NewEmail("alice@example.org")}, want: "bounces+msg-1=alice=example.org@example.com"},
+		{name: "VERP with several recipients", returnPath: "bounces@example.com", verp: true, targets: []target.Target{target.NewEmail("alice@example.org"), {Type: "cc", Value: "bob@example.org"}}, want: "bounces+msg-1@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, "")
+			cfg := NewConfig()
+			cfg.SMTPHost, cfg.SMTPPort = server.host, server.port
+			cfg.From = "sender@example.com"
+			cfg.UseTLS, cfg.UseStartTLS = false, false
+			cfg.ReturnPath, cfg.VERP = tt.returnPath, tt.verp
+			sender, err := NewSMTPSender(cfg, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewSMTPSender() error = %v", err)
+			}
+			defer sender.Close()
+
+			msg := message.New()
+			msg.ID = "msg-1"
+			msg.Title = "Hello"
+			msg.Body = "hi"
+			if err := sender.SendMessage(context.Background(), msg, tt.targets); err != nil {
+				t.Fatalf("SendMessage() error = %v", err)
+			}
+			if got := server.envelopeSenders(); !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("envelope senders = %q, want %q", got, tt.want)
+			}
+			if delivered := server.messages(); len(delivered) != 1 || !strings.Contains(delivered[0], HeaderMessageID+": msg-1\n") {
+				t.Errorf("message lacks the %s header: %q", HeaderMessageID, delivered)
+			}
+		})
+	}
+}
+
+func TestVERPAddress(t *testing.T) {
+	tests := []struct {
+		name          string
+		messageID     string
+		recipient     string
+		want          string
+		wantRecipient string
+	}{
+		{name: "message and recipient", messageID: "20260301-msg", recipient: "alice+ops@example.org", want: "bounces+20260301-msg=alice+ops=example.org@example.com", wantRecipient: "alice+ops@example.org"},
+		{name: "message only", messageID: "msg-1", want: "bounces+msg-1@example.com"},
+		{name: "unencodable message ID", messageID: "a=b", recipient: "alice@example.org", want: "bounces@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := VERPAddress("bounces@example.com", tt.messageID, tt.recipient)
+			if got != tt.want {
+				t.Fatalf("VERPAddress() = %q, want %q", got, tt.want)
+			}
+			id, recipient, ok := ParseVERP("bounces@example.com", strings.ToUpper(got[:1])+got[1:])
+			if got == "bounces@example.com" {
+				if ok {
+					t.Errorf("ParseVERP(%q) reported a VERP address", got)
+				}
+				return
+			}
+			if !ok || id != tt.messageID || recipient != tt.wantRecipient {
+				t.Errorf("ParseVERP(%q) = %q, %q, %v", got, id, recipient, ok)
+			}
+		})
+	}
+	if _, _, ok := ParseVERP("bounces@example.com", "bounces+msg-1@example.net"); ok {
+		t.Error("ParseVERP() accepted an address of another domain")
+	}
+}
+
+// dsn builds a delivery status notification for a recipient
+func dsn(to, recipient, action, status string) string {
+	return "From: MAILER-DAEMON@mx.example.org\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: Undelivered Mail Returned to Sender\r\n" +
+		"Date: Sun, 01 Mar 2026 10:00:00 +0000\r\n" +
+		"Auto-Submitted: auto-replied\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/report; report-type=delivery-status; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Your message could not be delivered.\r\n" +
+		"--b1\r\n" +
+		"Content-Type: message/delivery-status\r\n" +
+		"\r\n" +
+		"Reporting-MTA: dns; mx.example.org\r\n" +
+		"\r\n" +
+		"Final-Recipient: rfc822; " + recipient + "\r\n" +
+		"Action: " + action + "\r\n" +
+		"Status: " + status + "\r\n" +
+		"Diagnostic-Code: smtp; 550 " + status + " mailbox\r\n" +
+		"  unavailable\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/rfc822-headers\r\n" +
+		"\r\n" +
+		"From: sender@example.com\r\n" +
+		"To: " + recipient + "\r\n" +
+		"Message-ID: <msg-1@example.com>\r\n" +
+		"X-NotifyHub-Message-ID: msg-1\r\n" +
+		"\r\n" +
+		"--b1--\r\n"
+}
+
+func TestFeedbackParser_Parse(t *testing.T) {
+	received := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		raw  string
+		want []Feedback
+	}{
+		{
+			name: "hard bounce",
+			raw:  dsn("sender@example.com", "alice@example.org", "failed", "5.1.1"),
+			want: []Feedback{{Kind: FeedbackHardBounce, MessageID: "msg-1", Recipient: "alice@example.org", Status: "5.1.1", Diagnostic: "550 5.1.1 mailbox unavailable"}},
+		},
+		{
+			name: "soft bounce",
+			raw:  dsn("sender@example.com", "alice@example.org", "failed", "4.2.2"),
+			want: []Feedback{{Kind: FeedbackSoftBounce, MessageID: "msg-1", Recipient: "alice@example.org", Status: "4.2.2", Diagnostic: "550 4.2.2 mailbox unavailable"}},
+		},
+		{
+			name: "delayed",
+			raw:  dsn("sender@example.com", "alice@example.org", "delayed", "4.4.1"),
+			want: []Feedback{{Kind: FeedbackDelayed, MessageID: "msg-1", Recipient: "alice@example.org", Status: "4.4.1", Diagnostic: "550 4.4.1 mailbox unavailable"}},
+		},
+		{
+			name: "delivered",
+			raw:  dsn("sender@example.com", "alice@example.org", "delivered", "2.0.0"),
+		},
+		{
+			name: "bounce without original headers correlated by VERP",
+			raw: "From: MAILER-DAEMON@mx.example.org\r\n" +
+				"To: bounces+msg-2=bob=example.org@example.com\r\n" +
+				"Subject: failure notice\r\n" +
+				"Date: Sun, 01 Mar 2026 10:00:00 +0000\r\n" +
+				"\r\n" +
+				"Sorry, I couldn't deliver your message.\r\n",
+			want: []Feedback{{Kind: FeedbackHardBounce, MessageID: "msg-2", Recipient: "bob@example.org", Diagnostic: "failure notice"}},
+		},
+		{
+			name: "complaint",
+			raw: "From: fbl@isp.example.net\r\n" +
+				"To: sender@example.com\r\n" +
+				"Subject: Abuse report\r\n" +
+				"Date: Sun, 01 Mar 2026 10:00:00 +0000\r\n" +
+				"Content-Type: multipart/report; report-type=feedback-report; boundary=b2\r\n" +
+				"\r\n" +
+				"--b2\r\n" +
+				"Content-Type: message/feedback-report\r\n" +
+				"\r\n" +
+				"Feedback-Type: abuse\r\n" +
+				"Version: 1\r\n" +
+				"Original-Rcpt-To: <carol@example.net>\r\n" +
+				"\r\n" +
+				"--b2\r\n" +
+				"Content-Type: message/rfc822\r\n" +
+				"\r\n" +
+				"From: sender@example.com\r\n" +
+				"To: carol@example.net\r\n" +
+				"Message-ID: <msg-3@example.com>\r\n" +
+				"\r\n" +
+				"body\r\n" +
+				"--b2--\r\n",
+			want: []Feedback{{Kind: FeedbackComplaint, MessageID: "msg-3", Recipient: "carol@example.net"}},
+		},
+		{
+			name: "reply",
+			raw: "From: Dave <dave@example.org>\r\n" +
+				"To: sender@example.com\r\n" +
+				"Subject: =?UTF-8?B?UmU6IOWRiuitpg==?=\r\n" +
+				"Date: Sun, 01 Mar 2026 10:00:00 +0000\r\n" +
+				"In-Reply-To: <msg-4@example.com>\r\n" +
+				"\r\n" +
+				"Ack\r\n",
+			want: []Feedback{{Kind: FeedbackReply, MessageID: "msg-4", Recipient: "dave@example.org", Subject: "Re: 告警"}},
+		},
+		{
+			name: "out of office",
+			raw: "From: erin@example.org\r\n" +
+				"To: sender@example.com\r\n" +
+				"Subject: Out of office\r\n" +
+				"Date: Sun, 01 Mar 2026 10:00:00 +0000\r\n" +
+				"Auto-Submitted: auto-replied\r\n" +
+				"In-Reply-To: <msg-5@example.com>\r\n" +
+				"\r\n" +
+				"Back on Monday\r\n",
+			want: []Feedback{{Kind: FeedbackAutoReply, MessageID: "msg-5", Recipient: "erin@example.org", Subject: "Out of office"}},
+		},
+		{
+			name: "reply to a message of another domain",
+			raw: "From: dave@example.org\r\n" +
+				"To: sender@example.com\r\n" +
+				"Subject: Re: lunch\r\n" +
+				"In-Reply-To: <abc@mail.example.org>\r\n" +
+				"\r\n" +
+				"Sure\r\n",
+		},
+	}
+	parser := &FeedbackParser{BounceAddress: "bounces@example.com", Domain: "example.com"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.Parse(strings.NewReader(tt.raw))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			for i := range got {
+				if !got[i].ReceivedAt.Equal(received) {
+					t.Errorf("ReceivedAt = %v, want %v", got[i].ReceivedAt, received)
+				}
+				got[i].ReceivedAt = time.Time{}
+				if tt.want[i].Subject == "" && got[i].Kind != FeedbackReply && got[i].Kind != FeedbackAutoReply {
+					got[i].Subject = ""
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingReceiptHandler records the receipts it handles
+type recordingReceiptHandler struct {
+	mu       sync.Mutex
+	receipts []*receipt.Receipt
+}
+
+func (h *recordingReceiptHandler) ID() string                      { return "recording" }
+func (h *recordingReceiptHandler) Priority() int                   { return 0 }
+func (h *recordingReceiptHandler) CanHandle(*receipt.Receipt) bool { return true }
+func (h *recordingReceiptHandler) Handle(_ context.Context, r *receipt.Receipt) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.receipts = append(h.receipts, r)
+	return nil
+}
+
+func TestMailboxPoller_Poll(t *testing.T) {
+	tests := []struct {
+		name        string
+		delete      bool
+		messages    []string
+		wantEntries []suppression.Entry
+		wantResults []receipt.PlatformResult
+	}{
+		{
+			name: "bounce, complaint and reply",
+			messages: []string{
+				dsn("bounces+msg-1=alice=example.org@example.com", "alice@example.org", "failed", "5.1.1"),
+				dsn("bounces+msg-1=bob=example.org@example.com", "bob@example.org", "failed", "4.2.2"),
+				"From: dave@example.org\r\nSubject: Re: hi\r\nDate: Sun, 01 Mar 2026 10:00:00 +0000\r\nIn-Reply-To: <msg-4@example.com>\r\n\r\nok\r\n",
+				"From: newsletter@example.net\r\nSubject: unrelated\r\n\r\nhello\r\n",
+			},
+			wantEntries: []suppression.Entry{{Channel: "email", Address: "alice@example.org", Reason: suppression.ReasonBounce}},
+			wantResults: []receipt.PlatformResult{
+				{Platform: "email", Target: "alice@example.org", Status: receipt.ResultBounced, Error: "550 5.1.1 mailbox unavailable"},
+				{Platform: "email", Target: "bob@example.org", Status: receipt.ResultBounced, Error: "550 4.2.2 mailbox unavailable"},
+				{Platform: "email", Target: "dave@example.org", Status: receipt.ResultReplied, Success: true},
+			},
+		},
+		{
+			name:        "deleted after processing",
+			delete:      true,
+			messages:    []string{dsn("sender@example.com", "alice@example.org", "failed", "5.1.1")},
+			wantEntries: []suppression.Entry{{Channel: "email", Address: "alice@example.org", Reason: suppression.ReasonBounce}},
+			wantResults: []receipt.PlatformResult{{Platform: "email", Target: "alice@example.org", Status: receipt.ResultBounced, Error: "550 5.1.1 mailbox unavailable"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeIMAPServer(t, tt.messages)
+			store := suppression.NewMemoryStore()
+			handler := &recordingReceiptHandler{}
+			processor := receipt.NewDefaultProcessor(receipt.ProcessorConfig{}, &mockLogger{})
+			processor.AddHandler(handler)
+
+			poller, err := NewMailboxPoller(MailboxPollerConfig{
+				IMAP:         IMAPConfig{Host: "127.0.0.1", Port: server.port, Username: "bounces@example.com", Password: `p"ss`, Delete: tt.delete},
+				Parser:       FeedbackParser{BounceAddress: "bounces@example.com", Domain: "example.com"},
+				Suppressions: store,
+				Receipts:     processor,
+			})
+			if err != nil {
+				t.Fatalf("NewMailboxPoller() error = %v", err)
+			}
+
+			n, err := poller.Poll(context.Background())
+			if err != nil || n != len(tt.messages) {
+				t.Fatalf("Poll() = %d, %v, want %d", n, err, len(tt.messages))
+			}
+			if got := server.login(); got != `"bounces@example.com" "p\"ss"` {
+				t.Errorf("login = %s", got)
+			}
+
+			entries, _ := store.List(context.Background())
+			for i := range entries {
+				entries[i].CreatedAt = time.Time{}
+			}
+			if !reflect.DeepEqual(entries, tt.wantEntries) {
+				t.Errorf("suppressions = %+v, want %+v", entries, tt.wantEntries)
+			}
+
+			var results []receipt.PlatformResult
+			for _, r := range handler.receipts {
+				for _, result := range r.Results {
+					result.Timestamp = time.Time{}
+					results = append(results, result)
+				}
+			}
+			if !reflect.DeepEqual(results, tt.wantResults) {
+				t.Errorf("receipt results = %+v, want %+v", results, tt.wantResults)
+			}
+
+			// Processed messages are not read again
+			if n, err := poller.Poll(context.Background()); err != nil || n != 0 {
+				t.Errorf("second Poll() = %d, %v, want 0", n, err)
+			}
+			if got := server.remaining(); tt.delete && got != 0 {
+				t.Errorf("%d messages remain, want them deleted", got)
+			}
+		})
+	}
+}
+
+// fakeIMAPServer serves a mailbox of messages over plain IMAP
+type fakeIMAPServer struct {
+	port int
+
+	mu       sync.Mutex
+	messages map[uint32]string
+	flags    map[uint32]string
+	logins   []string
+}
+
+func newFakeIMAPServer(t *testing.T, messages []string) *fakeIMAPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeIMAPServer{
+		port:     listener.Addr().(*net.TCPAddr).Port,
+		messages: make(map[uint32]string),
+		flags:    make(map[uint32]string),
+	}
+	for i, msg := range messages {
+		s.messages[uint32(i+10)] = msg
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	return s
+}
+
+// login returns the arguments of the last LOGIN command
+func (s *fakeIMAPServer) login() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.logins) == 0 {
+		return ""
+	}
+	return s.logins[len(s.logins)-1]
+}
+
+// remaining returns the number of messages in the mailbox
+func (s *fakeIMAPServer) remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.messages)
+}
+
+func (s *fakeIMAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("* OK IMAP4rev1 ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		fields := strings.SplitN(line, " ", 3)
+		tag, cmd := fields[0], strings.ToUpper(fields[1])
+		args := ""
+		if len(fields) > 2 {
+			args = fields[2]
+		}
+		if cmd == "UID" {
+			sub := strings.SplitN(args, " ", 2)
+			cmd, args = "UID "+strings.ToUpper(sub[0]), sub[1]
+		}
+
+		s.mu.Lock()
+		switch cmd {
+		case "CAPABILITY":
+			_ = text.PrintfLine("* CAPABILITY IMAP4rev1")
+		case "LOGIN":
+			s.logins = append(s.logins, args)
+		case "SELECT":
+			_ = text.PrintfLine("* %d EXISTS", len(s.messages))
+		case "UID SEARCH":
+			var uids []string
+			for uid := range s.messages {
+				if !strings.Contains(s.flags[uid], `\Seen`) {
+					uids = append(uids, fmt.Sprint(uid))
+				}
+			}
+			sort.Strings(uids)
+			_ = text.PrintfLine("* SEARCH %s", strings.Join(uids, " "))
+		case "UID FETCH":
+			var uid uint32
+			_, _ = fmt.Sscanf(args, "%d", &uid)
+			msg := s.messages[uid]
+			_ = text.PrintfLine("* 1 FETCH (UID %d BODY[] {%d}\r\n%s)", uid, len(msg), msg)
+		case "UID STORE":
+			var uid uint32
+			_, _ = fmt.Sscanf(args, "%d", &uid)
+			s.flags[uid] += args[strings.Index(args, "("):]
+		case "EXPUNGE":
+			for uid, flags := range s.flags {
+				if strings.Contains(flags, `\Deleted`) {
+					delete(s.messages, uid)
+					delete(s.flags, uid)
+				}
+			}
+		case "LOGOUT":
+			_ = text.PrintfLine("* BYE")
+		}
+		s.mu.Unlock()
+		_ = text.PrintfLine("%s OK done", tag)
+		if cmd == "LOGOUT" {
+			return
+		}
+	}
+}
+
+// staticTokenSource returns a fixed token
+type staticTokenSource string
+
+func (s staticTokenSource) Token(context.Context) (*Token, error) {
+	return &Token{AccessToken: string(s)}, nil
+}
+
+// fakeSMTPServer accepts SMTP transactions and records the messages whose
+// data was completed. With a token it offers XOAUTH2 and accepts that
+// bearer token only.
+type fakeSMTPServer struct {
+	host  string
+	port  int
+	token string
+
+	mu         sync.Mutex
+	delivered  []string
+	senders    []string // envelope senders of MAIL commands
+	logins     []string
+	conns      int
+	maxPerConn int // close connections after this many messages when positive
+}
+
+func newFakeSMTPServer(t *testing.T, token string) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	s := &fakeSMTPServer{host: "127.0.0.1", port: addr.Port, token: token}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	return s
+}
+
+// loginAttempts returns the decoded XOAUTH2 responses the server received
+func (s *fakeSMTPServer) loginAttempts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.logins...)
+}
+
+// messages returns the delivered messages. The server records a message
+// before it acknowledges its data, which a successful send waits for.
+func (s *fakeSMTPServer) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.delivered...)
+}
+
+// envelopeSenders returns the addresses of the MAIL commands the server
+// received
+func (s *fakeSMTPServer) envelopeSenders() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.senders...)
+}
+
+// connections returns the number of connections the server accepted
+func (s *fakeSMTPServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	s.mu.Lock()
+	s.conns++
+	maxPerConn := s.maxPerConn
+	s.mu.Unlock()
+
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 localhost ESMTP")
+	messages := 0
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(strings.SplitN(line, " ", 2)[0]) {
+		case "EHLO", "HELO":
+			if s.token == "" {
+				_ = text.PrintfLine("250 localhost")
+				continue
+			}
+			_ = text.PrintfLine("250-localhost")
+			_ = text.PrintfLine("250 AUTH XOAUTH2")
+		case "AUTH":
+			fields := strings.Fields(line)
+			response, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			s.mu.Lock()
+			s.logins = append(s.logins, string(response))
+			s.mu.Unlock()
+			if string(response) == "user=sender@example.com\x01auth=Bearer "+s.token+"\x01\x01" {
+				_ = text.PrintfLine("235 accepted")
+				continue
+			}
+			_ = text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(`{"status":"401"}`)))
+			if _, err := text.ReadLine(); err != nil {
+				return
+			}
+			_ = text.PrintfLine("535 invalid credentials")
+		case "MAIL":
+			_, from, _ := strings.Cut(line, ":")
+			s.mu.Lock()
+			s.senders = append(s.senders, strings.Trim(from, " <>"))
+			s.mu.Unlock()
+			_ = text.PrintfLine("250 ok")
+		case "DATA":
+			_ = text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.delivered = append(s.delivered, string(data))
+			s.mu.Unlock()
+			_ = text.PrintfLine("250 queued")
+			if messages++; maxPerConn > 0 && messages >= maxPerConn {
+				return
+			}
+		case "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("250 ok")
+		}
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
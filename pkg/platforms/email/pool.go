@@ -0,0 +1,179 @@
+// Package email provides SMTP connection pooling for NotifyHub
+package email
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"sync"
+	"time"
+)
+
+// Defaults of the SMTP connection pool
+const (
+	DefaultPoolSize    = 10
+	DefaultIdleTimeout = 30 * time.Second
+
+	// poolPingAfter is how long a connection may stay idle before it is
+	// checked with a NOOP when it is reused
+	poolPingAfter = 5 * time.Second
+)
+
+// smtpConn is an SMTP connection of a pool
+type smtpConn struct {
+	*smtp.Client
+	reused   bool
+	idleFrom time.Time
+}
+
+// smtpPool reuses authenticated SMTP connections, so that bulk sends do not
+// pay the TLS handshake and login for each message, and caps the number of
+// connections open at once to respect provider connection limits
+type smtpPool struct {
+	dial        func() (*smtp.Client, error)
+	slots       chan struct{}
+	maxIdle     int
+	idleTimeout time.Duration // negative closes connections after each send
+	now         func() time.Time
+
+	mu     sync.Mutex
+	idle   []*smtpConn // most recently used last
+	closed bool
+}
+
+// newSMTPPool creates a pool of the connections dial opens
+func newSMTPPool(config *Config, dial func() (*smtp.Client, error)) *smtpPool {
+	size := config.PoolSize
+	if size <= 0 {
+		size = DefaultPoolSize
+	}
+	maxIdle := config.MaxIdleConns
+	if maxIdle <= 0 || maxIdle > size {
+		maxIdle = size
+	}
+	idleTimeout := config.IdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	if !config.KeepAlive {
+		idleTimeout = -1
+	}
+	return &smtpPool{
+		dial:        dial,
+		slots:       make(chan struct{}, size),
+		maxIdle:     maxIdle,
+		idleTimeout: idleTimeout,
+		now:         time.Now,
+	}
+}
+
+// get returns an idle connection that passes its health check, or dials
+// one. It waits while the pool has as many connections in use as it may
+// hold; put or discard must release the connection.
+func (p *smtpPool) get(ctx context.Context) (*smtpConn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		conn := p.popIdle()
+		if conn == nil {
+			break
+		}
+		if p.now().Sub(conn.idleFrom) < poolPingAfter {
+			return conn, nil
+		}
+		if err := conn.Noop(); err == nil {
+			return conn, nil
+		}
+		_ = conn.Close()
+	}
+
+	client, err := p.dial()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return &smtpConn{Client: client}, nil
+}
+
+// redial replaces a reused connection the server stopped accepting, such
+// as after its limit of messages per connection, keeping its slot
+func (p *smtpPool) redial(conn *smtpConn) (*smtpConn, error) {
+	_ = conn.Close()
+	client, err := p.dial()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return &smtpConn{Client: client}, nil
+}
+
+// put returns a connection after a completed transaction, keeping it idle
+// for reuse when the pool has room
+func (p *smtpPool) put(conn *smtpConn) {
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	keep := !p.closed && p.idleTimeout > 0 && len(p.idle) < p.maxIdle
+	if keep {
+		conn.reused = true
+		conn.idleFrom = p.now()
+		p.idle = append(p.idle, conn)
+	}
+	p.mu.Unlock()
+
+	if !keep {
+		_ = conn.Quit()
+	}
+}
+
+// discard closes a connection whose transaction failed, leaving its state
+// unknown
+func (p *smtpPool) discard(conn *smtpConn) {
+	_ = conn.Close()
+	<-p.slots
+}
+
+// popIdle takes the most recently used idle connection, closing the ones
+// idle for longer than the idle timeout
+func (p *smtpPool) popIdle() *smtpConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for len(p.idle) > 0 {
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if now.Sub(conn.idleFrom) <= p.idleTimeout {
+			return conn
+		}
+		// The older connections expired as well; the server may have
+		// dropped them already, so they are closed without QUIT
+		for _, stale := range append(p.idle, conn) {
+			_ = stale.Close()
+		}
+		p.idle = nil
+	}
+	return nil
+}
+
+// close closes the idle connections; connections in use are closed when
+// they are returned
+func (p *smtpPool) close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	var firstErr error
+	for _, conn := range idle {
+		if err := conn.Quit(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close SMTP connection: %w", err)
+		}
+	}
+	return firstErr
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"net/smtp"
 	"strings"
 	"time"
@@ -20,6 +21,7 @@ type SMTPSender struct {
 	authHandler *AuthHandler
 	msgBuilder  *MessageBuilder
 	logger      logger.Logger
+	pool        *smtpPool
 }
 
 // NewSMTPSender creates a new SMTP email sender
@@ -32,12 +34,14 @@ func NewSMTPSender(config *Config, logger logger.Logger) (*SMTPSender, error) {
 		return nil, fmt.Errorf("invalid email config: %w", err)
 	}
 
-	return &SMTPSender{
+	s := &SMTPSender{
 		config:      config,
 		authHandler: NewAuthHandler(config),
 		msgBuilder:  NewMessageBuilder(config),
 		logger:      logger,
-	}, nil
+	}
+	s.pool = newSMTPPool(config, s.connectSMTP)
+	return s, nil
 }
 
 // SendMessage sends an email message using SMTP
@@ -97,10 +101,10 @@ func (s *SMTPSender) SendMessage(ctx context.Context, msg *message.Message, targ
 func (s *SMTPSender) sendSMTP(ctx context.Context, emailMsg *Message) error {
 	s.logger.Debug("连接SMTP服务器", "host", s.config.SMTPHost, "port", s.config.SMTPPort)
 
-	// Get RFC2822 format message
-	messageBytes, err := emailMsg.ToRFC2822()
-	if err != nil {
-		return fmt.Errorf("failed to convert message to RFC2822: %w", err)
+	// Fail before connecting when the in-memory attachments are too large
+	limit := s.config.attachmentLimit()
+	if err := emailMsg.checkAttachmentSize(limit); err != nil {
+		return err
 	}
 
 	// Get all recipients
@@ -109,18 +113,37 @@ func (s *SMTPSender) sendSMTP(ctx context.Context, emailMsg *Message) error {
 		return fmt.Errorf("no recipients specified")
 	}
 
-	s.logger.Debug("邮件内容大小", "bytes", len(messageBytes), "recipients", len(recipients))
+	s.logger.Debug("邮件内容大小", "bytes", emailMsg.GetSize(), "attachments", len(emailMsg.Attachments), "recipients", len(recipients))
 
 	// Setup SMTP connection with context
-	if err := s.sendWithContext(ctx, emailMsg.From, recipients, messageBytes); err != nil {
+	write := func(w io.Writer) error {
+		return emailMsg.write(w, limit)
+	}
+	if err := s.sendWithContext(ctx, s.envelopeSender(emailMsg, recipients), recipients, write); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// sendWithContext sends email with context support
-func (s *SMTPSender) sendWithContext(ctx context.Context, from string, to []string, message []byte) error {
+// envelopeSender returns the address bounces of a message are returned to
+func (s *SMTPSender) envelopeSender(emailMsg *Message, recipients []string) string {
+	if s.config.ReturnPath == "" {
+		return emailMsg.From
+	}
+	if !s.config.VERP {
+		return s.config.ReturnPath
+	}
+	recipient := ""
+	if len(recipients) == 1 {
+		recipient = recipients[0]
+	}
+	return VERPAddress(s.config.ReturnPath, emailMsg.Headers[HeaderMessageID], recipient)
+}
+
+// sendWithContext sends email with context support, streaming the message
+// that write writes
+func (s *SMTPSender) sendWithContext(ctx context.Context, from string, to []string, write func(io.Writer) error) error {
 	serverAddr := s.config.GetServerAddress()
 	s.logger.Debug("正在连接SMTP服务器", "server", serverAddr)
 
@@ -129,69 +152,87 @@ func (s *SMTPSender) sendWithContext(ctx context.Context, from string, to []stri
 
 	go func() {
 		defer close(resultChan)
+		resultChan <- s.transact(ctx, from, to, write)
+	}()
 
-		// Connect to SMTP server
-		client, err := s.connectSMTP()
-		if err != nil {
-			resultChan <- fmt.Errorf("failed to connect to SMTP server: %w", err)
-			return
-		}
-		defer func() {
-			if closeErr := client.Close(); closeErr != nil {
-				s.logger.Warn("SMTP客户端关闭失败", "error", closeErr)
-			}
-		}()
-
-		s.logger.Debug("✅ SMTP连接成功")
+	// Wait for result or context cancellation
+	select {
+	case err := <-resultChan:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("email sending cancelled: %w", ctx.Err())
+	}
+}
 
-		// Set sender (extract email address from formatted string)
-		senderAddress := s.extractEmailAddress(from)
-		if err := client.Mail(senderAddress); err != nil {
-			resultChan <- fmt.Errorf("failed to set sender: %w", err)
-			return
+// transact sends a message on a pooled connection, which is returned to
+// the pool once the transaction completed and closed otherwise
+func (s *SMTPSender) transact(ctx context.Context, from string, to []string, write func(io.Writer) error) (err error) {
+	// Take a pooled connection, or connect to SMTP server
+	client, err := s.pool.get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer func() {
+		switch {
+		case client == nil:
+		case err == nil:
+			s.pool.put(client)
+		default:
+			s.pool.discard(client)
 		}
+	}()
 
-		s.logger.Debug("✅ 设置发件人成功", "from", from)
+	if client.reused {
+		s.logger.Debug("✅ 复用SMTP连接")
+	} else {
+		s.logger.Debug("✅ SMTP连接成功")
+	}
 
-		// Set recipients
-		for _, recipient := range to {
-			if err := client.Rcpt(recipient); err != nil {
-				resultChan <- fmt.Errorf("failed to set recipient %s: %w", recipient, err)
-				return
-			}
+	// Set sender (extract email address from formatted string)
+	senderAddress := s.extractEmailAddress(from)
+	err = client.Mail(senderAddress)
+	if err != nil && client.reused {
+		// The server may stop accepting messages on a connection, e.g.
+		// after its limit per connection: retry once on a new one
+		s.logger.Debug("复用的SMTP连接不可用，重新连接", "error", err)
+		if client, err = s.pool.redial(client); err != nil {
+			return fmt.Errorf("failed to connect to SMTP server: %w", err)
 		}
+		err = client.Mail(senderAddress)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
 
-		s.logger.Debug("✅ 设置收件人成功", "count", len(to))
+	s.logger.Debug("✅ 设置发件人成功", "from", from)
 
-		// Send message
-		wc, err := client.Data()
-		if err != nil {
-			resultChan <- fmt.Errorf("failed to initiate data transfer: %w", err)
-			return
+	// Set recipients
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("failed to set recipient %s: %w", recipient, err)
 		}
+	}
 
-		if _, err := wc.Write(message); err != nil {
-			_ = wc.Close() // Best effort close, original error is more important
-			resultChan <- fmt.Errorf("failed to write message data: %w", err)
-			return
-		}
+	s.logger.Debug("✅ 设置收件人成功", "count", len(to))
 
-		if err := wc.Close(); err != nil {
-			resultChan <- fmt.Errorf("failed to close data writer: %w", err)
-			return
-		}
+	// Send message
+	wc, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to initiate data transfer: %w", err)
+	}
 
-		s.logger.Debug("✅ 邮件数据发送成功")
-		resultChan <- nil
-	}()
+	// Closing the data writer would end the message, so on failure the
+	// connection is dropped instead and the server discards it
+	if err := write(wc); err != nil {
+		return fmt.Errorf("failed to write message data: %w", err)
+	}
 
-	// Wait for result or context cancellation
-	select {
-	case err := <-resultChan:
-		return err
-	case <-ctx.Done():
-		return fmt.Errorf("email sending cancelled: %w", ctx.Err())
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("failed to close data writer: %w", err)
 	}
+
+	s.logger.Debug("✅ 邮件数据发送成功")
+	return nil
 }
 
 // connectSMTP establishes an SMTP connection with authentication
@@ -369,7 +410,7 @@ func (s *SMTPSender) GetServerCapabilities(ctx context.Context) (map[string]stri
 // Close closes the SMTP sender (placeholder for future connection pooling)
 func (s *SMTPSender) Close() error {
 	s.logger.Debug("关闭SMTP发送器")
-	return nil
+	return s.pool.close()
 }
 
 // ConvertNotifyHubConfig converts NotifyHub config to email config
@@ -7,6 +7,7 @@ import (
 	htmlTemplate "html/template"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	textTemplate "text/template"
 
@@ -58,6 +59,7 @@ type TemplateData struct {
 // TemplateManager manages email templates
 type TemplateManager struct {
 	templates   map[string]*EmailTemplate
+	assets      map[string]Attachment // inline images by content ID
 	templateDir string
 	logger      logger.Logger
 }
@@ -66,6 +68,7 @@ type TemplateManager struct {
 func NewTemplateManager(templateDir string, logger logger.Logger) *TemplateManager {
 	return &TemplateManager{
 		templates:   make(map[string]*EmailTemplate),
+		assets:      make(map[string]Attachment),
 		templateDir: templateDir,
 		logger:      logger,
 	}
@@ -86,6 +89,9 @@ func (tm *TemplateManager) LoadTemplatesFromDir() error {
 
 	for _, file := range files {
 		if file.IsDir() {
+			if file.Name() == "assets" {
+				tm.loadAssetsFromDir(filepath.Join(tm.templateDir, file.Name()))
+			}
 			continue
 		}
 
@@ -103,6 +109,50 @@ func (tm *TemplateManager) LoadTemplatesFromDir() error {
 	return nil
 }
 
+// loadAssetsFromDir registers the files of the assets directory of the
+// templates as inline images named by their file names
+func (tm *TemplateManager) loadAssetsFromDir(dir string) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		tm.logger.Warn("无法读取模板资源目录", "dir", dir, "error", err)
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if err := tm.AddAssetFile(file.Name(), filepath.Join(dir, file.Name())); err != nil {
+			tm.logger.Error("加载模板资源失败", "file", file.Name(), "error", err)
+		}
+	}
+}
+
+// AddAsset registers an image HTML templates embed with the cid function:
+//
+//	<img src="{{cid "logo.png"}}" alt="logo">
+//
+// The images a rendered template references are attached to its message
+// inline, so that they render inside the email instead of as downloads.
+func (tm *TemplateManager) AddAsset(contentID string, att Attachment) {
+	att.Inline = true
+	att.ContentID = contentID
+	if att.Name == "" {
+		att.Name = contentID
+	}
+	tm.assets[contentID] = att
+}
+
+// AddAssetFile registers an image file, streamed when a message is sent,
+// as an asset
+func (tm *TemplateManager) AddAssetFile(contentID, path string) error {
+	att, err := FileAttachment(path)
+	if err != nil {
+		return err
+	}
+	tm.AddAsset(contentID, att)
+	return nil
+}
+
 // loadTemplateFromFile loads a template from a file
 func (tm *TemplateManager) loadTemplateFromFile(filePath string) error {
 	content, err := os.ReadFile(filePath)
@@ -197,7 +247,8 @@ func (tm *TemplateManager) RenderTemplate(templateName string, data *TemplateDat
 	}
 
 	// Render content
-	body, err := tm.renderContent(emailTemplate, data)
+	assets := make(map[string]bool)
+	body, err := tm.renderContent(emailTemplate, data, assets)
 	if err != nil {
 		return nil, fmt.Errorf("渲染邮件内容失败: %w", err)
 	}
@@ -207,6 +258,11 @@ func (tm *TemplateManager) RenderTemplate(templateName string, data *TemplateDat
 	msg.Title = subject
 	msg.Body = body
 
+	// Attach the referenced images inline
+	for _, contentID := range sortedAssetIDs(assets) {
+		AddAttachment(msg, tm.assets[contentID])
+	}
+
 	// Set format based on template type
 	switch emailTemplate.Type {
 	case TemplateTypeHTML:
@@ -245,11 +301,12 @@ func (tm *TemplateManager) RenderTemplate(templateName string, data *TemplateDat
 	return msg, nil
 }
 
-// renderContent renders template content based on type
-func (tm *TemplateManager) renderContent(emailTemplate *EmailTemplate, data *TemplateData) (string, error) {
+// renderContent renders template content based on type, recording the
+// assets an HTML template references
+func (tm *TemplateManager) renderContent(emailTemplate *EmailTemplate, data *TemplateData, assets map[string]bool) (string, error) {
 	switch emailTemplate.Type {
 	case TemplateTypeHTML:
-		return tm.renderHTML(emailTemplate.Content, data)
+		return tm.renderHTML(emailTemplate.Content, data, assets)
 	case TemplateTypeMarkdown:
 		return tm.renderText(emailTemplate.Content, data)
 	default:
@@ -273,8 +330,18 @@ func (tm *TemplateManager) renderText(content string, data *TemplateData) (strin
 }
 
 // renderHTML renders HTML template
-func (tm *TemplateManager) renderHTML(content string, data *TemplateData) (string, error) {
-	tmpl, err := htmlTemplate.New("html").Parse(content)
+func (tm *TemplateManager) renderHTML(content string, data *TemplateData, assets map[string]bool) (string, error) {
+	funcs := htmlTemplate.FuncMap{
+		// cid references an asset, which is attached inline
+		"cid": func(contentID string) (htmlTemplate.URL, error) {
+			if _, ok := tm.assets[contentID]; !ok {
+				return "", fmt.Errorf("模板资源不存在: %s", contentID)
+			}
+			assets[contentID] = true
+			return htmlTemplate.URL("cid:" + contentID), nil
+		},
+	}
+	tmpl, err := htmlTemplate.New("html").Funcs(funcs).Parse(content)
 	if err != nil {
 		return "", fmt.Errorf("解析HTML模板失败: %w", err)
 	}
@@ -287,6 +354,16 @@ func (tm *TemplateManager) renderHTML(content string, data *TemplateData) (strin
 	return buf.String(), nil
 }
 
+// sortedAssetIDs returns the content IDs of the referenced assets in order
+func sortedAssetIDs(assets map[string]bool) []string {
+	ids := make([]string, 0, len(assets))
+	for id := range assets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // ListTemplates returns all available templates
 func (tm *TemplateManager) ListTemplates() map[string]*EmailTemplate {
 	result := make(map[string]*EmailTemplate)
@@ -471,7 +548,7 @@ func (tm *TemplateManager) ValidateTemplate(template *EmailTemplate) error {
 	}
 
 	// Test content rendering
-	_, err := tm.renderContent(template, testData)
+	_, err := tm.renderContent(template, testData, make(map[string]bool))
 	if err != nil {
 		return fmt.Errorf("模板内容验证失败: %w", err)
 	}
@@ -0,0 +1,236 @@
+// Package feishu provides the Feishu app (Open API) client for NotifyHub
+// This file handles tenant access tokens and calls of the Open API
+package feishu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the Open API endpoint of Feishu
+const DefaultBaseURL = "https://open.feishu.cn"
+
+// tokenRefreshMargin is how long before it expires a tenant access token is
+// replaced; Feishu returns a new token once less than 30 minutes remain
+const tokenRefreshMargin = 5 * time.Minute
+
+// Open API codes of an invalid or expired tenant access token
+var invalidTokenCodes = map[int]bool{99991661: true, 99991663: true, 99991668: true}
+
+// APIError is an error response of the Feishu Open API
+type APIError struct {
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("feishu API error %d: %s", e.Code, e.Msg)
+}
+
+// appClient sends messages as a Feishu app with a cached tenant access token
+type appClient struct {
+	baseURL   string
+	appID     string
+	appSecret string
+	client    *http.Client
+	now       func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// newAppClient creates the client of an app
+func newAppClient(baseURL, appID, appSecret string, client *http.Client) *appClient {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &appClient{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		appID:     appID,
+		appSecret: appSecret,
+		client:    client,
+		now:       time.Now,
+	}
+}
+
+// tenantToken returns the cached tenant access token, fetching a new one
+// when it is about to expire
+func (a *appClient) tenantToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && a.now().Before(a.expires) {
+		return a.token, nil
+	}
+
+	var resp struct {
+		Code   int    `json:"code"`
+		Msg    string `json:"msg"`
+		Token  string `json:"tenant_access_token"`
+		Expire int    `json:"expire"` // seconds
+	}
+	body := map[string]string{"app_id": a.appID, "app_secret": a.appSecret}
+	if err := a.post(ctx, "/open-apis/auth/v3/tenant_access_token/internal", "", body, &resp); err != nil {
+		return "", fmt.Errorf("failed to get tenant access token: %w", err)
+	}
+	if resp.Code != 0 {
+		return "", fmt.Errorf("failed to get tenant access token: %w", &APIError{Code: resp.Code, Msg: resp.Msg})
+	}
+
+	a.token = resp.Token
+	a.expires = a.now().Add(time.Duration(resp.Expire)*time.Second - tokenRefreshMargin)
+	return a.token, nil
+}
+
+// invalidate drops a token the API rejected
+func (a *appClient) invalidate(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token == token {
+		a.token = ""
+	}
+}
+
+// sendMessage sends a message to a receiver and returns the Feishu message
+// ID
+func (a *appClient) sendMessage(ctx context.Context, receiveIDType, receiveID string, msg *FeishuMessage) (string, error) {
+	content, err := apiContent(msg)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(map[string]string{"receive_id": receiveID, "msg_type": msg.MsgType, "content": content})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	body := func() (io.Reader, string, error) {
+		return bytes.NewReader(data), "application/json; charset=utf-8", nil
+	}
+
+	var resp struct {
+		MessageID string `json:"message_id"`
+	}
+	path := "/open-apis/im/v1/messages?receive_id_type=" + url.QueryEscape(receiveIDType)
+	if err := a.call(ctx, path, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.MessageID, nil
+}
+
+// call sends a request authorized with the tenant access token and decodes
+// the data of its response into out. A rejected token is replaced and the
+// request sent once more, so body is called for every attempt.
+func (a *appClient) call(ctx context.Context, path string, body func() (io.Reader, string, error), out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		token, err := a.tenantToken(ctx)
+		if err != nil {
+			return err
+		}
+		r, contentType, err := body()
+		if err != nil {
+			return err
+		}
+
+		var resp struct {
+			Code int             `json:"code"`
+			Msg  string          `json:"msg"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := a.do(ctx, path, token, r, contentType, &resp); err != nil {
+			return err
+		}
+		if resp.Code == 0 {
+			if out == nil || len(resp.Data) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(resp.Data, out); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
+			return nil
+		}
+		if invalidTokenCodes[resp.Code] && attempt == 0 {
+			a.invalidate(token)
+			continue
+		}
+		return &APIError{Code: resp.Code, Msg: resp.Msg}
+	}
+}
+
+// post sends a JSON request to the Open API and decodes its response
+func (a *appClient) post(ctx context.Context, path, token string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return a.do(ctx, path, token, bytes.NewReader(data), "application/json; charset=utf-8", out)
+}
+
+// do sends a request to the Open API and decodes its response, which
+// carries an error code in its body whatever the HTTP status
+func (a *appClient) do(ctx context.Context, path, token string, body io.Reader, contentType string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// apiContent returns the content of a message as the JSON string the IM
+// API expects, whose rich text lacks the "post" wrapper of webhooks
+func apiContent(msg *FeishuMessage) (string, error) {
+	content := msg.Content
+	if rich, ok := content.(*FeishuRichTextContent); ok {
+		content = rich.Post
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message content: %w", err)
+	}
+	return string(data), nil
+}
+
+// receiveIDType returns the kind of ID a user or chat target holds: chat
+// IDs start with oc_, open_ids with ou_ and union_ids with on_, email
+// addresses contain @ and anything else is a user_id
+func receiveIDType(targetType, value string) string {
+	switch {
+	case strings.HasPrefix(value, "oc_"):
+		return "chat_id"
+	case strings.HasPrefix(value, "ou_"):
+		return "open_id"
+	case strings.HasPrefix(value, "on_"):
+		return "union_id"
+	case strings.Contains(value, "@"):
+		return "email"
+	case targetType == "group":
+		return "chat_id"
+	default:
+		return "user_id"
+	}
+}
@@ -0,0 +1,598 @@
+// Package feishu provides the interactive card callback handler for NotifyHub
+// This file verifies card action callbacks and dispatches button clicks to
+// registered handlers
+package feishu
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platformdata"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// Actions of the buttons AcknowledgeButton and ResolveButton create
+const (
+	CardActionAcknowledge = "acknowledge"
+	CardActionResolve     = "resolve"
+)
+
+// Keys of the value of a card button that NotifyHub sets
+const (
+	cardValueAction    = "action"
+	cardValueMessageID = "notifyhub_message_id"
+)
+
+// DefaultCallbackMaxSkew is how far the timestamp of a signed callback may
+// be from the current time
+const DefaultCallbackMaxSkew = 5 * time.Minute
+
+// maxCallbackBody caps the size of a callback request
+const maxCallbackBody = 1 << 20
+
+// ErrInvalidCallback is returned by ReadEvent for callbacks whose
+// signature or verification token is invalid
+var ErrInvalidCallback = errors.New("invalid feishu callback")
+
+// EventMessageRead is the event type of read receipts, which the app
+// receives when it subscribes to "message read"
+const EventMessageRead = "im.message.message_read_v1"
+
+// CardButton is a button of an interactive card whose clicks are sent to
+// the callback URL of the app
+type CardButton struct {
+	Text string `json:"text"`
+
+	// Action names the handler a click is dispatched to
+	Action string `json:"action"`
+
+	// Type is "default", "primary" or "danger"; empty is "default"
+	Type string `json:"type,omitempty"`
+
+	// Value is passed to the handler with the action
+	Value map[string]interface{} `json:"value,omitempty"`
+}
+
+// AcknowledgeButton creates a button acknowledging the alert ref names,
+// such as an incident ID
+func AcknowledgeButton(ref string) CardButton {
+	return CardButton{Text: "Acknowledge", Action: CardActionAcknowledge, Type: "primary", Value: map[string]interface{}{"ref": ref}}
+}
+
+// ResolveButton creates a button resolving the alert ref names
+func ResolveButton(ref string) CardButton {
+	return CardButton{Text: "Resolve", Action: CardActionResolve, Type: "default", Value: map[string]interface{}{"ref": ref}}
+}
+
+// AddCardButtons sends a message as an interactive card with buttons,
+// whose clicks a CallbackHandler dispatches:
+//
+//	feishu.AddCardButtons(msg, feishu.AcknowledgeButton("INC-42"), feishu.ResolveButton("INC-42"))
+func AddCardButtons(msg *message.Message, buttons ...CardButton) {
+	if len(buttons) == 0 {
+		return
+	}
+	existing, _ := platformdata.Get[[]CardButton](msg)
+	platformdata.Set(msg, append(append([]CardButton(nil), existing...), buttons...))
+}
+
+// cardButtons returns the buttons of a message
+func cardButtons(msg *message.Message) []CardButton {
+	buttons, _ := platformdata.Get[[]CardButton](msg)
+	return buttons
+}
+
+// cardActionElement returns the card element holding buttons, whose values
+// carry their action and the ID of the message
+func cardActionElement(msg *message.Message, buttons []CardButton) map[string]interface{} {
+	actions := make([]interface{}, 0, len(buttons))
+	for _, button := range buttons {
+		value := make(map[string]interface{}, len(button.Value)+2)
+		for k, v := range button.Value {
+			value[k] = v
+		}
+		value[cardValueAction] = button.Action
+		value[cardValueMessageID] = msg.ID
+
+		buttonType := button.Type
+		if buttonType == "" {
+			buttonType = "default"
+		}
+		actions = append(actions, map[string]interface{}{
+			"tag":   "button",
+			"text":  map[string]interface{}{"tag": "plain_text", "content": button.Text},
+			"type":  buttonType,
+			"value": value,
+		})
+	}
+	return map[string]interface{}{"tag": "action", "actions": actions}
+}
+
+// CardAction is a click on a card button
+type CardAction struct {
+	// Action is the action of the button, and MessageID the ID of the
+	// NotifyHub message the card was sent with
+	Action    string
+	MessageID string
+
+	// Value is the value of the button
+	Value map[string]interface{}
+
+	Tag       string // the tag of the component, such as "button"
+	Option    string // the selected option of a select menu
+	FormValue map[string]interface{}
+
+	// The user who clicked
+	OpenID    string
+	UserID    string
+	UnionID   string
+	TenantKey string
+
+	// The Feishu message and chat of the card
+	OpenMessageID string
+	OpenChatID    string
+
+	// Token updates the card later through the Open API
+	Token string
+}
+
+// CardResponse is the response to a card action
+type CardResponse struct {
+	// Toast is shown to the user who clicked
+	Toast *CardToast
+
+	// Card replaces the card, such as a *FeishuCardContent without buttons
+	Card interface{}
+}
+
+// CardToast is a toast shown to the user who clicked
+type CardToast struct {
+	Type    string `json:"type"` // "info", "success", "warning" or "error"
+	Content string `json:"content"`
+}
+
+// CardActionHandler handles card actions. A nil response leaves the card
+// as it is.
+type CardActionHandler interface {
+	HandleCardAction(ctx context.Context, action *CardAction) (*CardResponse, error)
+}
+
+// CardActionHandlerFunc adapts a function to the CardActionHandler interface
+type CardActionHandlerFunc func(ctx context.Context, action *CardAction) (*CardResponse, error)
+
+// HandleCardAction calls f(ctx, action)
+func (f CardActionHandlerFunc) HandleCardAction(ctx context.Context, action *CardAction) (*CardResponse, error) {
+	return f(ctx, action)
+}
+
+// CallbackConfig configures a CallbackHandler with the credentials of the
+// "Events & Callbacks" page of the app
+type CallbackConfig struct {
+	// VerificationToken is checked against the token of every callback
+	VerificationToken string
+
+	// EncryptKey decrypts encrypted callbacks and verifies their signature
+	EncryptKey string
+
+	// MaxSkew is how far the timestamp of a signed callback may be from the
+	// current time; zero uses DefaultCallbackMaxSkew
+	MaxSkew time.Duration
+
+	Logger logger.Logger
+}
+
+// CallbackHandler serves the card request URL of a Feishu app. It answers
+// the URL verification challenge, verifies the signature and token of card
+// action callbacks, decrypts them when an encrypt key is set, and
+// dispatches each click to the handler registered for its action:
+//
+//	callbacks, err := feishu.NewCallbackHandler(feishu.CallbackConfig{VerificationToken: token, EncryptKey: key})
+//	if err != nil {
+//		return err
+//	}
+//	callbacks.HandleFunc(feishu.CardActionAcknowledge, func(ctx context.Context, action *feishu.CardAction) (*feishu.CardResponse, error) {
+//		return &feishu.CardResponse{Toast: &feishu.CardToast{Type: "success", Content: "Acknowledged"}}, acknowledge(ctx, action.Value["ref"])
+//	})
+//	http.Handle("/feishu/card", callbacks)
+type CallbackHandler struct {
+	config CallbackConfig
+	logger logger.Logger
+	now    func() time.Time
+
+	mu       sync.RWMutex
+	handlers map[string]CardActionHandler
+	fallback CardActionHandler
+}
+
+// NewCallbackHandler creates a card callback handler
+func NewCallbackHandler(config CallbackConfig) (*CallbackHandler, error) {
+	if config.VerificationToken == "" && config.EncryptKey == "" {
+		return nil, fmt.Errorf("feishu callback verification token or encrypt key is required")
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = DefaultCallbackMaxSkew
+	}
+	log := config.Logger
+	if log == nil {
+		log = logger.Discard
+	}
+	return &CallbackHandler{
+		config:   config,
+		logger:   log,
+		now:      time.Now,
+		handlers: make(map[string]CardActionHandler),
+	}, nil
+}
+
+// Handle registers the handler of an action; the empty action registers
+// the handler of actions without their own
+func (h *CallbackHandler) Handle(action string, handler CardActionHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if action == "" {
+		h.fallback = handler
+		return
+	}
+	h.handlers[action] = handler
+}
+
+// HandleFunc registers a function handling an action
+func (h *CallbackHandler) HandleFunc(action string, f func(ctx context.Context, action *CardAction) (*CardResponse, error)) {
+	h.Handle(action, CardActionHandlerFunc(f))
+}
+
+// handler returns the handler of an action
+func (h *CallbackHandler) handler(action string) CardActionHandler {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if handler, ok := h.handlers[action]; ok {
+		return handler
+	}
+	return h.fallback
+}
+
+// callbackRequest is the decoded body of a callback: a URL verification,
+// a card.action.trigger event (schema 2.0) or a card action of the
+// original format
+type callbackRequest struct {
+	Encrypt   string `json:"encrypt"`
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+
+	Schema string `json:"schema"`
+	Header struct {
+		EventType string `json:"event_type"`
+		Token     string `json:"token"`
+		TenantKey string `json:"tenant_key"`
+	} `json:"header"`
+	Event *struct {
+		Operator struct {
+			OpenID  string `json:"open_id"`
+			UserID  string `json:"user_id"`
+			UnionID string `json:"union_id"`
+		} `json:"operator"`
+		Token   string         `json:"token"`
+		Action  callbackAction `json:"action"`
+		Context struct {
+			OpenMessageID string `json:"open_message_id"`
+			OpenChatID    string `json:"open_chat_id"`
+		} `json:"context"`
+
+		// im.message.message_read_v1
+		Reader struct {
+			ReaderID struct {
+				OpenID string `json:"open_id"`
+				UserID string `json:"user_id"`
+			} `json:"reader_id"`
+			ReadTime  string `json:"read_time"`
+			TenantKey string `json:"tenant_key"`
+		} `json:"reader"`
+		MessageIDList []string `json:"message_id_list"`
+	} `json:"event"`
+
+	// The original format
+	OpenID        string         `json:"open_id"`
+	UserID        string         `json:"user_id"`
+	TenantKey     string         `json:"tenant_key"`
+	OpenMessageID string         `json:"open_message_id"`
+	OpenChatID    string         `json:"open_chat_id"`
+	Action        callbackAction `json:"action"`
+}
+
+// callbackAction is the action of a callback
+type callbackAction struct {
+	Tag       string                 `json:"tag"`
+	Value     map[string]interface{} `json:"value"`
+	Option    string                 `json:"option"`
+	FormValue map[string]interface{} `json:"form_value"`
+}
+
+// ServeHTTP implements http.Handler
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBody))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.verifySignature(r.Header, body); err != nil {
+		h.logger.Warn("飞书卡片回调签名无效", "error", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	req, err := h.decode(body)
+	if err != nil {
+		h.logger.Warn("飞书卡片回调无法解析", "error", err)
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if !h.validToken(req) {
+		h.logger.Warn("飞书卡片回调 token 无效")
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	if req.Type == "url_verification" {
+		writeJSON(w, map[string]string{"challenge": req.Challenge})
+		return
+	}
+	action := req.cardAction()
+	if action == nil {
+		// Other events need no answer
+		writeJSON(w, struct{}{})
+		return
+	}
+
+	handler := h.handler(action.Action)
+	if handler == nil {
+		h.logger.Warn("飞书卡片动作没有处理器", "action", action.Action)
+		writeJSON(w, struct{}{})
+		return
+	}
+	resp, err := handler.HandleCardAction(r.Context(), action)
+	if err != nil {
+		h.logger.Error("飞书卡片动作处理失败", "action", action.Action, "message_id", action.MessageID, "error", err)
+		resp = &CardResponse{Toast: &CardToast{Type: "error", Content: err.Error()}}
+	}
+	writeJSON(w, req.response(resp))
+}
+
+// MessageRead is a read receipt: a user read messages the app sent
+type MessageRead struct {
+	// MessageIDs are the Feishu IDs of the messages read, the MessageID of
+	// their results
+	MessageIDs []string
+
+	OpenID    string
+	UserID    string
+	TenantKey string
+	ReadAt    time.Time
+}
+
+// ReadEvent verifies and decodes a callback of the event subscription of
+// the app, returning the read receipt it holds, nil for other events, and
+// the challenge to answer for a URL verification. It serves delivery
+// tracking, which receives the events apart from card actions.
+func (h *CallbackHandler) ReadEvent(header http.Header, body []byte) (*MessageRead, string, error) {
+	if err := h.verifySignature(header, body); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+	}
+	req, err := h.decode(body)
+	if err != nil {
+		return nil, "", err
+	}
+	if !h.validToken(req) {
+		return nil, "", fmt.Errorf("%w: invalid token", ErrInvalidCallback)
+	}
+	if req.Type == "url_verification" {
+		return nil, req.Challenge, nil
+	}
+	if req.Schema != "2.0" || req.Header.EventType != EventMessageRead || req.Event == nil {
+		return nil, "", nil
+	}
+
+	reader := req.Event.Reader
+	read := &MessageRead{
+		MessageIDs: req.Event.MessageIDList,
+		OpenID:     reader.ReaderID.OpenID,
+		UserID:     reader.ReaderID.UserID,
+		TenantKey:  reader.TenantKey,
+		ReadAt:     h.now(),
+	}
+	if millis, err := strconv.ParseInt(reader.ReadTime, 10, 64); err == nil {
+		read.ReadAt = time.UnixMilli(millis)
+	}
+	return read, "", nil
+}
+
+// verifySignature checks the signature of a signed callback: callbacks of
+// an app with an encrypt key are signed with it, others with the
+// verification token
+func (h *CallbackHandler) verifySignature(header http.Header, body []byte) error {
+	signature := header.Get("X-Lark-Signature")
+	if signature == "" {
+		if h.config.EncryptKey != "" {
+			return fmt.Errorf("missing signature")
+		}
+		return nil // checked by the verification token
+	}
+
+	timestamp := header.Get("X-Lark-Request-Timestamp")
+	nonce := header.Get("X-Lark-Request-Nonce")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if skew := h.now().Sub(time.Unix(seconds, 0)); skew > h.config.MaxSkew || skew < -h.config.MaxSkew {
+		return fmt.Errorf("timestamp is %s off", skew.Round(time.Second))
+	}
+
+	var expected string
+	if h.config.EncryptKey != "" {
+		sum := sha256.Sum256([]byte(timestamp + nonce + h.config.EncryptKey + string(body)))
+		expected = hex.EncodeToString(sum[:])
+	} else {
+		sum := sha1.Sum([]byte(timestamp + nonce + h.config.VerificationToken + string(body)))
+		expected = hex.EncodeToString(sum[:])
+	}
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// decode decodes a callback body, decrypting it when it is encrypted
+func (h *CallbackHandler) decode(body []byte) (*callbackRequest, error) {
+	var req callbackRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Encrypt == "" {
+		return &req, nil
+	}
+	if h.config.EncryptKey == "" {
+		return nil, fmt.Errorf("callback is encrypted but no encrypt key is configured")
+	}
+	plain, err := decryptCallback(req.Encrypt, h.config.EncryptKey)
+	if err != nil {
+		return nil, err
+	}
+	req = callbackRequest{}
+	if err := json.Unmarshal(plain, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// validToken checks the verification token of a callback
+func (h *CallbackHandler) validToken(req *callbackRequest) bool {
+	if h.config.VerificationToken == "" {
+		return true
+	}
+	token := req.Token
+	if req.Schema == "2.0" {
+		token = req.Header.Token
+	}
+	return hmac.Equal([]byte(token), []byte(h.config.VerificationToken))
+}
+
+// cardAction returns the card action of a callback, nil for other events
+func (req *callbackRequest) cardAction() *CardAction {
+	var (
+		action callbackAction
+		result CardAction
+	)
+	switch {
+	case req.Schema == "2.0":
+		if req.Header.EventType != "card.action.trigger" || req.Event == nil {
+			return nil
+		}
+		event := req.Event
+		action = event.Action
+		result = CardAction{
+			OpenID:        event.Operator.OpenID,
+			UserID:        event.Operator.UserID,
+			UnionID:       event.Operator.UnionID,
+			TenantKey:     req.Header.TenantKey,
+			OpenMessageID: event.Context.OpenMessageID,
+			OpenChatID:    event.Context.OpenChatID,
+			Token:         event.Token,
+		}
+	case req.Action.Tag != "":
+		action = req.Action
+		result = CardAction{
+			OpenID:        req.OpenID,
+			UserID:        req.UserID,
+			TenantKey:     req.TenantKey,
+			OpenMessageID: req.OpenMessageID,
+			OpenChatID:    req.OpenChatID,
+			Token:         req.Token,
+		}
+	default:
+		return nil
+	}
+
+	result.Tag = action.Tag
+	result.Option = action.Option
+	result.FormValue = action.FormValue
+	result.Value = action.Value
+	result.Action, _ = action.Value[cardValueAction].(string)
+	result.MessageID, _ = action.Value[cardValueMessageID].(string)
+	return &result
+}
+
+// response returns the body answering a card action in the format of the
+// callback: schema 2.0 callbacks take a toast and a card, the original
+// format only the card
+func (req *callbackRequest) response(resp *CardResponse) interface{} {
+	if resp == nil {
+		return struct{}{}
+	}
+	if req.Schema != "2.0" {
+		if resp.Card == nil {
+			return struct{}{}
+		}
+		return resp.Card
+	}
+
+	body := make(map[string]interface{})
+	if resp.Toast != nil {
+		body["toast"] = resp.Toast
+	}
+	if resp.Card != nil {
+		body["card"] = map[string]interface{}{"type": "raw", "data": resp.Card}
+	}
+	return body
+}
+
+// decryptCallback decrypts an encrypted callback: AES-256-CBC with the
+// SHA-256 of the encrypt key, the IV preceding the ciphertext
+func decryptCallback(encrypted, encryptKey string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted callback: %w", err)
+	}
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted callback has an invalid length")
+	}
+	key := sha256.Sum256([]byte(encryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(plain, data[aes.BlockSize:])
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, fmt.Errorf("failed to decrypt callback, check the encrypt key")
+	}
+	return plain[:len(plain)-padding], nil
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
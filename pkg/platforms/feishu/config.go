@@ -10,19 +10,25 @@ import (
 	"github.com/kart-io/notifyhub/pkg/config"
 )
 
+// Config is the typed configuration of the Feishu platform
+type Config = config.FeishuConfig
+
 // ValidateConfig validates the Feishu configuration
 func ValidateConfig(cfg *config.FeishuConfig) error {
 	if cfg == nil {
 		return fmt.Errorf("feishu config cannot be nil")
 	}
 
-	// Validate webhook URL
-	if cfg.WebhookURL == "" {
-		return fmt.Errorf("webhook_url is required for Feishu platform")
+	// Validate webhook URL or app credentials
+	if cfg.WebhookURL == "" && cfg.AppID == "" {
+		return fmt.Errorf("webhook_url or app_id is required for Feishu platform")
+	}
+	if cfg.AppID != "" && cfg.AppSecret == "" {
+		return fmt.Errorf("app_secret is required with app_id")
 	}
 
 	// Validate webhook URL format
-	if !strings.HasPrefix(cfg.WebhookURL, "http://") && !strings.HasPrefix(cfg.WebhookURL, "https://") {
+	if cfg.WebhookURL != "" && !strings.HasPrefix(cfg.WebhookURL, "http://") && !strings.HasPrefix(cfg.WebhookURL, "https://") {
 		return fmt.Errorf("webhook_url must start with http:// or https://")
 	}
 
@@ -71,7 +77,7 @@ func SetDefaults(cfg *config.FeishuConfig) {
 		cfg.RateLimit = 60
 	}
 
-	// No auth type needed - using webhook only
+	// No auth type needed - app credentials select the app API
 
 	// Clean up keywords: trim whitespace and remove empty ones
 	cleanKeywords := make([]string, 0, len(cfg.Keywords))
@@ -178,6 +184,9 @@ func NewConfigFromMap(configMap map[string]interface{}) (*config.FeishuConfig, e
 	cfg := &config.FeishuConfig{
 		WebhookURL: extractString(configMap, "webhook_url"),
 		Secret:     extractString(configMap, "secret"),
+		AppID:      extractString(configMap, "app_id"),
+		AppSecret:  extractString(configMap, "app_secret"),
+		BaseURL:    extractString(configMap, "base_url"),
 		MaxRetries: extractInt(configMap, "max_retries"),
 		RateLimit:  extractInt(configMap, "rate_limit"),
 		VerifySSL:  extractBool(configMap, "verify_ssl"),
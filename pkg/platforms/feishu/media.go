@@ -0,0 +1,156 @@
+// Package feishu provides media upload for the Feishu platform
+// This file uploads attachments through the Open API and builds the image
+// and file messages that reference them
+package feishu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// Upload limits of the Open API
+const (
+	MaxImageSize = 10 * 1024 * 1024 // 10MB
+	MaxFileSize  = 30 * 1024 * 1024 // 30MB
+)
+
+// fileTypes maps file extensions to the file types of the file upload API;
+// other files are uploaded as "stream"
+var fileTypes = map[string]string{
+	".opus": "opus",
+	".mp4":  "mp4",
+	".pdf":  "pdf",
+	".doc":  "doc",
+	".docx": "doc",
+	".xls":  "xls",
+	".xlsx": "xls",
+	".ppt":  "ppt",
+	".pptx": "ppt",
+}
+
+// uploadAttachments uploads the attachments of a message and returns the
+// messages sending them: images as image messages, opus audio as audio
+// messages and anything else as file messages
+func (a *appClient) uploadAttachments(ctx context.Context, attachments []message.Attachment) ([]*FeishuMessage, error) {
+	messages := make([]*FeishuMessage, 0, len(attachments))
+	for _, att := range attachments {
+		msg, err := a.uploadAttachment(ctx, att)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload attachment %s: %w", att.Name, err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// uploadAttachment uploads an attachment as an image or a file
+func (a *appClient) uploadAttachment(ctx context.Context, att message.Attachment) (*FeishuMessage, error) {
+	if strings.HasPrefix(att.MediaType(), "image/") {
+		content, err := readAttachment(att, MaxImageSize)
+		if err != nil {
+			return nil, err
+		}
+		key, err := a.uploadImage(ctx, att.Name, content)
+		if err != nil {
+			return nil, err
+		}
+		return &FeishuMessage{MsgType: "image", Content: map[string]string{"image_key": key}}, nil
+	}
+
+	content, err := readAttachment(att, MaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+	fileType := fileTypes[strings.ToLower(filepath.Ext(att.Name))]
+	if fileType == "" {
+		fileType = "stream"
+	}
+	key, err := a.uploadFile(ctx, att.Name, fileType, content)
+	if err != nil {
+		return nil, err
+	}
+	msgType := "file"
+	if fileType == "opus" {
+		msgType = "audio"
+	}
+	return &FeishuMessage{MsgType: msgType, Content: map[string]string{"file_key": key}}, nil
+}
+
+// uploadImage uploads an image to send in messages and returns its key
+func (a *appClient) uploadImage(ctx context.Context, name string, content []byte) (string, error) {
+	var resp struct {
+		ImageKey string `json:"image_key"`
+	}
+	fields := [][2]string{{"image_type", "message"}}
+	if err := a.call(ctx, "/open-apis/im/v1/images", multipartBody(fields, "image", name, content), &resp); err != nil {
+		return "", err
+	}
+	return resp.ImageKey, nil
+}
+
+// uploadFile uploads a file to send in messages and returns its key
+func (a *appClient) uploadFile(ctx context.Context, name, fileType string, content []byte) (string, error) {
+	var resp struct {
+		FileKey string `json:"file_key"`
+	}
+	fields := [][2]string{{"file_type", fileType}, {"file_name", name}}
+	if err := a.call(ctx, "/open-apis/im/v1/files", multipartBody(fields, "file", name, content), &resp); err != nil {
+		return "", err
+	}
+	return resp.FileKey, nil
+}
+
+// multipartBody returns the body of a form upload of a file, which follows
+// the name and value pairs of fields
+func multipartBody(fields [][2]string, fileField, name string, content []byte) func() (io.Reader, string, error) {
+	return func() (io.Reader, string, error) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for _, field := range fields {
+			if err := w.WriteField(field[0], field[1]); err != nil {
+				return nil, "", err
+			}
+		}
+		part, err := w.CreateFormFile(fileField, name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(content); err != nil {
+			return nil, "", err
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, w.FormDataContentType(), nil
+	}
+}
+
+// readAttachment reads the content of an attachment of at most limit bytes
+func readAttachment(att message.Attachment, limit int64) ([]byte, error) {
+	r, err := att.Reader()
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if len(content) == 0 {
+		return nil, fmt.Errorf("attachment is empty")
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("attachment exceeds the upload limit of %d bytes", limit)
+	}
+	return content, nil
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platformdata"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
 )
 
@@ -47,6 +48,22 @@ type FeishuCardContent struct {
 	Header   map[string]interface{} `json:"header,omitempty"`
 }
 
+// Card is a card sent instead of the title and body of a message:
+//
+//	platformdata.Set(msg, feishu.Card{Header: header, Elements: elements})
+type Card = FeishuCardContent
+
+// PlatformData keys of the Feishu data of messages
+const (
+	platformDataCard    = "feishu_card"
+	platformDataButtons = "feishu_card_buttons"
+)
+
+func init() {
+	platformdata.Register[Card](platformDataCard)
+	platformdata.Register[[]CardButton](platformDataButtons)
+}
+
 // NewMessageBuilder creates a new message builder
 func NewMessageBuilder(config *FeishuConfig, logger logger.Logger) *MessageBuilder {
 	return &MessageBuilder{
@@ -65,7 +82,7 @@ func (m *MessageBuilder) BuildMessage(msg *message.Message) (*FeishuMessage, err
 	}
 
 	// Check for platform-specific data first
-	if cardData, exists := msg.PlatformData["feishu_card"]; exists {
+	if cardData, exists := msg.PlatformData[platformDataCard]; exists {
 		m.logger.Debug("Using platform-specific card data")
 		return &FeishuMessage{
 			MsgType: "interactive",
@@ -95,6 +112,11 @@ func (m *MessageBuilder) BuildMessage(msg *message.Message) (*FeishuMessage, err
 
 // determineMessageType determines the optimal message type based on content
 func (m *MessageBuilder) determineMessageType(msg *message.Message) string {
+	// Buttons need a card
+	if len(cardButtons(msg)) > 0 {
+		return "interactive"
+	}
+
 	// Check metadata for format preference
 	if preferredType, exists := msg.Metadata["feishu_message_type"].(string); exists {
 		if preferredType == "text" || preferredType == "post" || preferredType == "interactive" {
@@ -174,6 +196,11 @@ func (m *MessageBuilder) buildCardContent(msg *message.Message) *FeishuCardConte
 		content.Elements = append(content.Elements, bodyElement)
 	}
 
+	// Add buttons
+	if buttons := cardButtons(msg); len(buttons) > 0 {
+		content.Elements = append(content.Elements, cardActionElement(msg, buttons))
+	}
+
 	return content
 }
 
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/kart-io/notifyhub/pkg/config"
@@ -24,6 +25,7 @@ type FeishuPlatform struct {
 	config    *FeishuConfig
 	client    *http.Client
 	auth      *AuthHandler
+	app       *appClient // nil without app credentials
 	messenger *MessageBuilder
 	logger    logger.Logger
 }
@@ -33,13 +35,19 @@ type FeishuConfig struct {
 	WebhookURL string        `json:"webhook_url"`
 	Secret     string        `json:"secret,omitempty"`
 	Keywords   []string      `json:"keywords,omitempty"`
+	AppID      string        `json:"app_id,omitempty"`
+	AppSecret  string        `json:"app_secret,omitempty"`
+	BaseURL    string        `json:"base_url,omitempty"`
 	Timeout    time.Duration `json:"timeout"`
 }
 
 // NewFeishuPlatform creates a new Feishu platform with strong-typed configuration
 func NewFeishuPlatform(feishuConfig *config.FeishuConfig, logger logger.Logger) (platform.Platform, error) {
-	if feishuConfig.WebhookURL == "" {
-		return nil, fmt.Errorf("feishu webhook URL is required")
+	if feishuConfig.WebhookURL == "" && feishuConfig.AppID == "" {
+		return nil, fmt.Errorf("feishu webhook URL is required, or app_id and app_secret")
+	}
+	if feishuConfig.AppID != "" && feishuConfig.AppSecret == "" {
+		return nil, fmt.Errorf("feishu app_secret is required with app_id")
 	}
 
 	// Convert to internal config structure
@@ -47,6 +55,9 @@ func NewFeishuPlatform(feishuConfig *config.FeishuConfig, logger logger.Logger)
 		WebhookURL: feishuConfig.WebhookURL,
 		Secret:     feishuConfig.Secret,
 		Keywords:   feishuConfig.Keywords,
+		AppID:      feishuConfig.AppID,
+		AppSecret:  feishuConfig.AppSecret,
+		BaseURL:    feishuConfig.BaseURL,
 		Timeout:    feishuConfig.Timeout,
 	}
 
@@ -56,18 +67,32 @@ func NewFeishuPlatform(feishuConfig *config.FeishuConfig, logger logger.Logger)
 	}
 
 	// Create HTTP client
-	client := &http.Client{
-		Timeout: internalConfig.Timeout,
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if err := feishuConfig.Proxy.Apply(transport); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := feishuConfig.TLS.Build()
+	if err != nil {
+		return nil, err
 	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	client := &http.Client{Transport: transport}
 
 	// Create specialized components
 	auth := NewAuthHandler(internalConfig.Secret, internalConfig.Keywords)
 	messenger := NewMessageBuilder(internalConfig, logger)
+	var app *appClient
+	if internalConfig.AppID != "" {
+		app = newAppClient(internalConfig.BaseURL, internalConfig.AppID, internalConfig.AppSecret, client)
+	}
 
 	return &FeishuPlatform{
 		config:    internalConfig,
 		client:    client,
 		auth:      auth,
+		app:       app,
 		messenger: messenger,
 		logger:    logger,
 	}, nil
@@ -80,8 +105,25 @@ func (f *FeishuPlatform) Name() string {
 
 // Send implements the Platform interface for sending messages
 func (f *FeishuPlatform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	ctx, cancel := platform.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+
 	results := make([]*platform.SendResult, len(targets))
 
+	// Attachments are uploaded once, before the first target is sent to
+	var (
+		media    []*FeishuMessage
+		mediaErr error
+		uploaded bool
+	)
+	upload := func() ([]*FeishuMessage, error) {
+		if !uploaded {
+			media, mediaErr = f.uploadAttachments(ctx, msg)
+			uploaded = true
+		}
+		return media, mediaErr
+	}
+
 	// Filter targets for Feishu
 	for i, t := range targets {
 		if !f.isFeishuTarget(t) {
@@ -94,7 +136,7 @@ func (f *FeishuPlatform) Send(ctx context.Context, msg *message.Message, targets
 		}
 
 		// Send to this target
-		err := f.sendSingleMessage(ctx, msg, t)
+		messageID, err := f.sendToTarget(ctx, msg, t, upload)
 		if err != nil {
 			results[i] = &platform.SendResult{
 				Target:  t,
@@ -102,7 +144,9 @@ func (f *FeishuPlatform) Send(ctx context.Context, msg *message.Message, targets
 				Error:   err,
 			}
 		} else {
-			messageID := msg.ID
+			if messageID == "" {
+				messageID = msg.ID
+			}
 			if messageID == "" {
 				messageID = fmt.Sprintf("feishu_%d", time.Now().UnixNano())
 			}
@@ -117,6 +161,87 @@ func (f *FeishuPlatform) Send(ctx context.Context, msg *message.Message, targets
 	return results, nil
 }
 
+// sendToTarget sends the text of a message to a target, followed by a
+// message for each of its attachments, and returns the Feishu message ID
+// of the first message sent through the app
+func (f *FeishuPlatform) sendToTarget(ctx context.Context, msg *message.Message, t target.Target, media func() ([]*FeishuMessage, error)) (string, error) {
+	if msg == nil {
+		return "", fmt.Errorf("message cannot be nil")
+	}
+	app := f.isAppTarget(t)
+
+	var messageID string
+	if msg.Title != "" || msg.Body != "" || !msg.HasAttachments() {
+		var err error
+		if app {
+			messageID, err = f.sendAppMessage(ctx, msg, t)
+		} else {
+			err = f.sendSingleMessage(ctx, msg, t)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if !msg.HasAttachments() {
+		return messageID, nil
+	}
+
+	attachments, err := media()
+	if err != nil {
+		return "", err
+	}
+	for _, attachment := range attachments {
+		if app {
+			id, err := f.app.sendMessage(ctx, receiveIDType(t.Type, t.Value), t.Value, attachment)
+			if err != nil {
+				f.logger.Error("Failed to send Feishu attachment", "type", attachment.MsgType, "error", err)
+				return "", fmt.Errorf("failed to send Feishu attachment: %w", err)
+			}
+			if messageID == "" {
+				messageID = id
+			}
+			continue
+		}
+		if err := f.sendAttachmentToWebhook(ctx, t, attachment); err != nil {
+			return "", err
+		}
+	}
+	return messageID, nil
+}
+
+// uploadAttachments uploads the attachments of a message through the Open
+// API, which bots without app credentials cannot use
+func (f *FeishuPlatform) uploadAttachments(ctx context.Context, msg *message.Message) ([]*FeishuMessage, error) {
+	if f.app == nil {
+		return nil, fmt.Errorf("feishu attachments are uploaded through the Open API, which requires app_id and app_secret")
+	}
+	media, err := f.app.uploadAttachments(ctx, msg.Attachments)
+	if err != nil {
+		f.logger.Error("Failed to upload Feishu attachments", "error", err)
+		return nil, err
+	}
+	return media, nil
+}
+
+// sendAttachmentToWebhook sends an uploaded attachment to a bot webhook,
+// which can only send images
+func (f *FeishuPlatform) sendAttachmentToWebhook(ctx context.Context, t target.Target, attachment *FeishuMessage) error {
+	if attachment.MsgType != "image" {
+		return fmt.Errorf("feishu webhook bots cannot send %s attachments, send them to a user or chat instead", attachment.MsgType)
+	}
+
+	// Each message is signed on its own
+	signed := *attachment
+	if err := f.auth.AddAuth(&signed); err != nil {
+		return fmt.Errorf("failed to add authentication: %w", err)
+	}
+	if err := f.sendToWebhook(ctx, f.webhookURLFor(t), &signed); err != nil {
+		f.logger.Error("Failed to send attachment to Feishu webhook", "error", err)
+		return fmt.Errorf("failed to send to Feishu webhook: %w", err)
+	}
+	return nil
+}
+
 // sendSingleMessage sends a message to a single feishu target
 func (f *FeishuPlatform) sendSingleMessage(ctx context.Context, msg *message.Message, target target.Target) error {
 	if msg == nil {
@@ -143,7 +268,7 @@ func (f *FeishuPlatform) sendSingleMessage(ctx context.Context, msg *message.Mes
 	}
 
 	// Send using HTTP client
-	if err := f.sendToWebhook(ctx, feishuMsg); err != nil {
+	if err := f.sendToWebhook(ctx, f.webhookURLFor(target), feishuMsg); err != nil {
 		f.logger.Error("Failed to send to Feishu webhook", "error", err)
 		return fmt.Errorf("failed to send to Feishu webhook: %w", err)
 	}
@@ -152,9 +277,33 @@ func (f *FeishuPlatform) sendSingleMessage(ctx context.Context, msg *message.Mes
 	return nil
 }
 
+// sendAppMessage sends a message to a user or chat as the configured app
+// and returns the Feishu message ID
+func (f *FeishuPlatform) sendAppMessage(ctx context.Context, msg *message.Message, target target.Target) (string, error) {
+	if msg == nil {
+		return "", fmt.Errorf("message cannot be nil")
+	}
+
+	feishuMsg, err := f.messenger.BuildMessage(msg)
+	if err != nil {
+		f.logger.Error("Failed to build Feishu message", "error", err)
+		return "", fmt.Errorf("failed to build Feishu message: %w", err)
+	}
+
+	idType := receiveIDType(target.Type, target.Value)
+	messageID, err := f.app.sendMessage(ctx, idType, target.Value, feishuMsg)
+	if err != nil {
+		f.logger.Error("Failed to send Feishu app message", "receive_id_type", idType, "error", err)
+		return "", fmt.Errorf("failed to send Feishu app message: %w", err)
+	}
+
+	f.logger.Info("Feishu app message sent successfully", "messageID", messageID, "target", target.Value)
+	return messageID, nil
+}
+
 // ValidateTarget implements the Platform interface
 func (f *FeishuPlatform) ValidateTarget(target target.Target) error {
-	if target.Type != "feishu" && target.Type != "webhook" {
+	if !f.isFeishuTarget(target) {
 		return fmt.Errorf("unsupported target type: %s", target.Type)
 	}
 	if target.Value == "" {
@@ -165,15 +314,39 @@ func (f *FeishuPlatform) ValidateTarget(target target.Target) error {
 
 // IsHealthy implements the Platform interface
 func (f *FeishuPlatform) IsHealthy(ctx context.Context) error {
-	// Simple health check - verify webhook URL is configured
-	if f.config.WebhookURL == "" {
+	// Simple health check - verify webhook URL or app is configured
+	if f.config.WebhookURL == "" && f.app == nil {
 		return fmt.Errorf("webhook URL is not configured")
 	}
 	return nil
 }
 
-// sendToWebhook sends a message to the Feishu webhook
-func (f *FeishuPlatform) sendToWebhook(ctx context.Context, msg *FeishuMessage) error {
+// Preflight implements platform.Preflighter. With app credentials a tenant
+// access token is obtained, which verifies them; a webhook bot cannot be
+// checked without posting a message.
+func (f *FeishuPlatform) Preflight(ctx context.Context) error {
+	if f.app == nil {
+		return f.IsHealthy(ctx)
+	}
+	if _, err := f.app.tenantToken(ctx); err != nil {
+		return fmt.Errorf("feishu preflight failed: %w", err)
+	}
+	return nil
+}
+
+// webhookURLFor returns the bot webhook a target is sent to: the target value
+// when it is a webhook URL (for example from a configured target alias),
+// otherwise the configured bot. Additional bots are signed with the
+// configured secret, so they must share it or not require signatures.
+func (f *FeishuPlatform) webhookURLFor(t target.Target) string {
+	if strings.HasPrefix(t.Value, "https://") || strings.HasPrefix(t.Value, "http://") {
+		return t.Value
+	}
+	return f.config.WebhookURL
+}
+
+// sendToWebhook sends a message to a Feishu bot webhook
+func (f *FeishuPlatform) sendToWebhook(ctx context.Context, webhookURL string, msg *FeishuMessage) error {
 	// Marshal message to JSON
 	data, err := json.Marshal(msg)
 	if err != nil {
@@ -181,7 +354,7 @@ func (f *FeishuPlatform) sendToWebhook(ctx context.Context, msg *FeishuMessage)
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", f.config.WebhookURL, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -216,25 +389,51 @@ func (f *FeishuPlatform) Close() error {
 
 // GetCapabilities implements the Platform interface
 func (f *FeishuPlatform) GetCapabilities() platform.Capabilities {
+	targetTypes := []string{"feishu", "webhook"}
+	if f.app != nil {
+		targetTypes = append(targetTypes, "user", "group")
+	}
 	return platform.Capabilities{
 		Name:                 "feishu",
-		SupportedTargetTypes: []string{"feishu", "webhook"},
+		SupportedTargetTypes: targetTypes,
 		SupportedFormats:     []string{"text", "markdown", "card", "rich_text"},
 		MaxMessageSize:       4000,
+		SupportsAttachments:  f.app != nil,
 	}
 }
 
 // isFeishuTarget checks if a target is relevant for Feishu
 func (f *FeishuPlatform) isFeishuTarget(target target.Target) bool {
-	return target.Type == "feishu" || target.Type == "webhook"
+	return target.Type == "feishu" || target.Type == "webhook" || f.isAppTarget(target)
+}
+
+// isAppTarget reports whether a target is sent through the app: user and
+// group targets, and feishu targets holding a user or chat ID instead of a
+// webhook URL when no webhook is configured or the ID is unambiguous
+func (f *FeishuPlatform) isAppTarget(t target.Target) bool {
+	if f.app == nil {
+		return false
+	}
+	switch t.Type {
+	case "user", "group":
+		return true
+	case "feishu":
+		if strings.HasPrefix(t.Value, "https://") || strings.HasPrefix(t.Value, "http://") {
+			return false
+		}
+		return f.config.WebhookURL == "" || receiveIDType(t.Type, t.Value) != "user_id"
+	default:
+		return false
+	}
 }
 
 // NewPlatform is the factory function for creating Feishu platforms
-// This function will be called by the platform registry
+// This function will be called by the platform registry with a Config,
+// a pointer to one, or the settings in map form
 func NewPlatform(cfg interface{}, log logger.Logger) (platform.Platform, error) {
-	feishuConfig, ok := cfg.(*config.FeishuConfig)
-	if !ok {
-		return nil, fmt.Errorf("invalid feishu configuration type")
+	feishuConfig, err := config.PlatformConfig[Config]("feishu", cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	return NewFeishuPlatform(feishuConfig, log)
@@ -1,10 +1,28 @@
 package feishu
 
 import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/platform"
 	"github.com/kart-io/notifyhub/pkg/target"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
 )
@@ -97,6 +115,32 @@ func TestNewFeishuPlatform(t *testing.T) {
 	}
 }
 
+func TestNewPlatform_Settings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings interface{}
+		wantErr  bool
+	}{
+		{"pointer", &Config{WebhookURL: "https://open.feishu.cn/hook/a"}, false},
+		{"value", Config{WebhookURL: "https://open.feishu.cn/hook/a"}, false},
+		{"map", map[string]interface{}{"webhook_url": "https://open.feishu.cn/hook/a", "timeout": "5s", "keywords": []string{"alert"}}, false},
+		{"map with unknown key", map[string]interface{}{"webhook": "https://open.feishu.cn/hook/a"}, true},
+		{"other type", "https://open.feishu.cn/hook/a", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPlatform(tt.settings, &mockLogger{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if p != nil {
+				_ = p.Close()
+			}
+		})
+	}
+}
+
 func TestFeishuPlatform_Name(t *testing.T) {
 	cfg := &config.FeishuConfig{
 		WebhookURL: "https://open.feishu.cn/open-apis/bot/v2/hook/test",
@@ -295,6 +339,326 @@ func TestFeishuPlatform_isFeishuTarget(t *testing.T) {
 	}
 }
 
+func TestFeishuPlatform_webhookURLFor(t *testing.T) {
+	cfg := &config.FeishuConfig{
+		WebhookURL: "https://open.feishu.cn/open-apis/bot/v2/hook/default",
+	}
+	p, err := NewFeishuPlatform(cfg, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewFeishuPlatform() error = %v", err)
+	}
+	feishu := p.(*FeishuPlatform)
+
+	tests := []struct {
+		name   string
+		target target.Target
+		want   string
+	}{
+		{"configured bot", target.Target{Type: "feishu", Value: "feishu"}, cfg.WebhookURL},
+		{"bot from target", target.Target{Type: "webhook", Value: "https://open.feishu.cn/open-apis/bot/v2/hook/ops"}, "https://open.feishu.cn/open-apis/bot/v2/hook/ops"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := feishu.webhookURLFor(tt.target); got != tt.want {
+				t.Errorf("webhookURLFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeishuPlatform_App(t *testing.T) {
+	tests := []struct {
+		name          string
+		webhookURL    string
+		target        target.Target
+		rejectToken   bool // the first token is reported invalid
+		apiCode       int
+		wantIDType    string
+		wantTokens    int
+		wantErr       bool
+		wantMessageID string
+	}{
+		{name: "user by open_id", target: target.NewFeishuUser("ou_abc"), wantIDType: "open_id", wantTokens: 1, wantMessageID: "om_1"},
+		{name: "user by user_id", target: target.NewFeishuUser("u123"), wantIDType: "user_id", wantTokens: 1, wantMessageID: "om_1"},
+		{name: "user by email", target: target.NewFeishuUser("alice@example.com"), wantIDType: "email", wantTokens: 1, wantMessageID: "om_1"},
+		{name: "chat", target: target.NewFeishuGroup("oc_xyz"), wantIDType: "chat_id", wantTokens: 1, wantMessageID: "om_1"},
+		{name: "feishu target with a chat ID", webhookURL: "https://open.feishu.cn/open-apis/bot/v2/hook/x", target: target.Target{Type: "feishu", Value: "oc_xyz"}, wantIDType: "chat_id", wantTokens: 1, wantMessageID: "om_1"},
+		{name: "expired token is replaced", target: target.NewFeishuUser("ou_abc"), rejectToken: true, wantIDType: "open_id", wantTokens: 2, wantMessageID: "om_1"},
+		{name: "API error", target: target.NewFeishuUser("ou_abc"), apiCode: 230001, wantIDType: "open_id", wantTokens: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				tokens  int
+				idTypes []string
+				bodies  []map[string]string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch r.URL.Path {
+				case "/open-apis/auth/v3/tenant_access_token/internal":
+					var creds map[string]string
+					_ = json.NewDecoder(r.Body).Decode(&creds)
+					if creds["app_id"] != "cli_123" || creds["app_secret"] != "secret" {
+						_, _ = io.WriteString(w, `{"code":10014,"msg":"app secret invalid"}`)
+						return
+					}
+					tokens++
+					fmt.Fprintf(w, `{"code":0,"msg":"ok","tenant_access_token":"t-%d","expire":7200}`, tokens)
+				case "/open-apis/im/v1/messages":
+					if tt.rejectToken && r.Header.Get("Authorization") == "Bearer t-1" {
+						_, _ = io.WriteString(w, `{"code":99991663,"msg":"Invalid access token"}`)
+						return
+					}
+					if r.Header.Get("Authorization") != fmt.Sprintf("Bearer t-%d", tokens) {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					var body map[string]string
+					_ = json.NewDecoder(r.Body).Decode(&body)
+					idTypes = append(idTypes, r.URL.Query().Get("receive_id_type"))
+					bodies = append(bodies, body)
+					if tt.apiCode != 0 {
+						fmt.Fprintf(w, `{"code":%d,"msg":"bot is not in the chat"}`, tt.apiCode)
+						return
+					}
+					_, _ = io.WriteString(w, `{"code":0,"msg":"success","data":{"message_id":"om_1"}}`)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			p, err := NewFeishuPlatform(&config.FeishuConfig{WebhookURL: tt.webhookURL, AppID: "cli_123", AppSecret: "secret", BaseURL: server.URL}, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewFeishuPlatform() error = %v", err)
+			}
+			if err := p.ValidateTarget(tt.target); err != nil {
+				t.Fatalf("ValidateTarget() error = %v", err)
+			}
+
+			msg := message.New()
+			msg.Title = "Deploy"
+			msg.Body = "v1.2 is live"
+			for i := 0; i < 2; i++ {
+				results, err := p.Send(context.Background(), msg, []target.Target{tt.target})
+				if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+				if results[0].Success == tt.wantErr {
+					t.Fatalf("Send() result = %+v, wantErr %v", results[0], tt.wantErr)
+				}
+				if !tt.wantErr && results[0].MessageID != tt.wantMessageID {
+					t.Errorf("MessageID = %q, want %q", results[0].MessageID, tt.wantMessageID)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tokens != tt.wantTokens {
+				t.Errorf("tenant tokens fetched = %d, want %d", tokens, tt.wantTokens)
+			}
+			if len(idTypes) != 2 || idTypes[0] != tt.wantIDType {
+				t.Fatalf("receive_id_type = %v, want %s", idTypes, tt.wantIDType)
+			}
+			if bodies[0]["receive_id"] != tt.target.Value || bodies[0]["msg_type"] != "text" || bodies[0]["content"] != `{"text":"Deploy\n\nv1.2 is live"}` {
+				t.Errorf("request body = %v", bodies[0])
+			}
+		})
+	}
+}
+
+func TestFeishuPlatform_Attachments(t *testing.T) {
+	png := message.Attachment{Name: "chart.png", Content: []byte("\x89PNG")}
+	pdf := message.Attachment{Name: "report.pdf", Content: []byte("%PDF-1.7")}
+
+	tests := []struct {
+		name        string
+		app         bool
+		webhook     bool
+		title       string
+		attachments []message.Attachment
+		targets     []string // "user", "group" or "webhook"
+		wantUploads []string
+		wantSent    []string
+		wantErr     string
+	}{
+		{
+			name: "text, image and file to a user", app: true, title: "Weekly report",
+			attachments: []message.Attachment{png, pdf},
+			targets:     []string{"user"},
+			wantUploads: []string{"image message chart.png \x89PNG", "file pdf report.pdf report.pdf %PDF-1.7"},
+			wantSent:    []string{`ou_abc text {"text":"Weekly report"}`, `ou_abc image {"image_key":"img_1"}`, `ou_abc file {"file_key":"file_2"}`},
+		},
+		{
+			name: "attachments are uploaded once", app: true,
+			attachments: []message.Attachment{png},
+			targets:     []string{"user", "group"},
+			wantUploads: []string{"image message chart.png \x89PNG"},
+			wantSent:    []string{`ou_abc image {"image_key":"img_1"}`, `oc_xyz image {"image_key":"img_1"}`},
+		},
+		{
+			name: "image to a webhook bot", app: true, webhook: true,
+			attachments: []message.Attachment{png},
+			targets:     []string{"webhook"},
+			wantUploads: []string{"image message chart.png \x89PNG"},
+			wantSent:    []string{`webhook image {"image_key":"img_1"}`},
+		},
+		{
+			name: "file to a webhook bot", app: true, webhook: true,
+			attachments: []message.Attachment{pdf},
+			targets:     []string{"webhook"},
+			wantUploads: []string{"file pdf report.pdf report.pdf %PDF-1.7"},
+			wantErr:     "cannot send file attachments",
+		},
+		{
+			name: "empty attachment", app: true,
+			attachments: []message.Attachment{{Name: "empty.txt"}},
+			targets:     []string{"user"},
+			wantErr:     "attachment is empty",
+		},
+		{
+			name: "without app credentials", webhook: true,
+			attachments: []message.Attachment{png},
+			targets:     []string{"webhook"},
+			wantErr:     "requires app_id and app_secret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				uploads []string
+				sent    []string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch r.URL.Path {
+				case "/open-apis/auth/v3/tenant_access_token/internal":
+					_, _ = io.WriteString(w, `{"code":0,"msg":"ok","tenant_access_token":"t-1","expire":7200}`)
+				case "/open-apis/im/v1/images", "/open-apis/im/v1/files":
+					if err := r.ParseMultipartForm(1 << 20); err != nil {
+						t.Errorf("ParseMultipartForm() error = %v", err)
+						return
+					}
+					field, key, prefix := "image", "image_key", "img"
+					upload := "image " + r.FormValue("image_type")
+					if r.URL.Path == "/open-apis/im/v1/files" {
+						field, key, prefix = "file", "file_key", "file"
+						upload = "file " + r.FormValue("file_type") + " " + r.FormValue("file_name")
+					}
+					file, header, err := r.FormFile(field)
+					if err != nil {
+						t.Errorf("FormFile(%s) error = %v", field, err)
+						return
+					}
+					content, _ := io.ReadAll(file)
+					uploads = append(uploads, upload+" "+header.Filename+" "+string(content))
+					fmt.Fprintf(w, `{"code":0,"msg":"success","data":{"%s":"%s_%d"}}`, key, prefix, len(uploads))
+				case "/open-apis/im/v1/messages":
+					var body map[string]string
+					_ = json.NewDecoder(r.Body).Decode(&body)
+					sent = append(sent, body["receive_id"]+" "+body["msg_type"]+" "+body["content"])
+					_, _ = io.WriteString(w, `{"code":0,"msg":"success","data":{"message_id":"om_1"}}`)
+				case "/hook":
+					var body struct {
+						MsgType string          `json:"msg_type"`
+						Content json.RawMessage `json:"content"`
+					}
+					_ = json.NewDecoder(r.Body).Decode(&body)
+					sent = append(sent, "webhook "+body.MsgType+" "+string(body.Content))
+					_, _ = io.WriteString(w, `{"code":0,"msg":"success"}`)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			cfg := &config.FeishuConfig{BaseURL: server.URL}
+			if tt.app {
+				cfg.AppID, cfg.AppSecret = "cli_123", "secret"
+			}
+			if tt.webhook {
+				cfg.WebhookURL = server.URL + "/hook"
+			}
+			p, err := NewFeishuPlatform(cfg, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewFeishuPlatform() error = %v", err)
+			}
+
+			var targets []target.Target
+			for _, name := range tt.targets {
+				switch name {
+				case "user":
+					targets = append(targets, target.NewFeishuUser("ou_abc"))
+				case "group":
+					targets = append(targets, target.NewFeishuGroup("oc_xyz"))
+				case "webhook":
+					targets = append(targets, target.Target{Type: "feishu", Value: server.URL + "/hook"})
+				}
+			}
+
+			msg := message.New()
+			msg.Title = tt.title
+			msg.AddAttachment(tt.attachments...)
+			results, err := p.Send(context.Background(), msg, targets)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			for _, result := range results {
+				if tt.wantErr == "" {
+					if !result.Success {
+						t.Errorf("Send(%s) error = %v", result.Target.Value, result.Error)
+					}
+				} else if result.Success || !strings.Contains(result.Error.Error(), tt.wantErr) {
+					t.Errorf("Send(%s) error = %v, want %q", result.Target.Value, result.Error, tt.wantErr)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(uploads, "\n") != strings.Join(tt.wantUploads, "\n") {
+				t.Errorf("uploads = %q, want %q", uploads, tt.wantUploads)
+			}
+			if strings.Join(sent, "\n") != strings.Join(tt.wantSent, "\n") {
+				t.Errorf("sent = %q, want %q", sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestFeishuPlatform_Preflight(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"code":10014,"msg":"app secret invalid"}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		config  *config.FeishuConfig
+		wantErr bool
+	}{
+		{name: "webhook", config: &config.FeishuConfig{WebhookURL: "https://open.feishu.cn/open-apis/bot/v2/hook/x"}},
+		{name: "invalid app credentials", config: &config.FeishuConfig{AppID: "cli_123", AppSecret: "wrong", BaseURL: server.URL}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewFeishuPlatform(tt.config, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewFeishuPlatform() error = %v", err)
+			}
+			err = p.(platform.Preflighter).Preflight(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Preflight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
@@ -309,3 +673,169 @@ func stringContains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestCallbackHandler(t *testing.T) {
+	const (
+		token      = "verify-token"
+		encryptKey = "encrypt-key"
+	)
+	now := time.Unix(1700000000, 0)
+
+	legacyClick := `{"open_id":"ou_abc","user_id":"u1","open_message_id":"om_1","open_chat_id":"oc_xyz","tenant_key":"tk","token":"` + token + `","action":{"tag":"button","value":{"action":"acknowledge","ref":"INC-42","notifyhub_message_id":"msg-1"}}}`
+	v2Click := `{"schema":"2.0","header":{"event_type":"card.action.trigger","token":"` + token + `","tenant_key":"tk"},"event":{"operator":{"open_id":"ou_abc","user_id":"u1"},"token":"c-1","action":{"tag":"button","value":{"action":"resolve","ref":"INC-42","notifyhub_message_id":"msg-1"}},"context":{"open_message_id":"om_1","open_chat_id":"oc_xyz"}}}`
+
+	sha1Sign := func(timestamp, body string) string {
+		sum := sha1.Sum([]byte(timestamp + "nonce" + token + body))
+		return hex.EncodeToString(sum[:])
+	}
+	sha256Sign := func(timestamp, body string) string {
+		sum := sha256.Sum256([]byte(timestamp + "nonce" + encryptKey + body))
+		return hex.EncodeToString(sum[:])
+	}
+	encrypt := func(plain string) string {
+		key := sha256.Sum256([]byte(encryptKey))
+		block, _ := aes.NewCipher(key[:])
+		padding := aes.BlockSize - len(plain)%aes.BlockSize
+		data := append([]byte(plain), bytes.Repeat([]byte{byte(padding)}, padding)...)
+		out := make([]byte, aes.BlockSize+len(data))
+		copy(out, "0123456789abcdef")
+		cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], data)
+		return `{"encrypt":"` + base64.StdEncoding.EncodeToString(out) + `"}`
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name       string
+		encryptKey string
+		body       string
+		timestamp  string
+		signature  func(timestamp, body string) string
+		handlerErr error
+		wantStatus int
+		wantAction string
+		wantBody   string
+	}{
+		{
+			name:       "URL verification",
+			body:       `{"challenge":"abc","token":"` + token + `","type":"url_verification"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"challenge":"abc"}`,
+		},
+		{
+			name:       "URL verification with a wrong token",
+			body:       `{"challenge":"abc","token":"wrong","type":"url_verification"}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "signed button click",
+			body:       legacyClick,
+			timestamp:  ts,
+			signature:  sha1Sign,
+			wantStatus: http.StatusOK,
+			wantAction: "acknowledge INC-42 msg-1 ou_abc om_1",
+			wantBody:   `{"elements":null,"header":{"template":"green"}}`,
+		},
+		{
+			name:       "wrong signature",
+			body:       legacyClick,
+			timestamp:  ts,
+			signature:  func(string, string) string { return "bad" },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "stale timestamp",
+			body:       legacyClick,
+			timestamp:  stale,
+			signature:  sha1Sign,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "encrypted card.action.trigger",
+			encryptKey: encryptKey,
+			body:       encrypt(v2Click),
+			timestamp:  ts,
+			signature:  sha256Sign,
+			wantStatus: http.StatusOK,
+			wantAction: "resolve INC-42 msg-1 ou_abc om_1",
+			wantBody:   `{"card":{"data":{"elements":null,"header":{"template":"green"}},"type":"raw"},"toast":{"type":"success","content":"done"}}`,
+		},
+		{
+			name:       "unsigned callback with an encrypt key",
+			encryptKey: encryptKey,
+			body:       encrypt(v2Click),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "handler error is shown as a toast",
+			body:       v2Click,
+			handlerErr: fmt.Errorf("incident already closed"),
+			wantStatus: http.StatusOK,
+			wantAction: "resolve INC-42 msg-1 ou_abc om_1",
+			wantBody:   `{"toast":{"type":"error","content":"incident already closed"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewCallbackHandler(CallbackConfig{VerificationToken: token, EncryptKey: tt.encryptKey})
+			if err != nil {
+				t.Fatalf("NewCallbackHandler() error = %v", err)
+			}
+			h.now = func() time.Time { return now }
+
+			var got string
+			h.HandleFunc("", func(ctx context.Context, action *CardAction) (*CardResponse, error) {
+				got = strings.Join([]string{action.Action, fmt.Sprint(action.Value["ref"]), action.MessageID, action.OpenID, action.OpenMessageID}, " ")
+				if tt.handlerErr != nil {
+					return nil, tt.handlerErr
+				}
+				return &CardResponse{
+					Toast: &CardToast{Type: "success", Content: "done"},
+					Card:  &FeishuCardContent{Header: map[string]interface{}{"template": "green"}},
+				}, nil
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/feishu/card", strings.NewReader(tt.body))
+			if tt.signature != nil {
+				req.Header.Set("X-Lark-Request-Timestamp", tt.timestamp)
+				req.Header.Set("X-Lark-Request-Nonce", "nonce")
+				req.Header.Set("X-Lark-Signature", tt.signature(tt.timestamp, tt.body))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if tt.wantBody != "" && strings.TrimSpace(rec.Body.String()) != tt.wantBody {
+				t.Errorf("body = %s, want %s", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAddCardButtons(t *testing.T) {
+	msg := message.New()
+	msg.ID = "msg-1"
+	msg.Title = "Disk full"
+	msg.Body = "db-1 is at 98%"
+	AddCardButtons(msg, AcknowledgeButton("INC-42"), ResolveButton("INC-42"))
+
+	feishuMsg, err := NewMessageBuilder(&FeishuConfig{}, &mockLogger{}).BuildMessage(msg)
+	if err != nil {
+		t.Fatalf("BuildMessage() error = %v", err)
+	}
+	if feishuMsg.MsgType != "interactive" {
+		t.Fatalf("MsgType = %q, want interactive", feishuMsg.MsgType)
+	}
+	data, _ := json.Marshal(feishuMsg.Content)
+	want := `{"actions":[` +
+		`{"tag":"button","text":{"content":"Acknowledge","tag":"plain_text"},"type":"primary","value":{"action":"acknowledge","notifyhub_message_id":"msg-1","ref":"INC-42"}},` +
+		`{"tag":"button","text":{"content":"Resolve","tag":"plain_text"},"type":"default","value":{"action":"resolve","notifyhub_message_id":"msg-1","ref":"INC-42"}}],"tag":"action"}`
+	if !strings.Contains(string(data), `"elements":[{"tag":"div"`) || !strings.Contains(string(data), want) {
+		t.Errorf("card = %s, want buttons %s", data, want)
+	}
+}
@@ -26,8 +26,15 @@ type SlackPlatform struct {
 	client    *http.Client
 	messenger *MessageBuilder
 	logger    logger.Logger
+	apiURL    string // the base URL of the Slack Web API
 }
 
+// DefaultAPIURL is the base URL of the Slack Web API
+const DefaultAPIURL = "https://slack.com/api"
+
+// Config is the typed configuration of the Slack platform
+type Config = config.SlackConfig
+
 // SlackConfig holds the configuration for Slack platform
 type SlackConfig struct {
 	WebhookURL string        `json:"webhook_url"`
@@ -77,9 +84,18 @@ func NewSlackPlatform(slackConfig *config.SlackConfig, logger logger.Logger) (pl
 	}
 
 	// Create HTTP client
-	client := &http.Client{
-		Timeout: internalConfig.Timeout,
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if err := slackConfig.Proxy.Apply(transport); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := slackConfig.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
 	}
+	client := &http.Client{Transport: transport}
 
 	// Create specialized components
 	messenger := NewMessageBuilder(internalConfig, logger)
@@ -89,6 +105,7 @@ func NewSlackPlatform(slackConfig *config.SlackConfig, logger logger.Logger) (pl
 		client:    client,
 		messenger: messenger,
 		logger:    logger,
+		apiURL:    DefaultAPIURL,
 	}, nil
 }
 
@@ -99,6 +116,9 @@ func (s *SlackPlatform) Name() string {
 
 // Send implements the Platform interface for sending messages
 func (s *SlackPlatform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	ctx, cancel := platform.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
 	results := make([]*platform.SendResult, len(targets))
 
 	// Filter targets for Slack
@@ -189,6 +209,54 @@ func (s *SlackPlatform) IsHealthy(ctx context.Context) error {
 	return nil
 }
 
+// Preflight implements platform.Preflighter. A bot token is verified with
+// the auth.test method of the Web API. A webhook is posted an empty
+// message, which Slack rejects with "no_text" without posting anything
+// when the webhook is valid, and with another error when it was revoked.
+func (s *SlackPlatform) Preflight(ctx context.Context) error {
+	ctx, cancel := platform.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	if s.config.Token != "" {
+		req, err := http.NewRequestWithContext(ctx, "POST", s.apiURL+"/auth.test", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+s.config.Token)
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("slack preflight failed: %w", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		var apiResp SlackAPIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+			return fmt.Errorf("slack preflight: status %d: failed to decode API response: %w", resp.StatusCode, err)
+		}
+		if !apiResp.OK {
+			return &APIError{Code: apiResp.Error}
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.WebhookURL, strings.NewReader("{}"))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack preflight failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusBadRequest && strings.TrimSpace(string(body)) == "no_text" {
+		return nil
+	}
+	return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(body))
+}
+
 // sendToWebhook sends a message to the Slack webhook
 func (s *SlackPlatform) sendToWebhook(ctx context.Context, msg *SlackMessage) error {
 	// Marshal message to JSON
@@ -235,7 +303,7 @@ func (s *SlackPlatform) sendToAPI(ctx context.Context, msg *SlackMessage, target
 	}
 
 	// Create HTTP request to Slack API
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://slack.com/api/chat.postMessage", bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", s.apiURL+"/chat.postMessage", bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -263,12 +331,31 @@ func (s *SlackPlatform) sendToAPI(ctx context.Context, msg *SlackMessage, target
 	}
 
 	if !apiResp.OK {
-		return fmt.Errorf("slack API error: %s", apiResp.Error)
+		return &APIError{Code: apiResp.Error}
 	}
 
 	return nil
 }
 
+// APIError is returned when the Slack API responds with an error code
+type APIError struct {
+	Code string // e.g. "channel_not_found" or "invalid_auth"
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("slack API error: %s", e.Code)
+}
+
+// AuthFailed reports whether the API rejected the bot token
+func (e *APIError) AuthFailed() bool {
+	switch e.Code {
+	case "invalid_auth", "not_authed", "token_revoked", "token_expired", "account_inactive":
+		return true
+	}
+	return false
+}
+
 // Close implements the Platform interface
 func (s *SlackPlatform) Close() error {
 	s.logger.Info("Closing Slack platform")
@@ -295,11 +382,12 @@ func (s *SlackPlatform) isSlackTarget(target target.Target) bool {
 }
 
 // NewPlatform is the factory function for creating Slack platforms
-// This function will be called by the platform registry
+// This function will be called by the platform registry with a Config,
+// a pointer to one, or the settings in map form
 func NewPlatform(cfg interface{}, log logger.Logger) (platform.Platform, error) {
-	slackConfig, ok := cfg.(*config.SlackConfig)
-	if !ok {
-		return nil, fmt.Errorf("invalid slack configuration type")
+	slackConfig, err := config.PlatformConfig[Config]("slack", cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	return NewSlackPlatform(slackConfig, log)
@@ -1,10 +1,15 @@
 package slack
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/platform"
 	"github.com/kart-io/notifyhub/pkg/target"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
 )
@@ -210,6 +215,59 @@ func TestSlackPlatform_GetCapabilities(t *testing.T) {
 	}
 }
 
+func TestSlackPlatform_Preflight(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/auth.test":
+			if r.Header.Get("Authorization") == "Bearer xoxb-valid" {
+				_, _ = w.Write([]byte(`{"ok": true}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok": false, "error": "invalid_auth"}`))
+		case "/services/valid":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("no_text"))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("invalid_token"))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		config   *config.SlackConfig
+		wantErr  string
+		wantAuth bool
+	}{
+		{name: "valid token", config: &config.SlackConfig{Token: "xoxb-valid"}},
+		{name: "revoked token", config: &config.SlackConfig{Token: "xoxb-revoked"}, wantErr: "invalid_auth", wantAuth: true},
+		{name: "valid webhook", config: &config.SlackConfig{WebhookURL: server.URL + "/services/valid"}},
+		{name: "revoked webhook", config: &config.SlackConfig{WebhookURL: server.URL + "/services/revoked"}, wantErr: "invalid_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewSlackPlatform(tt.config, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewSlackPlatform() error = %v", err)
+			}
+			slack := p.(*SlackPlatform)
+			slack.apiURL = server.URL + "/api"
+
+			err = slack.Preflight(context.Background())
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Preflight() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Preflight() error = %v, want %q", err, tt.wantErr)
+			}
+			if platform.IsAuthFailure(err) != tt.wantAuth {
+				t.Errorf("IsAuthFailure(%v) = %v, want %v", err, !tt.wantAuth, tt.wantAuth)
+			}
+		})
+	}
+}
+
 func TestSlackPlatform_Close(t *testing.T) {
 	cfg := &config.SlackConfig{
 		WebhookURL: "https://hooks.slack.com/services/TEST",
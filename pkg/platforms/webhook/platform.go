@@ -6,10 +6,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/kart-io/notifyhub/pkg/config"
@@ -21,9 +22,12 @@ import (
 
 // WebhookPlatform implements the unified Platform interface for webhook notifications
 type WebhookPlatform struct {
-	config *config.WebhookConfig
-	client *http.Client
-	logger logger.Logger
+	config        *config.WebhookConfig
+	client        *http.Client
+	responseMatch *regexp.Regexp // nil accepts any response body
+	endpoint      *endpoint
+	endpoints     map[string]*endpoint // named endpoints
+	logger        logger.Logger
 }
 
 // WebhookPayload represents the structure of webhook payload
@@ -53,6 +57,7 @@ func NewWebhookPlatform(webhookConfig *config.WebhookConfig, logger logger.Logge
 	if webhookConfig.Method == "" {
 		webhookConfig.Method = "POST"
 	}
+	webhookConfig.Method = strings.ToUpper(webhookConfig.Method)
 
 	// Set default content type if not specified
 	if webhookConfig.ContentType == "" {
@@ -73,16 +78,39 @@ func NewWebhookPlatform(webhookConfig *config.WebhookConfig, logger logger.Logge
 		IdleConnTimeout:    30 * time.Second,
 		DisableCompression: false,
 	}
+	if webhookConfig.TLS != nil {
+		tlsConfig, err := webhookConfig.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	if err := webhookConfig.Proxy.Apply(transport); err != nil {
+		return nil, err
+	}
 
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   webhookConfig.Timeout,
+	var responseMatch *regexp.Regexp
+	if webhookConfig.ResponseMatch != "" {
+		var err error
+		if responseMatch, err = regexp.Compile(webhookConfig.ResponseMatch); err != nil {
+			return nil, fmt.Errorf("invalid webhook response_match: %w", err)
+		}
 	}
 
+	def, endpoints, err := newEndpoints(webhookConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Transport: transport, CheckRedirect: checkRedirect(webhookConfig.RedirectPolicy)}
+
 	platform := &WebhookPlatform{
-		config: webhookConfig,
-		client: client,
-		logger: logger,
+		config:        webhookConfig,
+		client:        client,
+		responseMatch: responseMatch,
+		endpoint:      def,
+		endpoints:     endpoints,
+		logger:        logger,
 	}
 
 	return platform, nil
@@ -95,6 +123,9 @@ func (w *WebhookPlatform) Name() string {
 
 // Send sends a message to Webhook endpoint
 func (w *WebhookPlatform) Send(ctx context.Context, msg *message.Message, targets []target.Target) ([]*platform.SendResult, error) {
+	ctx, cancel := platform.WithTimeout(ctx, w.config.Timeout)
+	defer cancel()
+
 	results := make([]*platform.SendResult, len(targets))
 
 	for i, tgt := range targets {
@@ -111,10 +142,21 @@ func (w *WebhookPlatform) Send(ctx context.Context, msg *message.Message, target
 		}
 
 		// Build webhook payload
-		payload := w.buildWebhookPayload(msg, tgt)
+		ep := w.endpointFor(tgt)
+		body := getBuffer()
+		if err := ep.render(body, w.buildWebhookPayload(msg, tgt), msg, tgt); err != nil {
+			putBuffer(body)
+			result.Error = err
+			results[i] = result
+			continue
+		}
 
-		// Send webhook request
-		response, err := w.sendWebhookRequest(ctx, payload)
+		// Send webhook request. The transport may still read the body after
+		// the request returns, so it sends a copy and the buffer goes back
+		// to the pool.
+		payload := bytes.Clone(body.Bytes())
+		putBuffer(body)
+		response, err := w.sendWebhookRequest(ctx, ep, payload)
 		if err != nil {
 			result.Error = err
 		} else {
@@ -165,25 +207,11 @@ func (w *WebhookPlatform) IsHealthy(ctx context.Context) error {
 	if w.config.URL == "" {
 		return fmt.Errorf("webhook URL is not configured")
 	}
+	ctx, cancel := platform.WithTimeout(ctx, w.config.Timeout)
+	defer cancel()
 
 	// Create a simple HEAD request for health check
-	req, err := http.NewRequestWithContext(ctx, "HEAD", w.config.URL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create health check request: %w", err)
-	}
-
-	// Add authentication headers if configured
-	w.addAuthHeaders(req)
-
-	// Add custom headers
-	for key, value := range w.config.Headers {
-		req.Header.Set(key, value)
-	}
-
-	// Set user agent
-	req.Header.Set("User-Agent", "NotifyHub-Webhook/1.0")
-
-	resp, err := w.client.Do(req)
+	resp, err := w.probe(ctx, "HEAD", w.config.URL)
 	if err != nil {
 		return fmt.Errorf("webhook health check failed: %w", err)
 	}
@@ -201,6 +229,53 @@ func (w *WebhookPlatform) IsHealthy(ctx context.Context) error {
 	return fmt.Errorf("webhook endpoint returned status: %d", resp.StatusCode)
 }
 
+// Preflight implements platform.Preflighter. With a preflight URL, a no-op
+// or test endpoint of the receiver, it sends a GET with the credentials of
+// the webhook there, which must answer with a 2xx status; otherwise it
+// checks the webhook endpoint as IsHealthy does.
+func (w *WebhookPlatform) Preflight(ctx context.Context) error {
+	if w.config.PreflightURL == "" {
+		return w.IsHealthy(ctx)
+	}
+	ctx, cancel := platform.WithTimeout(ctx, w.config.Timeout)
+	defer cancel()
+
+	resp, err := w.probe(ctx, "GET", w.config.PreflightURL)
+	if err != nil {
+		return fmt.Errorf("webhook preflight failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook preflight endpoint returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// probe sends a request without a body, with the authentication and the
+// custom headers of the webhook
+func (w *WebhookPlatform) probe(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Add authentication headers if configured
+	w.addAuthHeaders(req)
+
+	// Add custom headers
+	for key, value := range w.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	// Set user agent
+	req.Header.Set("User-Agent", "NotifyHub-Webhook/1.0")
+
+	// Sign the empty body
+	signRequest(req.Header, nil, time.Now(), w.config.Secret, w.config.PreviousSecret)
+
+	return w.client.Do(req)
+}
+
 // Close cleans up resources
 func (w *WebhookPlatform) Close() error {
 	if w.client != nil {
@@ -229,41 +304,55 @@ func (w *WebhookPlatform) buildWebhookPayload(msg *message.Message, tgt target.T
 	return payload
 }
 
-// sendWebhookRequest sends the webhook HTTP request
-func (w *WebhookPlatform) sendWebhookRequest(ctx context.Context, payload *WebhookPayload) ([]byte, error) {
-	// Serialize payload to JSON
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+// endpointFor returns the endpoint a target is sent to: the named endpoint
+// the target value selects, the target value when it is a URL (for example
+// a templated webhook target resolved at send time), otherwise the
+// configured endpoint
+func (w *WebhookPlatform) endpointFor(t target.Target) *endpoint {
+	if ep, ok := w.endpoints[t.Value]; ok {
+		return ep
 	}
+	if strings.HasPrefix(t.Value, "https://") || strings.HasPrefix(t.Value, "http://") {
+		ep := *w.endpoint
+		ep.url = t.Value
+		return &ep
+	}
+	return w.endpoint
+}
 
+// sendWebhookRequest sends the webhook HTTP request
+func (w *WebhookPlatform) sendWebhookRequest(ctx context.Context, ep *endpoint, body []byte) ([]byte, error) {
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, w.config.Method, w.config.URL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, ep.method, ep.url, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create webhook request: %w", err)
 	}
 
 	// Set content type
-	req.Header.Set("Content-Type", w.config.ContentType)
+	req.Header.Set("Content-Type", ep.contentType)
 
 	// Add authentication headers
 	w.addAuthHeaders(req)
 
 	// Add custom headers
-	for key, value := range w.config.Headers {
+	for key, value := range ep.headers {
 		req.Header.Set(key, value)
 	}
 
 	// Set user agent
 	req.Header.Set("User-Agent", "NotifyHub-Webhook/1.0")
 
+	// Sign the payload, after the custom headers so that they cannot
+	// replace the signature
+	signRequest(req.Header, body, time.Now(), w.config.Secret, w.config.PreviousSecret)
+
 	// Log request details
-	if w.logger != nil {
+	if w.logger != nil && logger.Enabled(w.logger, logger.Debug) {
 		w.logger.Debug("Sending webhook request",
-			"url", w.config.URL,
-			"method", w.config.Method,
-			"content_type", w.config.ContentType,
-			"payload_size", len(jsonData))
+			"url", ep.url,
+			"method", ep.method,
+			"content_type", ep.contentType,
+			"payload_size", len(body))
 	}
 
 	// Send request
@@ -279,14 +368,17 @@ func (w *WebhookPlatform) sendWebhookRequest(ctx context.Context, payload *Webho
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	// Check response status
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return respBody, fmt.Errorf("webhook request failed with status %d: %s", resp.StatusCode, string(respBody))
+	// Check response status and body
+	if !w.expectedStatus(resp.StatusCode) {
+		return respBody, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	if w.responseMatch != nil && !w.responseMatch.Match(respBody) {
+		return respBody, fmt.Errorf("webhook response does not match %q: %s", w.config.ResponseMatch, respBody)
 	}
 
-	if w.logger != nil {
+	if w.logger != nil && logger.Enabled(w.logger, logger.Info) {
 		w.logger.Info("Webhook request successful",
-			"url", w.config.URL,
+			"url", ep.url,
 			"status", resp.StatusCode,
 			"response_size", len(respBody))
 	}
@@ -294,6 +386,60 @@ func (w *WebhookPlatform) sendWebhookRequest(ctx context.Context, payload *Webho
 	return respBody, nil
 }
 
+// expectedStatus reports whether a response status means success: one of
+// the expected status codes when they are configured, otherwise any 2xx
+func (w *WebhookPlatform) expectedStatus(status int) bool {
+	if len(w.config.ExpectedStatus) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, expected := range w.config.ExpectedStatus {
+		if status == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRedirect returns the redirect policy of the HTTP client: "none"
+// returns the redirect response itself, "same_host" refuses redirects to
+// other hosts, which would receive the signature and custom headers, and
+// anything else follows up to 10 redirects
+func checkRedirect(policy string) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		switch {
+		case policy == "none":
+			return http.ErrUseLastResponse
+		case policy == "same_host" && req.URL.Host != via[0].URL.Host:
+			return fmt.Errorf("redirect to another host %s refused", req.URL.Host)
+		case len(via) >= 10:
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+// StatusError is returned when a webhook endpoint responds with an unexpected
+// status, by default any but 2xx
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// TargetInvalid reports whether the endpoint no longer exists
+func (e *StatusError) TargetInvalid() bool {
+	return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+}
+
+// AuthFailed reports whether the endpoint rejected the credentials
+func (e *StatusError) AuthFailed() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
 // addAuthHeaders adds authentication headers based on configuration
 func (w *WebhookPlatform) addAuthHeaders(req *http.Request) {
 	switch w.config.AuthType {
@@ -316,11 +462,12 @@ func (w *WebhookPlatform) addAuthHeaders(req *http.Request) {
 }
 
 // NewPlatform is the factory function for creating Webhook platforms
-// This function will be called by the platform registry
+// This function will be called by the platform registry with a config.WebhookConfig,
+// a pointer to one, or the settings in map form
 func NewPlatform(cfg interface{}, log logger.Logger) (platform.Platform, error) {
-	webhookConfig, ok := cfg.(*config.WebhookConfig)
-	if !ok {
-		return nil, fmt.Errorf("invalid webhook configuration type")
+	webhookConfig, err := config.PlatformConfig[config.WebhookConfig]("webhook", cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	return NewWebhookPlatform(webhookConfig, log)
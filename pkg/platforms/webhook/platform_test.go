@@ -1,10 +1,30 @@
 package webhook
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/kart-io/notifyhub/pkg/alertmanager"
 	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
 	"github.com/kart-io/notifyhub/pkg/target"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
 )
@@ -304,6 +324,474 @@ func TestWebhookConfig_Headers(t *testing.T) {
 	}
 }
 
+func TestWebhookPlatform_Preflight(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") != "Bearer s3cret":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/ping" && r.Method == http.MethodGet:
+		case r.URL.Path == "/hook" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		token        string
+		preflightURL string
+		wantErr      string
+	}{
+		{name: "preflight endpoint", token: "s3cret", preflightURL: server.URL + "/ping"},
+		{name: "webhook endpoint without preflight endpoint", token: "s3cret"},
+		{name: "rejected credentials", token: "expired", preflightURL: server.URL + "/ping", wantErr: "status: 401"},
+		{name: "missing preflight endpoint", token: "s3cret", preflightURL: server.URL + "/missing", wantErr: "status: 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewWebhookPlatform(&config.WebhookConfig{URL: server.URL + "/hook", AuthType: "bearer", Token: tt.token, PreflightURL: tt.preflightURL}, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewWebhookPlatform() error = %v", err)
+			}
+			defer p.Close()
+
+			err = p.(*WebhookPlatform).Preflight(context.Background())
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Preflight() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Preflight() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookPlatform_Signing(t *testing.T) {
+	tests := []struct {
+		name           string
+		secret         string
+		previousSecret string
+		headers        map[string]string
+		verifyWith     []string // receiver secrets that must accept the request
+		rejectWith     []string
+	}{
+		{name: "unsigned", rejectWith: []string{"new"}},
+		{name: "signed", secret: "new", verifyWith: []string{"new"}, rejectWith: []string{"old"}},
+		{name: "rotating", secret: "new", previousSecret: "old", verifyWith: []string{"new", "old"}, rejectWith: []string{"other"}},
+		{name: "custom headers cannot replace the signature", secret: "new", headers: map[string]string{HeaderSignature: "sha256=forged"}, verifyWith: []string{"new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				header http.Header
+				body   []byte
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Clone()
+				body, _ = io.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			p, err := NewWebhookPlatform(&config.WebhookConfig{URL: server.URL, Secret: tt.secret, PreviousSecret: tt.previousSecret, Headers: tt.headers}, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewWebhookPlatform() error = %v", err)
+			}
+			defer p.Close()
+
+			msg := message.New()
+			msg.Title = "Deploy"
+			results, err := p.Send(context.Background(), msg, []target.Target{target.NewWebhook(server.URL)})
+			if err != nil || !results[0].Success {
+				t.Fatalf("Send() error = %v, result = %+v", err, results)
+			}
+
+			for _, secret := range tt.verifyWith {
+				if err := Verify(header, body, secret, 0); err != nil {
+					t.Errorf("Verify(%s) error = %v", secret, err)
+				}
+			}
+			for _, secret := range tt.rejectWith {
+				if err := Verify(header, body, secret, 0); !errors.Is(err, ErrInvalidSignature) {
+					t.Errorf("Verify(%s) error = %v, want ErrInvalidSignature", secret, err)
+				}
+			}
+			if len(tt.verifyWith) > 0 {
+				tampered := append([]byte(nil), body...)
+				tampered[0] = ' '
+				if err := Verify(header, tampered, tt.verifyWith[0], 0); err == nil {
+					t.Error("Verify() accepted a tampered body")
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookPlatform_Responses(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	}))
+	defer other.Close()
+
+	var (
+		method string
+		body   string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, body = r.Method, string(data)
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = io.WriteString(w, `{"ok":true}`)
+		case "/error":
+			_, _ = io.WriteString(w, `{"ok":false,"error":"channel_not_found"}`)
+		case "/accepted":
+			w.WriteHeader(http.StatusAccepted)
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusTemporaryRedirect)
+		case "/elsewhere":
+			http.Redirect(w, r, other.URL+"/ok", http.StatusTemporaryRedirect)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		config     config.WebhookConfig
+		wantMethod string
+		wantErr    string
+	}{
+		{name: "2xx", path: "/accepted", wantMethod: "POST"},
+		{name: "PUT", path: "/ok", config: config.WebhookConfig{Method: "put"}, wantMethod: "PUT"},
+		{name: "PATCH", path: "/ok", config: config.WebhookConfig{Method: "PATCH"}, wantMethod: "PATCH"},
+		{name: "unexpected status", path: "/accepted", config: config.WebhookConfig{ExpectedStatus: []int{200}}, wantErr: "status 202"},
+		{name: "expected status", path: "/accepted", config: config.WebhookConfig{ExpectedStatus: []int{200, 202}}},
+		{name: "matching response", path: "/ok", config: config.WebhookConfig{ResponseMatch: `"ok":\s*true`}},
+		{name: "error reported with 200", path: "/error", config: config.WebhookConfig{ResponseMatch: `"ok":\s*true`}, wantErr: "does not match"},
+		{name: "redirect followed", path: "/moved", wantMethod: "POST"},
+		{name: "redirect not followed", path: "/moved", config: config.WebhookConfig{RedirectPolicy: "none"}, wantErr: "status 307"},
+		{name: "redirect to the same host", path: "/moved", config: config.WebhookConfig{RedirectPolicy: "same_host"}, wantMethod: "POST"},
+		{name: "redirect to another host", path: "/elsewhere", config: config.WebhookConfig{RedirectPolicy: "same_host"}, wantErr: "redirect to another host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, body = "", ""
+			cfg := tt.config
+			cfg.URL = server.URL + tt.path
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			p, err := NewWebhookPlatform(&cfg, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewWebhookPlatform() error = %v", err)
+			}
+			defer p.Close()
+
+			msg := message.New()
+			msg.Title = "Deploy"
+			results, err := p.Send(context.Background(), msg, []target.Target{target.NewWebhook(cfg.URL)})
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if tt.wantErr == "" && !results[0].Success {
+				t.Fatalf("Send() error = %v", results[0].Error)
+			}
+			if tt.wantErr != "" && (results[0].Success || !strings.Contains(results[0].Error.Error(), tt.wantErr)) {
+				t.Fatalf("Send() error = %v, want %q", results[0].Error, tt.wantErr)
+			}
+			if tt.wantMethod != "" && (method != tt.wantMethod || !strings.Contains(body, `"title":"Deploy"`)) {
+				t.Errorf("request = %s %s, want %s with the payload", method, body, tt.wantMethod)
+			}
+		})
+	}
+}
+
+func TestWebhookPlatform_Templates(t *testing.T) {
+	type request struct {
+		path, method, contentType, auth, project, body string
+	}
+	var got request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		got = request{r.URL.Path, r.Method, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), r.Header.Get("X-Project"), string(data)}
+	}))
+	defer server.Close()
+
+	cfg := &config.WebhookConfig{
+		URL:      server.URL + "/hook",
+		AuthType: "bearer",
+		Token:    "token",
+		Headers:  map[string]string{"X-Project": "OPS"},
+		Template: `{"text":{{json .Title}},"to":{{json .Target.Value}}}`,
+		Endpoints: map[string]config.WebhookEndpoint{
+			"jira": {
+				URL:      server.URL + "/rest/api/2/issue",
+				Headers:  map[string]string{"X-Project": "INC"},
+				Template: `{"fields":{"summary":{{json .Title}},"description":{{json .Body}},"labels":[{{json (index .Metadata "service")}}]}}`,
+			},
+			"servicenow": {
+				URL:         server.URL + "/api/now/table/incident",
+				Method:      "put",
+				ContentType: "application/vnd.incident+json",
+			},
+			"alertmanager": {
+				URL:           server.URL + "/alerts",
+				PayloadFormat: "alertmanager",
+			},
+		},
+	}
+	p, err := NewWebhookPlatform(cfg, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewWebhookPlatform() error = %v", err)
+	}
+	defer p.Close()
+
+	msg := message.New()
+	msg.Title = `Disk "full"`
+	msg.Body = "db-1 at 98%"
+	msg.Metadata = map[string]interface{}{"service": "db"}
+
+	tests := []struct {
+		name   string
+		target target.Target
+		want   request
+	}{
+		{
+			name:   "default template",
+			target: target.NewWebhook("ops"),
+			want:   request{"/hook", "POST", "application/json", "Bearer token", "OPS", `{"text":"Disk \"full\"","to":"ops"}`},
+		},
+		{
+			name:   "endpoint template and headers",
+			target: target.NewWebhook("jira"),
+			want:   request{"/rest/api/2/issue", "POST", "application/json", "Bearer token", "INC", `{"fields":{"summary":"Disk \"full\"","description":"db-1 at 98%","labels":["db"]}}`},
+		},
+		{
+			name:   "endpoint inheriting the template",
+			target: target.NewWebhook("servicenow"),
+			want:   request{"/api/now/table/incident", "PUT", "application/vnd.incident+json", "Bearer token", "OPS", `{"text":"Disk \"full\"","to":"servicenow"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := p.Send(context.Background(), msg, []target.Target{tt.target})
+			if err != nil || !results[0].Success {
+				t.Fatalf("Send() = %v, %v", results[0].Error, err)
+			}
+			if got != tt.want {
+				t.Errorf("request = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("alertmanager payload", func(t *testing.T) {
+		results, err := p.Send(context.Background(), msg, []target.Target{target.NewWebhook("alertmanager")})
+		if err != nil || !results[0].Success {
+			t.Fatalf("Send() = %v, %v", results[0].Error, err)
+		}
+		var payload alertmanager.Payload
+		if err := json.Unmarshal([]byte(got.body), &payload); err != nil {
+			t.Fatalf("body %s: %v", got.body, err)
+		}
+		if got.path != "/alerts" || payload.Receiver != "alertmanager" || payload.Status != "firing" ||
+			len(payload.Alerts) != 1 || payload.Alerts[0].Annotations["description"] != msg.Body {
+			t.Errorf("request = %+v", got)
+		}
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := NewWebhookPlatform(&config.WebhookConfig{
+			URL:       server.URL,
+			Endpoints: map[string]config.WebhookEndpoint{"jira": {URL: server.URL, Template: `{{.Title`}},
+		}, &mockLogger{})
+		if err == nil || !strings.Contains(err.Error(), "endpoint jira: invalid webhook template") {
+			t.Errorf("NewWebhookPlatform() error = %v", err)
+		}
+	})
+
+	t.Run("template error", func(t *testing.T) {
+		p, err := NewWebhookPlatform(&config.WebhookConfig{URL: server.URL, Template: `{{.Missing}}`}, &mockLogger{})
+		if err != nil {
+			t.Fatalf("NewWebhookPlatform() error = %v", err)
+		}
+		results, _ := p.Send(context.Background(), msg, []target.Target{target.NewWebhook(server.URL)})
+		if results[0].Success || !strings.Contains(results[0].Error.Error(), "failed to render webhook template") {
+			t.Errorf("Send() error = %v", results[0].Error)
+		}
+	})
+}
+
+func TestVerify_Timestamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"title":"Deploy"}`)
+	tests := []struct {
+		name    string
+		sentAt  time.Time
+		maxAge  time.Duration
+		wantErr bool
+	}{
+		{name: "fresh", sentAt: now.Add(-time.Minute)},
+		{name: "too old", sentAt: now.Add(-10 * time.Minute), wantErr: true},
+		{name: "within a longer maximum age", sentAt: now.Add(-10 * time.Minute), maxAge: time.Hour},
+		{name: "from the future", sentAt: now.Add(10 * time.Minute), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := make(http.Header)
+			signRequest(header, body, tt.sentAt, "secret")
+			if got := header.Get(HeaderSignature); got != Sign("secret", tt.sentAt.Unix(), body) {
+				t.Errorf("%s = %q", HeaderSignature, got)
+			}
+			err := verifyAt(header, body, "secret", tt.maxAge, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyAt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookPlatform_ParallelSendsClosedEarly(t *testing.T) {
+	var corrupted sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/early" {
+			// Answer and close before the body is read
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			_, _ = io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+			time.Sleep(time.Millisecond)
+			conn.Close()
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		var payload struct {
+			Title string `json:"title"`
+			Body  string `json:"body"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil || !strings.HasPrefix(payload.Body, payload.Title) {
+			corrupted.Store(r.URL.RawQuery, string(data))
+		}
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	defer server.Close()
+
+	p, err := NewWebhookPlatform(&config.WebhookConfig{URL: server.URL}, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewWebhookPlatform() error = %v", err)
+	}
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				title := fmt.Sprintf("message-%d-%d", worker, i)
+				msg := message.New().SetTitle(title).SetBody(title + strings.Repeat(title[len(title)-1:], 60<<10))
+				path := "/read"
+				if i%2 == 0 {
+					path = "/early"
+				}
+				_, _ = p.Send(context.Background(), msg, []target.Target{target.NewWebhook(server.URL + path + "?" + title)})
+			}
+		}(worker)
+	}
+	wg.Wait()
+	corrupted.Range(func(key, value interface{}) bool {
+		t.Errorf("request %s received another payload", key)
+		return true
+	})
+}
+
+func TestWebhookPlatform_TLS(t *testing.T) {
+	clients := make(chan string, 4)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			clients <- r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MaxVersion: tls.VersionTLS12}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	clientCert, clientKey := newClientCertificate(t, "notifyhub")
+
+	tests := []struct {
+		name    string
+		tls     *config.TLSConfig
+		wantErr string
+	}{
+		{"system roots reject the test CA", nil, "certificate"},
+		{"custom CA without a client certificate", &config.TLSConfig{CA: serverCA}, "handshake failure"},
+		{"mutual TLS", &config.TLSConfig{CA: serverCA, Cert: clientCert, Key: clientKey}, ""},
+		{"minimum version above the server's", &config.TLSConfig{CA: serverCA, Cert: clientCert, Key: clientKey, MinVersion: "1.3"}, "protocol version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewWebhookPlatform(&config.WebhookConfig{URL: server.URL, VerifySSL: true, TLS: tt.tls}, &mockLogger{})
+			if err != nil {
+				t.Fatalf("NewWebhookPlatform() error = %v", err)
+			}
+			defer p.Close()
+
+			results, _ := p.Send(context.Background(), message.New().SetTitle("Alert").SetBody("disk full"), []target.Target{target.NewWebhook(server.URL)})
+			err = results[0].Error
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+				if cn := <-clients; cn != "notifyhub" {
+					t.Errorf("server saw client certificate %q", cn)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Send() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewWebhookPlatform(&config.WebhookConfig{URL: server.URL, TLS: &config.TLSConfig{CAFile: "missing.pem"}}, &mockLogger{}); err == nil {
+		t.Error("NewWebhookPlatform() with a missing CA file error = nil")
+	}
+}
+
+// newClientCertificate returns a self-signed client certificate and its
+// key, PEM encoded
+func newClientCertificate(t *testing.T, name string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
@@ -318,3 +806,24 @@ func stringContains(s, substr string) bool {
 	}
 	return false
 }
+
+func BenchmarkEndpoint_Render(b *testing.B) {
+	w, err := NewWebhookPlatform(&config.WebhookConfig{URL: "https://example.com/hook"}, logger.Discard)
+	if err != nil {
+		b.Fatal(err)
+	}
+	p := w.(*WebhookPlatform)
+	msg := message.New().SetTitle("Disk full on db-1").SetBody("The data volume of db-1 is 95% full")
+	msg.SetMetadata("service", "db")
+	tgt := target.NewWebhook("https://example.com/hook")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ep := p.endpointFor(tgt)
+		buf := getBuffer()
+		if err := ep.render(buf, p.buildWebhookPayload(msg, tgt), msg, tgt); err != nil {
+			b.Fatal(err)
+		}
+		putBuffer(buf)
+	}
+}
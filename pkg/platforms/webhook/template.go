@@ -0,0 +1,161 @@
+// Package webhook provides payload templates for NotifyHub webhooks
+// This file resolves named endpoints and renders the request bodies of
+// receivers that expect their own JSON shape, such as Jira, ServiceNow or
+// Alertmanager receivers
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/alertmanager"
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// PayloadData is what payload templates are executed with: the fields of
+// the message, such as .Title, .Body and .Metadata, the target and the
+// Unix time of the send
+type PayloadData struct {
+	*message.Message
+	Target    target.Target
+	Timestamp int64
+}
+
+// templateFuncs are the functions of payload templates: json encodes a
+// value as JSON, so that {{json .Title}} is a quoted and escaped string
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// endpoint is where and how a target is sent
+type endpoint struct {
+	url         string
+	method      string
+	contentType string
+	headers     map[string]string
+	format      string             // payload format without a template
+	receiver    string             // receiver of alertmanager notifications
+	template    *template.Template // nil sends the payload format
+}
+
+// parseTemplate parses a payload template; an empty template returns nil
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// newEndpoints returns the default endpoint of a webhook and its named
+// endpoints, which inherit the settings they leave empty
+func newEndpoints(cfg *config.WebhookConfig) (*endpoint, map[string]*endpoint, error) {
+	tmpl, err := parseTemplate("webhook", cfg.Template)
+	if err != nil {
+		return nil, nil, err
+	}
+	def := &endpoint{
+		url:         cfg.URL,
+		method:      cfg.Method,
+		contentType: cfg.ContentType,
+		headers:     cfg.Headers,
+		format:      cfg.PayloadFormat,
+		template:    tmpl,
+	}
+
+	endpoints := make(map[string]*endpoint, len(cfg.Endpoints))
+	for name, ep := range cfg.Endpoints {
+		if ep.URL == "" {
+			return nil, nil, fmt.Errorf("url is required for webhook endpoint %s", name)
+		}
+		e := *def
+		e.url = ep.URL
+		e.receiver = name
+		if ep.Method != "" {
+			e.method = strings.ToUpper(ep.Method)
+		}
+		if ep.ContentType != "" {
+			e.contentType = ep.ContentType
+		}
+		if len(ep.Headers) > 0 {
+			e.headers = make(map[string]string, len(def.headers)+len(ep.Headers))
+			for key, value := range def.headers {
+				e.headers[key] = value
+			}
+			for key, value := range ep.Headers {
+				e.headers[key] = value
+			}
+		}
+		if ep.PayloadFormat != "" {
+			e.format = ep.PayloadFormat
+			e.template = nil
+		}
+		if ep.Template != "" {
+			if e.template, err = parseTemplate(name, ep.Template); err != nil {
+				return nil, nil, fmt.Errorf("endpoint %s: %w", name, err)
+			}
+		}
+		endpoints[name] = &e
+	}
+	return def, endpoints, nil
+}
+
+// bufferPool holds the buffers request bodies are rendered into, which are
+// reused once the body is copied out, never while a request reads them
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer is the capacity above which buffers are not reused, so
+// that one large payload does not pin its memory
+const maxPooledBuffer = 64 << 10
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns a buffer to the pool
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// render writes the request body of a message for a target to buf: the
+// payload template executed over it, an Alertmanager notification or the
+// standard JSON payload
+func (e *endpoint) render(buf *bytes.Buffer, payload *WebhookPayload, msg *message.Message, tgt target.Target) error {
+	if e.template == nil {
+		var v interface{} = payload
+		if e.format == "alertmanager" {
+			v = alertmanager.FromMessage(msg, e.receiver, time.Now())
+		}
+		if err := json.NewEncoder(buf).Encode(v); err != nil {
+			return fmt.Errorf("failed to marshal webhook payload: %w", err)
+		}
+		buf.Truncate(buf.Len() - 1) // the newline Encode ends with
+		return nil
+	}
+
+	data := PayloadData{Message: msg, Target: tgt, Timestamp: time.Now().Unix()}
+	if err := e.template.Execute(buf, data); err != nil {
+		return fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Package preference provides digest collection for contacts that prefer batched delivery
+package preference
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// Digest is the set of messages collected for a contact target
+type Digest struct {
+	ContactID string             `json:"contact_id"`
+	Target    target.Target      `json:"target"`
+	Messages  []*message.Message `json:"messages"`
+	Since     time.Time          `json:"since"`
+}
+
+// DigestBuffer collects messages for contacts that prefer digest delivery.
+// The application decides when to flush it (for example hourly or daily)
+// and how to render a digest into a message.
+type DigestBuffer struct {
+	digests map[string]*Digest
+	mu      sync.Mutex
+}
+
+// NewDigestBuffer creates an empty digest buffer
+func NewDigestBuffer() *DigestBuffer {
+	return &DigestBuffer{digests: make(map[string]*Digest)}
+}
+
+// Add appends a message to the digest of a contact target
+func (b *DigestBuffer) Add(contactID string, t target.Target, msg *message.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := contactID + "\x00" + t.String()
+	d, ok := b.digests[key]
+	if !ok {
+		d = &Digest{ContactID: contactID, Target: t, Since: time.Now()}
+		b.digests[key] = d
+	}
+	d.Messages = append(d.Messages, msg)
+}
+
+// Len returns the number of pending digests
+func (b *DigestBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.digests)
+}
+
+// Flush hands every pending digest to fn and removes it from the buffer.
+// Digests for which fn fails are kept for the next flush, and the first
+// error is returned.
+func (b *DigestBuffer) Flush(ctx context.Context, fn func(ctx context.Context, d *Digest) error) error {
+	b.mu.Lock()
+	pending := b.digests
+	b.digests = make(map[string]*Digest)
+	b.mu.Unlock()
+
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var firstErr error
+	for _, key := range keys {
+		d := pending[key]
+		if err := ctx.Err(); err != nil {
+			b.requeue(key, d)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if err := fn(ctx, d); err != nil {
+			b.requeue(key, d)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// requeue puts an unsent digest back, merging with messages added since the flush began
+func (b *DigestBuffer) requeue(key string, d *Digest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if current, ok := b.digests[key]; ok {
+		d.Messages = append(d.Messages, current.Messages...)
+	}
+	b.digests[key] = d
+}
@@ -0,0 +1,202 @@
+// Package preference provides the HTTP preference center
+package preference
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// Topic is a message topic contacts can turn on or off in the preference center
+type Topic struct {
+	Name  string `json:"name"`            // topic as set on messages
+	Label string `json:"label,omitempty"` // shown to contacts, defaults to Name
+}
+
+// CenterOptions configures the preference center
+type CenterOptions struct {
+	// Channels are the channels contacts may choose from, in the order
+	// they are preferred when several are selected
+	Channels []string
+
+	// Topics are the topics contacts may turn off
+	Topics []Topic
+
+	Logger logger.Logger
+}
+
+var centerPage = template.Must(template.New("center").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Notification preferences</title></head>
+<body>
+{{if .Saved}}<p>Your preferences have been saved.</p>{{end}}
+<form method="post">
+<input type="hidden" name="token" value="{{.Token}}">
+{{if .Channels}}<fieldset><legend>Channels</legend>
+{{range .Channels}}<label><input type="checkbox" name="channel" value="{{.Name}}"{{if .Checked}} checked{{end}}> {{.Name}}</label><br>
+{{end}}</fieldset>{{end}}
+{{if .Topics}}<fieldset><legend>Notifications</legend>
+{{range .Topics}}<label><input type="checkbox" name="topic" value="{{.Name}}"{{if .Checked}} checked{{end}}> {{.Label}}</label><br>
+{{end}}</fieldset>{{end}}
+<fieldset><legend>Quiet hours</legend>
+<label>From <input type="time" name="quiet_start" value="{{.QuietStart}}"></label>
+<label>to <input type="time" name="quiet_end" value="{{.QuietEnd}}"></label>
+<label>Time zone <input type="text" name="timezone" value="{{.Timezone}}" placeholder="Asia/Shanghai"></label>
+</fieldset>
+<button type="submit">Save</button>
+</form>
+</body></html>
+`))
+
+// option is a checkbox on the preference page
+type option struct {
+	Name    string
+	Label   string
+	Checked bool
+}
+
+// pageData is the data rendered by the preference page
+type pageData struct {
+	Token      string
+	Saved      bool
+	Channels   []option
+	Topics     []option
+	QuietStart string
+	QuietEnd   string
+	Timezone   string
+}
+
+// Center serves a contact's preference page from links generated by a
+// Tokenizer. GET shows the contact's channels, topics and quiet hours; POST
+// saves them to the preference store. Fields the page does not show, such
+// as digest delivery, are preserved.
+type Center struct {
+	store  Store
+	tokens *Tokenizer
+	opts   CenterOptions
+	logger logger.Logger
+}
+
+// NewCenter creates a preference center handler
+func NewCenter(store Store, tokens *Tokenizer, opts CenterOptions) *Center {
+	log := opts.Logger
+	if log == nil {
+		log = logger.Discard
+	}
+	return &Center{store: store, tokens: tokens, opts: opts, logger: log}
+}
+
+// ServeHTTP implements http.Handler
+func (c *Center) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.FormValue("token")
+	contactID, err := c.tokens.Parse(token)
+	if err != nil {
+		http.Error(w, "invalid or expired preferences link", http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := c.store.Get(r.Context(), contactID)
+	if err != nil {
+		c.logger.Error("Failed to load preferences", "contact", contactID, "error", err)
+		http.Error(w, "failed to load preferences, please try again later", http.StatusInternalServerError)
+		return
+	}
+	if prefs == nil {
+		prefs = &Preferences{ContactID: contactID}
+	}
+
+	saved := false
+	if r.Method == http.MethodPost {
+		updated := c.apply(prefs, r)
+		if err := updated.Validate(); err != nil {
+			http.Error(w, "invalid preferences: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.store.Put(r.Context(), updated); err != nil {
+			c.logger.Error("Failed to save preferences", "contact", contactID, "error", err)
+			http.Error(w, "failed to save preferences, please try again later", http.StatusInternalServerError)
+			return
+		}
+		c.logger.Info("Preferences updated", "contact", contactID)
+		prefs, saved = updated, true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = centerPage.Execute(w, c.page(prefs, token, saved))
+}
+
+// apply returns a copy of prefs updated from the submitted form
+func (c *Center) apply(prefs *Preferences, r *http.Request) *Preferences {
+	updated := *prefs
+	updated.Channels = nil
+	selected := selection(r.PostForm["channel"])
+	for _, channel := range c.opts.Channels {
+		if selected[channel] {
+			updated.Channels = append(updated.Channels, channel)
+		}
+	}
+
+	enabled := selection(r.PostForm["topic"])
+	updated.Topics = make(map[string]Rule, len(prefs.Topics))
+	for name, rule := range prefs.Topics {
+		updated.Topics[name] = rule
+	}
+	for _, topic := range c.opts.Topics {
+		rule := updated.Topics[topic.Name]
+		rule.Muted = !enabled[topic.Name]
+		if rule.Muted || rule.Channels != nil || rule.QuietHours != nil || rule.Delivery != "" {
+			updated.Topics[topic.Name] = rule
+		} else {
+			delete(updated.Topics, topic.Name)
+		}
+	}
+
+	start := strings.TrimSpace(r.PostFormValue("quiet_start"))
+	end := strings.TrimSpace(r.PostFormValue("quiet_end"))
+	updated.QuietHours = nil
+	if start != "" || end != "" {
+		quiet := QuietHours{Start: start, End: end, Timezone: strings.TrimSpace(r.PostFormValue("timezone"))}
+		if prefs.QuietHours != nil {
+			quiet.IncludeUrgent = prefs.QuietHours.IncludeUrgent
+		}
+		updated.QuietHours = &quiet
+	}
+	return &updated
+}
+
+// page builds the page data for a contact's preferences
+func (c *Center) page(prefs *Preferences, token string, saved bool) pageData {
+	data := pageData{Token: token, Saved: saved}
+
+	selected := selection(prefs.Channels)
+	for _, channel := range c.opts.Channels {
+		data.Channels = append(data.Channels, option{Name: channel, Label: channel, Checked: selected[channel]})
+	}
+	for _, topic := range c.opts.Topics {
+		label := topic.Label
+		if label == "" {
+			label = topic.Name
+		}
+		data.Topics = append(data.Topics, option{Name: topic.Name, Label: label, Checked: !prefs.Topics[topic.Name].Muted})
+	}
+	if q := prefs.QuietHours; q != nil {
+		data.QuietStart, data.QuietEnd, data.Timezone = q.Start, q.End, q.Timezone
+	}
+	return data
+}
+
+// selection returns the set of submitted values
+func selection(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,251 @@
+// Package preference provides per-contact notification preferences for NotifyHub.
+//
+// Preferences are keyed by contact ID and control which channel a contact
+// is reached on, when they must not be disturbed (quiet hours), whether
+// messages are delivered immediately or collected into a digest, and the
+// contact's locale. Preferences can be overridden per message topic, so a
+// contact can ask for deploy notifications by email during the day and
+// nothing at night while still being paged for incidents.
+package preference
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+)
+
+// Delivery modes
+const (
+	DeliveryImmediate = "immediate"
+	DeliveryDigest    = "digest"
+)
+
+// Actions returned by Decide
+const (
+	ActionDeliver = "deliver"
+	ActionQuiet   = "quiet" // not delivered because of quiet hours
+	ActionDigest  = "digest"
+	ActionMuted   = "muted" // not delivered because the contact turned the topic off
+)
+
+// QuietHours is a daily window during which non-urgent messages are not delivered.
+// Windows may wrap midnight (e.g. 22:00-08:00).
+type QuietHours struct {
+	Start         string `json:"start"`                    // "HH:MM"
+	End           string `json:"end"`                      // "HH:MM"
+	Timezone      string `json:"timezone,omitempty"`       // IANA name, defaults to UTC
+	IncludeUrgent bool   `json:"include_urgent,omitempty"` // also silence urgent messages
+}
+
+// Validate checks the quiet hours definition
+func (q *QuietHours) Validate() error {
+	if _, err := parseClock(q.Start); err != nil {
+		return err
+	}
+	if _, err := parseClock(q.End); err != nil {
+		return err
+	}
+	if _, err := q.location(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Active reports whether the quiet hours window contains the given time
+func (q *QuietHours) Active(at time.Time) (bool, error) {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return false, err
+	}
+	loc, err := q.location()
+	if err != nil {
+		return false, err
+	}
+
+	local := at.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return now >= start && now < end, nil
+	}
+	return now >= start || now < end, nil
+}
+
+// location returns the quiet hours time zone
+func (q *QuietHours) location() (*time.Location, error) {
+	if q.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", q.Timezone, err)
+	}
+	return loc, nil
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Rule holds the routing preferences that can be overridden per topic
+type Rule struct {
+	Channels   []string    `json:"channels,omitempty"` // preferred channel order
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+	Delivery   string      `json:"delivery,omitempty"` // immediate or digest
+	Muted      bool        `json:"muted,omitempty"`    // topic turned off, urgent messages included
+}
+
+// Preferences are the notification preferences of a contact
+type Preferences struct {
+	ContactID  string          `json:"contact_id"`
+	Channels   []string        `json:"channels,omitempty"`
+	QuietHours *QuietHours     `json:"quiet_hours,omitempty"`
+	Delivery   string          `json:"delivery,omitempty"`
+	Locale     string          `json:"locale,omitempty"`
+	Topics     map[string]Rule `json:"topics,omitempty"` // topic -> override
+}
+
+// Validate checks the preferences
+func (p *Preferences) Validate() error {
+	if p.ContactID == "" {
+		return errors.New(errors.ErrValidationFailed, "preferences contact ID cannot be empty")
+	}
+
+	rules := map[string]Rule{"": p.Rule("")}
+	for topic, rule := range p.Topics {
+		rules[topic] = rule
+	}
+	for topic, rule := range rules {
+		switch rule.Delivery {
+		case "", DeliveryImmediate, DeliveryDigest:
+		default:
+			return errors.Newf(errors.ErrValidationFailed, "invalid delivery mode %q for topic %q", rule.Delivery, topic)
+		}
+		if rule.QuietHours != nil {
+			if err := rule.QuietHours.Validate(); err != nil {
+				return errors.Wrapf(err, errors.ErrValidationFailed, "invalid quiet hours for topic %q", topic)
+			}
+		}
+	}
+	return nil
+}
+
+// Rule returns the effective rule for a topic: fields set on the topic
+// override replace the contact-wide defaults
+func (p *Preferences) Rule(topic string) Rule {
+	rule := Rule{
+		Channels:   p.Channels,
+		QuietHours: p.QuietHours,
+		Delivery:   p.Delivery,
+	}
+
+	override, ok := p.Topics[topic]
+	if topic == "" || !ok {
+		return rule
+	}
+	if len(override.Channels) > 0 {
+		rule.Channels = override.Channels
+	}
+	if override.QuietHours != nil {
+		rule.QuietHours = override.QuietHours
+	}
+	if override.Delivery != "" {
+		rule.Delivery = override.Delivery
+	}
+	rule.Muted = override.Muted
+	return rule
+}
+
+// Decision is the outcome of applying preferences to a message
+type Decision struct {
+	Action   string   `json:"action"`
+	Channels []string `json:"channels,omitempty"`
+	Locale   string   `json:"locale,omitempty"`
+}
+
+// Decide applies the preferences for a topic at the given time.
+// Urgent messages bypass digests and, unless IncludeUrgent is set, quiet
+// hours; muted topics are never delivered.
+func (p *Preferences) Decide(topic string, urgent bool, at time.Time) Decision {
+	rule := p.Rule(topic)
+	decision := Decision{Action: ActionDeliver, Channels: rule.Channels, Locale: p.Locale}
+
+	if rule.Muted {
+		decision.Action = ActionMuted
+		return decision
+	}
+	if q := rule.QuietHours; q != nil && (!urgent || q.IncludeUrgent) {
+		if active, err := q.Active(at); err == nil && active {
+			decision.Action = ActionQuiet
+			return decision
+		}
+	}
+	if rule.Delivery == DeliveryDigest && !urgent {
+		decision.Action = ActionDigest
+	}
+	return decision
+}
+
+// Store persists preferences keyed by contact ID
+type Store interface {
+	// Get returns the preferences of a contact, or nil if none are stored
+	Get(ctx context.Context, contactID string) (*Preferences, error)
+
+	// Put creates or replaces the preferences of a contact
+	Put(ctx context.Context, prefs *Preferences) error
+
+	// Delete removes the preferences of a contact
+	Delete(ctx context.Context, contactID string) error
+}
+
+// MemoryStore implements Store in memory
+type MemoryStore struct {
+	prefs map[string]*Preferences
+	mu    sync.RWMutex
+}
+
+// NewMemoryStore creates a new in-memory preference store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{prefs: make(map[string]*Preferences)}
+}
+
+// Get returns the preferences of a contact
+func (s *MemoryStore) Get(ctx context.Context, contactID string) (*Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.prefs[contactID], nil
+}
+
+// Put creates or replaces the preferences of a contact
+func (s *MemoryStore) Put(ctx context.Context, prefs *Preferences) error {
+	if prefs == nil {
+		return errors.New(errors.ErrValidationFailed, "preferences cannot be nil")
+	}
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefs[prefs.ContactID] = prefs
+	return nil
+}
+
+// Delete removes the preferences of a contact
+func (s *MemoryStore) Delete(ctx context.Context, contactID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.prefs, contactID)
+	return nil
+}
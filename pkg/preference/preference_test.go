@@ -0,0 +1,308 @@
+package preference
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+func TestQuietHours_Active(t *testing.T) {
+	night := &QuietHours{Start: "22:00", End: "08:00", Timezone: "Asia/Shanghai"}
+	lunch := &QuietHours{Start: "12:00", End: "13:00"}
+
+	tests := []struct {
+		name  string
+		quiet *QuietHours
+		at    time.Time
+		want  bool
+	}{
+		{"late evening local", night, time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC), true},   // 23:00 CST
+		{"early morning local", night, time.Date(2024, 1, 1, 23, 30, 0, 0, time.UTC), true}, // 07:30 CST
+		{"daytime local", night, time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC), false},        // 10:00 CST
+		{"inside window", lunch, time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC), true},
+		{"end is exclusive", lunch, time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.quiet.Active(tt.at)
+			if err != nil {
+				t.Fatalf("Active() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryWindow_NextOpen(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	office := &DeliveryWindow{Start: "09:00", End: "18:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}}
+	overnight := &DeliveryWindow{Start: "22:00", End: "06:00"}
+
+	tests := []struct {
+		name   string
+		window *DeliveryWindow
+		loc    *time.Location
+		at     time.Time
+		want   time.Time
+	}{
+		// 2024-01-01 is a Monday
+		{"open", office, time.UTC, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{"before start", office, time.UTC, time.Date(2024, 1, 1, 7, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{"after end", office, time.UTC, time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"weekend", office, time.UTC, time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)},
+		{"recipient local time", office, shanghai, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC)},
+		{"overnight after midnight", overnight, time.UTC, time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)},
+		{"overnight closed", overnight, time.UTC, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.window.NextOpen(tt.at, tt.loc)
+			if err != nil {
+				t.Fatalf("NextOpen() error = %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextOpen() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryWindow_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  DeliveryWindow
+		wantErr bool
+	}{
+		{"valid", DeliveryWindow{Start: "09:00", End: "18:00", Days: []string{"Mon"}, Timezone: "Europe/Berlin"}, false},
+		{"bad clock", DeliveryWindow{Start: "9am", End: "18:00"}, true},
+		{"empty window", DeliveryWindow{Start: "09:00", End: "09:00"}, true},
+		{"bad day", DeliveryWindow{Start: "09:00", End: "18:00", Days: []string{"monday"}}, true},
+		{"bad timezone", DeliveryWindow{Start: "09:00", End: "18:00", Timezone: "Mars/Base"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.window.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPreferences_Decide(t *testing.T) {
+	prefs := &Preferences{
+		ContactID: "alice",
+		Channels:  []string{"feishu", "email"},
+		Locale:    "zh-CN",
+		Topics: map[string]Rule{
+			"deploys": {
+				Channels:   []string{"email"},
+				QuietHours: &QuietHours{Start: "20:00", End: "09:00"},
+			},
+			"reports":   {Delivery: DeliveryDigest},
+			"marketing": {Muted: true},
+		},
+	}
+	if err := prefs.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	day := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	night := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		topic        string
+		urgent       bool
+		at           time.Time
+		wantAction   string
+		wantChannels string
+	}{
+		{"default rule", "", false, night, ActionDeliver, "feishu"},
+		{"deploys by day", "deploys", false, day, ActionDeliver, "email"},
+		{"deploys at night", "deploys", false, night, ActionQuiet, "email"},
+		{"urgent breaks quiet hours", "deploys", true, night, ActionDeliver, "email"},
+		{"digest topic", "reports", false, day, ActionDigest, "feishu"},
+		{"urgent skips digest", "reports", true, day, ActionDeliver, "feishu"},
+		{"muted topic", "marketing", true, day, ActionMuted, "feishu"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := prefs.Decide(tt.topic, tt.urgent, tt.at)
+			if d.Action != tt.wantAction || d.Channels[0] != tt.wantChannels || d.Locale != "zh-CN" {
+				t.Errorf("Decide() = %+v, want action %v via %v", d, tt.wantAction, tt.wantChannels)
+			}
+		})
+	}
+}
+
+func TestPreferences_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefs   Preferences
+		wantErr bool
+	}{
+		{"valid", Preferences{ContactID: "alice", Delivery: DeliveryDigest}, false},
+		{"missing contact", Preferences{}, true},
+		{"bad delivery", Preferences{ContactID: "alice", Delivery: "weekly"}, true},
+		{"bad quiet hours", Preferences{ContactID: "alice", QuietHours: &QuietHours{Start: "25:00", End: "08:00"}}, true},
+		{"bad topic timezone", Preferences{ContactID: "alice", Topics: map[string]Rule{
+			"deploys": {QuietHours: &QuietHours{Start: "22:00", End: "08:00", Timezone: "Mars/Olympus"}},
+		}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.prefs.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDigestBuffer_Flush(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewDigestBuffer()
+	alice := target.NewEmail("alice@example.com")
+	bob := target.NewEmail("bob@example.com")
+
+	buffer.Add("alice", alice, message.New().SetTitle("one"))
+	buffer.Add("alice", alice, message.New().SetTitle("two"))
+	buffer.Add("bob", bob, message.New().SetTitle("three"))
+	if buffer.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", buffer.Len())
+	}
+
+	var flushed []string
+	err := buffer.Flush(ctx, func(ctx context.Context, d *Digest) error {
+		if d.ContactID == "bob" {
+			return errors.New("smtp unavailable")
+		}
+		flushed = append(flushed, d.ContactID)
+		if len(d.Messages) != 2 {
+			t.Errorf("digest for %s has %d messages, want 2", d.ContactID, len(d.Messages))
+		}
+		return nil
+	})
+	if err == nil {
+		t.Error("Flush() expected error from failed digest")
+	}
+	if len(flushed) != 1 || flushed[0] != "alice" {
+		t.Errorf("Flush() sent %v, want [alice]", flushed)
+	}
+	if buffer.Len() != 1 {
+		t.Errorf("Len() after partial flush = %d, want failed digest kept", buffer.Len())
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	tokens, err := NewTokenizer([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewTokenizer() error = %v", err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tokens.now = func() time.Time { return now }
+
+	token := tokens.Token("alice")
+	if id, err := tokens.Parse(token); err != nil || id != "alice" {
+		t.Errorf("Parse() = %v, %v, want alice", id, err)
+	}
+
+	other, _ := NewTokenizer([]byte("fedcba9876543210"), time.Hour)
+	if _, err := other.Parse(token); err == nil {
+		t.Error("Parse() expected error for token signed with another secret")
+	}
+	if _, err := tokens.Parse("garbage"); err == nil {
+		t.Error("Parse() expected error for malformed token")
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := tokens.Parse(token); err == nil {
+		t.Error("Parse() expected error for expired token")
+	}
+	if _, err := NewTokenizer([]byte("short"), 0); err == nil {
+		t.Error("NewTokenizer() expected error for short secret")
+	}
+}
+
+func TestCenter(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	_ = store.Put(ctx, &Preferences{
+		ContactID: "alice",
+		Channels:  []string{"email"},
+		Delivery:  DeliveryDigest,
+		Topics:    map[string]Rule{"reports": {Delivery: DeliveryDigest}},
+	})
+
+	tokens, _ := NewTokenizer([]byte("0123456789abcdef"), 0)
+	center := NewCenter(store, tokens, CenterOptions{
+		Channels: []string{"feishu", "email", "sms"},
+		Topics:   []Topic{{Name: "deploys", Label: "Deployments"}, {Name: "reports"}},
+	})
+
+	rec := httptest.NewRecorder()
+	center.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tokens.URL("/preferences", "alice"), nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, `value="email" checked`) || !strings.Contains(body, "Deployments") {
+		t.Errorf("GET status = %d, want preference page with current channels, got %s", rec.Code, body)
+	}
+
+	form := url.Values{
+		"token":       {tokens.Token("alice")},
+		"channel":     {"email", "feishu"},
+		"topic":       {"reports"},
+		"quiet_start": {"22:00"},
+		"quiet_end":   {"08:00"},
+		"timezone":    {"Asia/Shanghai"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/preferences", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	center.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	prefs, _ := store.Get(ctx, "alice")
+	if strings.Join(prefs.Channels, ",") != "feishu,email" {
+		t.Errorf("Channels = %v, want configured order feishu,email", prefs.Channels)
+	}
+	if !prefs.Topics["deploys"].Muted || prefs.Topics["reports"].Muted || prefs.Topics["reports"].Delivery != DeliveryDigest {
+		t.Errorf("Topics = %+v, want deploys muted and reports override kept", prefs.Topics)
+	}
+	if prefs.QuietHours == nil || prefs.QuietHours.Start != "22:00" || prefs.QuietHours.Timezone != "Asia/Shanghai" {
+		t.Errorf("QuietHours = %+v, want 22:00-08:00 Asia/Shanghai", prefs.QuietHours)
+	}
+	if prefs.Delivery != DeliveryDigest {
+		t.Errorf("Delivery = %q, want fields outside the page preserved", prefs.Delivery)
+	}
+
+	form.Set("quiet_start", "late")
+	req = httptest.NewRequest(http.MethodPost, "/preferences", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	center.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST with invalid quiet hours status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	center.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/preferences?token=bad", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET with bad token status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,91 @@
+// Package preference provides signed preference center links
+package preference
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+)
+
+// DefaultLinkTTL is how long preference center links stay valid when no
+// lifetime is configured
+const DefaultLinkTTL = 30 * 24 * time.Hour
+
+// Tokenizer generates and verifies signed, expiring preference center
+// tokens. A token encodes the contact ID and its expiry, so links can be
+// embedded in email templates without server-side state.
+type Tokenizer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewTokenizer creates a tokenizer using an HMAC secret. Tokens expire after
+// ttl; zero uses DefaultLinkTTL.
+func NewTokenizer(secret []byte, ttl time.Duration) (*Tokenizer, error) {
+	if len(secret) < 16 {
+		return nil, errors.New(errors.ErrInvalidConfig, "preference token secret must be at least 16 bytes")
+	}
+	if ttl < 0 {
+		return nil, errors.New(errors.ErrInvalidConfig, "preference token lifetime cannot be negative")
+	}
+	if ttl == 0 {
+		ttl = DefaultLinkTTL
+	}
+	return &Tokenizer{secret: secret, ttl: ttl, now: time.Now}, nil
+}
+
+// Token returns a preference center token for a contact
+func (t *Tokenizer) Token(contactID string) string {
+	expires := t.now().Add(t.ttl).Unix()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(contactID + "\n" + strconv.FormatInt(expires, 10)))
+	return payload + "." + t.sign(payload)
+}
+
+// URL returns a preference center link by appending the token to baseURL
+func (t *Tokenizer) URL(baseURL, contactID string) string {
+	sep := "?"
+	if strings.Contains(baseURL, "?") {
+		sep = "&"
+	}
+	return baseURL + sep + "token=" + url.QueryEscape(t.Token(contactID))
+}
+
+// Parse verifies a token and returns the contact ID it encodes
+func (t *Tokenizer) Parse(token string) (string, error) {
+	invalid := errors.New(errors.ErrInvalidCredentials, "invalid preference token")
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(t.sign(payload))) {
+		return "", invalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", invalid
+	}
+	contactID, expiry, ok := strings.Cut(string(raw), "\n")
+	if !ok || contactID == "" {
+		return "", invalid
+	}
+	expires, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", invalid
+	}
+	if t.now().Unix() >= expires {
+		return "", errors.New(errors.ErrInvalidCredentials, "preference token has expired")
+	}
+	return contactID, nil
+}
+
+// sign returns the encoded HMAC-SHA256 signature of a payload
+func (t *Tokenizer) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,125 @@
+// Package preference provides delivery windows for deferrable messages
+package preference
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays maps day abbreviations to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// DeliveryWindow is the local time of day during which deferrable messages
+// may be delivered. The window is evaluated in the recipient's time zone,
+// so "09:00-18:00" means office hours wherever the recipient is. Windows
+// may wrap midnight; a wrapping window belongs to the day it starts on.
+type DeliveryWindow struct {
+	Start    string   `json:"start"`              // "HH:MM"
+	End      string   `json:"end"`                // "HH:MM"
+	Days     []string `json:"days,omitempty"`     // "mon".."sun", defaults to every day
+	Timezone string   `json:"timezone,omitempty"` // used when the recipient has none, defaults to UTC
+}
+
+// Validate checks the delivery window definition
+func (w *DeliveryWindow) Validate() error {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("delivery window %s-%s is empty", w.Start, w.End)
+	}
+	if _, err := w.days(); err != nil {
+		return err
+	}
+	if _, err := w.Location(""); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Location returns the time zone the window is evaluated in: timezone when
+// set, otherwise the window default
+func (w *DeliveryWindow) Location(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		timezone = w.Timezone
+	}
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	return loc, nil
+}
+
+// NextOpen returns the earliest time at or after at when the window is open
+// in loc. It returns at unchanged when the window is already open.
+func (w *DeliveryWindow) NextOpen(at time.Time, loc *time.Location) (time.Time, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return time.Time{}, err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return time.Time{}, err
+	}
+	days, err := w.days()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := at.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	// Start at yesterday so that a wrapping window opened yesterday is found
+	for offset := -1; offset <= 7; offset++ {
+		day := midnight.AddDate(0, 0, offset)
+		if len(days) > 0 && !days[day.Weekday()] {
+			continue
+		}
+		opens := clockOn(day, start)
+		closes := clockOn(day, end)
+		if end < start {
+			closes = clockOn(day.AddDate(0, 0, 1), end)
+		}
+		if !local.Before(closes) {
+			continue
+		}
+		if !local.Before(opens) {
+			return at, nil
+		}
+		return opens, nil
+	}
+	return time.Time{}, fmt.Errorf("delivery window %s-%s never opens", w.Start, w.End)
+}
+
+// clockOn returns the time minutes after midnight on day, in day's location
+func clockOn(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, day.Location())
+}
+
+// days returns the set of weekdays the window applies to, or nil for every day
+func (w *DeliveryWindow) days() (map[time.Weekday]bool, error) {
+	if len(w.Days) == 0 {
+		return nil, nil
+	}
+	set := make(map[time.Weekday]bool, len(w.Days))
+	for _, name := range w.Days {
+		day, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q, expected mon..sun", name)
+		}
+		set[day] = true
+	}
+	return set, nil
+}
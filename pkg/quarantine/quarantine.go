@@ -0,0 +1,161 @@
+// Package quarantine stops delivery to dead targets for NotifyHub.
+//
+// The client counts hard failures per target, such as a bounced mailbox, an
+// unknown phone number or a webhook endpoint that returns 404. Once a target
+// reaches the failure threshold it is quarantined: later sends skip it with
+// the "quarantined" status instead of retrying it, until an operator
+// reinstates it. A successful delivery resets the failure count.
+package quarantine
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+)
+
+// DefaultThreshold is the number of consecutive hard failures after which
+// a target is quarantined when no threshold is configured
+const DefaultThreshold = 3
+
+// Entry is the failure record of a target
+type Entry struct {
+	Platform      string    `json:"platform"`
+	Address       string    `json:"address"`
+	Failures      int       `json:"failures"`         // consecutive hard failures
+	Reason        string    `json:"reason,omitempty"` // most recent failure
+	LastFailureAt time.Time `json:"last_failure_at"`
+	QuarantinedAt time.Time `json:"quarantined_at,omitempty"` // zero while not quarantined
+}
+
+// Quarantined reports whether the target is quarantined
+func (e Entry) Quarantined() bool {
+	return !e.QuarantinedAt.IsZero()
+}
+
+// Store persists target failure counts and quarantines
+type Store interface {
+	// RecordFailure counts a hard failure of a target and quarantines it
+	// once threshold consecutive failures were recorded. It returns the
+	// updated entry.
+	RecordFailure(ctx context.Context, platform, address, reason string, threshold int) (Entry, error)
+
+	// RecordSuccess resets the failure count of a target that is not quarantined
+	RecordSuccess(ctx context.Context, platform, address string) error
+
+	// Lookup returns the entry of a quarantined target, or nil if the
+	// target is not quarantined
+	Lookup(ctx context.Context, platform, address string) (*Entry, error)
+
+	// Reinstate lifts the quarantine of a target and resets its failure count
+	Reinstate(ctx context.Context, platform, address string) error
+
+	// List returns the quarantined targets
+	List(ctx context.Context) ([]Entry, error)
+}
+
+// MemoryStore implements Store in memory
+type MemoryStore struct {
+	entries map[string]*Entry
+	mu      sync.RWMutex
+}
+
+// NewMemoryStore creates a new in-memory quarantine store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+// RecordFailure counts a hard failure of a target
+func (s *MemoryStore) RecordFailure(ctx context.Context, platform, address, reason string, threshold int) (Entry, error) {
+	if strings.TrimSpace(address) == "" {
+		return Entry{}, errors.New(errors.ErrInvalidTarget, "quarantine address cannot be empty")
+	}
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(platform, address)
+	entry, ok := s.entries[k]
+	if !ok {
+		entry = &Entry{Platform: platform, Address: address}
+		s.entries[k] = entry
+	}
+
+	now := time.Now()
+	entry.Failures++
+	entry.Reason = reason
+	entry.LastFailureAt = now
+	if entry.Failures >= threshold && !entry.Quarantined() {
+		entry.QuarantinedAt = now
+	}
+	return *entry, nil
+}
+
+// RecordSuccess resets the failure count of a target
+func (s *MemoryStore) RecordSuccess(ctx context.Context, platform, address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(platform, address)
+	if entry, ok := s.entries[k]; ok && !entry.Quarantined() {
+		delete(s.entries, k)
+	}
+	return nil
+}
+
+// Lookup returns the entry of a quarantined target
+func (s *MemoryStore) Lookup(ctx context.Context, platform, address string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if entry, ok := s.entries[key(platform, address)]; ok && entry.Quarantined() {
+		e := *entry
+		return &e, nil
+	}
+	return nil, nil
+}
+
+// Reinstate lifts the quarantine of a target
+func (s *MemoryStore) Reinstate(ctx context.Context, platform, address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(platform, address)
+	if _, ok := s.entries[k]; !ok {
+		return errors.Newf(errors.ErrNotFound, "target %s on %s is not quarantined", address, platform)
+	}
+	delete(s.entries, k)
+	return nil
+}
+
+// List returns the quarantined targets ordered by platform and address
+func (s *MemoryStore) List(ctx context.Context) ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []Entry
+	for _, entry := range s.entries {
+		if entry.Quarantined() {
+			entries = append(entries, *entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Platform != entries[j].Platform {
+			return entries[i].Platform < entries[j].Platform
+		}
+		return entries[i].Address < entries[j].Address
+	})
+	return entries, nil
+}
+
+// key builds the map key for a platform and address. Addresses compare
+// case-insensitively, matching how the client normalizes email targets.
+func key(platform, address string) string {
+	return platform + "\x00" + strings.ToLower(strings.TrimSpace(address))
+}
@@ -0,0 +1,77 @@
+package quarantine
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	steps := []struct {
+		name            string
+		record          func() error
+		wantFailures    int
+		wantQuarantined bool
+	}{
+		{"first failure", func() error {
+			_, err := store.RecordFailure(ctx, "email", "Dead@Example.com", "550 no such user", 3)
+			return err
+		}, 1, false},
+		{"success resets", func() error {
+			return store.RecordSuccess(ctx, "email", "dead@example.com")
+		}, 0, false},
+		{"second failure", func() error {
+			_, err := store.RecordFailure(ctx, "email", "dead@example.com", "550 no such user", 3)
+			return err
+		}, 1, false},
+		{"third failure", func() error {
+			_, err := store.RecordFailure(ctx, "email", "dead@example.com", "550 no such user", 3)
+			return err
+		}, 2, false},
+		{"threshold reached", func() error {
+			_, err := store.RecordFailure(ctx, "email", "dead@example.com", "550 mailbox unavailable", 3)
+			return err
+		}, 3, true},
+		{"success does not lift quarantine", func() error {
+			return store.RecordSuccess(ctx, "email", "dead@example.com")
+		}, 3, true},
+	}
+
+	for _, step := range steps {
+		if err := step.record(); err != nil {
+			t.Fatalf("%s: error = %v", step.name, err)
+		}
+		entry, err := store.Lookup(ctx, "email", "dead@example.com")
+		if err != nil {
+			t.Fatalf("%s: Lookup() error = %v", step.name, err)
+		}
+		if (entry != nil) != step.wantQuarantined {
+			t.Fatalf("%s: Lookup() = %+v, want quarantined %v", step.name, entry, step.wantQuarantined)
+		}
+		if entry != nil && (entry.Failures != step.wantFailures || entry.Reason != "550 mailbox unavailable") {
+			t.Errorf("%s: entry = %+v, want %d failures and latest reason", step.name, entry, step.wantFailures)
+		}
+	}
+
+	if entry, _ := store.Lookup(ctx, "sms", "dead@example.com"); entry != nil {
+		t.Errorf("Lookup() on another platform = %+v, want nil", entry)
+	}
+	if entries, _ := store.List(ctx); len(entries) != 1 || entries[0].Address != "dead@example.com" {
+		t.Errorf("List() = %+v, want the quarantined address", entries)
+	}
+
+	if err := store.Reinstate(ctx, "email", "dead@example.com"); err != nil {
+		t.Fatalf("Reinstate() error = %v", err)
+	}
+	if entry, _ := store.Lookup(ctx, "email", "dead@example.com"); entry != nil {
+		t.Errorf("Lookup() after Reinstate() = %+v, want nil", entry)
+	}
+	if err := store.Reinstate(ctx, "email", "dead@example.com"); err == nil {
+		t.Error("Reinstate() expected error for target that is not quarantined")
+	}
+	if entry, _ := store.RecordFailure(ctx, "webhook", "https://example.com/hook", "404", 0); entry.Quarantined() {
+		t.Errorf("RecordFailure() with default threshold quarantined after one failure: %+v", entry)
+	}
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Quota caps the sends of a platform to respect a provider quota, such as
+// 300 Feishu messages per minute:
+//
+//	config.WithQuota(ratelimit.Quota{Platform: "feishu", Rate: 300, Per: time.Minute})
+//
+// Quotas are token buckets holding up to Burst sends that refill at Rate
+// per Per. Clients sharing the TokenBuckets of a quota, such as a
+// RedisTokenBuckets, respect it together.
+type Quota struct {
+	Platform  string        `json:"platform"`
+	Rate      int           `json:"rate"`
+	Per       time.Duration `json:"per"`
+	Burst     int           `json:"burst,omitempty"`      // zero allows Rate sends at once
+	PerTenant bool          `json:"per_tenant,omitempty"` // count the sends of each message tenant separately
+	Policy    string        `json:"policy,omitempty"`     // wait (default), drop or defer
+}
+
+// Validate checks the quota definition
+func (q Quota) Validate() error {
+	if q.Platform == "" {
+		return fmt.Errorf("quota platform is required")
+	}
+	if q.Rate <= 0 {
+		return fmt.Errorf("quota rate must be positive, got %d", q.Rate)
+	}
+	if q.Per <= 0 {
+		return fmt.Errorf("quota window must be positive, got %s", q.Per)
+	}
+	if q.Burst < 0 {
+		return fmt.Errorf("quota burst cannot be negative, got %d", q.Burst)
+	}
+	switch q.Policy {
+	case "", PolicyWait, PolicyDrop, PolicyDefer:
+		return nil
+	default:
+		return fmt.Errorf("invalid quota policy %q, expected wait, drop or defer", q.Policy)
+	}
+}
+
+// Size returns the number of sends the bucket holds
+func (q Quota) Size() int {
+	if q.Burst > 0 {
+		return q.Burst
+	}
+	return q.Rate
+}
+
+// Key returns the bucket key of the quota for a tenant, which is ignored
+// unless the quota is per tenant
+func (q Quota) Key(tenant string) string {
+	key := fmt.Sprintf("%s:%d/%s", q.Platform, q.Rate, q.Per)
+	if q.PerTenant {
+		key += ":" + tenant
+	}
+	return key
+}
+
+// TokenBuckets takes sends from token buckets
+type TokenBuckets interface {
+	// Take takes a send from the bucket of key when it holds one.
+	// Otherwise it takes nothing and returns false with the earliest time
+	// the bucket will hold a send.
+	Take(ctx context.Context, key string, quota Quota) (bool, time.Time, error)
+}
+
+// TokenRefunder is implemented by TokenBuckets that can return a send to
+// a bucket, for a send taken from several quotas and denied by one of them
+type TokenRefunder interface {
+	// Refund returns a send taken from the bucket of key
+	Refund(ctx context.Context, key string, quota Quota) error
+}
+
+// bucket is the state of a token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Duration // the time an empty bucket takes to fill up
+}
+
+// MemoryTokenBuckets implements TokenBuckets in memory, for a single client
+type MemoryTokenBuckets struct {
+	buckets map[string]*bucket
+	calls   int
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+// NewMemoryTokenBuckets creates in-memory token buckets
+func NewMemoryTokenBuckets() *MemoryTokenBuckets {
+	return &MemoryTokenBuckets{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take implements TokenBuckets
+func (m *MemoryTokenBuckets) Take(ctx context.Context, key string, quota Quota) (bool, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.calls++
+	if m.calls%sweepInterval == 0 {
+		m.sweep(now)
+	}
+
+	size := float64(quota.Size())
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: size, updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(size, b.tokens+refill(quota, now.Sub(b.updated)))
+	b.updated = now
+	b.full = refillTime(quota, size)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, now, nil
+	}
+	return false, now.Add(refillTime(quota, 1-b.tokens)), nil
+}
+
+// Refund implements TokenRefunder
+func (m *MemoryTokenBuckets) Refund(ctx context.Context, key string, quota Quota) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b, ok := m.buckets[key]; ok {
+		b.tokens = math.Min(float64(quota.Size()), b.tokens+1)
+	}
+	return nil
+}
+
+// sweep drops the buckets that were idle long enough to be full again;
+// they are recreated full when they are next used
+func (m *MemoryTokenBuckets) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.updated) >= b.full {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// refill returns the sends a bucket gains in elapsed
+func refill(quota Quota, elapsed time.Duration) float64 {
+	return float64(elapsed) * float64(quota.Rate) / float64(quota.Per)
+}
+
+// refillTime returns the time a bucket takes to gain tokens sends
+func refillTime(quota Quota, tokens float64) time.Duration {
+	return time.Duration(math.Round(tokens * float64(quota.Per) / float64(quota.Rate)))
+}
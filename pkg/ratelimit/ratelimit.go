@@ -0,0 +1,140 @@
+// Package ratelimit provides per-recipient rate limits for NotifyHub.
+//
+// Limits protect individual recipients from floods, for example at most
+// five SMS per phone number per hour:
+//
+//	config.WithTargetRateLimit(ratelimit.Limit{Platform: "sms", Max: 5, Per: time.Hour})
+//
+// Sends over a limit are dropped, waited for or deferred until the limit
+// allows them, according to the limit's policy, and are recorded on the
+// receipt.
+//
+// Quotas cap the sends of a whole platform to respect provider quotas, and
+// can be shared by a fleet of clients through RedisTokenBuckets. Usage
+// quotas cap the messages of tenants and apps per day or month.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Policies for sends over a limit or a quota
+const (
+	PolicyDrop  = "drop"  // record the send as rate limited and do not deliver it
+	PolicyDefer = "defer" // hold the send until the limit allows it
+	PolicyWait  = "wait"  // wait until the limit allows the send, or the send times out
+)
+
+// Limit caps the number of sends to a single target value within a window
+type Limit struct {
+	Platform string        `json:"platform,omitempty"` // empty applies to every platform
+	Max      int           `json:"max"`
+	Per      time.Duration `json:"per"`
+	Policy   string        `json:"policy,omitempty"` // drop (default), wait or defer
+}
+
+// Validate checks the limit definition
+func (l Limit) Validate() error {
+	if l.Max <= 0 {
+		return fmt.Errorf("rate limit max must be positive, got %d", l.Max)
+	}
+	if l.Per <= 0 {
+		return fmt.Errorf("rate limit window must be positive, got %s", l.Per)
+	}
+	switch l.Policy {
+	case "", PolicyDrop, PolicyWait, PolicyDefer:
+		return nil
+	default:
+		return fmt.Errorf("invalid rate limit policy %q, expected drop, wait or defer", l.Policy)
+	}
+}
+
+// Applies reports whether the limit applies to a platform
+func (l Limit) Applies(platform string) bool {
+	return l.Platform == "" || l.Platform == platform
+}
+
+// Key returns the counter key for a target value under this limit
+func (l Limit) Key(platform, value string) string {
+	return fmt.Sprintf("%s:%d/%s:%s", l.Platform, l.Max, l.Per, platform+":"+value)
+}
+
+// Limiter counts sends per key
+type Limiter interface {
+	// Allow records a send for key when fewer than limit.Max sends were
+	// recorded within limit.Per. Otherwise it records nothing and returns
+	// false with the earliest time a send will be allowed.
+	Allow(ctx context.Context, key string, limit Limit) (bool, time.Time, error)
+}
+
+// sendLog is the ordered send times recorded for a key within its window
+type sendLog struct {
+	sends []time.Time
+	per   time.Duration
+}
+
+// MemoryLimiter implements Limiter in memory with a sliding window log
+type MemoryLimiter struct {
+	logs  map[string]*sendLog
+	calls int
+	mu    sync.Mutex
+	now   func() time.Time
+}
+
+// sweepInterval is the number of Allow calls between sweeps of idle keys
+const sweepInterval = 1024
+
+// NewMemoryLimiter creates an in-memory limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		logs: make(map[string]*sendLog),
+		now:  time.Now,
+	}
+}
+
+// Allow implements Limiter
+func (m *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (bool, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.calls++
+	if m.calls%sweepInterval == 0 {
+		m.sweep(now)
+	}
+
+	log, ok := m.logs[key]
+	if !ok {
+		log = &sendLog{}
+		m.logs[key] = log
+	}
+	log.per = limit.Per
+	log.sends = prune(log.sends, now.Add(-limit.Per))
+
+	if len(log.sends) >= limit.Max {
+		return false, log.sends[len(log.sends)-limit.Max].Add(limit.Per), nil
+	}
+	log.sends = append(log.sends, now)
+	return true, now, nil
+}
+
+// sweep drops keys without sends in their window
+func (m *MemoryLimiter) sweep(now time.Time) {
+	for key, log := range m.logs {
+		if len(log.sends) == 0 || now.Sub(log.sends[len(log.sends)-1]) >= log.per {
+			delete(m.logs, key)
+		}
+	}
+}
+
+// prune removes sends at or before cutoff from an ordered send log
+func prune(sends []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(sends) && !sends[i].After(cutoff) {
+		i++
+	}
+	return sends[i:]
+}
@@ -0,0 +1,400 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/utils/redis"
+)
+
+func TestMemoryLimiter_Allow(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	limiter.now = func() time.Time { return now }
+
+	limit := Limit{Platform: "sms", Max: 2, Per: time.Hour}
+	key := limit.Key("sms", "+8613800138000")
+	ctx := context.Background()
+
+	steps := []struct {
+		at        time.Duration
+		allowed   bool
+		wantRetry time.Duration
+	}{
+		{0, true, 0},
+		{10 * time.Minute, true, 10 * time.Minute},
+		{20 * time.Minute, false, time.Hour},
+		{time.Hour, true, time.Hour},
+		{time.Hour + time.Minute, false, time.Hour + 10*time.Minute},
+	}
+
+	for _, step := range steps {
+		now = start.Add(step.at)
+		allowed, retryAt, err := limiter.Allow(ctx, key, limit)
+		if err != nil {
+			t.Fatalf("Allow() at %s error = %v", step.at, err)
+		}
+		if allowed != step.allowed || !retryAt.Equal(start.Add(step.wantRetry)) {
+			t.Errorf("Allow() at %s = %v, %v, want %v, %v", step.at, allowed, retryAt.Sub(start), step.allowed, step.wantRetry)
+		}
+	}
+
+	other := limit.Key("sms", "+8613800138001")
+	if allowed, _, _ := limiter.Allow(ctx, other, limit); !allowed {
+		t.Error("Allow() limits must be counted per target")
+	}
+}
+
+func TestLimit_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   Limit
+		wantErr bool
+	}{
+		{"valid", Limit{Platform: "sms", Max: 5, Per: time.Hour, Policy: PolicyDefer}, false},
+		{"wait", Limit{Platform: "sms", Max: 5, Per: time.Hour, Policy: PolicyWait}, false},
+		{"zero max", Limit{Max: 0, Per: time.Hour}, true},
+		{"zero window", Limit{Max: 5}, true},
+		{"bad policy", Limit{Max: 5, Per: time.Hour, Policy: "queue"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limit.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryTokenBuckets_Take(t *testing.T) {
+	buckets := NewMemoryTokenBuckets()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	buckets.now = func() time.Time { return now }
+
+	quota := Quota{Platform: "feishu", Rate: 2, Per: time.Minute, Burst: 3}
+	ctx := context.Background()
+
+	steps := []struct {
+		at        time.Duration
+		allowed   bool
+		wantRetry time.Duration
+	}{
+		{0, true, 0},
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, 30 * time.Second},
+		{30 * time.Second, true, 30 * time.Second},
+		{40 * time.Second, false, time.Minute},
+		{10 * time.Minute, true, 10 * time.Minute},
+	}
+	for i, step := range steps {
+		now = start.Add(step.at)
+		allowed, retryAt, err := buckets.Take(ctx, quota.Key(""), quota)
+		if err != nil {
+			t.Fatalf("step %d: Take() error = %v", i, err)
+		}
+		if allowed != step.allowed || !retryAt.Equal(start.Add(step.wantRetry)) {
+			t.Errorf("step %d: Take() at %s = %v, %v, want %v, %v", i, step.at, allowed, retryAt.Sub(start), step.allowed, step.wantRetry)
+		}
+	}
+
+	// A refunded send is available again, up to the size of the bucket
+	if err := buckets.Refund(ctx, quota.Key(""), quota); err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	for i, want := range []bool{true, true, true, false} {
+		if allowed, _, _ := buckets.Take(ctx, quota.Key(""), quota); allowed != want {
+			t.Errorf("Take() %d after Refund() = %v, want %v", i, allowed, want)
+		}
+	}
+
+	tenants := Quota{Platform: "feishu", Rate: 1, Per: time.Hour, PerTenant: true}
+	if tenants.Key("acme") == tenants.Key("globex") || quota.Key("acme") != quota.Key("globex") {
+		t.Error("Key() must separate tenants only for per-tenant quotas")
+	}
+	for _, tenant := range []string{"acme", "globex"} {
+		if allowed, _, _ := buckets.Take(ctx, tenants.Key(tenant), tenants); !allowed {
+			t.Errorf("Take() for tenant %s was not allowed", tenant)
+		}
+	}
+}
+
+func TestSaveState(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+	ctx := context.Background()
+	quota := Quota{Platform: "email", Rate: 2, Per: time.Minute}
+	limit := Limit{Platform: "sms", Max: 1, Per: time.Hour}
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+
+	buckets, limiter := NewMemoryTokenBuckets(), NewMemoryLimiter()
+	buckets.now, limiter.now = clock, clock
+	for i := 0; i < 2; i++ {
+		if taken, _, _ := buckets.Take(ctx, quota.Key(""), quota); !taken {
+			t.Fatalf("Take() %d = false, want true", i)
+		}
+	}
+	if allowed, _, _ := limiter.Allow(ctx, limit.Key("sms", "+8613800138000"), limit); !allowed {
+		t.Fatal("Allow() = false, want true")
+	}
+	// Idle keys are not saved
+	if allowed, _, _ := limiter.Allow(ctx, "stale", Limit{Max: 1, Per: time.Second}); !allowed {
+		t.Fatal("Allow() stale = false, want true")
+	}
+	now = start.Add(10 * time.Second)
+	if err := SaveState(path, buckets, limiter); err != nil {
+		t.Fatalf("SaveState() error = %v", err)
+	}
+
+	// A restarted client keeps the budgets spent before the restart
+	restored, restoredLimiter := NewMemoryTokenBuckets(), NewMemoryLimiter()
+	restored.now, restoredLimiter.now = clock, clock
+	if err := LoadState(path, restored, restoredLimiter); err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if taken, retryAt, _ := restored.Take(ctx, quota.Key(""), quota); taken || !retryAt.Equal(start.Add(30*time.Second)) {
+		t.Errorf("Take() after restore = %v, %v, want false until 30s", taken, retryAt.Sub(start))
+	}
+	if allowed, _, _ := restoredLimiter.Allow(ctx, limit.Key("sms", "+8613800138000"), limit); allowed {
+		t.Error("Allow() after restore = true, want the limit kept")
+	}
+	if n := len(restoredLimiter.logs); n != 1 {
+		t.Errorf("restored %d limiter keys, want 1", n)
+	}
+
+	// The buckets refill for the time the client was down
+	now = start.Add(time.Minute)
+	if taken, _, _ := restored.Take(ctx, quota.Key(""), quota); !taken {
+		t.Error("Take() after refill = false, want true")
+	}
+
+	if err := LoadState(filepath.Join(t.TempDir(), "missing.json"), restored, nil); err != nil {
+		t.Errorf("LoadState() missing file error = %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"version": 9}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadState(path, restored, nil); err == nil || !strings.Contains(err.Error(), "unsupported version 9") {
+		t.Errorf("LoadState() error = %v, want unsupported version", err)
+	}
+}
+
+func TestQuota_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   Quota
+		wantErr bool
+	}{
+		{"valid", Quota{Platform: "feishu", Rate: 300, Per: time.Minute, Policy: PolicyDrop}, false},
+		{"no platform", Quota{Rate: 300, Per: time.Minute}, true},
+		{"zero rate", Quota{Platform: "feishu", Per: time.Minute}, true},
+		{"zero window", Quota{Platform: "feishu", Rate: 300}, true},
+		{"negative burst", Quota{Platform: "feishu", Rate: 300, Per: time.Minute, Burst: -1}, true},
+		{"defer", Quota{Platform: "feishu", Rate: 300, Per: time.Minute, Policy: PolicyDefer}, false},
+		{"bad policy", Quota{Platform: "feishu", Rate: 300, Per: time.Minute, Policy: "queue"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.quota.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUsageQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   UsageQuota
+		wantErr bool
+	}{
+		{"valid", UsageQuota{Tenant: AnySubject, Platform: "sms", Max: 500, Period: PeriodMonth, SoftLimit: 400}, false},
+		{"zero max", UsageQuota{Period: PeriodDay}, true},
+		{"bad period", UsageQuota{Max: 10, Period: "week"}, true},
+		{"soft limit over max", UsageQuota{Max: 10, Period: PeriodDay, SoftLimit: 11}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.quota.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	now := time.Date(2024, 2, 29, 18, 30, 0, 0, time.FixedZone("UTC+8", 8*3600))
+	day, month := UsageQuota{Max: 1, Period: PeriodDay}, UsageQuota{Max: 1, Period: PeriodMonth}
+	if start, end := day.Window(now); !start.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) || end.Sub(start) != 24*time.Hour {
+		t.Errorf("Window() of a day = %s, %s", start, end)
+	}
+	if start, end := month.Window(now); !start.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Window() of a month = %s, %s", start, end)
+	}
+
+	tenants := UsageQuota{Tenant: AnySubject, Max: 1, Period: PeriodDay}
+	acme := UsageQuota{Tenant: "acme", App: "billing", Max: 1, Period: PeriodDay}
+	if !day.Applies("", "") || !tenants.Applies("acme", "") || tenants.Applies("", "billing") || !acme.Applies("acme", "billing") || acme.Applies("acme", "crm") {
+		t.Error("Applies() selected the wrong tenants and apps")
+	}
+	if tenants.Key("acme", "x", now) == tenants.Key("globex", "x", now) || tenants.Key("acme", "x", now) != tenants.Key("acme", "y", now) {
+		t.Error("Key() must separate the tenants and apps the quota counts separately only")
+	}
+}
+
+func TestMemoryUsageCounter_Add(t *testing.T) {
+	counter := NewMemoryUsageCounter()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	counter.now = func() time.Time { return now }
+	ctx := context.Background()
+	expires := start.Add(time.Hour)
+
+	steps := []struct {
+		n      int64
+		wantOK bool
+		want   int64
+	}{
+		{2, true, 2},
+		{1, true, 3},
+		{2, false, 3},
+		{-1, true, 2},
+		{-5, true, 0},
+	}
+	for i, step := range steps {
+		used, ok, err := counter.Add(ctx, "usage", step.n, 3, expires)
+		if err != nil || ok != step.wantOK || used != step.want {
+			t.Errorf("step %d: Add(%d) = %d, %v, %v, want %d, %v", i, step.n, used, ok, err, step.want, step.wantOK)
+		}
+	}
+	counter.Add(ctx, "usage", 1, 3, expires)
+	if used, _ := counter.Usage(ctx, "usage"); used != 1 {
+		t.Errorf("Usage() = %d, want 1", used)
+	}
+
+	now = expires
+	if used, _ := counter.Usage(ctx, "usage"); used != 0 {
+		t.Errorf("Usage() after the period = %d, want 0", used)
+	}
+	if used, ok, _ := counter.Add(ctx, "usage", 3, 3, expires.Add(time.Hour)); !ok || used != 3 {
+		t.Errorf("Add() after the period = %d, %v, want a new count", used, ok)
+	}
+}
+
+// fakeRedis serves the commands RedisTokenBuckets sends, answering EVAL
+// with the replies queued in takes
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	commands []string
+	scripts  map[string]bool
+	takes    []string // RESP replies to the script
+}
+
+func newFakeRedis(t *testing.T, takes ...string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{listener: listener, scripts: make(map[string]bool), takes: takes}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := redis.ReadReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range reply.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, args[0])
+		var out string
+		switch args[0] {
+		case "AUTH":
+			out = "+OK\r\n"
+			if args[len(args)-1] != "secret" {
+				out = "-WRONGPASS invalid username-password pair\r\n"
+			}
+		case "SELECT":
+			out = "+OK\r\n"
+		case "EVALSHA":
+			if !f.scripts[args[1]] {
+				out = "-NOSCRIPT No matching script\r\n"
+				break
+			}
+			out, f.takes = f.takes[0], f.takes[1:]
+		case "EVAL":
+			if args[3] != "notifyhub:quota:feishu:300/1m0s" || args[4] != "300" || args[6] != "60000" {
+				out = "-ERR unexpected arguments\r\n"
+				break
+			}
+			f.scripts[takeScript.SHA()] = true
+			out, f.takes = f.takes[0], f.takes[1:]
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		if _, err := conn.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedisTokenBuckets_Take(t *testing.T) {
+	server := newFakeRedis(t, "*2\r\n:1\r\n:0\r\n", "*2\r\n:0\r\n:1500\r\n")
+	buckets, err := NewRedisTokenBuckets(RedisOptions{Addr: server.listener.Addr().String(), Password: "secret", DB: 2})
+	if err != nil {
+		t.Fatalf("NewRedisTokenBuckets() error = %v", err)
+	}
+	defer buckets.Close()
+
+	quota := Quota{Platform: "feishu", Rate: 300, Per: time.Minute}
+	ctx := context.Background()
+	allowed, _, err := buckets.Take(ctx, quota.Key(""), quota)
+	if err != nil || !allowed {
+		t.Fatalf("Take() = %v, %v, want allowed", allowed, err)
+	}
+	start := time.Now()
+	allowed, retryAt, err := buckets.Take(ctx, quota.Key(""), quota)
+	if err != nil || allowed || retryAt.Sub(start) < 1400*time.Millisecond || retryAt.Sub(start) > 2*time.Second {
+		t.Fatalf("Take() = %v, %v, %v, want denied for 1.5s", allowed, retryAt.Sub(start), err)
+	}
+	if got := strings.Join(server.commands, " "); got != "AUTH SELECT EVALSHA EVAL EVALSHA" {
+		t.Errorf("commands = %s, want the script loaded once on a single connection", got)
+	}
+
+	wrong, _ := NewRedisTokenBuckets(RedisOptions{Addr: server.listener.Addr().String(), Password: "wrong"})
+	if _, _, err := wrong.Take(ctx, quota.Key(""), quota); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Take() with a wrong password error = %v", err)
+	}
+	if _, err := NewRedisTokenBuckets(RedisOptions{}); err == nil {
+		t.Error("NewRedisTokenBuckets() without an address succeeded")
+	}
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/utils/redis"
+)
+
+// DefaultRedisKeyPrefix prefixes the keys of the buckets kept in Redis
+const DefaultRedisKeyPrefix = "notifyhub:quota:"
+
+// RedisOptions configure the Redis server holding shared token buckets
+type RedisOptions struct {
+	Addr     string // host:port
+	Username string // for Redis ACL users; empty uses the default user
+	Password string
+	DB       int
+
+	// KeyPrefix prefixes bucket keys; empty uses DefaultRedisKeyPrefix
+	KeyPrefix string
+
+	// Timeout bounds connecting and each command; zero uses 5s
+	Timeout time.Duration
+
+	// PoolSize is the number of idle connections kept; zero uses 4
+	PoolSize int
+}
+
+// takeScript takes a send from a bucket stored as a hash of its tokens and
+// the time they were counted, using the server clock so that every client
+// counts alike. It returns whether the send was taken and otherwise the
+// milliseconds until the bucket holds one.
+var takeScript = redis.NewScript(`
+redis.replicate_commands()
+local size = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local per = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or size
+local updated = tonumber(state[2]) or now
+tokens = math.min(size, tokens + math.max(0, now - updated) * rate / per)
+local taken = 0
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  taken = 1
+else
+  wait = math.ceil((1 - tokens) * per / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(size * per / rate))
+return {taken, wait}
+`)
+
+// RedisTokenBuckets implements TokenBuckets in Redis, so that the clients
+// of a fleet share their quotas. Each take runs as one script on the
+// server, which counts time with its own clock. It is safe for concurrent
+// use.
+type RedisTokenBuckets struct {
+	opts RedisOptions
+	pool *redis.Pool
+}
+
+// NewRedisTokenBuckets creates token buckets kept in a Redis server.
+// Connections are opened when they are first needed.
+func NewRedisTokenBuckets(opts RedisOptions) (*RedisTokenBuckets, error) {
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = DefaultRedisKeyPrefix
+	}
+	pool, err := redis.NewPool(redis.Options{
+		Addr:     opts.Addr,
+		Username: opts.Username,
+		Password: opts.Password,
+		DB:       opts.DB,
+		Timeout:  opts.Timeout,
+		PoolSize: opts.PoolSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RedisTokenBuckets{opts: opts, pool: pool}, nil
+}
+
+// refundScript returns a send to a bucket, which is full when it expired
+var refundScript = redis.NewScript(`
+local tokens = redis.call('HGET', KEYS[1], 'tokens')
+if not tokens then
+  return 0
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(math.min(tonumber(ARGV[1]), tonumber(tokens) + 1)))
+return 1
+`)
+
+// Take implements TokenBuckets
+func (r *RedisTokenBuckets) Take(ctx context.Context, key string, quota Quota) (bool, time.Time, error) {
+	reply, err := takeScript.Run(ctx, r.pool, []string{r.opts.KeyPrefix + key},
+		strconv.Itoa(quota.Size()), strconv.Itoa(quota.Rate), strconv.FormatInt(quota.Per.Milliseconds(), 10))
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("redis quota %s: %w", key, err)
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, time.Time{}, fmt.Errorf("redis quota %s: unexpected reply %v", key, reply)
+	}
+	taken, _ := values[0].(int64)
+	wait, _ := values[1].(int64)
+	now := time.Now()
+	if taken == 1 {
+		return true, now, nil
+	}
+	return false, now.Add(time.Duration(wait) * time.Millisecond), nil
+}
+
+// Refund implements TokenRefunder
+func (r *RedisTokenBuckets) Refund(ctx context.Context, key string, quota Quota) error {
+	if _, err := refundScript.Run(ctx, r.pool, []string{r.opts.KeyPrefix + key}, strconv.Itoa(quota.Size())); err != nil {
+		return fmt.Errorf("redis quota %s: %w", key, err)
+	}
+	return nil
+}
+
+// Close closes the idle connections
+func (r *RedisTokenBuckets) Close() error {
+	return r.pool.Close()
+}
@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateVersion is the format version of state files
+const stateVersion = 1
+
+// stateFile is the saved state of in-memory token buckets and a limiter
+type stateFile struct {
+	Version int                    `json:"version"`
+	SavedAt time.Time              `json:"saved_at"`
+	Buckets map[string]bucketState `json:"buckets,omitempty"`
+	Limits  map[string]limitState  `json:"limits,omitempty"`
+}
+
+// bucketState is the saved state of a token bucket
+type bucketState struct {
+	Tokens  float64       `json:"tokens"`
+	Updated time.Time     `json:"updated"`
+	Full    time.Duration `json:"full"`
+}
+
+// limitState is the saved send log of a limiter key
+type limitState struct {
+	Sends []time.Time   `json:"sends"`
+	Per   time.Duration `json:"per"`
+}
+
+// SaveState writes the state of in-memory token buckets and a limiter,
+// either of which may be nil, to a file so that a restarted client does
+// not start with full budgets and burst past a provider quota. The file is
+// replaced atomically. Buckets that are full again and keys without sends
+// in their window are left out, as they are recreated alike.
+func SaveState(path string, buckets *MemoryTokenBuckets, limiter *MemoryLimiter) error {
+	state := stateFile{Version: stateVersion, SavedAt: time.Now()}
+	if buckets != nil {
+		state.Buckets = buckets.export()
+	}
+	if limiter != nil {
+		state.Limits = limiter.export()
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save rate limit state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save rate limit state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save rate limit state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save rate limit state: %w", err)
+	}
+	return nil
+}
+
+// LoadState restores the state saved by SaveState into in-memory token
+// buckets and a limiter, either of which may be nil. Buckets refill for
+// the time that passed since they were saved. A missing file restores
+// nothing.
+func LoadState(path string, buckets *MemoryTokenBuckets, limiter *MemoryLimiter) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load rate limit state: %w", err)
+	}
+
+	var state stateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to load rate limit state %s: %w", path, err)
+	}
+	if state.Version != stateVersion {
+		return fmt.Errorf("failed to load rate limit state %s: unsupported version %d", path, state.Version)
+	}
+	if buckets != nil {
+		buckets.restore(state.Buckets)
+	}
+	if limiter != nil {
+		limiter.restore(state.Limits)
+	}
+	return nil
+}
+
+// export returns the state of the buckets that are not full again
+func (m *MemoryTokenBuckets) export() map[string]bucketState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	state := make(map[string]bucketState, len(m.buckets))
+	for key, b := range m.buckets {
+		if now.Sub(b.updated) < b.full {
+			state[key] = bucketState{Tokens: b.tokens, Updated: b.updated, Full: b.full}
+		}
+	}
+	return state
+}
+
+// restore adds saved buckets, replacing the buckets of the same keys
+func (m *MemoryTokenBuckets) restore(state map[string]bucketState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, s := range state {
+		m.buckets[key] = &bucket{tokens: s.Tokens, updated: s.Updated, full: s.Full}
+	}
+}
+
+// export returns the send logs of the keys with sends in their window
+func (m *MemoryLimiter) export() map[string]limitState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	state := make(map[string]limitState, len(m.logs))
+	for key, log := range m.logs {
+		sends := prune(log.sends, now.Add(-log.per))
+		if len(sends) > 0 {
+			state[key] = limitState{Sends: sends, Per: log.per}
+		}
+	}
+	return state
+}
+
+// restore adds saved send logs, replacing the logs of the same keys
+func (m *MemoryLimiter) restore(state map[string]limitState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, s := range state {
+		m.logs[key] = &sendLog{sends: s.Sends, per: s.Per}
+	}
+}
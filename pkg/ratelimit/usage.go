@@ -0,0 +1,188 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Periods of usage quotas, calendar days and months in UTC
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// AnySubject applies a usage quota to each tenant or app separately
+const AnySubject = "*"
+
+// UsageQuota caps the usage of the tenants or apps (API keys) sending
+// messages, such as 10000 messages a day for each tenant or 500 SMS a
+// month for one app:
+//
+//	config.WithUsageQuota(ratelimit.UsageQuota{Tenant: "*", Max: 10000, Period: ratelimit.PeriodDay})
+//	config.WithUsageQuota(ratelimit.UsageQuota{App: "billing", Platform: "sms", Max: 500, Period: ratelimit.PeriodMonth})
+//
+// Tenant and App select the sends a quota counts: empty counts every send
+// together, a name the sends of that tenant or app, and AnySubject the
+// sends of each tenant or app separately. Unlike a Quota, which delays
+// sends to respect a provider quota, a usage quota rejects the messages
+// over it.
+type UsageQuota struct {
+	Tenant   string `json:"tenant,omitempty"`   // the tenant counted, * for each tenant, empty for every tenant together
+	App      string `json:"app,omitempty"`      // the app or API key counted, * for each app, empty for every app together
+	Platform string `json:"platform,omitempty"` // count the targets of the platform, e.g. sms; empty counts messages
+	Max      int64  `json:"max"`
+	Period   string `json:"period"` // day or month
+
+	// SoftLimit is the usage at which a warning is sent, once per period;
+	// zero sends no warning
+	SoftLimit int64 `json:"soft_limit,omitempty"`
+}
+
+// Validate checks the usage quota definition
+func (q UsageQuota) Validate() error {
+	if q.Max <= 0 {
+		return fmt.Errorf("usage quota max must be positive, got %d", q.Max)
+	}
+	if q.Period != PeriodDay && q.Period != PeriodMonth {
+		return fmt.Errorf("invalid usage quota period %q, expected day or month", q.Period)
+	}
+	if q.SoftLimit < 0 || q.SoftLimit > q.Max {
+		return fmt.Errorf("usage quota soft limit must be between 0 and max %d, got %d", q.Max, q.SoftLimit)
+	}
+	return nil
+}
+
+// Applies reports whether the quota counts the sends of a tenant and app
+func (q UsageQuota) Applies(tenant, app string) bool {
+	return matchSubject(q.Tenant, tenant) && matchSubject(q.App, app)
+}
+
+// matchSubject reports whether the tenant or app of a quota selects a
+// tenant or app of a send
+func matchSubject(pattern, subject string) bool {
+	switch pattern {
+	case "":
+		return true
+	case AnySubject:
+		return subject != ""
+	default:
+		return pattern == subject
+	}
+}
+
+// Window returns the period of the quota holding t
+func (q UsageQuota) Window(t time.Time) (start, end time.Time) {
+	t = t.UTC()
+	if q.Period == PeriodMonth {
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// Key returns the counter key of the quota for a tenant and app in the
+// period starting at start. Tenants and apps the quota counts together
+// share a key, and the max is left out so that changing it keeps the
+// usage.
+func (q UsageQuota) Key(tenant, app string, start time.Time) string {
+	if q.Tenant == "" {
+		tenant = ""
+	}
+	if q.App == "" {
+		app = ""
+	}
+	return fmt.Sprintf("usage:%s:%s:%s:%s:%s", q.Platform, q.Period, start.Format(time.DateOnly), tenant, app)
+}
+
+// Usage is the usage of a quota by a tenant and app in the current period
+type Usage struct {
+	Quota       UsageQuota `json:"quota"`
+	Tenant      string     `json:"tenant,omitempty"`
+	App         string     `json:"app,omitempty"`
+	PeriodStart time.Time  `json:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end"`
+	Used        int64      `json:"used"`
+	Remaining   int64      `json:"remaining"`
+}
+
+// UsageCounter counts the usage of quotas per key
+type UsageCounter interface {
+	// Add adds n to the usage of key unless that takes it over limit, in
+	// which case it adds nothing and returns false; a negative n gives
+	// usage back and always succeeds. It returns the usage of key
+	// afterwards, which may be forgotten after expires.
+	Add(ctx context.Context, key string, n, limit int64, expires time.Time) (int64, bool, error)
+
+	// Usage returns the usage of key
+	Usage(ctx context.Context, key string) (int64, error)
+}
+
+// usageCount is the usage of a key
+type usageCount struct {
+	used    int64
+	expires time.Time
+}
+
+// MemoryUsageCounter implements UsageCounter in memory, for a single
+// client
+type MemoryUsageCounter struct {
+	counts map[string]*usageCount
+	calls  int
+	mu     sync.Mutex
+	now    func() time.Time
+}
+
+// NewMemoryUsageCounter creates an in-memory usage counter
+func NewMemoryUsageCounter() *MemoryUsageCounter {
+	return &MemoryUsageCounter{
+		counts: make(map[string]*usageCount),
+		now:    time.Now,
+	}
+}
+
+// Add implements UsageCounter
+func (m *MemoryUsageCounter) Add(ctx context.Context, key string, n, limit int64, expires time.Time) (int64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.calls++
+	if m.calls%sweepInterval == 0 {
+		m.sweep(now)
+	}
+
+	count, ok := m.counts[key]
+	if !ok || !now.Before(count.expires) {
+		count = &usageCount{}
+		m.counts[key] = count
+	}
+	count.expires = expires
+	if n > 0 && count.used+n > limit {
+		return count.used, false, nil
+	}
+	count.used = max(0, count.used+n)
+	return count.used, true, nil
+}
+
+// Usage implements UsageCounter
+func (m *MemoryUsageCounter) Usage(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count, ok := m.counts[key]
+	if !ok || !m.now().Before(count.expires) {
+		return 0, nil
+	}
+	return count.used, nil
+}
+
+// sweep drops the usage of past periods
+func (m *MemoryUsageCounter) sweep(now time.Time) {
+	for key, count := range m.counts {
+		if !now.Before(count.expires) {
+			delete(m.counts, key)
+		}
+	}
+}
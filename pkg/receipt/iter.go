@@ -0,0 +1,78 @@
+package receipt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// All returns the results of the receipt
+func (r *Receipt) All() iter.Seq[PlatformResult] {
+	return func(yield func(PlatformResult) bool) {
+		for _, result := range r.Results {
+			if !yield(result) {
+				return
+			}
+		}
+	}
+}
+
+// Failures returns the results of the targets whose delivery was attempted
+// and failed
+func (r *Receipt) Failures() iter.Seq[PlatformResult] {
+	return func(yield func(PlatformResult) bool) {
+		for _, result := range r.Results {
+			if !result.Success && !result.IsSkipped() && !yield(result) {
+				return
+			}
+		}
+	}
+}
+
+// Results returns the results of a sequence of receipts with their receipt,
+// such as the receipts of a large batch, one at a time
+func Results(receipts iter.Seq[*Receipt]) iter.Seq2[*Receipt, PlatformResult] {
+	return func(yield func(*Receipt, PlatformResult) bool) {
+		for r := range receipts {
+			if r == nil {
+				continue
+			}
+			for _, result := range r.Results {
+				if !yield(r, result) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Read returns the receipts of JSON lines, such as those the CLI writes
+// with send --receipts, decoding one at a time so that large files are
+// not held in memory. Blank lines are skipped; an invalid line yields its
+// error and ends the sequence.
+func Read(r io.Reader) iter.Seq2[*Receipt, error] {
+	return func(yield func(*Receipt, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16<<20)
+		for line := 1; scanner.Scan(); line++ {
+			data := scanner.Bytes()
+			if len(bytes.TrimSpace(data)) == 0 {
+				continue
+			}
+			var receipt Receipt
+			if err := json.Unmarshal(data, &receipt); err != nil {
+				yield(nil, fmt.Errorf("invalid receipt on line %d: %w", line, err))
+				return
+			}
+			if !yield(&receipt, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
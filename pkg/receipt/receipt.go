@@ -10,18 +10,25 @@ type Receipt struct {
 	Results    []PlatformResult `json:"results"`
 	Successful int              `json:"successful"`
 	Failed     int              `json:"failed"`
+	Skipped    int              `json:"skipped,omitempty"`
+	Held       int              `json:"held,omitempty"`
 	Total      int              `json:"total"`
 	Timestamp  time.Time        `json:"timestamp"`
+	BatchMode  string           `json:"batch_mode,omitempty"` // the mode of the batch the message was sent in
 }
 
 // PlatformResult represents the result of sending to a specific platform
 type PlatformResult struct {
-	Platform  string    `json:"platform"`
-	Target    string    `json:"target"`
-	Success   bool      `json:"success"`
-	MessageID string    `json:"message_id,omitempty"`
-	Error     string    `json:"error,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
+	Platform  string        `json:"platform"`
+	Target    string        `json:"target"`
+	Success   bool          `json:"success"`
+	Status    string        `json:"status,omitempty"` // set for targets that were intentionally not delivered or reported on later
+	MessageID string        `json:"message_id,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	HeldUntil *time.Time    `json:"held_until,omitempty"` // when a held target will be delivered
+	Timeout   time.Duration `json:"timeout,omitempty"`    // the effective timeout of the delivery
+	DryRun    bool          `json:"dry_run,omitempty"`    // recorded by a platform in dry-run mode, not sent
+	Timestamp time.Time     `json:"timestamp"`
 }
 
 // Status constants
@@ -31,8 +38,59 @@ const (
 	StatusFailed     = "failed"
 	StatusPending    = "pending"
 	StatusProcessing = "processing"
+	StatusSkipped    = "skipped" // every target was intentionally not delivered
+	StatusHeld       = "held"    // nothing delivered yet, some targets wait for their delivery window
+	StatusAborted    = "aborted" // not sent, as its batch was rejected or stopped
+
+	// Statuses confirmed by delivery callbacks after the send
+	StatusDelivered = "delivered" // every attempted target confirmed delivered
+	StatusBounced   = "bounced"   // every attempted target returned as undeliverable
 )
 
+// Result status constants for targets that were intentionally not delivered
+const (
+	ResultSuppressed  = "suppressed"   // recipient opted out
+	ResultBlocked     = "blocked"      // target denied by access rules
+	ResultQuietHours  = "quiet_hours"  // recipient's quiet hours are active
+	ResultDigested    = "digested"     // collected into the recipient's digest
+	ResultHeld        = "held"         // held until the recipient's delivery window opens
+	ResultDuplicate   = "duplicate"    // recipient already reached through another target
+	ResultRateLimited = "rate_limited" // recipient rate limit reached, dropped by policy
+	ResultQuarantined = "quarantined"  // target quarantined after repeated hard failures
+	ResultDisabled    = "disabled"     // platform switched off by a feature flag
+	ResultExcluded    = "excluded"     // platform not among the platforms the send is restricted to
+)
+
+// Result status constants for feedback received after delivery
+const (
+	ResultBounced   = "bounced"   // accepted, then returned as undeliverable
+	ResultReplied   = "replied"   // recipient answered
+	ResultDelivered = "delivered" // confirmed delivered by the platform
+	ResultRead      = "read"      // confirmed read by the recipient
+)
+
+// IsConfirmed reports whether the platform or the recipient confirmed the
+// delivery after the send
+func (r PlatformResult) IsConfirmed() bool {
+	switch r.Status {
+	case ResultDelivered, ResultRead, ResultReplied:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsSkipped reports whether the target was intentionally not delivered
+// (for example because the recipient opted out) rather than attempted
+func (r PlatformResult) IsSkipped() bool {
+	switch r.Status {
+	case ResultSuppressed, ResultBlocked, ResultQuietHours, ResultDigested, ResultHeld, ResultDuplicate, ResultRateLimited, ResultQuarantined, ResultDisabled, ResultExcluded:
+		return true
+	default:
+		return false
+	}
+}
+
 // New creates a new receipt
 func New(messageID string) *Receipt {
 	return &Receipt{
@@ -46,13 +104,35 @@ func New(messageID string) *Receipt {
 // AddResult adds a platform result to the receipt
 func (r *Receipt) AddResult(result PlatformResult) {
 	r.Results = append(r.Results, result)
+	r.recount()
+}
+
+// UpdateResult replaces the result at index i, such as with the delivery a
+// platform confirmed later, and updates the counters and status
+func (r *Receipt) UpdateResult(i int, result PlatformResult) {
+	if i < 0 || i >= len(r.Results) {
+		return
+	}
+	r.Results[i] = result
+	r.recount()
+}
+
+// recount updates the counters and status from the results
+func (r *Receipt) recount() {
 	r.Total = len(r.Results)
 
 	// Update counters
 	r.Successful = 0
 	r.Failed = 0
+	r.Skipped = 0
+	r.Held = 0
 	for _, res := range r.Results {
-		if res.Success {
+		if res.IsSkipped() {
+			r.Skipped++
+			if res.Status == ResultHeld {
+				r.Held++
+			}
+		} else if res.Success {
 			r.Successful++
 		} else {
 			r.Failed++
@@ -70,8 +150,16 @@ func (r *Receipt) updateStatus() {
 		return
 	}
 
-	if r.Failed == 0 {
+	if r.Skipped == r.Total && r.Held > 0 {
+		r.Status = StatusHeld
+	} else if r.Skipped == r.Total {
+		r.Status = StatusSkipped
+	} else if r.Failed == 0 && r.allAttempted(PlatformResult.IsConfirmed) {
+		r.Status = StatusDelivered
+	} else if r.Failed == 0 {
 		r.Status = StatusSuccess
+	} else if r.Successful == 0 && r.allAttempted(func(res PlatformResult) bool { return res.Status == ResultBounced }) {
+		r.Status = StatusBounced
 	} else if r.Successful == 0 {
 		r.Status = StatusFailed
 	} else {
@@ -79,6 +167,17 @@ func (r *Receipt) updateStatus() {
 	}
 }
 
+// allAttempted reports whether every result that is not skipped satisfies
+// a predicate
+func (r *Receipt) allAttempted(ok func(PlatformResult) bool) bool {
+	for _, res := range r.Results {
+		if !res.IsSkipped() && !ok(res) {
+			return false
+		}
+	}
+	return true
+}
+
 // IsComplete returns true if all results have been received
 func (r *Receipt) IsComplete() bool {
 	return r.Status != StatusPending && r.Status != StatusProcessing
@@ -86,7 +185,7 @@ func (r *Receipt) IsComplete() bool {
 
 // IsSuccess returns true if all deliveries were successful
 func (r *Receipt) IsSuccess() bool {
-	return r.Status == StatusSuccess
+	return r.Status == StatusSuccess || r.Status == StatusDelivered
 }
 
 // IsPartial returns true if some deliveries were successful
@@ -96,22 +195,23 @@ func (r *Receipt) IsPartial() bool {
 
 // IsFailed returns true if all deliveries failed
 func (r *Receipt) IsFailed() bool {
-	return r.Status == StatusFailed
+	return r.Status == StatusFailed || r.Status == StatusBounced
 }
 
-// GetSuccessRate returns the success rate as a percentage
+// GetSuccessRate returns the success rate of attempted deliveries as a percentage
 func (r *Receipt) GetSuccessRate() float64 {
-	if r.Total == 0 {
+	attempted := r.Successful + r.Failed
+	if attempted == 0 {
 		return 0.0
 	}
-	return float64(r.Successful) / float64(r.Total) * 100
+	return float64(r.Successful) / float64(attempted) * 100
 }
 
 // GetErrors returns all error messages from failed results
 func (r *Receipt) GetErrors() []string {
-	errors := make([]string, 0, r.Failed)
+	errors := make([]string, 0, capacity(r.Failed, len(r.Results)))
 	for _, result := range r.Results {
-		if !result.Success && result.Error != "" {
+		if !result.Success && !result.IsSkipped() && result.Error != "" {
 			errors = append(errors, result.Error)
 		}
 	}
@@ -120,7 +220,7 @@ func (r *Receipt) GetErrors() []string {
 
 // GetSuccessfulPlatforms returns the names of platforms that succeeded
 func (r *Receipt) GetSuccessfulPlatforms() []string {
-	platforms := make([]string, 0, r.Successful)
+	platforms := make([]string, 0, capacity(r.Successful, len(r.Results)))
 	for _, result := range r.Results {
 		if result.Success {
 			platforms = append(platforms, result.Platform)
@@ -131,11 +231,39 @@ func (r *Receipt) GetSuccessfulPlatforms() []string {
 
 // GetFailedPlatforms returns the names of platforms that failed
 func (r *Receipt) GetFailedPlatforms() []string {
-	platforms := make([]string, 0, r.Failed)
+	platforms := make([]string, 0, capacity(r.Failed, len(r.Results)))
 	for _, result := range r.Results {
-		if !result.Success {
+		if !result.Success && !result.IsSkipped() {
 			platforms = append(platforms, result.Platform)
 		}
 	}
 	return platforms
 }
+
+// Masked returns a copy of the receipt whose target addresses are masked,
+// such as with redact.MaskTarget, for showing it to callers that may not
+// see them; a nil mask returns the receipt itself
+func (r *Receipt) Masked(mask func(string) string) *Receipt {
+	if r == nil || mask == nil {
+		return r
+	}
+	masked := *r
+	masked.Results = make([]PlatformResult, len(r.Results))
+	for i, result := range r.Results {
+		result.Target = mask(result.Target)
+		masked.Results[i] = result
+	}
+	return &masked
+}
+
+// capacity bounds a counter used as a slice capacity by the number of
+// results, as the counters of a decoded receipt may be anything
+func capacity(count, results int) int {
+	if count < 0 {
+		return 0
+	}
+	if count > results {
+		return results
+	}
+	return count
+}
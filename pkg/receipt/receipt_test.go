@@ -1,6 +1,9 @@
 package receipt
 
 import (
+	"encoding/json"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -108,6 +111,29 @@ func TestReceipt_UpdateStatus(t *testing.T) {
 			},
 			expectedStatus: StatusPartial,
 		},
+		{
+			name: "suppressed targets do not count as failures",
+			results: []PlatformResult{
+				{Platform: "email", Success: true},
+				{Platform: "email", Success: false, Status: ResultSuppressed},
+			},
+			expectedStatus: StatusSuccess,
+		},
+		{
+			name: "all suppressed",
+			results: []PlatformResult{
+				{Platform: "email", Success: false, Status: ResultSuppressed},
+			},
+			expectedStatus: StatusSkipped,
+		},
+		{
+			name: "held for delivery window",
+			results: []PlatformResult{
+				{Platform: "email", Success: false, Status: ResultHeld},
+				{Platform: "email", Success: false, Status: ResultSuppressed},
+			},
+			expectedStatus: StatusHeld,
+		},
 	}
 
 	for _, tt := range tests {
@@ -165,6 +191,42 @@ func TestReceipt_IsSuccess(t *testing.T) {
 	}
 }
 
+func TestReceipt_UpdateResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		updates    map[int]string // result status of updated results
+		wantStatus string
+		wantFailed int
+	}{
+		{name: "unconfirmed", wantStatus: StatusSuccess},
+		{name: "some delivered", updates: map[int]string{0: ResultDelivered}, wantStatus: StatusSuccess},
+		{name: "all delivered or read", updates: map[int]string{0: ResultDelivered, 1: ResultRead}, wantStatus: StatusDelivered},
+		{name: "one bounced", updates: map[int]string{0: ResultDelivered, 1: ResultBounced}, wantStatus: StatusPartial, wantFailed: 1},
+		{name: "all bounced", updates: map[int]string{0: ResultBounced, 1: ResultBounced}, wantStatus: StatusBounced, wantFailed: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := New("msg-123")
+			receipt.AddResult(PlatformResult{Platform: "email", Target: "a@example.org", Success: true})
+			receipt.AddResult(PlatformResult{Platform: "email", Target: "b@example.org", Success: true})
+			receipt.AddResult(PlatformResult{Platform: "email", Target: "c@example.org", Status: ResultSuppressed})
+			for i, status := range tt.updates {
+				result := receipt.Results[i]
+				result.Status, result.Success = status, status != ResultBounced
+				receipt.UpdateResult(i, result)
+			}
+			receipt.UpdateResult(5, PlatformResult{})
+
+			if receipt.Status != tt.wantStatus || receipt.Failed != tt.wantFailed || receipt.Total != 3 {
+				t.Errorf("UpdateResult() status = %s with %d failed of %d, want %s with %d failed", receipt.Status, receipt.Failed, receipt.Total, tt.wantStatus, tt.wantFailed)
+			}
+			if receipt.IsSuccess() != (tt.wantStatus == StatusSuccess || tt.wantStatus == StatusDelivered) || receipt.IsFailed() != (tt.wantStatus == StatusBounced) {
+				t.Errorf("IsSuccess() = %v, IsFailed() = %v for status %s", receipt.IsSuccess(), receipt.IsFailed(), receipt.Status)
+			}
+		})
+	}
+}
+
 func TestReceipt_IsPartial(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -267,3 +329,133 @@ func TestStatusConstants(t *testing.T) {
 		t.Errorf("StatusProcessing = %v, want processing", StatusProcessing)
 	}
 }
+
+func FuzzReceipt_UnmarshalJSON(f *testing.F) {
+	f.Add([]byte(`{"message_id":"msg-1","status":"partial","results":[{"platform":"email","target":"ops@example.com","success":true,"timestamp":"2026-01-02T03:04:05Z"},{"platform":"feishu","target":"ou_1","error":"timeout","timeout":5000000000}],"successful":1,"failed":1,"total":2}`))
+	f.Add([]byte(`{"status":"held","results":[{"platform":"sms","status":"held","held_until":"2026-01-02T08:00:00+08:00"}],"skipped":1,"held":1,"total":1}`))
+	f.Add([]byte(`{"successful":-1,"failed":-1,"total":-2,"results":null}`))
+	f.Add([]byte(`null`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var decoded Receipt
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return
+		}
+
+		// Counters of a consumed receipt are not trusted: accessors must
+		// not panic on inconsistent ones
+		decoded.IsComplete()
+		decoded.GetSuccessRate()
+		decoded.GetErrors()
+		decoded.GetSuccessfulPlatforms()
+		decoded.GetFailedPlatforms()
+
+		// Rebuilding from the results yields consistent counters
+		rebuilt := New(decoded.MessageID)
+		for _, result := range decoded.Results {
+			rebuilt.AddResult(result)
+		}
+		if rebuilt.Successful+rebuilt.Failed+rebuilt.Skipped != rebuilt.Total || rebuilt.Total != len(decoded.Results) {
+			t.Fatalf("rebuilt counters = %d+%d+%d of %d, want %d results", rebuilt.Successful, rebuilt.Failed, rebuilt.Skipped, rebuilt.Total, len(decoded.Results))
+		}
+		if rate := rebuilt.GetSuccessRate(); rate < 0 || rate > 100 {
+			t.Errorf("GetSuccessRate() = %v, want a percentage", rate)
+		}
+
+		encoded, err := json.Marshal(&decoded)
+		if err != nil {
+			t.Fatalf("Marshal() of a decoded receipt error = %v", err)
+		}
+		var again Receipt
+		if err := json.Unmarshal(encoded, &again); err != nil {
+			t.Fatalf("Unmarshal() of %s error = %v", encoded, err)
+		}
+		if reencoded, _ := json.Marshal(&again); string(reencoded) != string(encoded) {
+			t.Errorf("round trip changed the receipt:\n%s\n%s", encoded, reencoded)
+		}
+	})
+}
+
+func TestReceipt_Masked(t *testing.T) {
+	receipt := New("msg-123")
+	receipt.AddResult(PlatformResult{Platform: "email", Target: "carol@gmail.com", Success: true})
+
+	masked := receipt.Masked(func(string) string { return "***" })
+	if masked.Results[0].Target != "***" || masked.Successful != 1 || masked.Results[0].Platform != "email" {
+		t.Errorf("Masked() = %+v", masked)
+	}
+	if receipt.Results[0].Target != "carol@gmail.com" {
+		t.Errorf("Masked() changed the receipt: %+v", receipt.Results[0])
+	}
+	if receipt.Masked(nil) != receipt {
+		t.Error("Masked(nil) copied the receipt")
+	}
+	var nilReceipt *Receipt
+	if nilReceipt.Masked(func(s string) string { return s }) != nil {
+		t.Error("Masked() of a nil receipt is not nil")
+	}
+}
+
+func TestReceipt_Failures(t *testing.T) {
+	receipt := New("msg-123")
+	receipt.AddResult(PlatformResult{Platform: "email", Target: "a@example.com", Success: true})
+	receipt.AddResult(PlatformResult{Platform: "email", Target: "b@example.com", Error: "mailbox full"})
+	receipt.AddResult(PlatformResult{Platform: "email", Target: "c@example.com", Status: ResultSuppressed})
+	receipt.AddResult(PlatformResult{Platform: "sms", Target: "+8613800138000", Error: "timeout"})
+
+	var targets []string
+	for result := range receipt.Failures() {
+		targets = append(targets, result.Target)
+	}
+	if strings.Join(targets, ",") != "b@example.com,+8613800138000" {
+		t.Errorf("Failures() = %v", targets)
+	}
+	if all := slices.Collect(receipt.All()); len(all) != 4 {
+		t.Errorf("All() = %d results, want 4", len(all))
+	}
+	for result := range receipt.Failures() {
+		if result.Target != "b@example.com" {
+			t.Errorf("Failures() went on after the loop stopped at %s", result.Target)
+		}
+		break
+	}
+}
+
+func TestRead(t *testing.T) {
+	lines := `{"message_id": "m1", "status": "success", "results": [{"platform": "email", "target": "a@example.com", "success": true}]}
+
+{"message_id": "m2", "status": "failed", "results": [{"platform": "sms", "target": "+86", "error": "timeout"}, {"platform": "email", "target": "b@example.com", "error": "bounced"}]}
+not json
+{"message_id": "m4"}
+`
+	var ids []string
+	var readErr error
+	for r, err := range Read(strings.NewReader(lines)) {
+		if err != nil {
+			readErr = err
+			continue
+		}
+		ids = append(ids, r.MessageID)
+	}
+	if strings.Join(ids, ",") != "m1,m2" {
+		t.Errorf("Read() = %v, want the receipts before the invalid line", ids)
+	}
+	if readErr == nil || !strings.Contains(readErr.Error(), "line 4") {
+		t.Errorf("Read() error = %v, want the invalid line", readErr)
+	}
+
+	receipts := func(yield func(*Receipt) bool) {
+		for r, err := range Read(strings.NewReader(lines)) {
+			if err != nil || !yield(r) {
+				return
+			}
+		}
+	}
+	var results []string
+	for r, result := range Results(receipts) {
+		results = append(results, r.MessageID+":"+result.Target)
+	}
+	if strings.Join(results, ",") != "m1:a@example.com,m2:+86,m2:b@example.com" {
+		t.Errorf("Results() = %v", results)
+	}
+}
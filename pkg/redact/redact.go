@@ -0,0 +1,311 @@
+// Package redact masks personal data and secrets, such as email addresses,
+// phone numbers and webhook tokens, in log entries, error messages and
+// receipts.
+//
+// A Redactor applies rules in order; each rule replaces the matches of a
+// regular expression. The default rules mask contact addresses partially,
+// so that logs stay useful for support (c***@gmail.com, +86138****8000),
+// and replace credentials entirely.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// Redacted replaces the secrets removed by the rules without a mask
+const Redacted = "[REDACTED]"
+
+// Names of the default rules
+const (
+	RuleEmail    = "email"     // email addresses: c***@gmail.com
+	RulePhone    = "phone"     // international and Chinese mobile numbers: +86138****8000
+	RuleURLToken = "url_token" // token, key and signature query parameters
+	RuleWebhook  = "webhook"   // tokens in the paths of Slack, Feishu, Lark and Discord webhooks
+	RuleAuth     = "auth"      // bearer and basic credentials
+	RuleSecret   = "secret"    // password=..., secret: ... and similar assignments
+)
+
+// Rule masks the matches of a pattern
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+
+	// Mask returns the replacement of a match. Without one, matches are
+	// replaced with their first capture group, if any, followed by
+	// Redacted, so patterns can keep a prefix such as "token=".
+	Mask func(match string) string
+}
+
+// DefaultRules returns the rules masking contact addresses, webhook
+// tokens and credentials
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: RuleWebhook, Pattern: regexp.MustCompile(`((?:hooks\.slack\.com/services|open\.(?:feishu\.cn|larksuite\.com)/open-apis/bot/v2/hook|discord(?:app)?\.com/api/webhooks)/)[\w/-]+`)},
+		{Name: RuleURLToken, Pattern: regexp.MustCompile(`(?i)([?&](?:access_token|token|key|secret|sign|signature|api_key|apikey|password)=)[^&\s"']+`)},
+		{Name: RuleAuth, Pattern: regexp.MustCompile(`(?i)(\b(?:bearer|basic)\s+)[\w.~+/=-]+`)},
+		{Name: RuleSecret, Pattern: regexp.MustCompile(`(?i)(\b(?:password|passwd|secret|api_key|apikey|access_key|private_key)["']?\s*[:=]\s*["']?)[^\s"'&,;]+`)},
+		{Name: RuleEmail, Pattern: regexp.MustCompile(`[\w.%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), Mask: maskEmail},
+		{Name: RulePhone, Pattern: regexp.MustCompile(`\+\d{8,15}\b|\b1[3-9]\d{9}\b`), Mask: maskPhone},
+	}
+}
+
+// credentialRules are the names of the default rules removing credentials
+var credentialRules = []string{RuleWebhook, RuleURLToken, RuleAuth, RuleSecret}
+
+// minSecretLength is the length below which secret values are not
+// scrubbed, as they would match ordinary words
+const minSecretLength = 4
+
+// Secrets returns a rule replacing the occurrences of secret values, such
+// as configured passwords and tokens. Values shorter than four characters
+// are ignored. It reports false when there is no value to replace.
+func Secrets(name string, values ...string) (Rule, bool) {
+	unique := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		if len(value) >= minSecretLength && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	if len(unique) == 0 {
+		return Rule{}, false
+	}
+	// Longer values first, so that a URL is replaced before its path
+	sort.Slice(unique, func(i, j int) bool { return len(unique[i]) > len(unique[j]) })
+	quoted := make([]string, len(unique))
+	for i, value := range unique {
+		quoted[i] = regexp.QuoteMeta(value)
+	}
+	return Rule{Name: name, Pattern: regexp.MustCompile(strings.Join(quoted, "|"))}, true
+}
+
+// NewScrubber creates a redactor removing credentials: the secret values,
+// then the default rules for webhook tokens, token parameters, auth
+// headers and secret assignments. Unlike the default redactor it leaves
+// contact addresses, so it applies whether redaction is enabled or not.
+func NewScrubber(secrets ...string) *Redactor {
+	var rules []Rule
+	if rule, ok := Secrets("credentials", secrets...); ok {
+		rules = append(rules, rule)
+	}
+	defaults := DefaultRules()
+	for _, name := range credentialRules {
+		rules = append(rules, defaults[ruleIndex(defaults, name)])
+	}
+	return New(rules...)
+}
+
+// Redactor masks the matches of its rules. A nil Redactor leaves values
+// unchanged, so that callers need not check whether redaction is enabled.
+type Redactor struct {
+	rules []Rule
+}
+
+// New creates a redactor applying rules in order
+func New(rules ...Rule) *Redactor {
+	return &Redactor{rules: rules}
+}
+
+// Default creates a redactor with the default rules
+func Default() *Redactor {
+	return New(DefaultRules()...)
+}
+
+// Config selects the rules of a redactor
+type Config struct {
+	Disable  []string // names of default rules not to apply
+	Patterns []string // regular expressions whose matches are replaced, keeping their first capture group
+}
+
+// FromConfig creates a redactor with the default rules that are not
+// disabled, followed by the configured patterns
+func FromConfig(cfg Config) (*Redactor, error) {
+	rules := DefaultRules()
+	for _, name := range cfg.Disable {
+		i := ruleIndex(rules, name)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown redaction rule %q", name)
+		}
+		rules = append(rules[:i], rules[i+1:]...)
+	}
+	for i, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %d: %w", i, err)
+		}
+		rules = append(rules, Rule{Name: fmt.Sprintf("pattern_%d", i), Pattern: re})
+	}
+	return New(rules...), nil
+}
+
+// String masks a value
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, rule := range r.rules {
+		if rule.Mask != nil {
+			s = rule.Pattern.ReplaceAllStringFunc(s, rule.Mask)
+		} else if rule.Pattern.NumSubexp() > 0 {
+			s = rule.Pattern.ReplaceAllString(s, "${1}"+Redacted)
+		} else {
+			s = rule.Pattern.ReplaceAllLiteralString(s, Redacted)
+		}
+	}
+	return s
+}
+
+// Error returns an error whose message is masked, which unwraps to err so
+// that errors.Is and errors.As still match it. It returns err itself when
+// its message holds nothing to mask.
+func (r *Redactor) Error(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+	msg := err.Error()
+	masked := r.String(msg)
+	if masked == msg {
+		return err
+	}
+	return &redactedError{err: err, msg: masked}
+}
+
+// redactedError is an error with a masked message
+type redactedError struct {
+	err error
+	msg string
+}
+
+// Error returns the masked message
+func (e *redactedError) Error() string { return e.msg }
+
+// Unwrap returns the original error
+func (e *redactedError) Unwrap() error { return e.err }
+
+// ruleIndex returns the index of the rule with a name, or -1
+func ruleIndex(rules []Rule, name string) int {
+	for i, rule := range rules {
+		if rule.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// maskEmail keeps the first character of the local part and the domain
+func maskEmail(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	return email[:1] + "***" + email[at:]
+}
+
+// maskPhone keeps the last four digits and the digits before the four
+// masked ones, which hold the country and carrier codes
+func maskPhone(phone string) string {
+	return phone[:len(phone)-8] + "****" + phone[len(phone)-4:]
+}
+
+// Logger returns a logger masking the messages and values of the entries
+// of l. Values of keys that name credentials, such as "password" or
+// "token", are replaced entirely.
+func (r *Redactor) Logger(l logger.Logger) logger.Logger {
+	if r == nil {
+		return l
+	}
+	return &redactingLogger{logger: l, redactor: r}
+}
+
+// redactingLogger masks the entries of a logger
+type redactingLogger struct {
+	logger   logger.Logger
+	redactor *Redactor
+}
+
+// LogMode sets the log level of the underlying logger.
+func (l *redactingLogger) LogMode(level logger.LogLevel) logger.Logger {
+	return &redactingLogger{logger: l.logger.LogMode(level), redactor: l.redactor}
+}
+
+// Enabled reports whether the underlying logger logs the level.
+func (l *redactingLogger) Enabled(level logger.LogLevel) bool {
+	return logger.Enabled(l.logger, level)
+}
+
+// Info logs a masked informational message.
+func (l *redactingLogger) Info(msg string, args ...any) {
+	l.logger.Info(l.redactor.String(msg), l.args(args)...)
+}
+
+// Warn logs a masked warning message.
+func (l *redactingLogger) Warn(msg string, args ...any) {
+	l.logger.Warn(l.redactor.String(msg), l.args(args)...)
+}
+
+// Error logs a masked error message.
+func (l *redactingLogger) Error(msg string, args ...any) {
+	l.logger.Error(l.redactor.String(msg), l.args(args)...)
+}
+
+// Debug logs a masked debug message.
+func (l *redactingLogger) Debug(msg string, args ...any) {
+	l.logger.Debug(l.redactor.String(msg), l.args(args)...)
+}
+
+// args returns a masked copy of key-value pairs
+func (l *redactingLogger) args(args []any) []any {
+	if len(args) == 0 {
+		return args
+	}
+	masked := make([]any, len(args))
+	for i, arg := range args {
+		if i%2 == 1 {
+			if key, ok := args[i-1].(string); ok && isSecretKey(key) {
+				masked[i] = Redacted
+				continue
+			}
+		}
+		masked[i] = l.value(arg)
+	}
+	return masked
+}
+
+// value masks a logged value: strings are masked, errors and stringers
+// are replaced with their masked text when it holds something to mask, and
+// other values are logged unchanged
+func (l *redactingLogger) value(v any) any {
+	switch v := v.(type) {
+	case string:
+		return l.redactor.String(v)
+	case error:
+		return l.redactor.Error(v)
+	case fmt.Stringer:
+		text := v.String()
+		if masked := l.redactor.String(text); masked != text {
+			return masked
+		}
+		return v
+	case []string:
+		masked := make([]string, len(v))
+		for i, s := range v {
+			masked[i] = l.redactor.String(s)
+		}
+		return masked
+	}
+	return v
+}
+
+// isSecretKey reports whether a log key names a credential
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"password", "secret", "token", "api_key", "apikey", "authorization", "credential"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
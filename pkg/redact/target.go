@@ -0,0 +1,73 @@
+package redact
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Target masking modes, which select how receipts and APIs show target
+// addresses
+const (
+	TargetMaskPartial = "partial" // c***@gmail.com, +86138****8000, https://hooks.example.com/******
+	TargetMaskFull    = "full"    // [REDACTED]
+	TargetMaskNone    = "none"    // unchanged
+)
+
+// TargetMasker returns the mask of a target masking mode, nil for
+// TargetMaskNone; the empty mode is TargetMaskPartial
+func TargetMasker(mode string) (func(string) string, error) {
+	switch mode {
+	case "", TargetMaskPartial:
+		return MaskTarget, nil
+	case TargetMaskFull:
+		return func(string) string { return Redacted }, nil
+	case TargetMaskNone:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("target masking must be partial, full or none, got %q", mode)
+}
+
+// MaskTarget masks a target address partially, so that support can still
+// tell targets apart: email addresses and phone numbers like the default
+// rules, URLs down to their host, and other values such as user IDs down
+// to their first and last two characters
+func MaskTarget(value string) string {
+	switch {
+	case value == "":
+		return value
+	case strings.Count(value, "@") == 1 && !strings.HasPrefix(value, "@") && !strings.Contains(value, "://"):
+		return maskEmail(value)
+	case isPhone(value):
+		return maskPhone(value)
+	}
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" && u.Host != "" {
+		masked := u.Scheme + "://" + u.Host
+		if u.Path != "" && u.Path != "/" || u.RawQuery != "" {
+			masked += "/******"
+		}
+		return masked
+	}
+	if utf8.RuneCountInString(value) <= 6 {
+		r, _ := utf8.DecodeRuneInString(value)
+		return string(r) + "***"
+	}
+	runes := []rune(value)
+	return string(runes[:2]) + "***" + string(runes[len(runes)-2:])
+}
+
+// isPhone reports whether a value is a phone number of 8 to 15 digits,
+// with an optional leading plus
+func isPhone(value string) bool {
+	digits := strings.TrimPrefix(value, "+")
+	if len(digits) < 8 || len(digits) > 15 {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
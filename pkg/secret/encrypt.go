@@ -0,0 +1,160 @@
+// Package secret provides encrypted configuration values
+package secret
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of configuration encryption keys
+const KeySize = 32
+
+// EncryptedPrefix starts encrypted configuration values
+const EncryptedPrefix = "ENC[AES256_GCM,"
+
+// KeyProvider supplies the key that decrypts encrypted configuration values
+type KeyProvider interface {
+	Key(ctx context.Context) ([]byte, error)
+}
+
+// KeyProviderFunc adapts a function to the KeyProvider interface, e.g. to
+// unwrap a data key with a cloud KMS
+type KeyProviderFunc func(ctx context.Context) ([]byte, error)
+
+// Key implements KeyProvider
+func (f KeyProviderFunc) Key(ctx context.Context) ([]byte, error) {
+	return f(ctx)
+}
+
+// StaticKey provides a fixed key
+func StaticKey(key []byte) KeyProvider {
+	return KeyProviderFunc(func(context.Context) ([]byte, error) {
+		return key, nil
+	})
+}
+
+// KeyFromEnv provides the base64 encoded key held by an environment variable
+func KeyFromEnv(name string) KeyProvider {
+	return KeyProviderFunc(func(context.Context) ([]byte, error) {
+		value := os.Getenv(name)
+		if value == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+		return decodeKey(value)
+	})
+}
+
+// KeyFromFile provides the base64 encoded key stored in a file, such as a
+// mounted secret
+func KeyFromFile(path string) KeyProvider {
+	return KeyProviderFunc(func(context.Context) ([]byte, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return decodeKey(string(data))
+	})
+}
+
+// GenerateKey returns a new random key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// IsEncrypted reports whether a configuration value is encrypted
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix) && strings.HasSuffix(value, "]")
+}
+
+// Encrypt encrypts a configuration value in the SOPS value format,
+//
+//	ENC[AES256_GCM,data:...,iv:...,tag:...,type:str]
+//
+// The path of the setting, such as "webhook.token", is authenticated with
+// the value, so an encrypted value cannot be moved to another setting.
+func Encrypt(key []byte, path, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nil, iv, []byte(plaintext), []byte(path))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	return fmt.Sprintf("%sdata:%s,iv:%s,tag:%s,type:str]", EncryptedPrefix,
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag)), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt for the same path
+func Decrypt(key []byte, path, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", fmt.Errorf("value is not encrypted")
+	}
+
+	parts := make(map[string][]byte)
+	for _, part := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(value, EncryptedPrefix), "]"), ",") {
+		name, encoded, _ := strings.Cut(part, ":")
+		if name == "type" {
+			if encoded != "str" {
+				return "", fmt.Errorf("unsupported encrypted value type %q", encoded)
+			}
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", fmt.Errorf("invalid encrypted value: %s is not base64", name)
+		}
+		parts[name] = decoded
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(parts["iv"]) != gcm.NonceSize() || len(parts["tag"]) != gcm.Overhead() {
+		return "", fmt.Errorf("invalid encrypted value: bad iv or tag")
+	}
+	plaintext, err := gcm.Open(nil, parts["iv"], append(parts["data"], parts["tag"]...), []byte(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: wrong key or the value was encrypted for another setting")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decodeKey decodes a base64 key
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
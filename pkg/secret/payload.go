@@ -0,0 +1,118 @@
+// Package secret provides encryption of payloads stored at rest, such as
+// serialized messages in a queue or a dead letter file
+package secret
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// PayloadAlgorithm names the encryption of sealed payloads
+const PayloadAlgorithm = "AES256_GCM"
+
+// ErrPayloadEncrypted is returned when an encrypted payload is read
+// without a key
+var ErrPayloadEncrypted = errors.New("payload is encrypted but no key is configured")
+
+// sealedPayload is the JSON envelope of an encrypted payload; the data
+// holds the ciphertext followed by the GCM tag
+type sealedPayload struct {
+	Encrypted string `json:"encrypted"`
+	IV        []byte `json:"iv"`
+	Data      []byte `json:"data"`
+}
+
+// PayloadCipher encrypts serialized payloads, such as queued or dead
+// lettered messages, so that whoever can read the broker or the file
+// cannot read the notifications they hold. Sealed payloads are JSON
+// objects, so they can replace a JSON payload in a JSON lines file or a
+// queue message.
+type PayloadCipher struct {
+	keys KeyProvider
+}
+
+// NewPayloadCipher creates a cipher with the key of a provider, which is
+// asked for it on every payload so that rotated keys are picked up
+func NewPayloadCipher(keys KeyProvider) *PayloadCipher {
+	return &PayloadCipher{keys: keys}
+}
+
+// Seal encrypts a payload
+func (c *PayloadCipher) Seal(ctx context.Context, plaintext []byte) ([]byte, error) {
+	key, err := c.keys.Key(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the payload encryption key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedPayload{
+		Encrypted: PayloadAlgorithm,
+		IV:        iv,
+		Data:      gcm.Seal(nil, iv, plaintext, []byte(PayloadAlgorithm)),
+	})
+}
+
+// Open decrypts a payload sealed by Seal. Payloads that are not sealed are
+// returned unchanged, so that stores written before encryption was enabled
+// stay readable.
+func (c *PayloadCipher) Open(ctx context.Context, payload []byte) ([]byte, error) {
+	sealed, ok, err := parseSealed(payload)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return payload, nil
+	}
+	key, err := c.keys.Key(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the payload encryption key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed.IV) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted payload: bad iv")
+	}
+	plaintext, err := gcm.Open(nil, sealed.IV, sealed.Data, []byte(PayloadAlgorithm))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: wrong key or corrupted payload")
+	}
+	return plaintext, nil
+}
+
+// IsSealed reports whether a payload was sealed by a PayloadCipher
+func IsSealed(payload []byte) bool {
+	_, ok, _ := parseSealed(payload)
+	return ok
+}
+
+// parseSealed decodes the envelope of a sealed payload, reporting false
+// for a payload without one: a JSON object with an encrypted field is an
+// envelope, and an error when it is not a valid one
+func parseSealed(payload []byte) (sealedPayload, bool, error) {
+	var sealed sealedPayload
+	var probe struct {
+		Encrypted string `json:"encrypted"`
+	}
+	if !bytes.Contains(payload, []byte(`"encrypted"`)) || json.Unmarshal(payload, &probe) != nil || probe.Encrypted == "" {
+		return sealed, false, nil
+	}
+	if probe.Encrypted != PayloadAlgorithm {
+		return sealed, true, fmt.Errorf("unsupported payload encryption %q", probe.Encrypted)
+	}
+	if err := json.Unmarshal(payload, &sealed); err != nil {
+		return sealed, true, fmt.Errorf("invalid encrypted payload: %w", err)
+	}
+	return sealed, true, nil
+}
@@ -0,0 +1,227 @@
+// Package secret resolves secret references in NotifyHub configuration.
+//
+// Configuration values may name a secret instead of containing it:
+//
+//	secret://vault/smtp#password    key "password" of secret "smtp" from the "vault" provider
+//	file:///run/secrets/smtp_pass   contents of a mounted secret file
+//	file:///run/secrets/smtp.json#password
+//
+// References are resolved by pluggable providers when the client builds its
+// platform senders, and again periodically so that rotated credentials are
+// picked up without a restart.
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Reference schemes
+const (
+	SchemeSecret = "secret://"
+	SchemeFile   = "file://"
+)
+
+// Provider fetches secrets from a secret store such as Vault or a KMS
+type Provider interface {
+	// Resolve returns the secret at path. Key selects one field of secrets
+	// that hold several, and is empty otherwise.
+	Resolve(ctx context.Context, path, key string) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface, e.g. to decrypt
+// KMS ciphertexts with a cloud SDK client
+type ProviderFunc func(ctx context.Context, path, key string) (string, error)
+
+// Resolve implements Provider
+func (f ProviderFunc) Resolve(ctx context.Context, path, key string) (string, error) {
+	return f(ctx, path, key)
+}
+
+// IsReference reports whether a configuration value is a secret reference
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, SchemeSecret) || strings.HasPrefix(value, SchemeFile)
+}
+
+// Resolver resolves secret references with registered providers. The file
+// provider is always available.
+type Resolver struct {
+	providers map[string]Provider
+	mu        sync.RWMutex
+}
+
+// NewResolver creates a resolver
+func NewResolver() *Resolver {
+	return &Resolver{providers: make(map[string]Provider)}
+}
+
+// Register makes a provider available as secret://name/...
+func (r *Resolver) Register(name string, provider Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[name] = provider
+}
+
+// Resolve returns the secret a reference names. Values that are not
+// references are returned unchanged.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, SchemeFile):
+		path, key, _ := strings.Cut(strings.TrimPrefix(value, SchemeFile), "#")
+		secret, err := readFile(path, key)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", value, err)
+		}
+		return secret, nil
+
+	case strings.HasPrefix(value, SchemeSecret):
+		rest, key, _ := strings.Cut(strings.TrimPrefix(value, SchemeSecret), "#")
+		name, path, ok := strings.Cut(rest, "/")
+		if !ok || name == "" || path == "" {
+			return "", fmt.Errorf("invalid secret reference %s: expected secret://provider/path#key", value)
+		}
+
+		r.mu.RLock()
+		provider, exists := r.providers[name]
+		r.mu.RUnlock()
+		if !exists {
+			return "", fmt.Errorf("failed to resolve %s: no secret provider %q", value, name)
+		}
+
+		secret, err := provider.Resolve(ctx, path, key)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", value, err)
+		}
+		return secret, nil
+
+	default:
+		return value, nil
+	}
+}
+
+// ResolveFields replaces the secret references in the string fields of the
+// struct v points to, including nested structs, string slices and string
+// maps. Maps and slices holding references are replaced, not modified, so
+// a shallow copy of a configuration can be resolved without changing the
+// original.
+func (r *Resolver) ResolveFields(ctx context.Context, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return fmt.Errorf("ResolveFields requires a non-nil pointer, got %T", v)
+	}
+	return r.resolveValue(ctx, value.Elem())
+}
+
+func (r *Resolver) resolveValue(ctx context.Context, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return r.resolveValue(ctx, v.Elem())
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := r.resolveValue(ctx, v.Field(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.String:
+		if !IsReference(v.String()) || !v.CanSet() {
+			return nil
+		}
+		secret, err := r.Resolve(ctx, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(secret)
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String || !containsReference(v) {
+			return nil
+		}
+		resolved := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			secret, err := r.Resolve(ctx, v.Index(i).String())
+			if err != nil {
+				return err
+			}
+			resolved.Index(i).SetString(secret)
+		}
+		v.Set(resolved)
+
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String || !containsReference(v) {
+			return nil
+		}
+		resolved := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			secret, err := r.Resolve(ctx, iter.Value().String())
+			if err != nil {
+				return err
+			}
+			resolved.SetMapIndex(iter.Key(), reflect.ValueOf(secret).Convert(v.Type().Elem()))
+		}
+		v.Set(resolved)
+	}
+	return nil
+}
+
+// containsReference reports whether a string slice or map holds a reference
+func containsReference(v reflect.Value) bool {
+	if v.Kind() == reflect.Map {
+		iter := v.MapRange()
+		for iter.Next() {
+			if IsReference(iter.Value().String()) {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < v.Len(); i++ {
+		if IsReference(v.Index(i).String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// readFile reads a secret file. Trailing newlines are trimmed; with a key,
+// the file must hold a JSON object and the key's value is returned.
+func readFile(path, key string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", fmt.Errorf("secret file is not a JSON object: %w", err)
+	}
+	return field(fields, key)
+}
+
+// field returns a string field of a secret with several fields
+func field(fields map[string]interface{}, key string) (string, error) {
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("secret key %q is not a string", key)
+	}
+	return s, nil
+}
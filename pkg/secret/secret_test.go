@@ -0,0 +1,230 @@
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "smtp_pass")
+	if err := os.WriteFile(plain, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	structured := filepath.Join(dir, "smtp.json")
+	if err := os.WriteFile(structured, []byte(`{"username": "mailer", "password": "hunter2"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := NewResolver()
+	resolver.Register("kms", ProviderFunc(func(ctx context.Context, path, key string) (string, error) {
+		if path != "ciphertext" {
+			return "", fmt.Errorf("cannot decrypt %s", path)
+		}
+		return "decrypted:" + key, nil
+	}))
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{"plain value", "not-a-secret", "not-a-secret", ""},
+		{"secret file", "file://" + plain, "s3cret", ""},
+		{"secret file key", "file://" + structured + "#password", "hunter2", ""},
+		{"missing file key", "file://" + structured + "#token", "", `no key "token"`},
+		{"missing file", "file://" + filepath.Join(dir, "missing"), "", "failed to resolve"},
+		{"provider", "secret://kms/ciphertext#field", "decrypted:field", ""},
+		{"provider error", "secret://kms/other", "", "cannot decrypt other"},
+		{"unknown provider", "secret://vault/smtp#password", "", `no secret provider "vault"`},
+		{"malformed reference", "secret://kms", "", "invalid secret reference"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.Resolve(context.Background(), tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolver_ResolveFields(t *testing.T) {
+	type auth struct {
+		Token string
+	}
+	type settings struct {
+		Password string
+		Headers  map[string]string
+		Keywords []string
+		Auth     *auth
+		Port     int
+		hidden   string
+	}
+
+	resolver := NewResolver()
+	resolver.Register("test", ProviderFunc(func(ctx context.Context, path, key string) (string, error) {
+		return "resolved-" + path, nil
+	}))
+
+	headers := map[string]string{"X-Api-Key": "secret://test/api-key", "Accept": "application/json"}
+	s := settings{
+		Password: "secret://test/password",
+		Headers:  headers,
+		Keywords: []string{"alert"},
+		Auth:     &auth{Token: "secret://test/token"},
+		Port:     587,
+		hidden:   "secret://test/hidden",
+	}
+	if err := resolver.ResolveFields(context.Background(), &s); err != nil {
+		t.Fatalf("ResolveFields() error = %v", err)
+	}
+
+	if s.Password != "resolved-password" || s.Auth.Token != "resolved-token" || s.Headers["X-Api-Key"] != "resolved-api-key" {
+		t.Errorf("ResolveFields() = %+v, want references resolved", s)
+	}
+	if s.Headers["Accept"] != "application/json" || s.Keywords[0] != "alert" || s.hidden != "secret://test/hidden" {
+		t.Errorf("ResolveFields() = %+v, want other values unchanged", s)
+	}
+	if headers["X-Api-Key"] != "secret://test/api-key" {
+		t.Error("ResolveFields() must not modify a map shared with the original")
+	}
+
+	if err := resolver.ResolveFields(context.Background(), s); err == nil {
+		t.Error("ResolveFields() expected error for a non-pointer")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/notifyhub/smtp":
+			fmt.Fprint(w, `{"data": {"data": {"username": "mailer", "password": "hunter2"}}}`)
+		case "/v1/kv/data/notifyhub/feishu":
+			fmt.Fprint(w, `{"data": {"data": {"secret": "sign-key"}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	vault, err := NewVaultProvider(VaultConfig{Address: server.URL, Token: "root", Mount: "kv"})
+	if err != nil {
+		t.Fatalf("NewVaultProvider() error = %v", err)
+	}
+	resolver := NewResolver()
+	resolver.Register("vault", vault)
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"secret://vault/notifyhub/smtp#password", "hunter2", false},
+		{"secret://vault/notifyhub/feishu", "sign-key", false},
+		{"secret://vault/notifyhub/smtp", "", true},
+		{"secret://vault/notifyhub/missing#password", "", true},
+	}
+	for _, tt := range tests {
+		got, err := resolver.Resolve(context.Background(), tt.ref)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Resolve(%s) = %q, %v; want %q, error %v", tt.ref, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if _, err := NewVaultProvider(VaultConfig{Address: server.URL}); err == nil && os.Getenv("VAULT_TOKEN") == "" {
+		t.Error("NewVaultProvider() expected error without a token")
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := GenerateKey()
+
+	value, err := Encrypt(key, "webhook.token", "s3cret")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEncrypted(value) || strings.Contains(value, "s3cret") {
+		t.Fatalf("Encrypt() = %q", value)
+	}
+
+	if plaintext, err := Decrypt(key, "webhook.token", value); err != nil || plaintext != "s3cret" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+	if _, err := Decrypt(key, "email.password", value); err == nil {
+		t.Error("Decrypt() for another setting error = nil")
+	}
+	if _, err := Decrypt(other, "webhook.token", value); err == nil {
+		t.Error("Decrypt() with another key error = nil")
+	}
+	if _, err := Encrypt(key[:16], "webhook.token", "s3cret"); err == nil {
+		t.Error("Encrypt() with a short key error = nil")
+	}
+
+	t.Setenv("NOTIFYHUB_TEST_KEY", base64.StdEncoding.EncodeToString(key))
+	if got, err := KeyFromEnv("NOTIFYHUB_TEST_KEY").Key(context.Background()); err != nil || string(got) != string(key) {
+		t.Errorf("KeyFromEnv() = %x, %v", got, err)
+	}
+}
+
+func TestPayloadCipher(t *testing.T) {
+	key, _ := GenerateKey()
+	other, _ := GenerateKey()
+	ctx := context.Background()
+	c := NewPayloadCipher(StaticKey(key))
+	plaintext := []byte(`{"title":"Password reset","body":"code 123456"}`)
+
+	sealed, err := c.Seal(ctx, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || strings.Contains(string(sealed), "123456") {
+		t.Fatalf("Seal() = %s, want an encrypted envelope", sealed)
+	}
+	if again, _ := c.Seal(ctx, plaintext); string(again) == string(sealed) {
+		t.Error("Seal() reused its iv")
+	}
+	if got, err := c.Open(ctx, sealed); err != nil || string(got) != string(plaintext) {
+		t.Errorf("Open() = %s, %v, want %s", got, err, plaintext)
+	}
+
+	if got, err := c.Open(ctx, plaintext); err != nil || string(got) != string(plaintext) || IsSealed(plaintext) {
+		t.Errorf("Open() of a plain payload = %s, %v, want it unchanged", got, err)
+	}
+	if _, err := NewPayloadCipher(StaticKey(other)).Open(ctx, sealed); err == nil {
+		t.Error("Open() with another key error = nil")
+	}
+	tampered := []byte(strings.Replace(string(sealed), `"data":"`, `"data":"AA`, 1))
+	if _, err := c.Open(ctx, tampered); err == nil {
+		t.Error("Open() of a tampered payload error = nil")
+	}
+	if _, err := NewPayloadCipher(StaticKey([]byte("short"))).Seal(ctx, plaintext); err == nil {
+		t.Error("Seal() with a short key error = nil")
+	}
+}
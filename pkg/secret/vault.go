@@ -0,0 +1,108 @@
+// Package secret provides a HashiCorp Vault secret provider
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultConfig configures a Vault provider
+type VaultConfig struct {
+	Address string       // e.g. "https://vault.example.com:8200", defaults to VAULT_ADDR
+	Token   string       // defaults to VAULT_TOKEN
+	Mount   string       // KV version 2 mount, defaults to "secret"
+	Client  *http.Client // defaults to a client with a 10 second timeout
+}
+
+// VaultProvider reads secrets from a Vault KV version 2 engine:
+// secret://vault/smtp#password reads the "password" field of the secret at
+// "smtp" in the configured mount.
+type VaultProvider struct {
+	address string
+	token   string
+	mount   string
+	client  *http.Client
+}
+
+// NewVaultProvider creates a Vault provider
+func NewVaultProvider(cfg VaultConfig) (*VaultProvider, error) {
+	if cfg.Address == "" {
+		cfg.Address = os.Getenv("VAULT_ADDR")
+	}
+	if cfg.Token == "" {
+		cfg.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("vault token is required")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "secret"
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &VaultProvider{
+		address: strings.TrimRight(cfg.Address, "/"),
+		token:   cfg.Token,
+		mount:   strings.Trim(cfg.Mount, "/"),
+		client:  cfg.Client,
+	}, nil
+}
+
+// Resolve implements Provider. Without a key, the secret must have exactly
+// one field.
+func (v *VaultProvider) Resolve(ctx context.Context, path, key string) (string, error) {
+	endpoint := v.address + "/v1/" + url.PathEscape(v.mount) + "/data/" + escapePath(strings.Trim(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+
+	fields := body.Data.Data
+	if key == "" {
+		if len(fields) != 1 {
+			return "", fmt.Errorf("secret has %d keys, the reference must name one", len(fields))
+		}
+		for k := range fields {
+			key = k
+		}
+	}
+	return field(fields, key)
+}
+
+// escapePath escapes each segment of a secret path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
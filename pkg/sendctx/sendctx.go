@@ -0,0 +1,164 @@
+// Package sendctx defines the context values a send carries: the request
+// that caused it, the tenant it is made for, the user who made it and the
+// application, or API key, it was made through.
+//
+// Callers set them on the context of a send:
+//
+//	ctx = sendctx.WithRequestID(ctx, r.Header.Get("X-Request-ID"))
+//	receipt, err := client.Send(ctx, msg)
+//
+// The client attaches them to the message metadata (see
+// message.MetadataRequestID, MetadataTenant, MetadataUser and MetadataApp),
+// so that they survive asynchronous sends, and adds them to its logs, to
+// audit entries and to the context of platform sends and enrichers. Values
+// the message already carries take precedence over those of the context.
+package sendctx
+
+import (
+	"context"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// Values are the context values of a send
+type Values struct {
+	RequestID string
+	Tenant    string
+	User      string
+	App       string
+}
+
+// valuesKey holds the Values of a context
+type valuesKey struct{}
+
+// With returns a context carrying the non-empty fields of v, over those
+// ctx already carries
+func With(ctx context.Context, v Values) context.Context {
+	merged := From(ctx).merge(v)
+	if merged == From(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, valuesKey{}, merged)
+}
+
+// WithRequestID returns a context carrying a request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return With(ctx, Values{RequestID: requestID})
+}
+
+// WithTenant returns a context carrying a tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return With(ctx, Values{Tenant: tenant})
+}
+
+// WithUser returns a context carrying a user
+func WithUser(ctx context.Context, user string) context.Context {
+	return With(ctx, Values{User: user})
+}
+
+// WithApp returns a context carrying the application, or API key, a send
+// is made through
+func WithApp(ctx context.Context, app string) context.Context {
+	return With(ctx, Values{App: app})
+}
+
+// From returns the values of a context
+func From(ctx context.Context) Values {
+	v, _ := ctx.Value(valuesKey{}).(Values)
+	return v
+}
+
+// FromMessage returns the values held in the metadata of a message
+func FromMessage(msg *message.Message) Values {
+	v := Values{Tenant: msg.Tenant()}
+	v.RequestID, _ = msg.Metadata[message.MetadataRequestID].(string)
+	v.User, _ = msg.Metadata[message.MetadataUser].(string)
+	v.App, _ = msg.Metadata[message.MetadataApp].(string)
+	return v
+}
+
+// IsZero reports whether no value is set
+func (v Values) IsZero() bool {
+	return v == Values{}
+}
+
+// Attach returns a copy of the message with the values set in its
+// metadata where it has none, or the message itself when it already has
+// them all
+func (v Values) Attach(msg *message.Message) *message.Message {
+	if v.merge(FromMessage(msg)) == FromMessage(msg) {
+		return msg
+	}
+	m := msg.Clone()
+	v.Fill(m)
+	return m
+}
+
+// Fill sets the values in the metadata of the message where it has none,
+// changing the message itself; see Attach to leave it unchanged
+func (v Values) Fill(msg *message.Message) {
+	missing := v.merge(FromMessage(msg))
+	if missing == FromMessage(msg) {
+		return
+	}
+	for key, value := range missing.Metadata() {
+		msg.SetMetadata(key, value)
+	}
+}
+
+// Metadata returns the non-empty values by their message metadata key, or
+// nil when none is set
+func (v Values) Metadata() map[string]string {
+	if v.IsZero() {
+		return nil
+	}
+	metadata := make(map[string]string, 4)
+	if v.RequestID != "" {
+		metadata[message.MetadataRequestID] = v.RequestID
+	}
+	if v.Tenant != "" {
+		metadata[message.MetadataTenant] = v.Tenant
+	}
+	if v.User != "" {
+		metadata[message.MetadataUser] = v.User
+	}
+	if v.App != "" {
+		metadata[message.MetadataApp] = v.App
+	}
+	return metadata
+}
+
+// LogFields returns the non-empty values as structured log key-value pairs
+func (v Values) LogFields() []any {
+	var fields []any
+	if v.RequestID != "" {
+		fields = append(fields, "request_id", v.RequestID)
+	}
+	if v.Tenant != "" {
+		fields = append(fields, "tenant", v.Tenant)
+	}
+	if v.User != "" {
+		fields = append(fields, "user", v.User)
+	}
+	if v.App != "" {
+		fields = append(fields, "app", v.App)
+	}
+	return fields
+}
+
+// merge returns base with its empty fields filled from v
+func (v Values) merge(base Values) Values {
+	if base.RequestID == "" {
+		base.RequestID = v.RequestID
+	}
+	if base.Tenant == "" {
+		base.Tenant = v.Tenant
+	}
+	if base.User == "" {
+		base.User = v.User
+	}
+	if base.App == "" {
+		base.App = v.App
+	}
+	return base
+}
@@ -0,0 +1,82 @@
+package sendctx
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+func TestWith(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want Values
+	}{
+		{name: "empty", ctx: context.Background(), want: Values{}},
+		{name: "request ID", ctx: WithRequestID(context.Background(), "req-1"), want: Values{RequestID: "req-1"}},
+		{name: "accumulated", ctx: WithUser(WithTenant(WithRequestID(context.Background(), "req-1"), "acme"), "alice"), want: Values{RequestID: "req-1", Tenant: "acme", User: "alice"}},
+		{name: "app", ctx: WithApp(WithTenant(context.Background(), "acme"), "billing"), want: Values{Tenant: "acme", App: "billing"}},
+		{name: "later value wins", ctx: WithTenant(WithTenant(context.Background(), "acme"), "globex"), want: Values{Tenant: "globex"}},
+		{name: "empty value keeps earlier", ctx: WithRequestID(WithRequestID(context.Background(), "req-1"), ""), want: Values{RequestID: "req-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := From(tt.ctx); got != tt.want {
+				t.Errorf("From() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValues_Attach(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    Values
+		metadata  map[string]interface{}
+		want      Values
+		wantClone bool
+	}{
+		{name: "no values", values: Values{}, want: Values{}},
+		{name: "attached", values: Values{RequestID: "req-1", User: "alice"}, want: Values{RequestID: "req-1", User: "alice"}, wantClone: true},
+		{name: "message values take precedence", values: Values{RequestID: "req-1", Tenant: "acme"}, metadata: map[string]interface{}{message.MetadataTenant: "globex"}, want: Values{RequestID: "req-1", Tenant: "globex"}, wantClone: true},
+		{name: "already attached", values: Values{Tenant: "acme"}, metadata: map[string]interface{}{message.MetadataTenant: "globex"}, want: Values{Tenant: "globex"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := message.New()
+			for key, value := range tt.metadata {
+				msg.SetMetadata(key, value)
+			}
+			got := tt.values.Attach(msg)
+			if values := FromMessage(got); values != tt.want {
+				t.Errorf("Attach() values = %+v, want %+v", values, tt.want)
+			}
+			if (got != msg) != tt.wantClone {
+				t.Errorf("Attach() cloned the message = %v, want %v", got != msg, tt.wantClone)
+			}
+			if len(msg.Metadata) != len(tt.metadata) {
+				t.Errorf("Attach() changed the original metadata to %v", msg.Metadata)
+			}
+
+			tt.values.Fill(msg)
+			if values := FromMessage(msg); values != tt.want {
+				t.Errorf("Fill() values = %+v, want %+v", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestValues_Fields(t *testing.T) {
+	v := Values{RequestID: "req-1", User: "alice", App: "billing"}
+	if got, want := v.Metadata(), map[string]string{message.MetadataRequestID: "req-1", message.MetadataUser: "alice", message.MetadataApp: "billing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Metadata() = %v, want %v", got, want)
+	}
+	if got, want := v.LogFields(), []any{"request_id", "req-1", "user", "alice", "app", "billing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LogFields() = %v, want %v", got, want)
+	}
+	if (Values{}).Metadata() != nil || (Values{}).LogFields() != nil {
+		t.Error("empty values have metadata or log fields")
+	}
+}
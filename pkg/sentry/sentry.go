@@ -0,0 +1,222 @@
+// Package sentry provides the Sentry webhook formats for NotifyHub: issue
+// alerts of the integration platform (event_alert), issue events and the
+// legacy webhooks plugin convert to messages, which the HTTP server
+// (package server/http) receives.
+package sentry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/alertmanager"
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+// HeaderSignature is the header of the signature of integration platform
+// webhooks
+const HeaderSignature = "Sentry-Hook-Signature"
+
+// ErrInvalidSignature is returned by Verify for an unsigned request or one
+// signed with another secret
+var ErrInvalidSignature = errors.New("invalid sentry signature")
+
+// Payload is the body of a Sentry webhook: an integration platform webhook
+// has an action and data, a legacy plugin webhook the issue at the top
+// level
+type Payload struct {
+	// Integration platform
+	Action string `json:"action"`
+	Data   struct {
+		Event         *Event `json:"event"`
+		Issue         *Issue `json:"issue"`
+		TriggeredRule string `json:"triggered_rule"`
+	} `json:"data"`
+
+	// Legacy webhooks plugin
+	ID              string   `json:"id"`
+	Project         string   `json:"project"`
+	ProjectName     string   `json:"project_name"`
+	Level           string   `json:"level"`
+	Culprit         string   `json:"culprit"`
+	Message         string   `json:"message"`
+	URL             string   `json:"url"`
+	TriggeringRules []string `json:"triggering_rules"`
+	Event           *Event   `json:"event"`
+}
+
+// Event is the event of an issue alert
+type Event struct {
+	IssueID     string      `json:"issue_id"`
+	Project     interface{} `json:"project"` // ID or slug
+	Title       string      `json:"title"`
+	Culprit     string      `json:"culprit"`
+	Level       string      `json:"level"`
+	Environment string      `json:"environment"`
+	WebURL      string      `json:"web_url"`
+	Tags        [][]string  `json:"tags"` // name and value pairs
+}
+
+// Issue is the issue of an issue webhook
+type Issue struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Culprit   string `json:"culprit"`
+	Level     string `json:"level"`
+	Status    string `json:"status"`
+	Permalink string `json:"permalink"`
+	WebURL    string `json:"web_url"`
+	Project   struct {
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	} `json:"project"`
+}
+
+// alert is what a webhook reports, whatever its format
+type alert struct {
+	issueID, project, title, level, culprit, environment, rule, link string
+	resolved                                                         bool
+}
+
+// alert returns what the webhook reports
+func (p *Payload) alert() alert {
+	switch {
+	case p.Data.Event != nil:
+		e := p.Data.Event
+		return alert{
+			issueID:     e.IssueID,
+			project:     e.tag("project", e.project()),
+			title:       e.Title,
+			level:       e.Level,
+			culprit:     e.Culprit,
+			environment: e.tag("environment", e.Environment),
+			rule:        p.Data.TriggeredRule,
+			link:        e.WebURL,
+		}
+	case p.Data.Issue != nil:
+		i := p.Data.Issue
+		link := i.WebURL
+		if link == "" {
+			link = i.Permalink
+		}
+		return alert{
+			issueID:  i.ID,
+			project:  i.Project.Slug,
+			title:    i.Title,
+			level:    i.Level,
+			culprit:  i.Culprit,
+			link:     link,
+			resolved: p.Action == "resolved" || i.Status == "resolved",
+		}
+	}
+
+	a := alert{
+		issueID: p.ID,
+		project: p.Project,
+		title:   p.Message,
+		level:   p.Level,
+		culprit: p.Culprit,
+		rule:    strings.Join(p.TriggeringRules, ", "),
+		link:    p.URL,
+	}
+	if a.project == "" {
+		a.project = p.ProjectName
+	}
+	if p.Event != nil {
+		if p.Event.Title != "" {
+			a.title = p.Event.Title
+		}
+		a.environment = p.Event.tag("environment", p.Event.Environment)
+	}
+	return a
+}
+
+// tag returns the value of an event tag, or def without it
+func (e *Event) tag(name, def string) string {
+	for _, tag := range e.Tags {
+		if len(tag) == 2 && tag[0] == name && tag[1] != "" {
+			return tag[1]
+		}
+	}
+	return def
+}
+
+// project returns the project of an event, its slug or ID
+func (e *Event) project() string {
+	switch project := e.Project.(type) {
+	case string:
+		return project
+	case float64:
+		return strconv.FormatFloat(project, 'f', -1, 64)
+	}
+	return ""
+}
+
+// Valid reports whether the webhook reports an issue
+func (p *Payload) Valid() bool {
+	a := p.alert()
+	return a.issueID != "" && a.title != ""
+}
+
+// Messages returns the message of a webhook: the issue title, prefixed
+// with its project, as title, and the level, culprit, environment, rule
+// and a link to the issue as body. Its priority follows the level, its
+// labels are project, level, environment and issue_id, and its alert group
+// is the issue, so that the events of an issue can be told apart from new
+// issues. Resolving the issue resolves the alert.
+func (p *Payload) Messages() []*message.Message {
+	a := p.alert()
+
+	title := a.title
+	if a.project != "" {
+		title = "[" + a.project + "] " + title
+	}
+	if a.resolved {
+		title = "[RESOLVED] " + title
+	}
+	var lines []string
+	for _, field := range [][2]string{
+		{"Level", a.level},
+		{"Culprit", a.culprit},
+		{"Environment", a.environment},
+		{"Rule", a.rule},
+		{"Link", a.link},
+	} {
+		if field[1] != "" {
+			lines = append(lines, field[0]+": "+field[1])
+		}
+	}
+
+	labels := map[string]string{"issue_id": a.issueID}
+	for name, value := range map[string]string{"project": a.project, "level": a.level, "environment": a.environment} {
+		if value != "" {
+			labels[name] = value
+		}
+	}
+	status := message.AlertFiring
+	if a.resolved {
+		status = message.AlertResolved
+	}
+
+	msg := message.New().SetTitle(title).SetBody(strings.Join(lines, "\n")).SetPriority(alertmanager.Priority(a.level))
+	msg.SetAlertStatus(status).SetAlertLabels(labels).SetAlertGroup("sentry/issue/" + a.issueID)
+	return []*message.Message{msg}
+}
+
+// Verify checks the Sentry-Hook-Signature of an integration platform
+// webhook, the hex HMAC-SHA256 of the body keyed with the client secret
+func Verify(signature string, body []byte, secret string) error {
+	if signature == "" {
+		return fmt.Errorf("%w: missing %s header", ErrInvalidSignature, HeaderSignature)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package sentry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+)
+
+func TestPayload_Messages(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		title    string
+		body     string
+		priority message.Priority
+		status   string
+		labels   map[string]string
+	}{
+		{
+			name: "issue alert",
+			payload: `{"action": "triggered", "data": {"triggered_rule": "New issues", "event": {
+				"issue_id": "42", "project": 7, "title": "ZeroDivisionError: division by zero",
+				"culprit": "billing.invoice in total", "level": "fatal", "environment": "staging",
+				"web_url": "https://sentry.io/organizations/acme/issues/42/events/abc/",
+				"tags": [["environment", "prod"], ["level", "fatal"]]}}}`,
+			title:    "[7] ZeroDivisionError: division by zero",
+			body:     "Level: fatal\nCulprit: billing.invoice in total\nEnvironment: prod\nRule: New issues\nLink: https://sentry.io/organizations/acme/issues/42/events/abc/",
+			priority: message.PriorityUrgent,
+			status:   message.AlertFiring,
+			labels:   map[string]string{"issue_id": "42", "project": "7", "level": "fatal", "environment": "prod"},
+		},
+		{
+			name: "issue resolved",
+			payload: `{"action": "resolved", "data": {"issue": {
+				"id": "42", "title": "ZeroDivisionError", "culprit": "billing.invoice in total", "level": "error",
+				"status": "resolved", "permalink": "https://sentry.io/organizations/acme/issues/42/",
+				"project": {"slug": "billing", "name": "Billing"}}}}`,
+			title:    "[RESOLVED] [billing] ZeroDivisionError",
+			body:     "Level: error\nCulprit: billing.invoice in total\nLink: https://sentry.io/organizations/acme/issues/42/",
+			priority: message.PriorityHigh,
+			status:   message.AlertResolved,
+			labels:   map[string]string{"issue_id": "42", "project": "billing", "level": "error"},
+		},
+		{
+			name: "legacy plugin",
+			payload: `{"id": "42", "project": "billing", "project_name": "Billing", "level": "warning",
+				"culprit": "billing.invoice in total", "message": "Slow invoice",
+				"url": "https://sentry.io/acme/billing/issues/42/", "triggering_rules": ["Slow", "Billing"],
+				"event": {"title": "Slow invoice run", "tags": [["environment", "prod"]]}}`,
+			title:    "[billing] Slow invoice run",
+			body:     "Level: warning\nCulprit: billing.invoice in total\nEnvironment: prod\nRule: Slow, Billing\nLink: https://sentry.io/acme/billing/issues/42/",
+			priority: message.PriorityNormal,
+			status:   message.AlertFiring,
+			labels:   map[string]string{"issue_id": "42", "project": "billing", "level": "warning", "environment": "prod"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload Payload
+			if err := json.Unmarshal([]byte(tt.payload), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if !payload.Valid() {
+				t.Fatalf("Valid() = false")
+			}
+			msg := payload.Messages()[0]
+			if msg.Title != tt.title || msg.Body != tt.body || msg.Priority != tt.priority {
+				t.Errorf("message = %q %q %d, want %q %q %d", msg.Title, msg.Body, msg.Priority, tt.title, tt.body, tt.priority)
+			}
+			if msg.AlertStatus() != tt.status || msg.AlertGroup() != "sentry/issue/42" || !reflect.DeepEqual(msg.AlertLabels(), tt.labels) {
+				t.Errorf("alert = %s %q %v, want %s %v", msg.AlertStatus(), msg.AlertGroup(), msg.AlertLabels(), tt.status, tt.labels)
+			}
+		})
+	}
+
+	var empty Payload
+	if err := json.Unmarshal([]byte(`{"action": "created", "data": {"installation": {}}}`), &empty); err != nil {
+		t.Fatal(err)
+	}
+	if empty.Valid() {
+		t.Errorf("Valid() = true for a webhook without an issue")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"action": "triggered"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	if err := Verify(signature, body, "secret"); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	for name, err := range map[string]error{
+		"unsigned":     Verify("", body, "secret"),
+		"other secret": Verify(signature, body, "other"),
+		"other body":   Verify(signature, []byte(`{}`), "secret"),
+	} {
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: Verify() error = %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package grpc serves the NotifyHub API over gRPC for services written in
+// other languages, which use the hub as a sidecar or central notification
+// service. Its services, defined in notifyhub.proto, mirror server.Service:
+// NotifyHubService its send, status, health and receipt methods, and
+// NotifyHubAdminService its admin methods.
+//
+//	service := server.NewService(client)
+//	srv := grpc.NewServer(notifyhubgrpc.BearerTokens("s3cret")...)
+//	notifyhubgrpc.Register(srv, service)
+//	srv.Serve(listener)
+//
+// Messages are validated like those of the REST server (pkg/server/http),
+// receipts show target addresses masked, and errors map to gRPC codes:
+// InvalidArgument for invalid messages, ResourceExhausted over a usage
+// quota, NotFound for unknown jobs. Serve the admin service (RegisterAdmin)
+// to incident responders only, on a server of its own or behind other
+// credentials.
+//
+// The package is a module of its own, so that the root module keeps no
+// dependencies. The bindings are generated from notifyhub.proto with buf
+// generate (see buf.gen.yaml); CI lints the definition and checks that the
+// bindings are up to date.
+package grpc
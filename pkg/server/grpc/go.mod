@@ -0,0 +1,19 @@
+module github.com/kart-io/notifyhub/pkg/server/grpc
+
+go 1.23.0
+
+// 使用本地 NotifyHub 包进行开发
+replace github.com/kart-io/notifyhub => ../../../
+
+require (
+	github.com/kart-io/notifyhub v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.75.1
+	google.golang.org/protobuf v1.36.6
+)
+
+require (
+	golang.org/x/net v0.41.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.26.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+)
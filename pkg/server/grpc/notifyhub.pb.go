@@ -0,0 +1,1629 @@
+// The NotifyHub API for services that use the hub as a sidecar or central
+// notification service, mirroring server.Service. The Go bindings are
+// generated with buf generate (see buf.gen.yaml).
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: notifyhub.proto
+
+package grpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Target struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`         // "email", "user", "group", "channel", "webhook", ...
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`       // address or ID
+	Platform      string                 `protobuf:"bytes,3,opt,name=platform,proto3" json:"platform,omitempty"` // empty to choose by type
+	Timezone      string                 `protobuf:"bytes,4,opt,name=timezone,proto3" json:"timezone,omitempty"` // IANA time zone for delivery windows
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Target) Reset() {
+	*x = Target{}
+	mi := &file_notifyhub_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Target) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Target) ProtoMessage() {}
+
+func (x *Target) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Target.ProtoReflect.Descriptor instead.
+func (*Target) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Target) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Target) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *Target) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *Target) GetTimezone() string {
+	if x != nil {
+		return x.Timezone
+	}
+	return ""
+}
+
+type Message struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"` // generated when empty
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Body          string                 `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	Format        string                 `protobuf:"bytes,4,opt,name=format,proto3" json:"format,omitempty"`            // "text", "markdown" or "html"
+	Priority      *int32                 `protobuf:"varint,5,opt,name=priority,proto3,oneof" json:"priority,omitempty"` // 0 (low) to 3 (urgent), normal when unset
+	Targets       []*Target              `protobuf:"bytes,6,rep,name=targets,proto3" json:"targets,omitempty"`
+	Metadata      *structpb.Struct       `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Variables     *structpb.Struct       `protobuf:"bytes,8,opt,name=variables,proto3" json:"variables,omitempty"`
+	ScheduledAt   *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=scheduled_at,json=scheduledAt,proto3" json:"scheduled_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	mi := &file_notifyhub_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Message) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Message) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Message) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Message) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *Message) GetPriority() int32 {
+	if x != nil && x.Priority != nil {
+		return *x.Priority
+	}
+	return 0
+}
+
+func (x *Message) GetTargets() []*Target {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+func (x *Message) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Message) GetVariables() *structpb.Struct {
+	if x != nil {
+		return x.Variables
+	}
+	return nil
+}
+
+func (x *Message) GetScheduledAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ScheduledAt
+	}
+	return nil
+}
+
+type SendRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       *Message               `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendRequest) Reset() {
+	*x = SendRequest{}
+	mi := &file_notifyhub_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendRequest) ProtoMessage() {}
+
+func (x *SendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendRequest.ProtoReflect.Descriptor instead.
+func (*SendRequest) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SendRequest) GetMessage() *Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+type SendResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Receipt       *Receipt               `protobuf:"bytes,1,opt,name=receipt,proto3" json:"receipt,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendResponse) Reset() {
+	*x = SendResponse{}
+	mi := &file_notifyhub_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendResponse) ProtoMessage() {}
+
+func (x *SendResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendResponse.ProtoReflect.Descriptor instead.
+func (*SendResponse) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SendResponse) GetReceipt() *Receipt {
+	if x != nil {
+		return x.Receipt
+	}
+	return nil
+}
+
+type SendAsyncRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       *Message               `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendAsyncRequest) Reset() {
+	*x = SendAsyncRequest{}
+	mi := &file_notifyhub_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendAsyncRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendAsyncRequest) ProtoMessage() {}
+
+func (x *SendAsyncRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendAsyncRequest.ProtoReflect.Descriptor instead.
+func (*SendAsyncRequest) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SendAsyncRequest) GetMessage() *Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+type SendAsyncResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendAsyncResponse) Reset() {
+	*x = SendAsyncResponse{}
+	mi := &file_notifyhub_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendAsyncResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendAsyncResponse) ProtoMessage() {}
+
+func (x *SendAsyncResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendAsyncResponse.ProtoReflect.Descriptor instead.
+func (*SendAsyncResponse) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SendAsyncResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Wait          bool                   `protobuf:"varint,2,opt,name=wait,proto3" json:"wait,omitempty"` // wait for the job to finish, bounded by the call deadline
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_notifyhub_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StatusRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *StatusRequest) GetWait() bool {
+	if x != nil {
+		return x.Wait
+	}
+	return false
+}
+
+type StatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Job           *Job                   `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusResponse) Reset() {
+	*x = StatusResponse{}
+	mi := &file_notifyhub_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusResponse) ProtoMessage() {}
+
+func (x *StatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StatusResponse) GetJob() *Job {
+	if x != nil {
+		return x.Job
+	}
+	return nil
+}
+
+type Job struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"` // "pending", "processing", "completed", "failed" or "cancelled"
+	Receipt       *Receipt               `protobuf:"bytes,3,opt,name=receipt,proto3" json:"receipt,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	SubmittedAt   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=submitted_at,json=submittedAt,proto3" json:"submitted_at,omitempty"`
+	FinishedAt    *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_notifyhub_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Job) GetReceipt() *Receipt {
+	if x != nil {
+		return x.Receipt
+	}
+	return nil
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetSubmittedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SubmittedAt
+	}
+	return nil
+}
+
+func (x *Job) GetFinishedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return nil
+}
+
+type HealthRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthRequest) Reset() {
+	*x = HealthRequest{}
+	mi := &file_notifyhub_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthRequest) ProtoMessage() {}
+
+func (x *HealthRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthRequest.ProtoReflect.Descriptor instead.
+func (*HealthRequest) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{9}
+}
+
+type HealthResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"` // "healthy", "degraded" or "unhealthy"
+	Platforms     map[string]string      `protobuf:"bytes,2,rep,name=platforms,proto3" json:"platforms,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	UptimeSeconds float64                `protobuf:"fixed64,3,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	ActiveTasks   int64                  `protobuf:"varint,4,opt,name=active_tasks,json=activeTasks,proto3" json:"active_tasks,omitempty"`
+	QueueDepth    int64                  `protobuf:"varint,5,opt,name=queue_depth,json=queueDepth,proto3" json:"queue_depth,omitempty"`
+	TotalSent     int64                  `protobuf:"varint,6,opt,name=total_sent,json=totalSent,proto3" json:"total_sent,omitempty"`
+	SuccessRate   float64                `protobuf:"fixed64,7,opt,name=success_rate,json=successRate,proto3" json:"success_rate,omitempty"`
+	ConfigVersion string                 `protobuf:"bytes,8,opt,name=config_version,json=configVersion,proto3" json:"config_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthResponse) Reset() {
+	*x = HealthResponse{}
+	mi := &file_notifyhub_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthResponse) ProtoMessage() {}
+
+func (x *HealthResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthResponse.ProtoReflect.Descriptor instead.
+func (*HealthResponse) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *HealthResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *HealthResponse) GetPlatforms() map[string]string {
+	if x != nil {
+		return x.Platforms
+	}
+	return nil
+}
+
+func (x *HealthResponse) GetUptimeSeconds() float64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *HealthResponse) GetActiveTasks() int64 {
+	if x != nil {
+		return x.ActiveTasks
+	}
+	return 0
+}
+
+func (x *HealthResponse) GetQueueDepth() int64 {
+	if x != nil {
+		return x.QueueDepth
+	}
+	return 0
+}
+
+func (x *HealthResponse) GetTotalSent() int64 {
+	if x != nil {
+		return x.TotalSent
+	}
+	return 0
+}
+
+func (x *HealthResponse) GetSuccessRate() float64 {
+	if x != nil {
+		return x.SuccessRate
+	}
+	return 0
+}
+
+func (x *HealthResponse) GetConfigVersion() string {
+	if x != nil {
+		return x.ConfigVersion
+	}
+	return ""
+}
+
+type WatchReceiptsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchReceiptsRequest) Reset() {
+	*x = WatchReceiptsRequest{}
+	mi := &file_notifyhub_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchReceiptsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchReceiptsRequest) ProtoMessage() {}
+
+func (x *WatchReceiptsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchReceiptsRequest.ProtoReflect.Descriptor instead.
+func (*WatchReceiptsRequest) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{11}
+}
+
+type WatchReceiptsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Receipt       *Receipt               `protobuf:"bytes,1,opt,name=receipt,proto3" json:"receipt,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchReceiptsResponse) Reset() {
+	*x = WatchReceiptsResponse{}
+	mi := &file_notifyhub_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchReceiptsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchReceiptsResponse) ProtoMessage() {}
+
+func (x *WatchReceiptsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchReceiptsResponse.ProtoReflect.Descriptor instead.
+func (*WatchReceiptsResponse) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *WatchReceiptsResponse) GetReceipt() *Receipt {
+	if x != nil {
+		return x.Receipt
+	}
+	return nil
+}
+
+type Receipt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Results       []*PlatformResult      `protobuf:"bytes,3,rep,name=results,proto3" json:"results,omitempty"`
+	Successful    int32                  `protobuf:"varint,4,opt,name=successful,proto3" json:"successful,omitempty"`
+	Failed        int32                  `protobuf:"varint,5,opt,name=failed,proto3" json:"failed,omitempty"`
+	Skipped       int32                  `protobuf:"varint,6,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	Held          int32                  `protobuf:"varint,7,opt,name=held,proto3" json:"held,omitempty"`
+	Total         int32                  `protobuf:"varint,8,opt,name=total,proto3" json:"total,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Receipt) Reset() {
+	*x = Receipt{}
+	mi := &file_notifyhub_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Receipt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Receipt) ProtoMessage() {}
+
+func (x *Receipt) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Receipt.ProtoReflect.Descriptor instead.
+func (*Receipt) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Receipt) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *Receipt) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Receipt) GetResults() []*PlatformResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *Receipt) GetSuccessful() int32 {
+	if x != nil {
+		return x.Successful
+	}
+	return 0
+}
+
+func (x *Receipt) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *Receipt) GetSkipped() int32 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *Receipt) GetHeld() int32 {
+	if x != nil {
+		return x.Held
+	}
+	return 0
+}
+
+func (x *Receipt) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Receipt) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type PlatformResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Platform      string                 `protobuf:"bytes,1,opt,name=platform,proto3" json:"platform,omitempty"`
+	Target        string                 `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	Success       bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
+	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"` // why the target was not delivered, e.g. "suppressed"
+	MessageId     string                 `protobuf:"bytes,5,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Error         string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	HeldUntil     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=held_until,json=heldUntil,proto3" json:"held_until,omitempty"`
+	Timeout       *durationpb.Duration   `protobuf:"bytes,8,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlatformResult) Reset() {
+	*x = PlatformResult{}
+	mi := &file_notifyhub_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlatformResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlatformResult) ProtoMessage() {}
+
+func (x *PlatformResult) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlatformResult.ProtoReflect.Descriptor instead.
+func (*PlatformResult) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PlatformResult) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *PlatformResult) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *PlatformResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *PlatformResult) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *PlatformResult) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *PlatformResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *PlatformResult) GetHeldUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.HeldUntil
+	}
+	return nil
+}
+
+func (x *PlatformResult) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+func (x *PlatformResult) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type ControlsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ControlsRequest) Reset() {
+	*x = ControlsRequest{}
+	mi := &file_notifyhub_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ControlsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ControlsRequest) ProtoMessage() {}
+
+func (x *ControlsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ControlsRequest.ProtoReflect.Descriptor instead.
+func (*ControlsRequest) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{15}
+}
+
+type ControlsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Controls      *Controls              `protobuf:"bytes,1,opt,name=controls,proto3" json:"controls,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ControlsResponse) Reset() {
+	*x = ControlsResponse{}
+	mi := &file_notifyhub_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ControlsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ControlsResponse) ProtoMessage() {}
+
+func (x *ControlsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ControlsResponse.ProtoReflect.Descriptor instead.
+func (*ControlsResponse) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ControlsResponse) GetControls() *Controls {
+	if x != nil {
+		return x.Controls
+	}
+	return nil
+}
+
+type Controls struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	PausedPlatforms map[string]int32       `protobuf:"bytes,1,rep,name=paused_platforms,json=pausedPlatforms,proto3" json:"paused_platforms,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // held deliveries by paused platform
+	Queues          map[string]int32       `protobuf:"bytes,2,rep,name=queues,proto3" json:"queues,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`                                          // waiting items by queue
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Controls) Reset() {
+	*x = Controls{}
+	mi := &file_notifyhub_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Controls) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Controls) ProtoMessage() {}
+
+func (x *Controls) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Controls.ProtoReflect.Descriptor instead.
+func (*Controls) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Controls) GetPausedPlatforms() map[string]int32 {
+	if x != nil {
+		return x.PausedPlatforms
+	}
+	return nil
+}
+
+func (x *Controls) GetQueues() map[string]int32 {
+	if x != nil {
+		return x.Queues
+	}
+	return nil
+}
+
+type PausePlatformRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Platform      string                 `protobuf:"bytes,1,opt,name=platform,proto3" json:"platform,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PausePlatformRequest) Reset() {
+	*x = PausePlatformRequest{}
+	mi := &file_notifyhub_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PausePlatformRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PausePlatformRequest) ProtoMessage() {}
+
+func (x *PausePlatformRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PausePlatformRequest.ProtoReflect.Descriptor instead.
+func (*PausePlatformRequest) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *PausePlatformRequest) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+type PausePlatformResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Controls      *Controls              `protobuf:"bytes,1,opt,name=controls,proto3" json:"controls,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PausePlatformResponse) Reset() {
+	*x = PausePlatformResponse{}
+	mi := &file_notifyhub_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PausePlatformResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PausePlatformResponse) ProtoMessage() {}
+
+func (x *PausePlatformResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PausePlatformResponse.ProtoReflect.Descriptor instead.
+func (*PausePlatformResponse) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PausePlatformResponse) GetControls() *Controls {
+	if x != nil {
+		return x.Controls
+	}
+	return nil
+}
+
+type ResumePlatformRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Platform      string                 `protobuf:"bytes,1,opt,name=platform,proto3" json:"platform,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumePlatformRequest) Reset() {
+	*x = ResumePlatformRequest{}
+	mi := &file_notifyhub_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumePlatformRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumePlatformRequest) ProtoMessage() {}
+
+func (x *ResumePlatformRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumePlatformRequest.ProtoReflect.Descriptor instead.
+func (*ResumePlatformRequest) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ResumePlatformRequest) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+type ResumePlatformResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Released      int32                  `protobuf:"varint,1,opt,name=released,proto3" json:"released,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumePlatformResponse) Reset() {
+	*x = ResumePlatformResponse{}
+	mi := &file_notifyhub_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumePlatformResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumePlatformResponse) ProtoMessage() {}
+
+func (x *ResumePlatformResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumePlatformResponse.ProtoReflect.Descriptor instead.
+func (*ResumePlatformResponse) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ResumePlatformResponse) GetReleased() int32 {
+	if x != nil {
+		return x.Released
+	}
+	return 0
+}
+
+type DrainQueueRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Queue         string                 `protobuf:"bytes,1,opt,name=queue,proto3" json:"queue,omitempty"` // "async", "held" or a paused platform
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DrainQueueRequest) Reset() {
+	*x = DrainQueueRequest{}
+	mi := &file_notifyhub_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DrainQueueRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainQueueRequest) ProtoMessage() {}
+
+func (x *DrainQueueRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainQueueRequest.ProtoReflect.Descriptor instead.
+func (*DrainQueueRequest) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *DrainQueueRequest) GetQueue() string {
+	if x != nil {
+		return x.Queue
+	}
+	return ""
+}
+
+type DrainQueueResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Drained       int32                  `protobuf:"varint,1,opt,name=drained,proto3" json:"drained,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DrainQueueResponse) Reset() {
+	*x = DrainQueueResponse{}
+	mi := &file_notifyhub_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DrainQueueResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainQueueResponse) ProtoMessage() {}
+
+func (x *DrainQueueResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notifyhub_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainQueueResponse.ProtoReflect.Descriptor instead.
+func (*DrainQueueResponse) Descriptor() ([]byte, []int) {
+	return file_notifyhub_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *DrainQueueResponse) GetDrained() int32 {
+	if x != nil {
+		return x.Drained
+	}
+	return 0
+}
+
+var File_notifyhub_proto protoreflect.FileDescriptor
+
+const file_notifyhub_proto_rawDesc = "" +
+	"\n" +
+	"\x0fnotifyhub.proto\x12\fnotifyhub.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"j\n" +
+	"\x06Target\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\x12\x1a\n" +
+	"\bplatform\x18\x03 \x01(\tR\bplatform\x12\x1a\n" +
+	"\btimezone\x18\x04 \x01(\tR\btimezone\"\xe4\x02\n" +
+	"\aMessage\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x12\n" +
+	"\x04body\x18\x03 \x01(\tR\x04body\x12\x16\n" +
+	"\x06format\x18\x04 \x01(\tR\x06format\x12\x1f\n" +
+	"\bpriority\x18\x05 \x01(\x05H\x00R\bpriority\x88\x01\x01\x12.\n" +
+	"\atargets\x18\x06 \x03(\v2\x14.notifyhub.v1.TargetR\atargets\x123\n" +
+	"\bmetadata\x18\a \x01(\v2\x17.google.protobuf.StructR\bmetadata\x125\n" +
+	"\tvariables\x18\b \x01(\v2\x17.google.protobuf.StructR\tvariables\x12=\n" +
+	"\fscheduled_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\vscheduledAtB\v\n" +
+	"\t_priority\">\n" +
+	"\vSendRequest\x12/\n" +
+	"\amessage\x18\x01 \x01(\v2\x15.notifyhub.v1.MessageR\amessage\"?\n" +
+	"\fSendResponse\x12/\n" +
+	"\areceipt\x18\x01 \x01(\v2\x15.notifyhub.v1.ReceiptR\areceipt\"C\n" +
+	"\x10SendAsyncRequest\x12/\n" +
+	"\amessage\x18\x01 \x01(\v2\x15.notifyhub.v1.MessageR\amessage\"*\n" +
+	"\x11SendAsyncResponse\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\":\n" +
+	"\rStatusRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x12\n" +
+	"\x04wait\x18\x02 \x01(\bR\x04wait\"5\n" +
+	"\x0eStatusResponse\x12#\n" +
+	"\x03job\x18\x01 \x01(\v2\x11.notifyhub.v1.JobR\x03job\"\xee\x01\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12/\n" +
+	"\areceipt\x18\x03 \x01(\v2\x15.notifyhub.v1.ReceiptR\areceipt\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12=\n" +
+	"\fsubmitted_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vsubmittedAt\x12;\n" +
+	"\vfinished_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"finishedAt\"\x0f\n" +
+	"\rHealthRequest\"\x85\x03\n" +
+	"\x0eHealthResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12I\n" +
+	"\tplatforms\x18\x02 \x03(\v2+.notifyhub.v1.HealthResponse.PlatformsEntryR\tplatforms\x12%\n" +
+	"\x0euptime_seconds\x18\x03 \x01(\x01R\ruptimeSeconds\x12!\n" +
+	"\factive_tasks\x18\x04 \x01(\x03R\vactiveTasks\x12\x1f\n" +
+	"\vqueue_depth\x18\x05 \x01(\x03R\n" +
+	"queueDepth\x12\x1d\n" +
+	"\n" +
+	"total_sent\x18\x06 \x01(\x03R\ttotalSent\x12!\n" +
+	"\fsuccess_rate\x18\a \x01(\x01R\vsuccessRate\x12%\n" +
+	"\x0econfig_version\x18\b \x01(\tR\rconfigVersion\x1a<\n" +
+	"\x0ePlatformsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x16\n" +
+	"\x14WatchReceiptsRequest\"H\n" +
+	"\x15WatchReceiptsResponse\x12/\n" +
+	"\areceipt\x18\x01 \x01(\v2\x15.notifyhub.v1.ReceiptR\areceipt\"\xae\x02\n" +
+	"\aReceipt\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x126\n" +
+	"\aresults\x18\x03 \x03(\v2\x1c.notifyhub.v1.PlatformResultR\aresults\x12\x1e\n" +
+	"\n" +
+	"successful\x18\x04 \x01(\x05R\n" +
+	"successful\x12\x16\n" +
+	"\x06failed\x18\x05 \x01(\x05R\x06failed\x12\x18\n" +
+	"\askipped\x18\x06 \x01(\x05R\askipped\x12\x12\n" +
+	"\x04held\x18\a \x01(\x05R\x04held\x12\x14\n" +
+	"\x05total\x18\b \x01(\x05R\x05total\x128\n" +
+	"\ttimestamp\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\xd5\x02\n" +
+	"\x0ePlatformResult\x12\x1a\n" +
+	"\bplatform\x18\x01 \x01(\tR\bplatform\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\tR\x06target\x12\x18\n" +
+	"\asuccess\x18\x03 \x01(\bR\asuccess\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x05 \x01(\tR\tmessageId\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x129\n" +
+	"\n" +
+	"held_until\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\theldUntil\x123\n" +
+	"\atimeout\x18\b \x01(\v2\x19.google.protobuf.DurationR\atimeout\x128\n" +
+	"\ttimestamp\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\x11\n" +
+	"\x0fControlsRequest\"F\n" +
+	"\x10ControlsResponse\x122\n" +
+	"\bcontrols\x18\x01 \x01(\v2\x16.notifyhub.v1.ControlsR\bcontrols\"\x9d\x02\n" +
+	"\bControls\x12V\n" +
+	"\x10paused_platforms\x18\x01 \x03(\v2+.notifyhub.v1.Controls.PausedPlatformsEntryR\x0fpausedPlatforms\x12:\n" +
+	"\x06queues\x18\x02 \x03(\v2\".notifyhub.v1.Controls.QueuesEntryR\x06queues\x1aB\n" +
+	"\x14PausedPlatformsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01\x1a9\n" +
+	"\vQueuesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01\"2\n" +
+	"\x14PausePlatformRequest\x12\x1a\n" +
+	"\bplatform\x18\x01 \x01(\tR\bplatform\"K\n" +
+	"\x15PausePlatformResponse\x122\n" +
+	"\bcontrols\x18\x01 \x01(\v2\x16.notifyhub.v1.ControlsR\bcontrols\"3\n" +
+	"\x15ResumePlatformRequest\x12\x1a\n" +
+	"\bplatform\x18\x01 \x01(\tR\bplatform\"4\n" +
+	"\x16ResumePlatformResponse\x12\x1a\n" +
+	"\breleased\x18\x01 \x01(\x05R\breleased\")\n" +
+	"\x11DrainQueueRequest\x12\x14\n" +
+	"\x05queue\x18\x01 \x01(\tR\x05queue\".\n" +
+	"\x12DrainQueueResponse\x12\x18\n" +
+	"\adrained\x18\x01 \x01(\x05R\adrained2\x85\x03\n" +
+	"\x10NotifyHubService\x12=\n" +
+	"\x04Send\x12\x19.notifyhub.v1.SendRequest\x1a\x1a.notifyhub.v1.SendResponse\x12L\n" +
+	"\tSendAsync\x12\x1e.notifyhub.v1.SendAsyncRequest\x1a\x1f.notifyhub.v1.SendAsyncResponse\x12C\n" +
+	"\x06Status\x12\x1b.notifyhub.v1.StatusRequest\x1a\x1c.notifyhub.v1.StatusResponse\x12C\n" +
+	"\x06Health\x12\x1b.notifyhub.v1.HealthRequest\x1a\x1c.notifyhub.v1.HealthResponse\x12Z\n" +
+	"\rWatchReceipts\x12\".notifyhub.v1.WatchReceiptsRequest\x1a#.notifyhub.v1.WatchReceiptsResponse0\x012\xea\x02\n" +
+	"\x15NotifyHubAdminService\x12I\n" +
+	"\bControls\x12\x1d.notifyhub.v1.ControlsRequest\x1a\x1e.notifyhub.v1.ControlsResponse\x12X\n" +
+	"\rPausePlatform\x12\".notifyhub.v1.PausePlatformRequest\x1a#.notifyhub.v1.PausePlatformResponse\x12[\n" +
+	"\x0eResumePlatform\x12#.notifyhub.v1.ResumePlatformRequest\x1a$.notifyhub.v1.ResumePlatformResponse\x12O\n" +
+	"\n" +
+	"DrainQueue\x12\x1f.notifyhub.v1.DrainQueueRequest\x1a .notifyhub.v1.DrainQueueResponseB3Z1github.com/kart-io/notifyhub/pkg/server/grpc;grpcb\x06proto3"
+
+var (
+	file_notifyhub_proto_rawDescOnce sync.Once
+	file_notifyhub_proto_rawDescData []byte
+)
+
+func file_notifyhub_proto_rawDescGZIP() []byte {
+	file_notifyhub_proto_rawDescOnce.Do(func() {
+		file_notifyhub_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_notifyhub_proto_rawDesc), len(file_notifyhub_proto_rawDesc)))
+	})
+	return file_notifyhub_proto_rawDescData
+}
+
+var file_notifyhub_proto_msgTypes = make([]protoimpl.MessageInfo, 27)
+var file_notifyhub_proto_goTypes = []any{
+	(*Target)(nil),                 // 0: notifyhub.v1.Target
+	(*Message)(nil),                // 1: notifyhub.v1.Message
+	(*SendRequest)(nil),            // 2: notifyhub.v1.SendRequest
+	(*SendResponse)(nil),           // 3: notifyhub.v1.SendResponse
+	(*SendAsyncRequest)(nil),       // 4: notifyhub.v1.SendAsyncRequest
+	(*SendAsyncResponse)(nil),      // 5: notifyhub.v1.SendAsyncResponse
+	(*StatusRequest)(nil),          // 6: notifyhub.v1.StatusRequest
+	(*StatusResponse)(nil),         // 7: notifyhub.v1.StatusResponse
+	(*Job)(nil),                    // 8: notifyhub.v1.Job
+	(*HealthRequest)(nil),          // 9: notifyhub.v1.HealthRequest
+	(*HealthResponse)(nil),         // 10: notifyhub.v1.HealthResponse
+	(*WatchReceiptsRequest)(nil),   // 11: notifyhub.v1.WatchReceiptsRequest
+	(*WatchReceiptsResponse)(nil),  // 12: notifyhub.v1.WatchReceiptsResponse
+	(*Receipt)(nil),                // 13: notifyhub.v1.Receipt
+	(*PlatformResult)(nil),         // 14: notifyhub.v1.PlatformResult
+	(*ControlsRequest)(nil),        // 15: notifyhub.v1.ControlsRequest
+	(*ControlsResponse)(nil),       // 16: notifyhub.v1.ControlsResponse
+	(*Controls)(nil),               // 17: notifyhub.v1.Controls
+	(*PausePlatformRequest)(nil),   // 18: notifyhub.v1.PausePlatformRequest
+	(*PausePlatformResponse)(nil),  // 19: notifyhub.v1.PausePlatformResponse
+	(*ResumePlatformRequest)(nil),  // 20: notifyhub.v1.ResumePlatformRequest
+	(*ResumePlatformResponse)(nil), // 21: notifyhub.v1.ResumePlatformResponse
+	(*DrainQueueRequest)(nil),      // 22: notifyhub.v1.DrainQueueRequest
+	(*DrainQueueResponse)(nil),     // 23: notifyhub.v1.DrainQueueResponse
+	nil,                            // 24: notifyhub.v1.HealthResponse.PlatformsEntry
+	nil,                            // 25: notifyhub.v1.Controls.PausedPlatformsEntry
+	nil,                            // 26: notifyhub.v1.Controls.QueuesEntry
+	(*structpb.Struct)(nil),        // 27: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil),  // 28: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),    // 29: google.protobuf.Duration
+}
+var file_notifyhub_proto_depIdxs = []int32{
+	0,  // 0: notifyhub.v1.Message.targets:type_name -> notifyhub.v1.Target
+	27, // 1: notifyhub.v1.Message.metadata:type_name -> google.protobuf.Struct
+	27, // 2: notifyhub.v1.Message.variables:type_name -> google.protobuf.Struct
+	28, // 3: notifyhub.v1.Message.scheduled_at:type_name -> google.protobuf.Timestamp
+	1,  // 4: notifyhub.v1.SendRequest.message:type_name -> notifyhub.v1.Message
+	13, // 5: notifyhub.v1.SendResponse.receipt:type_name -> notifyhub.v1.Receipt
+	1,  // 6: notifyhub.v1.SendAsyncRequest.message:type_name -> notifyhub.v1.Message
+	8,  // 7: notifyhub.v1.StatusResponse.job:type_name -> notifyhub.v1.Job
+	13, // 8: notifyhub.v1.Job.receipt:type_name -> notifyhub.v1.Receipt
+	28, // 9: notifyhub.v1.Job.submitted_at:type_name -> google.protobuf.Timestamp
+	28, // 10: notifyhub.v1.Job.finished_at:type_name -> google.protobuf.Timestamp
+	24, // 11: notifyhub.v1.HealthResponse.platforms:type_name -> notifyhub.v1.HealthResponse.PlatformsEntry
+	13, // 12: notifyhub.v1.WatchReceiptsResponse.receipt:type_name -> notifyhub.v1.Receipt
+	14, // 13: notifyhub.v1.Receipt.results:type_name -> notifyhub.v1.PlatformResult
+	28, // 14: notifyhub.v1.Receipt.timestamp:type_name -> google.protobuf.Timestamp
+	28, // 15: notifyhub.v1.PlatformResult.held_until:type_name -> google.protobuf.Timestamp
+	29, // 16: notifyhub.v1.PlatformResult.timeout:type_name -> google.protobuf.Duration
+	28, // 17: notifyhub.v1.PlatformResult.timestamp:type_name -> google.protobuf.Timestamp
+	17, // 18: notifyhub.v1.ControlsResponse.controls:type_name -> notifyhub.v1.Controls
+	25, // 19: notifyhub.v1.Controls.paused_platforms:type_name -> notifyhub.v1.Controls.PausedPlatformsEntry
+	26, // 20: notifyhub.v1.Controls.queues:type_name -> notifyhub.v1.Controls.QueuesEntry
+	17, // 21: notifyhub.v1.PausePlatformResponse.controls:type_name -> notifyhub.v1.Controls
+	2,  // 22: notifyhub.v1.NotifyHubService.Send:input_type -> notifyhub.v1.SendRequest
+	4,  // 23: notifyhub.v1.NotifyHubService.SendAsync:input_type -> notifyhub.v1.SendAsyncRequest
+	6,  // 24: notifyhub.v1.NotifyHubService.Status:input_type -> notifyhub.v1.StatusRequest
+	9,  // 25: notifyhub.v1.NotifyHubService.Health:input_type -> notifyhub.v1.HealthRequest
+	11, // 26: notifyhub.v1.NotifyHubService.WatchReceipts:input_type -> notifyhub.v1.WatchReceiptsRequest
+	15, // 27: notifyhub.v1.NotifyHubAdminService.Controls:input_type -> notifyhub.v1.ControlsRequest
+	18, // 28: notifyhub.v1.NotifyHubAdminService.PausePlatform:input_type -> notifyhub.v1.PausePlatformRequest
+	20, // 29: notifyhub.v1.NotifyHubAdminService.ResumePlatform:input_type -> notifyhub.v1.ResumePlatformRequest
+	22, // 30: notifyhub.v1.NotifyHubAdminService.DrainQueue:input_type -> notifyhub.v1.DrainQueueRequest
+	3,  // 31: notifyhub.v1.NotifyHubService.Send:output_type -> notifyhub.v1.SendResponse
+	5,  // 32: notifyhub.v1.NotifyHubService.SendAsync:output_type -> notifyhub.v1.SendAsyncResponse
+	7,  // 33: notifyhub.v1.NotifyHubService.Status:output_type -> notifyhub.v1.StatusResponse
+	10, // 34: notifyhub.v1.NotifyHubService.Health:output_type -> notifyhub.v1.HealthResponse
+	12, // 35: notifyhub.v1.NotifyHubService.WatchReceipts:output_type -> notifyhub.v1.WatchReceiptsResponse
+	16, // 36: notifyhub.v1.NotifyHubAdminService.Controls:output_type -> notifyhub.v1.ControlsResponse
+	19, // 37: notifyhub.v1.NotifyHubAdminService.PausePlatform:output_type -> notifyhub.v1.PausePlatformResponse
+	21, // 38: notifyhub.v1.NotifyHubAdminService.ResumePlatform:output_type -> notifyhub.v1.ResumePlatformResponse
+	23, // 39: notifyhub.v1.NotifyHubAdminService.DrainQueue:output_type -> notifyhub.v1.DrainQueueResponse
+	31, // [31:40] is the sub-list for method output_type
+	22, // [22:31] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
+}
+
+func init() { file_notifyhub_proto_init() }
+func file_notifyhub_proto_init() {
+	if File_notifyhub_proto != nil {
+		return
+	}
+	file_notifyhub_proto_msgTypes[1].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_notifyhub_proto_rawDesc), len(file_notifyhub_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   27,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_notifyhub_proto_goTypes,
+		DependencyIndexes: file_notifyhub_proto_depIdxs,
+		MessageInfos:      file_notifyhub_proto_msgTypes,
+	}.Build()
+	File_notifyhub_proto = out.File
+	file_notifyhub_proto_goTypes = nil
+	file_notifyhub_proto_depIdxs = nil
+}
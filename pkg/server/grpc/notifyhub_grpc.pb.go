@@ -0,0 +1,535 @@
+// The NotifyHub API for services that use the hub as a sidecar or central
+// notification service, mirroring server.Service. The Go bindings are
+// generated with buf generate (see buf.gen.yaml).
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: notifyhub.proto
+
+package grpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	NotifyHubService_Send_FullMethodName          = "/notifyhub.v1.NotifyHubService/Send"
+	NotifyHubService_SendAsync_FullMethodName     = "/notifyhub.v1.NotifyHubService/SendAsync"
+	NotifyHubService_Status_FullMethodName        = "/notifyhub.v1.NotifyHubService/Status"
+	NotifyHubService_Health_FullMethodName        = "/notifyhub.v1.NotifyHubService/Health"
+	NotifyHubService_WatchReceipts_FullMethodName = "/notifyhub.v1.NotifyHubService/WatchReceipts"
+)
+
+// NotifyHubServiceClient is the client API for NotifyHubService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NotifyHubServiceClient interface {
+	// Send sends a message and returns its receipt once every target was
+	// attempted.
+	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error)
+	// SendAsync queues a message and returns the job that Status reports on.
+	SendAsync(ctx context.Context, in *SendAsyncRequest, opts ...grpc.CallOption) (*SendAsyncResponse, error)
+	// Status returns the state of a job, NOT_FOUND for unknown jobs and jobs
+	// forgotten after the server's job retention.
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	// Health returns the health of the hub and its platforms.
+	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+	// WatchReceipts streams the receipts of every send made after the call,
+	// by any client of the hub. Slow readers miss receipts.
+	WatchReceipts(ctx context.Context, in *WatchReceiptsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchReceiptsResponse], error)
+}
+
+type notifyHubServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNotifyHubServiceClient(cc grpc.ClientConnInterface) NotifyHubServiceClient {
+	return &notifyHubServiceClient{cc}
+}
+
+func (c *notifyHubServiceClient) Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendResponse)
+	err := c.cc.Invoke(ctx, NotifyHubService_Send_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyHubServiceClient) SendAsync(ctx context.Context, in *SendAsyncRequest, opts ...grpc.CallOption) (*SendAsyncResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendAsyncResponse)
+	err := c.cc.Invoke(ctx, NotifyHubService_SendAsync_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyHubServiceClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, NotifyHubService_Status_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyHubServiceClient) Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HealthResponse)
+	err := c.cc.Invoke(ctx, NotifyHubService_Health_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyHubServiceClient) WatchReceipts(ctx context.Context, in *WatchReceiptsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchReceiptsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NotifyHubService_ServiceDesc.Streams[0], NotifyHubService_WatchReceipts_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchReceiptsRequest, WatchReceiptsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotifyHubService_WatchReceiptsClient = grpc.ServerStreamingClient[WatchReceiptsResponse]
+
+// NotifyHubServiceServer is the server API for NotifyHubService service.
+// All implementations must embed UnimplementedNotifyHubServiceServer
+// for forward compatibility.
+type NotifyHubServiceServer interface {
+	// Send sends a message and returns its receipt once every target was
+	// attempted.
+	Send(context.Context, *SendRequest) (*SendResponse, error)
+	// SendAsync queues a message and returns the job that Status reports on.
+	SendAsync(context.Context, *SendAsyncRequest) (*SendAsyncResponse, error)
+	// Status returns the state of a job, NOT_FOUND for unknown jobs and jobs
+	// forgotten after the server's job retention.
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	// Health returns the health of the hub and its platforms.
+	Health(context.Context, *HealthRequest) (*HealthResponse, error)
+	// WatchReceipts streams the receipts of every send made after the call,
+	// by any client of the hub. Slow readers miss receipts.
+	WatchReceipts(*WatchReceiptsRequest, grpc.ServerStreamingServer[WatchReceiptsResponse]) error
+	mustEmbedUnimplementedNotifyHubServiceServer()
+}
+
+// UnimplementedNotifyHubServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNotifyHubServiceServer struct{}
+
+func (UnimplementedNotifyHubServiceServer) Send(context.Context, *SendRequest) (*SendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedNotifyHubServiceServer) SendAsync(context.Context, *SendAsyncRequest) (*SendAsyncResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendAsync not implemented")
+}
+func (UnimplementedNotifyHubServiceServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedNotifyHubServiceServer) Health(context.Context, *HealthRequest) (*HealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Health not implemented")
+}
+func (UnimplementedNotifyHubServiceServer) WatchReceipts(*WatchReceiptsRequest, grpc.ServerStreamingServer[WatchReceiptsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchReceipts not implemented")
+}
+func (UnimplementedNotifyHubServiceServer) mustEmbedUnimplementedNotifyHubServiceServer() {}
+func (UnimplementedNotifyHubServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeNotifyHubServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NotifyHubServiceServer will
+// result in compilation errors.
+type UnsafeNotifyHubServiceServer interface {
+	mustEmbedUnimplementedNotifyHubServiceServer()
+}
+
+func RegisterNotifyHubServiceServer(s grpc.ServiceRegistrar, srv NotifyHubServiceServer) {
+	// If the following call pancis, it indicates UnimplementedNotifyHubServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NotifyHubService_ServiceDesc, srv)
+}
+
+func _NotifyHubService_Send_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyHubServiceServer).Send(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyHubService_Send_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyHubServiceServer).Send(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyHubService_SendAsync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendAsyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyHubServiceServer).SendAsync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyHubService_SendAsync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyHubServiceServer).SendAsync(ctx, req.(*SendAsyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyHubService_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyHubServiceServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyHubService_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyHubServiceServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyHubService_Health_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyHubServiceServer).Health(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyHubService_Health_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyHubServiceServer).Health(ctx, req.(*HealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyHubService_WatchReceipts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchReceiptsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NotifyHubServiceServer).WatchReceipts(m, &grpc.GenericServerStream[WatchReceiptsRequest, WatchReceiptsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotifyHubService_WatchReceiptsServer = grpc.ServerStreamingServer[WatchReceiptsResponse]
+
+// NotifyHubService_ServiceDesc is the grpc.ServiceDesc for NotifyHubService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NotifyHubService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "notifyhub.v1.NotifyHubService",
+	HandlerType: (*NotifyHubServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Send",
+			Handler:    _NotifyHubService_Send_Handler,
+		},
+		{
+			MethodName: "SendAsync",
+			Handler:    _NotifyHubService_SendAsync_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _NotifyHubService_Status_Handler,
+		},
+		{
+			MethodName: "Health",
+			Handler:    _NotifyHubService_Health_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchReceipts",
+			Handler:       _NotifyHubService_WatchReceipts_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "notifyhub.proto",
+}
+
+const (
+	NotifyHubAdminService_Controls_FullMethodName       = "/notifyhub.v1.NotifyHubAdminService/Controls"
+	NotifyHubAdminService_PausePlatform_FullMethodName  = "/notifyhub.v1.NotifyHubAdminService/PausePlatform"
+	NotifyHubAdminService_ResumePlatform_FullMethodName = "/notifyhub.v1.NotifyHubAdminService/ResumePlatform"
+	NotifyHubAdminService_DrainQueue_FullMethodName     = "/notifyhub.v1.NotifyHubAdminService/DrainQueue"
+)
+
+// NotifyHubAdminServiceClient is the client API for NotifyHubAdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// NotifyHubAdminService pauses platforms and drains queues at runtime.
+// Serve it to incident responders only, with credentials apart from those
+// of senders.
+type NotifyHubAdminServiceClient interface {
+	// Controls returns the paused platforms and the length of the queues.
+	Controls(ctx context.Context, in *ControlsRequest, opts ...grpc.CallOption) (*ControlsResponse, error)
+	// PausePlatform holds the deliveries of a platform until it is resumed,
+	// NOT_FOUND for platforms that are not configured.
+	PausePlatform(ctx context.Context, in *PausePlatformRequest, opts ...grpc.CallOption) (*PausePlatformResponse, error)
+	// ResumePlatform releases the deliveries held for a paused platform,
+	// FAILED_PRECONDITION for platforms that are not paused.
+	ResumePlatform(ctx context.Context, in *ResumePlatformRequest, opts ...grpc.CallOption) (*ResumePlatformResponse, error)
+	// DrainQueue removes the items waiting in a queue, NOT_FOUND for unknown
+	// queues.
+	DrainQueue(ctx context.Context, in *DrainQueueRequest, opts ...grpc.CallOption) (*DrainQueueResponse, error)
+}
+
+type notifyHubAdminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNotifyHubAdminServiceClient(cc grpc.ClientConnInterface) NotifyHubAdminServiceClient {
+	return &notifyHubAdminServiceClient{cc}
+}
+
+func (c *notifyHubAdminServiceClient) Controls(ctx context.Context, in *ControlsRequest, opts ...grpc.CallOption) (*ControlsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ControlsResponse)
+	err := c.cc.Invoke(ctx, NotifyHubAdminService_Controls_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyHubAdminServiceClient) PausePlatform(ctx context.Context, in *PausePlatformRequest, opts ...grpc.CallOption) (*PausePlatformResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PausePlatformResponse)
+	err := c.cc.Invoke(ctx, NotifyHubAdminService_PausePlatform_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyHubAdminServiceClient) ResumePlatform(ctx context.Context, in *ResumePlatformRequest, opts ...grpc.CallOption) (*ResumePlatformResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResumePlatformResponse)
+	err := c.cc.Invoke(ctx, NotifyHubAdminService_ResumePlatform_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyHubAdminServiceClient) DrainQueue(ctx context.Context, in *DrainQueueRequest, opts ...grpc.CallOption) (*DrainQueueResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DrainQueueResponse)
+	err := c.cc.Invoke(ctx, NotifyHubAdminService_DrainQueue_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NotifyHubAdminServiceServer is the server API for NotifyHubAdminService service.
+// All implementations must embed UnimplementedNotifyHubAdminServiceServer
+// for forward compatibility.
+//
+// NotifyHubAdminService pauses platforms and drains queues at runtime.
+// Serve it to incident responders only, with credentials apart from those
+// of senders.
+type NotifyHubAdminServiceServer interface {
+	// Controls returns the paused platforms and the length of the queues.
+	Controls(context.Context, *ControlsRequest) (*ControlsResponse, error)
+	// PausePlatform holds the deliveries of a platform until it is resumed,
+	// NOT_FOUND for platforms that are not configured.
+	PausePlatform(context.Context, *PausePlatformRequest) (*PausePlatformResponse, error)
+	// ResumePlatform releases the deliveries held for a paused platform,
+	// FAILED_PRECONDITION for platforms that are not paused.
+	ResumePlatform(context.Context, *ResumePlatformRequest) (*ResumePlatformResponse, error)
+	// DrainQueue removes the items waiting in a queue, NOT_FOUND for unknown
+	// queues.
+	DrainQueue(context.Context, *DrainQueueRequest) (*DrainQueueResponse, error)
+	mustEmbedUnimplementedNotifyHubAdminServiceServer()
+}
+
+// UnimplementedNotifyHubAdminServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNotifyHubAdminServiceServer struct{}
+
+func (UnimplementedNotifyHubAdminServiceServer) Controls(context.Context, *ControlsRequest) (*ControlsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Controls not implemented")
+}
+func (UnimplementedNotifyHubAdminServiceServer) PausePlatform(context.Context, *PausePlatformRequest) (*PausePlatformResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PausePlatform not implemented")
+}
+func (UnimplementedNotifyHubAdminServiceServer) ResumePlatform(context.Context, *ResumePlatformRequest) (*ResumePlatformResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumePlatform not implemented")
+}
+func (UnimplementedNotifyHubAdminServiceServer) DrainQueue(context.Context, *DrainQueueRequest) (*DrainQueueResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DrainQueue not implemented")
+}
+func (UnimplementedNotifyHubAdminServiceServer) mustEmbedUnimplementedNotifyHubAdminServiceServer() {}
+func (UnimplementedNotifyHubAdminServiceServer) testEmbeddedByValue()                               {}
+
+// UnsafeNotifyHubAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NotifyHubAdminServiceServer will
+// result in compilation errors.
+type UnsafeNotifyHubAdminServiceServer interface {
+	mustEmbedUnimplementedNotifyHubAdminServiceServer()
+}
+
+func RegisterNotifyHubAdminServiceServer(s grpc.ServiceRegistrar, srv NotifyHubAdminServiceServer) {
+	// If the following call pancis, it indicates UnimplementedNotifyHubAdminServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NotifyHubAdminService_ServiceDesc, srv)
+}
+
+func _NotifyHubAdminService_Controls_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ControlsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyHubAdminServiceServer).Controls(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyHubAdminService_Controls_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyHubAdminServiceServer).Controls(ctx, req.(*ControlsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyHubAdminService_PausePlatform_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PausePlatformRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyHubAdminServiceServer).PausePlatform(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyHubAdminService_PausePlatform_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyHubAdminServiceServer).PausePlatform(ctx, req.(*PausePlatformRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyHubAdminService_ResumePlatform_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumePlatformRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyHubAdminServiceServer).ResumePlatform(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyHubAdminService_ResumePlatform_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyHubAdminServiceServer).ResumePlatform(ctx, req.(*ResumePlatformRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyHubAdminService_DrainQueue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainQueueRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyHubAdminServiceServer).DrainQueue(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyHubAdminService_DrainQueue_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyHubAdminServiceServer).DrainQueue(ctx, req.(*DrainQueueRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// NotifyHubAdminService_ServiceDesc is the grpc.ServiceDesc for NotifyHubAdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NotifyHubAdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "notifyhub.v1.NotifyHubAdminService",
+	HandlerType: (*NotifyHubAdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Controls",
+			Handler:    _NotifyHubAdminService_Controls_Handler,
+		},
+		{
+			MethodName: "PausePlatform",
+			Handler:    _NotifyHubAdminService_PausePlatform_Handler,
+		},
+		{
+			MethodName: "ResumePlatform",
+			Handler:    _NotifyHubAdminService_ResumePlatform_Handler,
+		},
+		{
+			MethodName: "DrainQueue",
+			Handler:    _NotifyHubAdminService_DrainQueue_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "notifyhub.proto",
+}
@@ -0,0 +1,389 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/redact"
+	"github.com/kart-io/notifyhub/pkg/server"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithValidator sets the validator of sent messages. By default messages
+// need targets and a title or body within the message.Validator limits.
+func WithValidator(v *message.Validator) Option {
+	return func(s *Server) {
+		s.validator = v
+	}
+}
+
+// WithTargetMask sets how receipts show target addresses,
+// redact.MaskTarget by default (c***@gmail.com); nil shows them unmasked
+func WithTargetMask(mask func(string) string) Option {
+	return func(s *Server) {
+		s.targetMask = mask
+	}
+}
+
+// WithLogger sets the logger of the server
+func WithLogger(l logger.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// Server serves NotifyHubService for a server.Service
+type Server struct {
+	UnimplementedNotifyHubServiceServer
+
+	service    *server.Service
+	validator  *message.Validator
+	targetMask func(string) string
+	logger     logger.Logger
+}
+
+// NewServer creates the NotifyHubService of a service
+func NewServer(service *server.Service, opts ...Option) *Server {
+	s := &Server{
+		service:    service,
+		validator:  message.NewValidator(message.ValidatorConfig{RequireTargets: true}),
+		targetMask: redact.MaskTarget,
+		logger:     logger.Discard,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register registers the NotifyHubService of a service with a gRPC server
+func Register(registrar grpc.ServiceRegistrar, service *server.Service, opts ...Option) *Server {
+	s := NewServer(service, opts...)
+	RegisterNotifyHubServiceServer(registrar, s)
+	return s
+}
+
+// Send implements NotifyHubServiceServer
+func (s *Server) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	msg, err := s.message(req.GetMessage())
+	if err != nil {
+		return nil, err
+	}
+	rcpt, err := s.service.Send(ctx, msg)
+	if err != nil {
+		return nil, sendError(err, codes.Unavailable)
+	}
+	return &SendResponse{Receipt: fromReceipt(rcpt.Masked(s.targetMask))}, nil
+}
+
+// SendAsync implements NotifyHubServiceServer
+func (s *Server) SendAsync(ctx context.Context, req *SendAsyncRequest) (*SendAsyncResponse, error) {
+	msg, err := s.message(req.GetMessage())
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.service.SendAsync(ctx, msg)
+	if err != nil {
+		return nil, sendError(err, codes.InvalidArgument)
+	}
+	return &SendAsyncResponse{JobId: id}, nil
+}
+
+// Status implements NotifyHubServiceServer
+func (s *Server) Status(ctx context.Context, req *StatusRequest) (*StatusResponse, error) {
+	var (
+		job server.Job
+		err error
+	)
+	if req.GetWait() {
+		job, err = s.service.Wait(ctx, req.GetJobId())
+	} else {
+		job, err = s.service.Status(req.GetJobId())
+	}
+	switch {
+	case errors.Is(err, server.ErrJobNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, status.FromContextError(err).Err()
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &StatusResponse{Job: &Job{
+		Id:          job.ID,
+		State:       string(job.State),
+		Receipt:     fromReceipt(job.Receipt.Masked(s.targetMask)),
+		Error:       job.Error,
+		SubmittedAt: timestamppb.New(job.SubmittedAt),
+		FinishedAt:  timestamp(job.FinishedAt),
+	}}, nil
+}
+
+// Health implements NotifyHubServiceServer
+func (s *Server) Health(ctx context.Context, _ *HealthRequest) (*HealthResponse, error) {
+	health, err := s.service.Health(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return fromHealth(health), nil
+}
+
+// WatchReceipts implements NotifyHubServiceServer. The response headers
+// are sent once the stream is subscribed, so that a client that waits for
+// them misses no receipt of the sends it makes afterwards.
+func (s *Server) WatchReceipts(_ *WatchReceiptsRequest, stream grpc.ServerStreamingServer[WatchReceiptsResponse]) error {
+	receipts := s.service.Receipts(stream.Context())
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for rcpt := range receipts {
+		if err := stream.Send(&WatchReceiptsResponse{Receipt: fromReceipt(rcpt.Masked(s.targetMask))}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// message returns the validated message of a request
+func (s *Server) message(m *Message) (*message.Message, error) {
+	if m == nil {
+		return nil, status.Error(codes.InvalidArgument, "message is required")
+	}
+	msg, err := toMessage(m)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	err = msg.Validate()
+	if err == nil {
+		err = s.validator.Validate(msg)
+	}
+	if err == nil {
+		err = server.CheckSchedule(msg)
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return msg, nil
+}
+
+// sendError returns the status of a failed send: ResourceExhausted over a
+// usage quota, or else code
+func sendError(err error, code codes.Code) error {
+	if errors.Is(err, notifyhub.ErrQuotaExceeded) {
+		code = codes.ResourceExhausted
+	}
+	return status.Error(code, err.Error())
+}
+
+// AdminServer serves NotifyHubAdminService for a server.Service. Register
+// it on a server apart from senders, or behind an interceptor that admits
+// incident responders only.
+type AdminServer struct {
+	UnimplementedNotifyHubAdminServiceServer
+
+	service *server.Service
+	logger  logger.Logger
+}
+
+// RegisterAdmin registers the NotifyHubAdminService of a service with a
+// gRPC server
+func RegisterAdmin(registrar grpc.ServiceRegistrar, service *server.Service, l logger.Logger) *AdminServer {
+	if l == nil {
+		l = logger.Discard
+	}
+	s := &AdminServer{service: service, logger: l}
+	RegisterNotifyHubAdminServiceServer(registrar, s)
+	return s
+}
+
+// Controls implements NotifyHubAdminServiceServer
+func (s *AdminServer) Controls(context.Context, *ControlsRequest) (*ControlsResponse, error) {
+	return &ControlsResponse{Controls: fromControls(s.service.Controls())}, nil
+}
+
+// PausePlatform implements NotifyHubAdminServiceServer
+func (s *AdminServer) PausePlatform(_ context.Context, req *PausePlatformRequest) (*PausePlatformResponse, error) {
+	if err := s.service.PausePlatform(req.GetPlatform()); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	s.logger.Warn("Platform paused through the gRPC admin API", "platform", req.GetPlatform())
+	return &PausePlatformResponse{Controls: fromControls(s.service.Controls())}, nil
+}
+
+// ResumePlatform implements NotifyHubAdminServiceServer
+func (s *AdminServer) ResumePlatform(_ context.Context, req *ResumePlatformRequest) (*ResumePlatformResponse, error) {
+	released, err := s.service.ResumePlatform(req.GetPlatform())
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	s.logger.Info("Platform resumed through the gRPC admin API", "platform", req.GetPlatform(), "released", released)
+	return &ResumePlatformResponse{Released: int32(released)}, nil
+}
+
+// DrainQueue implements NotifyHubAdminServiceServer
+func (s *AdminServer) DrainQueue(_ context.Context, req *DrainQueueRequest) (*DrainQueueResponse, error) {
+	drained, err := s.service.DrainQueue(req.GetQueue())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	s.logger.Warn("Queue drained through the gRPC admin API", "queue", req.GetQueue(), "drained", drained)
+	return &DrainQueueResponse{Drained: int32(drained)}, nil
+}
+
+// BearerTokens returns the server options that admit the calls with an
+// "authorization: Bearer <token>" metadata entry carrying one of the
+// tokens, rejecting others with Unauthenticated:
+//
+//	srv := grpc.NewServer(notifyhubgrpc.BearerTokens("s3cret")...)
+func BearerTokens(tokens ...string) []grpc.ServerOption {
+	check := func(ctx context.Context) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, value := range md.Get("authorization") {
+			token, ok := strings.CutPrefix(value, "Bearer ")
+			if !ok || token == "" {
+				continue
+			}
+			for _, valid := range tokens {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+					return nil
+				}
+			}
+			return status.Error(codes.Unauthenticated, "invalid bearer token")
+		}
+		return status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := check(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := check(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// toMessage converts a message of the API
+func toMessage(m *Message) (*message.Message, error) {
+	msg := message.New()
+	msg.ID = m.GetId()
+	if msg.ID == "" {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		msg.ID = "msg-" + hex.EncodeToString(b)
+	}
+	msg.Title, msg.Body = m.GetTitle(), m.GetBody()
+	if m.GetFormat() != "" {
+		msg.Format = message.Format(m.GetFormat())
+	}
+	if m.Priority != nil {
+		msg.Priority = message.Priority(m.GetPriority())
+	}
+	for _, t := range m.GetTargets() {
+		msg.Targets = append(msg.Targets, target.Target{Type: t.GetType(), Value: t.GetValue(), Platform: t.GetPlatform(), Timezone: t.GetTimezone()})
+	}
+	if m.GetMetadata() != nil {
+		msg.Metadata = m.GetMetadata().AsMap()
+	}
+	if m.GetVariables() != nil {
+		msg.Variables = m.GetVariables().AsMap()
+	}
+	if m.GetScheduledAt() != nil {
+		at := m.GetScheduledAt().AsTime()
+		msg.ScheduledAt = &at
+	}
+	return msg, nil
+}
+
+// fromReceipt converts a receipt to the API, nil for nil
+func fromReceipt(r *receipt.Receipt) *Receipt {
+	if r == nil {
+		return nil
+	}
+	out := &Receipt{
+		MessageId:  r.MessageID,
+		Status:     r.Status,
+		Successful: int32(r.Successful),
+		Failed:     int32(r.Failed),
+		Skipped:    int32(r.Skipped),
+		Held:       int32(r.Held),
+		Total:      int32(r.Total),
+		Timestamp:  timestamppb.New(r.Timestamp),
+	}
+	for _, result := range r.Results {
+		pr := &PlatformResult{
+			Platform:  result.Platform,
+			Target:    result.Target,
+			Success:   result.Success,
+			Status:    result.Status,
+			MessageId: result.MessageID,
+			Error:     result.Error,
+			HeldUntil: timestamp(result.HeldUntil),
+			Timestamp: timestamppb.New(result.Timestamp),
+		}
+		if result.Timeout > 0 {
+			pr.Timeout = durationpb.New(result.Timeout)
+		}
+		out.Results = append(out.Results, pr)
+	}
+	return out
+}
+
+// fromHealth converts the health of a client to the API
+func fromHealth(h *notifyhub.HealthStatus) *HealthResponse {
+	return &HealthResponse{
+		Status:        h.Status,
+		Platforms:     h.Platforms,
+		UptimeSeconds: h.Uptime,
+		ActiveTasks:   h.ActiveTasks,
+		QueueDepth:    h.QueueDepth,
+		TotalSent:     h.TotalSent,
+		SuccessRate:   h.SuccessRate,
+		ConfigVersion: h.ConfigVersion,
+	}
+}
+
+// fromControls converts the controls of a client to the API
+func fromControls(c notifyhub.ControlStatus) *Controls {
+	out := &Controls{PausedPlatforms: make(map[string]int32), Queues: make(map[string]int32)}
+	for name, n := range c.PausedPlatforms {
+		out.PausedPlatforms[name] = int32(n)
+	}
+	for name, n := range c.Queues {
+		out.Queues[name] = int32(n)
+	}
+	return out
+}
+
+// timestamp converts an optional time, nil for nil
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
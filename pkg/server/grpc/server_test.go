@@ -0,0 +1,131 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/server"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+func TestServer(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer webhook.Close()
+	client, err := notifyhub.NewClientFromOptions(config.WithQuickWebhook(webhook.URL), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	service := server.NewService(client)
+
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(BearerTokens("s3cret")...)
+	Register(srv, service, WithTargetMask(func(string) string { return "***" }))
+	RegisterAdmin(srv, service, nil)
+	go func() { _ = srv.Serve(listener) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer conn.Close()
+	hub, admin := NewNotifyHubServiceClient(conn), NewNotifyHubAdminServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := hub.Health(ctx, &HealthRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Health() without a token error = %v, want Unauthenticated", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer s3cret")
+
+	watch, err := hub.WatchReceipts(ctx, &WatchReceiptsRequest{})
+	if err != nil {
+		t.Fatalf("WatchReceipts() error = %v", err)
+	}
+	// The headers arrive once the stream is subscribed
+	if _, err := watch.Header(); err != nil {
+		t.Fatalf("Header() error = %v", err)
+	}
+
+	targets := []*Target{{Type: "webhook", Value: webhook.URL}}
+	sent, err := hub.Send(ctx, &SendRequest{Message: &Message{Id: "deploy-42", Title: "Deploy", Body: "done", Targets: targets}})
+	if err != nil || sent.GetReceipt().GetSuccessful() != 1 {
+		t.Fatalf("Send() = %v, %v", sent, err)
+	}
+
+	invalid := []struct {
+		name string
+		msg  *Message
+		code codes.Code
+	}{
+		{"no message", nil, codes.InvalidArgument},
+		{"no targets", &Message{Body: "disk full"}, codes.InvalidArgument},
+		{"invalid format", &Message{Body: "disk full", Format: "pdf", Targets: targets}, codes.InvalidArgument},
+		{"scheduled", &Message{Body: "disk full", Targets: targets, ScheduledAt: timestamppb.New(time.Now().Add(time.Hour))}, codes.InvalidArgument},
+	}
+	for _, tt := range invalid {
+		if _, err := hub.Send(ctx, &SendRequest{Message: tt.msg}); status.Code(err) != tt.code {
+			t.Errorf("%s: Send() error = %v, want %v", tt.name, err, tt.code)
+		}
+	}
+
+	queued, err := hub.SendAsync(ctx, &SendAsyncRequest{Message: &Message{Title: "Deploy", Body: "queued", Targets: targets}})
+	if err != nil {
+		t.Fatalf("SendAsync() error = %v", err)
+	}
+	job, err := hub.Status(ctx, &StatusRequest{JobId: queued.GetJobId(), Wait: true})
+	if err != nil || job.GetJob().GetState() != "completed" || job.GetJob().GetReceipt().GetSuccessful() != 1 || job.GetJob().GetFinishedAt() == nil {
+		t.Fatalf("Status() = %v, %v", job, err)
+	}
+	if _, err := hub.Status(ctx, &StatusRequest{JobId: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Status() of an unknown job error = %v, want NotFound", err)
+	}
+
+	for _, want := range []string{"deploy-42", queued.GetJobId()} {
+		got, err := watch.Recv()
+		if err != nil || got.GetReceipt().GetMessageId() != want {
+			t.Fatalf("Recv() = %v, %v, want the receipt of %s", got, err, want)
+		}
+		if target := got.GetReceipt().GetResults()[0].GetTarget(); target != "***" {
+			t.Errorf("streamed target %s, want it masked", target)
+		}
+	}
+
+	if health, err := hub.Health(ctx, &HealthRequest{}); err != nil || health.GetPlatforms()["webhook"] == "" {
+		t.Errorf("Health() = %v, %v", health, err)
+	}
+
+	if _, err := admin.PausePlatform(ctx, &PausePlatformRequest{Platform: "pager"}); status.Code(err) != codes.NotFound {
+		t.Errorf("PausePlatform() of an unknown platform error = %v, want NotFound", err)
+	}
+	paused, err := admin.PausePlatform(ctx, &PausePlatformRequest{Platform: "webhook"})
+	if err != nil {
+		t.Fatalf("PausePlatform() error = %v", err)
+	}
+	if _, ok := paused.GetControls().GetPausedPlatforms()["webhook"]; !ok {
+		t.Errorf("PausePlatform() controls = %v, want webhook paused", paused.GetControls())
+	}
+	if _, err := admin.ResumePlatform(ctx, &ResumePlatformRequest{Platform: "webhook"}); err != nil {
+		t.Errorf("ResumePlatform() error = %v", err)
+	}
+	if _, err := admin.ResumePlatform(ctx, &ResumePlatformRequest{Platform: "webhook"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ResumePlatform() of a running platform error = %v, want FailedPrecondition", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package http provides the admin endpoints of the HTTP server, which
+// pause platforms and drain queues at runtime
+package http
+
+import (
+	stdhttp "net/http"
+)
+
+// WithAdmin serves the admin endpoints, authenticated by auth instead of
+// the authenticator of the handler:
+//
+//	GET  /v1/admin/controls                  the paused platforms and queue lengths
+//	POST /v1/admin/platforms/{name}/pause    hold the deliveries of a platform
+//	POST /v1/admin/platforms/{name}/resume   release them and deliver again
+//	POST /v1/admin/queues/{name}/drain       remove the items waiting in a queue
+//
+// Queues are notifyhub.QueueAsync, notifyhub.QueueHeld or the name of a
+// paused platform. The endpoints are not served without an authenticator,
+// and admin credentials should be kept apart from those of senders.
+func WithAdmin(auth Authenticator) Option {
+	return func(h *Handler) {
+		h.adminAuth = auth
+	}
+}
+
+// admin wraps the handler of an admin endpoint with the admin
+// authenticator and the request metrics
+func (h *Handler) admin(route string, next stdhttp.HandlerFunc) stdhttp.HandlerFunc {
+	return h.counted(route, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		if err := h.adminAuth(r); err != nil {
+			h.writeError(w, stdhttp.StatusUnauthorized, err)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// getControls serves GET /v1/admin/controls
+func (h *Handler) getControls(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	h.writeJSON(w, stdhttp.StatusOK, h.service.Controls())
+}
+
+// postPause serves POST /v1/admin/platforms/{name}/pause
+func (h *Handler) postPause(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	name := r.PathValue("name")
+	if err := h.service.PausePlatform(name); err != nil {
+		h.writeError(w, stdhttp.StatusNotFound, err)
+		return
+	}
+	h.logger.Warn("Platform paused through the admin API", "platform", name, "remote_addr", r.RemoteAddr)
+	h.writeJSON(w, stdhttp.StatusOK, map[string]interface{}{"platform": name, "paused": true})
+}
+
+// postResume serves POST /v1/admin/platforms/{name}/resume
+func (h *Handler) postResume(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	name := r.PathValue("name")
+	released, err := h.service.ResumePlatform(name)
+	if err != nil {
+		h.writeError(w, stdhttp.StatusConflict, err)
+		return
+	}
+	h.logger.Info("Platform resumed through the admin API", "platform", name, "released", released, "remote_addr", r.RemoteAddr)
+	h.writeJSON(w, stdhttp.StatusOK, map[string]interface{}{"platform": name, "paused": false, "released": released})
+}
+
+// postDrain serves POST /v1/admin/queues/{name}/drain
+func (h *Handler) postDrain(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	name := r.PathValue("name")
+	drained, err := h.service.DrainQueue(name)
+	if err != nil {
+		h.writeError(w, stdhttp.StatusNotFound, err)
+		return
+	}
+	h.logger.Warn("Queue drained through the admin API", "queue", name, "drained", drained, "remote_addr", r.RemoteAddr)
+	h.writeJSON(w, stdhttp.StatusOK, map[string]interface{}{"queue": name, "drained": drained})
+}
@@ -0,0 +1,43 @@
+// Package http provides the Alertmanager receiver of the HTTP server, which
+// makes NotifyHub a notification backend of Prometheus Alertmanager:
+//
+//	receivers:
+//	  - name: notifyhub
+//	    webhook_configs:
+//	      - url: http://notifyhub:8080/v1/receivers/alertmanager
+//	        http_config:
+//	          authorization: {credentials: s3cret}
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdhttp "net/http"
+
+	"github.com/kart-io/notifyhub/pkg/alertmanager"
+)
+
+// postAlertmanager serves POST /v1/receivers/alertmanager: every alert of
+// the notification is sent as a message (see alertmanager.Payload.Messages)
+// to the targets of its route
+func (h *Handler) postAlertmanager(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	var payload alertmanager.Payload
+	if err := json.NewDecoder(stdhttp.MaxBytesReader(w, r.Body, h.maxBodyBytes)).Decode(&payload); err != nil {
+		h.writeError(w, stdhttp.StatusBadRequest, fmt.Errorf("invalid alertmanager notification: %w", err))
+		return
+	}
+	if len(payload.Alerts) == 0 {
+		h.writeError(w, stdhttp.StatusBadRequest, errors.New("invalid alertmanager notification: no alerts"))
+		return
+	}
+
+	ctx := h.sendContext(r)
+	result, err := h.dispatchReceived(ctx, "alertmanager", payload.Messages())
+	if err != nil {
+		// Alertmanager retries the notification after a 5xx response
+		h.writeError(w, stdhttp.StatusBadGateway, err)
+		return
+	}
+	h.writeJSON(w, stdhttp.StatusOK, result)
+}
@@ -0,0 +1,149 @@
+// Package http provides the audit export endpoint of the HTTP server, which
+// serves evidence packages of the audit log to auditors
+package http
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	stdhttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/audit"
+)
+
+// ErrForbidden is returned by an AuditAuthorizer to reject an
+// authenticated request with 403 Forbidden
+var ErrForbidden = errors.New("forbidden")
+
+// AllTenants is the tenant scope of audit tokens that export every tenant
+const AllTenants = "*"
+
+// AuditAuthorizer authorizes the audit export, returning the tenant the
+// request may export, or AllTenants. An error rejects the request with 401
+// Unauthorized, or 403 Forbidden when it wraps ErrForbidden.
+type AuditAuthorizer func(r *stdhttp.Request) (tenant string, err error)
+
+// AuditTokens authorizes requests with an "Authorization: Bearer <token>"
+// header carrying one of the tokens, which map to the tenant they may
+// export or to AllTenants. Audit tokens are kept apart from the tokens of
+// the message endpoints, so that senders cannot read the audit log.
+func AuditTokens(tokens map[string]string) AuditAuthorizer {
+	return func(r *stdhttp.Request) (string, error) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return "", errors.New("missing bearer token")
+		}
+		for valid, tenant := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+				return tenant, nil
+			}
+		}
+		return "", errors.New("invalid bearer token")
+	}
+}
+
+// WithAuditExport serves GET /v1/audit/export from the entries of a
+// source, authorized by authorize instead of the authenticator of the
+// handler. The endpoint is not served without an authorizer.
+func WithAuditExport(source audit.Source, authorize AuditAuthorizer) Option {
+	return func(h *Handler) {
+		h.auditSource = source
+		h.auditAuthorize = authorize
+	}
+}
+
+// getAuditExport serves GET /v1/audit/export: an evidence package (see
+// audit.Export) of the entries selected by the query parameters
+//
+//	from, to         RFC 3339 times or dates, to excluded
+//	tenant, sender   the tenant and user of the entries
+//	action           the actions of the entries, repeated or comma-separated
+//	exclude_content  true to leave out message titles and bodies
+//
+// Tokens scoped to a tenant export that tenant only.
+func (h *Handler) getAuditExport(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	scope, err := h.auditAuthorize(r)
+	if err != nil {
+		status := stdhttp.StatusUnauthorized
+		if errors.Is(err, ErrForbidden) {
+			status = stdhttp.StatusForbidden
+		}
+		h.writeError(w, status, err)
+		return
+	}
+	opts, err := auditExportOptions(r)
+	if err != nil {
+		h.writeError(w, stdhttp.StatusBadRequest, err)
+		return
+	}
+	if scope != AllTenants {
+		if scope == "" || opts.Filter.Tenant != "" && opts.Filter.Tenant != scope {
+			h.writeError(w, stdhttp.StatusForbidden, fmt.Errorf("%w: the token may not export tenant %q", ErrForbidden, opts.Filter.Tenant))
+			return
+		}
+		opts.Filter.Tenant = scope
+	}
+
+	entries, err := h.auditSource.Query(r.Context(), opts.Filter)
+	if err != nil {
+		h.writeError(w, stdhttp.StatusInternalServerError, err)
+		return
+	}
+	var buf bytes.Buffer
+	manifest, err := audit.Export(&buf, entries, opts)
+	if err != nil {
+		h.writeError(w, stdhttp.StatusInternalServerError, err)
+		return
+	}
+	h.logger.Info("Exported audit evidence", "tenant", opts.Filter.Tenant, "entries", manifest.Entries, "content_excluded", manifest.ContentExcluded)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.zip"`, manifest.GeneratedAt.Format("20060102T150405Z")))
+	w.Header().Set("X-Audit-Entries-SHA256", manifest.EntriesSHA256)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		h.logger.Warn("Failed to write response", "error", err)
+	}
+}
+
+// auditExportOptions parses the query parameters of an audit export
+func auditExportOptions(r *stdhttp.Request) (audit.ExportOptions, error) {
+	query := r.URL.Query()
+	var opts audit.ExportOptions
+	var err error
+	if opts.Filter.From, err = parseAuditTime(query.Get("from")); err != nil {
+		return opts, fmt.Errorf("invalid from: %w", err)
+	}
+	if opts.Filter.To, err = parseAuditTime(query.Get("to")); err != nil {
+		return opts, fmt.Errorf("invalid to: %w", err)
+	}
+	opts.Filter.Tenant = query.Get("tenant")
+	opts.Filter.Sender = query.Get("sender")
+	for _, actions := range query["action"] {
+		for _, action := range strings.Split(actions, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				opts.Filter.Actions = append(opts.Filter.Actions, action)
+			}
+		}
+	}
+	if v := query.Get("exclude_content"); v != "" {
+		if opts.ExcludeContent, err = strconv.ParseBool(v); err != nil {
+			return opts, fmt.Errorf("invalid exclude_content: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+// parseAuditTime parses an RFC 3339 time or a date in UTC; empty is the
+// zero time
+func parseAuditTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,217 @@
+// Package http provides the CloudEvents receiver of the HTTP server, which
+// lets any CloudEvents producer send messages: the events of the mapped
+// types are rendered by the templates of their mapping
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdhttp "net/http"
+	"strings"
+	"text/template"
+
+	"github.com/kart-io/notifyhub/pkg/cloudevents"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// CloudEventMapping maps the CloudEvents of a type to messages
+type CloudEventMapping struct {
+	// Type is the event type, or a prefix ending in * such as
+	// "com.example.orders.*"
+	Type string `json:"type"`
+
+	// Title and Body are Go text/templates over the event, with .ID,
+	// .Source, .Type, .Subject, .Time, .Extensions and the decoded .Data;
+	// the title defaults to the subject, or else the type, and the body to
+	// the data
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+
+	Format   message.Format    `json:"format,omitempty"`
+	Priority *message.Priority `json:"priority,omitempty"`
+
+	// Targets receive the messages of the events; without them the routes
+	// of the handler match the type, source, subject and extensions of the
+	// event as labels
+	Targets []target.Target `json:"targets,omitempty"`
+}
+
+// Validate checks the templates of the mapping
+func (m CloudEventMapping) Validate() error {
+	_, err := m.compile()
+	return err
+}
+
+// cloudEventMapping is a mapping with its templates parsed
+type cloudEventMapping struct {
+	CloudEventMapping
+	title, body *template.Template // nil for the defaults
+	err         error              // of an invalid mapping
+}
+
+// compile parses the templates of the mapping
+func (m CloudEventMapping) compile() (*cloudEventMapping, error) {
+	if m.Type == "" {
+		return nil, errors.New("cloudevent mapping needs a type")
+	}
+	parse := func(text string) (*template.Template, error) {
+		if text == "" {
+			return nil, nil
+		}
+		tmpl, err := template.New(m.Type).Funcs(cloudEventFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template of cloudevent type %s: %w", m.Type, err)
+		}
+		return tmpl, nil
+	}
+
+	c := &cloudEventMapping{CloudEventMapping: m}
+	var err error
+	if c.title, err = parse(m.Title); err != nil {
+		return nil, err
+	}
+	if c.body, err = parse(m.Body); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// matches reports whether the mapping maps an event type
+func (m *cloudEventMapping) matches(eventType string) bool {
+	if prefix, ok := strings.CutSuffix(m.Type, "*"); ok {
+		return strings.HasPrefix(eventType, prefix)
+	}
+	return m.Type == eventType
+}
+
+// cloudEventFuncs are the functions of mapping templates: json encodes a
+// value as JSON
+var cloudEventFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// cloudEventData is what mapping templates are executed with
+type cloudEventData struct {
+	*cloudevents.Event
+	Data interface{}
+}
+
+// message renders the message of an event
+func (m *cloudEventMapping) message(e *cloudevents.Event) (*message.Message, error) {
+	data := cloudEventData{Event: e, Data: e.DecodedData()}
+	render := func(tmpl *template.Template) (string, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("failed to render cloudevent %s: %w", e.Type, err)
+		}
+		return buf.String(), nil
+	}
+
+	msg := message.New()
+	msg.Title = e.Subject
+	if msg.Title == "" {
+		msg.Title = e.Type
+	}
+	msg.Body = string(e.Data)
+	var err error
+	if m.title != nil {
+		if msg.Title, err = render(m.title); err != nil {
+			return nil, err
+		}
+	}
+	if m.body != nil {
+		if msg.Body, err = render(m.body); err != nil {
+			return nil, err
+		}
+	}
+	if m.Format != "" {
+		msg.Format = m.Format
+	}
+	if m.Priority != nil {
+		msg.Priority = *m.Priority
+	}
+	msg.Targets = append(msg.Targets, m.Targets...)
+	return msg, nil
+}
+
+// WithCloudEvents sets the mappings of CloudEvents types to messages; the
+// first mapping of its type maps an event, and events of other types are
+// rejected. Mappings with invalid templates reject their events, see
+// CloudEventMapping.Validate.
+func WithCloudEvents(mappings ...CloudEventMapping) Option {
+	return func(h *Handler) {
+		h.cloudEventMappings = mappings
+	}
+}
+
+// compileCloudEvents parses the templates of the CloudEvents mappings
+func (h *Handler) compileCloudEvents() {
+	h.cloudEvents = make([]*cloudEventMapping, 0, len(h.cloudEventMappings))
+	for _, m := range h.cloudEventMappings {
+		c, err := m.compile()
+		if err != nil {
+			h.logger.Error("Invalid cloudevent mapping", "type", m.Type, "error", err)
+			c = &cloudEventMapping{CloudEventMapping: m}
+			c.err = err
+		}
+		h.cloudEvents = append(h.cloudEvents, c)
+	}
+}
+
+// postCloudEvent serves POST /v1/receivers/cloudevents: the event is sent
+// as the message of its mapping, and the response holds the message ID
+func (h *Handler) postCloudEvent(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	r.Body = stdhttp.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	event, err := cloudevents.ReadRequest(r)
+	if errors.Is(err, cloudevents.ErrBatchUnsupported) {
+		h.writeError(w, stdhttp.StatusUnsupportedMediaType, err)
+		return
+	}
+	if err != nil {
+		h.writeError(w, stdhttp.StatusBadRequest, err)
+		return
+	}
+
+	var mapping *cloudEventMapping
+	for _, m := range h.cloudEvents {
+		if m.matches(event.Type) {
+			mapping = m
+			break
+		}
+	}
+	if mapping == nil {
+		h.writeError(w, stdhttp.StatusUnprocessableEntity, fmt.Errorf("no mapping for cloudevent type %s", event.Type))
+		return
+	}
+	if mapping.err != nil {
+		h.writeError(w, stdhttp.StatusInternalServerError, mapping.err)
+		return
+	}
+	msg, err := mapping.message(event)
+	if err != nil {
+		h.writeError(w, stdhttp.StatusUnprocessableEntity, err)
+		return
+	}
+	if len(msg.Targets) == 0 {
+		labels := make(map[string]string, len(event.Extensions)+3)
+		for name, value := range event.Extensions {
+			labels[name] = value
+		}
+		labels["type"], labels["source"], labels["subject"] = event.Type, event.Source, event.Subject
+		msg.Targets = append(msg.Targets, h.routeTargets(labels)...)
+	}
+
+	ctx := h.sendContext(r)
+	result, err := h.dispatchReceived(ctx, "cloudevents", []*message.Message{msg})
+	if err != nil {
+		h.writeError(w, stdhttp.StatusBadGateway, err)
+		return
+	}
+	h.writeJSON(w, stdhttp.StatusOK, result)
+}
@@ -0,0 +1,36 @@
+// Package http provides the Grafana receiver of the HTTP server, which
+// accepts the webhook notifications of Grafana unified alerting contact
+// points and of legacy dashboard alerts
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdhttp "net/http"
+
+	"github.com/kart-io/notifyhub/pkg/grafana"
+)
+
+// postGrafana serves POST /v1/receivers/grafana: every alert of the
+// notification is sent as a message (see grafana.Payload.Messages) to the
+// targets of its route
+func (h *Handler) postGrafana(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	var payload grafana.Payload
+	if err := json.NewDecoder(stdhttp.MaxBytesReader(w, r.Body, h.maxBodyBytes)).Decode(&payload); err != nil {
+		h.writeError(w, stdhttp.StatusBadRequest, fmt.Errorf("invalid grafana notification: %w", err))
+		return
+	}
+	if len(payload.Alerts) == 0 && !payload.Legacy() {
+		h.writeError(w, stdhttp.StatusBadRequest, errors.New("invalid grafana notification: no alerts"))
+		return
+	}
+
+	ctx := h.sendContext(r)
+	result, err := h.dispatchReceived(ctx, "grafana", payload.Messages())
+	if err != nil {
+		h.writeError(w, stdhttp.StatusBadGateway, err)
+		return
+	}
+	h.writeJSON(w, stdhttp.StatusOK, result)
+}
@@ -0,0 +1,430 @@
+// Package http serves the NotifyHub API over HTTP with JSON bodies:
+//
+//	POST /v1/messages                send a message, directly or queued
+//	GET  /v1/messages/{id}           the state and receipt of a send
+//	POST /v1/receivers/alertmanager  receive Alertmanager notifications
+//	POST /v1/receivers/grafana       receive Grafana alert notifications
+//	POST /v1/receivers/sentry        receive Sentry issue webhooks
+//	POST /v1/receivers/cloudevents   receive CloudEvents
+//	GET  /v1/usage                   the usage of the usage quotas
+//	     /v1/admin/...               pause platforms and drain queues, see WithAdmin
+//	GET  /v1/audit/export            an evidence package of the audit log
+//	GET  /v1/health                  the health of the hub and its platforms
+//	GET  /metrics                    Prometheus metrics
+//
+// A message is posted as the JSON encoding of message.Message with an
+// optional "dispatch" of "direct" (send before responding, 200) or
+// "queued" (respond with 202 and send through the asynchronous queue):
+//
+//	{"title": "Disk full", "body": "/var at 95%", "format": "markdown",
+//	 "targets": [{"type": "email", "value": "ops@example.com"}],
+//	 "dispatch": "queued"}
+//
+// Unknown fields are rejected, and messages are validated before they are
+// sent. A "scheduled_at" in the future is rejected, as there is no
+// scheduler to hold the message until then. An X-Request-ID header is kept as the request ID of the send (see
+// package sendctx). Errors are reported as {"error": "..."} with a 4xx or 5xx status.
+// Sends over a usage quota are rejected with 429 Too Many Requests; the
+// app of a request, identified by WithApps, is counted in usage quotas.
+// Receipts show target addresses partially masked (c***@gmail.com), unless
+// an unmask hook permits the request to see them (see WithUnmask).
+//
+// Receivers accept the notifications of other systems and send them as
+// messages to the targets of their routes (see WithRoutes), directly or
+// queued as the handler dispatches by default.
+package http
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdhttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/audit"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/redact"
+	"github.com/kart-io/notifyhub/pkg/server"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// Dispatch modes of posted messages
+const (
+	DispatchDirect = "direct"
+	DispatchQueued = "queued"
+)
+
+// DefaultMaxBodyBytes is the default size limit of posted messages
+const DefaultMaxBodyBytes = 1 << 20
+
+// Authenticator authorizes the requests to the message endpoints,
+// returning an error to reject a request with 401 Unauthorized. The health
+// and metrics endpoints are not authenticated, so that probes and scrapers
+// need no credentials.
+type Authenticator func(r *stdhttp.Request) error
+
+// BearerTokens accepts requests with an "Authorization: Bearer <token>"
+// header carrying one of the tokens
+func BearerTokens(tokens ...string) Authenticator {
+	return func(r *stdhttp.Request) error {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return errors.New("missing bearer token")
+		}
+		for _, valid := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+				return nil
+			}
+		}
+		return errors.New("invalid bearer token")
+	}
+}
+
+// Unmasker permits a request to see unmasked target addresses, such as
+// for callers with an administrator role
+type Unmasker func(r *stdhttp.Request) bool
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithAuth sets the authenticator of the message endpoints
+func WithAuth(auth Authenticator) Option {
+	return func(h *Handler) {
+		h.auth = auth
+	}
+}
+
+// WithDispatch sets the dispatch of messages that do not choose one,
+// DispatchDirect by default
+func WithDispatch(dispatch string) Option {
+	return func(h *Handler) {
+		h.dispatch = dispatch
+	}
+}
+
+// WithValidator sets the validator of posted messages. By default messages
+// need targets and a title or body within the message.Validator limits.
+func WithValidator(v *message.Validator) Option {
+	return func(h *Handler) {
+		h.validator = v
+	}
+}
+
+// WithMaxBodyBytes sets the size limit of posted messages
+func WithMaxBodyBytes(n int64) Option {
+	return func(h *Handler) {
+		h.maxBodyBytes = n
+	}
+}
+
+// WithTargetMask sets how receipts show target addresses,
+// redact.MaskTarget by default (c***@gmail.com); nil shows them unmasked.
+// redact.TargetMasker returns the mask of a configured mode.
+func WithTargetMask(mask func(string) string) Option {
+	return func(h *Handler) {
+		h.targetMask = mask
+	}
+}
+
+// WithUnmask lets the requests the hook permits see unmasked target
+// addresses by adding unmask=true to the query; without a hook, or when it
+// refuses, such requests are rejected with 403 Forbidden
+func WithUnmask(allow Unmasker) Option {
+	return func(h *Handler) {
+		h.unmask = allow
+	}
+}
+
+// WithLogger sets the logger of the handler
+func WithLogger(l logger.Logger) Option {
+	return func(h *Handler) {
+		h.logger = l
+	}
+}
+
+// Handler serves the NotifyHub API of a server.Service
+type Handler struct {
+	service            *server.Service
+	auth               Authenticator
+	dispatch           string
+	validator          *message.Validator
+	maxBodyBytes       int64
+	routes             []Route
+	sentrySecret       string
+	sentryIssues       *issueDedupe
+	cloudEventMappings []CloudEventMapping
+	cloudEvents        []*cloudEventMapping
+	identifyApp        AppIdentifier
+	adminAuth          Authenticator
+	auditSource        audit.Source
+	auditAuthorize     AuditAuthorizer
+	targetMask         func(string) string
+	unmask             Unmasker
+	logger             logger.Logger
+	metrics            *requestMetrics
+	mux                *stdhttp.ServeMux
+}
+
+// NewHandler creates the HTTP handler of a service
+func NewHandler(service *server.Service, opts ...Option) *Handler {
+	h := &Handler{
+		service:      service,
+		dispatch:     DispatchDirect,
+		validator:    message.NewValidator(message.ValidatorConfig{RequireTargets: true}),
+		maxBodyBytes: DefaultMaxBodyBytes,
+		logger:       logger.Discard,
+		metrics:      newRequestMetrics(),
+		sentryIssues: newIssueDedupe(),
+		targetMask:   redact.MaskTarget,
+		mux:          stdhttp.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.compileCloudEvents()
+
+	h.mux.HandleFunc("POST /v1/messages", h.authenticated("/v1/messages", h.postMessage))
+	h.mux.HandleFunc("GET /v1/messages/{id}", h.authenticated("/v1/messages/{id}", h.getMessage))
+	h.mux.HandleFunc("POST /v1/receivers/alertmanager", h.authenticated("/v1/receivers/alertmanager", h.postAlertmanager))
+	h.mux.HandleFunc("POST /v1/receivers/grafana", h.authenticated("/v1/receivers/grafana", h.postGrafana))
+	h.mux.HandleFunc("POST /v1/receivers/sentry", h.counted("/v1/receivers/sentry", h.postSentry))
+	h.mux.HandleFunc("POST /v1/receivers/cloudevents", h.authenticated("/v1/receivers/cloudevents", h.postCloudEvent))
+	h.mux.HandleFunc("GET /v1/usage", h.authenticated("/v1/usage", h.getUsage))
+	if h.adminAuth != nil {
+		h.mux.HandleFunc("GET /v1/admin/controls", h.admin("/v1/admin/controls", h.getControls))
+		h.mux.HandleFunc("POST /v1/admin/platforms/{name}/pause", h.admin("/v1/admin/platforms/{name}/pause", h.postPause))
+		h.mux.HandleFunc("POST /v1/admin/platforms/{name}/resume", h.admin("/v1/admin/platforms/{name}/resume", h.postResume))
+		h.mux.HandleFunc("POST /v1/admin/queues/{name}/drain", h.admin("/v1/admin/queues/{name}/drain", h.postDrain))
+	}
+	if h.auditAuthorize != nil {
+		h.mux.HandleFunc("GET /v1/audit/export", h.counted("/v1/audit/export", h.getAuditExport))
+	}
+	h.mux.HandleFunc("GET /v1/health", h.counted("/v1/health", h.getHealth))
+	h.mux.HandleFunc("GET /metrics", h.getMetrics)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// messageRequest is the body of POST /v1/messages
+type messageRequest struct {
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title"`
+	Body        string                 `json:"body"`
+	Format      message.Format         `json:"format"`
+	Priority    *message.Priority      `json:"priority"`
+	Targets     []target.Target        `json:"targets"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	Variables   map[string]interface{} `json:"variables"`
+	ScheduledAt *time.Time             `json:"scheduled_at"`
+	Dispatch    string                 `json:"dispatch"`
+}
+
+// message returns the message of the request
+func (req *messageRequest) message() (*message.Message, error) {
+	msg := message.New()
+	msg.ID = req.ID
+	if msg.ID == "" {
+		id, err := newMessageID()
+		if err != nil {
+			return nil, err
+		}
+		msg.ID = id
+	}
+	msg.Title, msg.Body = req.Title, req.Body
+	if req.Format != "" {
+		msg.Format = req.Format
+	}
+	if req.Priority != nil {
+		msg.Priority = *req.Priority
+	}
+	msg.Targets = req.Targets
+	if req.Metadata != nil {
+		msg.Metadata = req.Metadata
+	}
+	if req.Variables != nil {
+		msg.Variables = req.Variables
+	}
+	msg.ScheduledAt = req.ScheduledAt
+	return msg, nil
+}
+
+// newMessageID returns a random message ID
+func newMessageID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "msg-" + hex.EncodeToString(b), nil
+}
+
+// postMessage serves POST /v1/messages
+func (h *Handler) postMessage(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	mask, err := h.mask(r)
+	if err != nil {
+		h.writeError(w, stdhttp.StatusForbidden, err)
+		return
+	}
+	decoder := json.NewDecoder(stdhttp.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	var req messageRequest
+	if err := decoder.Decode(&req); err != nil {
+		h.writeError(w, stdhttp.StatusBadRequest, fmt.Errorf("invalid message: %w", err))
+		return
+	}
+
+	dispatch := req.Dispatch
+	if dispatch == "" {
+		dispatch = h.dispatch
+	}
+	if dispatch != DispatchDirect && dispatch != DispatchQueued {
+		h.writeError(w, stdhttp.StatusBadRequest, fmt.Errorf("dispatch must be %s or %s, got %q", DispatchDirect, DispatchQueued, dispatch))
+		return
+	}
+
+	msg, err := req.message()
+	if err != nil {
+		h.writeError(w, stdhttp.StatusInternalServerError, err)
+		return
+	}
+	err = msg.Validate()
+	if err == nil {
+		err = h.validator.Validate(msg)
+	}
+	if err == nil {
+		err = server.CheckSchedule(msg)
+	}
+	if err != nil {
+		h.writeError(w, stdhttp.StatusBadRequest, err)
+		return
+	}
+
+	ctx := h.sendContext(r)
+	if dispatch == DispatchQueued {
+		id, err := h.service.SendAsync(ctx, msg)
+		if err != nil {
+			h.writeError(w, sendErrorStatus(w, err, stdhttp.StatusBadRequest), err)
+			return
+		}
+		w.Header().Set("Location", "/v1/messages/"+id)
+		h.writeJSON(w, stdhttp.StatusAccepted, map[string]string{"id": id, "state": "pending"})
+		return
+	}
+
+	rcpt, err := h.service.Send(ctx, msg)
+	if err != nil {
+		h.writeError(w, sendErrorStatus(w, err, stdhttp.StatusBadGateway), err)
+		return
+	}
+	h.writeJSON(w, stdhttp.StatusOK, map[string]interface{}{"id": msg.ID, "receipt": rcpt.Masked(mask)})
+}
+
+// getMessage serves GET /v1/messages/{id}
+func (h *Handler) getMessage(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	mask, err := h.mask(r)
+	if err != nil {
+		h.writeError(w, stdhttp.StatusForbidden, err)
+		return
+	}
+	job, err := h.service.Status(r.PathValue("id"))
+	if errors.Is(err, server.ErrJobNotFound) {
+		h.writeError(w, stdhttp.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		h.writeError(w, stdhttp.StatusInternalServerError, err)
+		return
+	}
+	job.Receipt = job.Receipt.Masked(mask)
+	h.writeJSON(w, stdhttp.StatusOK, job)
+}
+
+// mask returns the mask of the target addresses a request may see: none
+// when it asks for unmasked targets with unmask=true and the unmask hook
+// permits it
+func (h *Handler) mask(r *stdhttp.Request) (func(string) string, error) {
+	unmask, _ := strconv.ParseBool(r.URL.Query().Get("unmask"))
+	if !unmask {
+		return h.targetMask, nil
+	}
+	if h.unmask == nil || !h.unmask(r) {
+		return nil, errors.New("unmasked targets are not permitted")
+	}
+	return nil, nil
+}
+
+// getHealth serves GET /v1/health, with 503 when the hub is unhealthy
+func (h *Handler) getHealth(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	health, err := h.service.Health(r.Context())
+	if err != nil {
+		h.writeError(w, stdhttp.StatusServiceUnavailable, err)
+		return
+	}
+	status := stdhttp.StatusOK
+	if health.Status == "unhealthy" {
+		status = stdhttp.StatusServiceUnavailable
+	}
+	h.writeJSON(w, status, health)
+}
+
+// authenticated wraps the handler of a message endpoint with the
+// authenticator and the request metrics
+func (h *Handler) authenticated(route string, next stdhttp.HandlerFunc) stdhttp.HandlerFunc {
+	return h.counted(route, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		if h.auth != nil {
+			if err := h.auth(r); err != nil {
+				h.writeError(w, stdhttp.StatusUnauthorized, err)
+				return
+			}
+		}
+		next(w, r)
+	})
+}
+
+// counted wraps a handler with the request metrics of its route
+func (h *Handler) counted(route string, next stdhttp.HandlerFunc) stdhttp.HandlerFunc {
+	return func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: stdhttp.StatusOK}
+		start := time.Now()
+		next(recorder, r)
+		h.metrics.observe(route, recorder.status, time.Since(start))
+	}
+}
+
+// statusRecorder records the status code of a response
+type statusRecorder struct {
+	stdhttp.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// writeJSON writes a JSON response
+func (h *Handler) writeJSON(w stdhttp.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Warn("Failed to write response", "error", err)
+	}
+}
+
+// writeError writes an error response
+func (h *Handler) writeError(w stdhttp.ResponseWriter, status int, err error) {
+	if status >= stdhttp.StatusInternalServerError {
+		h.logger.Error("Request failed", "status", status, "error", err)
+	}
+	h.writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,596 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/audit"
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+	"github.com/kart-io/notifyhub/pkg/server"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+func TestHandler(t *testing.T) {
+	webhook := httptest.NewServer(stdhttp.HandlerFunc(func(stdhttp.ResponseWriter, *stdhttp.Request) {}))
+	defer webhook.Close()
+
+	client, err := notifyhub.NewClientFromOptions(config.WithQuickWebhook(webhook.URL), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	api := httptest.NewServer(NewHandler(server.NewService(client), WithAuth(BearerTokens("s3cret"))))
+	defer api.Close()
+
+	do := func(method, path, token, body string) (*stdhttp.Response, map[string]interface{}) {
+		req, _ := stdhttp.NewRequest(method, api.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := stdhttp.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s error = %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&decoded)
+		return resp, decoded
+	}
+	targets := `"targets": [{"type": "webhook", "value": "` + webhook.URL + `"}]`
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"no token", "", `{"body": "disk full", ` + targets + `}`, stdhttp.StatusUnauthorized},
+		{"wrong token", "guess", `{"body": "disk full", ` + targets + `}`, stdhttp.StatusUnauthorized},
+		{"unknown field", "s3cret", `{"body": "disk full", "urgent": true, ` + targets + `}`, stdhttp.StatusBadRequest},
+		{"no targets", "s3cret", `{"body": "disk full"}`, stdhttp.StatusBadRequest},
+		{"empty", "s3cret", `{` + targets + `}`, stdhttp.StatusBadRequest},
+		{"invalid format", "s3cret", `{"body": "disk full", "format": "pdf", ` + targets + `}`, stdhttp.StatusBadRequest},
+		{"invalid dispatch", "s3cret", `{"body": "disk full", "dispatch": "later", ` + targets + `}`, stdhttp.StatusBadRequest},
+		{"scheduled", "s3cret", `{"body": "disk full", "scheduled_at": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `", ` + targets + `}`, stdhttp.StatusBadRequest},
+		{"direct", "s3cret", `{"id": "deploy-42", "title": "Deploy", "body": "done", ` + targets + `}`, stdhttp.StatusOK},
+	}
+	for _, tt := range tests {
+		if resp, body := do(stdhttp.MethodPost, "/v1/messages", tt.token, tt.body); resp.StatusCode != tt.status {
+			t.Errorf("%s: POST /v1/messages status = %d, want %d (%v)", tt.name, resp.StatusCode, tt.status, body)
+		}
+	}
+
+	if resp, job := do(stdhttp.MethodGet, "/v1/messages/deploy-42", "s3cret", ""); resp.StatusCode != stdhttp.StatusOK || job["state"] != "completed" {
+		t.Errorf("GET direct message = %d %v", resp.StatusCode, job)
+	}
+	if resp, _ := do(stdhttp.MethodGet, "/v1/messages/unknown", "s3cret", ""); resp.StatusCode != stdhttp.StatusNotFound {
+		t.Errorf("GET unknown message status = %d, want 404", resp.StatusCode)
+	}
+
+	resp, queued := do(stdhttp.MethodPost, "/v1/messages", "s3cret", `{"body": "disk full", "dispatch": "queued", `+targets+`}`)
+	id, _ := queued["id"].(string)
+	if resp.StatusCode != stdhttp.StatusAccepted || id == "" || resp.Header.Get("Location") != "/v1/messages/"+id {
+		t.Fatalf("POST queued message = %d %v", resp.StatusCode, queued)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, job := do(stdhttp.MethodGet, "/v1/messages/"+id, "s3cret", "")
+		if job["state"] == "completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queued message state = %v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if resp, health := do(stdhttp.MethodGet, "/v1/health", "", ""); resp.StatusCode != stdhttp.StatusOK || health["status"] != "healthy" {
+		t.Errorf("GET /v1/health = %d %v", resp.StatusCode, health)
+	}
+
+	req, _ := stdhttp.NewRequestWithContext(context.Background(), stdhttp.MethodGet, api.URL+"/metrics", nil)
+	metricsResp, err := stdhttp.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	defer metricsResp.Body.Close()
+	var metrics strings.Builder
+	_, _ = io.Copy(&metrics, metricsResp.Body)
+	for _, want := range []string{
+		`notifyhub_http_requests_total{route="/v1/messages",code="401"} 2`,
+		`notifyhub_http_requests_total{route="/v1/messages",code="202"} 1`,
+		`notifyhub_platform_up{platform="webhook"} 1`,
+		"notifyhub_messages_sent_total 2",
+	} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("metrics do not contain %s:\n%s", want, metrics.String())
+		}
+	}
+}
+
+func TestHandler_Receivers(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	webhook := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.URL.Path+" "+string(body))
+	}))
+	defer webhook.Close()
+
+	client, err := notifyhub.NewClientFromOptions(config.WithQuickWebhook(webhook.URL), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	high := message.PriorityHigh
+	api := httptest.NewServer(NewHandler(server.NewService(client), WithAuth(BearerTokens("s3cret")), WithSentrySecret("sentry-secret"), WithCloudEvents(
+		CloudEventMapping{Type: "com.example.order.failed", Title: `Order {{.Data.order}} failed`, Body: `{{.Data.reason}} ({{.Source}})`, Priority: &high, Targets: []target.Target{target.NewWebhook(webhook.URL + "/orders")}},
+		CloudEventMapping{Type: "com.example.db.*"},
+		CloudEventMapping{Type: "com.example.broken", Title: `{{.Nope`},
+	), WithRoutes(
+		Route{Match: map[string]string{"type": "com.example.db.lagging"}, Targets: []target.Target{target.NewWebhook(webhook.URL + "/db")}},
+		Route{Match: map[string]string{"team": "db", "severity": "critical"}, Targets: []target.Target{target.NewWebhook(webhook.URL + "/db-pager")}},
+		Route{Match: map[string]string{"team": "db"}, Targets: []target.Target{target.NewWebhook(webhook.URL + "/db")}},
+		Route{Match: map[string]string{"project": "billing"}, Targets: []target.Target{target.NewWebhook(webhook.URL + "/billing")}},
+	)))
+	defer api.Close()
+
+	post := func(path, token, contentType, body string) (*stdhttp.Response, map[string]interface{}) {
+		req, _ := stdhttp.NewRequest(stdhttp.MethodPost, api.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", contentType)
+		if token == "sentry-secret" {
+			mac := hmac.New(sha256.New, []byte(token))
+			mac.Write([]byte(body))
+			req.Header.Set("Sentry-Hook-Signature", hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, err := stdhttp.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s error = %v", path, err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&decoded)
+		return resp, decoded
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		token       string
+		contentType string
+		body        string
+		status      int
+		unrouted    float64
+		duplicates  float64
+		received    [][]string // path and parts of the body of each delivery
+	}{
+		{name: "unauthenticated", path: "/v1/receivers/alertmanager", token: "guess", body: `{"alerts": []}`, status: stdhttp.StatusUnauthorized},
+		{name: "alertmanager without alerts", path: "/v1/receivers/alertmanager", token: "s3cret", body: `{"version": "4", "alerts": []}`, status: stdhttp.StatusBadRequest},
+		{
+			name:  "alertmanager",
+			path:  "/v1/receivers/alertmanager",
+			token: "s3cret",
+			body: `{
+				"version": "4",
+				"groupKey": "{}:{alertname=\"DiskFull\"}",
+				"status": "firing",
+				"alerts": [
+					{"status": "firing", "labels": {"alertname": "DiskFull", "team": "db", "severity": "critical"}, "annotations": {"summary": "Disk full on db-1"}},
+					{"status": "resolved", "labels": {"alertname": "DiskFull", "team": "db", "severity": "warning"}, "annotations": {"summary": "Disk full on db-2"}},
+					{"status": "firing", "labels": {"alertname": "DiskFull", "team": "web"}, "annotations": {"summary": "Disk full on web-1"}}
+				]
+			}`,
+			status:   stdhttp.StatusOK,
+			unrouted: 1,
+			received: [][]string{
+				{"/db-pager", `"title":"Disk full on db-1"`, `"priority":2`},
+				{"/db", `"title":"[RESOLVED] Disk full on db-2"`, `"alert_status":"resolved"`},
+			},
+		},
+		{name: "grafana without alerts", path: "/v1/receivers/grafana", token: "s3cret", body: `{"status": "firing", "alerts": []}`, status: stdhttp.StatusBadRequest},
+		{
+			name:     "grafana legacy",
+			path:     "/v1/receivers/grafana",
+			token:    "s3cret",
+			body:     `{"ruleId": 7, "ruleName": "Replication lag", "state": "alerting", "title": "[Alerting] Replication lag", "tags": {"team": "db"}}`,
+			status:   stdhttp.StatusOK,
+			received: [][]string{{"/db", `"title":"[Alerting] Replication lag"`}},
+		},
+		{name: "sentry unsigned", path: "/v1/receivers/sentry", token: "s3cret", body: `{"action": "triggered", "data": {"triggered_rule": "New issues", "event": {"issue_id": "42", "project": 7, "title": "ZeroDivisionError", "culprit": "billing.invoice", "level": "error", "web_url": "https://sentry.io/issues/42/", "tags": [["project", "billing"], ["environment", "prod"]]}}}`, status: stdhttp.StatusUnauthorized},
+		{name: "sentry without issue", path: "/v1/receivers/sentry", token: "sentry-secret", body: `{"action": "triggered", "data": {}}`, status: stdhttp.StatusBadRequest},
+		{
+			name:     "sentry issue alert",
+			path:     "/v1/receivers/sentry",
+			token:    "sentry-secret",
+			body:     `{"action": "triggered", "data": {"triggered_rule": "New issues", "event": {"issue_id": "42", "project": 7, "title": "ZeroDivisionError", "culprit": "billing.invoice", "level": "error", "web_url": "https://sentry.io/issues/42/", "tags": [["project", "billing"], ["environment", "prod"]]}}}`,
+			status:   stdhttp.StatusOK,
+			received: [][]string{{"/billing", `"title":"[billing] ZeroDivisionError"`, `Culprit: billing.invoice`, `"priority":2`}},
+		},
+		{name: "sentry repeated event", path: "/v1/receivers/sentry", token: "sentry-secret", body: `{"action": "triggered", "data": {"triggered_rule": "New issues", "event": {"issue_id": "42", "project": 7, "title": "ZeroDivisionError", "culprit": "billing.invoice", "level": "error", "web_url": "https://sentry.io/issues/42/", "tags": [["project", "billing"], ["environment", "prod"]]}}}`, status: stdhttp.StatusOK, duplicates: 1},
+		{
+			name:     "sentry issue resolved",
+			path:     "/v1/receivers/sentry",
+			token:    "sentry-secret",
+			body:     `{"action": "resolved", "data": {"issue": {"id": "42", "title": "ZeroDivisionError", "status": "resolved", "project": {"slug": "billing"}}}}`,
+			status:   stdhttp.StatusOK,
+			received: [][]string{{"/billing", `"title":"[RESOLVED] [billing] ZeroDivisionError"`}},
+		},
+		{name: "cloudevents batch", path: "/v1/receivers/cloudevents", token: "s3cret", contentType: "application/cloudevents-batch+json", body: `[]`, status: stdhttp.StatusUnsupportedMediaType},
+		{name: "cloudevents invalid", path: "/v1/receivers/cloudevents", token: "s3cret", contentType: "application/cloudevents+json", body: `{"specversion": "1.0", "type": "com.example.order.failed"}`, status: stdhttp.StatusBadRequest},
+		{name: "cloudevents unmapped", path: "/v1/receivers/cloudevents", token: "s3cret", contentType: "application/cloudevents+json", body: `{"specversion": "1.0", "id": "1", "source": "/orders", "type": "com.example.order.created"}`, status: stdhttp.StatusUnprocessableEntity},
+		{name: "cloudevents invalid mapping", path: "/v1/receivers/cloudevents", token: "s3cret", contentType: "application/cloudevents+json", body: `{"specversion": "1.0", "id": "1", "source": "/", "type": "com.example.broken"}`, status: stdhttp.StatusInternalServerError},
+		{
+			name:        "cloudevents structured",
+			path:        "/v1/receivers/cloudevents",
+			token:       "s3cret",
+			contentType: "application/cloudevents+json",
+			body:        `{"specversion": "1.0", "id": "1", "source": "/shop/orders", "type": "com.example.order.failed", "data": {"order": "A-7", "reason": "card declined"}}`,
+			status:      stdhttp.StatusOK,
+			received:    [][]string{{"/orders", `"title":"Order A-7 failed"`, `"body":"card declined (/shop/orders)"`, `"priority":2`}},
+		},
+		{
+			name:        "cloudevents routed",
+			path:        "/v1/receivers/cloudevents",
+			token:       "s3cret",
+			contentType: "application/cloudevents+json",
+			body:        `{"specversion": "1.0", "id": "2", "source": "/db", "type": "com.example.db.lagging", "subject": "Replica db-2 lagging"}`,
+			status:      stdhttp.StatusOK,
+			received:    [][]string{{"/db", `"title":"Replica db-2 lagging"`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			received = nil
+			mu.Unlock()
+
+			resp, result := post(tt.path, tt.token, tt.contentType, tt.body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("POST status = %d %v, want %d", resp.StatusCode, result, tt.status)
+			}
+			if tt.status != stdhttp.StatusOK {
+				return
+			}
+			messages, _ := result["messages"].([]interface{})
+			duplicates, _ := result["duplicates"].(float64)
+			if len(messages) != len(tt.received) || result["unrouted"] != tt.unrouted || duplicates != tt.duplicates {
+				t.Errorf("POST result = %v, want %d messages, %v unrouted and %v duplicates", result, len(tt.received), tt.unrouted, tt.duplicates)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(received) != len(tt.received) {
+				t.Fatalf("webhook received %v", received)
+			}
+			for i, delivery := range received {
+				if !strings.HasPrefix(delivery, tt.received[i][0]+" ") {
+					t.Errorf("delivery %d = %s, want it sent to %s", i, delivery, tt.received[i][0])
+				}
+				for _, part := range tt.received[i][1:] {
+					if !strings.Contains(delivery, part) {
+						t.Errorf("delivery %d = %s, want %s", i, delivery, part)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestIssueDedupe(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	d := newIssueDedupe()
+	d.now = func() time.Time { return now }
+
+	steps := []struct {
+		advance  time.Duration
+		key      string
+		status   string
+		repeated bool
+	}{
+		{0, "sentry/issue/1", "firing", false},
+		{time.Minute, "sentry/issue/1", "firing", true},
+		{0, "sentry/issue/2", "firing", false},
+		{time.Minute, "sentry/issue/1", "resolved", false},
+		{time.Minute, "sentry/issue/1", "firing", false},
+		{DefaultSentryDedupeWindow, "sentry/issue/1", "firing", false},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		if got := d.repeated(step.key, step.status); got != step.repeated {
+			t.Errorf("step %d: repeated(%s, %s) = %v, want %v", i, step.key, step.status, got, step.repeated)
+		}
+	}
+	if len(d.sent) != 1 {
+		t.Errorf("expired issues were not dropped: %v", d.sent)
+	}
+
+	d.forget("sentry/issue/1")
+	if d.repeated("sentry/issue/1", "firing") {
+		t.Errorf("forgotten issue is repeated")
+	}
+	d.window = 0
+	if d.repeated("sentry/issue/1", "firing") {
+		t.Errorf("issue is repeated without a window")
+	}
+}
+
+func TestHandler_AuditExport(t *testing.T) {
+	client, err := notifyhub.NewClientFromOptions(config.WithQuickWebhook("http://127.0.0.1:1"), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	recorder := audit.NewMemoryRecorder(0)
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	for i, tenant := range []string{"acme", "acme", "globex"} {
+		_ = recorder.Record(context.Background(), audit.Entry{
+			Time:        day.Add(time.Duration(i) * time.Hour),
+			Action:      audit.ActionMessageSent,
+			Metadata:    map[string]string{"tenant": tenant},
+			ContentHash: audit.ContentHash("Deploy", ""),
+			Content:     &audit.Content{Title: "Deploy"},
+		})
+	}
+	tokens := AuditTokens(map[string]string{"auditor": AllTenants, "acme-dpo": "acme"})
+	api := httptest.NewServer(NewHandler(server.NewService(client), WithAuth(BearerTokens("s3cret")), WithAuditExport(recorder, tokens)))
+	defer api.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		query   string
+		status  int
+		entries int
+	}{
+		{"message token", "s3cret", "", stdhttp.StatusUnauthorized, 0},
+		{"all tenants", "auditor", "", stdhttp.StatusOK, 3},
+		{"tenant and dates", "auditor", "?tenant=acme&from=2026-10-16T00:30:00Z&to=2026-10-17", stdhttp.StatusOK, 1},
+		{"scoped token", "acme-dpo", "?exclude_content=true", stdhttp.StatusOK, 2},
+		{"scoped token of another tenant", "acme-dpo", "?tenant=globex", stdhttp.StatusForbidden, 0},
+		{"invalid date", "auditor", "?from=yesterday", stdhttp.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := stdhttp.NewRequest(stdhttp.MethodGet, api.URL+"/v1/audit/export"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			resp, err := stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status != stdhttp.StatusOK {
+				return
+			}
+			manifest, err := audit.Verify(bytes.NewReader(body), int64(len(body)))
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if manifest.Entries != tt.entries || manifest.EntriesSHA256 != resp.Header.Get("X-Audit-Entries-SHA256") {
+				t.Errorf("manifest = %+v, want %d entries", manifest, tt.entries)
+			}
+			if tt.token == "acme-dpo" && (manifest.Filter.Tenant != "acme" || !manifest.ContentExcluded) {
+				t.Errorf("manifest = %+v, want the acme entries without content", manifest)
+			}
+		})
+	}
+}
+
+func TestHandler_TargetMasking(t *testing.T) {
+	webhook := httptest.NewServer(stdhttp.HandlerFunc(func(stdhttp.ResponseWriter, *stdhttp.Request) {}))
+	defer webhook.Close()
+	client, err := notifyhub.NewClientFromOptions(config.WithQuickWebhook(webhook.URL), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	admin := func(r *stdhttp.Request) bool { return r.Header.Get("X-Role") == "admin" }
+	api := httptest.NewServer(NewHandler(server.NewService(client), WithUnmask(admin)))
+	defer api.Close()
+
+	hook := webhook.URL + "/hook/s3cret"
+	body := `{"body": "disk full", "targets": [{"type": "webhook", "value": "` + hook + `"}]}`
+	tests := []struct {
+		name   string
+		query  string
+		role   string
+		status int
+		target string
+	}{
+		{"masked by default", "", "", stdhttp.StatusOK, webhook.URL + "/******"},
+		{"unmasked for admins", "?unmask=true", "admin", stdhttp.StatusOK, hook},
+		{"unmask refused", "?unmask=true", "viewer", stdhttp.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := stdhttp.NewRequest(stdhttp.MethodPost, api.URL+"/v1/messages"+tt.query, strings.NewReader(body))
+			req.Header.Set("X-Role", tt.role)
+			resp, err := stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("POST error = %v", err)
+			}
+			defer resp.Body.Close()
+			var decoded struct {
+				ID      string `json:"id"`
+				Receipt struct {
+					Results []struct {
+						Target string `json:"target"`
+					} `json:"results"`
+				} `json:"receipt"`
+			}
+			_ = json.NewDecoder(resp.Body).Decode(&decoded)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != stdhttp.StatusOK {
+				return
+			}
+			if len(decoded.Receipt.Results) != 1 || decoded.Receipt.Results[0].Target != tt.target {
+				t.Errorf("receipt results = %+v, want target %s", decoded.Receipt.Results, tt.target)
+			}
+
+			req, _ = stdhttp.NewRequest(stdhttp.MethodGet, api.URL+"/v1/messages/"+decoded.ID+tt.query, nil)
+			req.Header.Set("X-Role", tt.role)
+			resp, err = stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer resp.Body.Close()
+			got, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(got), `"target":"`+tt.target+`"`) {
+				t.Errorf("GET job = %s, want target %s", got, tt.target)
+			}
+		})
+	}
+}
+
+func TestHandler_Usage(t *testing.T) {
+	webhook := httptest.NewServer(stdhttp.HandlerFunc(func(stdhttp.ResponseWriter, *stdhttp.Request) {}))
+	defer webhook.Close()
+	client, err := notifyhub.NewClientFromOptions(
+		config.WithQuickWebhook(webhook.URL),
+		config.WithUsageQuota(ratelimit.UsageQuota{App: ratelimit.AnySubject, Max: 1, Period: ratelimit.PeriodDay}),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	apps := map[string]string{"billing-key": "billing", "crm-key": "crm"}
+	api := httptest.NewServer(NewHandler(server.NewService(client), WithAuth(BearerTokens("billing-key", "crm-key")), WithApps(BearerTokenApps(apps))))
+	defer api.Close()
+
+	do := func(method, path, token, body string) (*stdhttp.Response, string) {
+		req, _ := stdhttp.NewRequest(method, api.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := stdhttp.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s error = %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	body := `{"body": "invoice ready", "targets": [{"type": "webhook", "value": "` + webhook.URL + `"}]}`
+	steps := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"first message", "billing-key", stdhttp.StatusOK},
+		{"over the quota", "billing-key", stdhttp.StatusTooManyRequests},
+		{"other app", "crm-key", stdhttp.StatusOK},
+	}
+	for _, step := range steps {
+		resp, got := do(stdhttp.MethodPost, "/v1/messages", step.token, body)
+		if resp.StatusCode != step.status {
+			t.Errorf("%s: status = %d, want %d: %s", step.name, resp.StatusCode, step.status, got)
+		}
+		if step.status == stdhttp.StatusTooManyRequests && (resp.Header.Get("Retry-After") == "" || !strings.Contains(got, "QUOTA_EXCEEDED")) {
+			t.Errorf("%s: Retry-After %q, body %s", step.name, resp.Header.Get("Retry-After"), got)
+		}
+	}
+
+	resp, got := do(stdhttp.MethodGet, "/v1/usage", "billing-key", "")
+	var decoded struct {
+		Usage []ratelimit.Usage `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil || resp.StatusCode != stdhttp.StatusOK {
+		t.Fatalf("GET /v1/usage = %d %s, error %v", resp.StatusCode, got, err)
+	}
+	if len(decoded.Usage) != 1 || decoded.Usage[0].App != "billing" || decoded.Usage[0].Used != 1 || decoded.Usage[0].Remaining != 0 {
+		t.Errorf("usage = %+v, want the usage of billing", decoded.Usage)
+	}
+	if resp, _ := do(stdhttp.MethodGet, "/v1/usage?app=crm", "billing-key", ""); resp.StatusCode != stdhttp.StatusForbidden {
+		t.Errorf("GET /v1/usage of another app status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestHandler_Admin(t *testing.T) {
+	webhook := httptest.NewServer(stdhttp.HandlerFunc(func(stdhttp.ResponseWriter, *stdhttp.Request) {}))
+	defer webhook.Close()
+	client, err := notifyhub.NewClientFromOptions(
+		config.WithQuickWebhook(webhook.URL),
+		config.WithLogger(logger.Discard),
+	)
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	api := httptest.NewServer(NewHandler(server.NewService(client), WithAuth(BearerTokens("sender-key")), WithAdmin(BearerTokens("admin-key"))))
+	defer api.Close()
+
+	do := func(method, path, token, body string) (int, string) {
+		req, _ := stdhttp.NewRequest(method, api.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := stdhttp.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s error = %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	body := `{"body": "deploy started", "targets": [{"type": "webhook", "value": "` + webhook.URL + `"}]}`
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		want   string
+	}{
+		{"sender token", stdhttp.MethodPost, "/v1/admin/platforms/webhook/pause", "sender-key", "", stdhttp.StatusUnauthorized, ""},
+		{"pause unknown", stdhttp.MethodPost, "/v1/admin/platforms/feishu/pause", "admin-key", "", stdhttp.StatusNotFound, "not configured"},
+		{"pause", stdhttp.MethodPost, "/v1/admin/platforms/webhook/pause", "admin-key", "", stdhttp.StatusOK, `"paused":true`},
+		{"send while paused", stdhttp.MethodPost, "/v1/messages", "sender-key", body, stdhttp.StatusOK, `"held"`},
+		{"controls", stdhttp.MethodGet, "/v1/admin/controls", "admin-key", "", stdhttp.StatusOK, `"paused_platforms":{"webhook":1}`},
+		{"drain unknown", stdhttp.MethodPost, "/v1/admin/queues/nope/drain", "admin-key", "", stdhttp.StatusNotFound, "unknown queue"},
+		{"resume", stdhttp.MethodPost, "/v1/admin/platforms/webhook/resume", "admin-key", "", stdhttp.StatusOK, `"released":1`},
+		{"resume again", stdhttp.MethodPost, "/v1/admin/platforms/webhook/resume", "admin-key", "", stdhttp.StatusConflict, "not paused"},
+		{"drain held", stdhttp.MethodPost, "/v1/admin/queues/held/drain", "admin-key", "", stdhttp.StatusOK, `"drained":0`},
+	}
+	for _, step := range steps {
+		status, got := do(step.method, step.path, step.token, step.body)
+		if status != step.status || !strings.Contains(got, step.want) {
+			t.Errorf("%s: %s %s = %d %s, want %d %s", step.name, step.method, step.path, status, got, step.status, step.want)
+		}
+	}
+
+	plain := httptest.NewServer(NewHandler(server.NewService(client)))
+	defer plain.Close()
+	resp, err := stdhttp.Post(plain.URL+"/v1/admin/platforms/webhook/pause", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != stdhttp.StatusNotFound {
+		t.Errorf("admin endpoint without WithAdmin status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,116 @@
+// Package http provides the Prometheus metrics of the HTTP server
+package http
+
+import (
+	"context"
+	"fmt"
+	stdhttp "net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthTimeout bounds the health check of a metrics scrape
+const healthTimeout = 10 * time.Second
+
+// requestKey identifies the requests counted together
+type requestKey struct {
+	route  string
+	status int
+}
+
+// requestMetrics counts the requests of the handler
+type requestMetrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]int64
+	seconds  map[string]float64 // route -> total request duration
+}
+
+// newRequestMetrics creates empty request metrics
+func newRequestMetrics() *requestMetrics {
+	return &requestMetrics{
+		requests: make(map[requestKey]int64),
+		seconds:  make(map[string]float64),
+	}
+}
+
+// observe counts a request
+func (m *requestMetrics) observe(route string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{route, status}]++
+	m.seconds[route] += duration.Seconds()
+}
+
+// write writes the request metrics in the Prometheus text format
+func (m *requestMetrics) write(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].status < keys[j].status
+	})
+	b.WriteString("# HELP notifyhub_http_requests_total HTTP requests served, by route and status code.\n")
+	b.WriteString("# TYPE notifyhub_http_requests_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(b, "notifyhub_http_requests_total{route=%q,code=\"%d\"} %d\n", key.route, key.status, m.requests[key])
+	}
+
+	routes := make([]string, 0, len(m.seconds))
+	for route := range m.seconds {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	b.WriteString("# HELP notifyhub_http_request_seconds_total Time spent serving HTTP requests, by route.\n")
+	b.WriteString("# TYPE notifyhub_http_request_seconds_total counter\n")
+	for _, route := range routes {
+		fmt.Fprintf(b, "notifyhub_http_request_seconds_total{route=%q} %g\n", route, m.seconds[route])
+	}
+}
+
+// getMetrics serves GET /metrics: the request metrics and the counters of
+// the client's health status
+func (h *Handler) getMetrics(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	var b strings.Builder
+	h.metrics.write(&b)
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+	if health, err := h.service.Health(ctx); err == nil {
+		gauge := func(name, help string, value float64) {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, value)
+		}
+		fmt.Fprintf(&b, "# HELP notifyhub_messages_sent_total Messages sent by the client.\n# TYPE notifyhub_messages_sent_total counter\nnotifyhub_messages_sent_total %d\n", health.TotalSent)
+		gauge("notifyhub_success_rate", "Percentage of deliveries that succeeded.", health.SuccessRate)
+		gauge("notifyhub_active_tasks", "Sends in progress.", float64(health.ActiveTasks))
+		gauge("notifyhub_queue_depth", "Messages waiting in the asynchronous queue.", float64(health.QueueDepth))
+		gauge("notifyhub_uptime_seconds", "Seconds since the client was created.", health.Uptime)
+
+		platforms := make([]string, 0, len(health.Platforms))
+		for name := range health.Platforms {
+			platforms = append(platforms, name)
+		}
+		sort.Strings(platforms)
+		b.WriteString("# HELP notifyhub_platform_up Whether a platform is healthy.\n# TYPE notifyhub_platform_up gauge\n")
+		for _, name := range platforms {
+			up := 0
+			if health.Platforms[name] == "healthy" {
+				up = 1
+			}
+			fmt.Fprintf(&b, "notifyhub_platform_up{platform=%q} %d\n", name, up)
+		}
+	} else {
+		h.logger.Warn("Failed to check health for metrics", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
@@ -0,0 +1,97 @@
+// Package http provides the receivers of the HTTP server, which accept the
+// notifications of other systems and send them on as messages
+package http
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/target"
+)
+
+// Route sends the messages of receivers whose alert labels match to
+// targets. Match holds the values the labels must have; a route without
+// Match matches every message.
+type Route struct {
+	Match   map[string]string `json:"match,omitempty"`
+	Targets []target.Target   `json:"targets"`
+}
+
+// matches reports whether the route matches a label set
+func (r Route) matches(labels map[string]string) bool {
+	for name, value := range r.Match {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// WithRoutes sets the routes of received messages. A message is sent to
+// the targets of the first route that matches its alert labels; messages
+// that no route matches are dropped.
+func WithRoutes(routes ...Route) Option {
+	return func(h *Handler) {
+		h.routes = routes
+	}
+}
+
+// routeTargets returns the targets of the first route that matches a
+// label set, nil when none does
+func (h *Handler) routeTargets(labels map[string]string) []target.Target {
+	for _, route := range h.routes {
+		if route.matches(labels) {
+			return route.Targets
+		}
+	}
+	return nil
+}
+
+// receivedResult is the response of a receiver
+type receivedResult struct {
+	ID         string   `json:"id,omitempty"`         // ID of the only message sent
+	Messages   []string `json:"messages"`             // IDs of the messages sent
+	Unrouted   int      `json:"unrouted"`             // messages no route matched
+	Duplicates int      `json:"duplicates,omitempty"` // repeats that were dropped
+}
+
+// dispatchReceived routes the messages of a receiver that have no targets
+// by their alert labels and sends them with the dispatch of the handler
+func (h *Handler) dispatchReceived(ctx context.Context, source string, msgs []*message.Message) (*receivedResult, error) {
+	result := &receivedResult{Messages: make([]string, 0, len(msgs))}
+	for _, msg := range msgs {
+		if len(msg.Targets) == 0 {
+			msg.Targets = append(msg.Targets, h.routeTargets(msg.AlertLabels())...)
+		}
+		if len(msg.Targets) == 0 {
+			h.logger.Warn("No route for received message", "source", source, "title", msg.Title, "labels", msg.AlertLabels())
+			result.Unrouted++
+			continue
+		}
+
+		id, err := newMessageID()
+		if err != nil {
+			return result, err
+		}
+		msg.ID = id
+		msg.SetTags(source)
+		if err := msg.Validate(); err != nil {
+			return result, fmt.Errorf("invalid %s message: %w", source, err)
+		}
+
+		if h.dispatch == DispatchQueued {
+			id, err = h.service.SendAsync(ctx, msg)
+		} else {
+			_, err = h.service.Send(ctx, msg)
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Messages = append(result.Messages, id)
+	}
+	if len(result.Messages) == 1 {
+		result.ID = result.Messages[0]
+	}
+	return result, nil
+}
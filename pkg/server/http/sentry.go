@@ -0,0 +1,135 @@
+// Package http provides the Sentry receiver of the HTTP server, which
+// accepts issue alert and issue webhooks of Sentry and sends each issue
+// once until it is resolved
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	stdhttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/sentry"
+)
+
+// DefaultSentryDedupeWindow is how long repeated events of a Sentry issue
+// are dropped by default
+const DefaultSentryDedupeWindow = time.Hour
+
+// WithSentrySecret sets the client secret of a Sentry integration, whose
+// webhooks are accepted by their Sentry-Hook-Signature instead of the
+// authenticator of the handler
+func WithSentrySecret(secret string) Option {
+	return func(h *Handler) {
+		h.sentrySecret = secret
+	}
+}
+
+// WithSentryDedupeWindow sets how long repeated events of a Sentry issue
+// are dropped after the issue was sent, so that an issue firing
+// repeatedly sends one message until it is resolved or the window passes;
+// zero sends every event
+func WithSentryDedupeWindow(window time.Duration) Option {
+	return func(h *Handler) {
+		h.sentryIssues.window = window
+	}
+}
+
+// postSentry serves POST /v1/receivers/sentry: the issue of the webhook is
+// sent as a message (see sentry.Payload.Messages) to the targets of its
+// route, unless it repeats an issue sent within the dedupe window
+func (h *Handler) postSentry(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	body, err := io.ReadAll(stdhttp.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil {
+		h.writeError(w, stdhttp.StatusBadRequest, fmt.Errorf("invalid sentry webhook: %w", err))
+		return
+	}
+	if h.sentrySecret != "" {
+		err = sentry.Verify(r.Header.Get(sentry.HeaderSignature), body, h.sentrySecret)
+	} else if h.auth != nil {
+		err = h.auth(r)
+	}
+	if err != nil {
+		h.writeError(w, stdhttp.StatusUnauthorized, err)
+		return
+	}
+
+	var payload sentry.Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		h.writeError(w, stdhttp.StatusBadRequest, fmt.Errorf("invalid sentry webhook: %w", err))
+		return
+	}
+	if !payload.Valid() {
+		h.writeError(w, stdhttp.StatusBadRequest, errors.New("invalid sentry webhook: no issue"))
+		return
+	}
+
+	messages := payload.Messages()
+	key, status := messages[0].AlertGroup(), messages[0].AlertStatus()
+	if h.sentryIssues.repeated(key, status) {
+		h.writeJSON(w, stdhttp.StatusOK, &receivedResult{Messages: []string{}, Duplicates: 1})
+		return
+	}
+
+	ctx := h.sendContext(r)
+	result, err := h.dispatchReceived(ctx, "sentry", messages)
+	if err != nil {
+		// Sentry may deliver the webhook again
+		h.sentryIssues.forget(key)
+		h.writeError(w, stdhttp.StatusBadGateway, err)
+		return
+	}
+	h.writeJSON(w, stdhttp.StatusOK, result)
+}
+
+// issueDedupe remembers the issues sent within a window
+type issueDedupe struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	sent map[string]sentIssue
+}
+
+// sentIssue is the status of an issue when it was sent
+type sentIssue struct {
+	status string
+	at     time.Time
+}
+
+// newIssueDedupe creates the dedupe of issues with the default window
+func newIssueDedupe() *issueDedupe {
+	return &issueDedupe{window: DefaultSentryDedupeWindow, now: time.Now, sent: make(map[string]sentIssue)}
+}
+
+// repeated reports whether an issue was sent with the same status within
+// the window, and remembers it as sent otherwise
+func (d *issueDedupe) repeated(key, status string) bool {
+	if d.window <= 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	for k, issue := range d.sent {
+		if now.Sub(issue.at) >= d.window {
+			delete(d.sent, k)
+		}
+	}
+	if issue, ok := d.sent[key]; ok && issue.status == status {
+		return true
+	}
+	d.sent[key] = sentIssue{status: status, at: now}
+	return false
+}
+
+// forget forgets an issue that could not be sent
+func (d *issueDedupe) forget(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.sent, key)
+}
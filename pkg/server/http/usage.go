@@ -0,0 +1,99 @@
+// Package http provides the usage endpoint of the HTTP server and the apps
+// that sends are counted against in usage quotas
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	stdhttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/sendctx"
+	"github.com/kart-io/notifyhub/pkg/server"
+)
+
+// AppIdentifier returns the app, or API key, a request is made by, which
+// usage quotas count the sends of; empty when the request names none
+type AppIdentifier func(r *stdhttp.Request) string
+
+// BearerTokenApps identifies apps by the token of their "Authorization:
+// Bearer <token>" header, mapping tokens to app names
+func BearerTokenApps(apps map[string]string) AppIdentifier {
+	return func(r *stdhttp.Request) string {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return ""
+		}
+		for valid, app := range apps {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+				return app
+			}
+		}
+		return ""
+	}
+}
+
+// WithApps identifies the app of each request, so that its sends carry it
+// (see sendctx.WithApp) and GET /v1/usage reports its own usage
+func WithApps(identify AppIdentifier) Option {
+	return func(h *Handler) {
+		h.identifyApp = identify
+	}
+}
+
+// sendContext returns the context of the sends of a request, with its
+// request ID and app
+func (h *Handler) sendContext(r *stdhttp.Request) context.Context {
+	ctx := sendctx.WithRequestID(r.Context(), r.Header.Get("X-Request-ID"))
+	if h.identifyApp != nil {
+		ctx = sendctx.WithApp(ctx, h.identifyApp(r))
+	}
+	return ctx
+}
+
+// sendErrorStatus returns the status of a failed send: 429 Too Many
+// Requests, with a Retry-After header, for sends over a usage quota, and
+// status otherwise
+func sendErrorStatus(w stdhttp.ResponseWriter, err error, status int) int {
+	if !errors.Is(err, notifyhub.ErrQuotaExceeded) {
+		return status
+	}
+	var notifyErr interface{ GetRetryDelay() time.Duration }
+	if errors.As(err, &notifyErr) {
+		if delay := notifyErr.GetRetryDelay(); delay > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(delay.Round(time.Second)/time.Second)))
+		}
+	}
+	return stdhttp.StatusTooManyRequests
+}
+
+// getUsage serves GET /v1/usage: the usage of the usage quotas of the
+// tenant and app of the query parameters in their current period. Requests
+// identified as an app (see WithApps) see the usage of that app only.
+func (h *Handler) getUsage(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	tenant, app := r.URL.Query().Get("tenant"), r.URL.Query().Get("app")
+	if h.identifyApp != nil {
+		if own := h.identifyApp(r); own != "" {
+			if app != "" && app != own {
+				h.writeError(w, stdhttp.StatusForbidden, fmt.Errorf("the request may not read the usage of app %q", app))
+				return
+			}
+			app = own
+		}
+	}
+	usage, err := h.service.Usage(r.Context(), tenant, app)
+	if errors.Is(err, server.ErrUsageNotSupported) {
+		h.writeError(w, stdhttp.StatusNotImplemented, err)
+		return
+	}
+	if err != nil {
+		h.writeError(w, stdhttp.StatusInternalServerError, err)
+		return
+	}
+	h.writeJSON(w, stdhttp.StatusOK, map[string]interface{}{"usage": usage})
+}
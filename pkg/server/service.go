@@ -0,0 +1,299 @@
+// Package server exposes a NotifyHub client to other processes. Service is
+// the transport-independent API that the REST server (pkg/server/http) and
+// the gRPC server (pkg/server/grpc, a module of its own) serve, so that
+// services written in other languages can use the hub as a sidecar or
+// central notification service.
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/async"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/ratelimit"
+	"github.com/kart-io/notifyhub/pkg/receipt"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+// ErrJobNotFound is returned for sends that are unknown or were forgotten
+// after the job retention
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrScheduleNotSupported is returned for messages scheduled for later:
+// the hub has no scheduler and would deliver them at once
+var ErrScheduleNotSupported = errors.New("scheduled_at is not supported: messages are sent at once, no scheduler is available")
+
+// ErrUsageNotSupported is returned for usage reads when the client does not
+// implement notifyhub.UsageReader
+var ErrUsageNotSupported = errors.New("usage is not supported by the client")
+
+// CheckSchedule rejects a message scheduled in the future with
+// ErrScheduleNotSupported, so that transports do not accept a delivery
+// time they cannot honor
+func CheckSchedule(msg *message.Message) error {
+	if msg.IsScheduled() {
+		return ErrScheduleNotSupported
+	}
+	return nil
+}
+
+// DefaultJobRetention is how long finished sends can be queried by default
+const DefaultJobRetention = time.Hour
+
+// subscriberBuffer is the number of receipts buffered for each subscriber
+const subscriberBuffer = 64
+
+// Job is the state of a send made through the service
+type Job struct {
+	ID          string               `json:"id"`
+	State       async.OperationState `json:"state"`
+	Receipt     *receipt.Receipt     `json:"receipt,omitempty"`
+	Error       string               `json:"error,omitempty"`
+	SubmittedAt time.Time            `json:"submitted_at"`
+	FinishedAt  *time.Time           `json:"finished_at,omitempty"`
+
+	handle async.Handle  // nil once finished
+	done   chan struct{} // closed once finished
+}
+
+// Option configures a Service
+type Option func(*Service)
+
+// WithLogger sets the logger of the service
+func WithLogger(l logger.Logger) Option {
+	return func(s *Service) {
+		s.logger = l
+	}
+}
+
+// WithJobRetention sets how long finished sends can be queried,
+// DefaultJobRetention by default
+func WithJobRetention(retention time.Duration) Option {
+	return func(s *Service) {
+		s.retention = retention
+	}
+}
+
+// Service serves the sends, job status, health and receipts of a client
+type Service struct {
+	client    notifyhub.Client
+	logger    logger.Logger
+	retention time.Duration
+	now       func() time.Time
+
+	mu          sync.Mutex
+	jobs        map[string]*Job
+	subscribers map[chan *receipt.Receipt]struct{}
+}
+
+// NewService creates a service for a client. It adds a middleware to the
+// client that publishes the receipt of every send to the subscribers of
+// Receipts, including sends made by the process itself.
+func NewService(client notifyhub.Client, opts ...Option) *Service {
+	s := &Service{
+		client:      client,
+		logger:      logger.Discard,
+		retention:   DefaultJobRetention,
+		now:         time.Now,
+		jobs:        make(map[string]*Job),
+		subscribers: make(map[chan *receipt.Receipt]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	client.Use(s.publishReceipts)
+	return s
+}
+
+// Send sends a message and waits for its receipt. The send is recorded as
+// a finished job, so Status reports on it like on asynchronous sends.
+func (s *Service) Send(ctx context.Context, msg *message.Message) (*receipt.Receipt, error) {
+	submitted := s.now()
+	rcpt, err := s.client.Send(ctx, msg)
+
+	finished := s.now()
+	job := &Job{
+		ID:          msg.ID,
+		State:       async.StateCompleted,
+		Receipt:     rcpt,
+		SubmittedAt: submitted,
+		FinishedAt:  &finished,
+		done:        make(chan struct{}),
+	}
+	if err != nil {
+		job.State = async.StateFailed
+		job.Error = err.Error()
+	}
+	close(job.done)
+
+	s.mu.Lock()
+	s.pruneJobs()
+	s.jobs[job.ID] = job
+	s.mu.Unlock()
+	return rcpt, err
+}
+
+// SendAsync queues a message and returns the ID of the job, which Status
+// reports on
+func (s *Service) SendAsync(ctx context.Context, msg *message.Message) (string, error) {
+	handle, err := s.client.SendAsync(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+
+	job := &Job{
+		ID:          handle.ID(),
+		State:       async.StatePending,
+		SubmittedAt: s.now(),
+		handle:      handle,
+		done:        make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.pruneJobs()
+	s.jobs[job.ID] = job
+	s.mu.Unlock()
+
+	go s.track(job)
+	return job.ID, nil
+}
+
+// track records the outcome of a job
+func (s *Service) track(job *Job) {
+	rcpt, err := job.handle.Wait(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	finished := s.now()
+	job.FinishedAt = &finished
+	job.Receipt = rcpt
+	job.State = async.StateCompleted
+	if err != nil {
+		job.State = async.StateFailed
+		job.Error = err.Error()
+	}
+	job.handle = nil
+	close(job.done)
+}
+
+// Status returns the state of a send
+func (s *Service) Status(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneJobs()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	status := *job
+	if job.handle != nil {
+		status.State = job.handle.Status().State
+	}
+	status.handle, status.done = nil, nil
+	return status, nil
+}
+
+// Wait waits for a send to finish and returns its state
+func (s *Service) Wait(ctx context.Context, id string) (Job, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	select {
+	case <-job.done:
+		return s.Status(id)
+	case <-ctx.Done():
+		return Job{}, ctx.Err()
+	}
+}
+
+// pruneJobs forgets the jobs finished longer than the retention ago; the
+// caller holds s.mu
+func (s *Service) pruneJobs() {
+	cutoff := s.now().Add(-s.retention)
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// Health returns the health of the client
+func (s *Service) Health(ctx context.Context) (*notifyhub.HealthStatus, error) {
+	return s.client.Health(ctx)
+}
+
+// Usage returns the usage of the usage quotas of a tenant and app
+func (s *Service) Usage(ctx context.Context, tenant, app string) ([]ratelimit.Usage, error) {
+	reader, ok := s.client.(notifyhub.UsageReader)
+	if !ok {
+		return nil, ErrUsageNotSupported
+	}
+	return reader.Usage(ctx, tenant, app)
+}
+
+// PausePlatform holds the deliveries of a platform until it is resumed
+func (s *Service) PausePlatform(name string) error {
+	return s.client.PausePlatform(name)
+}
+
+// ResumePlatform resumes a paused platform, returning the number of held
+// deliveries it releases
+func (s *Service) ResumePlatform(name string) (int, error) {
+	return s.client.ResumePlatform(name)
+}
+
+// DrainQueue removes the items waiting in a queue, returning how many
+func (s *Service) DrainQueue(name string) (int, error) {
+	return s.client.DrainQueue(name)
+}
+
+// Controls returns the paused platforms and the length of the queues
+func (s *Service) Controls() notifyhub.ControlStatus {
+	return s.client.Controls()
+}
+
+// Receipts streams the receipts of the sends made after the call until the
+// context ends, when the channel is closed. A subscriber that falls more
+// than a buffer behind misses receipts rather than slowing sends down.
+func (s *Service) Receipts(ctx context.Context) <-chan *receipt.Receipt {
+	ch := make(chan *receipt.Receipt, subscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+// publishReceipts is the middleware publishing receipts to subscribers
+func (s *Service) publishReceipts(ctx context.Context, msg *message.Message, next notifyhub.SendFunc) (*receipt.Receipt, error) {
+	rcpt, err := next(ctx, msg)
+	if rcpt == nil {
+		return rcpt, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- rcpt:
+		default:
+			s.logger.Warn("Dropping receipt for slow subscriber", "message_id", rcpt.MessageID)
+		}
+	}
+	return rcpt, err
+}
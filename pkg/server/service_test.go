@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/async"
+	"github.com/kart-io/notifyhub/pkg/config"
+	"github.com/kart-io/notifyhub/pkg/message"
+	"github.com/kart-io/notifyhub/pkg/notifyhub"
+	"github.com/kart-io/notifyhub/pkg/target"
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+func TestService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	client, err := notifyhub.NewClientFromOptions(config.WithQuickWebhook(server.URL), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+	service := NewService(client, WithJobRetention(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	receipts := service.Receipts(ctx)
+
+	newMessage := func() *message.Message {
+		msg := message.New().SetTitle("Alert").SetBody("disk full")
+		msg.Targets = []target.Target{target.NewWebhook(server.URL)}
+		return msg
+	}
+
+	sent := newMessage()
+	if rcpt, err := service.Send(ctx, sent); err != nil || rcpt.Successful != 1 {
+		t.Fatalf("Send() = %+v, %v", rcpt, err)
+	}
+	if rcpt := <-receipts; rcpt.MessageID != sent.ID {
+		t.Errorf("streamed receipt of %s, want %s", rcpt.MessageID, sent.ID)
+	}
+
+	queued := newMessage()
+	id, err := service.SendAsync(ctx, queued)
+	if err != nil {
+		t.Fatalf("SendAsync() error = %v", err)
+	}
+	job, err := service.Wait(ctx, id)
+	if err != nil || job.State != async.StateCompleted || job.Receipt == nil || job.Receipt.Successful != 1 || job.FinishedAt == nil {
+		t.Fatalf("Wait() = %+v, %v", job, err)
+	}
+	if rcpt := <-receipts; rcpt.MessageID != queued.ID {
+		t.Errorf("streamed receipt of %s, want %s", rcpt.MessageID, queued.ID)
+	}
+
+	if _, err := service.Status("unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Status() of an unknown job error = %v", err)
+	}
+	service.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := service.Status(id); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Status() after the retention error = %v", err)
+	}
+
+	if health, err := service.Health(ctx); err != nil || health.Platforms["webhook"] == "" {
+		t.Errorf("Health() = %+v, %v", health, err)
+	}
+
+	cancel()
+	for range receipts {
+	}
+}
+
+func TestService_UsageNotSupported(t *testing.T) {
+	client, err := notifyhub.NewClientFromOptions(config.WithQuickWebhook("http://localhost"), config.WithLogger(logger.Discard))
+	if err != nil {
+		t.Fatalf("NewClientFromOptions() error = %v", err)
+	}
+	defer client.Close()
+
+	if _, err := NewService(client).Usage(context.Background(), "acme", ""); err != nil {
+		t.Errorf("Usage() error = %v", err)
+	}
+	// A client wrapped in Client alone hides notifyhub.UsageReader
+	wrapped := struct{ notifyhub.Client }{client}
+	if _, err := NewService(wrapped).Usage(context.Background(), "acme", ""); !errors.Is(err, ErrUsageNotSupported) {
+		t.Errorf("Usage() error = %v, want ErrUsageNotSupported", err)
+	}
+}
@@ -0,0 +1,90 @@
+// Package suppression provides encryption of suppressed addresses at rest
+package suppression
+
+import (
+	"context"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+	"github.com/kart-io/notifyhub/pkg/pii"
+)
+
+// EncryptedStore keeps suppressed addresses encrypted in the underlying
+// store. Entries are keyed by a blind index of the normalized address so
+// lookups still work, and the address itself is stored sealed.
+type EncryptedStore struct {
+	store    Store
+	envelope *pii.Envelope
+}
+
+// NewEncryptedStore wraps a store with envelope encryption
+func NewEncryptedStore(store Store, envelope *pii.Envelope) *EncryptedStore {
+	return &EncryptedStore{store: store, envelope: envelope}
+}
+
+// Add suppresses an address
+func (s *EncryptedStore) Add(ctx context.Context, entry Entry) error {
+	address := Normalize(entry.Address)
+	if address == "" {
+		return errors.New(errors.ErrInvalidTarget, "suppression address cannot be empty")
+	}
+
+	sealed, err := s.envelope.Encrypt(ctx, address)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrInternal, "failed to encrypt suppressed address")
+	}
+	entry.Address = s.envelope.Index(address)
+	entry.EncryptedAddress = sealed
+	return s.store.Add(ctx, entry)
+}
+
+// Remove lifts the suppression of an address on a channel
+func (s *EncryptedStore) Remove(ctx context.Context, channel, address string) error {
+	return s.store.Remove(ctx, channel, s.envelope.Index(Normalize(address)))
+}
+
+// Lookup returns the entry suppressing an address on a channel, decrypted
+func (s *EncryptedStore) Lookup(ctx context.Context, channel, address string) (*Entry, error) {
+	entry, err := s.store.Lookup(ctx, channel, s.envelope.Index(Normalize(address)))
+	if err != nil || entry == nil {
+		return entry, err
+	}
+	opened, err := s.decrypt(ctx, *entry)
+	if err != nil {
+		return nil, err
+	}
+	return &opened, nil
+}
+
+// List returns all entries decrypted
+func (s *EncryptedStore) List(ctx context.Context) ([]Entry, error) {
+	entries, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		if entries[i], err = s.decrypt(ctx, entry); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// decrypt restores the address of a stored entry
+func (s *EncryptedStore) decrypt(ctx context.Context, entry Entry) (Entry, error) {
+	if entry.EncryptedAddress == "" {
+		return entry, nil
+	}
+	address, err := s.envelope.Decrypt(ctx, entry.EncryptedAddress)
+	if err != nil {
+		return Entry{}, errors.Wrap(err, errors.ErrInternal, "failed to decrypt suppressed address")
+	}
+	entry.Address, entry.EncryptedAddress = address, ""
+	return entry, nil
+}
+
+// Masked returns a copy of the entry with its address masked for logs and exports
+func (e Entry) Masked() Entry {
+	e.Address = pii.Mask(e.Address)
+	e.EncryptedAddress = ""
+	return e
+}
@@ -0,0 +1,76 @@
+// Package suppression provides the HTTP unsubscribe handler
+package suppression
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/utils/logger"
+)
+
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+<p>Stop sending {{if .Channel}}{{.Channel}} {{end}}notifications to <strong>{{.Address}}</strong>?</p>
+<form method="post"><input type="hidden" name="token" value="{{.Token}}"><button type="submit">Unsubscribe</button></form>
+</body></html>
+`))
+
+var donePage = template.Must(template.New("done").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribed</title></head>
+<body><p><strong>{{.Address}}</strong> has been unsubscribed{{if .Channel}} from {{.Channel}} notifications{{end}}.</p></body></html>
+`))
+
+// Handler serves unsubscribe links generated by a Tokenizer.
+//
+// GET renders a confirmation form so that link scanners and prefetchers
+// cannot unsubscribe anyone; POST records the suppression. POST also
+// covers RFC 8058 one-click unsubscribe from mail clients.
+type Handler struct {
+	store  Store
+	tokens *Tokenizer
+	logger logger.Logger
+}
+
+// NewHandler creates an unsubscribe handler
+func NewHandler(store Store, tokens *Tokenizer, log logger.Logger) *Handler {
+	if log == nil {
+		log = logger.Discard
+	}
+	return &Handler{store: store, tokens: tokens, logger: log}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.FormValue("token")
+	channel, address, err := h.tokens.Parse(token)
+	if err != nil {
+		http.Error(w, "invalid or expired unsubscribe link", http.StatusBadRequest)
+		return
+	}
+
+	data := struct{ Channel, Address, Token string }{channel, address, token}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if r.Method == http.MethodGet {
+		_ = confirmPage.Execute(w, data)
+		return
+	}
+
+	entry := Entry{Channel: channel, Address: address, Reason: ReasonUnsubscribe, CreatedAt: time.Now()}
+	if err := h.store.Add(r.Context(), entry); err != nil {
+		h.logger.Error("Failed to record unsubscribe", "channel", channel, "error", err)
+		http.Error(w, "failed to unsubscribe, please try again later", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Recipient unsubscribed", "channel", channel)
+	_ = donePage.Execute(w, data)
+}
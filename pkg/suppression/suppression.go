@@ -0,0 +1,137 @@
+// Package suppression provides opt-out (unsubscribe) management for NotifyHub.
+//
+// A suppression entry stops delivery to an address on one channel (such as
+// "email" or "sms") or on every channel. The client consults the configured
+// Store before sending and records suppressed targets on the receipt with
+// the "suppressed" status instead of silently dropping them.
+package suppression
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+)
+
+// Suppression reasons
+const (
+	ReasonUnsubscribe = "unsubscribe"
+	ReasonBounce      = "bounce"
+	ReasonComplaint   = "complaint"
+	ReasonManual      = "manual"
+)
+
+// AllChannels is the channel value that suppresses an address everywhere
+const AllChannels = ""
+
+// Entry is a suppressed address
+type Entry struct {
+	Channel   string    `json:"channel,omitempty"` // empty suppresses all channels
+	Address   string    `json:"address"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// EncryptedAddress holds the sealed address of entries written by
+	// EncryptedStore, whose Address is a blind index
+	EncryptedAddress string `json:"encrypted_address,omitempty"`
+}
+
+// Store persists suppression entries
+type Store interface {
+	// Add suppresses an address, replacing any existing entry for the same channel
+	Add(ctx context.Context, entry Entry) error
+
+	// Remove lifts the suppression of an address on a channel
+	Remove(ctx context.Context, channel, address string) error
+
+	// Lookup returns the entry suppressing an address on a channel,
+	// including all-channel entries, or nil if the address is not suppressed
+	Lookup(ctx context.Context, channel, address string) (*Entry, error)
+
+	// List returns all entries
+	List(ctx context.Context) ([]Entry, error)
+}
+
+// Normalize normalizes an address for comparison: email addresses are
+// lower-cased and phone numbers lose spaces, dashes and parentheses
+func Normalize(address string) string {
+	address = strings.TrimSpace(address)
+	if strings.Contains(address, "@") {
+		return strings.ToLower(address)
+	}
+	return strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(address)
+}
+
+// MemoryStore implements Store in memory
+type MemoryStore struct {
+	entries map[string]Entry
+	mu      sync.RWMutex
+}
+
+// NewMemoryStore creates a new in-memory suppression store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+// Add suppresses an address
+func (s *MemoryStore) Add(ctx context.Context, entry Entry) error {
+	if strings.TrimSpace(entry.Address) == "" {
+		return errors.New(errors.ErrInvalidTarget, "suppression address cannot be empty")
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key(entry.Channel, entry.Address)] = entry
+	return nil
+}
+
+// Remove lifts the suppression of an address on a channel
+func (s *MemoryStore) Remove(ctx context.Context, channel, address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key(channel, address))
+	return nil
+}
+
+// Lookup returns the entry suppressing an address on a channel
+func (s *MemoryStore) Lookup(ctx context.Context, channel, address string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if entry, ok := s.entries[key(channel, address)]; ok {
+		return &entry, nil
+	}
+	if entry, ok := s.entries[key(AllChannels, address)]; ok {
+		return &entry, nil
+	}
+	return nil, nil
+}
+
+// List returns all entries ordered by channel and address
+func (s *MemoryStore) List(ctx context.Context) ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Channel != entries[j].Channel {
+			return entries[i].Channel < entries[j].Channel
+		}
+		return entries[i].Address < entries[j].Address
+	})
+	return entries, nil
+}
+
+// key builds the map key for a channel and address
+func key(channel, address string) string {
+	return channel + "\x00" + Normalize(address)
+}
@@ -0,0 +1,138 @@
+package suppression
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kart-io/notifyhub/pkg/pii"
+)
+
+func TestMemoryStore_Lookup(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	_ = store.Add(ctx, Entry{Channel: "email", Address: "Alice@Example.com", Reason: ReasonUnsubscribe})
+	_ = store.Add(ctx, Entry{Address: "+86 138-0013-8000", Reason: ReasonManual})
+
+	tests := []struct {
+		name       string
+		channel    string
+		address    string
+		suppressed bool
+	}{
+		{"same channel, different case", "email", "alice@example.com", true},
+		{"other channel", "sms", "alice@example.com", false},
+		{"all channels", "sms", "+8613800138000", true},
+		{"unknown address", "email", "bob@example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := store.Lookup(ctx, tt.channel, tt.address)
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if (entry != nil) != tt.suppressed {
+				t.Errorf("Lookup() = %v, want suppressed %v", entry, tt.suppressed)
+			}
+		})
+	}
+
+	_ = store.Remove(ctx, "email", "ALICE@example.com")
+	if entry, _ := store.Lookup(ctx, "email", "alice@example.com"); entry != nil {
+		t.Errorf("Lookup() after Remove() = %v, want nil", entry)
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	ctx := context.Background()
+	keys, _ := pii.NewLocalKeyService([]byte("0123456789abcdef0123456789abcdef"))
+	envelope, _ := pii.NewEnvelope(pii.EnvelopeConfig{Keys: keys, IndexKey: []byte("0123456789abcdef")})
+
+	inner := NewMemoryStore()
+	store := NewEncryptedStore(inner, envelope)
+	if err := store.Add(ctx, Entry{Channel: "email", Address: "Alice@Example.com", Reason: ReasonBounce}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	raw, _ := inner.List(ctx)
+	if len(raw) != 1 || strings.Contains(raw[0].Address, "alice") || !pii.IsEncrypted(raw[0].EncryptedAddress) {
+		t.Errorf("stored entries = %+v, want blind index and encrypted address", raw)
+	}
+
+	entry, err := store.Lookup(ctx, "email", "alice@example.com")
+	if err != nil || entry == nil || entry.Address != "alice@example.com" || entry.Reason != ReasonBounce {
+		t.Errorf("Lookup() = %+v, %v, want decrypted entry", entry, err)
+	}
+	if entries, _ := store.List(ctx); len(entries) != 1 || entries[0].Masked().Address != "a****@example.com" {
+		t.Errorf("List() = %+v, want one entry that masks", entries)
+	}
+
+	_ = store.Remove(ctx, "email", "ALICE@example.com")
+	if entry, _ := store.Lookup(ctx, "email", "alice@example.com"); entry != nil {
+		t.Errorf("Lookup() after Remove() = %+v, want nil", entry)
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	tokens, err := NewTokenizer([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewTokenizer() error = %v", err)
+	}
+
+	token := tokens.Token("email", "Alice@Example.com")
+	channel, address, err := tokens.Parse(token)
+	if err != nil || channel != "email" || address != "alice@example.com" {
+		t.Errorf("Parse() = %v, %v, %v, want email alice@example.com", channel, address, err)
+	}
+
+	other, _ := NewTokenizer([]byte("fedcba9876543210"))
+	if _, _, err := other.Parse(token); err == nil {
+		t.Error("Parse() expected error for token signed with another secret")
+	}
+	if _, _, err := tokens.Parse("garbage"); err == nil {
+		t.Error("Parse() expected error for malformed token")
+	}
+	if _, err := NewTokenizer([]byte("short")); err == nil {
+		t.Error("NewTokenizer() expected error for short secret")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	tokens, _ := NewTokenizer([]byte("0123456789abcdef"))
+	handler := NewHandler(store, tokens, nil)
+	link := tokens.URL("/unsubscribe", "email", "alice@example.com")
+
+	// GET only renders a confirmation form
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<form") {
+		t.Errorf("GET status = %d, want confirmation form", rec.Code)
+	}
+	if entry, _ := store.Lookup(ctx, "email", "alice@example.com"); entry != nil {
+		t.Error("GET should not unsubscribe")
+	}
+
+	form := url.Values{"token": {tokens.Token("email", "alice@example.com")}}
+	req := httptest.NewRequest(http.MethodPost, "/unsubscribe", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST status = %d, want 200", rec.Code)
+	}
+	if entry, _ := store.Lookup(ctx, "email", "alice@example.com"); entry == nil || entry.Reason != ReasonUnsubscribe {
+		t.Errorf("Lookup() after POST = %v, want unsubscribe entry", entry)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/unsubscribe?token=bad", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST with bad token status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,68 @@
+// Package suppression provides signed unsubscribe tokens
+package suppression
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+)
+
+// Tokenizer generates and verifies signed unsubscribe tokens.
+// A token encodes the channel and address it unsubscribes, so the
+// unsubscribe link needs no server-side state.
+type Tokenizer struct {
+	secret []byte
+}
+
+// NewTokenizer creates a tokenizer using an HMAC secret
+func NewTokenizer(secret []byte) (*Tokenizer, error) {
+	if len(secret) < 16 {
+		return nil, errors.New(errors.ErrInvalidConfig, "unsubscribe token secret must be at least 16 bytes")
+	}
+	return &Tokenizer{secret: secret}, nil
+}
+
+// Token returns the unsubscribe token for an address on a channel.
+// Use AllChannels to unsubscribe from every channel.
+func (t *Tokenizer) Token(channel, address string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(channel + "\n" + Normalize(address)))
+	return payload + "." + t.sign(payload)
+}
+
+// URL returns an unsubscribe link by appending the token to baseURL
+func (t *Tokenizer) URL(baseURL, channel, address string) string {
+	sep := "?"
+	if strings.Contains(baseURL, "?") {
+		sep = "&"
+	}
+	return baseURL + sep + "token=" + url.QueryEscape(t.Token(channel, address))
+}
+
+// Parse verifies a token and returns the channel and address it encodes
+func (t *Tokenizer) Parse(token string) (channel, address string, err error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(t.sign(payload))) {
+		return "", "", errors.New(errors.ErrInvalidCredentials, "invalid unsubscribe token")
+	}
+
+	raw, decodeErr := base64.RawURLEncoding.DecodeString(payload)
+	if decodeErr != nil {
+		return "", "", errors.New(errors.ErrInvalidCredentials, "invalid unsubscribe token")
+	}
+	channel, address, ok = strings.Cut(string(raw), "\n")
+	if !ok || address == "" {
+		return "", "", errors.New(errors.ErrInvalidCredentials, "invalid unsubscribe token")
+	}
+	return channel, address, nil
+}
+
+// sign returns the encoded HMAC-SHA256 signature of a payload
+func (t *Tokenizer) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,113 @@
+// Package target provides allow and deny rules for target values
+package target
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+)
+
+// AccessRule restricts the target values a platform may send to.
+//
+// Patterns are matched case-insensitively with path.Match syntax against a
+// subject that depends on the target type:
+//   - email: the domain ("example.com", "*.example.com"), or the full
+//     address when the pattern contains "@" ("*-noreply@example.com")
+//   - phone: the E.164 number; patterns without wildcards are prefixes, so
+//     "+86" matches every mainland China number
+//   - webhook: the URL host ("hooks.slack.com", "*.internal")
+//   - anything else: the target value
+//
+// A target matching a deny pattern is blocked. When allow patterns are set,
+// a target must match one of them.
+type AccessRule struct {
+	Platform string   `json:"platform,omitempty"` // empty applies to every platform
+	Allow    []string `json:"allow,omitempty"`
+	Deny     []string `json:"deny,omitempty"`
+}
+
+// Validate checks the rule's patterns
+func (r AccessRule) Validate() error {
+	for _, pattern := range append(append([]string{}, r.Allow...), r.Deny...) {
+		if pattern == "" {
+			return errors.New(errors.ErrInvalidConfig, "access rule pattern cannot be empty")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Newf(errors.ErrInvalidConfig, "invalid access rule pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// AccessPolicy is a set of access rules
+type AccessPolicy []AccessRule
+
+// Check reports whether a target may be sent to on a platform. When it may
+// not, the reason names the rule pattern that blocked it.
+func (p AccessPolicy) Check(platform string, t Target) (bool, string) {
+	for _, rule := range p {
+		if rule.Platform != "" && rule.Platform != platform {
+			continue
+		}
+		if pattern, ok := matchAny(rule.Deny, t); ok {
+			return false, "target matches deny pattern " + pattern
+		}
+		if len(rule.Allow) > 0 {
+			if _, ok := matchAny(rule.Allow, t); !ok {
+				return false, "target matches no allow pattern for " + platformLabel(rule.Platform)
+			}
+		}
+	}
+	return true, ""
+}
+
+// MatchAny reports whether a target matches one of the patterns, which
+// are matched like those of access rules
+func MatchAny(patterns []string, t Target) bool {
+	_, ok := matchAny(patterns, t)
+	return ok
+}
+
+// matchAny returns the first pattern that matches the target
+func matchAny(patterns []string, t Target) (string, bool) {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, t) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matchPattern matches a pattern against the target's subject
+func matchPattern(pattern string, t Target) bool {
+	pattern = strings.ToLower(pattern)
+	value := strings.ToLower(t.Value)
+
+	switch t.Type {
+	case TargetTypeEmail:
+		if !strings.Contains(pattern, "@") {
+			value = value[strings.LastIndexByte(value, '@')+1:]
+		}
+	case TargetTypePhone:
+		if !strings.ContainsAny(pattern, "*?[") {
+			return strings.HasPrefix(value, pattern)
+		}
+	case TargetTypeWebhook, "url":
+		if u, err := url.Parse(t.Value); err == nil && u.Host != "" {
+			value = strings.ToLower(u.Hostname())
+		}
+	}
+
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+// platformLabel describes the platform a rule applies to
+func platformLabel(platform string) string {
+	if platform == "" {
+		return "all platforms"
+	}
+	return platform
+}
@@ -7,7 +7,6 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/kart-io/notifyhub/pkg/errors"
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
 )
 
@@ -196,18 +195,14 @@ func (h *EmailResolutionHandler) CanResolve(spec TargetSpec) bool {
 }
 
 func (h *EmailResolutionHandler) Resolve(ctx context.Context, spec TargetSpec) ([]Target, error) {
-	// Validate email format
-	emailPattern := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	if !emailPattern.MatchString(spec.Value) {
-		return nil, &errors.NotifyError{
-			Code:    errors.ErrInvalidTarget,
-			Message: fmt.Sprintf("invalid email format: %s", spec.Value),
-		}
+	email, err := NormalizeEmail(spec.Value)
+	if err != nil {
+		return nil, err
 	}
 
 	return []Target{{
 		Type:     "email",
-		Value:    spec.Value,
+		Value:    email,
 		Platform: "email",
 	}}, nil
 }
@@ -220,18 +215,14 @@ func (h *PhoneResolutionHandler) CanResolve(spec TargetSpec) bool {
 }
 
 func (h *PhoneResolutionHandler) Resolve(ctx context.Context, spec TargetSpec) ([]Target, error) {
-	// Validate phone format
-	phonePattern := regexp.MustCompile(`^\+?[1-9]\d{1,14}$`)
-	if !phonePattern.MatchString(spec.Value) {
-		return nil, &errors.NotifyError{
-			Code:    errors.ErrInvalidTarget,
-			Message: fmt.Sprintf("invalid phone format: %s", spec.Value),
-		}
+	phone, err := NormalizePhone(spec.Value, "")
+	if err != nil {
+		return nil, err
 	}
 
 	return []Target{{
 		Type:     "phone",
-		Value:    spec.Value,
+		Value:    phone,
 		Platform: "sms",
 	}}, nil
 }
@@ -385,12 +376,8 @@ func (h *WebhookResolutionHandler) CanResolve(spec TargetSpec) bool {
 }
 
 func (h *WebhookResolutionHandler) Resolve(ctx context.Context, spec TargetSpec) ([]Target, error) {
-	// Validate URL format
-	if !strings.HasPrefix(spec.Value, "http://") && !strings.HasPrefix(spec.Value, "https://") {
-		return nil, &errors.NotifyError{
-			Code:    errors.ErrInvalidTarget,
-			Message: fmt.Sprintf("invalid webhook URL format: %s", spec.Value),
-		}
+	if err := ValidateWebhookURL(spec.Value); err != nil {
+		return nil, err
 	}
 
 	return []Target{{
@@ -7,9 +7,10 @@ import (
 
 // Target represents a unified target structure
 type Target struct {
-	Type     string `json:"type"`     // "email", "user", "group", "channel"
-	Value    string `json:"value"`    // specific address or ID
-	Platform string `json:"platform"` // "feishu", "email", "webhook"
+	Type     string `json:"type"`               // "email", "user", "group", "channel"
+	Value    string `json:"value"`              // specific address or ID
+	Platform string `json:"platform"`           // "feishu", "email", "webhook"
+	Timezone string `json:"timezone,omitempty"` // recipient's IANA time zone for delivery windows
 }
 
 // Target type constants
@@ -20,6 +21,12 @@ const (
 	TargetTypeGroup   = "group"
 	TargetTypeChannel = "channel"
 	TargetTypeWebhook = "webhook"
+	TargetTypeTeam    = "team"
+	TargetTypeOnCall  = "oncall"
+	TargetTypeContact = "contact"
+	TargetTypeAlias   = "alias"
+	TargetTypeList    = "list"
+	TargetTypeTag     = "tag"
 )
 
 // Platform constants
@@ -65,7 +72,61 @@ func NewFeishuGroup(groupID string) Target {
 	}
 }
 
-// NewWebhook creates a webhook target
+// NewTeam creates a team target that is expanded into its members at send time
+func NewTeam(name string) Target {
+	return Target{
+		Type:  TargetTypeTeam,
+		Value: name,
+	}
+}
+
+// NewContact creates a target for a directory contact that is resolved to
+// the contact's preferred reachable platform at send time
+func NewContact(id string) Target {
+	return Target{
+		Type:  TargetTypeContact,
+		Value: id,
+	}
+}
+
+// NewOnCall creates a target that resolves to whoever is currently on call
+// for the named schedule at send time
+func NewOnCall(schedule string) Target {
+	return Target{
+		Type:  TargetTypeOnCall,
+		Value: schedule,
+	}
+}
+
+// NewAlias creates a target for a configured target alias (e.g. "ops-room"),
+// replaced with the alias's targets at send time
+func NewAlias(name string) Target {
+	return Target{
+		Type:  TargetTypeAlias,
+		Value: name,
+	}
+}
+
+// NewList creates a target for a dynamic target list (e.g. the subscribers
+// of a product) whose members are fetched from a list provider at send time
+func NewList(name string) Target {
+	return Target{
+		Type:  TargetTypeList,
+		Value: name,
+	}
+}
+
+// NewTagQuery creates a target for the directory contacts whose tags match
+// a query such as "role:sre AND region:eu", resolved at send time
+func NewTagQuery(query string) Target {
+	return Target{
+		Type:  TargetTypeTag,
+		Value: query,
+	}
+}
+
+// NewWebhook creates a webhook target for a URL or the name of a webhook
+// endpoint
 func NewWebhook(url string) Target {
 	return Target{
 		Type:     TargetTypeWebhook,
@@ -103,6 +164,36 @@ func (t *Target) IsGroup() bool {
 	return t.Type == TargetTypeGroup
 }
 
+// IsTeam returns true if the target is a team
+func (t *Target) IsTeam() bool {
+	return t.Type == TargetTypeTeam
+}
+
+// IsContact returns true if the target is a directory contact
+func (t *Target) IsContact() bool {
+	return t.Type == TargetTypeContact
+}
+
+// IsOnCall returns true if the target is an on-call schedule
+func (t *Target) IsOnCall() bool {
+	return t.Type == TargetTypeOnCall
+}
+
+// IsAlias returns true if the target refers to a configured target alias
+func (t *Target) IsAlias() bool {
+	return t.Type == TargetTypeAlias
+}
+
+// IsList returns true if the target refers to a dynamic target list
+func (t *Target) IsList() bool {
+	return t.Type == TargetTypeList
+}
+
+// IsTagQuery returns true if the target selects contacts by tag query
+func (t *Target) IsTagQuery() bool {
+	return t.Type == TargetTypeTag
+}
+
 // IsWebhook returns true if the target is a webhook
 func (t *Target) IsWebhook() bool {
 	return t.Type == TargetTypeWebhook
@@ -112,3 +203,10 @@ func (t *Target) IsWebhook() bool {
 func (t *Target) String() string {
 	return t.Platform + ":" + t.Type + ":" + t.Value
 }
+
+// WithTimezone returns a copy of the target in the recipient's IANA time
+// zone (e.g. "Asia/Shanghai"), used to evaluate delivery windows
+func (t Target) WithTimezone(timezone string) Target {
+	t.Timezone = timezone
+	return t
+}
@@ -2,6 +2,8 @@ package target
 
 import (
 	"context"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/kart-io/notifyhub/pkg/utils/logger"
@@ -301,6 +303,9 @@ func TestTargetTypeConstants(t *testing.T) {
 		{TargetTypeGroup, "group"},
 		{TargetTypeChannel, "channel"},
 		{TargetTypeWebhook, "webhook"},
+		{TargetTypeTeam, "team"},
+		{TargetTypeOnCall, "oncall"},
+		{TargetTypeContact, "contact"},
 	}
 
 	for _, tt := range tests {
@@ -1391,3 +1396,258 @@ func TestMultipleTargetTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"Alice@Example.COM", "Alice@example.com", false},
+		{"first.last+tag@sub.example.co", "first.last+tag@sub.example.co", false},
+		{`"john doe"@example.com`, `"john doe"@example.com`, false},
+		{"ops@[192.0.2.1]", "ops@[192.0.2.1]", false},
+		{"no-at-sign", "", true},
+		{"@example.com", "", true},
+		{"alice@localhost", "", true},
+		{"alice..bob@example.com", "", true},
+		{".alice@example.com", "", true},
+		{"alice@-example.com", "", true},
+		{"alice@exa_mple.com", "", true},
+		{strings.Repeat("a", 65) + "@example.com", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeEmail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeEmail() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		input   string
+		region  string
+		want    string
+		wantErr bool
+	}{
+		{"+86 138-0013-8000", "", "+8613800138000", false},
+		{"0086 13800138000", "", "+8613800138000", false},
+		{"13800138000", "", "+8613800138000", false},
+		{"(415) 555-0100", "US", "+14155550100", false},
+		{"1 415 555 0100", "us", "+14155550100", false},
+		{"020 7946 0958", "GB", "+442079460958", false},
+		{"4155550100", "", "", true},
+		{"415555", "US", "", true},
+		{"+0123456789", "", "", true},
+		{"+1234567890123456", "", "", true},
+		{"call me", "", "", true},
+		{"12345678", "ZZ", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizePhone(tt.input, tt.region)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizePhone() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizePhone() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  Target
+		wantErr bool
+	}{
+		{"email", NewEmail("ops@example.com"), false},
+		{"bad email", NewEmail("ops@"), true},
+		{"webhook", NewWebhook("https://hooks.example.com:8443/notify?key=1"), false},
+		{"webhook ip", NewWebhook("http://10.0.0.1/hook"), false},
+		{"webhook bad scheme", NewWebhook("ftp://example.com/hook"), true},
+		{"webhook no host", NewWebhook("https:///hook"), true},
+		{"webhook bad port", NewWebhook("https://example.com:99999/hook"), true},
+		{"feishu bot webhook", New(TargetTypeWebhook, "feishu", PlatformFeishu), false},
+		{"feishu open id", NewFeishuUser("ou_7d8a6e6df7621556ce0d21922b676706"), false},
+		{"feishu union id", NewFeishuUser("on_94a1ee5551019f18cd73d9f111898cf2"), false},
+		{"feishu user id", NewFeishuUser("a1b2c3d4"), false},
+		{"feishu chat as user", NewFeishuUser("oc_a0553eda9014c201e6969b478895c230"), true},
+		{"feishu chat", NewFeishuGroup("oc_a0553eda9014c201e6969b478895c230"), false},
+		{"feishu group without oc_", NewFeishuGroup("group456"), true},
+		{"team", NewTeam("payments"), false},
+		{"empty value", New(TargetTypeEmail, " ", PlatformEmail), true},
+		{"empty type", Target{Value: "x"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.target); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccessPolicy_Check(t *testing.T) {
+	policy := AccessPolicy{
+		{Platform: PlatformEmail, Allow: []string{"example.com", "*.example.com"}, Deny: []string{"*-noreply@example.com"}},
+		{Platform: "sms", Allow: []string{"+86", "+852"}},
+		{Platform: PlatformWebhook, Deny: []string{"*.internal"}},
+		{Deny: []string{"blocked-*"}},
+	}
+	if err := policy[0].Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		platform string
+		target   Target
+		want     bool
+	}{
+		{"allowed domain", PlatformEmail, NewEmail("ops@Example.com"), true},
+		{"allowed subdomain", PlatformEmail, NewEmail("ops@mail.example.com"), true},
+		{"other domain", PlatformEmail, NewEmail("ops@gmail.com"), false},
+		{"denied address", PlatformEmail, NewEmail("build-noreply@example.com"), false},
+		{"allowed country code", "sms", New(TargetTypePhone, "+8613800138000", "sms"), true},
+		{"other country code", "sms", New(TargetTypePhone, "+14155550100", "sms"), false},
+		{"public webhook", PlatformWebhook, NewWebhook("https://hooks.example.com/x"), true},
+		{"internal webhook", PlatformWebhook, NewWebhook("https://alerts.corp.internal/x"), false},
+		{"rule for all platforms", PlatformFeishu, NewFeishuUser("blocked-user"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := policy.Check(tt.platform, tt.target)
+			if got != tt.want {
+				t.Errorf("Check() = %v (%s), want %v", got, reason, tt.want)
+			}
+			if !got && reason == "" {
+				t.Error("Check() must explain why a target is blocked")
+			}
+		})
+	}
+
+	if err := (AccessRule{Deny: []string{"[bad"}}).Validate(); err == nil {
+		t.Error("Validate() expected error for malformed pattern")
+	}
+}
+
+func TestExpandURL(t *testing.T) {
+	vars := map[string]interface{}{
+		"tenant_id": "acme",
+		"path":      "a/b",
+		"query":     "x&y=1",
+		"number":    42,
+		"empty":     "",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"path variable", "https://api.example.com/hooks/{{tenant_id}}", "https://api.example.com/hooks/acme", false},
+		{"spaces in placeholder", "https://api.example.com/hooks/{{ tenant_id }}", "https://api.example.com/hooks/acme", false},
+		{"path value is escaped", "https://api.example.com/hooks/{{path}}", "https://api.example.com/hooks/a%2Fb", false},
+		{"query value is escaped", "https://api.example.com/hooks?t={{query}}", "https://api.example.com/hooks?t=x%26y%3D1", false},
+		{"non-string value", "https://api.example.com/hooks/{{number}}", "https://api.example.com/hooks/42", false},
+		{"not templated", "https://api.example.com/hooks", "https://api.example.com/hooks", false},
+		{"variable in host", "https://{{tenant_id}}.example.com/hooks", "", true},
+		{"variable as host", "https://{{tenant_id}}", "", true},
+		{"variable in fragment", "https://api.example.com/hooks#{{tenant_id}}", "", true},
+		{"missing variable", "https://api.example.com/hooks/{{region}}", "", true},
+		{"empty variable", "https://api.example.com/hooks/{{empty}}", "", true},
+		{"bad variable name", "https://api.example.com/hooks/{{tenant-id}}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandURL(tt.template, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if !IsTemplated("https://api.example.com/{{tenant_id}}") || IsTemplated("https://api.example.com/x") {
+		t.Error("IsTemplated() misdetects placeholders")
+	}
+}
+
+func FuzzExpandURL(f *testing.F) {
+	f.Add("https://api.example.com/hooks/{{tenant_id}}", "acme")
+	f.Add("https://api.example.com/hooks?t={{ tenant_id }}&x=1", "x&y=1")
+	f.Add("https://api.example.com/{{tenant_id}}/{{tenant_id}}", "../../admin")
+	f.Add("https://{{tenant_id}}.example.com/hooks", "evil.com")
+	f.Add("https://api.example.com/hooks#{{tenant_id}}", "@evil.com")
+	f.Add("http://api.example.com:8080/{{tenant_id}}", "%2F%2Fevil.com")
+
+	f.Fuzz(func(t *testing.T, template, value string) {
+		expanded, err := ExpandURL(template, map[string]interface{}{"tenant_id": value})
+		if err != nil {
+			return
+		}
+		if !IsTemplated(template) {
+			return
+		}
+
+		// Variables must never change where the request is sent
+		end := authorityEnd(template)
+		if !strings.HasPrefix(expanded, template[:end]) {
+			t.Fatalf("ExpandURL(%q) = %q changed the scheme or host", template, expanded)
+		}
+		want, err := url.Parse(template[:end])
+		if err != nil {
+			t.Fatalf("url.Parse(%q) error = %v", template[:end], err)
+		}
+		got, err := url.Parse(expanded)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) error = %v", expanded, err)
+		}
+		if got.Scheme != want.Scheme || got.Host != want.Host || got.User.String() != want.User.String() {
+			t.Errorf("ExpandURL(%q) = %q sends to %s://%s, want %s://%s", template, expanded, got.Scheme, got.Host, want.Scheme, want.Host)
+		}
+	})
+}
+
+func FuzzNormalize(f *testing.F) {
+	f.Add("email", " Ops@Example.COM ", "email")
+	f.Add("phone", "138 0013 8000", "sms")
+	f.Add("phone", "+1 (415) 555-0100", "sms")
+	f.Add("webhook", "https://hooks.example.com/a?b=c", "webhook")
+	f.Add("user", "ou_123", "feishu")
+	f.Add("email", "\"quoted local\"@[127.0.0.1]", "email")
+
+	f.Fuzz(func(t *testing.T, targetType, value, platform string) {
+		normalized, err := Normalize(New(targetType, value, platform))
+		if err != nil {
+			return
+		}
+		// Normalized targets are valid and stay as they are
+		if err := Validate(normalized); err != nil {
+			t.Fatalf("Validate(%+v) error = %v", normalized, err)
+		}
+		again, err := Normalize(normalized)
+		if err != nil {
+			t.Fatalf("Normalize(%+v) error = %v", normalized, err)
+		}
+		if again != normalized {
+			t.Errorf("Normalize() = %+v, want %+v unchanged", again, normalized)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+// Package target provides templated webhook target values
+package target
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// placeholderPattern matches "{{name}}" placeholders in target values
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// variableName is the syntax of placeholder variable names
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsTemplated reports whether a target value contains "{{name}}" placeholders
+func IsTemplated(value string) bool {
+	return placeholderPattern.MatchString(value)
+}
+
+// ExpandURL resolves the "{{name}}" placeholders of a webhook URL template,
+// such as "https://api.example.com/hooks/{{tenant_id}}", from message
+// variables.
+//
+// Placeholders may only appear in the path or the query, so variables can
+// never change the scheme or host a request is sent to. Values are escaped
+// for their position: a value in the path stays within one path segment
+// and a value in the query within one query component. Missing or empty
+// variables are errors, and the result must be a valid webhook URL.
+func ExpandURL(template string, vars map[string]interface{}) (string, error) {
+	matches := placeholderPattern.FindAllStringSubmatchIndex(template, -1)
+	if len(matches) == 0 {
+		return template, ValidateWebhookURL(template)
+	}
+
+	pathStart := authorityEnd(template)
+	if pathStart < 0 || matches[0][0] < pathStart {
+		return "", invalidTarget("invalid webhook URL template %q: variables are only allowed in the path and query", template)
+	}
+	query := strings.IndexByte(template, '?')
+	fragment := strings.IndexByte(template, '#')
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		name := template[m[2]:m[3]]
+		if !variableName.MatchString(name) {
+			return "", invalidTarget("invalid webhook URL template %q: bad variable name %q", template, name)
+		}
+		value, ok := vars[name]
+		if !ok || value == nil {
+			return "", invalidTarget("webhook URL template %q: missing variable %q", template, name)
+		}
+		text := fmt.Sprint(value)
+		if text == "" {
+			return "", invalidTarget("webhook URL template %q: variable %q is empty", template, name)
+		}
+
+		b.WriteString(template[last:m[0]])
+		switch {
+		case fragment >= 0 && m[0] > fragment:
+			return "", invalidTarget("invalid webhook URL template %q: variables are not allowed in the fragment", template)
+		case query >= 0 && m[0] > query:
+			b.WriteString(url.QueryEscape(text))
+		default:
+			b.WriteString(url.PathEscape(text))
+		}
+		last = m[1]
+	}
+	b.WriteString(template[last:])
+
+	expanded := b.String()
+	if err := ValidateWebhookURL(expanded); err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+// authorityEnd returns the index where the path of a URL begins, or -1 if
+// the URL has no "scheme://host" prefix
+func authorityEnd(raw string) int {
+	scheme := strings.Index(raw, "://")
+	if scheme < 0 {
+		return -1
+	}
+	start := scheme + len("://")
+	if end := strings.IndexAny(raw[start:], "/?#"); end >= 0 {
+		return start + end
+	}
+	return len(raw)
+}
@@ -0,0 +1,358 @@
+// Package target provides strict target validation and normalization for NotifyHub
+package target
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kart-io/notifyhub/pkg/errors"
+)
+
+// Address length limits from RFC 5321 section 4.5.3.1
+const (
+	maxEmailLength  = 254
+	maxLocalLength  = 64
+	maxDomainLength = 255
+	maxLabelLength  = 63
+)
+
+// E.164 numbers have at most 15 digits including the country calling code
+const maxE164Digits = 15
+
+// callingCodes maps ISO 3166 region codes to country calling codes
+var callingCodes = map[string]string{
+	"AU": "61", "BR": "55", "CA": "1", "CN": "86", "DE": "49",
+	"ES": "34", "FR": "33", "GB": "44", "HK": "852", "ID": "62",
+	"IN": "91", "IT": "39", "JP": "81", "KR": "82", "MO": "853",
+	"MX": "52", "MY": "60", "NL": "31", "PH": "63", "RU": "7",
+	"SG": "65", "TH": "66", "TW": "886", "US": "1", "VN": "84",
+}
+
+// Validator performs strict target validation and normalization
+type Validator struct {
+	// DefaultRegion is the ISO 3166 region (e.g. "CN", "US") assumed for
+	// phone numbers written without a country calling code
+	DefaultRegion string
+}
+
+// NewValidator creates a validator with a default phone region
+func NewValidator(defaultRegion string) *Validator {
+	return &Validator{DefaultRegion: strings.ToUpper(defaultRegion)}
+}
+
+var defaultValidator = &Validator{}
+
+// Validate strictly validates a target without a default phone region
+func Validate(t Target) error {
+	return defaultValidator.Validate(t)
+}
+
+// Normalize validates a target and returns it in canonical form
+func Normalize(t Target) (Target, error) {
+	return defaultValidator.Normalize(t)
+}
+
+// Validate strictly validates a target according to its type and platform
+func (v *Validator) Validate(t Target) error {
+	_, err := v.Normalize(t)
+	return err
+}
+
+// Normalize validates a target and returns it in canonical form: email
+// domains are lower-cased and phone numbers are converted to E.164
+func (v *Validator) Normalize(t Target) (Target, error) {
+	if t.Type == "" {
+		return t, errors.New(errors.ErrEmptyTargetType, "target type cannot be empty")
+	}
+	value := strings.TrimSpace(t.Value)
+	if value == "" {
+		return t, errors.New(errors.ErrEmptyTargetValue, "target value cannot be empty")
+	}
+	t.Value = value
+
+	var err error
+	switch t.Type {
+	case TargetTypeEmail:
+		t.Value, err = NormalizeEmail(value)
+	case TargetTypePhone:
+		t.Value, err = NormalizePhone(value, v.DefaultRegion)
+	case TargetTypeWebhook, "url":
+		// Bot platforms such as Feishu accept webhook targets that name the
+		// configured bot rather than a URL
+		if t.Platform == "" || t.Platform == PlatformWebhook {
+			err = ValidateWebhookURL(value)
+		}
+	case TargetTypeUser, TargetTypeGroup, TargetTypeChannel:
+		if t.Platform == PlatformFeishu {
+			err = ValidateFeishuID(t.Type, value)
+		}
+	}
+	return t, err
+}
+
+// NormalizeEmail validates an email address against the RFC 5321 mailbox
+// syntax and returns it with a lower-cased domain
+func NormalizeEmail(address string) (string, error) {
+	if len(address) > maxEmailLength {
+		return "", invalidTarget("email address exceeds %d characters", maxEmailLength)
+	}
+
+	at := strings.LastIndexByte(address, '@')
+	if at <= 0 || at == len(address)-1 {
+		return "", invalidTarget("invalid email address %q: missing local part or domain", address)
+	}
+	local, domain := address[:at], address[at+1:]
+
+	if len(local) > maxLocalLength {
+		return "", invalidTarget("invalid email address %q: local part exceeds %d characters", address, maxLocalLength)
+	}
+	if !validLocalPart(local) {
+		return "", invalidTarget("invalid email address %q: malformed local part", address)
+	}
+	if reason := checkEmailDomain(domain); reason != "" {
+		return "", invalidTarget("invalid email address %q: %s", address, reason)
+	}
+
+	return local + "@" + strings.ToLower(domain), nil
+}
+
+// validLocalPart checks a dot-atom or quoted-string local part
+func validLocalPart(local string) bool {
+	if strings.HasPrefix(local, `"`) {
+		if len(local) < 2 || !strings.HasSuffix(local, `"`) {
+			return false
+		}
+		inner := local[1 : len(local)-1]
+		for i := 0; i < len(inner); i++ {
+			c := inner[i]
+			if c == '\\' {
+				i++
+				if i == len(inner) || inner[i] < 32 || inner[i] > 126 {
+					return false
+				}
+				continue
+			}
+			if c == '"' || c < 32 || c > 126 {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return false
+		}
+		for i := 0; i < len(atom); i++ {
+			if !isAtext(atom[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isAtext reports whether c is an RFC 5322 atext character
+func isAtext(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	case c >= 0x80:
+		// SMTPUTF8 (RFC 6531) addresses
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+// checkEmailDomain checks a domain name or address literal and returns
+// the reason it is invalid, or an empty string
+func checkEmailDomain(domain string) string {
+	if strings.HasPrefix(domain, "[") && strings.HasSuffix(domain, "]") {
+		literal := strings.TrimPrefix(domain[1:len(domain)-1], "IPv6:")
+		if net.ParseIP(literal) == nil {
+			return "invalid address literal"
+		}
+		return ""
+	}
+
+	if len(domain) > maxDomainLength {
+		return "domain exceeds " + strconv.Itoa(maxDomainLength) + " characters"
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "domain must be fully qualified"
+	}
+	for _, label := range labels {
+		if !validHostLabel(label) {
+			return "invalid domain label " + strconv.Quote(label)
+		}
+	}
+	return ""
+}
+
+// validHostLabel checks an LDH (letter-digit-hyphen) domain label
+func validHostLabel(label string) bool {
+	if label == "" || len(label) > maxLabelLength || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c >= 0x80) {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizePhone converts a phone number to E.164 ("+8613800138000").
+// Numbers without a country calling code use region; when region is empty,
+// mainland China mobile numbers (11 digits starting 13-19) are inferred.
+func NormalizePhone(number, region string) (string, error) {
+	cleaned := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(strings.TrimSpace(number))
+	if cleaned == "" {
+		return "", invalidTarget("phone number cannot be empty")
+	}
+
+	var digits string
+	switch {
+	case strings.HasPrefix(cleaned, "+"):
+		digits = cleaned[1:]
+	case strings.HasPrefix(cleaned, "00"):
+		digits = cleaned[2:]
+	default:
+		if !allDigits(cleaned) {
+			return "", invalidTarget("invalid phone number %q", number)
+		}
+		code, national, reason := inferCountry(cleaned, strings.ToUpper(region))
+		if reason != "" {
+			return "", invalidTarget("invalid phone number %q: %s", number, reason)
+		}
+		digits = code + national
+	}
+
+	if !allDigits(digits) || digits == "" || digits[0] == '0' {
+		return "", invalidTarget("invalid phone number %q", number)
+	}
+	if len(digits) < 8 || len(digits) > maxE164Digits {
+		return "", invalidTarget("invalid phone number %q: E.164 numbers have 8 to %d digits", number, maxE164Digits)
+	}
+	return "+" + digits, nil
+}
+
+// inferCountry determines the calling code for a national number. It
+// returns a non-empty reason when the country cannot be determined.
+func inferCountry(national, region string) (string, string, string) {
+	if region == "" && len(national) == 11 && national[0] == '1' && national[1] >= '3' {
+		return callingCodes["CN"], national, ""
+	}
+	if region == "" {
+		return "", "", "number has no country calling code and no default region is configured"
+	}
+
+	code, ok := callingCodes[region]
+	if !ok {
+		return "", "", "unsupported region " + strconv.Quote(region)
+	}
+	if code == "1" {
+		// NANP numbers may be written with the leading 1
+		if len(national) == 11 {
+			national = strings.TrimPrefix(national, "1")
+		}
+		if len(national) != 10 {
+			return "", "", "NANP numbers have 10 digits"
+		}
+		return code, national, ""
+	}
+	// Drop the national trunk prefix
+	return code, strings.TrimLeft(national, "0"), ""
+}
+
+// allDigits reports whether s consists only of ASCII digits
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http(s) URL with a valid host
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return invalidTarget("invalid webhook URL %q: %s", raw, err.Error())
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return invalidTarget("invalid webhook URL %q: scheme must be http or https", raw)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return invalidTarget("invalid webhook URL %q: missing host", raw)
+	}
+	if u.Fragment != "" {
+		return invalidTarget("invalid webhook URL %q: fragments are not sent to servers", raw)
+	}
+
+	host := u.Hostname()
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return invalidTarget("invalid webhook URL %q: bad port", raw)
+		}
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	for _, label := range strings.Split(host, ".") {
+		if !validHostLabel(label) {
+			return invalidTarget("invalid webhook URL %q: bad host name", raw)
+		}
+	}
+	return nil
+}
+
+// Feishu ID prefixes
+const (
+	feishuOpenIDPrefix  = "ou_"
+	feishuUnionIDPrefix = "on_"
+	feishuChatIDPrefix  = "oc_"
+)
+
+// ValidateFeishuID checks Feishu user and chat identifiers. Users may be
+// open IDs (ou_), union IDs (on_), email addresses or tenant user IDs;
+// groups must be chat IDs (oc_).
+func ValidateFeishuID(targetType, id string) error {
+	switch targetType {
+	case TargetTypeGroup, TargetTypeChannel:
+		if !strings.HasPrefix(id, feishuChatIDPrefix) || len(id) == len(feishuChatIDPrefix) {
+			return invalidTarget("invalid Feishu chat ID %q: expected oc_ prefix", id)
+		}
+	case TargetTypeUser:
+		switch {
+		case strings.HasPrefix(id, feishuChatIDPrefix):
+			return invalidTarget("invalid Feishu user ID %q: oc_ identifies a chat, use a group target", id)
+		case strings.HasPrefix(id, feishuOpenIDPrefix), strings.HasPrefix(id, feishuUnionIDPrefix):
+			if len(id) == 3 {
+				return invalidTarget("invalid Feishu user ID %q", id)
+			}
+		case strings.Contains(id, "@"):
+			if _, err := NormalizeEmail(id); err != nil {
+				return invalidTarget("invalid Feishu user ID %q: not a valid email address", id)
+			}
+		default:
+			for i := 0; i < len(id); i++ {
+				c := id[i]
+				if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+					return invalidTarget("invalid Feishu user ID %q", id)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// invalidTarget creates an ErrInvalidTarget error
+func invalidTarget(format string, args ...interface{}) error {
+	return errors.Newf(errors.ErrInvalidTarget, format, args...)
+}
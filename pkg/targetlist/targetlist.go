@@ -0,0 +1,217 @@
+// Package targetlist provides dynamic target lists for NotifyHub.
+//
+// A target such as target.NewList("product-x-subscribers") is expanded at
+// send time into the members returned by a list provider, so subscription
+// logic can stay in the product service that owns it:
+//
+//	lists := targetlist.NewHTTP(targetlist.HTTPConfig{
+//		URL:     "https://products.internal/lists/{list}/members",
+//		Headers: map[string]string{"Authorization": "Bearer " + token},
+//	})
+//	client, _ := notifyhub.NewClientFromOptions(config.WithListProvider(lists), ...)
+package targetlist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider returns the current members of a target list.
+// It satisfies contact.ListProvider.
+type Provider interface {
+	Members(ctx context.Context, list string) ([]string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface
+type ProviderFunc func(ctx context.Context, list string) ([]string, error)
+
+// Members calls f(ctx, list)
+func (f ProviderFunc) Members(ctx context.Context, list string) ([]string, error) {
+	return f(ctx, list)
+}
+
+// Default HTTP provider settings
+const (
+	DefaultCacheTTL = time.Minute
+	DefaultTimeout  = 10 * time.Second
+)
+
+// HTTPConfig configures the HTTP list provider
+type HTTPConfig struct {
+	// URL of the list endpoint. "{list}" is replaced with the escaped list
+	// name; without it the name is sent as the "list" query parameter.
+	URL string `json:"url"`
+
+	// Headers are added to every request (e.g. Authorization)
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// CacheTTL is how long a fetched list is used before it is revalidated
+	// with the endpoint using its ETag
+	CacheTTL time.Duration `json:"cache_ttl,omitempty"`
+
+	// MaxStale is how long past CacheTTL a cached list may still be used
+	// when the endpoint fails. Zero returns the error instead.
+	MaxStale time.Duration `json:"max_stale,omitempty"`
+
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// cachedList is a fetched list and its validator
+type cachedList struct {
+	members []string
+	etag    string
+	fetched time.Time
+}
+
+// HTTP fetches target lists from an HTTP endpoint.
+//
+// The endpoint returns either a JSON array of member references or an object
+// with a "members" array. Members use the same forms as group members:
+// contact IDs, email addresses or explicit targets such as
+// "email:ops@example.com".
+type HTTP struct {
+	config HTTPConfig
+	client *http.Client
+	cache  map[string]*cachedList
+	mu     sync.Mutex
+	now    func() time.Time
+}
+
+// NewHTTP creates an HTTP list provider
+func NewHTTP(cfg HTTPConfig) *HTTP {
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &HTTP{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		cache:  make(map[string]*cachedList),
+		now:    time.Now,
+	}
+}
+
+// Members returns the members of a list, from the cache while it is fresh
+func (p *HTTP) Members(ctx context.Context, list string) ([]string, error) {
+	p.mu.Lock()
+	cached := p.cache[list]
+	p.mu.Unlock()
+
+	now := p.now()
+	if cached != nil && now.Sub(cached.fetched) < p.config.CacheTTL {
+		return cached.members, nil
+	}
+
+	etag := ""
+	if cached != nil {
+		etag = cached.etag
+	}
+	fetched, err := p.fetch(ctx, list, etag)
+	if err != nil {
+		if cached != nil && now.Sub(cached.fetched) < p.config.CacheTTL+p.config.MaxStale {
+			return cached.members, nil
+		}
+		return nil, err
+	}
+
+	if fetched == nil {
+		// Not modified: keep the cached members
+		fetched = &cachedList{members: cached.members, etag: cached.etag}
+	}
+	fetched.fetched = now
+
+	p.mu.Lock()
+	p.cache[list] = fetched
+	p.mu.Unlock()
+	return fetched.members, nil
+}
+
+// Invalidate drops a cached list, or every cached list when list is empty
+func (p *HTTP) Invalidate(list string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if list == "" {
+		p.cache = make(map[string]*cachedList)
+		return
+	}
+	delete(p.cache, list)
+}
+
+// fetch requests a list from the endpoint. It returns nil without an error
+// when the endpoint reports that the list has not changed since etag.
+func (p *HTTP) fetch(ctx context.Context, list, etag string) (*cachedList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.listURL(list), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create list request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range p.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: request failed: %w", list, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("list %s: failed to read response body: %w", list, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("list %s: request failed with status %d: %s", list, resp.StatusCode, string(body))
+	}
+
+	members, err := parseMembers(body)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: failed to parse response: %w", list, err)
+	}
+	return &cachedList{members: members, etag: resp.Header.Get("ETag")}, nil
+}
+
+// listURL builds the endpoint URL for a list
+func (p *HTTP) listURL(list string) string {
+	if strings.Contains(p.config.URL, "{list}") {
+		return strings.ReplaceAll(p.config.URL, "{list}", url.PathEscape(list))
+	}
+
+	sep := "?"
+	if strings.Contains(p.config.URL, "?") {
+		sep = "&"
+	}
+	return p.config.URL + sep + "list=" + url.QueryEscape(list)
+}
+
+// parseMembers decodes a JSON array of members or an object with a "members" array
+func parseMembers(body []byte) ([]string, error) {
+	var members []string
+	if err := json.Unmarshal(body, &members); err == nil {
+		return members, nil
+	}
+
+	var wrapped struct {
+		Members []string `json:"members"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return nil, err
+	}
+	return wrapped.Members, nil
+}
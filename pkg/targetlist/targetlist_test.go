@@ -0,0 +1,103 @@
+package targetlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTP_MembersCacheAndETag(t *testing.T) {
+	var requests, notModified atomic.Int32
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/lists/product-x/members" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"members": ["alice", "email:fan@example.com"]}`))
+	}))
+	defer server.Close()
+
+	provider := NewHTTP(HTTPConfig{
+		URL:      server.URL + "/lists/{list}/members",
+		Headers:  map[string]string{"Authorization": "Bearer secret"},
+		CacheTTL: time.Minute,
+		MaxStale: time.Hour,
+	})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	provider.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	want := []string{"alice", "email:fan@example.com"}
+
+	steps := []struct {
+		name         string
+		advance      time.Duration
+		fail         bool
+		wantRequests int32
+	}{
+		{"first fetch", 0, false, 1},
+		{"fresh cache", 30 * time.Second, false, 1},
+		{"revalidated with ETag", time.Minute, false, 2},
+		{"stale on error", 2 * time.Minute, true, 3},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		failing.Store(step.fail)
+
+		got, err := provider.Members(ctx, "product-x")
+		if err != nil {
+			t.Fatalf("%s: Members() error = %v", step.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Members() = %v, want %v", step.name, got, want)
+		}
+		if requests.Load() != step.wantRequests {
+			t.Errorf("%s: requests = %d, want %d", step.name, requests.Load(), step.wantRequests)
+		}
+	}
+	if notModified.Load() != 1 {
+		t.Errorf("not modified responses = %d, want 1", notModified.Load())
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := provider.Members(ctx, "product-x"); err == nil {
+		t.Error("Members() expected error once the cached list is too stale")
+	}
+}
+
+func TestHTTP_ListURLAndArrayResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("list") != "weekly digest" || r.URL.Query().Get("tenant") != "acme" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`["bob@example.com"]`))
+	}))
+	defer server.Close()
+
+	provider := NewHTTP(HTTPConfig{URL: server.URL + "/subscribers?tenant=acme"})
+	got, err := provider.Members(context.Background(), "weekly digest")
+	if err != nil {
+		t.Fatalf("Members() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"bob@example.com"}) {
+		t.Errorf("Members() = %v, want bob", got)
+	}
+}
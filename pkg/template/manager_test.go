@@ -0,0 +1,43 @@
+package template
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTextEngine_Render(t *testing.T) {
+	engine := NewTextEngine()
+	if err := engine.Parse("alert", "{{.Host}} is {{.Status}}"); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := engine.Render(context.Background(), "alert", map[string]string{"Host": "db-1", "Status": "down"})
+	if err != nil || got != "db-1 is down" {
+		t.Errorf("Render() = %q, %v, want %q", got, err, "db-1 is down")
+	}
+	if _, err := engine.Render(context.Background(), "missing", nil); err == nil {
+		t.Error("Render() of a missing template error = nil")
+	}
+}
+
+func BenchmarkTextEngine_Render(b *testing.B) {
+	engine := NewTextEngine()
+	err := engine.Parse("alert", `{{.Title}} on {{.Host}}
+{{range .Labels}}- {{.}}
+{{end}}Priority: {{if .Urgent}}urgent{{else}}normal{{end}}`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"Title":  "Disk full",
+		"Host":   "db-1",
+		"Labels": []string{"team=db", "severity=critical", "env=prod"},
+		"Urgent": true,
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := engine.Render(ctx, "alert", data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -40,6 +40,23 @@ type Logger interface {
 	Debug(msg string, args ...any)
 }
 
+// LevelEnabler is implemented by loggers that can tell whether they log a
+// level, which lets callers skip building the arguments of entries that
+// would be dropped.
+type LevelEnabler interface {
+	// Enabled reports whether messages of the level are logged.
+	Enabled(level LogLevel) bool
+}
+
+// Enabled reports whether l logs messages of a level. Loggers that do not
+// implement LevelEnabler are assumed to log every level.
+func Enabled(l Logger, level LogLevel) bool {
+	if e, ok := l.(LevelEnabler); ok {
+		return e.Enabled(level)
+	}
+	return true
+}
+
 // StandardLogger is the default implementation of the Logger interface, using the standard log package.
 type StandardLogger struct {
 	logger *log.Logger
@@ -63,6 +80,11 @@ func (l *StandardLogger) LogMode(level LogLevel) Logger {
 	return &newLogger
 }
 
+// Enabled reports whether messages of the level are logged.
+func (l *StandardLogger) Enabled(level LogLevel) bool {
+	return l.level >= level
+}
+
 // Info logs an informational message.
 func (l *StandardLogger) Info(msg string, args ...any) {
 	if l.level >= Info {
@@ -115,6 +137,9 @@ type discardLogger struct{}
 // LogMode returns the discard logger itself.
 func (d *discardLogger) LogMode(LogLevel) Logger { return d }
 
+// Enabled reports false for every level.
+func (d *discardLogger) Enabled(LogLevel) bool { return false }
+
 // Info does nothing.
 func (d *discardLogger) Info(string, ...any) {}
 
@@ -127,6 +152,49 @@ func (d *discardLogger) Error(string, ...any) {}
 // Debug does nothing.
 func (d *discardLogger) Debug(string, ...any) {}
 
+// fieldLogger adds fields to the entries of a logger
+type fieldLogger struct {
+	logger Logger
+	fields []any
+}
+
+// With returns a logger adding key-value pairs to every entry of l, or l
+// itself when there are none.
+func With(l Logger, args ...any) Logger {
+	if len(args) == 0 {
+		return l
+	}
+	if f, ok := l.(*fieldLogger); ok {
+		return &fieldLogger{logger: f.logger, fields: append(append([]any(nil), f.fields...), args...)}
+	}
+	return &fieldLogger{logger: l, fields: args}
+}
+
+// LogMode sets the log level of the underlying logger.
+func (f *fieldLogger) LogMode(level LogLevel) Logger {
+	return &fieldLogger{logger: f.logger.LogMode(level), fields: f.fields}
+}
+
+// Enabled reports whether the underlying logger logs the level.
+func (f *fieldLogger) Enabled(level LogLevel) bool { return Enabled(f.logger, level) }
+
+// Info logs an informational message with the fields.
+func (f *fieldLogger) Info(msg string, args ...any) { f.logger.Info(msg, f.with(args)...) }
+
+// Warn logs a warning message with the fields.
+func (f *fieldLogger) Warn(msg string, args ...any) { f.logger.Warn(msg, f.with(args)...) }
+
+// Error logs an error message with the fields.
+func (f *fieldLogger) Error(msg string, args ...any) { f.logger.Error(msg, f.with(args)...) }
+
+// Debug logs a debug message with the fields.
+func (f *fieldLogger) Debug(msg string, args ...any) { f.logger.Debug(msg, f.with(args)...) }
+
+// with returns args followed by the fields
+func (f *fieldLogger) with(args []any) []any {
+	return append(append(make([]any, 0, len(args)+len(f.fields)), args...), f.fields...)
+}
+
 // Discard is a logger that discards all output.
 var Discard Logger = &discardLogger{}
 
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+// plainLogger is a logger that does not report its level
+type plainLogger struct{ Logger }
+
+func TestEnabled(t *testing.T) {
+	standard := NewStandardLogger(log.New(&bytes.Buffer{}, "", 0), Warn, "")
+	tests := []struct {
+		name   string
+		logger Logger
+		level  LogLevel
+		want   bool
+	}{
+		{"standard below its level", standard, Debug, false},
+		{"standard at its level", standard, Warn, true},
+		{"standard above its level", standard, Error, true},
+		{"standard in debug mode", standard.LogMode(Debug), Debug, true},
+		{"discard", Discard, Error, false},
+		{"with fields", With(standard, "request_id", "r-1"), Info, false},
+		{"with fields in debug mode", With(standard.LogMode(Debug), "request_id", "r-1"), Debug, true},
+		{"without level", plainLogger{Discard}, Debug, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Enabled(tt.logger, tt.level); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
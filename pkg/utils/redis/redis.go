@@ -0,0 +1,241 @@
+// Package redis provides a minimal Redis client speaking the Redis protocol
+// (RESP) over a pool of connections, enough for the scripts that keep the
+// shared state of NotifyHub, such as quota buckets and persistent queues,
+// without a client library
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configure the connections to a Redis server
+type Options struct {
+	Addr     string // host:port
+	Username string // for Redis ACL users; empty uses the default user
+	Password string
+	DB       int
+
+	// Timeout bounds connecting and each command; zero uses 5s
+	Timeout time.Duration
+
+	// PoolSize is the number of idle connections kept; zero uses 4
+	PoolSize int
+}
+
+// Error is an error reply of the server
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Pool runs commands on a pool of connections to a server, opened when
+// they are first needed. It is safe for concurrent use.
+type Pool struct {
+	opts Options
+	idle chan *conn
+}
+
+// NewPool creates a pool of connections to a server
+func NewPool(opts Options) (*Pool, error) {
+	if opts.Addr == "" {
+		return nil, errors.New("redis address is required")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 4
+	}
+	return &Pool{opts: opts, idle: make(chan *conn, opts.PoolSize)}, nil
+}
+
+// Do sends a command and returns its reply: a string, an int64, nil, a
+// []interface{} of replies, or an Error
+func (p *Pool) Do(ctx context.Context, args ...string) (interface{}, error) {
+	c, err := p.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.do(ctx, p.opts.Timeout, args...)
+	p.release(c, err)
+	return reply, err
+}
+
+// Close closes the idle connections
+func (p *Pool) Close() error {
+	for {
+		select {
+		case c := <-p.idle:
+			_ = c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// conn returns an idle connection, or opens one
+func (p *Pool) conn(ctx context.Context) (*conn, error) {
+	select {
+	case c := <-p.idle:
+		return c, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: p.opts.Timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", p.opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis %s: %w", p.opts.Addr, err)
+	}
+	c := &conn{Conn: nc, reader: bufio.NewReader(nc)}
+	if p.opts.Password != "" {
+		auth := []string{"AUTH", p.opts.Password}
+		if p.opts.Username != "" {
+			auth = []string{"AUTH", p.opts.Username, p.opts.Password}
+		}
+		if _, err := c.do(ctx, p.opts.Timeout, auth...); err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("redis authentication failed: %w", err)
+		}
+	}
+	if p.opts.DB != 0 {
+		if _, err := c.do(ctx, p.opts.Timeout, "SELECT", strconv.Itoa(p.opts.DB)); err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("failed to select redis database %d: %w", p.opts.DB, err)
+		}
+	}
+	return c, nil
+}
+
+// release returns a connection to the pool, or closes it when the command
+// failed other than with an error reply or the pool is full
+func (p *Pool) release(c *conn, err error) {
+	var redisErr Error
+	if err != nil && !errors.As(err, &redisErr) {
+		_ = c.Close()
+		return
+	}
+	select {
+	case p.idle <- c:
+	default:
+		_ = c.Close()
+	}
+}
+
+// Script is a Lua script run by its digest, loaded on the servers that do
+// not know it yet
+type Script struct {
+	src string
+	sha string
+}
+
+// NewScript creates a script
+func NewScript(src string) *Script {
+	sum := sha1.Sum([]byte(src))
+	return &Script{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+// SHA returns the digest EVALSHA runs the script by
+func (s *Script) SHA() string {
+	return s.sha
+}
+
+// Run runs the script with keys and arguments, sending its source when the
+// server does not know its digest
+func (s *Script) Run(ctx context.Context, p *Pool, keys []string, args ...string) (interface{}, error) {
+	params := append([]string{strconv.Itoa(len(keys))}, keys...)
+	params = append(params, args...)
+	reply, err := p.Do(ctx, append([]string{"EVALSHA", s.sha}, params...)...)
+	var redisErr Error
+	if errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "NOSCRIPT") {
+		reply, err = p.Do(ctx, append([]string{"EVAL", s.src}, params...)...)
+	}
+	return reply, err
+}
+
+// conn is a connection used by one command at a time
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// do sends a command and reads its reply
+func (c *conn) do(ctx context.Context, timeout time.Duration, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+	return ReadReply(c.reader)
+}
+
+// ReadReply reads a RESP reply, such as a command sent to a fake server in
+// tests
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			value, err := ReadReply(r)
+			var redisErr Error
+			if errors.As(err, &redisErr) {
+				// Read the rest of the array so that the connection stays usable
+				value, err = redisErr, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("invalid redis reply %q", line)
+}
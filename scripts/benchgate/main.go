@@ -0,0 +1,174 @@
+// Command benchgate compares the output of go test -bench with a stored
+// baseline and fails when a benchmark regressed beyond a threshold:
+//
+//	benchgate [-time-threshold 25] [-alloc-threshold 10] baseline.txt current.txt
+//
+// Both files hold go test -bench -benchmem output, usually with -count
+// above one; the median of the samples of each benchmark is compared.
+// Time regressions are measured in ns/op and allocation regressions in
+// allocs/op. Benchmarks of the baseline that are missing from the current
+// run fail the gate, new ones are only reported. It is run by make
+// bench-check.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// samples are the values of each unit of a benchmark, e.g. ns/op
+type samples map[string][]float64
+
+// gomaxprocs matches the -N suffix go test adds to benchmark names
+var gomaxprocs = regexp.MustCompile(`-\d+$`)
+
+// parse reads the samples of the benchmarks of go test -bench output
+func parse(r io.Reader) (map[string]samples, error) {
+	results := make(map[string]samples)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// name, iterations, then value and unit pairs
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := gomaxprocs.ReplaceAllString(fields[0], "")
+		if results[name] == nil {
+			results[name] = make(samples)
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q of %s", fields[i], name)
+			}
+			results[name][fields[i+1]] = append(results[name][fields[i+1]], value)
+		}
+	}
+	return results, scanner.Err()
+}
+
+// median returns the median of values, 0 without values
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// delta returns the change from base to current in percent
+func delta(base, current float64) float64 {
+	if base == 0 {
+		if current == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (current - base) / base * 100
+}
+
+// thresholds are the regressions in percent a gate tolerates per unit
+type thresholds map[string]float64
+
+// compare writes a line for each benchmark and unit gated, and returns
+// the number of regressions
+func compare(w io.Writer, baseline, current map[string]samples, limits thresholds) int {
+	names := make([]string, 0, len(baseline)+len(current))
+	for name := range baseline {
+		names = append(names, name)
+	}
+	for name := range current {
+		if _, ok := baseline[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	units := make([]string, 0, len(limits))
+	for unit := range limits {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tunit\tbaseline\tcurrent\tdelta\t")
+	regressions := 0
+	for _, name := range names {
+		base, ok := baseline[name]
+		if !ok {
+			fmt.Fprintf(tw, "%s\t\t\t\t\tnew\n", name)
+			continue
+		}
+		cur, ok := current[name]
+		if !ok {
+			fmt.Fprintf(tw, "%s\t\t\t\t\tMISSING\n", name)
+			regressions++
+			continue
+		}
+		for _, unit := range units {
+			if len(base[unit]) == 0 || len(cur[unit]) == 0 {
+				continue
+			}
+			b, c := median(base[unit]), median(cur[unit])
+			d := delta(b, c)
+			status := "ok"
+			if d > limits[unit] {
+				status = "REGRESSION"
+				regressions++
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%.6g\t%.6g\t%+.1f%%\t%s\n", name, unit, b, c, d, status)
+		}
+	}
+	tw.Flush()
+	return regressions
+}
+
+func main() {
+	timeThreshold := flag.Float64("time-threshold", 25, "tolerated ns/op regression in percent")
+	allocThreshold := flag.Float64("alloc-threshold", 10, "tolerated allocs/op regression in percent")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: benchgate [flags] baseline.txt current.txt")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var results [2]map[string]samples
+	for i, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "benchgate:", err)
+			os.Exit(2)
+		}
+		results[i], err = parse(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "benchgate: %s: %v\n", path, err)
+			os.Exit(2)
+		}
+	}
+
+	limits := thresholds{"ns/op": *timeThreshold, "allocs/op": *allocThreshold}
+	if n := compare(os.Stdout, results[0], results[1], limits); n > 0 {
+		fmt.Fprintf(os.Stderr, "benchgate: %d regressions against %s\n", n, flag.Arg(0))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const baselineOutput = `goos: linux
+pkg: github.com/kart-io/notifyhub/pkg/message
+BenchmarkBuilder_Build-8   	 1000000	      1000 ns/op	    2111 B/op	      19 allocs/op
+BenchmarkBuilder_Build-8   	 1000000	      1100 ns/op	    2111 B/op	      19 allocs/op
+BenchmarkBuilder_Build-8   	 1000000	       900 ns/op	    2111 B/op	      19 allocs/op
+BenchmarkClient_SendFanOut/targets=10-8	  70000	     16000 ns/op	    608934 msg/s	   19641 B/op	     195 allocs/op
+PASS
+`
+
+func TestParse(t *testing.T) {
+	results, err := parse(strings.NewReader(baselineOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := results["BenchmarkBuilder_Build"]
+	if len(build["ns/op"]) != 3 || median(build["ns/op"]) != 1000 || median(build["allocs/op"]) != 19 {
+		t.Errorf("BenchmarkBuilder_Build = %v", build)
+	}
+	if got := median(results["BenchmarkClient_SendFanOut/targets=10"]["msg/s"]); got != 608934 {
+		t.Errorf("msg/s = %v, want 608934", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline, _ := parse(strings.NewReader(baselineOutput))
+	limits := thresholds{"ns/op": 25, "allocs/op": 10}
+	tests := []struct {
+		name        string
+		current     string
+		regressions int
+	}{
+		{
+			name: "within thresholds",
+			current: `BenchmarkBuilder_Build-4 1000000 1200 ns/op 2111 B/op 20 allocs/op
+BenchmarkClient_SendFanOut/targets=10-4 70000 15000 ns/op 195 allocs/op`,
+		},
+		{
+			name: "slower and allocating",
+			current: `BenchmarkBuilder_Build-4 1000000 1300 ns/op 2111 B/op 19 allocs/op
+BenchmarkClient_SendFanOut/targets=10-4 70000 15000 ns/op 250 allocs/op`,
+			regressions: 2,
+		},
+		{
+			name:        "missing benchmark",
+			current:     `BenchmarkBuilder_Build-4 1000000 1000 ns/op 2111 B/op 19 allocs/op`,
+			regressions: 1,
+		},
+		{
+			name: "new benchmark",
+			current: `BenchmarkBuilder_Build-4 1000000 1000 ns/op 2111 B/op 19 allocs/op
+BenchmarkClient_SendFanOut/targets=10-4 70000 15000 ns/op 195 allocs/op
+BenchmarkTextEngine_Render-4 1000000 900 ns/op 19 allocs/op`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, err := parse(strings.NewReader(tt.current))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			if got := compare(&out, baseline, current, limits); got != tt.regressions {
+				t.Errorf("compare() = %d regressions, want %d\n%s", got, tt.regressions, out.String())
+			}
+		})
+	}
+}